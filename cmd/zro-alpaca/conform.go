@@ -0,0 +1,442 @@
+package main
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	cli "github.com/urfave/cli/v2"
+)
+
+// conformClientID is the ClientID sent by the conform checks. Any fixed
+// value works; 1 keeps the requests easy to tell apart in the server logs.
+const conformClientID = 1
+
+// conformResponse is an Alpaca response as received, to check its format.
+type conformResponse struct {
+	status int
+	fields map[string]json.RawMessage
+
+	ClientTransactionID uint32          `json:"ClientTransactionID"`
+	ServerTransactionID uint32          `json:"ServerTransactionID"`
+	ErrorNumber         int             `json:"ErrorNumber"`
+	ErrorMessage        string          `json:"ErrorMessage"`
+	Value               json.RawMessage `json:"Value"`
+}
+
+// err returns the Alpaca error of the response, if any.
+func (r conformResponse) err() error {
+	if r.ErrorNumber != 0 {
+		return alpacaerrors.New(r.ErrorNumber, r.ErrorMessage)
+	}
+	return nil
+}
+
+// conformer runs a subset of the ConformU dome checks against the device API
+// at baseURL, e.g. http://host:11111/api/v1/dome/0.
+type conformer struct {
+	baseURL string
+	http    *http.Client
+	timeout time.Duration // Time given to a connection or a slew to complete
+	txID    uint32
+	rep     *report
+}
+
+func newConformer(baseURL string, timeout time.Duration) *conformer {
+	return &conformer{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: timeout},
+		timeout: timeout,
+		rep:     &report{title: "ZRO Alpaca conform report"},
+	}
+}
+
+// call sends a request and decodes the response, failing on transport errors
+// and on HTTP statuses other than 200.
+func (c *conformer) call(method, name string, params url.Values) (conformResponse, error) {
+	resp, err := c.send(method, name, params)
+	if err != nil {
+		return resp, err
+	}
+	if resp.status != http.StatusOK {
+		return resp, fmt.Errorf("%s %s: HTTP status %d", method, name, resp.status)
+	}
+	if resp.fields == nil {
+		return resp, fmt.Errorf("%s %s: the response is not a JSON object", method, name)
+	}
+	return resp, nil
+}
+
+func (c *conformer) send(method, name string, params url.Values) (conformResponse, error) {
+	c.txID++
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("ClientID", fmt.Sprint(conformClientID))
+	params.Set("ClientTransactionID", fmt.Sprint(c.txID))
+
+	target := c.baseURL + "/" + name
+	var req *http.Request
+	var err error
+	if method == http.MethodGet {
+		req, err = http.NewRequest(method, target+"?"+params.Encode(), nil)
+	} else {
+		req, err = http.NewRequest(method, target, strings.NewReader(params.Encode()))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return conformResponse{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	httpResp, err := c.http.Do(req)
+	if err != nil {
+		return conformResponse{}, err
+	}
+	defer httpResp.Body.Close()
+
+	resp := conformResponse{status: httpResp.StatusCode}
+	if resp.status != http.StatusOK {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if err := json.Unmarshal(body, &resp.fields); err != nil {
+		return resp, fmt.Errorf("%s %s: invalid JSON: %v", method, name, err)
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return resp, fmt.Errorf("%s %s: invalid response: %v", method, name, err)
+	}
+	return resp, nil
+}
+
+// get reads a property and decodes its value into value.
+func (c *conformer) get(name string, value any) error {
+	resp, err := c.call(http.MethodGet, name, nil)
+	if err != nil {
+		return err
+	}
+	if err := resp.err(); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp.Value, value); err != nil {
+		return fmt.Errorf("%s: unexpected value %s: %v", name, resp.Value, err)
+	}
+	return nil
+}
+
+// put calls a method and returns its Alpaca error, if any.
+func (c *conformer) put(name string, params url.Values) error {
+	resp, err := c.call(http.MethodPut, name, params)
+	if err != nil {
+		return err
+	}
+	return resp.err()
+}
+
+// errorNumber returns the Alpaca error number of err, or 0.
+func errorNumber(err error) int {
+	var alpacaErr alpacaerrors.Error
+	if errors.As(err, &alpacaErr) {
+		return alpacaErr.Number
+	}
+	return 0
+}
+
+// domeCapabilities are the Can* properties of a dome.
+type domeCapabilities struct {
+	FindHome, Park, SetAltitude, SetAzimuth, SetPark, SetShutter, Slave, SyncAzimuth bool
+}
+
+// run runs the checks. The motion checks slew the dome and are only run if
+// motion is true.
+func (c *conformer) run(motion bool) {
+	c.checkResponseFormat()
+	c.checkInterfaceVersion()
+
+	var connected bool
+	err := c.get("connected", &connected)
+	c.rep.add("Connected", err, fmt.Sprintf("connected is %t", connected))
+	if err != nil {
+		return
+	}
+	if !connected {
+		if err := c.connect(); err != nil {
+			c.rep.add("Connect", err, "")
+			return
+		}
+		c.rep.add("Connect", nil, "connected")
+		defer func() {
+			err := c.put("connected", url.Values{"Connected": {"false"}})
+			c.rep.add("Disconnect", err, "disconnected, as found")
+		}()
+	}
+
+	c.checkString("Name", "name")
+	c.checkString("Description", "description")
+	c.checkString("DriverInfo", "driverinfo")
+	c.checkString("DriverVersion", "driverversion")
+
+	var actions []string
+	err = c.get("supportedactions", &actions)
+	c.rep.add("SupportedActions", err, fmt.Sprintf("%d actions", len(actions)))
+
+	caps, err := c.capabilities()
+	c.rep.add("Capabilities", err, fmt.Sprintf("%+v", caps))
+	if err != nil {
+		return
+	}
+
+	c.checkRange("Azimuth", "azimuth", caps.SetAzimuth, 0, 360)
+	c.checkRange("Altitude", "altitude", caps.SetAltitude, 0, 90)
+	c.checkRange("ShutterStatus", "shutterstatus", caps.SetShutter, 0, 4)
+	c.checkBool("AtHome", "athome", caps.FindHome)
+	c.checkBool("AtPark", "atpark", caps.Park)
+	c.checkBool("Slewing", "slewing", true)
+	c.checkSlaved(caps)
+	c.checkInvalidAzimuth(caps)
+	c.checkUnknownMethod()
+
+	if motion {
+		c.checkSlewAndAbort(caps)
+	} else {
+		c.rep.skip("Slew and abort", "run with --motion to slew the dome")
+	}
+}
+
+// checkResponseFormat checks that the responses have the transaction IDs and
+// echo the client transaction ID. The error fields may be omitted on success.
+func (c *conformer) checkResponseFormat() {
+	resp, err := c.call(http.MethodGet, "interfaceversion", nil)
+	if err == nil {
+		for _, field := range []string{"ClientTransactionID", "ServerTransactionID"} {
+			if _, ok := resp.fields[field]; !ok {
+				err = fmt.Errorf("the response has no %s field", field)
+				break
+			}
+		}
+	}
+	if err == nil && resp.ClientTransactionID != c.txID {
+		err = fmt.Errorf("ClientTransactionID is %d, want %d", resp.ClientTransactionID, c.txID)
+	}
+	if err == nil && resp.ServerTransactionID == 0 {
+		err = errors.New("ServerTransactionID is 0")
+	}
+	c.rep.add("Response format", err, "transaction IDs present and echoed")
+}
+
+func (c *conformer) checkInterfaceVersion() {
+	var version int
+	err := c.get("interfaceversion", &version)
+	if err == nil && (version < 1 || version > 3) {
+		err = fmt.Errorf("unexpected interface version %d", version)
+	}
+	c.rep.add("InterfaceVersion", err, fmt.Sprintf("version %d", version))
+}
+
+// connect connects the device, waiting for an asynchronous connection to
+// complete.
+func (c *conformer) connect() error {
+	if err := c.put("connected", url.Values{"Connected": {"true"}}); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(c.timeout)
+	for {
+		var connected bool
+		if err := c.get("connected", &connected); err != nil {
+			return err
+		}
+		if connected {
+			return nil
+		}
+
+		var connecting bool
+		if err := c.get("connecting", &connecting); err != nil || !connecting {
+			return errors.New("connected is still false after setting it")
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("still connecting after %s", c.timeout)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+func (c *conformer) checkString(check, name string) {
+	var value string
+	err := c.get(name, &value)
+	if err == nil && value == "" {
+		err = fmt.Errorf("%s is empty", name)
+	}
+	c.rep.add(check, err, value)
+}
+
+func (c *conformer) capabilities() (domeCapabilities, error) {
+	var caps domeCapabilities
+	for name, value := range map[string]*bool{
+		"canfindhome":    &caps.FindHome,
+		"canpark":        &caps.Park,
+		"cansetaltitude": &caps.SetAltitude,
+		"cansetazimuth":  &caps.SetAzimuth,
+		"cansetpark":     &caps.SetPark,
+		"cansetshutter":  &caps.SetShutter,
+		"canslave":       &caps.Slave,
+		"cansyncazimuth": &caps.SyncAzimuth,
+	} {
+		if err := c.get(name, value); err != nil {
+			return caps, err
+		}
+	}
+	return caps, nil
+}
+
+// checkRange reads a numeric property, which must be implemented and within
+// [lo, hi] if supported is true.
+func (c *conformer) checkRange(check, name string, supported bool, lo, hi float64) {
+	var value float64
+	err := c.get(name, &value)
+	switch {
+	case !supported && (err == nil || errorNumber(err) == alpacaerrors.ErrNotImplemented.Number):
+		c.rep.skip(check, "not supported")
+		return
+	case err == nil && (value < lo || value > hi || math.IsNaN(value)):
+		err = fmt.Errorf("%s %v is outside [%v, %v]", name, value, lo, hi)
+	}
+	c.rep.add(check, err, fmt.Sprint(value))
+}
+
+// checkBool reads a boolean property, which must be implemented if supported
+// is true.
+func (c *conformer) checkBool(check, name string, supported bool) {
+	var value bool
+	err := c.get(name, &value)
+	if !supported && errorNumber(err) == alpacaerrors.ErrNotImplemented.Number {
+		c.rep.skip(check, "not supported")
+		return
+	}
+	c.rep.add(check, err, fmt.Sprint(value))
+}
+
+// checkSlaved reads Slaved and, for a dome that cannot be slaved, checks that
+// slaving it is rejected as not implemented.
+func (c *conformer) checkSlaved(caps domeCapabilities) {
+	var slaved bool
+	if err := c.get("slaved", &slaved); err != nil || caps.Slave {
+		c.rep.add("Slaved", err, fmt.Sprint(slaved))
+		return
+	}
+
+	err := c.put("slaved", url.Values{"Slaved": {"true"}})
+	switch {
+	case err == nil:
+		err = errors.New("slaving a dome that cannot be slaved succeeded")
+	case errorNumber(err) == alpacaerrors.ErrNotImplemented.Number:
+		err = nil
+	default:
+		err = fmt.Errorf("slaving a dome that cannot be slaved returned %v, want not implemented", err)
+	}
+	c.rep.add("Slaved", err, "setting Slaved is not implemented")
+}
+
+// checkInvalidAzimuth checks that an azimuth out of range is rejected,
+// without moving the dome.
+func (c *conformer) checkInvalidAzimuth(caps domeCapabilities) {
+	if !caps.SetAzimuth {
+		c.rep.skip("Invalid azimuth", "not supported")
+		return
+	}
+
+	err := c.put("slewtoazimuth", url.Values{"Azimuth": {"400"}})
+	switch {
+	case err == nil:
+		c.put("abortslew", nil)
+		err = errors.New("a slew to azimuth 400 was accepted")
+	case errorNumber(err) == alpacaerrors.ErrInvalidValue.Number:
+		err = nil
+	default:
+		err = fmt.Errorf("a slew to azimuth 400 returned %v, want invalid value", err)
+	}
+	c.rep.add("Invalid azimuth", err, "azimuth 400 rejected as invalid value")
+}
+
+// checkUnknownMethod checks that an unknown method is answered with a client
+// error status.
+func (c *conformer) checkUnknownMethod() {
+	resp, err := c.send(http.MethodGet, "nosuchmethod", nil)
+	if err == nil && (resp.status < 400 || resp.status > 499) {
+		err = fmt.Errorf("HTTP status %d, want 4xx", resp.status)
+	}
+	c.rep.add("Unknown method", err, fmt.Sprintf("HTTP status %d", resp.status))
+}
+
+// checkSlewAndAbort slews the dome by 10 degrees, aborts the slew and waits
+// for the dome to stop.
+func (c *conformer) checkSlewAndAbort(caps domeCapabilities) {
+	if !caps.SetAzimuth {
+		c.rep.skip("Slew and abort", "not supported")
+		return
+	}
+
+	var azimuth float64
+	err := c.get("azimuth", &azimuth)
+	if err == nil {
+		target := math.Mod(azimuth+10, 360)
+		err = c.put("slewtoazimuth", url.Values{"Azimuth": {fmt.Sprint(target)}})
+	}
+	if err == nil {
+		err = c.put("abortslew", nil)
+	}
+	if err == nil {
+		err = c.waitStopped()
+	}
+	c.rep.add("Slew and abort", err, "slew aborted")
+}
+
+// waitStopped waits until Slewing is false.
+func (c *conformer) waitStopped() error {
+	deadline := time.Now().Add(c.timeout)
+	for {
+		var slewing bool
+		if err := c.get("slewing", &slewing); err != nil {
+			return err
+		}
+		if !slewing {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("still slewing %s after the abort", c.timeout)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// conform runs a subset of the ConformU checks against an Alpaca dome and
+// prints a pass/fail report.
+func conform(c *cli.Context) error {
+	target := c.String("url")
+	if _, err := url.ParseRequestURI(target); err != nil {
+		return fmt.Errorf("invalid URL %q: %v", target, err)
+	}
+
+	checker := newConformer(target, c.Duration("timeout"))
+	checker.run(c.Bool("motion"))
+	checker.rep.print(os.Stdout)
+
+	if checker.rep.failed() > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
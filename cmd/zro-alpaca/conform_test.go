@@ -0,0 +1,77 @@
+package main
+
+import (
+	"alpaca/internal/mocks"
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newConformDome returns a mock dome that behaves as the Alpaca dome
+// interface requires.
+func newConformDome() *mocks.Dome {
+	var connected bool
+	status := alpaca.DomeStatus{Azimuth: 355}
+
+	return &mocks.Dome{
+		DeviceInfoFunc: func() alpaca.DeviceInfo {
+			return alpaca.DeviceInfo{Name: "Mock Dome", Description: "Mock dome", Type: alpaca.DeviceTypeDome, UniqueID: "mock"}
+		},
+		DriverInfoFunc: func() alpaca.DriverInfo {
+			return alpaca.DriverInfo{Name: "Mock", Version: "1.0", InterfaceVersion: 1}
+		},
+		ConnectedFunc:  func() bool { return connected },
+		ConnectFunc:    func() error { connected = true; return nil },
+		DisconnectFunc: func() error { connected = false; return nil },
+		CapabilitiesFunc: func() alpaca.DomeCapabilities {
+			return alpaca.DomeCapabilities{CanSetAzimuth: true, CanSetShutter: true}
+		},
+		StatusFunc:        func() alpaca.DomeStatus { return status },
+		SetSlavedFunc:     func(bool) error { return errors.ErrNotImplemented },
+		SlewToAzimuthFunc: func(az float64) error { status.Azimuth = az; return nil },
+	}
+}
+
+func TestConform(t *testing.T) {
+	dome := newConformDome()
+	server := alpaca.NewServer(alpaca.ServerDescription{Name: "Test"}, []alpaca.Device{dome}, nil, nil)
+	ts := httptest.NewServer(server.AddRoutes())
+	defer ts.Close()
+
+	checker := newConformer(ts.URL+"/api/v1/dome/0", 5*time.Second)
+	checker.run(true)
+
+	var out bytes.Buffer
+	checker.rep.print(&out)
+	assert.Zero(t, checker.rep.failed(), out.String())
+	assert.Equal(t, 5.0, dome.StatusFunc().Azimuth, "the slew wraps around north")
+	assert.False(t, dome.ConnectedFunc(), "the dome is left disconnected, as found")
+}
+
+func TestConformFailures(t *testing.T) {
+	dome := newConformDome()
+	dome.StatusFunc = func() alpaca.DomeStatus { return alpaca.DomeStatus{Azimuth: 400} }
+	server := alpaca.NewServer(alpaca.ServerDescription{Name: "Test"}, []alpaca.Device{dome}, nil, nil)
+	ts := httptest.NewServer(server.AddRoutes())
+	defer ts.Close()
+
+	checker := newConformer(ts.URL+"/api/v1/dome/0", 5*time.Second)
+	checker.run(false)
+
+	failed := map[string]bool{}
+	for _, res := range checker.rep.results {
+		if !res.OK {
+			failed[res.Name] = true
+		}
+	}
+	assert.Equal(t, map[string]bool{"Azimuth": true}, failed)
+}
+
+func TestSelfTest(t *testing.T) {
+	assert.NoError(t, selfTest(5*time.Second))
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"alpaca/pkg/dome"
+	"alpaca/pkg/drivers/zro"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	cli "github.com/urfave/cli/v2"
+	bolt "go.etcd.io/bbolt"
+)
+
+// checkResult is the outcome of a single doctor check.
+type checkResult struct {
+	Name    string
+	OK      bool
+	Skipped bool // The check does not apply, e.g. to a capability the device lacks
+	Detail  string
+}
+
+// report collects the results of the doctor and conform checks and prints
+// them in a format that can be pasted into a bug report.
+type report struct {
+	title   string
+	results []checkResult
+}
+
+func (r *report) add(name string, err error, detail string) {
+	res := checkResult{Name: name, OK: err == nil, Detail: detail}
+	if err != nil {
+		res.Detail = err.Error()
+	}
+	r.results = append(r.results, res)
+}
+
+func (r *report) skip(name, detail string) {
+	r.results = append(r.results, checkResult{Name: name, OK: true, Skipped: true, Detail: detail})
+}
+
+func (r *report) failed() int {
+	n := 0
+	for _, res := range r.results {
+		if !res.OK {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *report) print(w io.Writer) {
+	fmt.Fprintln(w, r.title)
+	fmt.Fprintf(w, "Date:    %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(w, "Runtime: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintln(w)
+
+	for _, res := range r.results {
+		status := " OK "
+		if !res.OK {
+			status = "FAIL"
+		} else if res.Skipped {
+			status = "SKIP"
+		}
+		fmt.Fprintf(w, "[%s] %-22s %s\n", status, res.Name, res.Detail)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "%d checks, %d failed\n", len(r.results), r.failed())
+}
+
+// doctor runs a set of environment checks and prints a report.
+func doctor(c *cli.Context) error {
+	rep := report{title: "ZRO Alpaca doctor report"}
+	timeout := c.Duration("timeout")
+
+	cfg, err := checkDatabase(dbFile)
+	rep.add("Database", err, fmt.Sprintf("%s is writable", dbFile))
+	if err != nil {
+		cfg = dome.DefaultConfig()
+	}
+
+	port := c.Int("port")
+	rep.add("HTTP port", checkPort(port), fmt.Sprintf("port %d is available", port))
+	rep.add("Discovery", checkDiscovery(), "UDP port 32227 is available")
+
+	// Every broker is checked, so a failover broker that is down is found
+	// before it is needed. The traffic goes through the first one reachable.
+	var client mqtt.Client
+	for i, broker := range cfg.Brokers() {
+		name := "MQTT broker"
+		if i > 0 {
+			name = "MQTT failover broker"
+		}
+		c, err := connectBroker(cfg.MQTTConfig, broker, timeout)
+		rep.add(name, err, fmt.Sprintf("connected to %s", broker))
+		switch {
+		case err != nil:
+		case client == nil:
+			client = c
+		default:
+			c.Disconnect(100)
+		}
+	}
+	if client != nil {
+		defer client.Disconnect(100)
+
+		n, err := checkTopicTraffic(client, cfg.TopicRoot, timeout)
+		rep.add("Topic traffic", err, fmt.Sprintf("%d messages on %s/# in %s", n, cfg.TopicRoot, timeout))
+
+		version, err := checkFirmware(client, cfg.TopicRoot, timeout)
+		rep.add("Firmware", err, fmt.Sprintf("controller answered version %s", version))
+	}
+
+	rep.print(os.Stdout)
+
+	if rep.failed() > 0 {
+		return cli.Exit("", 1)
+	}
+	return nil
+}
+
+// checkDatabase opens the database, writes and deletes a probe key, and
+// returns the stored ZRO configuration. A missing database fails instead of
+// being created, since the server would start without its settings.
+func checkDatabase(path string) (dome.Config, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return dome.Config{}, fmt.Errorf("%s does not exist, check the working directory of the server", path)
+		}
+		return dome.Config{}, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return dome.Config{}, fmt.Errorf("%s is locked, is the server running?", path)
+	} else if err != nil {
+		return dome.Config{}, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("doctor"))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte("probe"), []byte(time.Now().String())); err != nil {
+			return err
+		}
+		return tx.DeleteBucket([]byte("doctor"))
+	})
+	if err != nil {
+		return dome.Config{}, fmt.Errorf("%s is not writable: %v", path, err)
+	}
+
+	store, err := zro.NewStore(db)
+	if err != nil {
+		return dome.Config{}, fmt.Errorf("failed to open ZRO store: %v", err)
+	}
+	cfg, err := store.GetConfig()
+	return cfg.Config, err
+}
+
+// checkPort verifies that the HTTP port can be bound.
+func checkPort(port int) error {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return fmt.Errorf("port %d is not available: %v", port, err)
+	}
+	return ln.Close()
+}
+
+// checkDiscovery verifies that the Alpaca discovery port can be bound.
+func checkDiscovery() error {
+	addr, err := net.ResolveUDPAddr("udp", "0.0.0.0:32227")
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return fmt.Errorf("UDP port 32227 is not available: %v", err)
+	}
+	return conn.Close()
+}
+
+func connectBroker(cfg dome.MQTTConfig, broker string, timeout time.Duration) (mqtt.Client, error) {
+	opts := mqtt.NewClientOptions()
+	opts.SetClientID(fmt.Sprintf("zro-alpaca-doctor-%d", os.Getpid()))
+	opts.AddBroker(broker)
+	opts.SetUsername(cfg.Username)
+	opts.SetPassword(cfg.Password)
+	opts.SetConnectTimeout(timeout)
+
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(timeout) {
+		return nil, fmt.Errorf("timeout connecting to %s", broker)
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", broker, err)
+	}
+	return client, nil
+}
+
+// checkTopicTraffic counts the messages received under the topic root.
+func checkTopicTraffic(client mqtt.Client, root string, wait time.Duration) (int, error) {
+	var count atomic.Int32
+
+	topic := root + "/#"
+	token := client.Subscribe(topic, 0, func(mqtt.Client, mqtt.Message) {
+		count.Add(1)
+	})
+	if token.Wait() && token.Error() != nil {
+		return 0, fmt.Errorf("failed to subscribe to %s: %v", topic, token.Error())
+	}
+	defer client.Unsubscribe(topic)
+
+	time.Sleep(wait)
+
+	n := int(count.Load())
+	if n == 0 {
+		return 0, fmt.Errorf("no messages on %s in %s", topic, wait)
+	}
+	return n, nil
+}
+
+// checkFirmware sends a version command to the controller and waits for the
+// acknowledgment on the responses topic.
+func checkFirmware(client mqtt.Client, root string, timeout time.Duration) (string, error) {
+	versions := make(chan string, 1)
+
+	topic := root + "/responses"
+	token := client.Subscribe(topic, 0, func(_ mqtt.Client, msg mqtt.Message) {
+		payload := string(msg.Payload())
+		if strings.HasPrefix(payload, "_ACK_V") {
+			select {
+			case versions <- strings.Trim(strings.TrimPrefix(payload, "_ACK_V="), "();"):
+			default:
+			}
+		}
+	})
+	if token.Wait() && token.Error() != nil {
+		return "", fmt.Errorf("failed to subscribe to %s: %v", topic, token.Error())
+	}
+	defer client.Unsubscribe(topic)
+
+	if token := client.Publish(root+"/commands", 0, false, "_V;"); token.Wait() && token.Error() != nil {
+		return "", fmt.Errorf("failed to publish command: %v", token.Error())
+	}
+
+	select {
+	case v := <-versions:
+		return v, nil
+	case <-time.After(timeout):
+		return "", fmt.Errorf("no response from controller in %s", timeout)
+	}
+}
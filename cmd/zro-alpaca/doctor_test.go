@@ -0,0 +1,28 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestCheckDatabase(t *testing.T) {
+	dir := t.TempDir()
+
+	missing := filepath.Join(dir, "missing.db")
+	_, err := checkDatabase(missing)
+	assert.ErrorContains(t, err, "does not exist")
+	assert.NoFileExists(t, missing, "not created by the check")
+
+	path := filepath.Join(dir, "alpaca.db")
+	db, err := bolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	cfg, err := checkDatabase(path)
+	require.NoError(t, err)
+	assert.Positive(t, cfg.TicksPerTurn, "the default configuration")
+}
@@ -2,35 +2,103 @@ package main
 
 import (
 	"alpaca/pkg/alpaca"
-	"alpaca/pkg/drivers/zro"
+	"alpaca/pkg/drivers"
+	"alpaca/pkg/indi"
+	"alpaca/pkg/metrics"
+	"alpaca/pkg/notify"
+	"alpaca/pkg/syslog"
+	"alpaca/pkg/telegram"
+	"alpaca/pkg/version"
 	"alpaca/templates"
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	cli "github.com/urfave/cli/v2"
-	bolt "go.etcd.io/bbolt"
 )
 
+const dbFile = "alpaca.db"
+
+// allDomes returns the domes, served to the INDI clients while enabled.
+func allDomes(devices []alpaca.Device) []alpaca.Dome {
+	var domes []alpaca.Dome
+	for _, dev := range devices {
+		if dome, ok := dev.(alpaca.Dome); ok {
+			domes = append(domes, dome)
+		}
+	}
+	return domes
+}
+
+// enabledDomes returns the enabled domes.
+func enabledDomes(devices []alpaca.Device) []alpaca.Dome {
+	var domes []alpaca.Dome
+	for _, dome := range allDomes(devices) {
+		if d, ok := dome.(alpaca.Disabler); !ok || !d.Disabled() {
+			domes = append(domes, dome)
+		}
+	}
+	return domes
+}
+
+// checkINDIListen refuses to serve INDI, which has no authentication, beyond
+// the loopback interface while API keys protect the dome commands.
+func checkINDIListen(host string, cfg alpaca.Config) error {
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	if !cfg.CommandsOpen() {
+		return fmt.Errorf("INDI has no authentication: cannot listen on %q while API keys are required to move the domes; listen on 127.0.0.1 or open the %s and %s scopes",
+			host, alpaca.ScopeRotation, alpaca.ScopeShutter)
+	}
+	return nil
+}
+
+// telegramDome returns the dome controlled by the Telegram bot: the first
+// enabled dome.
+func telegramDome(devices []alpaca.Device) alpaca.Dome {
+	if domes := enabledDomes(devices); len(domes) > 0 {
+		return domes[0]
+	}
+	return nil
+}
+
 func run(c *cli.Context) error {
 	if c.Bool("debug") {
 		log.SetLevel(log.DebugLevel)
 	}
+	alpaca.CaptureLogs(log.StandardLogger())
 
-	log.Info("ZRO Alpaca Server")
+	log.Infof("ZRO Alpaca Server %s", version.Get())
+
+	if dir := c.String("dump-dir"); dir != "" {
+		alpaca.SetDumpDir(dir)
+		log.Infof("Dumping API requests and responses to %s", dir)
+	}
+
+	if c.Bool("self-test") {
+		if err := selfTest(5 * time.Second); err != nil {
+			return err
+		}
+	}
 
 	tmpl, err := templates.LoadTemplates()
 	if err != nil {
 		return fmt.Errorf("failed to load templates: %v", err)
 	}
 
-	db, err := bolt.Open("alpaca.db", 0600, nil)
+	db, err := alpaca.OpenDB(dbFile)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %v", err)
 	}
@@ -41,30 +109,65 @@ func run(c *cli.Context) error {
 		return fmt.Errorf("failed to create store: %v", err)
 	}
 
-	// simDome, err := dome_simulator.NewDomeSimulator(0, db, tmpl, log.WithField("device", "dome"))
-	// if err != nil {
-	// 	return fmt.Errorf("failed to create dome simulator: %v", err)
-	// }
-	// defer simDome.Close()
-
-	zroDome, err := zro.NewDriver(1, db, tmpl, log.WithField("device", "zro"))
+	cfg, err := store.GetConfig()
 	if err != nil {
-		return fmt.Errorf("failed to create ZRO dome: %v", err)
+		return fmt.Errorf("failed to get server config: %v", err)
 	}
-	defer zroDome.Close()
+
+	// The devices are listed in the device file if given, in the server
+	// configuration otherwise, the simulator and the ZRO dome by default.
+	deviceConfigs := cfg.Devices
+	if path := c.String("devices"); path != "" {
+		if deviceConfigs, err = alpaca.ReadDeviceFile(path); err != nil {
+			return err
+		}
+		log.Infof("Creating the devices of %s; the device list of the setup page is ignored", path)
+	}
+	devices := drivers.NewDevices(deviceConfigs, db, tmpl)
 
 	serverDesc := alpaca.ServerDescription{
 		Name:                "ZRO Alpaca Server",
 		Manufacturer:        "ZRO",
-		ManufacturerVersion: "1.0",
+		ManufacturerVersion: version.Version,
 		Location:            "ZRO",
 	}
 
-	devices := []alpaca.Device{
-		// simDome,
-		zroDome,
+	history, err := alpaca.NewHistory(db)
+	if err != nil {
+		return err
 	}
+
 	server := alpaca.NewServer(serverDesc, devices, store, tmpl)
+	server.SetHistory(history)
+	server.SetProfiling(c.Bool("pprof"))
+	server.SetFederation(alpaca.NewFederation(c.Int("port"), log.WithField("component", "federation")))
+	defer server.SubscribeEvents(alpaca.Events())()
+	defer server.CollectMetrics(metrics.Default())()
+
+	if endpoint := c.String("otlp-endpoint"); endpoint != "" {
+		shutdown, err := alpaca.StartTracing(context.Background(), endpoint, "zro-alpaca")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				log.Errorf("Failed to flush the traces: %v", err)
+			}
+		}()
+		log.Infof("Sending the traces to %s", endpoint)
+	}
+
+	if target := c.String("syslog"); target != "" {
+		w, err := syslog.Dial(target, "zro-alpaca")
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		defer alpaca.Events().Subscribe("syslog", alpaca.SyslogHandler(w, log.WithField("component", "syslog")))()
+		log.Infof("Sending the device events to %s", target)
+	}
 
 	mux := server.AddRoutes()
 
@@ -73,27 +176,99 @@ func run(c *cli.Context) error {
 		Handler: mux,
 	}
 
+	// The options override the TLS settings of the setup page.
+	tlsCert, tlsKey, redirectPort := cfg.TLSCert, cfg.TLSKey, cfg.HTTPRedirectPort
+	if c.String("tls-cert") != "" || c.String("tls-key") != "" {
+		tlsCert, tlsKey = c.String("tls-cert"), c.String("tls-key")
+	}
+	if c.IsSet("http-redirect-port") {
+		redirectPort = c.Int("http-redirect-port")
+	}
+	var redirect *http.Server
+	if tlsCert != "" || tlsKey != "" {
+		if srv.TLSConfig, err = alpaca.NewTLSConfig(tlsCert, tlsKey); err != nil {
+			return err
+		}
+		if redirectPort > 0 {
+			redirect = &http.Server{
+				Addr:    fmt.Sprintf(":%d", redirectPort),
+				Handler: alpaca.RedirectHTTPS(c.Int("port")),
+			}
+		}
+	}
+
 	// Channel to listen for interrupt or terminate signals
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// The events are subscribed, so the connection history records them.
+	alpaca.ConnectOnStartup(ctx, devices)
+
 	var wg sync.WaitGroup
 
+	if dome := telegramDome(devices); dome != nil && cfg.Notifications.TelegramToken != "" {
+		bot := telegram.NewBot(cfg.Notifications.TelegramToken, cfg.Notifications.TelegramChatIDs, dome, log.WithField("component", "telegram"))
+		notify.Default().AddSink(bot)
+
+		wg.Add(1)
+		go func() {
+			bot.Run(ctx)
+			wg.Done()
+		}()
+	}
+
+	if port := c.Int("indi-port"); port != 0 {
+		host := c.String("indi-listen")
+		if err := checkINDIListen(host, cfg); err != nil {
+			return err
+		}
+		addr := net.JoinHostPort(host, strconv.Itoa(port))
+		indiServer := indi.NewServer(allDomes(devices), log.WithField("component", "indi"))
+		wg.Add(1)
+		go func() {
+			if err := indiServer.ListenAndServe(ctx, addr); err != nil {
+				log.Fatalf("Could not serve INDI on %s: %v", addr, err)
+			}
+			wg.Done()
+		}()
+	}
+
 	wg.Add(1)
 	go func() {
-		log.Debugf("Server started on %s", srv.Addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			log.Debugf("Server started on %s over HTTPS", srv.Addr)
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			log.Debugf("Server started on %s", srv.Addr)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Could not listen on %s: %v\n", srv.Addr, err)
 		}
 		wg.Done()
 	}()
 
+	if redirect != nil {
+		wg.Add(1)
+		go func() {
+			log.Debugf("Redirecting HTTP on %s to HTTPS", redirect.Addr)
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Could not listen on %s: %v\n", redirect.Addr, err)
+			}
+			wg.Done()
+		}()
+	}
+
 	// Create discovery responder
 	discoveryLogger := log.WithField("component", "discovery")
 	dr, err := alpaca.NewDiscoveryResponder("0.0.0.0", c.Int("port"), discoveryLogger)
 	if err != nil {
 		log.Fatalf("Failed to start discovery responder: %v", err)
 	}
+	if srv.TLSConfig != nil {
+		dr.SetHTTPS()
+	}
 
 	wg.Add(1)
 	go func() {
@@ -111,8 +286,17 @@ func run(c *cli.Context) error {
 	ctx2, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx2); err != nil {
-		return fmt.Errorf("server forced to shutdown: %v", err)
+	httpErr := srv.Shutdown(ctx2)
+	if redirect != nil {
+		if err := redirect.Shutdown(ctx2); err != nil {
+			log.Errorf("Failed to shut down the HTTP redirect: %v", err)
+		}
+	}
+	if err := server.Shutdown(ctx2); err != nil {
+		log.Errorf("Failed to shut down the devices: %v", err)
+	}
+	if httpErr != nil {
+		return fmt.Errorf("server forced to shutdown: %v", httpErr)
 	}
 
 	wg.Wait()
@@ -122,8 +306,9 @@ func run(c *cli.Context) error {
 
 func main() {
 	app := cli.App{
-		Name:  "ZRO Alpaca Server",
-		Usage: "ZRO Alpaca Server",
+		Name:    "ZRO Alpaca Server",
+		Usage:   "ZRO Alpaca Server",
+		Version: version.Get().String(),
 		Flags: []cli.Flag{
 			&cli.BoolFlag{
 				Name:    "debug",
@@ -139,6 +324,144 @@ func main() {
 				Value:   8090,
 				EnvVars: []string{"ALPACA_PORT"},
 			},
+			&cli.StringFlag{
+				Name:    "tls-cert",
+				Usage:   "Serve over HTTPS with this PEM certificate, reloaded when it changes",
+				EnvVars: []string{"ALPACA_TLS_CERT"},
+			},
+			&cli.StringFlag{
+				Name:    "tls-key",
+				Usage:   "PEM key of the HTTPS certificate",
+				EnvVars: []string{"ALPACA_TLS_KEY"},
+			},
+			&cli.IntFlag{
+				Name:    "http-redirect-port",
+				Usage:   "Redirect plain HTTP on this port to HTTPS, 0 to disable",
+				EnvVars: []string{"ALPACA_HTTP_REDIRECT_PORT"},
+			},
+			&cli.IntFlag{
+				Name:    "indi-port",
+				Usage:   fmt.Sprintf("Also serve the domes to INDI clients such as KStars on this port, usually %d; 0 to disable", indi.DefaultPort),
+				EnvVars: []string{"ALPACA_INDI_PORT"},
+			},
+			&cli.StringFlag{
+				Name:    "indi-listen",
+				Usage:   "Address the INDI server listens on; INDI has no authentication, so another address is refused while API keys protect the dome commands",
+				Value:   "127.0.0.1",
+				EnvVars: []string{"ALPACA_INDI_LISTEN"},
+			},
+			&cli.StringFlag{
+				Name:    "devices",
+				Usage:   "Create the devices listed in this JSON file, with their settings, instead of those of the setup page",
+				EnvVars: []string{"ALPACA_DEVICES"},
+			},
+			&cli.BoolFlag{
+				Name:    "self-test",
+				Usage:   "Run the conform checks against the dome simulator at startup and stop if any fails",
+				EnvVars: []string{"ALPACA_SELF_TEST"},
+			},
+			&cli.StringFlag{
+				Name:    "dump-dir",
+				Usage:   "Write every API request and response to per-day files in this directory",
+				EnvVars: []string{"ALPACA_DUMP_DIR"},
+			},
+			&cli.StringFlag{
+				Name:    "syslog",
+				Usage:   "Send the device events to the systemd journal (journald) or a syslog server (udp://host:514, tcp://host:514 or unix:///dev/log)",
+				EnvVars: []string{"ALPACA_SYSLOG"},
+			},
+			&cli.StringFlag{
+				Name:    "otlp-endpoint",
+				Usage:   "Send OpenTelemetry traces of the requests and of the controller commands to this OTLP/HTTP collector, e.g. http://localhost:4318",
+				EnvVars: []string{"ALPACA_OTLP_ENDPOINT"},
+			},
+			&cli.BoolFlag{
+				Name:    "pprof",
+				Usage:   "Serve the Go profiler under /debug/pprof/, to API keys with the configure scope or to the local host",
+				EnvVars: []string{"ALPACA_PPROF"},
+			},
+		},
+		Commands: []*cli.Command{
+			{
+				Name:   "doctor",
+				Usage:  "Check the environment and print a diagnosis report",
+				Action: doctor,
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Usage: "Time to wait for the broker and the controller",
+						Value: 5 * time.Second,
+					},
+				},
+			},
+			{
+				Name:   "conform",
+				Usage:  "Run a subset of the ConformU checks against an Alpaca dome",
+				Action: conform,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "url",
+						Usage:    "Device API URL, e.g. http://localhost:8090/api/v1/dome/1",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "motion",
+						Usage: "Also run the checks that slew the dome",
+					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Usage: "Time to wait for each request, a connection or a slew",
+						Value: 30 * time.Second,
+					},
+				},
+			},
+			{
+				Name:   "new-driver",
+				Usage:  "Generate the skeleton of a driver package and add it to the drivers",
+				Action: newDriver,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "type",
+						Usage:    "Alpaca device type, e.g. focuser",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "Driver and package name, e.g. mydev",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "dir",
+						Usage: "Root of the repository",
+						Value: ".",
+					},
+				},
+			},
+			{
+				Name:   "update",
+				Usage:  "Update the binary to the latest GitHub release",
+				Action: update,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "check",
+						Usage: "Only check for a newer release",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Install the latest release even if it is not newer than the running version",
+					},
+					&cli.StringFlag{
+						Name:  "repo",
+						Usage: "GitHub repository to download releases from",
+						Value: defaultRepo,
+					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Usage: "Timeout of each download",
+						Value: 2 * time.Minute,
+					},
+				},
+			},
 		},
 		Action: run,
 	}
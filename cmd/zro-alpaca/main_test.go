@@ -0,0 +1,26 @@
+package main
+
+import (
+	"alpaca/pkg/alpaca"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckINDIListen(t *testing.T) {
+	keys := alpaca.Config{APIKeys: []alpaca.APIKey{{Name: "admin", Scopes: []alpaca.Scope{alpaca.ScopeConfigure}}}}
+	open := keys
+	open.OpenScopes = []alpaca.Scope{alpaca.ScopeRotation, alpaca.ScopeShutter}
+	rotationOnly := keys
+	rotationOnly.OpenScopes = []alpaca.Scope{alpaca.ScopeRotation}
+
+	for _, host := range []string{"127.0.0.1", "::1", "localhost"} {
+		assert.NoError(t, checkINDIListen(host, keys), host)
+	}
+	for _, host := range []string{"", "0.0.0.0", "192.168.1.10"} {
+		assert.NoError(t, checkINDIListen(host, alpaca.Config{}), "without keys, %q", host)
+		assert.NoError(t, checkINDIListen(host, open), "with open control scopes, %q", host)
+		assert.Error(t, checkINDIListen(host, keys), "with keys, %q", host)
+		assert.Error(t, checkINDIListen(host, rotationOnly), "with the shutter scope closed, %q", host)
+	}
+}
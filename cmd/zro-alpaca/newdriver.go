@@ -0,0 +1,505 @@
+package main
+
+import (
+	"alpaca/pkg/alpaca"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	cli "github.com/urfave/cli/v2"
+)
+
+// driverName is the pattern of the driver names, which are also their
+// package names.
+var driverName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// scaffold describes the driver generated by the new-driver command.
+type scaffold struct {
+	Name    string            // Driver and package name, e.g. "mydev"
+	Title   string            // Human readable name, e.g. "Mydev"
+	Const   string            // Name of the driver constant, e.g. "DriverMydev"
+	Type    alpaca.DeviceType // Alpaca device type
+	UID     string            // UniqueID of the default instance
+	Handler bool              // Whether to generate the handler of the device type
+}
+
+// newDriver generates the skeleton of a driver package and adds it to the
+// drivers of pkg/drivers.
+func newDriver(c *cli.Context) error {
+	sc, err := newScaffold(c.String("name"), c.String("type"))
+	if err != nil {
+		return err
+	}
+
+	files, err := sc.generate(c.String("dir"))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		fmt.Printf("created %s\n", f)
+	}
+	fmt.Printf("added the %q driver to pkg/drivers/drivers.go\n", sc.Name)
+	fmt.Printf("run `go build ./...`, then add \"%s 0\" to the devices of the server setup page\n", sc.Name)
+	return nil
+}
+
+func newScaffold(name, deviceType string) (scaffold, error) {
+	if !driverName.MatchString(name) {
+		return scaffold{}, fmt.Errorf("invalid driver name %q: use lower case letters, digits and underscores", name)
+	}
+
+	var devType alpaca.DeviceType
+	for _, t := range alpaca.DeviceTypes {
+		if strings.EqualFold(string(t), deviceType) {
+			devType = t
+		}
+	}
+	if devType == "" {
+		return scaffold{}, fmt.Errorf("unknown device type %q, expected one of %v", deviceType, alpaca.DeviceTypes)
+	}
+
+	uid, err := newUUID()
+	if err != nil {
+		return scaffold{}, err
+	}
+
+	var title strings.Builder
+	for _, word := range strings.Split(name, "_") {
+		if word != "" {
+			title.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+
+	return scaffold{
+		Name:    name,
+		Title:   title.String(),
+		Const:   "Driver" + title.String(),
+		Type:    devType,
+		UID:     uid,
+		Handler: !alpaca.HasDeviceHandler(devType),
+	}, nil
+}
+
+// newUUID returns a random UUID.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// generate writes the driver package and its setup template under the
+// repository root dir, and adds the driver to pkg/drivers/drivers.go. It
+// returns the created files.
+func (sc scaffold) generate(dir string) ([]string, error) {
+	pkgDir := filepath.Join(dir, "pkg", "drivers", sc.Name)
+	if _, err := os.Stat(pkgDir); err == nil {
+		return nil, fmt.Errorf("%s already exists", pkgDir)
+	}
+
+	// drivers.go is changed first, so nothing is created if it cannot be.
+	driversFile := filepath.Join(dir, "pkg", "drivers", "drivers.go")
+	drivers, err := os.ReadFile(driversFile)
+	if err != nil {
+		return nil, fmt.Errorf("not the repository root: %v", err)
+	}
+	drivers, err = sc.addToDrivers(drivers)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{
+		filepath.Join(pkgDir, "driver.go"):                     driverTemplate,
+		filepath.Join(pkgDir, "store.go"):                      storeTemplate,
+		filepath.Join(dir, "templates", sc.Name+"_setup.html"): setupTemplate,
+	}
+	if sc.Handler {
+		files[filepath.Join(pkgDir, "handler.go")] = handlerTemplate
+	}
+
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		return nil, err
+	}
+	var created []string
+	for path, text := range files {
+		content, err := sc.render(text, strings.HasSuffix(path, ".go"))
+		if err != nil {
+			return created, fmt.Errorf("%s: %v", path, err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return created, err
+		}
+		created = append(created, path)
+	}
+
+	return created, os.WriteFile(driversFile, drivers, 0644)
+}
+
+func (sc scaffold) render(text string, goSource bool) ([]byte, error) {
+	tmpl, err := template.New("").Delims("[[", "]]").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, sc); err != nil {
+		return nil, err
+	}
+	if !goSource {
+		return buf.Bytes(), nil
+	}
+	return format.Source(buf.Bytes())
+}
+
+// addToDrivers adds the driver to the import, the driver names, Names and
+// New of pkg/drivers/drivers.go.
+func (sc scaffold) addToDrivers(src []byte) ([]byte, error) {
+	text := string(src)
+	if strings.Contains(text, fmt.Sprintf("%q", "alpaca/pkg/drivers/"+sc.Name)) {
+		return nil, fmt.Errorf("the %q driver is already in drivers.go", sc.Name)
+	}
+
+	// The import is sorted by gofmt, the other lines go after the existing
+	// drivers, before their anchor.
+	edits := []struct {
+		anchor, insert string
+		after          bool
+	}{
+		{"import (\n", fmt.Sprintf("\t%q\n", "alpaca/pkg/drivers/"+sc.Name), true},
+		{"\n)\n\n// Names returns", fmt.Sprintf("\n\t%s = %q", sc.Const, sc.Name), false},
+		{"\tdefault:\n\t\treturn nil, fmt.Errorf(\"unknown driver", fmt.Sprintf("\tcase %s:\n\t\treturn %s.New(cfg, db, tmpl, logger)\n", sc.Const, sc.Name), false},
+	}
+	for _, e := range edits {
+		i := strings.Index(text, e.anchor)
+		if i < 0 {
+			return nil, fmt.Errorf("cannot find %q in drivers.go, add the driver by hand", strings.TrimSpace(e.anchor))
+		}
+		if e.after {
+			i += len(e.anchor)
+		}
+		text = text[:i] + e.insert + text[i:]
+	}
+
+	names := "\treturn []string{"
+	i := strings.Index(text, names)
+	if i < 0 {
+		return nil, fmt.Errorf("cannot find the driver names in drivers.go, add the driver by hand")
+	}
+	j := i + strings.Index(text[i:], "}")
+	text = text[:j] + ", " + sc.Const + text[j:]
+
+	return format.Source([]byte(text))
+}
+
+const driverTemplate = `// Package [[.Name]] is the [[.Title]] [[.Type]] driver.
+package [[.Name]]
+
+import (
+	"alpaca/pkg/alpaca"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	driverUID     = "[[.UID]]"
+	deviceName    = "[[.Title]]"
+	deviceType    = alpaca.DeviceType("[[.Type]]")
+	driverName    = "[[.Title]] Driver"
+	driverVersion = "0.1"
+)
+
+// Driver is the [[.Title]] [[.Type]].
+// TODO: implement the [[.Type]] methods.
+type Driver struct {
+	logger log.FieldLogger
+	tmpl   *template.Template
+	store  *store
+	config Config
+
+	info   alpaca.DeviceInfo
+	driver alpaca.DriverInfo
+
+	connected atomic.Bool
+}
+
+// New creates the driver of a configured device instance. Each instance
+// keeps its settings under its own key; the default key is used when none is
+// set.
+func New(dev alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*Driver, error) {
+	key := dev.Key
+	if key == "" {
+		key = configKey
+	}
+
+	store, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %v", err)
+	}
+
+	config, err := store.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get [[.Name]] config: %v", err)
+	}
+
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(driverUID, dev.Key)
+	}
+
+	return &Driver{
+		logger: logger,
+		tmpl:   tmpl,
+		store:  store,
+		config: config,
+
+		info: alpaca.DeviceInfo{
+			Name:     deviceName,
+			Type:     deviceType,
+			Number:   dev.Number,
+			UniqueID: uid,
+		},
+		driver: alpaca.DriverInfo{
+			Name:             driverName,
+			Version:          driverVersion,
+			InterfaceVersion: 1,
+		},
+	}, nil
+}
+
+func (d *Driver) DeviceInfo() alpaca.DeviceInfo {
+	info := d.info
+
+	format := d.config.Description
+	if format == "" {
+		format = defaultDescription
+	}
+	info.Description = alpaca.ExpandDescription(format, map[string]string{
+		"driver": driverVersion,
+	})
+	return info
+}
+
+func (d *Driver) DriverInfo() alpaca.DriverInfo {
+	return d.driver
+}
+
+// Disabled reports whether the device is disabled in its setup page.
+func (d *Driver) Disabled() bool {
+	return d.config.Disabled
+}
+
+func (d *Driver) GetState() []alpaca.StateProperty {
+	// TODO: add the state of the device while connected.
+	return []alpaca.StateProperty{
+		alpaca.StateTimeStamp(time.Now()),
+	}
+}
+
+func (d *Driver) Connect() error {
+	// TODO: connect to the device.
+	if !d.connected.Swap(true) {
+		d.logger.Infof("%s connected", d.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventConnected,
+			Device:  d.info.Name,
+			Message: "Connected",
+		})
+	}
+	return nil
+}
+
+func (d *Driver) Disconnect() error {
+	if d.connected.Swap(false) {
+		d.logger.Infof("%s disconnected", d.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventDisconnected,
+			Device:  d.info.Name,
+			Message: "Disconnected",
+		})
+	}
+	return nil
+}
+
+func (d *Driver) Connected() bool {
+	return d.connected.Load()
+}
+
+func (d *Driver) Connecting() bool {
+	return false
+}
+
+// HandleSetup serves the setup page of the device.
+func (d *Driver) HandleSetup(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := d.store.GetConfig()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		d.renderSetupForm(rw, cfg, false, "")
+
+	case http.MethodPost:
+		cfg, err := parseSetupForm(r)
+		if err != nil {
+			d.renderSetupForm(rw, cfg, false, err.Error())
+			return
+		}
+
+		d.logger.Infof("Setting [[.Name]] config: %+v", alpaca.Redact(cfg))
+		d.config = cfg
+		if err := d.store.SetConfig(cfg); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		d.renderSetupForm(rw, cfg, true, "")
+
+	default:
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (d *Driver) renderSetupForm(rw http.ResponseWriter, cfg Config, success bool, err string) {
+	data := struct {
+		Config
+		Connected bool
+		Success   bool
+		Error     string
+	}{cfg, d.connected.Load(), success, err}
+
+	if err := d.tmpl.ExecuteTemplate(rw, "[[.Name]]_setup.html", data); err != nil {
+		http.Error(rw, "Error rendering template", http.StatusInternalServerError)
+		d.logger.Errorf("Error rendering template: %v", err)
+	}
+}
+
+func parseSetupForm(r *http.Request) (Config, error) {
+	if err := r.ParseForm(); err != nil {
+		return Config{}, fmt.Errorf("error parsing form: %v", err)
+	}
+
+	return Config{
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Disabled:    r.FormValue("enabled") != "true",
+	}, nil
+}
+`
+
+const storeTemplate = `package [[.Name]]
+
+import (
+	"alpaca/pkg/alpaca"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	configKey          = "[[.Name]]_config"
+	defaultDescription = "[[.Title]] {driver} @ {host}"
+)
+
+// Config holds the settings of the device.
+type Config struct {
+	Description string ` + "`json:\"description\"`" + ` // device description, with {driver} and {host} placeholders
+
+	Disabled bool ` + "`json:\"disabled\"`" + ` // hidden from the configured devices
+}
+
+type store = alpaca.DriverStore[Config]
+
+// NewStoreWithKey creates a store for the configuration saved under key,
+// which defaults to a disabled device.
+func NewStoreWithKey(db *bolt.DB, key string) (*store, error) {
+	return alpaca.NewDriverStore(db, key, Config{
+		Description: defaultDescription,
+		Disabled:    true,
+	})
+}
+`
+
+const handlerTemplate = `package [[.Name]]
+
+import (
+	"alpaca/pkg/alpaca"
+	"net/http"
+)
+
+// handler serves the [[.Type]] methods. No other driver serves this device
+// type yet; move the handler to pkg/alpaca once the interface is settled.
+type handler struct {
+	*alpaca.DeviceHandler
+	dev *Driver
+}
+
+func init() {
+	alpaca.RegisterDeviceHandler(deviceType, func(dev alpaca.Device, version int) alpaca.DeviceHTTPHandler {
+		if d, ok := dev.(*Driver); ok {
+			return &handler{DeviceHandler: alpaca.NewDeviceHandler(dev, version), dev: d}
+		}
+		return nil
+	})
+}
+
+func (h *handler) RegisterRoutes(mux *http.ServeMux) {
+	h.DeviceHandler.RegisterRoutes(mux)
+
+	// TODO: register the [[.Type]] methods, reading their parameters with
+	// alpaca.FloatParam, alpaca.IntParam and alpaca.BoolParam, e.g.
+	// mux.Handle("GET /position", alpaca.HandleAPI(h.handlePosition))
+}
+`
+
+const setupTemplate = `{{template "header"}}
+<div class="container">
+    <main>
+        <div class="py-5 text-center">
+            <h1>[[.Title]] Setup</h1>
+        </div>
+        <div class="container" style="max-width: 500px;">
+            <form action="" method="post">
+                <div class="form-check mb-3">
+                    <input class="form-check-input" type="checkbox" id="enabled" name="enabled" value="true" {{if not .Disabled}}checked{{end}}>
+                    <label class="form-check-label" for="enabled">Enabled</label>
+                    <div class="form-text">A disabled device keeps its settings but is hidden from the configured devices.</div>
+                </div>
+                <div class="mb-3">
+                    <label for="description" class="form-label">Description</label>
+                    <input type="text" id="description" name="description" class="form-control" placeholder="[[.Title]] {driver} @ {host}" value="{{.Description}}">
+                    <div class="form-text">{driver} and {host} are replaced by the driver version and the host name.</div>
+                </div>
+                <p>Connected: {{.Connected}}</p>
+                <button type="submit" class="btn btn-primary">Save</button>
+            </form>
+            {{if .Success}}
+            <div class="alert alert-success mt-3" role="alert">
+                Settings saved successfully.
+            </div>
+            {{end}}
+            {{if .Error}}
+            <div class="alert alert-danger mt-3" role="alert">
+                {{.Error}}
+            </div>
+            {{end}}
+        </div>
+    </main>
+</div>
+{{template "footer"}}
+`
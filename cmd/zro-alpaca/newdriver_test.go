@@ -0,0 +1,79 @@
+package main
+
+import (
+	"alpaca/pkg/alpaca"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewScaffold(t *testing.T) {
+	sc, err := newScaffold("my_dev", "camera")
+	require.NoError(t, err)
+	assert.Equal(t, "MyDev", sc.Title)
+	assert.Equal(t, "DriverMyDev", sc.Const)
+	assert.Equal(t, alpaca.DeviceTypeCamera, sc.Type)
+	assert.False(t, sc.Handler, "the camera handler is built in")
+	assert.Len(t, sc.UID, 36)
+
+	sc, err = newScaffold("dome2", "Dome")
+	require.NoError(t, err)
+	assert.False(t, sc.Handler, "the dome handler is built in")
+
+	for _, name := range []string{"", "MyDev", "my-dev", "2dev"} {
+		_, err := newScaffold(name, "focuser")
+		assert.Error(t, err, name)
+	}
+	_, err = newScaffold("mydev", "toaster")
+	assert.Error(t, err)
+}
+
+func TestGenerateDriver(t *testing.T) {
+	dir := t.TempDir()
+	drivers, err := os.ReadFile("../../pkg/drivers/drivers.go")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pkg", "drivers"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "templates"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pkg", "drivers", "drivers.go"), drivers, 0644))
+
+	sc, err := newScaffold("my_dev", "camera")
+	require.NoError(t, err)
+	sc.Handler = true // Every device type has a built-in handler; still check the handler template
+	files, err := sc.generate(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 4)
+	assert.FileExists(t, filepath.Join(dir, "templates", "my_dev_setup.html"))
+
+	fset := token.NewFileSet()
+	for _, name := range []string{"driver.go", "store.go", "handler.go"} {
+		_, err := parser.ParseFile(fset, filepath.Join(dir, "pkg", "drivers", "my_dev", name), nil, parser.AllErrors)
+		assert.NoError(t, err, name)
+	}
+
+	f, err := parser.ParseFile(fset, filepath.Join(dir, "pkg", "drivers", "drivers.go"), nil, parser.AllErrors)
+	require.NoError(t, err)
+	updated, _ := os.ReadFile(filepath.Join(dir, "pkg", "drivers", "drivers.go"))
+	assert.Regexp(t, `DriverMyDev += "my_dev"`, string(updated), "aligned by gofmt")
+	assert.Contains(t, string(updated), "return my_dev.New(cfg, db, tmpl, logger)")
+	assert.Contains(t, string(updated), "DriverRemote, DriverMyDev}")
+	assert.Len(t, f.Imports, len(mustParseImports(t, drivers))+1)
+
+	_, err = sc.generate(dir)
+	assert.Error(t, err, "the driver already exists")
+}
+
+func mustParseImports(t *testing.T, src []byte) []string {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), "drivers.go", src, parser.ImportsOnly)
+	require.NoError(t, err)
+	var imports []string
+	for _, imp := range f.Imports {
+		imports = append(imports, imp.Path.Value)
+	}
+	return imports
+}
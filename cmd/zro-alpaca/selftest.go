@@ -0,0 +1,80 @@
+package main
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/drivers/dome_simulator"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// selfTestKey is the settings key of the simulator checked by the self-test.
+const selfTestKey = "self_test"
+
+// selfTest runs the conform checks, motion included, against a dome
+// simulator served on a loopback port, to catch a build that broke the core
+// Alpaca semantics before it serves the real devices. The simulator gets its
+// own temporary database, so the self-test leaves the configuration alone.
+func selfTest(timeout time.Duration) error {
+	dir, err := os.MkdirTemp("", "zro-alpaca-self-test")
+	if err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := bolt.Open(filepath.Join(dir, "self-test.db"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("self-test: failed to open the database: %v", err)
+	}
+	defer db.Close()
+
+	// The simulator is disabled by default.
+	st, err := dome_simulator.NewStoreWithKey(db, selfTestKey)
+	if err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	cfg, err := st.GetConfig()
+	if err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	cfg.Disabled = false
+	if err := st.SetConfig(cfg); err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+
+	logger := log.WithField("component", "self-test")
+	sim, err := dome_simulator.New(alpaca.DeviceConfig{Key: selfTestKey}, db, nil, logger)
+	if err != nil {
+		return fmt.Errorf("self-test: failed to create the simulator: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("self-test: %v", err)
+	}
+	server := alpaca.NewServer(alpaca.ServerDescription{Name: "Self-test"}, []alpaca.Device{sim}, nil, nil)
+	srv := &http.Server{Handler: server.AddRoutes()}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	checker := newConformer(fmt.Sprintf("http://%s/api/v1/dome/0", ln.Addr()), timeout)
+	checker.rep.title = "ZRO Alpaca self-test report"
+	checker.run(true)
+
+	var out strings.Builder
+	checker.rep.print(&out)
+	if n := checker.rep.failed(); n > 0 {
+		logger.Errorf("Self-test failed:\n%s", out.String())
+		return fmt.Errorf("self-test: %d checks failed against the simulator", n)
+	}
+	logger.Infof("Self-test passed, %d checks", len(checker.rep.results))
+	logger.Debugf("Self-test report:\n%s", out.String())
+	return nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"alpaca/pkg/version"
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	cli "github.com/urfave/cli/v2"
+)
+
+const (
+	defaultRepo   = "CerecedaObs/alpaca-driver"
+	checksumsName = "checksums.txt"
+	signatureName = checksumsName + ".sig"
+)
+
+var (
+	// releaseKey is the base64 ed25519 public key that signs the release
+	// checksums, set at build time by `make release` with
+	// -X main.releaseKey=<key>.
+	releaseKey string
+
+	githubAPI = "https://api.github.com"
+	rename    = os.Rename
+)
+
+// release is the subset of the GitHub release API response used for updates.
+type release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// assetName returns the name of the release binary for the current platform.
+func assetName() string {
+	name := fmt.Sprintf("zro-alpaca_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+func (r release) asset(name string) (releaseAsset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// update replaces the running binary with the latest GitHub release for the
+// current platform, after verifying the signature of the release checksums
+// and the SHA-256 checksum of the binary.
+func update(c *cli.Context) error {
+	client := &http.Client{Timeout: c.Duration("timeout")}
+
+	rel, err := latestRelease(client, c.String("repo"))
+	if err != nil {
+		return err
+	}
+
+	current := version.Get().Version
+	latest := strings.TrimPrefix(rel.TagName, "v")
+	fmt.Printf("Current version: %s\nLatest release:  %s\n", current, latest)
+
+	if !c.Bool("force") {
+		switch cmp := version.Compare(latest, current); {
+		case cmp == 0:
+			fmt.Println("Already up to date")
+			return nil
+		case cmp < 0:
+			if c.Bool("check") {
+				return nil
+			}
+			return fmt.Errorf("release %s is older than the running version %s, use --force to install it anyway", latest, current)
+		}
+	}
+	if c.Bool("check") {
+		return nil
+	}
+	if releaseKey == "" {
+		return fmt.Errorf("this build has no release signing key, download the release by hand")
+	}
+
+	binAsset, ok := rel.asset(assetName())
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s/%s", rel.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	sumAsset, ok := rel.asset(checksumsName)
+	if !ok {
+		return fmt.Errorf("release %s has no %s, refusing to install an unverified binary", rel.TagName, checksumsName)
+	}
+
+	sigAsset, ok := rel.asset(signatureName)
+	if !ok {
+		return fmt.Errorf("release %s has no %s, refusing to install an unverified binary", rel.TagName, signatureName)
+	}
+
+	sums, err := download(client, sumAsset.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download checksums: %v", err)
+	}
+	sig, err := download(client, sigAsset.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download the checksums signature: %v", err)
+	}
+	if err := verifySignature(releaseKey, sums, sig); err != nil {
+		return err
+	}
+	want, err := findChecksum(sums, binAsset.Name)
+	if err != nil {
+		return err
+	}
+
+	bin, err := download(client, binAsset.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download binary: %v", err)
+	}
+	sum := sha256.Sum256(bin)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", binAsset.Name, got, want)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot locate the running binary: %v", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("cannot locate the running binary: %v", err)
+	}
+
+	if err := replaceBinary(exe, bin); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s to %s, restart the server to use it\n", exe, latest)
+	return nil
+}
+
+func latestRelease(client *http.Client, repo string) (release, error) {
+	var rel release
+
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", githubAPI, repo)
+	body, err := download(client, url)
+	if err != nil {
+		return rel, fmt.Errorf("failed to query latest release: %v", err)
+	}
+	if err := json.Unmarshal(body, &rel); err != nil {
+		return rel, fmt.Errorf("invalid release response: %v", err)
+	}
+	return rel, nil
+}
+
+func download(client *http.Client, url string) ([]byte, error) {
+	log.Debugf("Downloading %s", url)
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifySignature checks the base64 ed25519 signature of the checksums file
+// against a base64 public key.
+func verifySignature(key string, sums, sig []byte) error {
+	pub, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release signing key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(pub, sums, raw) {
+		return fmt.Errorf("invalid signature of %s, refusing to install an unverified binary", checksumsName)
+	}
+	return nil
+}
+
+// findChecksum looks up a file in a checksums file with the format produced
+// by sha256sum: "<hex digest>  <file name>" per line.
+func findChecksum(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(string(sums)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s in %s", name, checksumsName)
+}
+
+// replaceBinary swaps the binary at path with the new content. The running
+// binary is renamed instead of overwritten, which also works on Windows, and
+// kept as <path>.old so a bad update can be reverted by hand.
+func replaceBinary(path string, bin []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".new"
+	if err := os.WriteFile(tmp, bin, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write new binary: %v", err)
+	}
+
+	old := path + ".old"
+	if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to remove the previous backup: %v", err)
+	}
+	if err := rename(path, old); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to move the current binary aside: %v", err)
+	}
+	if err := rename(tmp, path); err != nil {
+		if rerr := rename(old, path); rerr != nil {
+			return fmt.Errorf("failed to install the new binary: %v, and to restore %s: %v", err, old, rerr)
+		}
+		os.Remove(tmp)
+		return fmt.Errorf("failed to install the new binary: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestRelease(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/obs/driver/releases/latest":
+			w.Write([]byte(`{"tag_name":"v1.3.0","assets":[{"name":"checksums.txt","browser_download_url":"http://example.org/checksums.txt"}]}`))
+		case "/repos/obs/invalid/releases/latest":
+			w.Write([]byte(`not json`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	api := githubAPI
+	githubAPI = srv.URL
+	defer func() { githubAPI = api }()
+
+	rel, err := latestRelease(srv.Client(), "obs/driver")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.3.0", rel.TagName)
+	a, ok := rel.asset(checksumsName)
+	assert.True(t, ok)
+	assert.Equal(t, "http://example.org/checksums.txt", a.URL)
+	_, ok = rel.asset(assetName())
+	assert.False(t, ok)
+
+	_, err = latestRelease(srv.Client(), "obs/invalid")
+	assert.ErrorContains(t, err, "invalid release response")
+	_, err = latestRelease(srv.Client(), "obs/missing")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bin" {
+			http.Error(w, "gone", http.StatusGone)
+			return
+		}
+		w.Write([]byte("binary"))
+	}))
+	defer srv.Close()
+
+	body, err := download(srv.Client(), srv.URL+"/bin")
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(body))
+
+	_, err = download(srv.Client(), srv.URL+"/other")
+	assert.ErrorContains(t, err, "410 Gone")
+}
+
+func TestFindChecksum(t *testing.T) {
+	sums := []byte("ABCDEF  zro-alpaca_linux_amd64\n" +
+		"123456 *zro-alpaca_windows_amd64.exe\n" +
+		"malformed line\n")
+
+	sum, err := findChecksum(sums, "zro-alpaca_linux_amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef", sum)
+
+	sum, err = findChecksum(sums, "zro-alpaca_windows_amd64.exe")
+	require.NoError(t, err)
+	assert.Equal(t, "123456", sum)
+
+	_, err = findChecksum(sums, "zro-alpaca_linux_arm64")
+	assert.ErrorContains(t, err, "no checksum for zro-alpaca_linux_arm64")
+	_, err = findChecksum(sums, "malformed")
+	assert.Error(t, err)
+}
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key := base64.StdEncoding.EncodeToString(pub)
+	sums := []byte("abcdef  zro-alpaca_linux_amd64\n")
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, sums)) + "\n")
+
+	assert.NoError(t, verifySignature(key, sums, sig))
+	assert.Error(t, verifySignature(key, []byte("123456  zro-alpaca_linux_amd64\n"), sig), "tampered checksums")
+	assert.Error(t, verifySignature(key, sums, []byte("not base64")), "invalid signature")
+
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	assert.Error(t, verifySignature(base64.StdEncoding.EncodeToString(other), sums, sig), "other key")
+	assert.ErrorContains(t, verifySignature("", sums, sig), "invalid release signing key")
+}
+
+func TestReplaceBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zro-alpaca")
+	require.NoError(t, os.WriteFile(path, []byte("v1"), 0o755))
+	require.NoError(t, os.WriteFile(path+".old", []byte("v0"), 0o755))
+
+	require.NoError(t, replaceBinary(path, []byte("v2")))
+	assertFile(t, path, "v2")
+	assertFile(t, path+".old", "v1")
+	assert.NoFileExists(t, path+".new")
+}
+
+func TestReplaceBinaryRollback(t *testing.T) {
+	defer func() { rename = os.Rename }()
+	failInstall := func(from, to string) error {
+		if filepath.Ext(from) == ".new" {
+			return errors.New("disk full")
+		}
+		return os.Rename(from, to)
+	}
+
+	t.Run("Restored", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "zro-alpaca")
+		require.NoError(t, os.WriteFile(path, []byte("v1"), 0o755))
+
+		rename = failInstall
+		err := replaceBinary(path, []byte("v2"))
+		assert.ErrorContains(t, err, "failed to install the new binary: disk full")
+		assertFile(t, path, "v1")
+		assert.NoFileExists(t, path+".new")
+	})
+
+	t.Run("Restore failed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "zro-alpaca")
+		require.NoError(t, os.WriteFile(path, []byte("v1"), 0o755))
+
+		rename = func(from, to string) error {
+			if filepath.Ext(from) == ".old" {
+				return errors.New("permission denied")
+			}
+			return failInstall(from, to)
+		}
+		err := replaceBinary(path, []byte("v2"))
+		assert.ErrorContains(t, err, "disk full")
+		assert.ErrorContains(t, err, "permission denied")
+		assertFile(t, path+".old", "v1")
+	})
+
+	t.Run("Previous backup not removable", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "zro-alpaca")
+		require.NoError(t, os.WriteFile(path, []byte("v1"), 0o755))
+		require.NoError(t, os.Mkdir(path+".old", 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(path+".old", "file"), nil, 0o644))
+
+		rename = os.Rename
+		err := replaceBinary(path, []byte("v2"))
+		assert.ErrorContains(t, err, "failed to remove the previous backup")
+		assertFile(t, path, "v1")
+		assert.NoFileExists(t, path+".new")
+	})
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(data))
+}
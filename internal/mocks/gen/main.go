@@ -0,0 +1,275 @@
+// Command gen generates mocks of interfaces. Each mock has a function field
+// per method, named after the method with a Func suffix, and counts the calls.
+// Methods whose function is nil return zero values.
+//
+// Usage:
+//
+//	go run ./gen -o mocks_gen.go alpaca/pkg/alpaca.Device github.com/eclipse/paho.mqtt.golang.Client=MQTTClient
+//
+// Each argument is the import path and name of an interface, optionally
+// followed by the name of the mock, which defaults to the interface name.
+// The packages are loaded from the export data of the go command, so they
+// must build.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+)
+
+// target is an interface to mock.
+type target struct {
+	pkgPath string
+	name    string
+	mock    string
+}
+
+func parseTarget(arg string) (target, error) {
+	spec, mock, _ := strings.Cut(arg, "=")
+	slash := strings.LastIndex(spec, "/")
+	dot := strings.LastIndex(spec, ".")
+	if dot <= slash+1 || dot == len(spec)-1 {
+		return target{}, fmt.Errorf("invalid interface %q, want <import path>.<name>", spec)
+	}
+
+	t := target{pkgPath: spec[:dot], name: spec[dot+1:], mock: mock}
+	if t.mock == "" {
+		t.mock = t.name
+	}
+	return t, nil
+}
+
+// newImporter returns an importer for the packages and their dependencies,
+// reading the export data built by the go command.
+func newImporter(pkgPaths []string) (types.Importer, error) {
+	args := append([]string{"list", "-export", "-deps", "-f", "{{.ImportPath}} {{.Export}}"}, pkgPaths...)
+	out, err := exec.Command("go", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %v", err)
+	}
+
+	exports := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if importPath, file, ok := strings.Cut(line, " "); ok && file != "" {
+			exports[importPath] = file
+		}
+	}
+
+	lookup := func(importPath string) (io.ReadCloser, error) {
+		file, ok := exports[importPath]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", importPath)
+		}
+		return os.Open(file)
+	}
+	return importer.ForCompiler(token.NewFileSet(), "gc", lookup), nil
+}
+
+// generator writes the mocks of a file and collects their imports.
+type generator struct {
+	pkgName string
+	imports map[string]string // Package name by import path
+	body    bytes.Buffer
+}
+
+func (g *generator) qualifier(pkg *types.Package) string {
+	g.imports[pkg.Path()] = pkg.Name()
+	return pkg.Name()
+}
+
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *generator) mock(t target, iface *types.Interface) {
+	g.printf("// %s is a mock of %s.%s.\n", t.mock, g.pkgNameOf(t.pkgPath), t.name)
+	g.printf("type %s struct {\n\tcalls\n\n", t.mock)
+	for i := range iface.NumMethods() {
+		m := iface.Method(i)
+		g.printf("\t%sFunc %s\n", m.Name(), g.funcType(m.Type().(*types.Signature)))
+	}
+	g.printf("}\n\n")
+	g.printf("var _ %s.%s = (*%s)(nil)\n\n", g.pkgNameOf(t.pkgPath), t.name, t.mock)
+
+	for i := range iface.NumMethods() {
+		g.method(t.mock, iface.Method(i))
+	}
+}
+
+// pkgNameOf returns the name of an imported package.
+func (g *generator) pkgNameOf(pkgPath string) string {
+	return g.imports[pkgPath]
+}
+
+// funcType returns the type of the function field of a method.
+func (g *generator) funcType(sig *types.Signature) string {
+	var params []string
+	for i := range sig.Params().Len() {
+		params = append(params, g.paramType(sig, i))
+	}
+
+	s := "func(" + strings.Join(params, ", ") + ")"
+	switch results := g.results(sig); len(results) {
+	case 0:
+	case 1:
+		s += " " + results[0]
+	default:
+		s += " (" + strings.Join(results, ", ") + ")"
+	}
+	return s
+}
+
+func (g *generator) paramType(sig *types.Signature, i int) string {
+	t := sig.Params().At(i).Type()
+	if sig.Variadic() && i == sig.Params().Len()-1 {
+		return "..." + g.typeString(t.(*types.Slice).Elem())
+	}
+	return g.typeString(t)
+}
+
+func (g *generator) results(sig *types.Signature) []string {
+	var results []string
+	for i := range sig.Results().Len() {
+		results = append(results, g.typeString(sig.Results().At(i).Type()))
+	}
+	return results
+}
+
+func (g *generator) method(mock string, m *types.Func) {
+	sig := m.Type().(*types.Signature)
+
+	var params, args []string
+	for i := range sig.Params().Len() {
+		name := sig.Params().At(i).Name()
+		if name == "" || name == "_" || name == "m" {
+			name = fmt.Sprintf("p%d", i)
+		}
+		params = append(params, name+" "+g.paramType(sig, i))
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			name += "..."
+		}
+		args = append(args, name)
+	}
+
+	results := g.results(sig)
+	resultList := strings.Join(results, ", ")
+	if len(results) > 1 {
+		resultList = "(" + resultList + ")"
+	}
+	call := fmt.Sprintf("m.%sFunc(%s)", m.Name(), strings.Join(args, ", "))
+
+	g.printf("// %s calls %sFunc", m.Name(), m.Name())
+	if len(results) > 0 {
+		g.printf(", or returns zero values if it is nil")
+	}
+	g.printf(".\n")
+	g.printf("func (m *%s) %s(%s) %s {\n", mock, m.Name(), strings.Join(params, ", "), resultList)
+	g.printf("\tm.record(%q)\n", m.Name())
+	if len(results) == 0 {
+		g.printf("\tif m.%sFunc != nil {\n\t\t%s\n\t}\n}\n\n", m.Name(), call)
+		return
+	}
+
+	g.printf("\tif m.%sFunc != nil {\n\t\treturn %s\n\t}\n", m.Name(), call)
+	var zeros []string
+	for i, r := range results {
+		zero := fmt.Sprintf("r%d", i)
+		g.printf("\tvar %s %s\n", zero, r)
+		zeros = append(zeros, zero)
+	}
+	g.printf("\treturn %s\n}\n\n", strings.Join(zeros, ", "))
+}
+
+// source returns the formatted file.
+func (g *generator) source() ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.pkgName)
+
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	for _, p := range paths {
+		if name := g.imports[p]; name != path.Base(p) {
+			fmt.Fprintf(&buf, "\t%s %q\n", name, p)
+		} else {
+			fmt.Fprintf(&buf, "\t%q\n", p)
+		}
+	}
+	buf.WriteString(")\n\n")
+	buf.Write(g.body.Bytes())
+
+	return format.Source(buf.Bytes())
+}
+
+func main() {
+	output := flag.String("o", "mocks_gen.go", "output file")
+	pkgName := flag.String("pkg", "mocks", "package name of the mocks")
+	flag.Parse()
+
+	var targets []target
+	var pkgPaths []string
+	for _, arg := range flag.Args() {
+		t, err := parseTarget(arg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		targets = append(targets, t)
+		if !slices.Contains(pkgPaths, t.pkgPath) {
+			pkgPaths = append(pkgPaths, t.pkgPath)
+		}
+	}
+	if len(targets) == 0 {
+		log.Fatal("no interfaces given")
+	}
+
+	imp, err := newImporter(pkgPaths)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	g := generator{pkgName: *pkgName, imports: make(map[string]string)}
+	for _, t := range targets {
+		pkg, err := imp.Import(t.pkgPath)
+		if err != nil {
+			log.Fatalf("failed to load %s: %v", t.pkgPath, err)
+		}
+
+		obj := pkg.Scope().Lookup(t.name)
+		if obj == nil {
+			log.Fatalf("%s.%s not found", t.pkgPath, t.name)
+		}
+		iface, ok := obj.Type().Underlying().(*types.Interface)
+		if !ok {
+			log.Fatalf("%s.%s is not an interface", t.pkgPath, t.name)
+		}
+
+		g.imports[pkg.Path()] = pkg.Name()
+		g.mock(t, iface)
+	}
+
+	src, err := g.source()
+	if err != nil {
+		log.Fatalf("failed to format the mocks: %v", err)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
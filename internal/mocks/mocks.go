@@ -0,0 +1,37 @@
+// Package mocks provides mocks of the core interfaces: the Alpaca devices,
+// the server configuration store and the MQTT transport of the dome
+// controller.
+//
+// Each mock has a function field per method, such as ConnectFunc for
+// Connect, so tests only set the methods they exercise; the others return
+// zero values. The mocks are generated, run go generate after changing the
+// interfaces.
+package mocks
+
+import "sync"
+
+//go:generate go run ./gen -o mocks_gen.go alpaca/pkg/alpaca.Device alpaca/pkg/alpaca.Dome alpaca/pkg/alpaca.ConfigStore github.com/eclipse/paho.mqtt.golang.Client=MQTTClient github.com/eclipse/paho.mqtt.golang.Token=MQTTToken github.com/eclipse/paho.mqtt.golang.Message=MQTTMessage
+
+// calls counts the calls to the methods of a mock.
+type calls struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *calls) record(method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[method]++
+}
+
+// Calls returns the number of calls to the method.
+func (c *calls) Calls(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.counts[method]
+}
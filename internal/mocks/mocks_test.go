@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"alpaca/pkg/alpaca"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDome(t *testing.T) {
+	errMoving := errors.New("moving")
+	dome := &Dome{
+		StatusFunc:        func() alpaca.DomeStatus { return alpaca.DomeStatus{Azimuth: 90} },
+		SlewToAzimuthFunc: func(float64) error { return errMoving },
+	}
+
+	assert.Equal(t, 90.0, dome.Status().Azimuth)
+	assert.ErrorIs(t, dome.SlewToAzimuth(180), errMoving)
+	assert.NoError(t, dome.Park(), "methods without a function return zero values")
+	assert.Equal(t, 1, dome.Calls("SlewToAzimuth"))
+	assert.Zero(t, dome.Calls("FindHome"))
+}
+
+// TestGenerated checks that the mocks match the interfaces.
+func TestGenerated(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the generator")
+	}
+
+	src, err := os.ReadFile("mocks.go")
+	require.NoError(t, err)
+
+	var args []string
+	for _, line := range strings.Split(string(src), "\n") {
+		if cmd, ok := strings.CutPrefix(line, "//go:generate go run ./gen -o mocks_gen.go "); ok {
+			args = strings.Fields(cmd)
+		}
+	}
+	require.NotEmpty(t, args, "go:generate directive")
+
+	output := filepath.Join(t.TempDir(), "mocks_gen.go")
+	cmd := exec.Command("go", append([]string{"run", "./gen", "-o", output}, args...)...)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	want, err := os.ReadFile(output)
+	require.NoError(t, err)
+	got, err := os.ReadFile("mocks_gen.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "mocks_gen.go is out of date, run go generate ./internal/mocks")
+}
@@ -0,0 +1,92 @@
+// Command signrelease signs the checksums of a release with an ed25519 key,
+// so that `zro-alpaca update` can verify them with the public key built into
+// the binary.
+//
+// Usage:
+//
+//	go run ./internal/signrelease -key release.key -generate
+//	go run ./internal/signrelease -key release.key -public
+//	go run ./internal/signrelease -key release.key dist/checksums.txt
+//
+// The key file holds the base64 seed of the private key. -generate creates
+// it, -public prints the base64 public key to build into the binary, and
+// otherwise each file is signed into <file>.sig, a base64 signature.
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func readKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s is not a base64 ed25519 seed", path)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func generate(path string) error {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(f, base64.StdEncoding.EncodeToString(key.Seed())); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func main() {
+	keyPath := flag.String("key", "", "file with the base64 seed of the private key")
+	gen := flag.Bool("generate", false, "generate a new key file")
+	public := flag.Bool("public", false, "print the base64 public key")
+	flag.Parse()
+
+	if *keyPath == "" {
+		log.Fatal("no key file given")
+	}
+	if *gen {
+		if err := generate(*keyPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	key, err := readKey(*keyPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *public || *gen {
+		fmt.Println(base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+		return
+	}
+
+	if flag.NArg() == 0 {
+		log.Fatal("no files to sign")
+	}
+	for _, path := range flag.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, data))
+		if err := os.WriteFile(path+".sig", []byte(sig+"\n"), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+// Package simtest holds the test helpers shared by the device simulators.
+package simtest
+
+import (
+	"alpaca/pkg/alpaca"
+	"html/template"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// OpenDB opens a database in a temporary directory, closed at the end of
+// the test.
+func OpenDB(t testing.TB) *bolt.DB {
+	t.Helper()
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "alpaca.db"), 0600, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Connector is a simulator that can be connected.
+type Connector interface {
+	Connect() error
+}
+
+// Connected creates a simulator with the New function of its package, on a
+// temporary database with the default settings, and connects it.
+func Connected[T Connector](t testing.TB, newSim func(alpaca.DeviceConfig, *bolt.DB, *template.Template, log.FieldLogger) (T, error)) T {
+	t.Helper()
+
+	sim, err := newSim(alpaca.DeviceConfig{}, OpenDB(t), nil, log.New())
+	require.NoError(t, err)
+	require.NoError(t, sim.Connect())
+	return sim
+}
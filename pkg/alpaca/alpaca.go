@@ -1,49 +1,150 @@
 package alpaca
 
 import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"mime"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
-type Error struct {
-	Number  int
-	Message string
-}
+var errBadRequest = errors.New("bad request")
+
+// Global transaction counter
+var txCounter atomic.Int32
+
+// deviceTxIDs adds a transaction counter of each device to the logs of the
+// requests, to follow the traffic of a device among those of the others.
+// The ServerTransactionID stays the one of the server, as the specification
+// requires.
+var deviceTxIDs atomic.Bool
 
-func (e Error) Error() string {
-	return e.Message
+// deviceTxCounters holds the transaction counter of each device, by
+// "<type>/<number>" path.
+var deviceTxCounters sync.Map
+
+// SetDeviceTransactionIDs enables or disables the per device transaction
+// counters in the logs.
+func SetDeviceTransactionIDs(enabled bool) {
+	deviceTxIDs.Store(enabled)
 }
 
-func NewError(number int, message string) Error {
-	return Error{Number: number, Message: message}
+// nextDeviceTransactionID returns the next transaction number of a device.
+func nextDeviceTransactionID(device string) uint32 {
+	counter, _ := deviceTxCounters.LoadOrStore(device, new(atomic.Uint32))
+	return counter.(*atomic.Uint32).Add(1)
 }
 
-var errBadRequest = errors.New("bad request")
+// strictMode makes handleAPI reject requests that deviate from the Alpaca
+// specification instead of only logging the deviation.
+var strictMode atomic.Bool
 
-// Common Alpaca errors.
-// Reference: https://ascom-standards.org/AlpacaDeveloper/ASCOMAlpacaAPIReference.html
-var (
-	ErrPropertyNotImplemented = Error{Number: 0x400, Message: "property not implemented"}
-	ErrInvalidValue           = Error{Number: 0x401, Message: "invalid value"}
-	ErrNotSet                 = Error{Number: 0x402, Message: "not set"}
-	ErrNotConnected           = Error{Number: 0x403, Message: "not connected"}
-	ErrInvalidWhileParked     = Error{Number: 0x404, Message: "invalid while parked"}
-	ErrInvalidWhileSlaved     = Error{Number: 0x405, Message: "invalid while slaved"}
-	ErrInvalidOperation       = Error{Number: 0x406, Message: "invalid operation"}
-	ErrActionNotImplemented   = Error{Number: 0x407, Message: "action not implemented"}
-)
+// SetStrictMode enables or disables the strict parameter validation mode.
+func SetStrictMode(strict bool) {
+	strictMode.Store(strict)
+}
 
-// Global transaction counter
-var txCounter atomic.Int32
+// maxBodySize is the maximum size of a PUT request body.
+const maxBodySize = 1 << 20
+
+// knownParams lists the parameter names defined by the Alpaca specification
+// for the endpoints served by this package, with their canonical casing.
+var knownParams = []string{
+	"ClientID",
+	"ClientTransactionID",
+	"Connected",
+	"Slaved",
+	"Altitude",
+	"Azimuth",
+	"Action",
+	"Parameters",
+	"Command",
+	"Raw",
+	"Properties",
+	"SensorName",
+	"AveragePeriod",
+	"Id",
+	"State",
+	"Name",
+	"Value",
+	"RightAscension",
+	"Declination",
+	"Axis",
+	"Rate",
+	"Direction",
+	"Duration",
+	"Tracking",
+	"TrackingRate",
+	"SideOfPier",
+	"DoesRefraction",
+	"DeclinationRate",
+	"RightAscensionRate",
+	"GuideRateDeclination",
+	"GuideRateRightAscension",
+	"SiteElevation",
+	"SiteLatitude",
+	"SiteLongitude",
+	"SlewSettleTime",
+	"TargetDeclination",
+	"TargetRightAscension",
+	"UTCDate",
+	"Position",
+	"TempComp",
+	"Reverse",
+	"Brightness",
+	"BinX",
+	"BinY",
+	"StartX",
+	"StartY",
+	"NumX",
+	"NumY",
+	"Light",
+}
+
+// criticalParams are the parameters that move the device or change its
+// state. A request giving one of them more than once is rejected instead of
+// silently using the first value.
+var criticalParams = []string{
+	"Azimuth",
+	"Altitude",
+	"Connected",
+	"Slaved",
+	"State",
+	"Value",
+	"RightAscension",
+	"Declination",
+	"Axis",
+	"Rate",
+	"Direction",
+	"Duration",
+	"Tracking",
+	"SideOfPier",
+	"Position",
+	"TempComp",
+	"Reverse",
+	"Brightness",
+	"BinX",
+	"BinY",
+	"StartX",
+	"StartY",
+	"NumX",
+	"NumY",
+	"Light",
+}
 
 type baseResponse struct {
 	ClientTransactionID int    `json:"ClientTransactionID"`
@@ -56,22 +157,60 @@ type baseResponse struct {
 // Define a custom type for context keys
 type contextKey string
 
-const paramsKey contextKey = "params"
+const (
+	paramsKey     contextKey = "params"
+	clientIDKey   contextKey = "clientID"
+	devicePathKey contextKey = "devicePath"
+)
+
+// withDevice tags the requests of a device API with the "<type>/<number>"
+// path of the device, which their stripped paths no longer have.
+func withDevice(device string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), devicePathKey, device)))
+	})
+}
+
+// ClientID returns the ClientID sent by the client of the Alpaca request of
+// ctx, 0 if it sent none. Client software such as NINA, the setup pages and
+// the conformance checker each use their own.
+func ClientID(ctx context.Context) uint32 {
+	id, _ := ctx.Value(clientIDKey).(uint32)
+	return id
+}
+
+// requestLogger returns a logger tagged with the request and its ClientID, to
+// tell apart the requests of the clients in the log.
+func requestLogger(r *http.Request) *log.Entry {
+	fields := log.Fields{
+		"remote":    r.RemoteAddr,
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"client_id": ClientID(r.Context()),
+	}
+	if name, ok := r.Context().Value(apiKeyNameKey).(string); ok {
+		fields["api_key"] = name
+	}
+	return log.WithFields(fields)
+}
 
 // handleMgm wraps a management handler function and returns an http.Handler.
 // Management handlers do not require a ClientTransactionID.
 func handleMgm(handler func(r *http.Request) (any, error)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, span := startSpan(r)
 		var response baseResponse
 
-		value, err := handler(r)
+		value, err := callHandler(handler, r)
 		if err != nil {
-			// TODO: Define error numbers
-			response.ErrorNumber = 1
+			response.ErrorNumber = alpacaerrors.Number(err)
 			response.ErrorMessage = err.Error()
 		} else {
 			response.Value = value
 		}
+		observeRequest(r, start, response.ErrorNumber)
+		endSpan(span, r, response)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(response)
@@ -80,61 +219,226 @@ func handleMgm(handler func(r *http.Request) (any, error)) http.Handler {
 
 // handleAPI wraps an API handler function and returns an http.Handler.
 // The handler function should return a value and an error.
-// If the error is not nil, it will be returned as an Alpaca error response.
-// If the error is nil, the value will be returned as an Alpaca response.
+// If the error is not nil, it will be returned as an Alpaca error response,
+// with the number of the Alpaca error it carries, or the number of an
+// unspecified error. If the error is nil, the value will be returned as an
+// Alpaca response.
 func handleAPI(handler func(r *http.Request) (any, error)) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		r = addParamsToRequestContext(r)
+	return serveAPI(handler, writeJSON)
+}
 
-		txID, err := getUintParam(r, "ClientTransactionID", true)
+// serveAPI is handleAPI with the response written by write, for the
+// responses that are not always JSON, such as the images.
+func serveAPI(handler func(r *http.Request) (any, error), write func(w http.ResponseWriter, r *http.Request, response baseResponse)) http.Handler {
+	return dumpExchanges(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		r, span := startSpan(r)
+		r, err := addParamsToRequestContext(w, r)
 		if err != nil {
+			failSpan(span, err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
+		if deviations := checkRequest(r); len(deviations) > 0 {
+			deviation := fmt.Errorf("Alpaca spec deviation: %s", strings.Join(deviations, "; "))
+			requestLogger(r).Warn(deviation)
+
+			if strictMode.Load() {
+				failSpan(span, deviation)
+				http.Error(w, strings.Join(deviations, "\n"), http.StatusBadRequest)
+				return
+			}
+		}
+
+		// A missing or malformed ClientTransactionID is a deviation, rejected
+		// in strict mode; older clients get a transaction ID of 0.
+		txID, _ := getUintParam(r, "ClientTransactionID", true)
+
 		response := baseResponse{
 			ServerTransactionID: int(txCounter.Add(1)),
 			ClientTransactionID: int(txID),
 		}
 
-		value, err := handler(r)
+		var value any
+		if name, ok := duplicateParam(r); ok {
+			err = alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "parameter %s given more than once", name)
+		} else {
+			value, err = callHandler(handler, r)
+		}
 
-		if e, ok := err.(Error); ok {
-			response.ErrorNumber = e.Number
-			response.ErrorMessage = e.Message
-		} else if errors.Is(err, errBadRequest) {
+		if errors.Is(err, errBadRequest) {
+			failSpan(span, err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		} else if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			// The errors of the devices are answered with HTTP 200, as the
+			// specification requires; only the request errors get another
+			// status.
+			response.ErrorNumber = alpacaerrors.Number(err)
+			response.ErrorMessage = err.Error()
 		} else {
 			response.Value = value
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
-	})
+		// The commands are logged, the polls only in debug.
+		logger := requestLogger(r).WithFields(log.Fields{
+			"client_transaction_id": response.ClientTransactionID,
+			"server_transaction_id": response.ServerTransactionID,
+		})
+		if device, ok := r.Context().Value(devicePathKey).(string); ok && deviceTxIDs.Load() {
+			logger = logger.WithFields(log.Fields{"device": device, "device_transaction_id": nextDeviceTransactionID(device)})
+		}
+		switch {
+		case response.ErrorNumber != 0:
+			logger.Infof("Alpaca error 0x%X: %s", response.ErrorNumber, response.ErrorMessage)
+		case r.Method == http.MethodPut:
+			logger.Info("Alpaca command")
+		default:
+			logger.Debug("Alpaca request")
+		}
+		recordCommand(r, response)
+		observeRequest(r, start, response.ErrorNumber)
+		endSpan(span, r, response)
+
+		write(w, r, response)
+	}))
+}
+
+// writeJSON writes an API response as JSON.
+func writeJSON(w http.ResponseWriter, r *http.Request, response baseResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// checkRequest compares the request against the Alpaca specification and
+// returns a description of every deviation found.
+// PUT parameters must be sent form-encoded in the body with the exact casing
+// of the specification, while GET parameters are matched in any case. Every
+// request must carry a ClientTransactionID, and the ClientID and the
+// ClientTransactionID must be unsigned 32 bit integers.
+func checkRequest(r *http.Request) []string {
+	var deviations []string
+
+	if accept := r.Header.Get("Accept"); accept != "" && !acceptsJSON(accept) && !acceptsImageBytes(accept) {
+		deviations = append(deviations, fmt.Sprintf("Accept header %q does not allow application/json", accept))
+	}
+
+	if r.Method == http.MethodPut {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/x-www-form-urlencoded" {
+			deviations = append(deviations, fmt.Sprintf("Content-Type %q is not application/x-www-form-urlencoded", r.Header.Get("Content-Type")))
+		}
+		if r.URL.RawQuery != "" {
+			deviations = append(deviations, "PUT parameters sent in the query string")
+		}
+	}
+
+	params, _ := r.Context().Value(paramsKey).(url.Values)
+	for name := range params {
+		canonical, ok := canonicalParam(name)
+		switch {
+		case !ok:
+			deviations = append(deviations, fmt.Sprintf("unknown parameter %q", name))
+		case r.Method == http.MethodPut && name != canonical:
+			deviations = append(deviations, fmt.Sprintf("parameter %q should be spelled %q", name, canonical))
+		}
+	}
+
+	for _, name := range []string{"ClientID", "ClientTransactionID"} {
+		value, err := getParam(r, name, true)
+		if err != nil {
+			if name == "ClientTransactionID" {
+				deviations = append(deviations, "missing ClientTransactionID")
+			}
+			continue
+		}
+		if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+			deviations = append(deviations, fmt.Sprintf("%s %q is not an unsigned 32 bit integer", name, value))
+		}
+	}
+
+	return deviations
+}
+
+// duplicateParam returns the first critical parameter given more than once,
+// either repeated or with different casings.
+func duplicateParam(r *http.Request) (string, bool) {
+	params, _ := r.Context().Value(paramsKey).(url.Values)
+
+	counts := make(map[string]int)
+	for name, values := range params {
+		canonical, ok := canonicalParam(name)
+		if ok && slices.Contains(criticalParams, canonical) {
+			counts[canonical] += len(values)
+		}
+	}
+
+	for _, name := range criticalParams {
+		if counts[name] > 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// canonicalParam returns the spelling used by the specification for a
+// parameter name, matched in any case.
+func canonicalParam(name string) (string, bool) {
+	for _, known := range knownParams {
+		if strings.EqualFold(known, name) {
+			return known, true
+		}
+	}
+	return "", false
+}
+
+// acceptsJSON reports whether an Accept header allows a JSON response.
+func acceptsJSON(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json", "application/*", "*/*":
+			return true
+		}
+	}
+	return false
 }
 
 // addParamsToRequestContext extracts the parameters from the request and adds
 // them to the request context.
 // PUT requests have the parameters in the body.
 // GET requests have the parameters in the URL.
-func addParamsToRequestContext(r *http.Request) *http.Request {
+func addParamsToRequestContext(w http.ResponseWriter, r *http.Request) (*http.Request, error) {
 	var params url.Values
 
 	if r.Method == "PUT" {
-		params, _ = parseBodyParams(r)
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
 
+		var err error
+		params, err = parseBodyParams(r)
+		if err != nil {
+			return r, fmt.Errorf("invalid request body: %v", err)
+		}
 	} else {
 		params = r.URL.Query()
 	}
 
-	// Insert the params into the request context
+	// Insert the params and the ClientID into the request context
 	ctx := context.WithValue(r.Context(), paramsKey, params)
+	for name, values := range params {
+		if strings.EqualFold(name, "ClientID") {
+			if id, err := strconv.ParseUint(values[0], 10, 32); err == nil {
+				ctx = context.WithValue(ctx, clientIDKey, uint32(id))
+			}
+			break
+		}
+	}
 
-	return r.WithContext(ctx)
+	return r.WithContext(ctx), nil
 }
 
 // Helper to read and parse the request body as URL-encoded data.
@@ -148,6 +452,18 @@ func parseBodyParams(r *http.Request) (url.Values, error) {
 	return url.ParseQuery(string(bodyBytes))
 }
 
+// HandleAPI is handleAPI for the handlers of the device types registered
+// outside this package.
+func HandleAPI(handler func(r *http.Request) (any, error)) http.Handler {
+	return handleAPI(handler)
+}
+
+// BoolParam, FloatParam and IntParam read a parameter of a request served by
+// HandleAPI, with the validation of the built-in handlers.
+func BoolParam(r *http.Request, field string) (bool, error)     { return getBoolParam(r, field) }
+func FloatParam(r *http.Request, field string) (float64, error) { return getFloatParam(r, field) }
+func IntParam(r *http.Request, field string) (int, error)       { return getIntParam(r, field) }
+
 // getParam now reads the field from the request body.
 func getParam(r *http.Request, field string, anyCase bool) (string, error) {
 	params, ok := r.Context().Value(paramsKey).(url.Values)
@@ -155,36 +471,62 @@ func getParam(r *http.Request, field string, anyCase bool) (string, error) {
 		return "", fmt.Errorf("%w: missing params", errBadRequest)
 	}
 
-	if !anyCase {
-		param, ok := params[field]
-		if ok {
-			return param[0], nil
-		}
-		return "", fmt.Errorf("%w: missing field %s", errBadRequest, field)
+	if param, ok := params[field]; ok {
+		return param[0], nil
 	}
 
-	for param, value := range params {
-		if anyCase && strings.EqualFold(param, field) {
-			return value[0], nil
+	// Outside of strict mode, the PUT parameters of older clients are
+	// matched in any case too.
+	if anyCase || !strictMode.Load() {
+		for param, value := range params {
+			if strings.EqualFold(param, field) {
+				return value[0], nil
+			}
 		}
 	}
 	return "", fmt.Errorf("%w: missing field %s", errBadRequest, field)
 }
 
+// getBoolParam reads a boolean parameter. In strict mode, only True and False,
+// in any case, are accepted, as the specification requires.
 func getBoolParam(r *http.Request, field string) (bool, error) {
 	value, err := getParam(r, field, false)
 	if err != nil {
 		return false, err
 	}
+	if strictMode.Load() {
+		switch strings.ToLower(value) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		default:
+			return false, fmt.Errorf("invalid boolean %q", value)
+		}
+	}
 	return strconv.ParseBool(value)
 }
 
+// getFloatParam reads a number parameter. NaN and infinities are rejected.
 func getFloatParam(r *http.Request, field string) (float64, error) {
-	value, err := getParam(r, field, false)
+	return getFloatParamCase(r, field, false)
+}
+
+// getFloatParamCase is getFloatParam for the GET parameters, which may be
+// matched in any case.
+func getFloatParamCase(r *http.Request, field string, anyCase bool) (float64, error) {
+	value, err := getParam(r, field, anyCase)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(value, 64)
 	if err != nil {
 		return 0, err
 	}
-	return strconv.ParseFloat(value, 64)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return f, nil
 }
 
 func getIntParam(r *http.Request, field string) (int, error) {
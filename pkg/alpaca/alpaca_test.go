@@ -0,0 +1,392 @@
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPutRequest(path, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestCheckRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    *http.Request
+		deviations int
+	}{
+		{
+			name:    "Valid PUT",
+			request: newPutRequest("/slewtoazimuth", "Azimuth=10&ClientID=1&ClientTransactionID=2"),
+		},
+		{
+			name:    "Valid GET in any case",
+			request: httptest.NewRequest(http.MethodGet, "/azimuth?clienttransactionid=2&CLIENTID=1", nil),
+		},
+		{
+			name:       "PUT with wrong casing",
+			request:    newPutRequest("/slewtoazimuth", "azimuth=10&ClientTransactionID=2"),
+			deviations: 1,
+		},
+		{
+			name:       "Unknown parameter",
+			request:    httptest.NewRequest(http.MethodGet, "/azimuth?Foo=1&ClientTransactionID=1", nil),
+			deviations: 1,
+		},
+		{
+			name: "PUT with JSON body",
+			request: func() *http.Request {
+				r := newPutRequest("/park", "ClientTransactionID=2")
+				r.Header.Set("Content-Type", "application/json")
+				return r
+			}(),
+			deviations: 1,
+		},
+		{
+			name: "Accept without JSON",
+			request: func() *http.Request {
+				r := httptest.NewRequest(http.MethodGet, "/azimuth?ClientTransactionID=1", nil)
+				r.Header.Set("Accept", "text/html")
+				return r
+			}(),
+			deviations: 1,
+		},
+		{
+			name:       "Missing ClientTransactionID",
+			request:    httptest.NewRequest(http.MethodGet, "/azimuth?ClientID=1", nil),
+			deviations: 1,
+		},
+		{
+			name:       "Malformed ClientID and ClientTransactionID",
+			request:    newPutRequest("/park", "ClientID=-1&ClientTransactionID=abc"),
+			deviations: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := addParamsToRequestContext(httptest.NewRecorder(), tc.request)
+			require.NoError(t, err)
+			assert.Len(t, checkRequest(r), tc.deviations)
+		})
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	handler := handleAPI(func(r *http.Request) (any, error) {
+		return true, nil
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newPutRequest("/park", "clienttransactionid=1"))
+		return w
+	}
+
+	SetStrictMode(false)
+	assert.Equal(t, http.StatusOK, request().Code)
+
+	SetStrictMode(true)
+	defer SetStrictMode(false)
+	assert.Equal(t, http.StatusBadRequest, request().Code)
+}
+
+func TestStrictModeParams(t *testing.T) {
+	handler := handleAPI(func(r *http.Request) (any, error) {
+		azimuth, err := getFloatParam(r, "Azimuth")
+		if err != nil {
+			return nil, errBadRequest
+		}
+		slaved, err := getBoolParam(r, "Slaved")
+		if err != nil {
+			return nil, errBadRequest
+		}
+		return fmt.Sprintf("%g %v", azimuth, slaved), nil
+	})
+
+	request := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, newPutRequest("/test", body))
+		return w
+	}
+
+	tests := []struct {
+		body    string
+		lenient int
+		strict  int
+	}{
+		{"Azimuth=10&Slaved=True&ClientTransactionID=1", http.StatusOK, http.StatusOK},
+		{"azimuth=10&slaved=true&ClientTransactionID=1", http.StatusOK, http.StatusBadRequest},
+		{"Azimuth=10&Slaved=1&ClientTransactionID=1", http.StatusOK, http.StatusBadRequest},
+		{"Azimuth=10&Slaved=False", http.StatusOK, http.StatusBadRequest},
+		{"Azimuth=ten&Slaved=False&ClientTransactionID=1", http.StatusBadRequest, http.StatusBadRequest},
+		{"Azimuth=NaN&Slaved=False&ClientTransactionID=1", http.StatusBadRequest, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		SetStrictMode(false)
+		assert.Equal(t, tt.lenient, request(tt.body).Code, "lenient: %s", tt.body)
+		SetStrictMode(true)
+		assert.Equal(t, tt.strict, request(tt.body).Code, "strict: %s", tt.body)
+	}
+	SetStrictMode(false)
+
+	w := request("Azimuth=10&Slaved=False")
+	assert.Contains(t, w.Body.String(), `"ClientTransactionID":0`, "older clients get a transaction ID of 0")
+}
+
+func TestDuplicateParams(t *testing.T) {
+	var called bool
+	handler := handleAPI(func(r *http.Request) (any, error) {
+		called = true
+		return true, nil
+	})
+
+	const (
+		accepted  = iota
+		duplicate // Answered with an InvalidValue error, without calling the handler
+		rejected  // Answered with HTTP 400, as a spec deviation
+	)
+	request := func(method, params string) int {
+		called = false
+		r := httptest.NewRequest(method, "/test?"+params, nil)
+		if method == http.MethodPut {
+			r = newPutRequest("/test", params)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		switch {
+		case w.Code == http.StatusBadRequest:
+			return rejected
+		case strings.Contains(w.Body.String(), `"ErrorNumber":1025`):
+			assert.False(t, called, "%s %s", method, params)
+			return duplicate
+		default:
+			assert.True(t, called, "%s %s", method, params)
+			return accepted
+		}
+	}
+
+	tests := []struct {
+		method  string
+		params  string
+		lenient int
+		strict  int
+	}{
+		{http.MethodPut, "Azimuth=10&ClientTransactionID=1", accepted, accepted},
+		{http.MethodPut, "Azimuth=10&Azimuth=20&ClientTransactionID=1", duplicate, duplicate},
+		{http.MethodPut, "Azimuth=10&azimuth=20&ClientTransactionID=1", duplicate, rejected},
+		{http.MethodPut, "SLAVED=True&slaved=False&ClientTransactionID=1", duplicate, rejected},
+		{http.MethodPut, "Action=a&Action=b&ClientTransactionID=1", accepted, accepted},
+		{http.MethodGet, "Azimuth=10&Azimuth=20&ClientTransactionID=1", duplicate, duplicate},
+		{http.MethodGet, "Connected=True&connected=False&ClientTransactionID=1", duplicate, duplicate},
+		{http.MethodGet, "ClientID=1&clientid=2&ClientTransactionID=1", accepted, accepted},
+	}
+	for _, tt := range tests {
+		SetStrictMode(false)
+		assert.Equal(t, tt.lenient, request(tt.method, tt.params), "lenient: %s %s", tt.method, tt.params)
+		SetStrictMode(true)
+		assert.Equal(t, tt.strict, request(tt.method, tt.params), "strict: %s %s", tt.method, tt.params)
+	}
+	SetStrictMode(false)
+}
+
+func TestHandleAPIRecoversPanic(t *testing.T) {
+	handler := handleAPI(func(r *http.Request) (any, error) {
+		panic("boom")
+	})
+
+	before := Panics()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/azimuth?ClientTransactionID=1", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"ErrorNumber":1279`)
+	assert.Equal(t, before+1, Panics())
+}
+
+func TestHandleAPIErrorNumbers(t *testing.T) {
+	tests := []struct {
+		err    error
+		number int
+	}{
+		{fmt.Errorf("slew to 90.0 degrees: %w", alpacaerrors.ErrParked), 0x408},
+		{alpacaerrors.Wrap(alpacaerrors.ErrNotConnected, fmt.Errorf("broker unreachable")), 0x407},
+		{fmt.Errorf("controller timeout"), 0x4FF},
+	}
+	for _, tt := range tests {
+		handler := handleAPI(func(r *http.Request) (any, error) {
+			return nil, tt.err
+		})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/azimuth?ClientTransactionID=1", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code, "device errors are not HTTP errors")
+		assert.Contains(t, w.Body.String(), fmt.Sprintf(`"ErrorNumber":%d`, tt.number))
+		assert.Contains(t, w.Body.String(), tt.err.Error())
+	}
+
+	w := httptest.NewRecorder()
+	handleMgm(func(r *http.Request) (any, error) {
+		return nil, alpacaerrors.ErrInvalidValue
+	}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/management/v1/peers", nil))
+	assert.Contains(t, w.Body.String(), `"ErrorNumber":1025`)
+}
+
+func TestClientID(t *testing.T) {
+	var got []uint32
+	handler := handleAPI(func(r *http.Request) (any, error) {
+		got = append(got, ClientID(r.Context()))
+		assert.Equal(t, got[len(got)-1], requestLogger(r).Data["client_id"], "the log entries are tagged")
+		return nil, nil
+	})
+
+	for _, target := range []string{
+		"/azimuth?ClientID=42&ClientTransactionID=1",
+		"/azimuth?clientid=7&ClientTransactionID=2",
+		"/azimuth?ClientTransactionID=3",
+		"/azimuth?ClientID=nina&ClientTransactionID=4",
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), newPutRequest("/park", "ClientID=9&ClientTransactionID=5"))
+
+	assert.Equal(t, []uint32{42, 7, 0, 0, 9}, got)
+}
+
+func TestDeviceTransactionIDs(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	ts := newTestServer(&fakeDome{})
+	defer ts.Close()
+
+	park := func() log.Fields {
+		req, err := http.NewRequest(http.MethodPut, ts.URL+"/api/v1/dome/0/park", strings.NewReader("ClientTransactionID=1"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		for _, e := range hook.AllEntries() {
+			if e.Message == "Alpaca command" {
+				defer hook.Reset()
+				return e.Data
+			}
+		}
+		t.Fatal("command not logged")
+		return nil
+	}
+
+	fields := park()
+	assert.NotContains(t, fields, "device_transaction_id", "disabled by default")
+	assert.NotZero(t, fields["server_transaction_id"])
+
+	SetDeviceTransactionIDs(true)
+	t.Cleanup(func() { SetDeviceTransactionIDs(false) })
+	first := park()
+	assert.Equal(t, "dome/0", first["device"])
+	second := park()
+	assert.Equal(t, first["device_transaction_id"].(uint32)+1, second["device_transaction_id"])
+	assert.Greater(t, second["server_transaction_id"], first["server_transaction_id"])
+}
+
+func TestExpandDescription(t *testing.T) {
+	host, _ := os.Hostname()
+
+	assert.Equal(t, "ZRO Dome fw 2.3 @ observatory-east",
+		ExpandDescription("ZRO Dome fw {firmware} @ observatory-east", map[string]string{"firmware": "2.3"}))
+	assert.Equal(t, "Dome on "+host, ExpandDescription("Dome on {host}", nil))
+	assert.Equal(t, "Dome {unknown}", ExpandDescription("Dome {unknown}", nil))
+}
+
+func TestParseDeviceList(t *testing.T) {
+	devices, err := ParseDeviceList("# devices\ndome_simulator 0\n\nzro 1\nzro 2 zro_config_2\nzro 3 zro_config_3 my-uid\n")
+	require.NoError(t, err)
+	assert.Equal(t, []DeviceConfig{
+		{Driver: "dome_simulator", Number: 0},
+		{Driver: "zro", Number: 1},
+		{Driver: "zro", Number: 2, Key: "zro_config_2"},
+		{Driver: "zro", Number: 3, Key: "zro_config_3", UniqueID: "my-uid"},
+	}, devices)
+
+	for _, dev := range devices {
+		parsed, err := ParseDeviceList(dev.String())
+		require.NoError(t, err)
+		assert.Equal(t, []DeviceConfig{dev}, parsed)
+	}
+
+	_, err = ParseDeviceList("zro")
+	assert.Error(t, err)
+	_, err = ParseDeviceList("zro one")
+	assert.Error(t, err)
+}
+
+func TestReadDeviceFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"driver": "zro", "number": 0, "settings": {"Host": "east"}},
+		{"driver": "zro", "number": 1, "key": "zro_config_2", "settings": {"Host": "west"}}
+	]`), 0600))
+
+	devices, err := ReadDeviceFile(path)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, "zro_config_2", devices[1].Key)
+	assert.JSONEq(t, `{"Host": "west"}`, string(devices[1].Settings))
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"number": 0}]`), 0600))
+	_, err = ReadDeviceFile(path)
+	assert.Error(t, err, "no driver")
+
+	_, err = ReadDeviceFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestMergeSettings(t *testing.T) {
+	cfg := struct {
+		Speed    int    `json:"speed"`
+		Name     string `json:"name"`
+		Disabled bool   `json:"disabled"`
+	}{Speed: 5, Name: "sim", Disabled: true}
+
+	changed, err := MergeSettings(&cfg, json.RawMessage(`{"speed": 10, "disabled": false}`))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 10, cfg.Speed)
+	assert.Equal(t, "sim", cfg.Name, "kept")
+	assert.False(t, cfg.Disabled)
+
+	changed, err = MergeSettings(&cfg, json.RawMessage(`{"speed": 10}`))
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	_, err = MergeSettings(&cfg, json.RawMessage(`{"sped": 10}`))
+	assert.Error(t, err, "unknown field")
+	_, err = MergeSettings(&cfg, json.RawMessage(`{"speed": "fast"}`))
+	assert.Error(t, err)
+}
+
+func TestInstanceUID(t *testing.T) {
+	const base = "0a0af300-b0fc-4178-b758-caa109fc836f"
+
+	assert.Equal(t, base, InstanceUID(base, ""))
+
+	uid := InstanceUID(base, "zro_config_2")
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, uid)
+	assert.Equal(t, uid, InstanceUID(base, "zro_config_2"), "stable across restarts")
+	assert.NotEqual(t, uid, InstanceUID(base, "zro_config_3"))
+}
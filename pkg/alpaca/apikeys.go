@@ -0,0 +1,238 @@
+package alpaca
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Scope is a permission granted to an API key.
+type Scope string
+
+const (
+	ScopeRead      Scope = "read"             // Read the state of the devices and the server
+	ScopeRotation  Scope = "control-rotation" // Slew, park, home and slave the domes, connect the devices, and every other command
+	ScopeShutter   Scope = "control-shutter"  // Open and close the shutters
+	ScopeConfigure Scope = "configure"        // Use the setup pages, actions, raw commands and the profiler
+)
+
+// Scopes lists the scopes in the order of the setup page.
+var Scopes = []Scope{ScopeRead, ScopeRotation, ScopeShutter, ScopeConfigure}
+
+// APIKey is a key accepted by the server. Only the hash of the key is saved,
+// the key itself is shown once, when it is created.
+type APIKey struct {
+	Name   string  `json:"name"`   // Name shown on the setup page and in the logs
+	Hash   string  `json:"hash"`   // Hex encoded SHA-256 of the key
+	Scopes []Scope `json:"scopes"` // Permissions granted to the key
+}
+
+// Allows reports whether the key grants the scope.
+func (k APIKey) Allows(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// NewAPIKey creates a key with the scopes. It returns the key to save and
+// the secret to give to the client.
+func NewAPIKey(name string, scopes []Scope) (APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return APIKey{}, "", fmt.Errorf("missing API key name")
+	}
+	if len(scopes) == 0 {
+		return APIKey{}, "", fmt.Errorf("API key %q has no scope", name)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return APIKey{}, "", fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return APIKey{}, "", err
+	}
+	secret := hex.EncodeToString(b)
+
+	return APIKey{Name: name, Hash: hashAPIKey(secret), Scopes: scopes}, secret, nil
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidateAPIKeys checks that the keys have unique names and that one of
+// them can still open the setup page, so configuring keys cannot lock the
+// administrator out.
+func ValidateAPIKeys(keys []APIKey) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	names := make(map[string]bool, len(keys))
+	configure := false
+	for _, key := range keys {
+		if names[key.Name] {
+			return fmt.Errorf("duplicate API key name %q", key.Name)
+		}
+		names[key.Name] = true
+		configure = configure || key.Allows(ScopeConfigure)
+	}
+	if !configure {
+		return fmt.Errorf("at least one API key needs the %s scope", ScopeConfigure)
+	}
+	return nil
+}
+
+// ValidateOpenScopes checks the scopes granted without a key. The setup
+// pages manage the keys, so they cannot be open.
+func ValidateOpenScopes(scopes []Scope) error {
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+		if scope == ScopeConfigure {
+			return fmt.Errorf("the %s scope cannot be open", ScopeConfigure)
+		}
+	}
+	return nil
+}
+
+// apiKeys holds the keys accepted by the server, none to accept any request.
+var apiKeys atomic.Pointer[[]APIKey]
+
+// openScopes holds the scopes granted to the requests without a key once
+// keys are configured.
+var openScopes atomic.Pointer[[]Scope]
+
+// SetAPIKeys sets the keys accepted by the server. Without keys, every
+// request is accepted, as on a trusted network.
+func SetAPIKeys(keys []APIKey) {
+	keys = slices.Clone(keys)
+	apiKeys.Store(&keys)
+}
+
+// SetOpenScopes sets the scopes granted without a key, e.g. read to let
+// anyone on the network read the devices while the commands need a key.
+func SetOpenScopes(scopes []Scope) {
+	scopes = slices.Clone(scopes)
+	openScopes.Store(&scopes)
+}
+
+// isOpenScope reports whether the scope is granted without a key.
+func isOpenScope(scope Scope) bool {
+	scopes := openScopes.Load()
+	return scopes != nil && slices.Contains(*scopes, scope)
+}
+
+// findAPIKey returns the configured key matching the secret.
+func findAPIKey(secret string) (APIKey, bool) {
+	keys := apiKeys.Load()
+	if keys == nil || secret == "" {
+		return APIKey{}, false
+	}
+
+	hash := []byte(hashAPIKey(secret))
+	for _, key := range *keys {
+		if subtle.ConstantTimeCompare(hash, []byte(key.Hash)) == 1 {
+			return key, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// requestAPIKey returns the key sent as a bearer token, or as the password of
+// basic authentication, which Alpaca clients and browsers support.
+func requestAPIKey(r *http.Request) string {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// shutterMethods are the device methods that move a shutter.
+var shutterMethods = []string{"openshutter", "closeshutter", "slewtoaltitude"}
+
+// configureMethods are the device methods that reach the driver beyond the
+// standard interface, and the management method restarting a driver.
+var configureMethods = []string{"action", "commandblind", "commandbool", "commandstring", "restartdevice"}
+
+// requiredScope returns the scope needed by a request. The setup pages, under
+// /setup or at any path ending with setup, such as a device of another server
+// forwarded under the API prefix, need the configure scope.
+func requiredScope(r *http.Request) Scope {
+	if r.URL.Path == "/setup" || strings.HasPrefix(r.URL.Path, "/setup/") || isSetupPage(r.URL.Path) || isProfilingPath(r.URL.Path) {
+		return ScopeConfigure
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || isBatchPath(r.URL.Path) {
+		return ScopeRead
+	}
+
+	method := strings.ToLower(path.Base(r.URL.Path))
+	switch {
+	case slices.Contains(shutterMethods, method):
+		return ScopeShutter
+	case slices.Contains(configureMethods, method):
+		return ScopeConfigure
+	default:
+		return ScopeRotation
+	}
+}
+
+// isSetupPage reports whether the last segment of a path is setup, in any
+// case.
+func isSetupPage(p string) bool {
+	return strings.EqualFold(path.Base(p), "setup")
+}
+
+// publicPaths are served without a key: the public status is meant to be
+// fetched by anyone.
+var publicPaths = []string{"/status.json", WidgetPrefix + "/status"}
+
+const apiKeyNameKey contextKey = "apiKey"
+
+// authMiddleware rejects the requests without a key granting the scope they
+// need, once keys are configured: 401 without a valid key, 403 when the key
+// lacks the scope. The requests needing an open scope are served without a
+// key.
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if keys := apiKeys.Load(); keys == nil || len(*keys) == 0 || slices.Contains(publicPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scope := requiredScope(r)
+		key, ok := findAPIKey(requestAPIKey(r))
+		if !ok && isOpenScope(scope) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="zro-alpaca"`)
+			http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		if !key.Allows(scope) && !isOpenScope(scope) {
+			requestLogger(r).Warnf("API key %q lacks the %s scope", key.Name, scope)
+			http.Error(w, fmt.Sprintf("API key %q lacks the %s scope", key.Name, scope), http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiKeyNameKey, key.Name)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
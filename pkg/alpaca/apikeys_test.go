@@ -0,0 +1,141 @@
+package alpaca
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyScopes(t *testing.T) {
+	guest, guestSecret, err := NewAPIKey("weather display", []Scope{ScopeRead})
+	require.NoError(t, err)
+	operator, operatorSecret, err := NewAPIKey("operator", []Scope{ScopeRead, ScopeRotation})
+	require.NoError(t, err)
+	rotator, rotatorSecret, err := NewAPIKey("rotator", []Scope{ScopeRotation})
+	require.NoError(t, err)
+	admin, adminSecret, err := NewAPIKey("admin", Scopes)
+	require.NoError(t, err)
+
+	SetAPIKeys([]APIKey{guest, operator, rotator, admin})
+	t.Cleanup(func() { SetAPIKeys(nil) })
+
+	ts := newTestServer(&fakeDome{})
+	defer ts.Close()
+
+	do := func(method, path, secret string, basic bool) int {
+		var body *strings.Reader
+		if method == http.MethodPut {
+			body = strings.NewReader(url.Values{"ClientTransactionID": {"1"}, "Azimuth": {"90"}}.Encode())
+		} else {
+			body = strings.NewReader("")
+			path += "?ClientTransactionID=1"
+		}
+		req, err := http.NewRequest(method, ts.URL+path, body)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basic {
+			req.SetBasicAuth("", secret)
+		} else if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		method, path, secret string
+		basic                bool
+		status               int
+	}{
+		{http.MethodGet, "/api/v1/dome/0/azimuth", "", false, http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/dome/0/azimuth", "wrong", false, http.StatusUnauthorized},
+		{http.MethodGet, "/api/v1/dome/0/azimuth", guestSecret, false, http.StatusOK},
+		{http.MethodGet, "/api/v1/dome/0/azimuth", guestSecret, true, http.StatusOK},
+		{http.MethodGet, "/status.json", "", false, http.StatusOK},
+		{http.MethodPut, "/api/v1/dome/0/openshutter", guestSecret, false, http.StatusForbidden},
+		{http.MethodPut, "/api/v1/dome/0/slewtoazimuth", guestSecret, false, http.StatusForbidden},
+		{http.MethodPut, "/api/v1/dome/0/slewtoazimuth", operatorSecret, false, http.StatusOK},
+		{http.MethodPut, "/api/v1/dome/0/openshutter", operatorSecret, false, http.StatusForbidden},
+		{http.MethodPut, "/api/v1/dome/0/action", operatorSecret, false, http.StatusForbidden},
+		{http.MethodGet, "/setup/v1/dome/0/setup", operatorSecret, false, http.StatusForbidden},
+		{http.MethodGet, "/api/v1/dome/0/setup", guestSecret, false, http.StatusForbidden},
+		{http.MethodPost, "/api/v1/dome/0/setup", guestSecret, false, http.StatusForbidden},
+		{http.MethodGet, "/api/v1/dome/0/setup", rotatorSecret, false, http.StatusForbidden},
+		{http.MethodPost, "/api/v1/dome/0/Setup", rotatorSecret, false, http.StatusForbidden},
+		{http.MethodGet, "/api/v1/dome/0/setup", adminSecret, false, http.StatusNotFound},
+		{http.MethodPut, "/api/v1/dome/0/openshutter", adminSecret, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.status, do(tt.method, tt.path, tt.secret, tt.basic), "%s %s", tt.method, tt.path)
+	}
+}
+
+func TestValidateAPIKeys(t *testing.T) {
+	read, _, err := NewAPIKey("read", []Scope{ScopeRead})
+	require.NoError(t, err)
+	admin, _, err := NewAPIKey("admin", []Scope{ScopeConfigure})
+	require.NoError(t, err)
+
+	assert.NoError(t, ValidateAPIKeys(nil))
+	assert.NoError(t, ValidateAPIKeys([]APIKey{read, admin}))
+	assert.Error(t, ValidateAPIKeys([]APIKey{read}), "no key can configure the server")
+	assert.Error(t, ValidateAPIKeys([]APIKey{admin, admin}), "duplicate names")
+
+	_, _, err = NewAPIKey("", []Scope{ScopeRead})
+	assert.Error(t, err)
+	_, _, err = NewAPIKey("guest", nil)
+	assert.Error(t, err)
+	_, _, err = NewAPIKey("guest", []Scope{"admin"})
+	assert.Error(t, err)
+}
+
+func TestOpenScopes(t *testing.T) {
+	guest, guestSecret, err := NewAPIKey("weather display", []Scope{ScopeRead})
+	require.NoError(t, err)
+	admin, _, err := NewAPIKey("admin", Scopes)
+	require.NoError(t, err)
+
+	SetAPIKeys([]APIKey{guest, admin})
+	SetOpenScopes([]Scope{ScopeRead, ScopeShutter})
+	t.Cleanup(func() {
+		SetAPIKeys(nil)
+		SetOpenScopes(nil)
+	})
+
+	ts := newTestServer(&fakeDome{})
+	defer ts.Close()
+
+	do := func(method, path, secret string) int {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(url.Values{"ClientTransactionID": {"1"}, "Azimuth": {"90"}}.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/v1/dome/0/azimuth", ""))
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/api/v1/dome/0/openshutter", ""))
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/api/v1/dome/0/openshutter", guestSecret), "open to a key without the scope")
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodPut, "/api/v1/dome/0/slewtoazimuth", ""))
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPut, "/api/v1/dome/0/slewtoazimuth", guestSecret))
+	assert.Equal(t, http.StatusUnauthorized, do(http.MethodGet, "/setup", ""))
+}
+
+func TestValidateOpenScopes(t *testing.T) {
+	assert.NoError(t, ValidateOpenScopes(nil))
+	assert.NoError(t, ValidateOpenScopes([]Scope{ScopeRead, ScopeRotation}))
+	assert.Error(t, ValidateOpenScopes([]Scope{ScopeConfigure}), "the setup pages manage the keys")
+	assert.Error(t, ValidateOpenScopes([]Scope{"admin"}))
+}
@@ -0,0 +1,144 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// maxBackups is the number of rotating database backups kept next to the
+// database file, named <path>.bak.1 (newest) to <path>.bak.<maxBackups>.
+const maxBackups = 5
+
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.bak.%d", path, n)
+}
+
+// BackupDB writes a consistent copy of the database to the newest backup
+// slot, rotating the older backups.
+func BackupDB(db *bolt.DB) error {
+	path := db.Path()
+	tmp := path + ".bak.tmp"
+
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(tmp, 0600)
+	})
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to copy database: %v", err)
+	}
+
+	for n := maxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(backupPath(path, n), backupPath(path, n+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate backups: %v", err)
+		}
+	}
+
+	return os.Rename(tmp, backupPath(path, 1))
+}
+
+// OpenDB opens the database at path. If the database cannot be opened or the
+// stored configurations cannot be decoded, the corrupted file is moved aside
+// and the most recent valid backup is restored in its place.
+func OpenDB(path string) (*bolt.DB, error) {
+	db, err := openAndCheck(path, nil)
+	if err == nil {
+		return db, nil
+	} else if errors.Is(err, os.ErrPermission) {
+		return nil, err
+	}
+
+	log.Errorf("Database %s is corrupted: %v", path, err)
+
+	corrupted := fmt.Sprintf("%s.corrupted-%s", path, time.Now().Format("20060102-150405"))
+	if err := os.Rename(path, corrupted); err != nil {
+		return nil, fmt.Errorf("failed to move corrupted database aside: %v", err)
+	}
+	log.Warnf("Corrupted database moved to %s", corrupted)
+
+	for n := 1; n <= maxBackups; n++ {
+		backup := backupPath(path, n)
+		if _, err := os.Stat(backup); err != nil {
+			continue
+		}
+
+		bdb, err := openAndCheck(backup, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+		if err != nil {
+			log.Warnf("Skipping backup %s: %v", backup, err)
+			continue
+		}
+		bdb.Close()
+
+		if err := copyFile(backup, path); err != nil {
+			return nil, fmt.Errorf("failed to restore backup %s: %v", backup, err)
+		}
+		log.Warnf("Database restored from backup %s", backup)
+
+		return openAndCheck(path, nil)
+	}
+
+	return nil, fmt.Errorf("database %s is corrupted and no valid backup was found (corrupted file kept as %s)", path, corrupted)
+}
+
+// openAndCheck opens a database and verifies that every stored configuration
+// is valid JSON. bbolt panics on some kinds of corruption, so panics are
+// reported as errors.
+func openAndCheck(path string, opts *bolt.Options) (db *bolt.DB, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if db != nil {
+				db.Close()
+			}
+			db, err = nil, fmt.Errorf("panic while reading database: %v", r)
+		}
+	}()
+
+	db, err = bolt.Open(path, 0600, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if v != nil && !json.Valid(v) {
+				return fmt.Errorf("invalid value for key %s", k)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,63 @@
+package alpaca
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestOpenDBRestoresBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alpaca.db")
+
+	db, err := OpenDB(path)
+	require.NoError(t, err)
+	store, err := NewStore(db)
+	require.NoError(t, err)
+	require.NoError(t, store.SetConfig(Config{StrictMode: true}))
+	assert.FileExists(t, backupPath(path, 1))
+
+	// Corrupt the configuration without going through the store.
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Put([]byte(configKey), []byte("{not json"))
+	}))
+	require.NoError(t, db.Close())
+
+	db, err = OpenDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err = NewStore(db)
+	require.NoError(t, err)
+	cfg, err := store.GetConfig()
+	require.NoError(t, err)
+	assert.True(t, cfg.StrictMode)
+
+	matches, _ := filepath.Glob(path + ".corrupted-*")
+	assert.Len(t, matches, 1)
+}
+
+func TestOpenDBWithoutBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alpaca.db")
+	require.NoError(t, os.WriteFile(path, []byte("garbage"), 0600))
+
+	_, err := OpenDB(path)
+	assert.Error(t, err)
+}
+
+func TestBackupRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alpaca.db")
+	db, err := OpenDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	for i := 0; i < maxBackups+2; i++ {
+		require.NoError(t, BackupDB(db))
+	}
+
+	matches, _ := filepath.Glob(path + ".bak.*")
+	assert.Len(t, matches, maxBackups)
+}
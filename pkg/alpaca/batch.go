@@ -0,0 +1,166 @@
+package alpaca
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+)
+
+// maxBatchSize is the largest number of properties read by a batch request.
+const maxBatchSize = 100
+
+// batchEndpoint enables the batch endpoint, a non-standard extension.
+var batchEndpoint atomic.Bool
+
+// SetBatchEndpoint enables or disables the batch endpoint, which reads
+// several properties of the devices in one round trip. It is off by default
+// since it is not part of the Alpaca specification.
+func SetBatchEndpoint(enabled bool) {
+	batchEndpoint.Store(enabled)
+}
+
+// BatchRequest is a property read by a batch request, e.g. the azimuth of
+// dome 0.
+type BatchRequest struct {
+	DeviceType   string `json:"DeviceType"`
+	DeviceNumber int    `json:"DeviceNumber"`
+	Property     string `json:"Property"`
+}
+
+// BatchResult is the answer to a BatchRequest, with the Value, ErrorNumber
+// and ErrorMessage the property would have returned on its own.
+type BatchResult struct {
+	BatchRequest
+	Value        json.RawMessage `json:"Value,omitempty"`
+	ErrorNumber  int             `json:"ErrorNumber"`
+	ErrorMessage string          `json:"ErrorMessage"`
+}
+
+// batchPath returns the path of the batch endpoint of an API version.
+func batchPath(version int) string {
+	return fmt.Sprintf("/api/v%d/batch", version)
+}
+
+// isBatchPath reports whether a path is the batch endpoint of an API
+// version. The batch only reads properties, so it needs the read scope.
+func isBatchPath(path string) bool {
+	for _, version := range apiVersions {
+		if path == batchPath(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// batchName matches the device types and the properties of a batch request.
+var batchName = regexp.MustCompile(`^[a-z]+$`)
+
+// handleBatch reads the properties listed by the JSON array of the request
+// body, in order, and returns their results as its Value. Each property is
+// read with a GET request through the routes of the devices, so the batch
+// cannot run a command; a result whose request was not answered with HTTP
+// 200, such as an unknown property, gets its error message with the
+// ErrorNumber of an invalid value.
+func handleBatch(mux *http.ServeMux, version int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !batchEndpoint.Load() {
+			http.NotFound(w, r)
+			return
+		}
+
+		var requests []BatchRequest
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			http.Error(w, fmt.Sprintf("invalid batch: %v", err), http.StatusBadRequest)
+			return
+		}
+		if len(requests) > maxBatchSize {
+			http.Error(w, fmt.Sprintf("invalid batch: more than %d properties", maxBatchSize), http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		txID, _ := strconv.ParseUint(query.Get("ClientTransactionID"), 10, 32)
+
+		results := make([]BatchResult, len(requests))
+		for i, req := range requests {
+			results[i] = readBatchProperty(mux, r, version, req)
+		}
+
+		requestLogger(r).WithField("client_transaction_id", txID).Debugf("Alpaca batch of %d properties", len(requests))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(baseResponse{
+			ClientTransactionID: int(txID),
+			ServerTransactionID: int(txCounter.Add(1)),
+			Value:               results,
+		})
+	})
+}
+
+// readBatchProperty reads a property of a batch request with a GET request
+// carrying the ClientID and ClientTransactionID of the batch.
+func readBatchProperty(mux *http.ServeMux, r *http.Request, version int, req BatchRequest) BatchResult {
+	req.DeviceType = strings.ToLower(req.DeviceType)
+	req.Property = strings.ToLower(req.Property)
+	result := BatchResult{BatchRequest: req}
+
+	if !batchName.MatchString(req.DeviceType) || !batchName.MatchString(req.Property) || req.DeviceNumber < 0 {
+		result.ErrorNumber = alpacaerrors.ErrInvalidValue.Number
+		result.ErrorMessage = fmt.Sprintf("invalid property %s/%d/%s", req.DeviceType, req.DeviceNumber, req.Property)
+		return result
+	}
+
+	params := url.Values{}
+	for _, name := range []string{"ClientID", "ClientTransactionID"} {
+		if value := r.URL.Query().Get(name); value != "" {
+			params.Set(name, value)
+		}
+	}
+	target := fmt.Sprintf("/api/v%d/%s/%d/%s?%s", version, req.DeviceType, req.DeviceNumber, req.Property, params.Encode())
+	get, err := http.NewRequestWithContext(r.Context(), http.MethodGet, target, nil)
+	if err != nil {
+		result.ErrorNumber = alpacaerrors.ErrInvalidValue.Number
+		result.ErrorMessage = err.Error()
+		return result
+	}
+	get.RemoteAddr = r.RemoteAddr
+
+	rec := newBatchRecorder()
+	mux.ServeHTTP(rec, get)
+
+	var response struct {
+		Value        json.RawMessage
+		ErrorNumber  int
+		ErrorMessage string
+	}
+	if rec.status != http.StatusOK || json.Unmarshal(rec.body.Bytes(), &response) != nil {
+		result.ErrorNumber = alpacaerrors.ErrInvalidValue.Number
+		result.ErrorMessage = strings.TrimSpace(rec.body.String())
+		return result
+	}
+	result.Value, result.ErrorNumber, result.ErrorMessage = response.Value, response.ErrorNumber, response.ErrorMessage
+	return result
+}
+
+// batchRecorder records the response to a property read of a batch.
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBatchRecorder() *batchRecorder {
+	return &batchRecorder{header: http.Header{}, status: http.StatusOK}
+}
+
+func (br *batchRecorder) Header() http.Header         { return br.header }
+func (br *batchRecorder) Write(p []byte) (int, error) { return br.body.Write(p) }
+func (br *batchRecorder) WriteHeader(status int)      { br.status = status }
@@ -0,0 +1,91 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func postBatch(t *testing.T, url, body string) (int, []BatchResult) {
+	t.Helper()
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	var response struct {
+		ClientTransactionID int
+		Value               []BatchResult
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	assert.Equal(t, 7, response.ClientTransactionID)
+	return resp.StatusCode, response.Value
+}
+
+func TestBatch(t *testing.T) {
+	dome := &fakeDome{connected: true, status: DomeStatus{Azimuth: 42.5, Shutter: ShutterOpen}}
+	ts := newTestServer(dome)
+	defer ts.Close()
+	url := ts.URL + "/api/v1/batch?ClientID=3&ClientTransactionID=7"
+
+	status, _ := postBatch(t, url, `[]`)
+	assert.Equal(t, http.StatusNotFound, status, "off by default")
+
+	SetBatchEndpoint(true)
+	t.Cleanup(func() { SetBatchEndpoint(false) })
+
+	status, results := postBatch(t, url, `[
+		{"DeviceType": "dome", "DeviceNumber": 0, "Property": "azimuth"},
+		{"DeviceType": "Dome", "DeviceNumber": 0, "Property": "ShutterStatus"},
+		{"DeviceType": "dome", "DeviceNumber": 0, "Property": "openshutter"},
+		{"DeviceType": "dome", "DeviceNumber": 1, "Property": "azimuth"},
+		{"DeviceType": "dome", "DeviceNumber": 0, "Property": "../setup"},
+		{"DeviceType": "dome", "DeviceNumber": 0, "Property": "setup"}
+	]`)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, results, 6)
+
+	assert.JSONEq(t, "42.5", string(results[0].Value))
+	assert.Zero(t, results[0].ErrorNumber)
+	assert.JSONEq(t, "0", string(results[1].Value))
+	assert.Equal(t, "shutterstatus", results[1].Property)
+	for _, result := range results[2:] {
+		assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, result.ErrorNumber, result.Property)
+		assert.Nil(t, result.Value)
+	}
+	assert.Equal(t, ShutterOpen, dome.status.Shutter, "commands are not run")
+
+	status, _ = postBatch(t, url, `{"DeviceType": "dome"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = postBatch(t, url, "["+strings.Repeat(`{},`, maxBatchSize)+`{}]`)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestBatchScope(t *testing.T) {
+	guest, guestSecret, err := NewAPIKey("weather display", []Scope{ScopeRead})
+	require.NoError(t, err)
+	SetAPIKeys([]APIKey{guest})
+	t.Cleanup(func() { SetAPIKeys(nil) })
+	SetBatchEndpoint(true)
+	t.Cleanup(func() { SetBatchEndpoint(false) })
+
+	ts := newTestServer(&fakeDome{})
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/v1/batch", strings.NewReader(`[]`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+guestSecret)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
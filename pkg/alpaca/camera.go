@@ -0,0 +1,403 @@
+// Documentation: https://ascom-standards.org/api/#/Camera%20Specific%20Methods
+
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type CameraState int
+
+const (
+	CameraIdle CameraState = iota
+	CameraWaiting
+	CameraExposing
+	CameraReading
+	CameraDownload
+	CameraError
+)
+
+func (s CameraState) String() string {
+	switch s {
+	case CameraIdle:
+		return "Idle"
+	case CameraWaiting:
+		return "Waiting"
+	case CameraExposing:
+		return "Exposing"
+	case CameraReading:
+		return "Reading"
+	case CameraDownload:
+		return "Download"
+	case CameraError:
+		return "Error"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
+type SensorType int
+
+const (
+	SensorMonochrome SensorType = iota
+	SensorColor                 // Full color, one plane per color
+	SensorRGGB
+	SensorCMYG
+	SensorCMYG2
+	SensorLRGB
+)
+
+type CameraCapabilities struct {
+	SensorName       string
+	SensorType       SensorType
+	CameraXSize      int     // Pixels
+	CameraYSize      int     // Pixels
+	PixelSizeX       float64 // Microns
+	PixelSizeY       float64 // Microns
+	BayerOffsetX     int
+	BayerOffsetY     int
+	MaxBinX          int
+	MaxBinY          int
+	CanAsymmetricBin bool
+	MaxADU           int
+	ElectronsPerADU  float64
+	FullWellCapacity float64 // Electrons
+
+	ExposureMin        float64 // Seconds
+	ExposureMax        float64 // Seconds
+	ExposureResolution float64 // Seconds, 0 for any duration
+	CanAbortExposure   bool
+	CanStopExposure    bool
+	HasShutter         bool
+}
+
+type CameraStatus struct {
+	State                CameraState
+	ImageReady           bool
+	PercentCompleted     int
+	LastExposureDuration float64   // Seconds, of the last exposure started
+	LastExposureStart    time.Time // Zero before the first exposure
+	CCDTemperature       *float64  // Celsius, nil without a sensor
+}
+
+func (cs CameraStatus) ToProperties() []StateProperty {
+	props := []StateProperty{
+		{"CameraState", int(cs.State)},
+		{"ImageReady", cs.ImageReady},
+		{"PercentCompleted", cs.PercentCompleted},
+	}
+	if cs.CCDTemperature != nil {
+		props = append(props, StateProperty{"CCDTemperature", *cs.CCDTemperature})
+	}
+	return props
+}
+
+// CameraFrame is the binning and the subframe of the next exposures. The
+// subframe is in binned pixels.
+type CameraFrame struct {
+	BinX, BinY     int
+	StartX, StartY int
+	NumX, NumY     int
+}
+
+// Camera takes images. The handler checks the capabilities, the binning and
+// the exposure durations; the subframe is checked against the binned sensor
+// when an exposure starts, as the specification requires. StartExposure
+// starts an exposure and returns at once, and Image returns the last image
+// once it is ready. No driver serves a camera yet.
+type Camera interface {
+	Device
+
+	Capabilities() CameraCapabilities
+	Status() CameraStatus
+	Frame() CameraFrame
+
+	SetFrame(CameraFrame) error
+	StartExposure(duration float64, light bool) error
+	AbortExposure() error
+	StopExposure() error
+	Image() (*Image, error)
+}
+
+type CameraHandler struct {
+	DeviceHandler
+	dev Camera
+}
+
+func NewCameraHandler(dev Camera, version int) *CameraHandler {
+	return &CameraHandler{
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
+		dev:           dev,
+	}
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeCamera, func(dev Device, version int) DeviceHTTPHandler {
+		if c, ok := dev.(Camera); ok {
+			return NewCameraHandler(c, version)
+		}
+		return nil
+	})
+}
+
+func (ch *CameraHandler) RegisterRoutes(mux *http.ServeMux) {
+	ch.DeviceHandler.RegisterRoutes(mux)
+
+	for _, property := range []string{
+		"sensorname", "sensortype", "cameraxsize", "cameraysize", "pixelsizex", "pixelsizey",
+		"bayeroffsetx", "bayeroffsety", "maxbinx", "maxbiny", "canasymmetricbin", "maxadu",
+		"electronsperadu", "fullwellcapacity", "exposuremin", "exposuremax", "exposureresolution",
+		"canabortexposure", "canstopexposure", "hasshutter",
+	} {
+		mux.Handle("GET /"+property, ch.handleStatic(ch.handleCapability))
+	}
+	// The features of the camera API no camera of this server has yet.
+	for _, property := range []string{"canfastreadout", "cangetcoolerpower", "canpulseguide", "cansetccdtemperature"} {
+		mux.Handle("GET /"+property, ch.handleStatic(func(r *http.Request) (any, error) {
+			return false, nil
+		}))
+	}
+	for _, property := range []string{"camerastate", "imageready", "percentcompleted", "lastexposureduration", "lastexposurestarttime", "ccdtemperature"} {
+		mux.Handle("GET /"+property, handleAPI(ch.handleStatus))
+	}
+	for _, property := range []string{"binx", "biny", "startx", "starty", "numx", "numy"} {
+		mux.Handle("GET /"+property, handleAPI(ch.handleFrame))
+		mux.Handle("PUT /"+property, handleAPI(ch.handleSetFrame))
+	}
+	mux.Handle("GET /imagearray", serveAPI(ch.handleImageArray, writeImage))
+
+	mux.Handle("PUT /startexposure", handleAPI(ch.handleStartExposure))
+	mux.Handle("PUT /abortexposure", handleAPI(ch.handleAbortExposure))
+	mux.Handle("PUT /stopexposure", handleAPI(ch.handleStopExposure))
+}
+
+func (ch *CameraHandler) handleCapability(r *http.Request) (any, error) {
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	caps := ch.dev.Capabilities()
+
+	property := r.URL.Path[1:]
+	switch property {
+	case "sensorname":
+		return caps.SensorName, nil
+	case "sensortype":
+		return caps.SensorType, nil
+	case "cameraxsize":
+		return caps.CameraXSize, nil
+	case "cameraysize":
+		return caps.CameraYSize, nil
+	case "pixelsizex":
+		return caps.PixelSizeX, nil
+	case "pixelsizey":
+		return caps.PixelSizeY, nil
+	case "bayeroffsetx", "bayeroffsety":
+		if caps.SensorType == SensorMonochrome || caps.SensorType == SensorColor {
+			return nil, alpacaerrors.ErrNotImplemented
+		}
+		if property == "bayeroffsetx" {
+			return caps.BayerOffsetX, nil
+		}
+		return caps.BayerOffsetY, nil
+	case "maxbinx":
+		return caps.MaxBinX, nil
+	case "maxbiny":
+		return caps.MaxBinY, nil
+	case "canasymmetricbin":
+		return caps.CanAsymmetricBin, nil
+	case "maxadu":
+		return caps.MaxADU, nil
+	case "electronsperadu":
+		return caps.ElectronsPerADU, nil
+	case "fullwellcapacity":
+		return caps.FullWellCapacity, nil
+	case "exposuremin":
+		return caps.ExposureMin, nil
+	case "exposuremax":
+		return caps.ExposureMax, nil
+	case "exposureresolution":
+		return caps.ExposureResolution, nil
+	case "canabortexposure":
+		return caps.CanAbortExposure, nil
+	case "canstopexposure":
+		return caps.CanStopExposure, nil
+	case "hasshutter":
+		return caps.HasShutter, nil
+	default:
+		return nil, errBadRequest
+	}
+}
+
+func (ch *CameraHandler) handleStatus(r *http.Request) (any, error) {
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	status := ch.dev.Status()
+
+	property := r.URL.Path[1:]
+	switch property {
+	case "camerastate":
+		return status.State, nil
+	case "imageready":
+		return status.ImageReady, nil
+	case "percentcompleted":
+		return status.PercentCompleted, nil
+	case "lastexposureduration", "lastexposurestarttime":
+		if status.LastExposureStart.IsZero() {
+			return nil, alpacaerrors.ErrValueNotSet
+		}
+		if property == "lastexposureduration" {
+			return status.LastExposureDuration, nil
+		}
+		// FITS format, as the specification requires.
+		return status.LastExposureStart.UTC().Format("2006-01-02T15:04:05.000"), nil
+	case "ccdtemperature":
+		if status.CCDTemperature == nil {
+			return nil, alpacaerrors.ErrNotImplemented
+		}
+		return *status.CCDTemperature, nil
+	default:
+		return nil, errBadRequest
+	}
+}
+
+func (ch *CameraHandler) handleFrame(r *http.Request) (any, error) {
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	frame := ch.dev.Frame()
+
+	switch r.URL.Path[1:] {
+	case "binx":
+		return frame.BinX, nil
+	case "biny":
+		return frame.BinY, nil
+	case "startx":
+		return frame.StartX, nil
+	case "starty":
+		return frame.StartY, nil
+	case "numx":
+		return frame.NumX, nil
+	case "numy":
+		return frame.NumY, nil
+	default:
+		return nil, errBadRequest
+	}
+}
+
+// handleSetFrame changes the binning or the subframe. A symmetric binning
+// sets both axes, so clients setting BinX only get square pixels.
+func (ch *CameraHandler) handleSetFrame(r *http.Request) (any, error) {
+	property := r.URL.Path[1:]
+	params := map[string]string{
+		"binx": "BinX", "biny": "BinY", "startx": "StartX", "starty": "StartY", "numx": "NumX", "numy": "NumY",
+	}
+	value, err := getIntParam(r, params[property])
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	caps := ch.dev.Capabilities()
+	frame := ch.dev.Frame()
+
+	switch property {
+	case "binx", "biny":
+		maxBin := caps.MaxBinX
+		if property == "biny" {
+			maxBin = caps.MaxBinY
+		}
+		if value < 1 || value > maxBin {
+			return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "binning %d out of range 1 to %d", value, maxBin)
+		}
+		switch {
+		case !caps.CanAsymmetricBin:
+			frame.BinX, frame.BinY = value, value
+		case property == "binx":
+			frame.BinX = value
+		default:
+			frame.BinY = value
+		}
+	case "startx", "starty", "numx", "numy":
+		if value < 0 || (value == 0 && (property == "numx" || property == "numy")) {
+			return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "%s %d out of range", params[property], value)
+		}
+		switch property {
+		case "startx":
+			frame.StartX = value
+		case "starty":
+			frame.StartY = value
+		case "numx":
+			frame.NumX = value
+		case "numy":
+			frame.NumY = value
+		}
+	}
+	return nil, ch.dev.SetFrame(frame)
+}
+
+func (ch *CameraHandler) handleStartExposure(r *http.Request) (any, error) {
+	duration, err := getFloatParam(r, "Duration")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	light, err := getBoolParam(r, "Light")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+
+	caps := ch.dev.Capabilities()
+	if duration < caps.ExposureMin || duration > caps.ExposureMax {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "duration %g out of range %g to %g seconds", duration, caps.ExposureMin, caps.ExposureMax)
+	}
+	frame := ch.dev.Frame()
+	width, height := caps.CameraXSize/max(frame.BinX, 1), caps.CameraYSize/max(frame.BinY, 1)
+	if frame.StartX+frame.NumX > width || frame.StartY+frame.NumY > height {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "subframe %dx%d at %d,%d outside the %dx%d binned sensor",
+			frame.NumX, frame.NumY, frame.StartX, frame.StartY, width, height)
+	}
+	return nil, ch.dev.StartExposure(duration, light)
+}
+
+func (ch *CameraHandler) handleAbortExposure(r *http.Request) (any, error) {
+	if !ch.dev.Capabilities().CanAbortExposure {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, ch.dev.AbortExposure()
+}
+
+func (ch *CameraHandler) handleStopExposure(r *http.Request) (any, error) {
+	if !ch.dev.Capabilities().CanStopExposure {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, ch.dev.StopExposure()
+}
+
+// handleImageArray returns the last image, sent in the ImageBytes format to
+// the clients accepting it.
+func (ch *CameraHandler) handleImageArray(r *http.Request) (any, error) {
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	if !ch.dev.Status().ImageReady {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidOperation, "no image ready")
+	}
+	img, err := ch.dev.Image()
+	if err != nil {
+		return nil, err
+	}
+	return img, nil
+}
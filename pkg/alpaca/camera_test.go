@@ -0,0 +1,121 @@
+package alpaca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCamera takes a 3x2 image of 16-bit pixels at once.
+type fakeCamera struct {
+	fakeConditions
+	frame  CameraFrame
+	status CameraStatus
+	image  *Image
+}
+
+func (d *fakeCamera) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Camera", Type: DeviceTypeCamera, Number: 0, UniqueID: "fake-camera"}
+}
+
+func (d *fakeCamera) Capabilities() CameraCapabilities {
+	return CameraCapabilities{
+		CameraXSize: 3, CameraYSize: 2, MaxBinX: 2, MaxBinY: 2, MaxADU: 65535,
+		ExposureMin: 0.001, ExposureMax: 3600, CanAbortExposure: true,
+	}
+}
+
+func (d *fakeCamera) Status() CameraStatus         { return d.status }
+func (d *fakeCamera) Frame() CameraFrame           { return d.frame }
+func (d *fakeCamera) SetFrame(f CameraFrame) error { d.frame = f; return nil }
+func (d *fakeCamera) AbortExposure() error         { d.status.State = CameraIdle; return nil }
+func (d *fakeCamera) StopExposure() error          { return nil }
+func (d *fakeCamera) Image() (*Image, error)       { return d.image, nil }
+
+func (d *fakeCamera) StartExposure(duration float64, light bool) error {
+	d.status = CameraStatus{ImageReady: true, LastExposureDuration: duration, LastExposureStart: time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC)}
+	d.image = &Image{Width: 3, Height: 2, Planes: 1, Pixels: []uint16{1, 2, 3, 4, 5, 6}}
+	return nil
+}
+
+func getImageBytes(t *testing.T, url string) (header [11]int32, data []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/imagebytes, application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/imagebytes", resp.Header.Get("Content-Type"))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, binary.Read(bytes.NewReader(body), binary.LittleEndian, &header))
+	return header, body[header[4]:]
+}
+
+func TestCameraHandler(t *testing.T) {
+	dev := &fakeCamera{frame: CameraFrame{BinX: 1, BinY: 1, NumX: 3, NumY: 2}}
+	dev.connected = true
+	ts := newTestServer(dev)
+	defer ts.Close()
+	api := ts.URL + "/api/v1/camera/0/"
+
+	assert.Equal(t, 3.0, getJSON(t, api+"cameraxsize?ClientTransactionID=1").Value)
+	assert.Equal(t, false, getJSON(t, api+"canpulseguide?ClientTransactionID=1").Value)
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, getJSON(t, api+"bayeroffsetx?ClientTransactionID=1").ErrorNumber, "monochrome")
+	assert.Equal(t, alpacaerrors.ErrValueNotSet.Number, getJSON(t, api+"lastexposureduration?ClientTransactionID=1").ErrorNumber)
+	assert.Equal(t, alpacaerrors.ErrInvalidOperation.Number, getJSON(t, api+"imagearray?ClientTransactionID=1").ErrorNumber, "no image yet")
+
+	resp := putForm(t, api+"binx", url.Values{"BinX": {"2"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 2.0, getJSON(t, api+"biny?ClientTransactionID=1").Value, "symmetric binning")
+	resp = putForm(t, api+"binx", url.Values{"BinX": {"3"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber)
+	resp = putForm(t, api+"startexposure", url.Values{"Duration": {"1"}, "Light": {"true"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber, "the 3x2 subframe does not fit the binned sensor")
+	resp = putForm(t, api+"binx", url.Values{"BinX": {"1"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+
+	resp = putForm(t, api+"startexposure", url.Values{"Duration": {"0"}, "Light": {"true"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber, "below ExposureMin")
+	resp = putForm(t, api+"startexposure", url.Values{"Duration": {"2.5"}, "Light": {"true"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, "2026-03-01T22:00:00.000", getJSON(t, api+"lastexposurestarttime?ClientTransactionID=1").Value)
+
+	// JSON, indexed by column then row.
+	httpResp, err := http.Get(api + "imagearray?ClientTransactionID=5")
+	require.NoError(t, err)
+	defer httpResp.Body.Close()
+	var image struct {
+		Type, Rank          int
+		ClientTransactionID int
+		Value               [][]int
+	}
+	require.NoError(t, json.NewDecoder(httpResp.Body).Decode(&image))
+	assert.Equal(t, int(ImageInt32), image.Type)
+	assert.Equal(t, 2, image.Rank)
+	assert.Equal(t, 5, image.ClientTransactionID)
+	assert.Equal(t, [][]int{{1, 4}, {2, 5}, {3, 6}}, image.Value)
+
+	header, data := getImageBytes(t, api+"imagearray?ClientTransactionID=6")
+	assert.Equal(t, [11]int32{1, 0, 6, header[3], 44, int32(ImageInt32), int32(ImageUInt16), 2, 3, 2, 0}, header)
+	assert.Equal(t, []byte{1, 0, 4, 0, 2, 0, 5, 0, 3, 0, 6, 0}, data)
+
+	dev.connected = false
+	header, data = getImageBytes(t, api+"imagearray?ClientTransactionID=7")
+	assert.Equal(t, int32(alpacaerrors.ErrNotConnected.Number), header[1])
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Error(), string(data), "the error message follows the header")
+}
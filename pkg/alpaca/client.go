@@ -0,0 +1,108 @@
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Client calls the API of a device served by another Alpaca server, such as
+// the telescope a dome is slaved to.
+type Client struct {
+	baseURL  string // Device URL, e.g. http://host:11111/api/v1/telescope/0
+	clientID uint32
+	apiKey   string // Key of the other server, none if it accepts any request
+	txID     atomic.Uint32
+	http     *http.Client
+}
+
+// NewClient creates a client for the device at baseURL.
+func NewClient(baseURL string, clientID uint32, timeout time.Duration) *Client {
+	return &Client{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		clientID: clientID,
+		http:     &http.Client{Timeout: timeout},
+	}
+}
+
+// SetAPIKey sets the key sent as a bearer token to a server requiring one.
+func (c *Client) SetAPIKey(key string) {
+	c.apiKey = key
+}
+
+// clientResponse is an Alpaca response with the value left undecoded.
+type clientResponse struct {
+	ErrorNumber  int             `json:"ErrorNumber"`
+	ErrorMessage string          `json:"ErrorMessage"`
+	Value        json.RawMessage `json:"Value"`
+}
+
+// Get reads a property, such as "azimuth", and decodes its value into value.
+// Alpaca errors are returned as Error.
+func (c *Client) Get(ctx context.Context, property string, value any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/"+property+"?"+c.params(nil).Encode(), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, property, value)
+}
+
+// Put calls a method, such as "connected", with the parameters. Alpaca
+// errors are returned as Error.
+func (c *Client) Put(ctx context.Context, method string, params url.Values) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.baseURL+"/"+method, strings.NewReader(c.params(params).Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req, method, nil)
+}
+
+// params adds the client and transaction IDs to the parameters.
+func (c *Client) params(params url.Values) url.Values {
+	all := url.Values{
+		"ClientID":            {fmt.Sprint(c.clientID)},
+		"ClientTransactionID": {fmt.Sprint(c.txID.Add(1))},
+	}
+	for k, v := range params {
+		all[k] = v
+	}
+	return all
+}
+
+// do sends the request and decodes the value of the response into value,
+// unless value is nil.
+func (c *Client) do(req *http.Request, name string, value any) error {
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", req.Method, name, resp.Status)
+	}
+
+	var body clientResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("invalid %s response: %v", name, err)
+	}
+	if body.ErrorNumber != 0 {
+		return alpacaerrors.New(body.ErrorNumber, body.ErrorMessage)
+	}
+	if value == nil {
+		return nil
+	}
+	return json.Unmarshal(body.Value, value)
+}
@@ -0,0 +1,49 @@
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientGet(t *testing.T) {
+	ts := newTestServer(&fakeDome{connected: true, status: DomeStatus{Azimuth: 42.5}})
+	defer ts.Close()
+
+	client := NewClient(ts.URL+"/api/v1/dome/0/", 7, time.Second)
+
+	var azimuth float64
+	require.NoError(t, client.Get(context.Background(), "azimuth", &azimuth))
+	assert.Equal(t, 42.5, azimuth)
+}
+
+func TestClientGetError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ErrorNumber":1031,"ErrorMessage":"not connected"}`))
+	}))
+	defer ts.Close()
+
+	var azimuth float64
+	err := NewClient(ts.URL, 7, time.Second).Get(context.Background(), "azimuth", &azimuth)
+
+	var alpacaErr alpacaerrors.Error
+	require.ErrorAs(t, err, &alpacaErr)
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, alpacaErr.Number)
+}
+
+func TestClientPut(t *testing.T) {
+	dome := &fakeDome{}
+	ts := newTestServer(dome)
+	defer ts.Close()
+
+	client := NewClient(ts.URL+"/api/v1/dome/0", 7, time.Second)
+	require.NoError(t, client.Put(context.Background(), "connected", url.Values{"Connected": {"true"}}))
+	assert.True(t, dome.connected)
+}
@@ -0,0 +1,233 @@
+// Documentation: https://ascom-standards.org/api/#/CoverCalibrator%20Specific%20Methods
+
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"fmt"
+	"net/http"
+)
+
+type CoverStatus int
+
+const (
+	CoverNotPresent CoverStatus = iota
+	CoverClosed
+	CoverMoving
+	CoverOpen
+	CoverUnknown
+	CoverError
+)
+
+func (s CoverStatus) String() string {
+	switch s {
+	case CoverNotPresent:
+		return "NotPresent"
+	case CoverClosed:
+		return "Closed"
+	case CoverMoving:
+		return "Moving"
+	case CoverOpen:
+		return "Open"
+	case CoverUnknown:
+		return "Unknown"
+	case CoverError:
+		return "Error"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
+type CalibratorStatus int
+
+const (
+	CalibratorNotPresent CalibratorStatus = iota
+	CalibratorOff
+	CalibratorNotReady
+	CalibratorReady
+	CalibratorUnknown
+	CalibratorError
+)
+
+func (s CalibratorStatus) String() string {
+	switch s {
+	case CalibratorNotPresent:
+		return "NotPresent"
+	case CalibratorOff:
+		return "Off"
+	case CalibratorNotReady:
+		return "NotReady"
+	case CalibratorReady:
+		return "Ready"
+	case CalibratorUnknown:
+		return "Unknown"
+	case CalibratorError:
+		return "Error"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
+type CoverCalibratorCapabilities struct {
+	Cover         bool // Has a motorized cover
+	CanHaltCover  bool
+	MaxBrightness int // Largest brightness of the flat panel, 0 without one
+}
+
+type CoverCalibratorStatus struct {
+	Cover      CoverStatus
+	Calibrator CalibratorStatus
+	Brightness int
+}
+
+func (cs CoverCalibratorStatus) ToProperties() []StateProperty {
+	return []StateProperty{
+		{"Brightness", cs.Brightness},
+		{"CalibratorChanging", cs.Calibrator == CalibratorNotReady},
+		{"CalibratorState", int(cs.Calibrator)},
+		{"CoverMoving", cs.Cover == CoverMoving},
+		{"CoverState", int(cs.Cover)},
+	}
+}
+
+// CoverCalibrator is a telescope cover, a flat panel, or both, such as a
+// flip-flat. The handler checks the capabilities and the brightness range;
+// the cover moves and the calibrator changes start and return at once, and
+// the states report their progress.
+type CoverCalibrator interface {
+	Device
+
+	Capabilities() CoverCalibratorCapabilities
+	Status() CoverCalibratorStatus
+
+	OpenCover() error
+	CloseCover() error
+	HaltCover() error
+	CalibratorOn(brightness int) error
+	CalibratorOff() error
+}
+
+type CoverCalibratorHandler struct {
+	DeviceHandler
+	dev CoverCalibrator
+}
+
+func NewCoverCalibratorHandler(dev CoverCalibrator, version int) *CoverCalibratorHandler {
+	return &CoverCalibratorHandler{
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
+		dev:           dev,
+	}
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeCover, func(dev Device, version int) DeviceHTTPHandler {
+		if c, ok := dev.(CoverCalibrator); ok {
+			return NewCoverCalibratorHandler(c, version)
+		}
+		return nil
+	})
+}
+
+func (ch *CoverCalibratorHandler) RegisterRoutes(mux *http.ServeMux) {
+	ch.DeviceHandler.RegisterRoutes(mux)
+
+	for _, property := range []string{"coverstate", "covermoving", "calibratorstate", "calibratorchanging", "brightness"} {
+		mux.Handle("GET /"+property, handleAPI(ch.handleStatus))
+	}
+	mux.Handle("GET /maxbrightness", ch.handleStatic(ch.handleMaxBrightness))
+
+	mux.Handle("PUT /opencover", handleAPI(ch.handleCover(ch.dev.OpenCover)))
+	mux.Handle("PUT /closecover", handleAPI(ch.handleCover(ch.dev.CloseCover)))
+	mux.Handle("PUT /haltcover", handleAPI(ch.handleHaltCover))
+	mux.Handle("PUT /calibratoron", handleAPI(ch.handleCalibratorOn))
+	mux.Handle("PUT /calibratoroff", handleAPI(ch.handleCalibratorOff))
+}
+
+func (ch *CoverCalibratorHandler) handleStatus(r *http.Request) (any, error) {
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	status := ch.dev.Status()
+
+	property := r.URL.Path[1:]
+	switch property {
+	case "coverstate":
+		return status.Cover, nil
+	case "covermoving":
+		return status.Cover == CoverMoving, nil
+	case "calibratorstate":
+		return status.Calibrator, nil
+	case "calibratorchanging":
+		return status.Calibrator == CalibratorNotReady, nil
+	case "brightness":
+		if status.Calibrator == CalibratorNotPresent {
+			return nil, alpacaerrors.ErrNotImplemented
+		}
+		return status.Brightness, nil
+	default:
+		return nil, errBadRequest
+	}
+}
+
+func (ch *CoverCalibratorHandler) handleMaxBrightness(r *http.Request) (any, error) {
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	max := ch.dev.Capabilities().MaxBrightness
+	if max <= 0 {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	return max, nil
+}
+
+// handleCover returns the handler of a cover command, not implemented
+// without a cover.
+func (ch *CoverCalibratorHandler) handleCover(command func() error) func(r *http.Request) (any, error) {
+	return func(r *http.Request) (any, error) {
+		if !ch.dev.Capabilities().Cover {
+			return nil, alpacaerrors.ErrNotImplemented
+		}
+		if !ch.dev.Connected() {
+			return nil, alpacaerrors.ErrNotConnected
+		}
+		return nil, command()
+	}
+}
+
+func (ch *CoverCalibratorHandler) handleHaltCover(r *http.Request) (any, error) {
+	if caps := ch.dev.Capabilities(); !caps.Cover || !caps.CanHaltCover {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, ch.dev.HaltCover()
+}
+
+func (ch *CoverCalibratorHandler) handleCalibratorOn(r *http.Request) (any, error) {
+	brightness, err := getIntParam(r, "Brightness")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	max := ch.dev.Capabilities().MaxBrightness
+	if max <= 0 {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	if brightness < 0 || brightness > max {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "brightness %d out of range 0 to %d", brightness, max)
+	}
+	return nil, ch.dev.CalibratorOn(brightness)
+}
+
+func (ch *CoverCalibratorHandler) handleCalibratorOff(r *http.Request) (any, error) {
+	if ch.dev.Capabilities().MaxBrightness <= 0 {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if !ch.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, ch.dev.CalibratorOff()
+}
@@ -0,0 +1,85 @@
+package alpaca
+
+import (
+	"net/url"
+	"testing"
+
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCoverCalibrator is a flip-flat whose cover moves at once, or a cover
+// without a flat panel.
+type fakeCoverCalibrator struct {
+	fakeConditions
+	noPanel bool
+	status  CoverCalibratorStatus
+}
+
+func (d *fakeCoverCalibrator) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Flip-Flat", Type: DeviceTypeCover, Number: 0, UniqueID: "fake-cover"}
+}
+
+func (d *fakeCoverCalibrator) Capabilities() CoverCalibratorCapabilities {
+	caps := CoverCalibratorCapabilities{Cover: true}
+	if !d.noPanel {
+		caps.MaxBrightness = 255
+	}
+	return caps
+}
+
+func (d *fakeCoverCalibrator) Status() CoverCalibratorStatus { return d.status }
+func (d *fakeCoverCalibrator) OpenCover() error              { d.status.Cover = CoverOpen; return nil }
+func (d *fakeCoverCalibrator) CloseCover() error             { d.status.Cover = CoverClosed; return nil }
+func (d *fakeCoverCalibrator) HaltCover() error              { return nil }
+
+func (d *fakeCoverCalibrator) CalibratorOn(brightness int) error {
+	d.status.Calibrator, d.status.Brightness = CalibratorReady, brightness
+	return nil
+}
+
+func (d *fakeCoverCalibrator) CalibratorOff() error {
+	d.status.Calibrator, d.status.Brightness = CalibratorOff, 0
+	return nil
+}
+
+func TestCoverCalibratorHandler(t *testing.T) {
+	dev := &fakeCoverCalibrator{status: CoverCalibratorStatus{Cover: CoverClosed, Calibrator: CalibratorOff}}
+	dev.connected = true
+	ts := newTestServer(dev)
+	defer ts.Close()
+	api := ts.URL + "/api/v1/covercalibrator/0/"
+
+	assert.Equal(t, float64(CoverClosed), getJSON(t, api+"coverstate?ClientTransactionID=1").Value)
+	assert.Equal(t, 255.0, getJSON(t, api+"maxbrightness?ClientTransactionID=1").Value)
+
+	resp := putForm(t, api+"opencover", url.Values{"ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, float64(CoverOpen), getJSON(t, api+"coverstate?ClientTransactionID=1").Value)
+	assert.Equal(t, false, getJSON(t, api+"covermoving?ClientTransactionID=1").Value)
+	resp = putForm(t, api+"haltcover", url.Values{"ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, resp.ErrorNumber, "the cover cannot be halted")
+
+	resp = putForm(t, api+"calibratoron", url.Values{"Brightness": {"128"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 128.0, getJSON(t, api+"brightness?ClientTransactionID=1").Value)
+	assert.Equal(t, float64(CalibratorReady), getJSON(t, api+"calibratorstate?ClientTransactionID=1").Value)
+	resp = putForm(t, api+"calibratoron", url.Values{"Brightness": {"256"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber)
+	resp = putForm(t, api+"calibratoroff", url.Values{"ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 0.0, getJSON(t, api+"brightness?ClientTransactionID=1").Value)
+
+	dev.noPanel = true
+	dev.status.Calibrator = CalibratorNotPresent
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, getJSON(t, api+"brightness?ClientTransactionID=1").ErrorNumber)
+	resp = putForm(t, api+"calibratoron", url.Values{"Brightness": {"1"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, resp.ErrorNumber)
+
+	dev.connected = false
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, getJSON(t, api+"coverstate?ClientTransactionID=1").ErrorNumber)
+	resp = putForm(t, api+"closecover", url.Values{"ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, resp.ErrorNumber)
+}
@@ -1,7 +1,15 @@
 package alpaca
 
 import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"context"
+	"crypto/sha1"
+	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type DeviceType string
@@ -12,16 +20,38 @@ const (
 	DeviceTypeDome        DeviceType = "Dome"
 	DeviceTypeFilterWheel DeviceType = "FilterWheel"
 	DeviceTypeFocuser     DeviceType = "Focuser"
+	DeviceTypeConditions  DeviceType = "ObservingConditions"
 	DeviceTypeRotator     DeviceType = "Rotator"
 	DeviceTypeSafety      DeviceType = "SafetyMonitor"
 	DeviceTypeSwitch      DeviceType = "Switch"
 	DeviceTypeTelescope   DeviceType = "Telescope"
 )
 
+// DeviceTypes lists the Alpaca device types.
+var DeviceTypes = []DeviceType{
+	DeviceTypeCamera, DeviceTypeCover, DeviceTypeDome, DeviceTypeFilterWheel, DeviceTypeFocuser,
+	DeviceTypeConditions, DeviceTypeRotator, DeviceTypeSafety, DeviceTypeSwitch, DeviceTypeTelescope,
+}
+
 func (dt DeviceType) String() string {
 	return string(dt)
 }
 
+// ExpandDescription replaces the {name} placeholders of a configured device
+// description with their values, e.g. "ZRO Dome fw {firmware} @ {host}".
+// {host} is always available; unknown placeholders are left as they are.
+func ExpandDescription(format string, values map[string]string) string {
+	oldnew := make([]string, 0, 2*(len(values)+1))
+	if _, ok := values["host"]; !ok {
+		host, _ := os.Hostname()
+		oldnew = append(oldnew, "{host}", host)
+	}
+	for name, value := range values {
+		oldnew = append(oldnew, "{"+name+"}", value)
+	}
+	return strings.NewReplacer(oldnew...).Replace(format)
+}
+
 type DeviceInfo struct {
 	Name        string     `json:"DeviceName"`
 	Description string     `json:"-"`
@@ -56,33 +86,132 @@ type Device interface {
 	HandleSetup(http.ResponseWriter, *http.Request)
 }
 
+// InstanceUID derives the UniqueID of a driver instance from the UniqueID of
+// the driver and the key of the instance settings, as a name based UUID, so
+// it is stable across restarts. The default instance, with an empty key,
+// keeps the driver UniqueID.
+func InstanceUID(driverUID, key string) string {
+	if key == "" {
+		return driverUID
+	}
+
+	sum := sha1.Sum([]byte(driverUID + "/" + key))
+	sum[6] = sum[6]&0x0f | 0x50 // Version 5
+	sum[8] = sum[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// Shutdowner is implemented by devices that release resources, such as a
+// broker connection, when the server stops. Shutdown must return by the
+// deadline of the context.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Restarter is implemented by devices whose driver can be torn down and
+// initialized again without restarting the server, such as after a change of
+// the broker settings or to clear a fault. A device that was connected
+// connects again.
+type Restarter interface {
+	Restart() error
+}
+
+// Proxy is implemented by devices served by another Alpaca server. Their API
+// and setup requests are forwarded to that server instead of being handled
+// here, so this server can act as the single address of several servers.
+type Proxy interface {
+	// ProxyAPI forwards a device API request, whose path is relative to the
+	// device, e.g. /azimuth.
+	ProxyAPI() http.Handler
+	// ProxySetup forwards a setup page request, whose path is relative to
+	// the device, e.g. /setup.
+	ProxySetup() http.Handler
+}
+
+// Disabler is implemented by devices that can be disabled from their setup
+// page. A disabled device keeps its configuration and setup page, but is
+// hidden from the configured devices and its API is not served.
+type Disabler interface {
+	Disabled() bool
+}
+
+// deviceEnabled reports whether a device is served.
+func deviceEnabled(dev Device) bool {
+	d, ok := dev.(Disabler)
+	return !ok || !d.Disabled()
+}
+
+// deviceKey identifies a device as "<type>/<number>", as in the timeline.
+func deviceKey(info DeviceInfo) string {
+	return strings.ToLower(info.Type.String()) + "/" + strconv.Itoa(info.Number)
+}
+
+// ActionProvider is implemented by devices that support custom actions.
+// Action names are matched in any case; Action is only called with one of
+// the names returned by SupportedActions.
+type ActionProvider interface {
+	SupportedActions() []string
+	Action(name, parameters string) (string, error)
+}
+
+// Commander is implemented by devices that pass commands through to their
+// controller, for the CommandBlind, CommandBool and CommandString methods.
+// With raw, the command is sent exactly as given, framing included;
+// otherwise the device adds the framing of its protocol. Command returns the
+// response of the controller.
+type Commander interface {
+	Command(command string, raw bool) (string, error)
+}
+
+// ContextBinder is implemented by devices that trace the commands they send
+// to their controller. WithContext returns the device serving the request of
+// ctx, whose commands are children of the span of the request.
+type ContextBinder interface {
+	WithContext(ctx context.Context) Device
+}
+
+// bindDevice returns the device serving a request, bound to its context if
+// the device is a ContextBinder.
+func bindDevice(dev Device, r *http.Request) Device {
+	if b, ok := dev.(ContextBinder); ok {
+		return b.WithContext(r.Context())
+	}
+	return dev
+}
+
 type DeviceHandler struct {
-	dev Device
+	dev        Device
+	version    int      // Alpaca API version served by this handler
+	history    *History // Connection history, nil if not recorded
+	state      stateCache
+	validators validatorCache // Validators of the static properties
+}
+
+// NewDeviceHandler creates the handler of the methods common to every
+// device. The handlers of the device types embed it.
+func NewDeviceHandler(dev Device, version int) *DeviceHandler {
+	return &DeviceHandler{dev: dev, version: version}
 }
 
 func (h *DeviceHandler) RegisterRoutes(mux *http.ServeMux) {
 	// mux.HandleFunc("GET /setup", h.handleSetup)
-	mux.Handle("GET /name", handleAPI(func(r *http.Request) (any, error) {
+	mux.Handle("GET /name", h.handleStatic(func(r *http.Request) (any, error) {
 		return h.dev.DeviceInfo().Name, nil
 	}))
-	mux.Handle("GET /description", handleAPI(func(r *http.Request) (any, error) {
+	mux.Handle("GET /description", h.handleStatic(func(r *http.Request) (any, error) {
 		return h.dev.DeviceInfo().Description, nil
 	}))
-	mux.Handle("GET /driverinfo", handleAPI(func(r *http.Request) (any, error) {
+	mux.Handle("GET /driverinfo", h.handleStatic(func(r *http.Request) (any, error) {
 		return h.dev.DriverInfo().Name, nil
 	}))
-	mux.Handle("GET /driverversion", handleAPI(func(r *http.Request) (any, error) {
+	mux.Handle("GET /driverversion", h.handleStatic(func(r *http.Request) (any, error) {
 		return h.dev.DriverInfo().Version, nil
 	}))
-	mux.Handle("GET /interfaceversion", handleAPI(func(r *http.Request) (any, error) {
+	mux.Handle("GET /interfaceversion", h.handleStatic(func(r *http.Request) (any, error) {
 		return h.dev.DriverInfo().InterfaceVersion, nil
 	}))
-	mux.Handle("GET /devicestate", handleAPI(func(r *http.Request) (any, error) {
-		return h.dev.GetState(), nil
-	}))
-	mux.Handle("GET /supportedactions", handleAPI(func(r *http.Request) (any, error) {
-		return []string{}, nil
-	}))
+	mux.Handle("GET /devicestate", handleAPI(h.handleDeviceState))
+	mux.Handle("GET /supportedactions", h.handleStatic(h.handleSupportedActions))
 	mux.Handle("GET /connecting", handleAPI(func(r *http.Request) (any, error) {
 		return h.dev.Connecting(), nil
 	}))
@@ -90,11 +219,124 @@ func (h *DeviceHandler) RegisterRoutes(mux *http.ServeMux) {
 		return h.dev.Connected(), nil
 	}))
 
+	mux.Handle("PUT /action", handleAPI(h.handleAction))
+	mux.Handle("PUT /commandblind", handleAPI(h.handleCommandBlind))
+	mux.Handle("PUT /commandbool", handleAPI(h.handleCommandBool))
+	mux.Handle("PUT /commandstring", handleAPI(h.handleCommandString))
 	mux.Handle("PUT /connected", handleAPI(h.putConnected))
 	mux.Handle("PUT /connect", handleAPI(h.handleConnect))
 	mux.Handle("PUT /disconnect", handleAPI(h.handleDisconnect))
+}
+
+// handleDeviceState returns the device state. The optional, non standard,
+// Properties parameter limits it to a comma separated list of property
+// names, matched in any case, so fast pollers only get what they use. The
+// TimeStamp is always returned. The state is cached for the state cache TTL.
+func (h *DeviceHandler) handleDeviceState(r *http.Request) (any, error) {
+	state := h.state.get(h.dev.GetState, time.Now())
+
+	value, err := getParam(r, "Properties", true)
+	if err != nil {
+		return state, nil
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			wanted[strings.ToLower(name)] = true
+		}
+	}
+	if len(wanted) == 0 {
+		return state, nil
+	}
+
+	filtered := make([]StateProperty, 0, len(wanted)+1)
+	for _, prop := range state {
+		if prop.Name == "TimeStamp" || wanted[strings.ToLower(prop.Name)] {
+			filtered = append(filtered, prop)
+		}
+	}
+	return filtered, nil
+}
+
+func (h *DeviceHandler) handleSupportedActions(r *http.Request) (any, error) {
+	provider, ok := h.dev.(ActionProvider)
+	if !ok {
+		return []string{}, nil
+	}
+
+	actions := provider.SupportedActions()
+	if actions == nil {
+		actions = []string{}
+	}
+	return actions, nil
+}
+
+func (h *DeviceHandler) handleAction(r *http.Request) (any, error) {
+	name, err := getParam(r, "Action", false)
+	if err != nil {
+		return nil, err
+	}
+	parameters, _ := getParam(r, "Parameters", false)
+
+	provider, ok := bindDevice(h.dev, r).(ActionProvider)
+	if !ok {
+		return nil, alpacaerrors.ErrActionNotImplemented
+	}
+
+	for _, action := range provider.SupportedActions() {
+		if strings.EqualFold(action, name) {
+			return provider.Action(action, parameters)
+		}
+	}
+	return nil, alpacaerrors.ErrActionNotImplemented
+}
+
+// command passes the Command parameter through to the device. Raw is
+// optional and defaults to false.
+func (h *DeviceHandler) command(r *http.Request) (string, error) {
+	command, err := getParam(r, "Command", false)
+	if err != nil {
+		return "", err
+	}
+	raw := false
+	if value, err := getParam(r, "Raw", false); err == nil {
+		if raw, err = strconv.ParseBool(value); err != nil {
+			return "", fmt.Errorf("%w: invalid Raw %q", errBadRequest, value)
+		}
+	}
+
+	commander, ok := bindDevice(h.dev, r).(Commander)
+	if !ok {
+		return "", alpacaerrors.ErrNotImplemented
+	}
+	return commander.Command(command, raw)
+}
+
+func (h *DeviceHandler) handleCommandBlind(r *http.Request) (any, error) {
+	_, err := h.command(r)
+	return nil, err
+}
+
+// handleCommandBool returns the response of the controller as a boolean. A
+// command acknowledged without a value is true.
+func (h *DeviceHandler) handleCommandBool(r *http.Request) (any, error) {
+	response, err := h.command(r)
+	if err != nil {
+		return nil, err
+	}
+	if response == "" {
+		return true, nil
+	}
+	value, err := strconv.ParseBool(response)
+	if err != nil {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "response %q is not a boolean", response)
+	}
+	return value, nil
+}
 
-	mux.HandleFunc("/setup", h.dev.HandleSetup)
+func (h *DeviceHandler) handleCommandString(r *http.Request) (any, error) {
+	return h.command(r)
 }
 
 func (h *DeviceHandler) putConnected(r *http.Request) (any, error) {
@@ -103,6 +345,7 @@ func (h *DeviceHandler) putConnected(r *http.Request) (any, error) {
 		return nil, errBadRequest
 	}
 
+	h.history.request(h.dev.DeviceInfo().Name, connected, r)
 	if connected {
 		return connected, h.dev.Connect()
 	}
@@ -110,6 +353,7 @@ func (h *DeviceHandler) putConnected(r *http.Request) (any, error) {
 }
 
 func (h *DeviceHandler) handleConnect(r *http.Request) (any, error) {
+	h.history.request(h.dev.DeviceInfo().Name, true, r)
 	if err := h.dev.Connect(); err != nil {
 		return nil, err
 	}
@@ -117,6 +361,7 @@ func (h *DeviceHandler) handleConnect(r *http.Request) (any, error) {
 }
 
 func (h *DeviceHandler) handleDisconnect(r *http.Request) (any, error) {
+	h.history.request(h.dev.DeviceInfo().Name, false, r)
 	if err := h.dev.Disconnect(); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,264 @@
+package alpaca
+
+import (
+	"alpaca/pkg/timeline"
+	"alpaca/pkg/version"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxLogLines is the number of recent log lines kept for the diagnostics
+// bundle.
+const maxLogLines = 2000
+
+// diagnosticsUploadTimeout bounds the upload of a diagnostics bundle.
+const diagnosticsUploadTimeout = 30 * time.Second
+
+// logRing is a logrus hook keeping the last log lines in memory.
+type logRing struct {
+	mu        sync.Mutex
+	lines     []string
+	next      int // Index of the oldest line once the ring is full
+	formatter log.Formatter
+}
+
+func (l *logRing) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (l *logRing) Fire(e *log.Entry) error {
+	line, err := l.formatter.Format(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.lines) < maxLogLines {
+		l.lines = append(l.lines, string(line))
+		return nil
+	}
+	l.lines[l.next] = string(line)
+	l.next = (l.next + 1) % maxLogLines
+	return nil
+}
+
+// Lines returns the kept lines, oldest first.
+func (l *logRing) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Concat(l.lines[l.next:], l.lines[:l.next])
+}
+
+var recentLogs = &logRing{formatter: &log.TextFormatter{DisableColors: true, FullTimestamp: true}}
+
+// CaptureLogs keeps the recent lines of a logger for the diagnostics bundle.
+func CaptureLogs(logger *log.Logger) {
+	logger.AddHook(recentLogs)
+}
+
+// settingsStore is implemented by the stores that can list every stored
+// setting, those of the drivers included.
+type settingsStore interface {
+	Settings() (map[string]json.RawMessage, error)
+}
+
+// serverDiagnostics describes the server in the diagnostics bundle.
+type serverDiagnostics struct {
+	Description ServerDescription
+	Version     string
+	GoVersion   string
+	Platform    string
+	Started     time.Time
+	Generated   time.Time
+}
+
+// deviceDiagnostics describes a device in the diagnostics bundle. The
+// description of a connected device holds its firmware version.
+type deviceDiagnostics struct {
+	Device      DeviceInfo
+	Description string
+	Driver      DriverInfo
+	Enabled     bool
+	Connected   bool
+	State       []StateProperty `json:",omitempty"`
+}
+
+// WriteDiagnostics writes the diagnostics bundle of the server, a zip
+// archive for support: the server and devices, the settings without their
+// secrets, the connection history, the timeline and the recent logs.
+func (s *Server) WriteDiagnostics(w io.Writer, now time.Time) error {
+	zw := zip.NewWriter(w)
+	create := func(name string) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+	}
+	add := func(name string, value any) error {
+		f, err := create(name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(value)
+	}
+
+	err := add("server.json", serverDiagnostics{
+		Description: s.description,
+		Version:     version.Get().String(),
+		GoVersion:   runtime.Version(),
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+		Started:     s.started,
+		Generated:   now,
+	})
+	if err != nil {
+		return err
+	}
+
+	devices := make([]deviceDiagnostics, 0, len(s.devices))
+	for _, dev := range s.devices {
+		info := dev.DeviceInfo()
+		d := deviceDiagnostics{
+			Device:      info,
+			Description: info.Description,
+			Driver:      dev.DriverInfo(),
+			Enabled:     deviceEnabled(dev),
+			Connected:   dev.Connected(),
+		}
+		if d.Connected {
+			d.State = dev.GetState()
+		}
+		devices = append(devices, d)
+	}
+	if err := add("devices.json", devices); err != nil {
+		return err
+	}
+
+	// The secrets of the settings are redacted, and scrubbed from the logs,
+	// where errors may quote them, e.g. in the URL of a failed webhook.
+	settings, err := s.settings()
+	var redactedSettings any = map[string]any{"error": fmt.Sprint(err)}
+	if err == nil {
+		redactedSettings = redactJSON(settings)
+	}
+	if err := add("settings.json", redactedSettings); err != nil {
+		return err
+	}
+
+	if s.history != nil {
+		records, err := s.history.Records("", 0, maxHistoryLimit)
+		if err != nil {
+			return err
+		}
+		if err := add("connecthistory.json", records); err != nil {
+			return err
+		}
+	}
+	if err := add("timeline.json", timeline.Default().Page(0, maxTimelineLimit)); err != nil {
+		return err
+	}
+
+	f, err := create("logs.txt")
+	if err != nil {
+		return err
+	}
+	logs := strings.Join(recentLogs.Lines(), "")
+	if _, err := io.WriteString(f, scrubSecrets(logs, secretValues(settings))); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// settings returns the stored settings, by database key, decoded from JSON.
+func (s *Server) settings() (map[string]any, error) {
+	if s.db == nil {
+		return map[string]any{}, nil
+	}
+	store, ok := s.db.(settingsStore)
+	if !ok {
+		cfg, err := s.db.GetConfig()
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(cfg)
+		if err != nil {
+			return nil, err
+		}
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return map[string]any{configKey: v}, nil
+	}
+
+	raw, err := store.Settings()
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]any, len(raw))
+	for key, value := range raw {
+		var v any
+		if err := json.Unmarshal(value, &v); err != nil {
+			return nil, fmt.Errorf("setting %s: %v", key, err)
+		}
+		settings[key] = v
+	}
+	return settings, nil
+}
+
+// handleDiagnostics downloads the diagnostics bundle.
+func (s *Server) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	var buf bytes.Buffer
+	if err := s.WriteDiagnostics(&buf, now); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	requestLogger(r).Info("Diagnostics bundle downloaded")
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="zro-alpaca-diagnostics-%s.zip"`, now.Format("20060102-150405")))
+	w.Write(buf.Bytes())
+}
+
+// UploadDiagnostics sends the diagnostics bundle to a support URL, in the
+// body of a POST request.
+func (s *Server) UploadDiagnostics(ctx context.Context, url string) error {
+	if url == "" {
+		return fmt.Errorf("no diagnostics upload URL is configured")
+	}
+	var buf bytes.Buffer
+	if err := s.WriteDiagnostics(&buf, time.Now()); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, diagnosticsUploadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/zip")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload the diagnostics bundle: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to upload the diagnostics bundle: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package alpaca
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+// readBundle returns the files of a diagnostics bundle by name.
+func readBundle(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "alpaca.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	store, err := NewStore(db)
+	require.NoError(t, err)
+
+	cfg := DefaultConfig()
+	cfg.Notifications.SMTPPassword = "smtp-secret"
+	cfg.Notifications.WebhookURL = "https://hooks.example.com/services/T0/B0/webhook-secret"
+	require.NoError(t, store.SetConfig(cfg))
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(bucket)).Put([]byte("zro_config"), []byte(`{"Broker": "tcp://dome:1883", "Password": "mqtt-secret"}`))
+	}))
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	CaptureLogs(logger)
+	logger.Info("Dome parked for diagnostics")
+	logger.Errorf(`Failed to send error event to webhook: Post "%s": EOF`, cfg.Notifications.WebhookURL)
+	logger.Warn("MQTT login failed with password mqtt-secret")
+
+	history, err := NewHistory(db)
+	require.NoError(t, err)
+	server := NewServer(ServerDescription{Name: "Test"}, []Device{&fakeDome{connected: true}}, store, nil)
+	server.SetHistory(history)
+
+	var buf bytes.Buffer
+	require.NoError(t, server.WriteDiagnostics(&buf, time.Now()))
+	files := readBundle(t, buf.Bytes())
+	assert.Contains(t, files, "server.json")
+	assert.Contains(t, files, "connecthistory.json")
+	assert.Contains(t, files, "timeline.json")
+	assert.Contains(t, files["logs.txt"], "Dome parked for diagnostics")
+	assert.Contains(t, files["logs.txt"], "Failed to send error event to webhook")
+	assert.NotContains(t, files["logs.txt"], "webhook-secret", "the secrets are scrubbed from the logs")
+	assert.NotContains(t, files["logs.txt"], "mqtt-secret")
+
+	var devices []deviceDiagnostics
+	require.NoError(t, json.Unmarshal([]byte(files["devices.json"]), &devices))
+	require.Len(t, devices, 1)
+	assert.Equal(t, "Fake Dome", devices[0].Device.Name)
+	assert.True(t, devices[0].Connected)
+
+	assert.Contains(t, files["settings.json"], "tcp://dome:1883")
+	assert.NotContains(t, files["settings.json"], "mqtt-secret")
+	assert.NotContains(t, files["settings.json"], "smtp-secret")
+	assert.NotContains(t, files["settings.json"], "webhook-secret")
+}
+
+func TestLogRing(t *testing.T) {
+	ring := &logRing{formatter: &log.TextFormatter{DisableColors: true}}
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(ring)
+
+	for i := 0; i < maxLogLines+10; i++ {
+		logger.Infof("line %d", i)
+	}
+	lines := ring.Lines()
+	require.Len(t, lines, maxLogLines)
+	assert.Contains(t, lines[0], "line 10", "oldest kept line first")
+	assert.Contains(t, lines[maxLogLines-1], "line 2009")
+}
+
+func TestUploadDiagnostics(t *testing.T) {
+	var received []byte
+	support := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/expired" {
+			http.Error(w, "expired", http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "application/zip", r.Header.Get("Content-Type"))
+		received, _ = io.ReadAll(r.Body)
+	}))
+	defer support.Close()
+
+	server := NewServer(ServerDescription{Name: "Test"}, nil, nil, nil)
+	require.NoError(t, server.UploadDiagnostics(context.Background(), support.URL))
+	assert.Contains(t, readBundle(t, received), "devices.json")
+
+	assert.Error(t, server.UploadDiagnostics(context.Background(), ""), "no URL")
+	assert.Error(t, server.UploadDiagnostics(context.Background(), support.URL+"/expired"), "rejected")
+}
+
+func TestDiagnosticsUploadURL(t *testing.T) {
+	form := url.Values{"diagnostics-upload-url": {"http://support.example.com/upload"}}
+	r := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err := parseSetupForm(r)
+	assert.Error(t, err, "the bundle is only sent over HTTPS")
+}
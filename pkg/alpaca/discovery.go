@@ -13,16 +13,18 @@ import (
 // DiscoveryResponder responds to Alpaca discovery requests.
 type DiscoveryResponder struct {
 	addr           string
+	port           int // Alpaca port of the server
 	alpacaResponse string
 	logger         log.FieldLogger
 }
 
 // NewDiscoveryResponder creates and starts a new discovery responder.
 func NewDiscoveryResponder(addr string, port int, logger log.FieldLogger) (*DiscoveryResponder, error) {
-	alpacaResponse := fmt.Sprintf(`{"AlpacaPort": %d}`, port)
+	alpacaResponse := discoveryResponse(port, false)
 
 	dr := DiscoveryResponder{
 		addr:           addr,
+		port:           port,
 		alpacaResponse: alpacaResponse,
 		logger:         logger,
 	}
@@ -30,6 +32,22 @@ func NewDiscoveryResponder(addr string, port int, logger log.FieldLogger) (*Disc
 	return &dr, nil
 }
 
+// discoveryResponse returns the response to the discovery requests. A server
+// served over HTTPS adds AlpacaScheme, which is not part of the Alpaca
+// specification: the clients ignore it, and the peer servers read it.
+func discoveryResponse(port int, https bool) string {
+	if https {
+		return fmt.Sprintf(`{"AlpacaPort": %d, "AlpacaScheme": "https"}`, port)
+	}
+	return fmt.Sprintf(`{"AlpacaPort": %d}`, port)
+}
+
+// SetHTTPS advertises that the server is served over HTTPS. It must be
+// called before Run.
+func (d *DiscoveryResponder) SetHTTPS() {
+	d.alpacaResponse = discoveryResponse(d.port, true)
+}
+
 func (d *DiscoveryResponder) Run(ctx context.Context) error {
 	buf := make([]byte, 1024)
 
@@ -1,7 +1,12 @@
 package alpaca
 
 import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"fmt"
+	"math"
 	"net/http"
+	"sync/atomic"
+	"time"
 )
 
 type DomeCapabilities struct {
@@ -25,6 +30,23 @@ const (
 	ShutterError
 )
 
+func (s ShutterStatus) String() string {
+	switch s {
+	case ShutterOpen:
+		return "Open"
+	case ShutterClosed:
+		return "Closed"
+	case ShutterOpening:
+		return "Opening"
+	case ShutterClosing:
+		return "Closing"
+	case ShutterError:
+		return "Error"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
 type DomeStatus struct {
 	AtHome   bool          `json:"AtHome"`
 	AtPark   bool          `json:"AtPark"`
@@ -73,18 +95,58 @@ type Dome interface {
 	SetShutter(ShutterCommand) error
 }
 
+// SlewEstimator is implemented by the domes that can tell how long a slew
+// takes, for the countdowns of the user interfaces.
+type SlewEstimator interface {
+	EstimateSlew(azimuth float64) time.Duration // Of a slew from the current azimuth
+	SlewTimeRemaining() time.Duration           // Of the slew in progress, zero if none
+}
+
+// slewEstimateInResponse makes PUT slewtoazimuth return the estimated
+// duration of the slew as its Value instead of true.
+var slewEstimateInResponse atomic.Bool
+
+// SetSlewEstimateInResponse enables or disables the estimated slew duration
+// in the slewtoazimuth responses. Strict clients expect no Value there.
+func SetSlewEstimateInResponse(enabled bool) {
+	slewEstimateInResponse.Store(enabled)
+}
+
+// SlewSeconds returns a slew duration as the seconds reported to the
+// clients, to a tenth of a second.
+func SlewSeconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*10) / 10
+}
+
 type DomeHandler struct {
 	DeviceHandler
 	dev Dome
 }
 
-func NewDomeHandler(dev Dome) *DomeHandler {
+func NewDomeHandler(dev Dome, version int) *DomeHandler {
 	return &DomeHandler{
-		DeviceHandler: DeviceHandler{dev: dev},
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
 		dev:           dev,
 	}
 }
 
+// device returns the dome serving a request, bound to its context.
+func (dh *DomeHandler) device(r *http.Request) Dome {
+	if d, ok := bindDevice(dh.dev, r).(Dome); ok {
+		return d
+	}
+	return dh.dev
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeDome, func(dev Device, version int) DeviceHTTPHandler {
+		if d, ok := dev.(Dome); ok {
+			return NewDomeHandler(d, version)
+		}
+		return nil
+	})
+}
+
 func (dh *DomeHandler) RegisterRoutes(mux *http.ServeMux) {
 	dh.DeviceHandler.RegisterRoutes(mux)
 
@@ -95,14 +157,14 @@ func (dh *DomeHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("GET /shutterstatus", handleAPI(dh.handleStatus))
 	mux.Handle("GET /slewing", handleAPI(dh.handleStatus))
 
-	mux.Handle("GET /canfindhome", handleAPI(dh.handleCapabilities))
-	mux.Handle("GET /canpark", handleAPI(dh.handleCapabilities))
-	mux.Handle("GET /cansetaltitude", handleAPI(dh.handleCapabilities))
-	mux.Handle("GET /cansetazimuth", handleAPI(dh.handleCapabilities))
-	mux.Handle("GET /cansetpark", handleAPI(dh.handleCapabilities))
-	mux.Handle("GET /cansetshutter", handleAPI(dh.handleCapabilities))
-	mux.Handle("GET /canslave", handleAPI(dh.handleCapabilities))
-	mux.Handle("GET /cansyncazimuth", handleAPI(dh.handleCapabilities))
+	mux.Handle("GET /canfindhome", dh.handleStatic(dh.handleCapabilities))
+	mux.Handle("GET /canpark", dh.handleStatic(dh.handleCapabilities))
+	mux.Handle("GET /cansetaltitude", dh.handleStatic(dh.handleCapabilities))
+	mux.Handle("GET /cansetazimuth", dh.handleStatic(dh.handleCapabilities))
+	mux.Handle("GET /cansetpark", dh.handleStatic(dh.handleCapabilities))
+	mux.Handle("GET /cansetshutter", dh.handleStatic(dh.handleCapabilities))
+	mux.Handle("GET /canslave", dh.handleStatic(dh.handleCapabilities))
+	mux.Handle("GET /cansyncazimuth", dh.handleStatic(dh.handleCapabilities))
 
 	mux.Handle("GET /slaved", handleAPI(func(r *http.Request) (any, error) {
 		return dh.dev.Status().Slaved, nil
@@ -176,7 +238,12 @@ func (dh *DomeHandler) handleSlaved(r *http.Request) (any, error) {
 		return nil, errBadRequest
 	}
 
-	if err := dh.dev.SetSlaved(slaved); err != nil {
+	// Slaved may always be cleared, but only set on domes that can slave.
+	if slaved && !dh.dev.Capabilities().CanSlave {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+
+	if err := dh.device(r).SetSlaved(slaved); err != nil {
 		return nil, err
 	}
 	return slaved, nil
@@ -188,7 +255,7 @@ func (dh *DomeHandler) handleSlewToAltitude(r *http.Request) (any, error) {
 		return nil, errBadRequest
 	}
 
-	if err := dh.dev.SlewToAltitude(altitude); err != nil {
+	if err := dh.device(r).SlewToAltitude(altitude); err != nil {
 		return nil, err
 	}
 	return true, nil
@@ -200,10 +267,20 @@ func (dh *DomeHandler) handleSlewToAzimuth(r *http.Request) (any, error) {
 		return nil, errBadRequest
 	}
 	if azimuth < 0 || azimuth > 360 {
-		return false, ErrInvalidValue
+		return false, alpacaerrors.ErrInvalidValue
 	}
 
-	return true, dh.dev.SlewToAzimuth(azimuth)
+	// The estimate is taken from the azimuth before the slew starts.
+	dev := dh.device(r)
+	est, ok := dev.(SlewEstimator)
+	if !ok || !slewEstimateInResponse.Load() {
+		return true, dev.SlewToAzimuth(azimuth)
+	}
+	estimate := est.EstimateSlew(azimuth)
+	if err := dev.SlewToAzimuth(azimuth); err != nil {
+		return nil, err
+	}
+	return SlewSeconds(estimate), nil
 }
 
 func (dh *DomeHandler) handleSyncToAzimuth(r *http.Request) (any, error) {
@@ -212,32 +289,32 @@ func (dh *DomeHandler) handleSyncToAzimuth(r *http.Request) (any, error) {
 		return nil, errBadRequest
 	}
 	if azimuth < 0 || azimuth > 360 {
-		return false, ErrInvalidValue
+		return false, alpacaerrors.ErrInvalidValue
 	}
 
-	return true, dh.dev.SyncToAzimuth(azimuth)
+	return true, dh.device(r).SyncToAzimuth(azimuth)
 }
 
 func (dh *DomeHandler) handleAbortSlew(r *http.Request) (any, error) {
-	return true, dh.dev.AbortSlew()
+	return true, dh.device(r).AbortSlew()
 }
 
 func (dh *DomeHandler) handleFindHome(r *http.Request) (any, error) {
-	return true, dh.dev.FindHome()
+	return true, dh.device(r).FindHome()
 }
 
 func (dh *DomeHandler) handlePark(r *http.Request) (any, error) {
-	return true, dh.dev.Park()
+	return true, dh.device(r).Park()
 }
 
 func (dh *DomeHandler) handleSetPark(r *http.Request) (any, error) {
-	return true, dh.dev.SetPark()
+	return true, dh.device(r).SetPark()
 }
 
 func (dh *DomeHandler) handleOpenShutter(r *http.Request) (any, error) {
-	return true, dh.dev.SetShutter(ShutterCommandOpen)
+	return true, dh.device(r).SetShutter(ShutterCommandOpen)
 }
 
 func (dh *DomeHandler) handleCloseShutter(r *http.Request) (any, error) {
-	return true, dh.dev.SetShutter(ShutterCommandClose)
+	return true, dh.device(r).SetShutter(ShutterCommandClose)
 }
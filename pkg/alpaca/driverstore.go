@@ -0,0 +1,90 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DriverStore loads and saves the configuration of a driver instance, as a
+// json string under its key in the settings bucket.
+type DriverStore[T any] struct {
+	db  *bolt.DB
+	key string // database key of the configuration
+}
+
+// NewDriverStore creates a store for the configuration saved under key, and
+// saves defaults there if it holds none yet.
+func NewDriverStore[T any](db *bolt.DB, key string, defaults T) (*DriverStore[T], error) {
+	st := DriverStore[T]{db: db, key: key}
+
+	if _, err := st.GetConfig(); err != nil {
+		log.Infof("Setting default config %s", key)
+		if err := st.SetConfig(defaults); err != nil {
+			return nil, fmt.Errorf("failed to save the default config %s: %v", key, err)
+		}
+	}
+	return &st, nil
+}
+
+// SetConfig saves the configuration in the database.
+func (s *DriverStore[T]) SetConfig(cfg T) error {
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(s.key), value)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := BackupDB(s.db); err != nil {
+		log.Warnf("Failed to back up database: %v", err)
+	}
+	return nil
+}
+
+// GetConfig retrieves the configuration from the database.
+func (s *DriverStore[T]) GetConfig() (T, error) {
+	var cfg T
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", bucket)
+		}
+
+		value := b.Get([]byte(s.key))
+		if value == nil {
+			return fmt.Errorf("key %s not found", s.key)
+		}
+
+		return json.Unmarshal(value, &cfg)
+	})
+
+	return cfg, err
+}
+
+// ApplySettings merges the settings of a device file into the configuration
+// and saves it if they changed it.
+func (s *DriverStore[T]) ApplySettings(settings json.RawMessage) error {
+	cfg, err := s.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	changed, err := MergeSettings(&cfg, settings)
+	if err != nil || !changed {
+		return err
+	}
+	return s.SetConfig(cfg)
+}
@@ -0,0 +1,53 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+type testDriverConfig struct {
+	Speed    float64 `json:"speed"`
+	Disabled bool    `json:"disabled"`
+}
+
+func TestDriverStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alpaca.db")
+	db, err := bolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+
+	defaults := testDriverConfig{Speed: 5, Disabled: true}
+	st, err := NewDriverStore(db, "test_config", defaults)
+	require.NoError(t, err)
+	cfg, err := st.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, defaults, cfg)
+
+	require.NoError(t, st.ApplySettings(json.RawMessage(`{"disabled": false}`)))
+	assert.Error(t, st.ApplySettings(json.RawMessage(`{"sped": 2}`)), "unknown setting")
+
+	// The saved configuration is kept by the next store.
+	st, err = NewDriverStore(db, "test_config", defaults)
+	require.NoError(t, err)
+	cfg, err = st.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, testDriverConfig{Speed: 5}, cfg)
+
+	other, err := NewDriverStore(db, "other_config", defaults)
+	require.NoError(t, err)
+	cfg, err = other.GetConfig()
+	require.NoError(t, err)
+	assert.True(t, cfg.Disabled, "each key has its own configuration")
+	require.NoError(t, db.Close())
+
+	// The defaults that cannot be saved fail the store.
+	db, err = bolt.Open(path, 0o600, &bolt.Options{ReadOnly: true})
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = NewDriverStore(db, "missing_config", defaults)
+	assert.Error(t, err)
+}
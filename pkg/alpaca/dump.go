@@ -0,0 +1,154 @@
+package alpaca
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dumpDir is the directory where API exchanges are dumped. Dumping is
+// disabled while it is empty.
+var dumpDir atomic.Pointer[string]
+
+// dumpMu serializes the writes to the dump files.
+var dumpMu sync.Mutex
+
+// SetDumpDir enables the debug dump of every API request and response into
+// per-day files in dir. An empty dir disables the dump.
+func SetDumpDir(dir string) {
+	dumpDir.Store(&dir)
+}
+
+// dumpFileName returns the name of the dump file for the day of t.
+func dumpFileName(t time.Time) string {
+	return fmt.Sprintf("alpaca-dump-%s.jsonl", t.Format("2006-01-02"))
+}
+
+// dumpEntry is a request/response pair written as one JSON line.
+type dumpEntry struct {
+	Time                time.Time    `json:"time"`
+	ServerTransactionID int          `json:"server_transaction_id"`
+	ClientTransactionID int          `json:"client_transaction_id"`
+	Remote              string       `json:"remote"`
+	Request             dumpRequest  `json:"request"`
+	Response            dumpResponse `json:"response"`
+}
+
+type dumpRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type dumpResponse struct {
+	Status int             `json:"status"`
+	Header http.Header     `json:"header"`
+	JSON   json.RawMessage `json:"json,omitempty"`
+	Body   string          `json:"body,omitempty"`
+}
+
+// dumpRecorder is a ResponseWriter that keeps a copy of the response.
+type dumpRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (d *dumpRecorder) WriteHeader(status int) {
+	d.status = status
+	d.ResponseWriter.WriteHeader(status)
+}
+
+func (d *dumpRecorder) Write(b []byte) (int, error) {
+	if d.status == 0 {
+		d.status = http.StatusOK
+	}
+	d.body.Write(b)
+	return d.ResponseWriter.Write(b)
+}
+
+// dumpExchanges records the exchanges served by next when the debug dump is
+// enabled. Entries are keyed by the ServerTransactionID of the response, or 0
+// for requests rejected before one was assigned.
+func dumpExchanges(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dir := dumpDir.Load()
+		if dir == nil || *dir == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var reqBody []byte
+		if r.Body != nil {
+			// Read one byte more than the limit so handleAPI still rejects
+			// oversized bodies.
+			reqBody, _ = io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+			r.Body = io.NopCloser(bytes.NewReader(reqBody))
+		}
+
+		rec := &dumpRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		entry := dumpEntry{
+			Time:   time.Now(),
+			Remote: r.RemoteAddr,
+			Request: dumpRequest{
+				Method: r.Method,
+				URL:    r.URL.String(),
+				Header: redactHeader(r.Header),
+				Body:   string(reqBody),
+			},
+			Response: dumpResponse{
+				Status: rec.status,
+				Header: w.Header(),
+			},
+		}
+
+		var resp baseResponse
+		if json.Unmarshal(rec.body.Bytes(), &resp) == nil {
+			entry.ServerTransactionID = resp.ServerTransactionID
+			entry.ClientTransactionID = resp.ClientTransactionID
+			entry.Response.JSON = bytes.TrimSpace(rec.body.Bytes())
+		} else {
+			entry.Response.Body = rec.body.String()
+		}
+
+		if err := writeDumpEntry(*dir, entry); err != nil {
+			log.Errorf("Failed to write debug dump: %v", err)
+		}
+	})
+}
+
+// writeDumpEntry appends an entry to the dump file of its day.
+func writeDumpEntry(dir string, entry dumpEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	dumpMu.Lock()
+	defer dumpMu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, dumpFileName(entry.Time)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,53 @@
+package alpaca
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpExchanges(t *testing.T) {
+	dir := t.TempDir()
+	SetDumpDir(dir)
+	defer SetDumpDir("")
+
+	handler := handleAPI(func(r *http.Request) (any, error) {
+		return true, nil
+	})
+
+	w := httptest.NewRecorder()
+	req := newPutRequest("/api/v1/dome/0/openshutter", "ClientID=1&ClientTransactionID=42")
+	req.Header.Set("Authorization", "Bearer secret-key")
+	handler.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp baseResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	f, err := os.Open(filepath.Join(dir, dumpFileName(time.Now())))
+	require.NoError(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+
+	var entry dumpEntry
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+	assert.Equal(t, resp.ServerTransactionID, entry.ServerTransactionID)
+	assert.Equal(t, 42, entry.ClientTransactionID)
+	assert.Equal(t, http.MethodPut, entry.Request.Method)
+	assert.Equal(t, "ClientID=1&ClientTransactionID=42", entry.Request.Body)
+	assert.Equal(t, "application/x-www-form-urlencoded", entry.Request.Header.Get("Content-Type"))
+	assert.Equal(t, redacted, entry.Request.Header.Get("Authorization"))
+	assert.Equal(t, http.StatusOK, entry.Response.Status)
+	assert.JSONEq(t, w.Body.String(), string(entry.Response.JSON))
+	assert.False(t, scanner.Scan())
+}
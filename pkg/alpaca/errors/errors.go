@@ -0,0 +1,108 @@
+// Package errors defines the errors the devices report to the Alpaca
+// clients. Each error carries its Alpaca error number, so a driver returns
+// why a request failed, with a sentinel or an error wrapping one, and the
+// server answers with the number the specification defines for it.
+//
+// Reference: https://ascom-standards.org/AlpacaDeveloper/ASCOMAlpacaAPIReference.html
+package errors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error is an error with an Alpaca error number.
+type Error struct {
+	Number  int
+	Message string
+}
+
+func (e Error) Error() string {
+	return e.Message
+}
+
+// Is matches the errors by number, so an error created with Errorf matches
+// the sentinel of its kind.
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.Number == e.Number
+}
+
+// New creates an error with an Alpaca error number.
+func New(number int, message string) Error {
+	return Error{Number: number, Message: message}
+}
+
+// Errorf creates an error of the kind of a sentinel, such as ErrInvalidValue,
+// with a specific message.
+func Errorf(kind Error, format string, args ...any) Error {
+	return Error{Number: kind.Number, Message: fmt.Sprintf(format, args...)}
+}
+
+// Sentinel errors of the devices, with the numbers of the Alpaca
+// specification. Client software such as NINA shows a specific message for
+// each of them.
+var (
+	ErrNotImplemented       = Error{Number: 0x400, Message: "property not implemented"}
+	ErrInvalidValue         = Error{Number: 0x401, Message: "invalid value"}
+	ErrValueNotSet          = Error{Number: 0x402, Message: "not set"}
+	ErrNotConnected         = Error{Number: 0x407, Message: "not connected"}
+	ErrParked               = Error{Number: 0x408, Message: "invalid while parked"}
+	ErrSlaved               = Error{Number: 0x409, Message: "invalid while slaved"}
+	ErrInvalidOperation     = Error{Number: 0x40B, Message: "invalid operation"}
+	ErrActionNotImplemented = Error{Number: 0x40C, Message: "action not implemented"}
+	ErrUnspecified          = Error{Number: 0x4FF, Message: "unspecified error"}
+)
+
+// wrapped is an error of a driver given the number of an Alpaca error.
+type wrapped struct {
+	kind Error
+	err  error
+}
+
+func (w wrapped) Error() string {
+	return w.err.Error()
+}
+
+func (w wrapped) Unwrap() []error {
+	return []error{w.kind, w.err}
+}
+
+// Wrap gives err, such as an error of a device controller, the number of
+// the kind of a sentinel. The error keeps its message and still matches err
+// with Is. Wrap returns nil if err is nil.
+func Wrap(kind Error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return wrapped{kind: kind, err: err}
+}
+
+// From returns the Alpaca error of err: the Error it is or wraps, with the
+// message of the whole chain, such as "slew to 90.0 degrees: invalid while
+// parked". It returns false if err carries no Alpaca error.
+func From(err error) (Error, bool) {
+	var e Error
+	if err == nil || !errors.As(err, &e) {
+		return Error{}, false
+	}
+	return Error{Number: e.Number, Message: err.Error()}, true
+}
+
+// Number returns the Alpaca error number of err: 0 for nil, and the number
+// of ErrUnspecified for an error that carries none.
+func Number(err error) int {
+	if err == nil {
+		return 0
+	}
+	if e, ok := From(err); ok {
+		return e.Number
+	}
+	return ErrUnspecified.Number
+}
+
+// Is reports whether any error in the chain of err matches target, as the
+// standard errors.Is, for the callers importing this package as errors.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
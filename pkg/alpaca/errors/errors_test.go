@@ -0,0 +1,43 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorf(t *testing.T) {
+	err := Errorf(ErrInvalidValue, "invalid azimuth %.1f", 400.0)
+	assert.Equal(t, "invalid azimuth 400.0", err.Error())
+	assert.True(t, Is(err, ErrInvalidValue), "matches the sentinel of its kind")
+	assert.False(t, Is(err, ErrNotConnected))
+}
+
+func TestFrom(t *testing.T) {
+	err := fmt.Errorf("slew to 90.0 degrees: %w", ErrParked)
+	e, ok := From(err)
+	assert.True(t, ok)
+	assert.Equal(t, Error{Number: ErrParked.Number, Message: "slew to 90.0 degrees: invalid while parked"}, e)
+
+	_, ok = From(fmt.Errorf("broker unreachable"))
+	assert.False(t, ok)
+	_, ok = From(nil)
+	assert.False(t, ok)
+}
+
+func TestNumber(t *testing.T) {
+	assert.Zero(t, Number(nil))
+	assert.Equal(t, ErrSlaved.Number, Number(fmt.Errorf("park: %w", ErrSlaved)))
+	assert.Equal(t, ErrUnspecified.Number, Number(fmt.Errorf("broker unreachable")))
+}
+
+func TestWrap(t *testing.T) {
+	rejected := fmt.Errorf("command rejected by the controller")
+	err := Wrap(ErrInvalidOperation, fmt.Errorf("close shutter: %w", rejected))
+	assert.Equal(t, "close shutter: command rejected by the controller", err.Error())
+	assert.True(t, Is(err, ErrInvalidOperation))
+	assert.True(t, Is(err, rejected), "still matches the wrapped error")
+	assert.Equal(t, ErrInvalidOperation.Number, Number(err))
+	assert.Nil(t, Wrap(ErrInvalidOperation, nil))
+}
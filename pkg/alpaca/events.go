@@ -0,0 +1,181 @@
+package alpaca
+
+import (
+	"alpaca/pkg/notify"
+	"alpaca/pkg/timeline"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EventType is the kind of a device lifecycle event.
+type EventType string
+
+const (
+	EventConnected    EventType = "connected"     // The device connected to its controller
+	EventDisconnected EventType = "disconnected"  // The device disconnected, on request or not
+	EventError        EventType = "error"         // The device reported a failure or an alarm
+	EventStateChanged EventType = "state_changed" // The device state changed noticeably
+)
+
+// Event is a lifecycle event published by a device on the event bus.
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	Device  string // Name of the device
+	Message string // Human readable description
+
+	// Notification is the notification raised by the event, if any. Drivers
+	// set it for the conditions an operator must be told about, such as a
+	// lost connection or a low battery.
+	Notification notify.EventType
+
+	State any // New device state of an EventStateChanged, e.g. a DomeStatus
+}
+
+// subscriberQueueSize is the number of events queued for a subscriber before
+// new events are dropped.
+const subscriberQueueSize = 64
+
+type subscriber struct {
+	name    string
+	events  chan Event
+	dropped atomic.Int64
+}
+
+// Bus delivers the device lifecycle events to the subscribers, so drivers
+// publish what happened without knowing which features react to it, such as
+// the notifications or the timeline.
+//
+// Each subscriber receives the events in order from its own goroutine, so a
+// slow subscriber never blocks the drivers nor the other subscribers.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[*subscriber]struct{}
+	logger log.FieldLogger
+}
+
+func NewBus(logger log.FieldLogger) *Bus {
+	return &Bus{
+		subs:   make(map[*subscriber]struct{}),
+		logger: logger,
+	}
+}
+
+// Subscribe calls fn for each event published until the returned function
+// is called. The name identifies the subscriber in the logs.
+func (b *Bus) Subscribe(name string, fn func(Event)) (unsubscribe func()) {
+	sub := &subscriber{name: name, events: make(chan Event, subscriberQueueSize)}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range sub.events {
+			fn(e)
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			close(sub.events)
+			b.mu.Unlock()
+			<-done
+		})
+	}
+}
+
+// Publish sends the event to the subscribers, setting its time if unset.
+// Events are dropped for subscribers whose queue is full.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		select {
+		case sub.events <- e:
+		default:
+			if n := sub.dropped.Add(1); n == 1 || n%100 == 0 {
+				b.logger.Warnf("Event subscriber %s is too slow, %d events dropped", sub.name, n)
+			}
+		}
+	}
+}
+
+// defaultBus is the bus used by the package-level functions.
+var defaultBus = NewBus(log.WithField("component", "events"))
+
+// Events returns the event bus the drivers publish to.
+func Events() *Bus {
+	return defaultBus
+}
+
+// Publish sends an event through the default bus.
+func Publish(e Event) {
+	defaultBus.Publish(e)
+}
+
+// SubscribeEvents subscribes the server to the event bus: events raising a
+// notification are sent to the notifiers, the connections and state changes
+// are added to the timeline, the last event of each device is kept for the
+// widgets, and the connections are added to the history if it is set. It
+// returns the function that stops the subscription.
+func (s *Server) SubscribeEvents(bus *Bus) (unsubscribe func()) {
+	unsubscribes := []func(){
+		bus.Subscribe("server", handleEvent),
+		bus.Subscribe("widget", s.lastEvents.handleEvent),
+	}
+	if s.history != nil {
+		unsubscribes = append(unsubscribes, bus.Subscribe("history", s.history.handleEvent))
+	}
+
+	return func() {
+		for _, unsubscribe := range unsubscribes {
+			unsubscribe()
+		}
+	}
+}
+
+// handleEvent forwards a lifecycle event to the notifications and the
+// timeline.
+func handleEvent(e Event) {
+	notification := e.Notification
+	if notification == "" && e.Type == EventConnected {
+		notification = notify.EventConnected
+	}
+
+	if notification != "" {
+		// Notify records the event in the timeline as well.
+		notify.Notify(notify.Event{
+			Type:    notification,
+			Time:    e.Time,
+			Device:  e.Device,
+			Message: e.Message,
+		})
+		return
+	}
+
+	kind := timeline.KindEvent
+	if e.Type == EventStateChanged {
+		kind = timeline.KindTelemetry
+	}
+	timeline.Record(timeline.Entry{
+		Time:    e.Time,
+		Kind:    kind,
+		Device:  e.Device,
+		Summary: e.Message,
+		Data:    e.State,
+	})
+}
@@ -0,0 +1,66 @@
+package alpaca
+
+import (
+	"alpaca/pkg/timeline"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus(log.StandardLogger())
+
+	var got []EventType
+	unsubscribe := bus.Subscribe("test", func(e Event) {
+		assert.False(t, e.Time.IsZero())
+		got = append(got, e.Type)
+	})
+
+	bus.Publish(Event{Type: EventConnected})
+	bus.Publish(Event{Type: EventStateChanged})
+	unsubscribe()
+	bus.Publish(Event{Type: EventDisconnected})
+	unsubscribe()
+
+	assert.Equal(t, []EventType{EventConnected, EventStateChanged}, got, "delivered in order until unsubscribed")
+}
+
+func TestBusSlowSubscriber(t *testing.T) {
+	bus := NewBus(log.StandardLogger())
+
+	release := make(chan struct{})
+	seen := make(chan struct{})
+	var slow int
+	unsubscribeSlow := bus.Subscribe("slow", func(Event) { <-release; slow++ })
+	unsubscribeFast := bus.Subscribe("fast", func(Event) { seen <- struct{}{} })
+
+	// Each event reaches the fast subscriber while the slow one is stuck.
+	n := subscriberQueueSize + 10
+	for range n {
+		bus.Publish(Event{Type: EventStateChanged})
+		<-seen
+	}
+	unsubscribeFast()
+	close(release)
+	unsubscribeSlow()
+
+	assert.Less(t, slow, n, "the events beyond the queue of the slow subscriber are dropped")
+}
+
+func TestServerEvents(t *testing.T) {
+	bus := NewBus(log.StandardLogger())
+	server := NewServer(ServerDescription{Name: "Test"}, nil, nil, nil)
+	unsubscribe := server.SubscribeEvents(bus)
+
+	bus.Publish(Event{Type: EventStateChanged, Device: "Bus Dome", Message: "Azimuth 10.0", State: DomeStatus{Azimuth: 10}})
+	unsubscribe()
+
+	entries := timeline.Default().Page(0, 1)
+	require.Len(t, entries, 1)
+	assert.Equal(t, timeline.KindTelemetry, entries[0].Kind)
+	assert.Equal(t, "Bus Dome", entries[0].Device)
+	assert.Equal(t, "Azimuth 10.0", entries[0].Summary)
+	assert.Equal(t, DomeStatus{Azimuth: 10}, entries[0].Data)
+}
@@ -0,0 +1,223 @@
+package alpaca
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// discoveryPort is the UDP port of the Alpaca discovery protocol.
+	discoveryPort = 32227
+
+	// peerTimeout bounds the discovery and the status requests to the peers,
+	// so a peer that is down does not hold the dashboard.
+	peerTimeout = 2 * time.Second
+)
+
+// PeerStatus is the status of a peer server, as returned by its status.json.
+type PeerStatus struct {
+	URL        string         `json:"URL"` // Base URL of the peer, e.g. http://rolloff.local:8090
+	Discovered bool           `json:"Discovered"`
+	Server     string         `json:"Server,omitempty"`
+	Location   string         `json:"Location,omitempty"`
+	Devices    []deviceStatus `json:"Devices"`
+	Error      string         `json:"Error,omitempty"` // Why the status could not be read
+}
+
+// Federation reads the status of peer zro-alpaca servers, configured or
+// discovered on the local network, to show the devices of a whole site, such
+// as separate dome and roll-off buildings, on one dashboard.
+type Federation struct {
+	port   int // Alpaca port of this server, to skip it when discovering
+	client *http.Client
+	logger log.FieldLogger
+}
+
+// NewFederation creates a federation for the server listening on port.
+func NewFederation(port int, logger log.FieldLogger) *Federation {
+	return &Federation{
+		port:   port,
+		client: &http.Client{Timeout: peerTimeout},
+		logger: logger,
+	}
+}
+
+// ValidatePeers checks the base URLs of the configured peers.
+func ValidatePeers(peers []string) error {
+	for _, p := range peers {
+		u, err := url.Parse(p)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid peer URL %q", p)
+		}
+	}
+	return nil
+}
+
+// Peers returns the status of the configured peers and, if enabled, of the
+// peers discovered on the network. The peers are queried in parallel; those
+// that do not answer are returned with an error.
+func (f *Federation) Peers(ctx context.Context, cfg Config) []PeerStatus {
+	peers := make([]PeerStatus, 0, len(cfg.Peers))
+	seen := make(map[string]bool)
+	for _, p := range cfg.Peers {
+		p = strings.TrimSuffix(p, "/")
+		if !seen[p] {
+			seen[p] = true
+			peers = append(peers, PeerStatus{URL: p})
+		}
+	}
+
+	if cfg.DiscoverPeers {
+		discovered, err := f.discover(ctx)
+		if err != nil {
+			f.logger.Warnf("Failed to discover the peer servers: %v", err)
+		}
+		for _, p := range discovered {
+			if !seen[p] {
+				seen[p] = true
+				peers = append(peers, PeerStatus{URL: p, Discovered: true})
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f.fetch(ctx, &peers[i]); err != nil {
+				peers[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return peers
+}
+
+// fetch reads the status.json of a peer.
+func (f *Federation) fetch(ctx context.Context, peer *PeerStatus) error {
+	peer.Devices = []deviceStatus{}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer.URL+"/status.json", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status.json: %s", resp.Status)
+	}
+
+	var status publicStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("invalid status.json: %v", err)
+	}
+	peer.Server, peer.Location, peer.Devices = status.Server, status.Location, status.Devices
+	return nil
+}
+
+// discover broadcasts an Alpaca discovery request and returns the base URL of
+// the servers that answer before the timeout, except this one.
+func (f *Federation) discover(ctx context.Context) ([]string, error) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("cannot bind discovery socket: %v", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(peerTimeout / 2)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, err := conn.WriteToUDP([]byte("alpacadiscovery1"), &net.UDPAddr{IP: net.IPv4bcast, Port: discoveryPort}); err != nil {
+		return nil, fmt.Errorf("cannot send discovery request: %v", err)
+	}
+
+	var urls []string
+	buf := make([]byte, 1024)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// The read deadline ends the discovery.
+			return urls, nil
+		}
+
+		var resp struct {
+			AlpacaPort   int    `json:"AlpacaPort"`
+			AlpacaScheme string `json:"AlpacaScheme"` // Set by the servers served over HTTPS
+		}
+		if err := json.Unmarshal(buf[:n], &resp); err != nil || resp.AlpacaPort == 0 {
+			f.logger.Debugf("Ignoring discovery response %q from %s", buf[:n], addr)
+			continue
+		}
+		if resp.AlpacaPort == f.port && isLocalIP(addr.IP) {
+			continue
+		}
+		scheme := "http"
+		if resp.AlpacaScheme == "https" {
+			scheme = "https"
+		}
+		urls = append(urls, scheme+"://"+net.JoinHostPort(addr.IP.String(), fmt.Sprint(resp.AlpacaPort)))
+	}
+}
+
+// isLocalIP reports whether ip is an address of this host.
+func isLocalIP(ip net.IP) bool {
+	if ip.IsLoopback() {
+		return true
+	}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFederation shows the devices of the peer servers on the setup page and
+// in the peers endpoint. It must be called before AddRoutes.
+func (s *Server) SetFederation(f *Federation) {
+	s.federation = f
+}
+
+// peers returns the status of the peer servers, nil without a federation.
+func (s *Server) peers(ctx context.Context, cfg Config) []PeerStatus {
+	if s.federation == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+	defer cancel()
+	return s.federation.Peers(ctx, cfg)
+}
+
+// handlePeers returns the status of the peer servers. This is an extension
+// to the Alpaca management API.
+func (s *Server) handlePeers(r *http.Request) (any, error) {
+	if s.federation == nil {
+		return nil, fmt.Errorf("the peer servers are not enabled")
+	}
+	cfg, err := s.db.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return s.peers(r.Context(), cfg), nil
+}
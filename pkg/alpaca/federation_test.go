@@ -0,0 +1,40 @@
+package alpaca
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFederationPeers(t *testing.T) {
+	dome := &fakeDome{connected: true, status: DomeStatus{Azimuth: 180, Shutter: ShutterClosed}}
+	peer := NewServer(ServerDescription{Name: "Roll-off", Location: "North"}, []Device{dome}, nil, nil)
+	ts := httptest.NewServer(peer.AddRoutes())
+	defer ts.Close()
+
+	f := NewFederation(8090, log.StandardLogger())
+	peers := f.Peers(context.Background(), Config{Peers: []string{ts.URL + "/", ts.URL, "http://127.0.0.1:1"}})
+	require.Len(t, peers, 2, "duplicates are dropped")
+
+	assert.Equal(t, ts.URL, peers[0].URL)
+	assert.Equal(t, "Roll-off", peers[0].Server)
+	assert.Equal(t, "North", peers[0].Location)
+	assert.Empty(t, peers[0].Error)
+	require.Len(t, peers[0].Devices, 1)
+	assert.Equal(t, "Fake Dome", peers[0].Devices[0].Name)
+	assert.Equal(t, "Closed", peers[0].Devices[0].Dome.Shutter)
+
+	assert.Equal(t, "http://127.0.0.1:1", peers[1].URL)
+	assert.NotEmpty(t, peers[1].Error)
+	assert.Empty(t, peers[1].Devices)
+}
+
+func TestValidatePeers(t *testing.T) {
+	assert.NoError(t, ValidatePeers([]string{"http://rolloff.local:8090", "https://10.0.0.2"}))
+	assert.Error(t, ValidatePeers([]string{"rolloff.local:8090"}))
+	assert.Error(t, ValidatePeers([]string{"ftp://rolloff.local"}))
+}
@@ -0,0 +1,87 @@
+// Documentation: https://ascom-standards.org/api/#/FilterWheel%20Specific%20Methods
+
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"net/http"
+)
+
+// FilterWheel selects one of the filters of a wheel. The filters are
+// numbered from 0 in the order of Names, and FocusOffsets has an offset for
+// each one; the handler checks the positions against them. SetPosition
+// starts the move and returns at once, and Position is -1 until the filter
+// is in place.
+type FilterWheel interface {
+	Device
+
+	Names() []string
+	FocusOffsets() []int
+	Position() int
+	SetPosition(int) error
+}
+
+type FilterWheelHandler struct {
+	DeviceHandler
+	dev FilterWheel
+}
+
+func NewFilterWheelHandler(dev FilterWheel, version int) *FilterWheelHandler {
+	return &FilterWheelHandler{
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
+		dev:           dev,
+	}
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeFilterWheel, func(dev Device, version int) DeviceHTTPHandler {
+		if f, ok := dev.(FilterWheel); ok {
+			return NewFilterWheelHandler(f, version)
+		}
+		return nil
+	})
+}
+
+func (fh *FilterWheelHandler) RegisterRoutes(mux *http.ServeMux) {
+	fh.DeviceHandler.RegisterRoutes(mux)
+
+	mux.Handle("GET /names", fh.handleStatic(fh.handleNames))
+	mux.Handle("GET /focusoffsets", fh.handleStatic(fh.handleFocusOffsets))
+	mux.Handle("GET /position", handleAPI(fh.handlePosition))
+	mux.Handle("PUT /position", handleAPI(fh.handleSetPosition))
+}
+
+func (fh *FilterWheelHandler) handleNames(r *http.Request) (any, error) {
+	if !fh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return fh.dev.Names(), nil
+}
+
+func (fh *FilterWheelHandler) handleFocusOffsets(r *http.Request) (any, error) {
+	if !fh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return fh.dev.FocusOffsets(), nil
+}
+
+func (fh *FilterWheelHandler) handlePosition(r *http.Request) (any, error) {
+	if !fh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return fh.dev.Position(), nil
+}
+
+func (fh *FilterWheelHandler) handleSetPosition(r *http.Request) (any, error) {
+	position, err := getIntParam(r, "Position")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if !fh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	if n := len(fh.dev.Names()); position < 0 || position >= n {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "position %d out of range 0 to %d", position, n-1)
+	}
+	return nil, fh.dev.SetPosition(position)
+}
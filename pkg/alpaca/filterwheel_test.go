@@ -0,0 +1,50 @@
+package alpaca
+
+import (
+	"net/url"
+	"testing"
+
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeFilterWheel struct {
+	fakeConditions
+	position int
+}
+
+func (d *fakeFilterWheel) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Filter Wheel", Type: DeviceTypeFilterWheel, Number: 0, UniqueID: "fake-filterwheel"}
+}
+
+func (d *fakeFilterWheel) Names() []string           { return []string{"L", "R", "G", "B"} }
+func (d *fakeFilterWheel) FocusOffsets() []int       { return []int{0, 12, 15, 20} }
+func (d *fakeFilterWheel) Position() int             { return d.position }
+func (d *fakeFilterWheel) SetPosition(pos int) error { d.position = pos; return nil }
+
+func TestFilterWheelHandler(t *testing.T) {
+	dev := &fakeFilterWheel{}
+	dev.connected = true
+	ts := newTestServer(dev)
+	defer ts.Close()
+	api := ts.URL + "/api/v1/filterwheel/0/"
+
+	assert.Equal(t, []any{"L", "R", "G", "B"}, getJSON(t, api+"names?ClientTransactionID=1").Value)
+	assert.Equal(t, []any{0.0, 12.0, 15.0, 20.0}, getJSON(t, api+"focusoffsets?ClientTransactionID=1").Value)
+
+	resp := putForm(t, api+"position", url.Values{"Position": {"2"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 2.0, getJSON(t, api+"position?ClientTransactionID=1").Value)
+
+	resp = putForm(t, api+"position", url.Values{"Position": {"4"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber)
+	resp = putForm(t, api+"position", url.Values{"Position": {"-1"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber)
+
+	dev.connected = false
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, getJSON(t, api+"names?ClientTransactionID=1").ErrorNumber)
+	resp = putForm(t, api+"position", url.Values{"Position": {"1"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, resp.ErrorNumber)
+}
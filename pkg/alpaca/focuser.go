@@ -0,0 +1,182 @@
+// Documentation: https://ascom-standards.org/api/#/Focuser%20Specific%20Methods
+
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"net/http"
+)
+
+type FocuserCapabilities struct {
+	Absolute          bool    // Moves to a step position rather than by a number of steps
+	MaxIncrement      int     // Largest move, in steps
+	MaxStep           int     // Largest position, in steps
+	StepSize          float64 // Microns, 0 if unknown
+	TempCompAvailable bool
+}
+
+type FocuserStatus struct {
+	Position    int // Steps, only meaningful for an absolute focuser
+	IsMoving    bool
+	TempComp    bool
+	Temperature *float64 // Celsius, nil without a sensor
+}
+
+func (fs FocuserStatus) ToProperties() []StateProperty {
+	props := []StateProperty{
+		{"IsMoving", fs.IsMoving},
+		{"Position", fs.Position},
+	}
+	if fs.Temperature != nil {
+		props = append(props, StateProperty{"Temperature", *fs.Temperature})
+	}
+	return props
+}
+
+// Focuser moves the focus of a telescope. The handler checks the
+// capabilities and the ranges of the moves; Move starts a move to a position
+// for an absolute focuser, or by a number of steps otherwise, and returns at
+// once.
+type Focuser interface {
+	Device
+
+	Capabilities() FocuserCapabilities
+	Status() FocuserStatus
+
+	SetTempComp(bool) error
+	Move(position int) error
+	Halt() error
+}
+
+type FocuserHandler struct {
+	DeviceHandler
+	dev Focuser
+}
+
+func NewFocuserHandler(dev Focuser, version int) *FocuserHandler {
+	return &FocuserHandler{
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
+		dev:           dev,
+	}
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeFocuser, func(dev Device, version int) DeviceHTTPHandler {
+		if f, ok := dev.(Focuser); ok {
+			return NewFocuserHandler(f, version)
+		}
+		return nil
+	})
+}
+
+func (fh *FocuserHandler) RegisterRoutes(mux *http.ServeMux) {
+	fh.DeviceHandler.RegisterRoutes(mux)
+
+	for _, property := range []string{"absolute", "maxincrement", "maxstep", "stepsize", "tempcompavailable"} {
+		mux.Handle("GET /"+property, fh.handleStatic(fh.handleCapabilities))
+	}
+	for _, property := range []string{"position", "ismoving", "tempcomp", "temperature"} {
+		mux.Handle("GET /"+property, handleAPI(fh.handleStatus))
+	}
+
+	mux.Handle("PUT /tempcomp", handleAPI(fh.handleTempComp))
+	mux.Handle("PUT /move", handleAPI(fh.handleMove))
+	mux.Handle("PUT /halt", handleAPI(fh.handleHalt))
+}
+
+func (fh *FocuserHandler) handleCapabilities(r *http.Request) (any, error) {
+	cap := fh.dev.Capabilities()
+
+	property := r.URL.Path[1:]
+	switch property {
+	case "absolute":
+		return cap.Absolute, nil
+	case "maxincrement":
+		return cap.MaxIncrement, nil
+	case "maxstep":
+		return cap.MaxStep, nil
+	case "stepsize":
+		if cap.StepSize <= 0 {
+			return nil, alpacaerrors.ErrNotImplemented
+		}
+		return cap.StepSize, nil
+	case "tempcompavailable":
+		return cap.TempCompAvailable, nil
+	default:
+		return nil, errBadRequest
+	}
+}
+
+func (fh *FocuserHandler) handleStatus(r *http.Request) (any, error) {
+	if !fh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	status := fh.dev.Status()
+
+	property := r.URL.Path[1:]
+	switch property {
+	case "position":
+		if !fh.dev.Capabilities().Absolute {
+			return nil, alpacaerrors.Errorf(alpacaerrors.ErrNotImplemented, "a relative focuser has no position")
+		}
+		return status.Position, nil
+	case "ismoving":
+		return status.IsMoving, nil
+	case "tempcomp":
+		return status.TempComp, nil
+	case "temperature":
+		if status.Temperature == nil {
+			return nil, alpacaerrors.Errorf(alpacaerrors.ErrNotImplemented, "the focuser has no temperature sensor")
+		}
+		return *status.Temperature, nil
+	default:
+		return nil, errBadRequest
+	}
+}
+
+// handleTempComp turns the temperature compensation on or off. Turning it
+// off is accepted without compensation, as it is always off.
+func (fh *FocuserHandler) handleTempComp(r *http.Request) (any, error) {
+	tempComp, err := getBoolParam(r, "TempComp")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if !fh.dev.Capabilities().TempCompAvailable {
+		if tempComp {
+			return nil, alpacaerrors.Errorf(alpacaerrors.ErrNotImplemented, "temperature compensation is not available")
+		}
+		return nil, nil
+	}
+	if !fh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, fh.dev.SetTempComp(tempComp)
+}
+
+// handleMove moves an absolute focuser to a position from 0 to MaxStep, and
+// a relative one by up to MaxIncrement steps in either direction.
+func (fh *FocuserHandler) handleMove(r *http.Request) (any, error) {
+	position, err := getIntParam(r, "Position")
+	if err != nil {
+		return nil, errBadRequest
+	}
+
+	cap := fh.dev.Capabilities()
+	switch {
+	case cap.Absolute && (position < 0 || position > cap.MaxStep):
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "position %d out of range 0 to %d", position, cap.MaxStep)
+	case !cap.Absolute && (position < -cap.MaxIncrement || position > cap.MaxIncrement):
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "move of %d steps larger than %d", position, cap.MaxIncrement)
+	}
+	if !fh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, fh.dev.Move(position)
+}
+
+func (fh *FocuserHandler) handleHalt(r *http.Request) (any, error) {
+	if !fh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, fh.dev.Halt()
+}
@@ -0,0 +1,103 @@
+package alpaca
+
+import (
+	"net/url"
+	"testing"
+
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFocuser is an absolute focuser without temperature sensor, or a
+// relative one with temperature compensation.
+type fakeFocuser struct {
+	fakeConditions
+	relative bool
+	position int
+	tempComp bool
+	halted   bool
+}
+
+func (d *fakeFocuser) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Focuser", Type: DeviceTypeFocuser, Number: 0, UniqueID: "fake-focuser"}
+}
+
+func (d *fakeFocuser) Capabilities() FocuserCapabilities {
+	return FocuserCapabilities{
+		Absolute:          !d.relative,
+		MaxIncrement:      1000,
+		MaxStep:           10000,
+		TempCompAvailable: d.relative,
+	}
+}
+
+func (d *fakeFocuser) Status() FocuserStatus {
+	return FocuserStatus{Position: d.position, TempComp: d.tempComp}
+}
+
+func (d *fakeFocuser) SetTempComp(on bool) error { d.tempComp = on; return nil }
+func (d *fakeFocuser) Halt() error               { d.halted = true; return nil }
+
+func (d *fakeFocuser) Move(position int) error {
+	if d.relative {
+		position += d.position
+	}
+	d.position = position
+	return nil
+}
+
+func TestFocuserHandler(t *testing.T) {
+	dev := &fakeFocuser{position: 5000}
+	dev.connected = true
+	ts := newTestServer(dev)
+	defer ts.Close()
+	api := ts.URL + "/api/v1/focuser/0/"
+
+	assert.Equal(t, true, getJSON(t, api+"absolute?ClientTransactionID=1").Value)
+	assert.Equal(t, 10000.0, getJSON(t, api+"maxstep?ClientTransactionID=1").Value)
+	assert.Equal(t, 5000.0, getJSON(t, api+"position?ClientTransactionID=1").Value)
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, getJSON(t, api+"stepsize?ClientTransactionID=1").ErrorNumber, "unknown step size")
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, getJSON(t, api+"temperature?ClientTransactionID=1").ErrorNumber, "no sensor")
+
+	resp := putForm(t, api+"move", url.Values{"Position": {"7000"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 7000, dev.position)
+	resp = putForm(t, api+"move", url.Values{"Position": {"10001"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber, "beyond MaxStep")
+
+	resp = putForm(t, api+"tempcomp", url.Values{"TempComp": {"false"}, "ClientTransactionID": {"1"}})
+	assert.Zero(t, resp.ErrorNumber, "turning off an unavailable compensation is accepted")
+	resp = putForm(t, api+"tempcomp", url.Values{"TempComp": {"true"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, resp.ErrorNumber)
+
+	resp = putForm(t, api+"halt", url.Values{"ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.True(t, dev.halted)
+
+	dev.connected = false
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, getJSON(t, api+"ismoving?ClientTransactionID=1").ErrorNumber)
+	resp = putForm(t, api+"move", url.Values{"Position": {"6000"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, resp.ErrorNumber)
+}
+
+func TestRelativeFocuser(t *testing.T) {
+	dev := &fakeFocuser{relative: true, position: 5000}
+	dev.connected = true
+	ts := newTestServer(dev)
+	defer ts.Close()
+	api := ts.URL + "/api/v1/focuser/0/"
+
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, getJSON(t, api+"position?ClientTransactionID=1").ErrorNumber)
+
+	resp := putForm(t, api+"move", url.Values{"Position": {"-800"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 4200, dev.position)
+	resp = putForm(t, api+"move", url.Values{"Position": {"-1001"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber, "beyond MaxIncrement")
+
+	resp = putForm(t, api+"tempcomp", url.Values{"TempComp": {"true"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, true, getJSON(t, api+"tempcomp?ClientTransactionID=1").Value)
+}
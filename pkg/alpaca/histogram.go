@@ -0,0 +1,48 @@
+package alpaca
+
+import "net/http"
+
+// AzimuthBin is the time a dome spent slewing through an azimuth sector.
+type AzimuthBin struct {
+	From     float64 `json:"From"`     // Start of the sector, in degrees
+	To       float64 `json:"To"`       // End of the sector, in degrees
+	Seconds  float64 `json:"Seconds"`  // Total time spent slewing through the sector
+	Passes   int     `json:"Passes"`   // Number of times the dome slewed into the sector
+	MeanPass float64 `json:"MeanPass"` // Mean time of a pass, in seconds
+	Slow     bool    `json:"Slow"`     // True if a pass takes much longer than through the other sectors
+}
+
+// AzimuthHistogrammer is implemented by domes that aggregate the time spent
+// slewing through each azimuth sector, to find where the dome sticks or
+// slows down.
+type AzimuthHistogrammer interface {
+	AzimuthHistogram() []AzimuthBin
+}
+
+// deviceHistogram is the histogram of a device in the azimuthhistogram
+// response.
+type deviceHistogram struct {
+	Device string       `json:"Device"` // "<type>/<number>", as in the timeline
+	Name   string       `json:"Name"`
+	Bins   []AzimuthBin `json:"Bins"`
+}
+
+// handleAzimuthHistogram returns the azimuth histograms of the enabled domes
+// that record one. This is an extension to the Alpaca management API.
+func (s *Server) handleAzimuthHistogram(r *http.Request) (any, error) {
+	histograms := []deviceHistogram{}
+	for _, dev := range s.devices {
+		h, ok := dev.(AzimuthHistogrammer)
+		if !ok || !deviceEnabled(dev) {
+			continue
+		}
+
+		info := dev.DeviceInfo()
+		histograms = append(histograms, deviceHistogram{
+			Device: deviceKey(info),
+			Name:   info.Name,
+			Bins:   h.AzimuthHistogram(),
+		})
+	}
+	return histograms, nil
+}
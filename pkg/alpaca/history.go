@@ -0,0 +1,314 @@
+package alpaca
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	historyBucket = "connect_history"
+
+	// maxConnectionRecords is the number of connections kept, the oldest ones
+	// are dropped.
+	maxConnectionRecords = 2000
+
+	// requestAttribution is how long after a client request a connection or
+	// disconnection is attributed to that client.
+	requestAttribution = 2 * time.Minute
+
+	// Paging limits of the connection history endpoint.
+	defaultHistoryLimit = 100
+	maxHistoryLimit     = 1000
+)
+
+// ConnectionRecord is a connection of a device, from the connect to the
+// disconnect. A disconnection without a recorded connect, such as a broker
+// connection lost after it was reestablished, has no connect time.
+type ConnectionRecord struct {
+	ID     uint64 `json:"ID"`
+	Device string `json:"Device"` // Device name
+
+	ConnectedAt     *time.Time `json:"ConnectedAt,omitempty"`
+	ConnectClientID uint32     `json:"ConnectClientID,omitempty"` // ClientID of the client that connected the device, 0 if none
+	ConnectRemote   string     `json:"ConnectRemote,omitempty"`   // Address of that client
+
+	DisconnectedAt     *time.Time `json:"DisconnectedAt,omitempty"`
+	DisconnectClientID uint32     `json:"DisconnectClientID,omitempty"` // ClientID of the client that disconnected the device, 0 if none
+	DisconnectRemote   string     `json:"DisconnectRemote,omitempty"`   // Address of that client
+	Reason             string     `json:"Reason,omitempty"`             // Why the device was disconnected
+
+	Duration float64 `json:"Duration,omitempty"` // Connection duration in seconds, once disconnected
+}
+
+// clientRequest is a connect or disconnect request of a client, waiting for
+// the device to report the change.
+type clientRequest struct {
+	clientID uint32
+	remote   string
+	at       time.Time
+}
+
+// History persists the connections and disconnections of the devices, with
+// the client that requested them, to find out why a device was disconnected
+// during an unattended night.
+type History struct {
+	db *bolt.DB
+
+	mu       sync.Mutex
+	open     map[string]uint64        // ID of the open connection of each device
+	requests map[string]clientRequest // Last request of each device, by "<device> <connect|disconnect>"
+}
+
+// NewHistory opens the connection history in the database. Connections left
+// open by a previous run are closed as interrupted by the server stop.
+func NewHistory(db *bolt.DB) (*History, error) {
+	h := &History{
+		db:       db,
+		open:     make(map[string]uint64),
+		requests: make(map[string]clientRequest),
+	}
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(historyBucket))
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var rec ConnectionRecord
+			if err := json.Unmarshal(v, &rec); err != nil || rec.DisconnectedAt != nil || rec.Reason != "" {
+				return nil
+			}
+			rec.Reason = "server stopped while connected"
+			value, _ := json.Marshal(rec)
+			return b.Put(k, value)
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the connection history: %v", err)
+	}
+	return h, nil
+}
+
+func historyKey(id uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, id)
+}
+
+// request remembers a connect or disconnect request, to attribute the change
+// the device reports to the client. It is safe to call on a nil History.
+func (h *History) request(device string, connect bool, r *http.Request) {
+	if h == nil {
+		return
+	}
+	clientID := ClientID(r.Context())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.requests[requestKey(device, connect)] = clientRequest{
+		clientID: clientID,
+		remote:   r.RemoteAddr,
+		at:       time.Now(),
+	}
+}
+
+func requestKey(device string, connect bool) string {
+	if connect {
+		return device + " connect"
+	}
+	return device + " disconnect"
+}
+
+// takeRequest returns the recent request of a change, if any, and forgets it.
+func (h *History) takeRequest(device string, connect bool, now time.Time) (clientRequest, bool) {
+	key := requestKey(device, connect)
+	req, ok := h.requests[key]
+	delete(h.requests, key)
+	return req, ok && now.Sub(req.at) <= requestAttribution
+}
+
+// handleEvent records the connections and disconnections published on the
+// event bus.
+func (h *History) handleEvent(e Event) {
+	if e.Type != EventConnected && e.Type != EventDisconnected {
+		return
+	}
+	now := e.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var err error
+	if e.Type == EventConnected {
+		err = h.connected(e.Device, now)
+	} else {
+		err = h.disconnected(e.Device, e.Message, now)
+	}
+	if err != nil {
+		log.Warnf("Failed to record the connection history of %s: %v", e.Device, err)
+	}
+}
+
+func (h *History) connected(device string, now time.Time) error {
+	if _, ok := h.open[device]; ok {
+		return nil // Already connected
+	}
+
+	rec := ConnectionRecord{Device: device, ConnectedAt: &now}
+	if req, ok := h.takeRequest(device, true, now); ok {
+		rec.ConnectClientID, rec.ConnectRemote = req.clientID, req.remote
+	}
+
+	return h.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(historyBucket))
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		rec.ID = id
+		value, _ := json.Marshal(rec)
+		if err := b.Put(historyKey(id), value); err != nil {
+			return err
+		}
+		h.open[device] = id
+		return prune(b)
+	})
+}
+
+func (h *History) disconnected(device, reason string, now time.Time) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(historyBucket))
+
+		rec := ConnectionRecord{Device: device}
+		if id, ok := h.open[device]; ok {
+			delete(h.open, device)
+			if v := b.Get(historyKey(id)); v != nil {
+				if err := json.Unmarshal(v, &rec); err != nil {
+					return err
+				}
+			}
+		}
+		if rec.ID == 0 {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			rec.ID = id
+		}
+
+		rec.DisconnectedAt = &now
+		rec.Reason = reason
+		if req, ok := h.takeRequest(device, false, now); ok {
+			rec.DisconnectClientID, rec.DisconnectRemote = req.clientID, req.remote
+		}
+		if rec.ConnectedAt != nil {
+			rec.Duration = now.Sub(*rec.ConnectedAt).Seconds()
+		}
+
+		value, _ := json.Marshal(rec)
+		if err := b.Put(historyKey(rec.ID), value); err != nil {
+			return err
+		}
+		return prune(b)
+	})
+}
+
+// prune drops the oldest records beyond maxConnectionRecords. The records
+// are keyed by their sequential ID.
+func prune(b *bolt.Bucket) error {
+	if b.Sequence() <= maxConnectionRecords {
+		return nil
+	}
+	oldest := b.Sequence() - maxConnectionRecords
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k) <= oldest; k, _ = c.First() {
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Records returns the connections, newest first, optionally of a single
+// device. Before is the ID of the first record not to return, 0 for the
+// newest ones.
+func (h *History) Records(device string, before uint64, limit int) ([]ConnectionRecord, error) {
+	records := []ConnectionRecord{}
+	err := h.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(historyBucket)).Cursor()
+
+		k, v := c.Last()
+		if before > 0 {
+			c.Seek(historyKey(before))
+			k, v = c.Prev()
+		}
+		for ; k != nil && len(records) < limit; k, v = c.Prev() {
+			var rec ConnectionRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				continue
+			}
+			if device == "" || rec.Device == device {
+				records = append(records, rec)
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+// historyPage is the response of the connection history endpoint.
+type historyPage struct {
+	Records []ConnectionRecord `json:"Records"`
+	// Next is the cursor of the following page, to be sent as the before
+	// parameter, or 0 if there are no more records.
+	Next uint64 `json:"Next"`
+}
+
+// handleConnectHistory returns the connections of the devices, newest first.
+// The device parameter selects a device by name, limit sets the page size and
+// before the page, the Next cursor of the previous page. This is an extension
+// to the Alpaca management API.
+func (s *Server) handleConnectHistory(r *http.Request) (any, error) {
+	if s.history == nil {
+		return nil, fmt.Errorf("the connection history is not enabled")
+	}
+	query := r.URL.Query()
+
+	limit := defaultHistoryLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit %q", v)
+		}
+		limit = min(n, maxHistoryLimit)
+	}
+
+	var before uint64
+	if v := query.Get("before"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid before %q", v)
+		}
+		before = n
+	}
+
+	records, err := s.history.Records(query.Get("device"), before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the connection history: %v", err)
+	}
+	page := historyPage{Records: records}
+	if len(records) == limit {
+		page.Next = records[len(records)-1].ID
+	}
+	return page, nil
+}
@@ -0,0 +1,123 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openHistory(t *testing.T, path string) (*History, *bolt.DB) {
+	t.Helper()
+
+	db, err := bolt.Open(path, 0o600, nil)
+	require.NoError(t, err)
+	h, err := NewHistory(db)
+	require.NoError(t, err)
+	return h, db
+}
+
+func TestConnectHistory(t *testing.T) {
+	h, db := openHistory(t, filepath.Join(t.TempDir(), "history.db"))
+	defer db.Close()
+
+	dome := &fakeDome{}
+	server := NewServer(ServerDescription{Name: "Test"}, []Device{dome}, nil, nil)
+	server.SetHistory(h)
+	ts := httptest.NewServer(server.AddRoutes())
+	defer ts.Close()
+
+	put := func(method string, clientID string) {
+		form := url.Values{"ClientID": {clientID}, "ClientTransactionID": {"1"}}
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/api/v1/dome/0/"+method, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	start := time.Now()
+	put("connect", "7")
+	h.handleEvent(Event{Type: EventConnected, Time: start, Device: "Fake Dome"})
+	h.handleEvent(Event{Type: EventConnected, Time: start.Add(time.Second), Device: "Fake Dome"}) // Already connected
+
+	// The broker connection is lost.
+	h.handleEvent(Event{Type: EventDisconnected, Time: start.Add(30 * time.Second), Device: "Fake Dome", Message: "Lost connection to MQTT broker"})
+
+	put("connect", "8")
+	h.handleEvent(Event{Type: EventConnected, Time: start.Add(time.Minute), Device: "Fake Dome"})
+	put("disconnect", "8")
+	h.handleEvent(Event{Type: EventDisconnected, Time: start.Add(90 * time.Second), Device: "Fake Dome", Message: "Disconnected from MQTT broker"})
+	h.handleEvent(Event{Type: EventStateChanged, Device: "Fake Dome"})
+
+	resp, err := http.Get(ts.URL + "/management/v1/connecthistory?limit=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var page struct {
+		Value historyPage
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&page))
+	require.Len(t, page.Value.Records, 1)
+	assert.NotZero(t, page.Value.Next)
+
+	records, err := h.Records("Fake Dome", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, records[0], page.Value.Records[0])
+
+	last := records[0]
+	assert.EqualValues(t, 8, last.ConnectClientID)
+	assert.EqualValues(t, 8, last.DisconnectClientID)
+	assert.Equal(t, "Disconnected from MQTT broker", last.Reason)
+	assert.Equal(t, 30.0, last.Duration)
+
+	lost := records[1]
+	assert.EqualValues(t, 7, lost.ConnectClientID)
+	assert.Zero(t, lost.DisconnectClientID, "not requested by a client")
+	assert.Equal(t, "Lost connection to MQTT broker", lost.Reason)
+	assert.Equal(t, 30.0, lost.Duration)
+
+	older, err := h.Records("", page.Value.Next, 10)
+	require.NoError(t, err)
+	assert.Equal(t, records[1:], older)
+
+	none, err := h.Records("Other Dome", 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestConnectHistoryServerStopped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	h, db := openHistory(t, path)
+	h.handleEvent(Event{Type: EventConnected, Device: "Fake Dome"})
+	require.NoError(t, db.Close())
+
+	h, db = openHistory(t, path)
+	defer db.Close()
+	records, err := h.Records("", 0, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "server stopped while connected", records[0].Reason)
+	assert.Nil(t, records[0].DisconnectedAt)
+}
+
+func TestConnectHistoryPrune(t *testing.T) {
+	h, db := openHistory(t, filepath.Join(t.TempDir(), "history.db"))
+	defer db.Close()
+
+	for range maxConnectionRecords + 5 {
+		h.handleEvent(Event{Type: EventDisconnected, Device: "Fake Dome"})
+	}
+	records, err := h.Records("", 0, maxHistoryLimit*3)
+	require.NoError(t, err)
+	assert.Len(t, records, maxConnectionRecords)
+	assert.EqualValues(t, maxConnectionRecords+5, records[0].ID)
+}
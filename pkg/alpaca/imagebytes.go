@@ -0,0 +1,266 @@
+// Documentation: https://ascom-standards.org/Developer/AlpacaImageBytes.pdf
+
+package alpaca
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ImageElementType is the type of the pixels of an image, as numbered by the
+// Alpaca specification.
+type ImageElementType int
+
+const (
+	ImageUnknown ImageElementType = iota
+	ImageInt16
+	ImageInt32
+	ImageDouble
+	ImageSingle
+	ImageUInt64
+	ImageByte
+	ImageInt64
+	ImageUInt16
+	ImageUInt32
+)
+
+// imageBytesMediaType is the media type of the binary image responses.
+const imageBytesMediaType = "application/imagebytes"
+
+const (
+	imageBytesVersion    = 1  // Version of the metadata of the ImageBytes header
+	imageBytesHeaderSize = 44 // Size of the ImageBytes header, eleven int32
+)
+
+// Image is an image read from a camera. Its pixels are stored row by row,
+// with the planes of each pixel next to each other, at the index
+// (y*Width+x)*Planes+plane. Pixels is a []uint8, []int16, []uint16, []int32,
+// []float32 or []float64; the smallest type holding the values keeps the
+// binary responses small.
+type Image struct {
+	Width, Height int
+	Planes        int // 1 for a monochrome or Bayer image, 3 for a color one
+	Pixels        any
+}
+
+// Rank returns the number of dimensions of the image array: 2 for one
+// plane, 3 for a color image.
+func (img *Image) Rank() int {
+	if img.Planes > 1 {
+		return 3
+	}
+	return 2
+}
+
+// elementTypes returns the type of the ImageArray property, Int32 or Double
+// as the specification requires, and the type the pixels are sent as.
+func (img *Image) elementTypes() (ImageElementType, ImageElementType, error) {
+	switch img.Pixels.(type) {
+	case []uint8:
+		return ImageInt32, ImageByte, nil
+	case []int16:
+		return ImageInt32, ImageInt16, nil
+	case []uint16:
+		return ImageInt32, ImageUInt16, nil
+	case []int32:
+		return ImageInt32, ImageInt32, nil
+	case []float32:
+		return ImageDouble, ImageSingle, nil
+	case []float64:
+		return ImageDouble, ImageDouble, nil
+	default:
+		return ImageUnknown, ImageUnknown, fmt.Errorf("unsupported pixel type %T", img.Pixels)
+	}
+}
+
+// check returns an error if the pixel buffer does not match the size of the
+// image.
+func (img *Image) check() error {
+	if _, _, err := img.elementTypes(); err != nil {
+		return err
+	}
+	planes := max(img.Planes, 1)
+	if n := pixelCount(img.Pixels); img.Width <= 0 || img.Height <= 0 || n != img.Width*img.Height*planes {
+		return fmt.Errorf("%d pixels for a %dx%dx%d image", n, img.Width, img.Height, planes)
+	}
+	return nil
+}
+
+func pixelCount(pixels any) int {
+	switch p := pixels.(type) {
+	case []uint8:
+		return len(p)
+	case []int16:
+		return len(p)
+	case []uint16:
+		return len(p)
+	case []int32:
+		return len(p)
+	case []float32:
+		return len(p)
+	case []float64:
+		return len(p)
+	default:
+		return 0
+	}
+}
+
+// columnMajor returns the pixels in the order of the Alpaca image arrays:
+// by column, then row, then plane.
+func columnMajor[T any](pixels []T, img *Image) []T {
+	planes := max(img.Planes, 1)
+	out := make([]T, 0, len(pixels))
+	for x := range img.Width {
+		for y := range img.Height {
+			i := (y*img.Width + x) * planes
+			out = append(out, pixels[i:i+planes]...)
+		}
+	}
+	return out
+}
+
+// nested returns the pixels as the nested arrays of the JSON image arrays,
+// indexed by column, then row, then plane for a color image.
+func nested[T any](pixels []T, img *Image) any {
+	planes := max(img.Planes, 1)
+	columns := make([]any, img.Width)
+	for x := range img.Width {
+		if img.Rank() == 2 {
+			column := make([]T, img.Height)
+			for y := range img.Height {
+				column[y] = pixels[y*img.Width+x]
+			}
+			columns[x] = column
+			continue
+		}
+		column := make([][]T, img.Height)
+		for y := range img.Height {
+			i := (y*img.Width + x) * planes
+			column[y] = pixels[i : i+planes]
+		}
+		columns[x] = column
+	}
+	return columns
+}
+
+// acceptsImageBytes reports whether an Accept header allows the binary
+// image responses.
+func acceptsImageBytes(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == imageBytesMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeImageBytes returns the ImageBytes form of an image response: the
+// header, then the pixels, or the UTF-8 error message of an error response.
+func encodeImageBytes(response baseResponse, img *Image) ([]byte, error) {
+	header := [11]int32{
+		imageBytesVersion,
+		int32(response.ErrorNumber),
+		int32(response.ClientTransactionID),
+		int32(response.ServerTransactionID),
+		imageBytesHeaderSize,
+	}
+
+	var buf bytes.Buffer
+	if response.ErrorNumber != 0 || img == nil {
+		binary.Write(&buf, binary.LittleEndian, header)
+		buf.WriteString(response.ErrorMessage)
+		return buf.Bytes(), nil
+	}
+
+	if err := img.check(); err != nil {
+		return nil, err
+	}
+	imageType, transmissionType, _ := img.elementTypes()
+	header[5], header[6] = int32(imageType), int32(transmissionType)
+	header[7], header[8], header[9] = int32(img.Rank()), int32(img.Width), int32(img.Height)
+	if img.Rank() == 3 {
+		header[10] = int32(img.Planes)
+	}
+	binary.Write(&buf, binary.LittleEndian, header)
+
+	switch p := img.Pixels.(type) {
+	case []uint8:
+		buf.Write(columnMajor(p, img))
+	case []int16:
+		binary.Write(&buf, binary.LittleEndian, columnMajor(p, img))
+	case []uint16:
+		binary.Write(&buf, binary.LittleEndian, columnMajor(p, img))
+	case []int32:
+		binary.Write(&buf, binary.LittleEndian, columnMajor(p, img))
+	case []float32:
+		binary.Write(&buf, binary.LittleEndian, columnMajor(p, img))
+	case []float64:
+		binary.Write(&buf, binary.LittleEndian, columnMajor(p, img))
+	}
+	return buf.Bytes(), nil
+}
+
+// imageArrayResponse is the JSON response of an image array, with the type
+// and the rank of its Value.
+type imageArrayResponse struct {
+	baseResponse
+	Type ImageElementType `json:"Type"`
+	Rank int              `json:"Rank"`
+}
+
+// writeImage writes the response of an image array, in the ImageBytes
+// format when the client accepts it, as JSON otherwise.
+func writeImage(w http.ResponseWriter, r *http.Request, response baseResponse) {
+	img, _ := response.Value.(*Image)
+
+	if acceptsImageBytes(r.Header.Get("Accept")) {
+		data, err := encodeImageBytes(response, img)
+		if err != nil {
+			requestLogger(r).Errorf("Failed to encode image: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", imageBytesMediaType)
+		w.Write(data)
+		return
+	}
+
+	if img == nil {
+		writeJSON(w, r, response)
+		return
+	}
+	if err := img.check(); err != nil {
+		requestLogger(r).Errorf("Failed to encode image: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	imageType, _, _ := img.elementTypes()
+	switch p := img.Pixels.(type) {
+	case []uint8:
+		// A []uint8 would be encoded as a base64 string.
+		wide := make([]int16, len(p))
+		for i, v := range p {
+			wide[i] = int16(v)
+		}
+		response.Value = nested(wide, img)
+	case []int16:
+		response.Value = nested(p, img)
+	case []uint16:
+		response.Value = nested(p, img)
+	case []int32:
+		response.Value = nested(p, img)
+	case []float32:
+		response.Value = nested(p, img)
+	case []float64:
+		response.Value = nested(p, img)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(imageArrayResponse{baseResponse: response, Type: imageType, Rank: img.Rank()})
+}
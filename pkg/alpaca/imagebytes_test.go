@@ -0,0 +1,50 @@
+package alpaca
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// colorImage is a 2x1 RGB image of 8-bit pixels.
+func colorImage() *Image {
+	return &Image{Width: 2, Height: 1, Planes: 3, Pixels: []uint8{1, 2, 3, 4, 5, 6}}
+}
+
+func TestEncodeImageBytes(t *testing.T) {
+	data, err := encodeImageBytes(baseResponse{ClientTransactionID: 3, ServerTransactionID: 9}, colorImage())
+	require.NoError(t, err)
+	require.Len(t, data, imageBytesHeaderSize+6)
+
+	var header [11]int32
+	_, err = binary.Decode(data, binary.LittleEndian, &header)
+	require.NoError(t, err)
+	assert.Equal(t, [11]int32{1, 0, 3, 9, 44, int32(ImageInt32), int32(ImageByte), 3, 2, 1, 3}, header)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6}, data[imageBytesHeaderSize:])
+
+	_, err = encodeImageBytes(baseResponse{}, &Image{Width: 2, Height: 2, Pixels: []int32{1, 2, 3}})
+	assert.Error(t, err, "too few pixels")
+	_, err = encodeImageBytes(baseResponse{}, &Image{Width: 1, Height: 1, Pixels: []int64{1}})
+	assert.Error(t, err, "unsupported type")
+}
+
+func TestWriteImageJSON(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeImage(w, httptest.NewRequest(http.MethodGet, "/imagearray", nil), baseResponse{Value: colorImage()})
+
+	var response struct {
+		Type, Rank int
+		Value      [][][]int
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 3, response.Rank)
+	assert.Equal(t, [][][]int{{{1, 2, 3}}, {{4, 5, 6}}}, response.Value, "bytes as numbers, not base64")
+
+	assert.True(t, acceptsImageBytes("application/json, application/imagebytes;q=0.9"))
+	assert.False(t, acceptsImageBytes("application/json"))
+}
@@ -0,0 +1,107 @@
+package alpaca
+
+import (
+	"alpaca/pkg/metrics"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// requestBuckets are the bounds of the request durations, in seconds: the
+// properties are answered in milliseconds, the commands waiting for the
+// controller in up to seconds.
+var requestBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	apiRequests = metrics.NewCounterVec("alpaca_requests_total",
+		"Alpaca requests by device, method and Alpaca error number, 0 for success.", "device", "method", "error")
+	apiDurations = metrics.NewHistogramVec("alpaca_request_duration_seconds",
+		"Duration of the Alpaca requests by device and method.", requestBuckets, "device", "method")
+)
+
+// MetricsProvider is implemented by devices exporting gauges of their own,
+// beyond their DeviceState, such as the age of their telemetry.
+type MetricsProvider interface {
+	Metrics() []metrics.Gauge
+}
+
+// observeRequest counts an Alpaca request and its duration, by device and
+// method. The management requests have the management device.
+func observeRequest(r *http.Request, start time.Time, errorNumber int) {
+	device, method := requestTarget(r)
+	apiRequests.Inc(device, method, strconv.Itoa(errorNumber))
+	apiDurations.Observe(time.Since(start).Seconds(), device, method)
+}
+
+// CollectMetrics exports the gauges of the devices in a registry: whether
+// each enabled device is connected, the numeric and boolean properties of
+// the DeviceState of the connected ones, and the gauges of the devices
+// providing their own. It returns a function stopping the export.
+func (s *Server) CollectMetrics(registry *metrics.Registry) (unregister func()) {
+	return registry.Collect(s.deviceGauges)
+}
+
+func (s *Server) deviceGauges() []metrics.Gauge {
+	var gauges []metrics.Gauge
+	for _, dev := range s.devices {
+		if !deviceEnabled(dev) {
+			continue
+		}
+		info := dev.DeviceInfo()
+		device := metrics.Label{Name: "device", Value: deviceKey(info)}
+
+		connected := dev.Connected()
+		gauges = append(gauges, metrics.Gauge{
+			Name:   "alpaca_device_connected",
+			Help:   "1 if the device is connected, 0 otherwise.",
+			Labels: []metrics.Label{device, {Name: "name", Value: info.Name}},
+			Value:  gaugeValue(connected),
+		})
+		if !connected {
+			continue
+		}
+
+		for _, prop := range dev.GetState() {
+			value, ok := numericValue(prop.Value)
+			if !ok {
+				continue
+			}
+			gauges = append(gauges, metrics.Gauge{
+				Name:   "alpaca_device_state",
+				Help:   "Numeric and boolean DeviceState properties of the connected devices, booleans as 0 or 1.",
+				Labels: []metrics.Label{device, {Name: "property", Value: prop.Name}},
+				Value:  value,
+			})
+		}
+		if p, ok := dev.(MetricsProvider); ok {
+			gauges = append(gauges, p.Metrics()...)
+		}
+	}
+	return gauges
+}
+
+// numericValue returns a state value as a gauge value, if it is a number or
+// a boolean, such as the ShutterStatus.
+func numericValue(value any) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Bool:
+		return gaugeValue(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+func gaugeValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
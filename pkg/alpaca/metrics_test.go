@@ -0,0 +1,49 @@
+package alpaca
+
+import (
+	"alpaca/pkg/metrics"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	dome := &fakeDome{connected: true, status: DomeStatus{Azimuth: 123.5, Shutter: ShutterClosed, AtPark: true}}
+	ts := newTestServer(dome)
+	defer ts.Close()
+
+	registry := metrics.NewRegistry()
+	server := NewServer(ServerDescription{Name: "Test"}, []Device{dome}, nil, nil)
+	defer server.CollectMetrics(registry)()
+
+	getJSON(t, ts.URL+"/api/v1/dome/0/azimuth?ClientTransactionID=1")
+	getJSON(t, ts.URL+"/management/v1/description")
+
+	resp, err := http.Get(ts.URL + "/metrics")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `alpaca_requests_total{device="dome/0",method="azimuth",error="0"}`)
+	assert.Contains(t, string(body), `alpaca_requests_total{device="management",method="description",error="0"}`)
+	assert.Contains(t, string(body), `alpaca_request_duration_seconds_count{device="dome/0",method="azimuth"}`)
+
+	gauges := make(map[string]float64)
+	for _, g := range server.deviceGauges() {
+		key := g.Name
+		for _, l := range g.Labels {
+			key += " " + l.Value
+		}
+		gauges[key] = g.Value
+	}
+	assert.Equal(t, 1.0, gauges["alpaca_device_connected dome/0 Fake Dome"])
+	assert.Equal(t, 123.5, gauges["alpaca_device_state dome/0 Azimuth"])
+	assert.Equal(t, float64(ShutterClosed), gauges["alpaca_device_state dome/0 ShutterStatus"])
+	assert.Equal(t, 1.0, gauges["alpaca_device_state dome/0 AtPark"])
+
+	dome.connected = false
+	assert.Len(t, server.deviceGauges(), 1, "only the connection of a disconnected device")
+}
@@ -0,0 +1,203 @@
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sensors of the ObservingConditions devices, named as their properties.
+const (
+	SensorCloudCover     = "CloudCover"     // Percent of the sky covered
+	SensorDewPoint       = "DewPoint"       // Degrees Celsius
+	SensorHumidity       = "Humidity"       // Percent
+	SensorPressure       = "Pressure"       // Hectopascals at the observatory altitude
+	SensorRainRate       = "RainRate"       // Millimeters per hour
+	SensorSkyBrightness  = "SkyBrightness"  // Lux
+	SensorSkyQuality     = "SkyQuality"     // Magnitudes per square arc second
+	SensorSkyTemperature = "SkyTemperature" // Degrees Celsius
+	SensorStarFWHM       = "StarFWHM"       // Arc seconds
+	SensorTemperature    = "Temperature"    // Degrees Celsius
+	SensorWindDirection  = "WindDirection"  // Degrees from north, 0 without wind
+	SensorWindGust       = "WindGust"       // Meters per second
+	SensorWindSpeed      = "WindSpeed"      // Meters per second
+)
+
+// ObservingSensors lists the sensors of the ObservingConditions devices.
+var ObservingSensors = []string{
+	SensorCloudCover,
+	SensorDewPoint,
+	SensorHumidity,
+	SensorPressure,
+	SensorRainRate,
+	SensorSkyBrightness,
+	SensorSkyQuality,
+	SensorSkyTemperature,
+	SensorStarFWHM,
+	SensorTemperature,
+	SensorWindDirection,
+	SensorWindGust,
+	SensorWindSpeed,
+}
+
+// ObservingConditions is a weather station. The sensors are named as in
+// ObservingSensors; a device returns ErrNotImplemented for the sensors it
+// does not have.
+type ObservingConditions interface {
+	Device
+
+	Sensor(name string) (float64, error)
+	SensorDescription(name string) (string, error)
+
+	// TimeSinceLastUpdate returns the age of the value of a sensor, or of
+	// the latest value of any sensor when name is empty.
+	TimeSinceLastUpdate(name string) (time.Duration, error)
+
+	// AveragePeriod is the period, in hours, over which the values are
+	// averaged, 0 for the instantaneous values.
+	AveragePeriod() float64
+	SetAveragePeriod(hours float64) error
+
+	// Refresh reads the sensors at once.
+	Refresh() error
+}
+
+// DewPoint returns the dew point, in degrees Celsius, at a temperature in
+// degrees Celsius and a relative humidity in percent, with the Magnus
+// formula.
+func DewPoint(temperature, humidity float64) float64 {
+	const b, c = 17.62, 243.12
+	if humidity <= 0 {
+		return math.Inf(-1)
+	}
+	gamma := math.Log(humidity/100) + b*temperature/(c+temperature)
+	return c * gamma / (b - gamma)
+}
+
+// sensorName returns the name of a sensor given in any case.
+func sensorName(name string) (string, bool) {
+	for _, s := range ObservingSensors {
+		if strings.EqualFold(s, name) {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+type ObservingConditionsHandler struct {
+	DeviceHandler
+	dev ObservingConditions
+}
+
+func NewObservingConditionsHandler(dev ObservingConditions, version int) *ObservingConditionsHandler {
+	return &ObservingConditionsHandler{
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
+		dev:           dev,
+	}
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeConditions, func(dev Device, version int) DeviceHTTPHandler {
+		if d, ok := dev.(ObservingConditions); ok {
+			return NewObservingConditionsHandler(d, version)
+		}
+		return nil
+	})
+}
+
+func (oh *ObservingConditionsHandler) RegisterRoutes(mux *http.ServeMux) {
+	oh.DeviceHandler.RegisterRoutes(mux)
+
+	for _, s := range ObservingSensors {
+		mux.Handle("GET /"+strings.ToLower(s), handleAPI(oh.handleSensor))
+	}
+	mux.Handle("GET /sensordescription", handleAPI(oh.handleSensorDescription))
+	mux.Handle("GET /timesincelastupdate", handleAPI(oh.handleTimeSinceLastUpdate))
+	mux.Handle("GET /averageperiod", handleAPI(oh.handleAveragePeriod))
+	mux.Handle("PUT /averageperiod", handleAPI(oh.handleSetAveragePeriod))
+	mux.Handle("PUT /refresh", handleAPI(oh.handleRefresh))
+}
+
+func (oh *ObservingConditionsHandler) handleSensor(r *http.Request) (any, error) {
+	if !oh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	name, ok := sensorName(r.URL.Path[1:])
+	if !ok {
+		return nil, errBadRequest
+	}
+	return oh.dev.Sensor(name)
+}
+
+// sensorParam returns the sensor named by the SensorName parameter. An empty
+// name is only valid if allowed.
+func sensorParam(r *http.Request, allowEmpty bool) (string, error) {
+	value, err := getParam(r, "SensorName", true)
+	if err != nil {
+		return "", err
+	}
+	if value == "" && allowEmpty {
+		return "", nil
+	}
+	name, ok := sensorName(value)
+	if !ok {
+		return "", alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "unknown sensor %q", value)
+	}
+	return name, nil
+}
+
+func (oh *ObservingConditionsHandler) handleSensorDescription(r *http.Request) (any, error) {
+	name, err := sensorParam(r, false)
+	if err != nil {
+		return nil, err
+	}
+	if !oh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return oh.dev.SensorDescription(name)
+}
+
+func (oh *ObservingConditionsHandler) handleTimeSinceLastUpdate(r *http.Request) (any, error) {
+	name, err := sensorParam(r, true)
+	if err != nil {
+		return nil, err
+	}
+	if !oh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	age, err := oh.dev.TimeSinceLastUpdate(name)
+	if err != nil {
+		return nil, err
+	}
+	return age.Seconds(), nil
+}
+
+func (oh *ObservingConditionsHandler) handleAveragePeriod(r *http.Request) (any, error) {
+	if !oh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return oh.dev.AveragePeriod(), nil
+}
+
+func (oh *ObservingConditionsHandler) handleSetAveragePeriod(r *http.Request) (any, error) {
+	hours, err := getFloatParam(r, "AveragePeriod")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if hours < 0 {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "invalid average period %g", hours)
+	}
+	if !oh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, oh.dev.SetAveragePeriod(hours)
+}
+
+func (oh *ObservingConditionsHandler) handleRefresh(r *http.Request) (any, error) {
+	if !oh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, oh.dev.Refresh()
+}
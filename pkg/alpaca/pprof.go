@@ -0,0 +1,60 @@
+package alpaca
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// pprofPrefix is the path of the Go profiler endpoints.
+const pprofPrefix = "/debug/pprof/"
+
+// SetProfiling serves the Go profiler under /debug/pprof/, to profile the
+// CPU and the memory of a server in the field. It must be called before
+// AddRoutes.
+func (s *Server) SetProfiling(enabled bool) {
+	s.profiling = enabled
+}
+
+// addProfilingRoutes registers the profiler endpoints. They need the
+// configure scope once API keys are set; without keys, they are only served
+// to the clients of the same host, such as through an SSH tunnel.
+func addProfilingRoutes(r *http.ServeMux) {
+	r.Handle(pprofPrefix, localUnlessKeys(http.HandlerFunc(pprof.Index)))
+	r.Handle(pprofPrefix+"cmdline", localUnlessKeys(http.HandlerFunc(pprof.Cmdline)))
+	r.Handle(pprofPrefix+"profile", localUnlessKeys(http.HandlerFunc(pprof.Profile)))
+	r.Handle(pprofPrefix+"symbol", localUnlessKeys(http.HandlerFunc(pprof.Symbol)))
+	r.Handle(pprofPrefix+"trace", localUnlessKeys(http.HandlerFunc(pprof.Trace)))
+}
+
+// localUnlessKeys answers 403 to the requests from another host while no
+// API key is configured. The requests forwarded by a proxy are not local.
+func localUnlessKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if keys := apiKeys.Load(); (keys == nil || len(*keys) == 0) && !isLocalRequest(r) {
+			http.Error(w, "The profiler is only served to the local host until API keys are configured", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLocalRequest reports whether a request comes from the loopback
+// interface and was not forwarded.
+func isLocalRequest(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("Forwarded") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isProfilingPath reports whether a path is one of the profiler endpoints.
+func isProfilingPath(path string) bool {
+	return strings.HasPrefix(path, pprofPrefix)
+}
@@ -0,0 +1,52 @@
+package alpaca
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiling(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+	status := func(url, secret string) int {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusNotFound, status(ts.URL+"/debug/pprof/", ""), "off by default")
+
+	server := NewServer(ServerDescription{Name: "Test"}, nil, nil, nil)
+	server.SetProfiling(true)
+	ts = httptest.NewServer(server.AddRoutes())
+	defer ts.Close()
+	assert.Equal(t, http.StatusOK, status(ts.URL+"/debug/pprof/", ""), "served to the local host")
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+	req.RemoteAddr = "192.168.1.20:50000"
+	assert.False(t, isLocalRequest(req))
+	req.RemoteAddr = "127.0.0.1:50000"
+	assert.True(t, isLocalRequest(req))
+	req.Header.Set("X-Forwarded-For", "192.168.1.20")
+	assert.False(t, isLocalRequest(req), "forwarded by a proxy")
+
+	operator, operatorSecret, err := NewAPIKey("operator", []Scope{ScopeRead, ScopeRotation})
+	require.NoError(t, err)
+	admin, adminSecret, err := NewAPIKey("admin", Scopes)
+	require.NoError(t, err)
+	SetAPIKeys([]APIKey{operator, admin})
+	t.Cleanup(func() { SetAPIKeys(nil) })
+
+	assert.Equal(t, http.StatusUnauthorized, status(ts.URL+"/debug/pprof/", ""))
+	assert.Equal(t, http.StatusForbidden, status(ts.URL+"/debug/pprof/cmdline", operatorSecret))
+	assert.Equal(t, http.StatusOK, status(ts.URL+"/debug/pprof/cmdline", adminSecret))
+}
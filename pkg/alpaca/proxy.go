@@ -0,0 +1,160 @@
+package alpaca
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// trustedProxies holds the networks whose X-Forwarded-* headers are honored.
+var trustedProxies atomic.Pointer[[]*net.IPNet]
+
+const forwardedKey contextKey = "forwarded"
+
+// forwardedInfo is the original request information reported by a trusted
+// reverse proxy.
+type forwardedInfo struct {
+	Scheme string
+	Host   string
+}
+
+// ParseTrustedProxies parses a list of IP addresses or CIDR networks.
+func ParseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address: %q", p)
+			}
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			p = fmt.Sprintf("%s/%d", p, bits)
+		}
+
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy network: %q", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// SetTrustedProxies sets the reverse proxies whose X-Forwarded-* headers are
+// honored. Invalid entries are rejected.
+func SetTrustedProxies(proxies []string) error {
+	nets, err := ParseTrustedProxies(proxies)
+	if err != nil {
+		return err
+	}
+	trustedProxies.Store(&nets)
+	return nil
+}
+
+// isTrustedProxy reports whether a remote address belongs to a trusted proxy.
+func isTrustedProxy(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return isTrustedIP(net.ParseIP(host))
+}
+
+// isTrustedIP reports whether an IP address belongs to a trusted proxy.
+func isTrustedIP(ip net.IP) bool {
+	nets := trustedProxies.Load()
+	if nets == nil || ip == nil {
+		return false
+	}
+
+	for _, n := range *nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyMiddleware rewrites the request with the information forwarded by a
+// trusted reverse proxy: RemoteAddr becomes the original client address, so
+// every log line reports the real client, and the original scheme and host
+// are kept in the context for building absolute URLs.
+// Headers from untrusted peers are ignored, since any client can set them.
+func proxyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isTrustedProxy(r.RemoteAddr) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if client := forwardedClient(r.Header.Values("X-Forwarded-For")); client != nil {
+			r.RemoteAddr = net.JoinHostPort(client.String(), "0")
+		}
+
+		info := forwardedInfo{
+			Scheme: strings.ToLower(r.Header.Get("X-Forwarded-Proto")),
+			Host:   r.Header.Get("X-Forwarded-Host"),
+		}
+		ctx := context.WithValue(r.Context(), forwardedKey, info)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// forwardedClient returns the client address of X-Forwarded-For headers, or
+// nil if there is none. Each proxy appends the address it received the
+// request from, so the list is walked from the right, skipping the trusted
+// proxies: the addresses left of the first untrusted one were set by a
+// client and can be spoofed. If every address is a trusted proxy, the
+// left-most one is the client.
+func forwardedClient(headers []string) net.IP {
+	var hops []string
+	for _, h := range headers {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+
+	var client net.IP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !isTrustedIP(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// BaseURL returns the absolute URL of the server as seen by the client,
+// honoring the scheme and host forwarded by a trusted reverse proxy.
+func BaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if info, ok := r.Context().Value(forwardedKey).(forwardedInfo); ok {
+		if info.Scheme == "http" || info.Scheme == "https" {
+			scheme = info.Scheme
+		}
+		if info.Host != "" {
+			host = info.Host
+		}
+	}
+
+	return scheme + "://" + host
+}
@@ -0,0 +1,85 @@
+package alpaca
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyMiddleware(t *testing.T) {
+	require.NoError(t, SetTrustedProxies([]string{"10.0.0.1", "192.168.1.0/24"}))
+	defer SetTrustedProxies(nil)
+
+	var remote, base string
+	handler := proxyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remote = r.RemoteAddr
+		base = BaseURL(r)
+	}))
+
+	tests := []struct {
+		name       string
+		remote     string
+		remoteWant string
+		baseWant   string
+	}{
+		{"Trusted address", "10.0.0.1:5555", "203.0.113.7:0", "https://dome.example.org"},
+		{"Trusted network", "192.168.1.20:5555", "203.0.113.7:0", "https://dome.example.org"},
+		{"Untrusted peer", "10.0.0.2:5555", "10.0.0.2:5555", "http://localhost:8090"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://localhost:8090/setup", nil)
+			r.RemoteAddr = tc.remote
+			r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+			r.Header.Set("X-Forwarded-Proto", "https")
+			r.Header.Set("X-Forwarded-Host", "dome.example.org")
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			assert.Equal(t, tc.remoteWant, remote)
+			assert.Equal(t, tc.baseWant, base)
+		})
+	}
+}
+
+func TestForwardedClient(t *testing.T) {
+	require.NoError(t, SetTrustedProxies([]string{"10.0.0.1", "192.168.1.0/24"}))
+	defer SetTrustedProxies(nil)
+
+	tests := []struct {
+		name    string
+		headers []string
+		want    string
+	}{
+		{"Single client", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"Spoofed entry", []string{"127.0.0.1, 203.0.113.7"}, "203.0.113.7"},
+		{"Trusted proxies skipped", []string{"198.51.100.1, 203.0.113.7, 192.168.1.5, 10.0.0.1"}, "203.0.113.7"},
+		{"Several headers", []string{"127.0.0.1", "203.0.113.7, 10.0.0.1"}, "203.0.113.7"},
+		{"Only trusted proxies", []string{"192.168.1.5, 10.0.0.1"}, "192.168.1.5"},
+		{"Invalid entry", []string{"203.0.113.7, unknown"}, ""},
+		{"No header", nil, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := forwardedClient(tc.headers)
+			if tc.want == "" {
+				assert.Nil(t, client)
+			} else {
+				assert.Equal(t, tc.want, client.String())
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	nets, err := ParseTrustedProxies([]string{"127.0.0.1", " ::1 ", "10.0.0.0/8", ""})
+	require.NoError(t, err)
+	assert.Len(t, nets, 3)
+
+	_, err = ParseTrustedProxies([]string{"not-an-ip"})
+	assert.Error(t, err)
+}
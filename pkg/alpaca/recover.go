@@ -0,0 +1,54 @@
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// panics counts the panics recovered in HTTP handlers.
+var panics atomic.Int64
+
+// Panics returns the number of panics recovered in HTTP handlers since the
+// server started.
+func Panics() int64 {
+	return panics.Load()
+}
+
+// logPanic logs a recovered panic with its stack trace and counts it.
+func logPanic(r *http.Request, p any) {
+	panics.Add(1)
+	requestLogger(r).Errorf("Recovered from panic: %v\n%s", p, debug.Stack())
+}
+
+// callHandler calls an API handler, turning a panic into an Alpaca error so
+// the client gets a well-formed response.
+func callHandler(handler func(r *http.Request) (any, error), r *http.Request) (value any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logPanic(r, p)
+			value, err = nil, alpacaerrors.Errorf(alpacaerrors.ErrUnspecified, "internal error: %v", p)
+		}
+	}()
+
+	return handler(r)
+}
+
+// recoverMiddleware recovers from panics in any handler, such as the setup
+// pages, and replies with an internal server error.
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				logPanic(r, p)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,166 @@
+package alpaca
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// redacted replaces the secrets in the logs.
+const redacted = "[REDACTED]"
+
+// secretFields are the lower case substrings of the names of the string
+// fields holding secrets: passwords, tokens, API key hashes, and webhook and
+// upload URLs, which often embed a token or a signature.
+var secretFields = []string{"password", "token", "secret", "hash", "webhook", "upload"}
+
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range secretFields {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of a configuration with its secret fields replaced,
+// to be logged with %+v. Every configuration must be logged through it.
+func Redact[T any](cfg T) T {
+	v := redactValue(reflect.ValueOf(cfg))
+	if !v.IsValid() {
+		return cfg
+	}
+	return v.Interface().(T)
+}
+
+func redactValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Type.Kind() == reflect.String && isSecretField(field.Name) {
+				if v.Field(i).String() != "" {
+					out.Field(i).SetString(redacted)
+				}
+				continue
+			}
+			out.Field(i).Set(redactValue(v.Field(i)))
+		}
+		return out
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(redactValue(v.Elem()))
+		return out
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), redactValue(iter.Value()))
+		}
+		return out
+
+	default:
+		return v
+	}
+}
+
+// redactJSON returns a copy of a decoded JSON value with the strings of its
+// secret fields replaced, for the settings stored as JSON whose type is not
+// known, such as those of the drivers.
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for name, value := range v {
+			if s, ok := value.(string); ok && s != "" && isSecretField(name) {
+				out[name] = redacted
+				continue
+			}
+			out[name] = redactJSON(value)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = redactJSON(value)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// secretValues returns the strings of the secret fields of a decoded JSON
+// value, longest first.
+func secretValues(v any) []string {
+	var secrets []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for name, value := range v {
+				if s, ok := value.(string); ok && s != "" && isSecretField(name) {
+					secrets = append(secrets, s)
+					continue
+				}
+				walk(value)
+			}
+		case []any:
+			for _, value := range v {
+				walk(value)
+			}
+		}
+	}
+	walk(v)
+
+	slices.SortFunc(secrets, func(a, b string) int { return len(b) - len(a) })
+	return slices.Compact(secrets)
+}
+
+// scrubSecrets replaces the secret values in a text, such as the logs.
+func scrubSecrets(text string, secrets []string) string {
+	if len(secrets) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, 2*len(secrets))
+	for _, s := range secrets {
+		pairs = append(pairs, s, redacted)
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// redactHeader returns a copy of the headers without the credentials.
+func redactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range []string{"Authorization", "Proxy-Authorization", "Cookie"} {
+		if out.Get(name) != "" {
+			out.Set(name, redacted)
+		}
+	}
+	return out
+}
@@ -0,0 +1,64 @@
+package alpaca
+
+import (
+	"alpaca/pkg/notify"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	key, _, err := NewAPIKey("admin", Scopes)
+	assert.NoError(t, err)
+	cfg := Config{
+		StrictMode: true,
+		Notifications: notify.Config{
+			WebhookURL:    "https://hooks.example.org/T000/secret",
+			MQTTBroker:    "tcp://localhost:1883",
+			MQTTPassword:  "mqtt-secret",
+			SMTPPassword:  "smtp-secret",
+			TelegramToken: "123:telegram-secret",
+		},
+		APIKeys: []APIKey{key},
+	}
+
+	logged := fmt.Sprintf("%+v", Redact(cfg))
+	for _, secret := range []string{"secret", key.Hash} {
+		assert.NotContains(t, logged, secret)
+	}
+	assert.Contains(t, logged, "tcp://localhost:1883")
+	assert.Contains(t, logged, "admin")
+	assert.Equal(t, "mqtt-secret", cfg.Notifications.MQTTPassword, "the original is unchanged")
+	assert.NotEqual(t, redacted, cfg.APIKeys[0].Hash, "the original is unchanged")
+
+	// Embedded, pointed and mapped structs are redacted too, empty secrets
+	// are kept empty.
+	type MQTT struct{ Host, Password string }
+	type domeConfig struct {
+		MQTT
+		Backup *MQTT
+		Tokens map[string]MQTT
+	}
+	dc := Redact(domeConfig{MQTT{"broker", "pw"}, &MQTT{"backup", ""}, map[string]MQTT{"a": {"x", "pw"}}})
+	assert.Equal(t, redacted, dc.Password)
+	assert.Equal(t, "broker", dc.Host)
+	assert.Equal(t, "", dc.Backup.Password)
+	assert.Equal(t, "backup", dc.Backup.Host)
+	assert.Equal(t, redacted, dc.Tokens["a"].Password)
+}
+
+func TestRedactJSON(t *testing.T) {
+	settings := map[string]any{
+		"Broker":   "tcp://localhost:1883",
+		"Password": "mqtt-secret",
+		"Relays":   []any{map[string]any{"Name": "flat", "token": "relay-secret"}},
+		"Empty":    map[string]any{"password": ""},
+	}
+	redactedSettings := redactJSON(settings).(map[string]any)
+	assert.Equal(t, "tcp://localhost:1883", redactedSettings["Broker"])
+	assert.Equal(t, redacted, redactedSettings["Password"])
+	assert.Equal(t, map[string]any{"Name": "flat", "token": redacted}, redactedSettings["Relays"].([]any)[0])
+	assert.Equal(t, map[string]any{"password": ""}, redactedSettings["Empty"], "nothing to hide")
+	assert.Equal(t, "mqtt-secret", settings["Password"], "the original is left alone")
+}
@@ -0,0 +1,71 @@
+package alpaca
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DeviceHandlerFactory creates the HTTP handler of a device for an API
+// version. It returns nil if the device does not implement the interface of
+// its device type.
+type DeviceHandlerFactory func(dev Device, version int) DeviceHTTPHandler
+
+var (
+	handlerFactoriesMu sync.RWMutex
+	handlerFactories   = make(map[DeviceType]DeviceHandlerFactory)
+)
+
+// RegisterDeviceHandler registers the handler factory of a device type, so a
+// new kind of device is served without changing the server. It is meant to
+// be called from an init function; registering a type again replaces its
+// factory.
+func RegisterDeviceHandler(deviceType DeviceType, factory DeviceHandlerFactory) {
+	handlerFactoriesMu.Lock()
+	defer handlerFactoriesMu.Unlock()
+	handlerFactories[deviceType] = factory
+}
+
+// HasDeviceHandler reports whether a handler factory is registered for the
+// device type.
+func HasDeviceHandler(deviceType DeviceType) bool {
+	handlerFactoriesMu.RLock()
+	defer handlerFactoriesMu.RUnlock()
+	_, ok := handlerFactories[deviceType]
+	return ok
+}
+
+// historySetter is implemented by the handlers embedding DeviceHandler.
+type historySetter interface {
+	setHistory(h *History)
+}
+
+func (h *DeviceHandler) setHistory(history *History) {
+	h.history = history
+}
+
+// newDeviceHTTPHandler creates the HTTP handler for a device and API version
+// with the factory registered for its device type. Devices of a type without
+// factory only get the common device methods.
+func newDeviceHTTPHandler(dev Device, version int, history *History) DeviceHTTPHandler {
+	info := dev.DeviceInfo()
+
+	handlerFactoriesMu.RLock()
+	factory := handlerFactories[info.Type]
+	handlerFactoriesMu.RUnlock()
+
+	var h DeviceHTTPHandler
+	if factory != nil {
+		h = factory(dev, version)
+	}
+	if h == nil {
+		log.Errorf("No %s handler for %s (%T), serving the common device methods only", info.Type, info.Name, dev)
+		return &DeviceHandler{dev: dev, version: version, history: history}
+	}
+
+	log.Infof("Creating new %s handler v%d for %s", info.Type, version, info.Name)
+	if hs, ok := h.(historySetter); ok {
+		hs.setHistory(history)
+	}
+	return h
+}
@@ -0,0 +1,61 @@
+package alpaca
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSafety is a safety monitor without IsSafe, so the built-in handler
+// does not serve it.
+type fakeSafety struct{ fakeConditions }
+
+func (d *fakeSafety) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Safety", Type: DeviceTypeSafety, Number: 0, UniqueID: "fake-safety"}
+}
+
+type safetyHandler struct{ DeviceHandler }
+
+func (h *safetyHandler) RegisterRoutes(mux *http.ServeMux) {
+	h.DeviceHandler.RegisterRoutes(mux)
+	mux.Handle("GET /issafe", handleAPI(func(r *http.Request) (any, error) { return true, nil }))
+}
+
+func TestRegisterDeviceHandler(t *testing.T) {
+	ts := newTestServer(&fakeSafety{})
+	resp, err := http.Get(ts.URL + "/api/v1/safetymonitor/0/issafe?ClientTransactionID=1")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "no handler for the device, only the common methods")
+	assert.Equal(t, "Fake Safety", getJSON(t, ts.URL+"/api/v1/safetymonitor/0/name?ClientTransactionID=1").Value)
+	ts.Close()
+
+	handlerFactoriesMu.RLock()
+	previous := handlerFactories[DeviceTypeSafety]
+	handlerFactoriesMu.RUnlock()
+	RegisterDeviceHandler(DeviceTypeSafety, func(dev Device, version int) DeviceHTTPHandler {
+		return &safetyHandler{DeviceHandler{dev: dev, version: version}}
+	})
+	t.Cleanup(func() { RegisterDeviceHandler(DeviceTypeSafety, previous) })
+
+	ts = newTestServer(&fakeSafety{})
+	defer ts.Close()
+	assert.Equal(t, true, getJSON(t, ts.URL+"/api/v1/safetymonitor/0/issafe?ClientTransactionID=1").Value)
+	assert.Equal(t, "Fake Safety", getJSON(t, ts.URL+"/api/v1/safetymonitor/0/name?ClientTransactionID=1").Value)
+}
+
+// fakeMonitor is a safety monitor that is always safe.
+type fakeMonitor struct{ fakeSafety }
+
+func (d *fakeMonitor) IsSafe() bool { return true }
+
+func TestSafetyMonitorHandler(t *testing.T) {
+	dev := &fakeMonitor{}
+	ts := newTestServer(dev)
+	defer ts.Close()
+
+	assert.Equal(t, false, getJSON(t, ts.URL+"/api/v1/safetymonitor/0/issafe?ClientTransactionID=1").Value, "unsafe while disconnected")
+	dev.connected = true
+	assert.Equal(t, true, getJSON(t, ts.URL+"/api/v1/safetymonitor/0/issafe?ClientTransactionID=1").Value)
+}
@@ -0,0 +1,38 @@
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// restartDevice restarts the driver of the device with a "<type>/<number>"
+// key, see Restarter.
+func (s *Server) restartDevice(r *http.Request, key string) error {
+	for _, dev := range s.devices {
+		info := dev.DeviceInfo()
+		if deviceKey(info) != key {
+			continue
+		}
+		restarter, ok := dev.(Restarter)
+		if !ok {
+			return alpacaerrors.Errorf(alpacaerrors.ErrNotImplemented, "the driver of %s cannot be restarted", info.Name)
+		}
+
+		requestLogger(r).Infof("Restarting the driver of %s", info.Name)
+		return restarter.Restart()
+	}
+	return alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "no device %s", key)
+}
+
+// handleRestartDevice restarts the driver of the device of the DeviceType
+// and DeviceNumber parameters, as in the configured devices.
+func (s *Server) handleRestartDevice(r *http.Request) (any, error) {
+	devType := r.FormValue("DeviceType")
+	number, err := strconv.Atoi(r.FormValue("DeviceNumber"))
+	if devType == "" || err != nil {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "DeviceType and DeviceNumber are required")
+	}
+	return nil, s.restartDevice(r, strings.ToLower(devType)+"/"+strconv.Itoa(number))
+}
@@ -0,0 +1,57 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// restartableDome is a fakeDome whose driver can be restarted.
+type restartableDome struct {
+	fakeDome
+	restarts int
+}
+
+func (d *restartableDome) Restart() error { d.restarts++; return nil }
+
+func TestRestartDevice(t *testing.T) {
+	restart := func(t *testing.T, ts string, form url.Values) baseResponse {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPut, ts+"/management/v1/restartdevice", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var body baseResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body
+	}
+
+	dome := &restartableDome{}
+	ts := newTestServer(dome)
+	defer ts.Close()
+
+	body := restart(t, ts.URL, url.Values{"DeviceType": {"Dome"}, "DeviceNumber": {"0"}})
+	assert.Zero(t, body.ErrorNumber, body.ErrorMessage)
+	assert.Equal(t, 1, dome.restarts)
+
+	body = restart(t, ts.URL, url.Values{"DeviceType": {"Dome"}, "DeviceNumber": {"1"}})
+	assert.NotZero(t, body.ErrorNumber, "unknown device")
+	body = restart(t, ts.URL, url.Values{"DeviceType": {"Dome"}})
+	assert.NotZero(t, body.ErrorNumber, "missing device number")
+	assert.Equal(t, 1, dome.restarts)
+
+	plain := newTestServer(&fakeDome{})
+	defer plain.Close()
+	body = restart(t, plain.URL, url.Values{"DeviceType": {"Dome"}, "DeviceNumber": {"0"}})
+	assert.NotZero(t, body.ErrorNumber, "a driver that cannot be restarted")
+}
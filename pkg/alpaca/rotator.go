@@ -0,0 +1,190 @@
+// Documentation: https://ascom-standards.org/api/#/Rotator%20Specific%20Methods
+
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"net/http"
+)
+
+type RotatorCapabilities struct {
+	CanReverse bool
+	StepSize   float64 // Degrees, 0 if unknown
+}
+
+type RotatorStatus struct {
+	Position           float64 // Sky position angle, in degrees, shifted by the syncs
+	MechanicalPosition float64 // Degrees
+	TargetPosition     float64 // Sky position angle of the last move, in degrees
+	IsMoving           bool
+	Reverse            bool
+}
+
+func (rs RotatorStatus) ToProperties() []StateProperty {
+	return []StateProperty{
+		{"IsMoving", rs.IsMoving},
+		{"MechanicalPosition", rs.MechanicalPosition},
+		{"Position", rs.Position},
+	}
+}
+
+// Rotator turns a camera around the optical axis. The handler checks the
+// capabilities and the ranges of the positions, from 0 to 360 degrees; the
+// moves start and return at once. Move turns by an offset, MoveAbsolute to a
+// sky position and MoveMechanical to a mechanical one.
+type Rotator interface {
+	Device
+
+	Capabilities() RotatorCapabilities
+	Status() RotatorStatus
+
+	SetReverse(bool) error
+	Move(offset float64) error
+	MoveAbsolute(position float64) error
+	MoveMechanical(position float64) error
+	Sync(position float64) error
+	Halt() error
+}
+
+type RotatorHandler struct {
+	DeviceHandler
+	dev Rotator
+}
+
+func NewRotatorHandler(dev Rotator, version int) *RotatorHandler {
+	return &RotatorHandler{
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
+		dev:           dev,
+	}
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeRotator, func(dev Device, version int) DeviceHTTPHandler {
+		if r, ok := dev.(Rotator); ok {
+			return NewRotatorHandler(r, version)
+		}
+		return nil
+	})
+}
+
+func (rh *RotatorHandler) RegisterRoutes(mux *http.ServeMux) {
+	rh.DeviceHandler.RegisterRoutes(mux)
+
+	mux.Handle("GET /canreverse", rh.handleStatic(func(r *http.Request) (any, error) {
+		return rh.dev.Capabilities().CanReverse, nil
+	}))
+	mux.Handle("GET /stepsize", rh.handleStatic(rh.handleStepSize))
+	for _, property := range []string{"position", "mechanicalposition", "targetposition", "ismoving", "reverse"} {
+		mux.Handle("GET /"+property, handleAPI(rh.handleStatus))
+	}
+
+	mux.Handle("PUT /reverse", handleAPI(rh.handleReverse))
+	mux.Handle("PUT /move", handleAPI(rh.handleMove))
+	mux.Handle("PUT /moveabsolute", handleAPI(rh.handleMoveAbsolute))
+	mux.Handle("PUT /movemechanical", handleAPI(rh.handleMoveMechanical))
+	mux.Handle("PUT /sync", handleAPI(rh.handleSync))
+	mux.Handle("PUT /halt", handleAPI(rh.handleHalt))
+}
+
+func (rh *RotatorHandler) handleStepSize(r *http.Request) (any, error) {
+	step := rh.dev.Capabilities().StepSize
+	if step <= 0 {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	return step, nil
+}
+
+func (rh *RotatorHandler) handleStatus(r *http.Request) (any, error) {
+	if !rh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	status := rh.dev.Status()
+
+	property := r.URL.Path[1:]
+	switch property {
+	case "position":
+		return status.Position, nil
+	case "mechanicalposition":
+		return status.MechanicalPosition, nil
+	case "targetposition":
+		return status.TargetPosition, nil
+	case "ismoving":
+		return status.IsMoving, nil
+	case "reverse":
+		if !rh.dev.Capabilities().CanReverse {
+			return nil, alpacaerrors.ErrNotImplemented
+		}
+		return status.Reverse, nil
+	default:
+		return nil, errBadRequest
+	}
+}
+
+func (rh *RotatorHandler) handleReverse(r *http.Request) (any, error) {
+	reverse, err := getBoolParam(r, "Reverse")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if !rh.dev.Capabilities().CanReverse {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if !rh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, rh.dev.SetReverse(reverse)
+}
+
+// position returns the Position parameter of a request, in degrees, from 0
+// to 360 unless it is an offset.
+func (rh *RotatorHandler) position(r *http.Request, offset bool) (float64, error) {
+	position, err := getFloatParam(r, "Position")
+	if err != nil {
+		return 0, errBadRequest
+	}
+	if !offset && (position < 0 || position >= 360) {
+		return 0, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "position %g out of range 0 to 360 degrees", position)
+	}
+	if !rh.dev.Connected() {
+		return 0, alpacaerrors.ErrNotConnected
+	}
+	return position, nil
+}
+
+func (rh *RotatorHandler) handleMove(r *http.Request) (any, error) {
+	offset, err := rh.position(r, true)
+	if err != nil {
+		return nil, err
+	}
+	return nil, rh.dev.Move(offset)
+}
+
+func (rh *RotatorHandler) handleMoveAbsolute(r *http.Request) (any, error) {
+	position, err := rh.position(r, false)
+	if err != nil {
+		return nil, err
+	}
+	return nil, rh.dev.MoveAbsolute(position)
+}
+
+func (rh *RotatorHandler) handleMoveMechanical(r *http.Request) (any, error) {
+	position, err := rh.position(r, false)
+	if err != nil {
+		return nil, err
+	}
+	return nil, rh.dev.MoveMechanical(position)
+}
+
+func (rh *RotatorHandler) handleSync(r *http.Request) (any, error) {
+	position, err := rh.position(r, false)
+	if err != nil {
+		return nil, err
+	}
+	return nil, rh.dev.Sync(position)
+}
+
+func (rh *RotatorHandler) handleHalt(r *http.Request) (any, error) {
+	if !rh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return nil, rh.dev.Halt()
+}
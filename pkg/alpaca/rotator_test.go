@@ -0,0 +1,91 @@
+package alpaca
+
+import (
+	"net/url"
+	"testing"
+
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRotator turns at once, its sky position equal to the mechanical one
+// until it is synced.
+type fakeRotator struct {
+	fakeConditions
+	fixed      bool // cannot reverse
+	mechanical float64
+	offset     float64
+	reverse    bool
+	halted     bool
+}
+
+func (d *fakeRotator) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Rotator", Type: DeviceTypeRotator, Number: 0, UniqueID: "fake-rotator"}
+}
+
+func (d *fakeRotator) Capabilities() RotatorCapabilities {
+	return RotatorCapabilities{CanReverse: !d.fixed}
+}
+
+func (d *fakeRotator) Status() RotatorStatus {
+	return RotatorStatus{Position: d.mechanical + d.offset, MechanicalPosition: d.mechanical, Reverse: d.reverse}
+}
+
+func (d *fakeRotator) SetReverse(on bool) error         { d.reverse = on; return nil }
+func (d *fakeRotator) Move(offset float64) error        { d.mechanical += offset; return nil }
+func (d *fakeRotator) MoveAbsolute(pos float64) error   { d.mechanical = pos - d.offset; return nil }
+func (d *fakeRotator) MoveMechanical(pos float64) error { d.mechanical = pos; return nil }
+func (d *fakeRotator) Sync(pos float64) error           { d.offset = pos - d.mechanical; return nil }
+func (d *fakeRotator) Halt() error                      { d.halted = true; return nil }
+
+func TestRotatorHandler(t *testing.T) {
+	dev := &fakeRotator{mechanical: 90}
+	dev.connected = true
+	ts := newTestServer(dev)
+	defer ts.Close()
+	api := ts.URL + "/api/v1/rotator/0/"
+
+	assert.Equal(t, true, getJSON(t, api+"canreverse?ClientTransactionID=1").Value)
+	assert.Equal(t, 90.0, getJSON(t, api+"position?ClientTransactionID=1").Value)
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, getJSON(t, api+"stepsize?ClientTransactionID=1").ErrorNumber, "unknown step size")
+
+	resp := putForm(t, api+"sync", url.Values{"Position": {"100"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 100.0, getJSON(t, api+"position?ClientTransactionID=1").Value)
+	assert.Equal(t, 90.0, getJSON(t, api+"mechanicalposition?ClientTransactionID=1").Value)
+
+	resp = putForm(t, api+"moveabsolute", url.Values{"Position": {"120"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 110.0, dev.mechanical)
+	resp = putForm(t, api+"move", url.Values{"Position": {"-20"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage, "offsets may be negative")
+	assert.Equal(t, 90.0, dev.mechanical)
+	resp = putForm(t, api+"movemechanical", url.Values{"Position": {"45"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 45.0, dev.mechanical)
+
+	for _, position := range []string{"-1", "360"} {
+		resp = putForm(t, api+"moveabsolute", url.Values{"Position": {position}, "ClientTransactionID": {"1"}})
+		assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber, position)
+	}
+
+	resp = putForm(t, api+"reverse", url.Values{"Reverse": {"true"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, true, getJSON(t, api+"reverse?ClientTransactionID=1").Value)
+
+	resp = putForm(t, api+"halt", url.Values{"ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.True(t, dev.halted)
+
+	dev.fixed = true
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, getJSON(t, api+"reverse?ClientTransactionID=1").ErrorNumber)
+	resp = putForm(t, api+"reverse", url.Values{"Reverse": {"false"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, resp.ErrorNumber)
+
+	dev.connected = false
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, getJSON(t, api+"ismoving?ClientTransactionID=1").ErrorNumber)
+	resp = putForm(t, api+"move", url.Values{"Position": {"10"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, resp.ErrorNumber)
+}
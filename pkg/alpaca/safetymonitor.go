@@ -0,0 +1,52 @@
+// Documentation: https://ascom-standards.org/api/#/SafetyMonitor%20Specific%20Methods
+
+package alpaca
+
+import (
+	"net/http"
+)
+
+// SafetyMonitor tells whether it is safe to observe, so the clients pause
+// imaging and close the dome otherwise.
+type SafetyMonitor interface {
+	Device
+
+	// IsSafe reports whether the monitored conditions are safe.
+	IsSafe() bool
+}
+
+type SafetyMonitorHandler struct {
+	DeviceHandler
+	dev SafetyMonitor
+}
+
+func NewSafetyMonitorHandler(dev SafetyMonitor, version int) *SafetyMonitorHandler {
+	return &SafetyMonitorHandler{
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
+		dev:           dev,
+	}
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeSafety, func(dev Device, version int) DeviceHTTPHandler {
+		if d, ok := dev.(SafetyMonitor); ok {
+			return NewSafetyMonitorHandler(d, version)
+		}
+		return nil
+	})
+}
+
+func (sh *SafetyMonitorHandler) RegisterRoutes(mux *http.ServeMux) {
+	sh.DeviceHandler.RegisterRoutes(mux)
+
+	mux.Handle("GET /issafe", handleAPI(sh.handleIsSafe))
+}
+
+// handleIsSafe returns false while the device is not connected, as the
+// specification requires, rather than a not connected error.
+func (sh *SafetyMonitorHandler) handleIsSafe(r *http.Request) (any, error) {
+	if !sh.dev.Connected() {
+		return false, nil
+	}
+	return sh.dev.IsSafe(), nil
+}
@@ -3,10 +3,19 @@
 package alpaca
 
 import (
+	"alpaca/pkg/metrics"
+	"alpaca/pkg/notify"
+	"alpaca/pkg/version"
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -24,76 +33,173 @@ type Server struct {
 	description ServerDescription
 	devices     []Device
 
-	db   *Store
-	tmpl *template.Template
+	db         ConfigStore
+	tmpl       *template.Template
+	history    *History    // Connection history, nil if not recorded
+	federation *Federation // Peer servers shown on the setup page, nil if none
+	started    time.Time
+	profiling  bool // Go profiler served under /debug/pprof/
+
+	lastEvents lastEvents // Last event of each device, for the widgets
 }
 
 // NewServer creates a new ManagementServer instance.
-func NewServer(description ServerDescription, devices []Device, db *Store, tmpl *template.Template) *Server {
+func NewServer(description ServerDescription, devices []Device, db ConfigStore, tmpl *template.Template) *Server {
 	server := Server{
 		description: description,
 		devices:     devices,
 		db:          db,
 		tmpl:        tmpl,
+		started:     time.Now(),
+	}
+
+	if db != nil {
+		if cfg, err := db.GetConfig(); err == nil {
+			server.applyConfig(cfg)
+		}
 	}
 
 	return &server
 }
 
+// SetHistory records the connections of the devices in the history. It must
+// be called before AddRoutes and SubscribeEvents.
+func (s *Server) SetHistory(h *History) {
+	s.history = h
+}
+
 type DeviceHTTPHandler interface {
 	RegisterRoutes(mux *http.ServeMux)
 }
 
-func (s *Server) AddRoutes() *http.ServeMux {
+// apiVersions lists the Alpaca API versions served by the server. Each
+// version gets its own set of device handlers, so a newer version can change
+// the behavior of an endpoint without affecting clients of the older one.
+var apiVersions = []int{1}
+
+// AddRoutes creates the HTTP handler serving the management API, the device
+// APIs and the setup pages.
+func (s *Server) AddRoutes() http.Handler {
 	r := http.NewServeMux()
 
 	// Add management routes
 	r.Handle("GET /management/apiversions", handleMgm(s.handleAPIVersions))
-	r.Handle("GET /management/v1/description", handleMgm(s.handleDescription))
-	r.Handle("GET /management/v1/configureddevices", handleMgm(s.handleConfiguredDevices))
 	r.HandleFunc("/setup", s.handleSetup)
+	r.HandleFunc("GET /setup/diagnostics", s.handleDiagnostics)
+	r.HandleFunc("GET /status.json", s.handleStatus)
+	r.HandleFunc("GET "+WidgetPrefix+"/status", s.handleWidgetStatus)
+	r.Handle("GET /metrics", metrics.Default().Handler())
+
+	for _, version := range apiVersions {
+		s.addVersionRoutes(r, version)
+	}
+	if s.profiling {
+		addProfilingRoutes(r)
+	}
+
+	return proxyMiddleware(recoverMiddleware(authMiddleware(r)))
+}
+
+// addVersionRoutes registers the management and device routes of a single
+// API version.
+func (s *Server) addVersionRoutes(r *http.ServeMux, version int) {
+	mgmPrefix := fmt.Sprintf("/management/v%d", version)
+	r.Handle("GET "+mgmPrefix+"/description", handleMgm(s.handleDescription))
+	r.Handle("GET "+mgmPrefix+"/configureddevices", handleMgm(s.handleConfiguredDevices))
+	r.Handle("GET "+mgmPrefix+"/serverversion", handleMgm(s.handleServerVersion))
+	r.Handle("GET "+mgmPrefix+"/timeline", handleMgm(s.handleTimeline))
+	r.Handle("GET "+mgmPrefix+"/azimuthhistogram", handleMgm(s.handleAzimuthHistogram))
+	r.Handle("GET "+mgmPrefix+"/slewhistory", handleMgm(s.handleSlewHistory))
+	r.Handle("GET "+mgmPrefix+"/connecthistory", handleMgm(s.handleConnectHistory))
+	r.Handle("GET "+mgmPrefix+"/peers", handleMgm(s.handlePeers))
+	r.Handle("PUT "+mgmPrefix+"/restartdevice", handleMgm(s.handleRestartDevice))
+	r.Handle("POST "+batchPath(version), handleBatch(r, version))
 
 	// Create handlers for each device
 	for _, dev := range s.devices {
-		mux := http.NewServeMux()
-		var handler DeviceHTTPHandler
+		devType := strings.ToLower(dev.DeviceInfo().Type.String())
+		devNumber := dev.DeviceInfo().Number
+		apiPrefix := fmt.Sprintf("/api/v%d/%s/%d", version, devType, devNumber)
+		setupPrefix := fmt.Sprintf("/setup/v%d/%s/%d", version, devType, devNumber)
 
-		switch d := dev.(type) {
-		case Dome:
-			log.Infof("Creating new DomeHandler for %s", dev.DeviceInfo().Name)
-			handler = NewDomeHandler(d)
-			handler.RegisterRoutes(mux)
-		default:
-			log.Errorf("Unknown device type: %T", dev)
-			handler = &DeviceHandler{dev: dev}
-			handler.RegisterRoutes(mux)
+		// The requests for a device of another server are forwarded as they
+		// are, under the number it has on this server.
+		if p, ok := dev.(Proxy); ok {
+			r.Handle(apiPrefix+"/", http.StripPrefix(apiPrefix, enabledOnly(dev, p.ProxyAPI())))
+			r.Handle(setupPrefix+"/", http.StripPrefix(setupPrefix, p.ProxySetup()))
+			continue
 		}
 
-		devType := strings.ToLower(dev.DeviceInfo().Type.String())
-		devNumber := dev.DeviceInfo().Number
+		mux := http.NewServeMux()
+		h := newDeviceHTTPHandler(dev, version, s.history)
+		h.RegisterRoutes(mux)
+		r.Handle(apiPrefix+"/", withDevice(deviceKey(dev.DeviceInfo()), http.StripPrefix(apiPrefix, enabledOnly(dev, invalidateOnPut(h, mux)))))
+
+		// Only the setup page is served under the setup prefix: the device
+		// methods go through the API routes, their scopes and checks.
+		setup := http.NewServeMux()
+		setup.HandleFunc("/setup", dev.HandleSetup)
+		r.Handle(setupPrefix+"/", http.StripPrefix(setupPrefix, setup))
+	}
+}
+
+// Shutdown shuts the devices down, in parallel, before the deadline of the
+// context. It is called once the HTTP server has stopped serving requests.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(s.devices))
 
-		apiPrefix := fmt.Sprintf("/api/v1/%s/%d", devType, devNumber)
-		r.Handle(apiPrefix+"/", http.StripPrefix(apiPrefix, mux))
+	for i, dev := range s.devices {
+		d, ok := dev.(Shutdowner)
+		if !ok {
+			continue
+		}
 
-		setupPrefix := fmt.Sprintf("/setup/v1/%s/%d", devType, devNumber)
-		r.Handle(setupPrefix+"/", http.StripPrefix(setupPrefix, mux))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %v", dev.DeviceInfo().Name, err)
+			}
+		}()
 	}
 
-	return r
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// enabledOnly answers 404 to the requests for a disabled device. The check
+// is done on each request since devices are enabled from their setup page.
+func enabledOnly(dev Device, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !deviceEnabled(dev) {
+			http.Error(w, fmt.Sprintf("%s is disabled", dev.DeviceInfo().Name), http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 func (s *Server) handleAPIVersions(r *http.Request) (any, error) {
-	return []int{1}, nil
+	return apiVersions, nil
 }
 
 func (s *Server) handleDescription(r *http.Request) (any, error) {
 	return s.description, nil
 }
 
+// handleServerVersion returns the build information of the server. This is
+// an extension to the Alpaca management API.
+func (s *Server) handleServerVersion(r *http.Request) (any, error) {
+	return version.Get(), nil
+}
+
 func (s *Server) handleConfiguredDevices(r *http.Request) (any, error) {
 	deviceInfo := make([]DeviceInfo, 0, len(s.devices))
 	for _, device := range s.devices {
-		deviceInfo = append(deviceInfo, device.DeviceInfo())
+		if deviceEnabled(device) {
+			deviceInfo = append(deviceInfo, device.DeviceInfo())
+		}
 	}
 
 	return deviceInfo, nil
@@ -108,21 +214,51 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		s.renderSetupForm(w, cfg, false, "")
+		s.renderSetupForm(w, r, cfg, false, "")
 
 	case http.MethodPost:
+		current, err := s.db.GetConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.FormValue("apikey-action") != "" {
+			s.handleAPIKeyForm(w, r, current)
+			return
+		}
+		if key := r.FormValue("restart-device"); key != "" {
+			if err := s.restartDevice(r, key); err != nil {
+				s.renderSetupForm(w, r, current, false, err.Error())
+				return
+			}
+			s.renderSetupForm(w, r, current, true, "")
+			return
+		}
+		if r.FormValue("diagnostics-action") == "upload" {
+			if err := s.UploadDiagnostics(r.Context(), current.DiagnosticsUploadURL); err != nil {
+				requestLogger(r).Errorf("%v", err)
+				s.renderSetupForm(w, r, current, false, err.Error())
+				return
+			}
+			requestLogger(r).Info("Diagnostics bundle uploaded")
+			s.renderSetupForm(w, r, current, true, "")
+			return
+		}
+
 		cfg, err := parseSetupForm(r)
+		cfg.APIKeys, cfg.OpenScopes = current.APIKeys, current.OpenScopes
 		if err != nil {
-			s.renderSetupForm(w, cfg, false, err.Error())
+			s.renderSetupForm(w, r, cfg, false, err.Error())
 			return
 		}
 
-		log.Infof("Setting config: %+v", cfg)
+		log.Infof("Setting config: %+v", Redact(cfg))
 		if err := s.db.SetConfig(cfg); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		s.renderSetupForm(w, cfg, true, "")
+		s.applyConfig(cfg)
+		s.renderSetupForm(w, r, cfg, true, "")
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -130,12 +266,128 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *Server) renderSetupForm(w http.ResponseWriter, cfg Config, success bool, err string) {
+// applyConfig applies the server configuration to the running server.
+func (s *Server) applyConfig(cfg Config) {
+	SetStrictMode(cfg.StrictMode)
+	SetDeviceTransactionIDs(cfg.DeviceTransactionIDs)
+	SetSlewEstimateInResponse(cfg.SlewEstimate)
+	SetBatchEndpoint(cfg.BatchEndpoint)
+	SetAPIKeys(cfg.APIKeys)
+	SetOpenScopes(cfg.OpenScopes)
+	SetStateCacheTTL(time.Duration(cfg.StateCacheTTL) * time.Millisecond)
+	if err := SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Errorf("Ignoring trusted proxies: %v", err)
+	}
+	if err := notify.Default().Configure(cfg.Notifications); err != nil {
+		log.Errorf("Ignoring notification settings: %v", err)
+	}
+}
+
+// deviceLink is a link to the setup page of a device.
+type deviceLink struct {
+	Name        string
+	URL         string
+	Key         string // "<type>/<number>"
+	Disabled    bool
+	Restartable bool // True if the driver can be restarted from the page
+}
+
+// handleAPIKeyForm creates or deletes an API key, or sets the open scopes,
+// from the setup page. A new key is shown once, only its hash is saved.
+func (s *Server) handleAPIKeyForm(w http.ResponseWriter, r *http.Request, cfg Config) {
+	keys := slices.Clone(cfg.APIKeys)
+	var secret string
+
+	switch r.FormValue("apikey-action") {
+	case "create":
+		var scopes []Scope
+		for _, scope := range r.Form["apikey-scope"] {
+			scopes = append(scopes, Scope(scope))
+		}
+		key, sec, err := NewAPIKey(r.FormValue("apikey-name"), scopes)
+		if err != nil {
+			s.renderSetupForm(w, r, cfg, false, err.Error())
+			return
+		}
+		keys, secret = append(keys, key), sec
+
+	case "delete":
+		name := r.FormValue("apikey-name")
+		keys = slices.DeleteFunc(keys, func(k APIKey) bool { return k.Name == name })
+
+	case "open":
+		var scopes []Scope
+		for _, scope := range r.Form["open-scope"] {
+			scopes = append(scopes, Scope(scope))
+		}
+		if err := ValidateOpenScopes(scopes); err != nil {
+			s.renderSetupForm(w, r, cfg, false, err.Error())
+			return
+		}
+		cfg.OpenScopes = scopes
+		log.Infof("Setting open scopes: %v", scopes)
+
+	default:
+		s.renderSetupForm(w, r, cfg, false, fmt.Sprintf("unknown API key action %q", r.FormValue("apikey-action")))
+		return
+	}
+
+	if err := ValidateAPIKeys(keys); err != nil {
+		s.renderSetupForm(w, r, cfg, false, err.Error())
+		return
+	}
+
+	cfg.APIKeys = keys
+	log.Infof("Setting API keys: %s", apiKeyNames(keys))
+	if err := s.db.SetConfig(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.applyConfig(cfg)
+	s.renderSetupPage(w, r, cfg, true, "", secret)
+}
+
+// apiKeyNames lists the names of the keys for the logs.
+func apiKeyNames(keys []APIKey) string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, key.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (s *Server) renderSetupForm(w http.ResponseWriter, r *http.Request, cfg Config, success bool, err string) {
+	s.renderSetupPage(w, r, cfg, success, err, "")
+}
+
+// renderSetupPage renders the setup page, with the secret of a new API key
+// if one was just created.
+func (s *Server) renderSetupPage(w http.ResponseWriter, r *http.Request, cfg Config, success bool, err string, newAPIKey string) {
+	links := make([]deviceLink, 0, len(s.devices))
+	for _, dev := range s.devices {
+		info := dev.DeviceInfo()
+		_, restartable := dev.(Restarter)
+		links = append(links, deviceLink{
+			Name:        info.Name,
+			URL:         fmt.Sprintf("%s/setup/v1/%s/%d/setup", BaseURL(r), strings.ToLower(info.Type.String()), info.Number),
+			Key:         deviceKey(info),
+			Disabled:    !deviceEnabled(dev),
+			Restartable: restartable,
+		})
+	}
+
 	data := struct {
 		Config
-		Success bool
-		Error   string
-	}{cfg, success, err}
+		Devices     []deviceLink
+		Federation  bool
+		PeerServers []PeerStatus
+		EventTypes  []notify.EventType
+		SinkNames   []string
+		Scopes      []Scope
+		NewAPIKey   string
+		Success     bool
+		Error       string
+	}{cfg, links, s.federation != nil, s.peers(r.Context(), cfg), notify.EventTypes, notify.SinkNames(), Scopes, newAPIKey, success, err}
 
 	if err := s.tmpl.ExecuteTemplate(w, "setup.html", data); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -147,5 +399,120 @@ func parseSetupForm(r *http.Request) (Config, error) {
 		return Config{}, fmt.Errorf("error parsing form: %v", err)
 	}
 
-	return Config{}, nil
+	proxies := splitList(r.FormValue("trusted-proxies"))
+	peers := splitList(r.FormValue("peers"))
+
+	var chatIDs []int64
+	for _, id := range splitList(r.FormValue("telegram-chat-ids")) {
+		chatID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid Telegram chat ID %q", id)
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+
+	smtpPort := 587
+	if port := strings.TrimSpace(r.FormValue("smtp-port")); port != "" {
+		var err error
+		if smtpPort, err = strconv.Atoi(port); err != nil {
+			return Config{}, fmt.Errorf("invalid SMTP port %q", port)
+		}
+	}
+
+	var stateCacheTTL int
+	if ttl := strings.TrimSpace(r.FormValue("state-cache-ttl")); ttl != "" {
+		var err error
+		stateCacheTTL, err = strconv.Atoi(ttl)
+		if err != nil || stateCacheTTL < 0 || time.Duration(stateCacheTTL)*time.Millisecond > MaxStateCacheTTL {
+			return Config{}, fmt.Errorf("invalid state cache TTL %q: must be between 0 and %d ms", ttl, MaxStateCacheTTL.Milliseconds())
+		}
+	}
+
+	devices, err := ParseDeviceList(r.FormValue("devices"))
+	if err != nil {
+		return Config{}, err
+	}
+
+	tlsCert, tlsKey := strings.TrimSpace(r.FormValue("tls-cert")), strings.TrimSpace(r.FormValue("tls-key"))
+	if (tlsCert == "") != (tlsKey == "") {
+		return Config{}, fmt.Errorf("HTTPS needs both a certificate and a key")
+	}
+
+	var redirectPort int
+	if port := strings.TrimSpace(r.FormValue("http-redirect-port")); port != "" {
+		if redirectPort, err = strconv.Atoi(port); err != nil || redirectPort < 0 || redirectPort > 65535 {
+			return Config{}, fmt.Errorf("invalid HTTP redirect port %q", port)
+		}
+	}
+
+	uploadURL := strings.TrimSpace(r.FormValue("diagnostics-upload-url"))
+	if uploadURL != "" && !strings.HasPrefix(uploadURL, "https://") {
+		return Config{}, fmt.Errorf("the diagnostics upload URL must use HTTPS, the bundle holds the configuration")
+	}
+
+	cfg := Config{
+		Devices:          devices,
+		StrictMode:       r.FormValue("strict-mode") == "true",
+		StateCacheTTL:    stateCacheTTL,
+		SlewEstimate:     r.FormValue("slew-estimate") == "true",
+		BatchEndpoint:    r.FormValue("batch-endpoint") == "true",
+		TrustedProxies:   proxies,
+		TLSCert:          tlsCert,
+		TLSKey:           tlsKey,
+		HTTPRedirectPort: redirectPort,
+		Peers:            peers,
+		DiscoverPeers:    r.FormValue("discover-peers") == "true",
+
+		DeviceTransactionIDs: r.FormValue("device-transaction-ids") == "true",
+		DiagnosticsUploadURL: uploadURL,
+
+		Notifications: notify.Config{
+			WebhookURL:   strings.TrimSpace(r.FormValue("webhook-url")),
+			MQTTBroker:   strings.TrimSpace(r.FormValue("notify-mqtt-broker")),
+			MQTTUsername: r.FormValue("notify-mqtt-username"),
+			MQTTPassword: r.FormValue("notify-mqtt-password"),
+			MQTTTopic:    strings.TrimSpace(r.FormValue("notify-mqtt-topic")),
+			SMTPHost:     strings.TrimSpace(r.FormValue("smtp-host")),
+			SMTPPort:     smtpPort,
+			SMTPSecurity: r.FormValue("smtp-security"),
+			SMTPUsername: r.FormValue("smtp-username"),
+			SMTPPassword: r.FormValue("smtp-password"),
+			SMTPFrom:     strings.TrimSpace(r.FormValue("smtp-from")),
+			SMTPTo:       splitList(r.FormValue("smtp-to")),
+
+			TelegramToken:   strings.TrimSpace(r.FormValue("telegram-token")),
+			TelegramChatIDs: chatIDs,
+
+			Routes: make(map[notify.EventType][]string),
+		},
+	}
+
+	// Routes are sent as one checkbox per event type and sink, with the
+	// value "<event>:<sink>".
+	for _, route := range r.Form["route"] {
+		event, sink, ok := strings.Cut(route, ":")
+		if !ok {
+			return cfg, fmt.Errorf("invalid route %q", route)
+		}
+		cfg.Notifications.Routes[notify.EventType(event)] = append(cfg.Notifications.Routes[notify.EventType(event)], sink)
+	}
+
+	if _, err := ParseTrustedProxies(cfg.TrustedProxies); err != nil {
+		return cfg, err
+	}
+	if err := ValidatePeers(cfg.Peers); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Notifications.Validate(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// splitList splits a form value listing items separated by commas, spaces or
+// new lines.
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(c rune) bool {
+		return c == ',' || c == '\n' || c == '\r' || c == ' '
+	})
 }
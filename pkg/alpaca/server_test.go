@@ -0,0 +1,552 @@
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"alpaca/pkg/timeline"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDome is a minimal Dome implementation used to exercise the handlers.
+type fakeDome struct {
+	connected bool
+	status    DomeStatus
+}
+
+func (d *fakeDome) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Dome", Type: DeviceTypeDome, Number: 0, UniqueID: "fake"}
+}
+func (d *fakeDome) DriverInfo() DriverInfo {
+	return DriverInfo{Name: "Fake", Version: "1.0", InterfaceVersion: 2}
+}
+func (d *fakeDome) GetState() []StateProperty                      { return d.status.ToProperties() }
+func (d *fakeDome) Connected() bool                                { return d.connected }
+func (d *fakeDome) Connecting() bool                               { return false }
+func (d *fakeDome) Connect() error                                 { d.connected = true; return nil }
+func (d *fakeDome) Disconnect() error                              { d.connected = false; return nil }
+func (d *fakeDome) HandleSetup(http.ResponseWriter, *http.Request) {}
+func (d *fakeDome) Capabilities() DomeCapabilities                 { return DomeCapabilities{CanSetAzimuth: true} }
+func (d *fakeDome) Status() DomeStatus                             { return d.status }
+func (d *fakeDome) SetSlaved(bool) error                           { return nil }
+func (d *fakeDome) SlewToAltitude(float64) error                   { return alpacaerrors.ErrNotImplemented }
+func (d *fakeDome) SlewToAzimuth(az float64) error                 { d.status.Azimuth = az; return nil }
+func (d *fakeDome) SyncToAzimuth(az float64) error                 { d.status.Azimuth = az; return nil }
+func (d *fakeDome) AbortSlew() error                               { return nil }
+func (d *fakeDome) FindHome() error                                { return nil }
+func (d *fakeDome) Park() error                                    { return nil }
+func (d *fakeDome) SetPark() error                                 { return nil }
+func (d *fakeDome) SetShutter(ShutterCommand) error                { return nil }
+
+func newTestServer(devices ...Device) *httptest.Server {
+	server := NewServer(ServerDescription{Name: "Test"}, devices, nil, nil)
+	return httptest.NewServer(server.AddRoutes())
+}
+
+func getJSON(t *testing.T, url string) baseResponse {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body baseResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+func TestAPIVersions(t *testing.T) {
+	ts := newTestServer(&fakeDome{})
+	defer ts.Close()
+
+	body := getJSON(t, ts.URL+"/management/apiversions")
+	assert.Equal(t, []any{1.0}, body.Value)
+
+	for _, version := range apiVersions {
+		body := getJSON(t, ts.URL+fmt.Sprintf("/api/v%d/dome/0/name?ClientTransactionID=1", version))
+		assert.Equal(t, "Fake Dome", body.Value)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/v9/dome/0/name?ClientTransactionID=1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// actionDome is a fakeDome that supports custom actions.
+type actionDome struct {
+	fakeDome
+}
+
+func (d *actionDome) SupportedActions() []string {
+	return []string{"Calibrate"}
+}
+
+func (d *actionDome) Action(name, parameters string) (string, error) {
+	return name + ":" + parameters, nil
+}
+
+func TestSupportedActions(t *testing.T) {
+	tests := []struct {
+		name string
+		dev  Device
+		want []any
+	}{
+		{"Without actions", &fakeDome{}, []any{}},
+		{"With actions", &actionDome{}, []any{"Calibrate"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(tc.dev)
+			defer ts.Close()
+
+			body := getJSON(t, ts.URL+"/api/v1/dome/0/supportedactions?ClientTransactionID=1")
+			assert.Equal(t, tc.want, body.Value)
+		})
+	}
+}
+
+func putForm(t *testing.T, url string, form url.Values) baseResponse {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var body baseResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return body
+}
+
+func TestSetSlavedWithoutCanSlave(t *testing.T) {
+	ts := newTestServer(&fakeDome{})
+	defer ts.Close()
+
+	body := putForm(t, ts.URL+"/api/v1/dome/0/slaved", url.Values{"Slaved": {"true"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, body.ErrorNumber)
+
+	body = putForm(t, ts.URL+"/api/v1/dome/0/slaved", url.Values{"Slaved": {"false"}, "ClientTransactionID": {"2"}})
+	assert.Zero(t, body.ErrorNumber)
+}
+
+func TestTimeline(t *testing.T) {
+	ts := newTestServer(&fakeDome{})
+	defer ts.Close()
+
+	putForm(t, ts.URL+"/api/v1/dome/0/slewtoazimuth", url.Values{"Azimuth": {"120"}, "ClientTransactionID": {"7"}})
+
+	resp, err := http.Get(ts.URL + "/management/v1/timeline?limit=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body struct {
+		Value timelinePage
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Len(t, body.Value.Entries, 1)
+
+	entry := body.Value.Entries[0]
+	assert.Equal(t, timeline.KindCommand, entry.Kind)
+	assert.Equal(t, "dome/0", entry.Device)
+	assert.Equal(t, "slewtoazimuth Azimuth=120", entry.Summary)
+	assert.Equal(t, entry.ID, body.Value.Next)
+}
+
+func TestAction(t *testing.T) {
+	ts := newTestServer(&actionDome{})
+	defer ts.Close()
+
+	body := putForm(t, ts.URL+"/api/v1/dome/0/action", url.Values{"Action": {"calibrate"}, "Parameters": {"fast"}, "ClientTransactionID": {"1"}})
+	assert.Zero(t, body.ErrorNumber)
+	assert.Equal(t, "Calibrate:fast", body.Value)
+
+	body = putForm(t, ts.URL+"/api/v1/dome/0/action", url.Values{"Action": {"Explode"}, "Parameters": {""}, "ClientTransactionID": {"2"}})
+	assert.Equal(t, alpacaerrors.ErrActionNotImplemented.Number, body.ErrorNumber)
+}
+
+// commandDome is a fakeDome that passes commands through.
+type commandDome struct {
+	fakeDome
+	sent []string
+}
+
+func (d *commandDome) Command(command string, raw bool) (string, error) {
+	d.sent = append(d.sent, fmt.Sprintf("%s raw=%v", command, raw))
+	switch command {
+	case "V":
+		return "2.3", nil
+	case "S":
+		return "false", nil
+	default:
+		return "", nil
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dev := &commandDome{}
+	ts := newTestServer(dev)
+	defer ts.Close()
+
+	body := putForm(t, ts.URL+"/api/v1/dome/0/commandstring", url.Values{"Command": {"V"}, "Raw": {"false"}, "ClientTransactionID": {"1"}})
+	assert.Zero(t, body.ErrorNumber)
+	assert.Equal(t, "2.3", body.Value)
+
+	body = putForm(t, ts.URL+"/api/v1/dome/0/commandbool", url.Values{"Command": {"S"}, "ClientTransactionID": {"2"}})
+	assert.Equal(t, false, body.Value)
+	body = putForm(t, ts.URL+"/api/v1/dome/0/commandbool", url.Values{"Command": {"A"}, "ClientTransactionID": {"3"}})
+	assert.Equal(t, true, body.Value, "acknowledged without a value")
+	body = putForm(t, ts.URL+"/api/v1/dome/0/commandbool", url.Values{"Command": {"V"}, "ClientTransactionID": {"4"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, body.ErrorNumber)
+
+	body = putForm(t, ts.URL+"/api/v1/dome/0/commandblind", url.Values{"Command": {"_H;"}, "Raw": {"true"}, "ClientTransactionID": {"5"}})
+	assert.Zero(t, body.ErrorNumber)
+	assert.Nil(t, body.Value)
+	assert.Equal(t, []string{"V raw=false", "S raw=false", "A raw=false", "V raw=false", "_H; raw=true"}, dev.sent)
+
+	ts2 := newTestServer(&fakeDome{})
+	defer ts2.Close()
+	body = putForm(t, ts2.URL+"/api/v1/dome/0/commandstring", url.Values{"Command": {"V"}, "Raw": {"false"}, "ClientTransactionID": {"6"}})
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, body.ErrorNumber)
+}
+
+// estimatingDome is a dome slewing at 10 degrees per second.
+type estimatingDome struct {
+	fakeDome
+}
+
+func (d *estimatingDome) EstimateSlew(az float64) time.Duration {
+	return time.Duration(math.Abs(az-d.status.Azimuth) / 10 * float64(time.Second))
+}
+func (d *estimatingDome) SlewTimeRemaining() time.Duration { return 0 }
+
+func TestSlewEstimateInResponse(t *testing.T) {
+	dev := &estimatingDome{fakeDome{connected: true, status: DomeStatus{Azimuth: 100}}}
+	ts := newTestServer(dev)
+	defer ts.Close()
+
+	body := putForm(t, ts.URL+"/api/v1/dome/0/slewtoazimuth", url.Values{"Azimuth": {"120"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, true, body.Value, "disabled by default")
+
+	SetSlewEstimateInResponse(true)
+	t.Cleanup(func() { SetSlewEstimateInResponse(false) })
+	body = putForm(t, ts.URL+"/api/v1/dome/0/slewtoazimuth", url.Values{"Azimuth": {"155"}, "ClientTransactionID": {"2"}})
+	require.Zero(t, body.ErrorNumber, body.ErrorMessage)
+	assert.Equal(t, 3.5, body.Value, "from the azimuth before the slew")
+	assert.Equal(t, 155.0, dev.status.Azimuth)
+
+	// Domes without an estimate keep answering true.
+	plain := newTestServer(&fakeDome{connected: true})
+	defer plain.Close()
+	body = putForm(t, plain.URL+"/api/v1/dome/0/slewtoazimuth", url.Values{"Azimuth": {"155"}, "ClientTransactionID": {"3"}})
+	assert.Equal(t, true, body.Value)
+}
+
+func TestDeviceStateProperties(t *testing.T) {
+	dev := &fakeDome{connected: true, status: DomeStatus{Azimuth: 120, Shutter: ShutterClosed}}
+	ts := newTestServer(dev)
+	defer ts.Close()
+
+	names := func(value any) []string {
+		var names []string
+		for _, prop := range value.([]any) {
+			names = append(names, prop.(map[string]any)["Name"].(string))
+		}
+		return names
+	}
+
+	resp := getJSON(t, ts.URL+"/api/v1/dome/0/devicestate?ClientTransactionID=1")
+	assert.Len(t, names(resp.Value), len(dev.status.ToProperties()))
+
+	resp = getJSON(t, ts.URL+"/api/v1/dome/0/devicestate?ClientTransactionID=1&properties=azimuth,%20ShutterStatus")
+	assert.Equal(t, []string{"Azimuth", "ShutterStatus"}, names(resp.Value))
+
+	resp = getJSON(t, ts.URL+"/api/v1/dome/0/devicestate?ClientTransactionID=1&Properties=Nothing")
+	assert.Empty(t, resp.Value)
+}
+
+// disabledDome is a dome that can be disabled.
+type disabledDome struct {
+	fakeDome
+	disabled bool
+}
+
+func (d *disabledDome) Disabled() bool { return d.disabled }
+
+func TestDisabledDevice(t *testing.T) {
+	dev := &disabledDome{disabled: true}
+	ts := newTestServer(dev)
+	defer ts.Close()
+
+	resp := getJSON(t, ts.URL+"/management/v1/configureddevices")
+	assert.Empty(t, resp.Value)
+
+	api, err := http.Get(ts.URL + "/api/v1/dome/0/azimuth?ClientTransactionID=1")
+	require.NoError(t, err)
+	api.Body.Close()
+	assert.Equal(t, http.StatusNotFound, api.StatusCode)
+
+	setup, err := http.Get(ts.URL + "/setup/v1/dome/0/setup")
+	require.NoError(t, err)
+	setup.Body.Close()
+	assert.Equal(t, http.StatusOK, setup.StatusCode, "the setup page stays reachable")
+
+	// The device methods are not served under the setup prefix, which
+	// would bypass the enabled check.
+	for _, method := range []string{"azimuth", "openshutter"} {
+		req, err := http.NewRequest(http.MethodPut, ts.URL+"/setup/v1/dome/0/"+method, strings.NewReader("ClientTransactionID=1"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		cmd, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		cmd.Body.Close()
+		assert.Equal(t, http.StatusNotFound, cmd.StatusCode, method)
+	}
+
+	dev.disabled = false
+	resp = getJSON(t, ts.URL+"/management/v1/configureddevices")
+	assert.Len(t, resp.Value, 1)
+	getJSON(t, ts.URL+"/api/v1/dome/0/azimuth?ClientTransactionID=1")
+}
+
+// stoppingDome is a dome that blocks in Shutdown until released or timed out.
+type stoppingDome struct {
+	fakeDome
+	release chan struct{}
+	stopped bool
+}
+
+func (d *stoppingDome) Shutdown(ctx context.Context) error {
+	select {
+	case <-d.release:
+		d.stopped = true
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	dev := &stoppingDome{release: make(chan struct{})}
+	server := NewServer(ServerDescription{Name: "Test"}, []Device{dev, &fakeDome{}}, nil, nil)
+
+	close(dev.release)
+	require.NoError(t, server.Shutdown(context.Background()))
+	assert.True(t, dev.stopped)
+
+	dev = &stoppingDome{release: make(chan struct{})}
+	server = NewServer(ServerDescription{Name: "Test"}, []Device{dev}, nil, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	require.Error(t, err)
+	assert.Equal(t, "Fake Dome: context deadline exceeded", err.Error())
+}
+
+// histogramDome is a dome that records an azimuth histogram.
+type histogramDome struct {
+	fakeDome
+}
+
+func (d *histogramDome) AzimuthHistogram() []AzimuthBin {
+	return []AzimuthBin{{From: 0, To: 10, Seconds: 6, Passes: 3, MeanPass: 2}}
+}
+
+func TestAzimuthHistogram(t *testing.T) {
+	ts := newTestServer(&histogramDome{})
+	defer ts.Close()
+
+	resp := getJSON(t, ts.URL+"/management/v1/azimuthhistogram")
+	assert.Equal(t, []any{map[string]any{
+		"Device": "dome/0",
+		"Name":   "Fake Dome",
+		"Bins": []any{map[string]any{
+			"From": 0.0, "To": 10.0, "Seconds": 6.0, "Passes": 3.0, "MeanPass": 2.0, "Slow": false,
+		}},
+	}}, resp.Value)
+}
+
+func TestPublicStatus(t *testing.T) {
+	dome := &fakeDome{status: DomeStatus{Azimuth: 123.4, Shutter: ShutterOpening, Slewing: true}}
+	ts := newTestServer(dome)
+	defer ts.Close()
+
+	get := func() publicStatus {
+		resp, err := http.Get(ts.URL + "/status.json")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+
+		var status publicStatus
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		return status
+	}
+
+	status := get()
+	assert.Equal(t, "Test", status.Server)
+	require.Len(t, status.Devices, 1)
+	assert.Equal(t, deviceStatus{Device: "dome/0", Name: "Fake Dome"}, status.Devices[0])
+
+	dome.connected = true
+	status = get()
+	assert.Equal(t, &publicDomeStatus{Azimuth: 123.4, Shutter: "Opening", Slewing: true}, status.Devices[0].Dome)
+
+	resp, err := http.Post(ts.URL+"/status.json", "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, "read-only")
+}
+
+// batteryDome is a fakeDome with a battery powered shutter.
+type batteryDome struct {
+	fakeDome
+	volts float64
+}
+
+func (d *batteryDome) Capabilities() DomeCapabilities {
+	return DomeCapabilities{CanSetAzimuth: true, CanSetShutter: true}
+}
+func (d *batteryDome) BatteryVoltage() (float64, bool) { return d.volts, d.volts > 0 }
+
+func TestWidgetStatus(t *testing.T) {
+	dome := &batteryDome{fakeDome: fakeDome{connected: true, status: DomeStatus{Azimuth: 123.44, Shutter: ShutterOpen}}, volts: 12.63}
+	server := NewServer(ServerDescription{Name: "Test"}, []Device{dome}, nil, nil)
+	ts := httptest.NewServer(server.AddRoutes())
+	defer ts.Close()
+
+	get := func(etag string) (*http.Response, widgetStatus) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+WidgetPrefix+"/status", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var status widgetStatus
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		}
+		return resp, status
+	}
+
+	server.lastEvents.handleEvent(Event{Type: EventConnected, Time: time.Unix(1700000000, 0), Device: "Fake Dome", Message: "Connected"})
+	server.lastEvents.handleEvent(Event{Type: EventStateChanged, Device: "Fake Dome"})
+
+	resp, status := get("")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	require.Len(t, status.Devices, 1)
+	dev := status.Devices[0]
+	assert.Equal(t, 123.4, *dev.Azimuth)
+	assert.Equal(t, "Open", dev.Shutter)
+	assert.Equal(t, 12.6, *dev.Battery)
+	assert.Equal(t, &widgetEvent{Time: 1700000000, Type: "connected", Message: "Connected"}, dev.LastEvent)
+
+	resp, _ = get(etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Equal(t, etag, resp.Header.Get("ETag"))
+
+	dome.volts = 12.61 // Telemetry noise
+	resp, _ = get("W/" + etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	dome.status.Shutter = ShutterClosing
+	resp, status = get(etag)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+	assert.Equal(t, "Closing", status.Devices[0].Shutter)
+
+	server.lastEvents.handleEvent(Event{Type: EventError, Device: "Fake Dome", Message: "Shutter stuck", Notification: "shutter_error"})
+	_, status = get("")
+	assert.Equal(t, "shutter_error", status.Devices[0].LastEvent.Type)
+}
+
+// fakeConditions is a minimal ObservingConditions implementation with a
+// temperature sensor only.
+type fakeConditions struct {
+	connected bool
+	average   float64
+}
+
+func (d *fakeConditions) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Weather", Type: DeviceTypeConditions, Number: 0, UniqueID: "fake-weather"}
+}
+func (d *fakeConditions) DriverInfo() DriverInfo {
+	return DriverInfo{Name: "Fake", Version: "1.0", InterfaceVersion: 2}
+}
+func (d *fakeConditions) GetState() []StateProperty                      { return nil }
+func (d *fakeConditions) Connected() bool                                { return d.connected }
+func (d *fakeConditions) Connecting() bool                               { return false }
+func (d *fakeConditions) Connect() error                                 { d.connected = true; return nil }
+func (d *fakeConditions) Disconnect() error                              { d.connected = false; return nil }
+func (d *fakeConditions) HandleSetup(http.ResponseWriter, *http.Request) {}
+func (d *fakeConditions) Sensor(name string) (float64, error) {
+	if name != SensorTemperature {
+		return 0, alpacaerrors.ErrNotImplemented
+	}
+	return 12.5, nil
+}
+func (d *fakeConditions) SensorDescription(name string) (string, error) {
+	return name + " sensor", nil
+}
+func (d *fakeConditions) TimeSinceLastUpdate(string) (time.Duration, error) {
+	return 3 * time.Second, nil
+}
+func (d *fakeConditions) AveragePeriod() float64               { return d.average }
+func (d *fakeConditions) SetAveragePeriod(hours float64) error { d.average = hours; return nil }
+func (d *fakeConditions) Refresh() error                       { return nil }
+
+func TestObservingConditions(t *testing.T) {
+	dev := &fakeConditions{}
+	ts := newTestServer(dev)
+	defer ts.Close()
+	base := ts.URL + "/api/v1/observingconditions/0/"
+
+	body := getJSON(t, base+"temperature?ClientTransactionID=1")
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, body.ErrorNumber)
+
+	dev.connected = true
+	body = getJSON(t, base+"temperature?ClientTransactionID=1")
+	assert.Zero(t, body.ErrorNumber)
+	assert.Equal(t, 12.5, body.Value)
+	body = getJSON(t, base+"rainrate?ClientTransactionID=1")
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, body.ErrorNumber)
+
+	body = getJSON(t, base+"sensordescription?sensorname=temperature&ClientTransactionID=1")
+	assert.Equal(t, "Temperature sensor", body.Value, "the sensor name is matched in any case")
+	body = getJSON(t, base+"sensordescription?SensorName=Sunshine&ClientTransactionID=1")
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, body.ErrorNumber)
+	body = getJSON(t, base+"timesincelastupdate?SensorName=&ClientTransactionID=1")
+	assert.Equal(t, 3.0, body.Value, "an empty name is the latest update of any sensor")
+
+	body = putForm(t, base+"averageperiod", url.Values{"AveragePeriod": {"-1"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, body.ErrorNumber)
+	body = putForm(t, base+"averageperiod", url.Values{"AveragePeriod": {"0.5"}, "ClientTransactionID": {"2"}})
+	assert.Zero(t, body.ErrorNumber)
+	assert.Equal(t, 0.5, dev.average)
+}
+
+func TestDewPoint(t *testing.T) {
+	assert.InDelta(t, 10.0, DewPoint(10, 100), 0.01)
+	assert.InDelta(t, 4.8, DewPoint(12, 61), 0.1)
+}
@@ -0,0 +1,118 @@
+package alpaca
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultSlewHistoryLimit is the number of slews listed when the request
+// does not set one. The statistics cover all the slews kept by the device.
+const defaultSlewHistoryLimit = 50
+
+// SlewEntry is a slew of a dome with the azimuth commanded and the one
+// reached.
+type SlewEntry struct {
+	Time     time.Time `json:"Time"`     // When the slew was commanded
+	From     float64   `json:"From"`     // Azimuth at the start, in degrees
+	Target   float64   `json:"Target"`   // Commanded azimuth, in degrees
+	Achieved float64   `json:"Achieved"` // Azimuth where the dome stopped, in degrees
+	Offset   float64   `json:"Offset"`   // Achieved minus commanded azimuth, in degrees
+	Duration float64   `json:"Duration"` // Time until the dome stopped, in seconds
+}
+
+// SlewStats sums up the accuracy of the slews of a dome.
+type SlewStats struct {
+	Count         int     `json:"Count"`
+	MeanOffset    float64 `json:"MeanOffset"`    // Mean signed offset, a bias of the positioning, in degrees
+	MeanAbsOffset float64 `json:"MeanAbsOffset"` // Mean absolute offset, the accuracy, in degrees
+	MaxAbsOffset  float64 `json:"MaxAbsOffset"`  // Largest absolute offset, in degrees
+	Repeatability float64 `json:"Repeatability"` // Standard deviation of the offset, in degrees
+	MeanSpeed     float64 `json:"MeanSpeed"`     // Mean of the distance over the duration of the slews, in degrees per second
+}
+
+// SlewHistorian is implemented by domes that record the commanded and the
+// achieved azimuth of their slews, to tune the tolerance and the speeds.
+type SlewHistorian interface {
+	SlewHistory() []SlewEntry // Oldest first
+}
+
+// SlewAccuracy returns the statistics of the slews.
+func SlewAccuracy(entries []SlewEntry) SlewStats {
+	stats := SlewStats{Count: len(entries)}
+	if len(entries) == 0 {
+		return stats
+	}
+
+	var speeds int
+	for _, e := range entries {
+		stats.MeanOffset += e.Offset
+		stats.MeanAbsOffset += math.Abs(e.Offset)
+		stats.MaxAbsOffset = max(stats.MaxAbsOffset, math.Abs(e.Offset))
+		if distance := math.Abs(math.Mod(e.Achieved-e.From+540, 360) - 180); e.Duration > 0 && distance > 0 {
+			stats.MeanSpeed += distance / e.Duration
+			speeds++
+		}
+	}
+	n := float64(len(entries))
+	stats.MeanOffset /= n
+	stats.MeanAbsOffset /= n
+	if speeds > 0 {
+		stats.MeanSpeed /= float64(speeds)
+	}
+
+	var variance float64
+	for _, e := range entries {
+		variance += (e.Offset - stats.MeanOffset) * (e.Offset - stats.MeanOffset)
+	}
+	stats.Repeatability = math.Sqrt(variance / n)
+	return stats
+}
+
+// deviceSlews is the slew history of a device in the slewhistory response.
+type deviceSlews struct {
+	Device string      `json:"Device"` // "<type>/<number>", as in the timeline
+	Name   string      `json:"Name"`
+	Stats  SlewStats   `json:"Stats"`
+	Slews  []SlewEntry `json:"Slews"` // Newest first
+}
+
+// handleSlewHistory returns the latest slews of the enabled domes that
+// record them, with the statistics of all the slews they kept. Use limit to
+// set the number of slews listed. This is an extension to the Alpaca
+// management API.
+func (s *Server) handleSlewHistory(r *http.Request) (any, error) {
+	limit := defaultSlewHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid limit %q", v)
+		}
+		limit = n
+	}
+
+	histories := []deviceSlews{}
+	for _, dev := range s.devices {
+		h, ok := dev.(SlewHistorian)
+		if !ok || !deviceEnabled(dev) {
+			continue
+		}
+
+		entries := h.SlewHistory()
+		slews := []SlewEntry{}
+		for i := len(entries) - 1; i >= 0 && len(slews) < limit; i-- {
+			slews = append(slews, entries[i])
+		}
+
+		info := dev.DeviceInfo()
+		histories = append(histories, deviceSlews{
+			Device: deviceKey(info),
+			Name:   info.Name,
+			Stats:  SlewAccuracy(entries),
+			Slews:  slews,
+		})
+	}
+	return histories, nil
+}
@@ -0,0 +1,59 @@
+package alpaca
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slewDome is a dome that records its slews.
+type slewDome struct {
+	fakeDome
+	slews []SlewEntry
+}
+
+func (d *slewDome) SlewHistory() []SlewEntry {
+	return d.slews
+}
+
+func TestSlewAccuracy(t *testing.T) {
+	assert.Equal(t, SlewStats{}, SlewAccuracy(nil))
+
+	stats := SlewAccuracy([]SlewEntry{
+		{From: 350, Target: 10, Achieved: 10.5, Offset: 0.5, Duration: 2},
+		{From: 10, Target: 100, Achieved: 99.5, Offset: -0.5, Duration: 9},
+		{From: 100, Target: 101, Achieved: 102, Offset: 1.0, Duration: 0},
+	})
+	assert.Equal(t, 3, stats.Count)
+	assert.InDelta(t, 1.0/3, stats.MeanOffset, 1e-9)
+	assert.InDelta(t, 2.0/3, stats.MeanAbsOffset, 1e-9)
+	assert.Equal(t, 1.0, stats.MaxAbsOffset)
+	assert.InDelta(t, 0.6236, stats.Repeatability, 1e-4)
+	assert.InDelta(t, (20.5/2+89.5/9)/2, stats.MeanSpeed, 1e-9, "across north, without the slews of no duration")
+}
+
+func TestSlewHistory(t *testing.T) {
+	now := time.Now().UTC()
+	dev := &slewDome{slews: []SlewEntry{
+		{Time: now, Target: 10, Achieved: 10.5, Offset: 0.5, Duration: 2},
+		{Time: now.Add(time.Minute), Target: 20, Achieved: 19.5, Offset: -0.5, Duration: 2},
+	}}
+	ts := newTestServer(dev)
+	defer ts.Close()
+
+	resp := getJSON(t, ts.URL+"/management/v1/slewhistory?limit=1")
+	histories, ok := resp.Value.([]any)
+	require.True(t, ok)
+	require.Len(t, histories, 1)
+	history := histories[0].(map[string]any)
+	assert.Equal(t, "dome/0", history["Device"])
+	assert.Equal(t, 2.0, history["Stats"].(map[string]any)["Count"], "the statistics cover all the slews")
+	slews := history["Slews"].([]any)
+	require.Len(t, slews, 1)
+	assert.Equal(t, 20.0, slews[0].(map[string]any)["Target"], "newest first")
+
+	resp = getJSON(t, ts.URL+"/management/v1/slewhistory?limit=x")
+	assert.NotZero(t, resp.ErrorNumber)
+}
@@ -0,0 +1,80 @@
+package alpaca
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Retries of the connections on startup. After a power cut, the broker or
+// the network of a device may come up after the server.
+var (
+	startupRetryInterval = 30 * time.Second
+	startupRetryPeriod   = 10 * time.Minute
+)
+
+// StartupConnector is implemented by devices that can be connected when the
+// server starts, without waiting for a client to connect them, so they are
+// back after a power cut.
+type StartupConnector interface {
+	ConnectOnStartup() bool
+}
+
+// ConnectOnStartup connects the enabled devices set to connect on startup,
+// in the background. A device that fails to connect is retried for a while;
+// the connections started by a client meanwhile are left alone.
+func ConnectOnStartup(ctx context.Context, devices []Device) {
+	for _, dev := range devices {
+		sc, ok := dev.(StartupConnector)
+		if !ok || !sc.ConnectOnStartup() || !deviceEnabled(dev) {
+			continue
+		}
+		go connectOnStartup(ctx, dev, startupRetryInterval, startupRetryPeriod, log.WithField("device", dev.DeviceInfo().Name))
+	}
+}
+
+// connectOnStartup connects a device, retrying at interval for period.
+func connectOnStartup(ctx context.Context, dev Device, interval, period time.Duration, logger log.FieldLogger) {
+	deadline := time.Now().Add(period)
+	for {
+		if dev.Connected() || dev.Connecting() {
+			return
+		}
+
+		logger.Info("Connecting on startup")
+		if err := dev.Connect(); err != nil {
+			logger.Warnf("Failed to connect on startup: %v", err)
+		}
+		// Connect may return before the device is connected.
+		for dev.Connecting() {
+			if !sleepContext(ctx, time.Second) {
+				return
+			}
+		}
+		if dev.Connected() {
+			return
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			logger.Errorf("Giving up connecting on startup after %s", period)
+			return
+		}
+		if !sleepContext(ctx, interval) {
+			return
+		}
+	}
+}
+
+// sleepContext waits for a duration, and reports false if the context was
+// cancelled first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
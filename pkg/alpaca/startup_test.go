@@ -0,0 +1,61 @@
+package alpaca
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startupDome connects on startup, after failing a number of attempts.
+type startupDome struct {
+	fakeDome
+	onStartup bool
+	disabled  bool
+	failures  int32
+	attempts  atomic.Int32
+	up        atomic.Bool
+}
+
+func (d *startupDome) ConnectOnStartup() bool { return d.onStartup }
+func (d *startupDome) Disabled() bool         { return d.disabled }
+func (d *startupDome) Connected() bool        { return d.up.Load() }
+
+func (d *startupDome) Connect() error {
+	if d.attempts.Add(1) <= d.failures {
+		return fmt.Errorf("broker unreachable")
+	}
+	d.up.Store(true)
+	return nil
+}
+
+func TestConnectOnStartup(t *testing.T) {
+	interval, period := startupRetryInterval, startupRetryPeriod
+	startupRetryInterval, startupRetryPeriod = time.Millisecond, 50*time.Millisecond
+	t.Cleanup(func() { startupRetryInterval, startupRetryPeriod = interval, period })
+
+	retried := &startupDome{onStartup: true, failures: 2}
+	never := &startupDome{onStartup: true, failures: 1 << 30}
+	manual := &startupDome{}
+	disabled := &startupDome{onStartup: true, disabled: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ConnectOnStartup(ctx, []Device{retried, never, manual, disabled})
+
+	assert.Eventually(t, retried.up.Load, time.Second, time.Millisecond)
+	assert.EqualValues(t, 3, retried.attempts.Load())
+	assert.Eventually(t, func() bool { return never.attempts.Load() > 1 }, time.Second, time.Millisecond, "retried")
+	assert.False(t, never.up.Load())
+	assert.Zero(t, manual.attempts.Load(), "not set to connect on startup")
+	assert.Zero(t, disabled.attempts.Load(), "disabled")
+
+	// The retries stop after the retry period.
+	time.Sleep(100 * time.Millisecond)
+	attempts := never.attempts.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, attempts, never.attempts.Load())
+}
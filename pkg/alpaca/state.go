@@ -0,0 +1,84 @@
+package alpaca
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stateCacheTTL is how long the device state is reused by the DeviceState
+// requests, zero to compute it on every request.
+var stateCacheTTL atomic.Int64
+
+// MaxStateCacheTTL is the longest state cache TTL accepted.
+const MaxStateCacheTTL = 10 * time.Second
+
+// SetStateCacheTTL sets how long the device state is reused by the
+// DeviceState requests, so clients polling aggressively do not recompute it
+// on every request.
+func SetStateCacheTTL(ttl time.Duration) {
+	stateCacheTTL.Store(int64(ttl))
+}
+
+// StateTimeStamp returns the TimeStamp property of a device state: when its
+// values were received from the device, rather than when they were asked, so
+// the clients can detect stale data.
+func StateTimeStamp(t time.Time) StateProperty {
+	return StateProperty{Name: "TimeStamp", Value: t.Format(time.RFC3339Nano)}
+}
+
+// stateCache keeps the last state of a device for the cache TTL.
+type stateCache struct {
+	mu    sync.Mutex
+	state []StateProperty
+	at    time.Time // When the state was computed, zero if invalidated
+}
+
+// get returns the cached state, computing it with getState once expired.
+func (c *stateCache) get(getState func() []StateProperty, now time.Time) []StateProperty {
+	ttl := time.Duration(stateCacheTTL.Load())
+	if ttl <= 0 {
+		return getState()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.at.IsZero() || now.Sub(c.at) >= ttl || now.Before(c.at) {
+		c.state, c.at = getState(), now
+	}
+	return c.state
+}
+
+// invalidate drops the cached state, so the effect of a command shows in the
+// next DeviceState request.
+func (c *stateCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state, c.at = nil, time.Time{}
+}
+
+// stateInvalidator is implemented by the handlers embedding DeviceHandler.
+type stateInvalidator interface {
+	invalidateState()
+}
+
+func (h *DeviceHandler) invalidateState() {
+	h.state.invalidate()
+}
+
+// invalidateOnPut invalidates the cached state of a device after each command.
+func invalidateOnPut(h DeviceHTTPHandler, next http.Handler) http.Handler {
+	si, ok := h.(stateInvalidator)
+	if !ok {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if r.Method == http.MethodPut {
+			si.invalidateState()
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package alpaca
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingDome counts the computations of its state.
+type countingDome struct {
+	fakeDome
+	calls atomic.Int32
+}
+
+func (d *countingDome) GetState() []StateProperty {
+	d.calls.Add(1)
+	return append([]StateProperty{StateTimeStamp(time.Now())}, d.fakeDome.GetState()...)
+}
+
+func TestStateCache(t *testing.T) {
+	var c stateCache
+	calls := 0
+	getState := func() []StateProperty { calls++; return nil }
+	now := time.Now()
+
+	SetStateCacheTTL(0)
+	c.get(getState, now)
+	c.get(getState, now)
+	assert.Equal(t, 2, calls, "no caching without TTL")
+
+	SetStateCacheTTL(time.Second)
+	t.Cleanup(func() { SetStateCacheTTL(0) })
+	calls = 0
+	c.get(getState, now)
+	c.get(getState, now.Add(500*time.Millisecond))
+	assert.Equal(t, 1, calls)
+	c.get(getState, now.Add(time.Second))
+	assert.Equal(t, 2, calls, "expired")
+	c.invalidate()
+	c.get(getState, now.Add(time.Second))
+	assert.Equal(t, 3, calls, "invalidated")
+}
+
+func TestDeviceStateCachedUntilCommand(t *testing.T) {
+	SetStateCacheTTL(time.Minute)
+	t.Cleanup(func() { SetStateCacheTTL(0) })
+
+	dome := &countingDome{}
+	ts := newTestServer(dome)
+	defer ts.Close()
+
+	getJSON(t, ts.URL+"/api/v1/dome/0/devicestate?ClientTransactionID=1")
+	getJSON(t, ts.URL+"/api/v1/dome/0/devicestate?ClientTransactionID=2&Properties=Azimuth")
+	assert.EqualValues(t, 1, dome.calls.Load())
+
+	req, err := http.NewRequest(http.MethodPut, ts.URL+"/api/v1/dome/0/slewtoazimuth",
+		strings.NewReader("Azimuth=90&ClientTransactionID=3"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	body := getJSON(t, ts.URL+"/api/v1/dome/0/devicestate?ClientTransactionID=4&Properties=Azimuth")
+	assert.EqualValues(t, 2, dome.calls.Load(), "the command refreshed the state")
+	assert.Contains(t, body.Value, map[string]any{"Name": "Azimuth", "Value": 90.0})
+}
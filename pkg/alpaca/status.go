@@ -0,0 +1,84 @@
+package alpaca
+
+import (
+	"alpaca/pkg/version"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// publicStatus is the summary of the server and devices returned by
+// /status.json.
+type publicStatus struct {
+	Server   string         `json:"Server"`
+	Location string         `json:"Location"`
+	Version  string         `json:"Version"`
+	Time     time.Time      `json:"Time"`
+	Uptime   float64        `json:"Uptime"` // Seconds since the server started
+	Devices  []deviceStatus `json:"Devices"`
+}
+
+// deviceStatus is the state of a device in the public status. Only the
+// properties safe to publish are included: no client, address or setting.
+type deviceStatus struct {
+	Device    string            `json:"Device"` // "<type>/<number>", as in the timeline
+	Name      string            `json:"Name"`
+	Connected bool              `json:"Connected"`
+	Dome      *publicDomeStatus `json:"Dome,omitempty"` // State of a connected dome
+}
+
+type publicDomeStatus struct {
+	Azimuth float64 `json:"Azimuth"`
+	Shutter string  `json:"Shutter"`
+	Slewing bool    `json:"Slewing"`
+	AtPark  bool    `json:"AtPark"`
+	AtHome  bool    `json:"AtHome"`
+	Slaved  bool    `json:"Slaved"`
+}
+
+// handleStatus serves /status.json, a read-only summary of the server and
+// devices for a public observatory webpage. It reads the cached device state
+// only, never sends a command, and may be fetched from any origin.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := publicStatus{
+		Server:   s.description.Name,
+		Location: s.description.Location,
+		Version:  version.Get().Version,
+		Time:     time.Now().UTC(),
+		Uptime:   time.Since(s.started).Round(time.Second).Seconds(),
+		Devices:  []deviceStatus{},
+	}
+
+	for _, dev := range s.devices {
+		if !deviceEnabled(dev) {
+			continue
+		}
+		info := dev.DeviceInfo()
+		st := deviceStatus{
+			Device:    deviceKey(info),
+			Name:      info.Name,
+			Connected: dev.Connected(),
+		}
+		if d, ok := dev.(Dome); ok && st.Connected {
+			ds := d.Status()
+			st.Dome = &publicDomeStatus{
+				Azimuth: ds.Azimuth,
+				Shutter: ds.Shutter.String(),
+				Slewing: ds.Slewing,
+				AtPark:  ds.AtPark,
+				AtHome:  ds.AtHome,
+				Slaved:  ds.Slaved,
+			}
+		}
+		status.Devices = append(status.Devices, st)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Debugf("Failed to write the status: %v", err)
+	}
+}
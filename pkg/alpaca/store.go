@@ -1,8 +1,14 @@
 package alpaca
 
 import (
+	"alpaca/pkg/notify"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	bolt "go.etcd.io/bbolt"
@@ -11,9 +17,161 @@ import (
 const (
 	bucket    = "alpaca"
 	configKey = "server_config"
+
+	defaultStateCacheTTL = 250 // milliseconds
 )
 
-type Config struct{}
+type Config struct {
+	StrictMode     bool     `json:"strict_mode"`     // Reject requests that deviate from the Alpaca specification
+	TrustedProxies []string `json:"trusted_proxies"` // Reverse proxies whose X-Forwarded-* headers are honored
+	StateCacheTTL  int      `json:"state_cache_ttl"` // Milliseconds the DeviceState of a device is reused, 0 to disable
+	SlewEstimate   bool     `json:"slew_estimate"`   // Return the estimated slew duration from PUT slewtoazimuth
+	BatchEndpoint  bool     `json:"batch_endpoint"`  // Serve the non-standard batch endpoint reading several properties at once
+
+	DeviceTransactionIDs bool `json:"device_transaction_ids"` // Log a transaction counter of each device with its requests
+
+	TLSCert          string `json:"tls_cert"`           // Path of the PEM certificate served over HTTPS, empty for plain HTTP
+	TLSKey           string `json:"tls_key"`            // Path of the PEM private key of the certificate
+	HTTPRedirectPort int    `json:"http_redirect_port"` // Plain HTTP port redirecting to HTTPS, 0 for none
+
+	Peers         []string `json:"peers"`          // Base URLs of the peer servers shown on the setup page
+	DiscoverPeers bool     `json:"discover_peers"` // Also show the servers found by Alpaca discovery
+
+	Notifications notify.Config `json:"notifications"` // Notification sinks and routes
+
+	DiagnosticsUploadURL string `json:"diagnostics_upload_url"` // HTTPS URL the diagnostics bundle can be sent to for support, empty for none
+
+	APIKeys    []APIKey `json:"api_keys"`    // Keys required by the API and the setup pages, none on a trusted network
+	OpenScopes []Scope  `json:"open_scopes"` // Scopes granted without a key once keys exist, e.g. read
+
+	Devices []DeviceConfig `json:"devices"` // Devices created at startup, the driver defaults if empty
+}
+
+// OpenScope reports whether the scope is granted without a key.
+func (c Config) OpenScope(scope Scope) bool {
+	return slices.Contains(c.OpenScopes, scope)
+}
+
+// CommandsOpen reports whether anyone can move the domes and their shutters
+// without a key: without keys, or with both control scopes open.
+func (c Config) CommandsOpen() bool {
+	return len(c.APIKeys) == 0 || (c.OpenScope(ScopeRotation) && c.OpenScope(ScopeShutter))
+}
+
+// DeviceConfig describes a device instance served by the server. The devices
+// are created from this list at startup, so adding a second dome is a
+// configuration change.
+type DeviceConfig struct {
+	Driver   string `json:"driver"`              // Driver name, e.g. "zro"
+	Number   int    `json:"number"`              // Device number in the API paths
+	Key      string `json:"key,omitempty"`       // Database key of the instance settings, empty for the driver default
+	UniqueID string `json:"unique_id,omitempty"` // UniqueID reported to the clients, derived from the key if empty
+
+	// Settings of the instance, in the JSON layout of its stored
+	// configuration, applied at every startup. They cannot be set from the
+	// device list of the setup page, only from a device file.
+	Settings json.RawMessage `json:"settings,omitempty"`
+}
+
+// String formats the device as a line of the device list of the setup page.
+func (c DeviceConfig) String() string {
+	fields := []string{c.Driver, strconv.Itoa(c.Number)}
+	if c.Key != "" || c.UniqueID != "" {
+		fields = append(fields, c.Key)
+	}
+	if c.UniqueID != "" {
+		fields = append(fields, c.UniqueID)
+	}
+	return strings.Join(fields, " ")
+}
+
+// ParseDeviceList parses a device list with one device per line, as
+// "<driver> <number> [<key> [<unique id>]]". Empty lines and lines starting
+// with # are ignored.
+func ParseDeviceList(text string) ([]DeviceConfig, error) {
+	var devices []DeviceConfig
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 4 {
+			return nil, fmt.Errorf("invalid device %q, expected <driver> <number> [<key> [<unique id>]]", strings.TrimSpace(line))
+		}
+
+		number, err := strconv.Atoi(fields[1])
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid device number %q", fields[1])
+		}
+
+		dev := DeviceConfig{Driver: fields[0], Number: number}
+		if len(fields) > 2 {
+			dev.Key = fields[2]
+		}
+		if len(fields) > 3 {
+			dev.UniqueID = fields[3]
+		}
+		devices = append(devices, dev)
+	}
+	return devices, nil
+}
+
+// ReadDeviceFile reads a device file, a JSON array of devices with their
+// settings, e.g. [{"driver": "zro", "number": 1, "settings": {"Host": "broker"}}].
+func ReadDeviceFile(path string) ([]DeviceConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []DeviceConfig
+	if err := json.Unmarshal(data, &devices); err != nil {
+		return nil, fmt.Errorf("invalid device file %s: %v", path, err)
+	}
+	for _, dev := range devices {
+		if dev.Driver == "" || dev.Number < 0 {
+			return nil, fmt.Errorf("invalid device %q number %d in %s", dev.Driver, dev.Number, path)
+		}
+	}
+	return devices, nil
+}
+
+// MergeSettings merges JSON settings into a configuration: the fields they
+// set replace those of cfg, the others are kept. Unknown fields are rejected,
+// so a misspelled setting is not silently ignored. It reports whether cfg
+// changed.
+func MergeSettings[T any](cfg *T, settings json.RawMessage) (bool, error) {
+	before, err := json.Marshal(cfg)
+	if err != nil {
+		return false, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(settings))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return false, fmt.Errorf("invalid settings: %v", err)
+	}
+
+	after, err := json.Marshal(cfg)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(before, after), nil
+}
+
+func DefaultConfig() Config {
+	return Config{
+		StateCacheTTL: defaultStateCacheTTL,
+		Notifications: notify.DefaultConfig(),
+	}
+}
+
+// ConfigStore loads and saves the server configuration. Store implements it
+// on the database.
+type ConfigStore interface {
+	GetConfig() (Config, error)
+	SetConfig(cfg Config) error
+}
 
 type Store struct {
 	db *bolt.DB
@@ -31,7 +189,7 @@ func NewStore(db *bolt.DB) (*Store, error) {
 func (s *Store) setDefaults() error {
 	if _, err := s.GetConfig(); err != nil {
 		log.Infof("Setting default config")
-		return s.SetConfig(Config{})
+		return s.SetConfig(DefaultConfig())
 	}
 
 	return nil
@@ -39,7 +197,7 @@ func (s *Store) setDefaults() error {
 
 // SetConfig saves the configuration as a json string in the database.
 func (s *Store) SetConfig(cfg Config) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
 		if err != nil {
 			return err
@@ -48,6 +206,31 @@ func (s *Store) SetConfig(cfg Config) error {
 		value, _ := json.Marshal(cfg)
 		return b.Put([]byte(configKey), value)
 	})
+	if err != nil {
+		return err
+	}
+
+	if err := BackupDB(s.db); err != nil {
+		log.Warnf("Failed to back up database: %v", err)
+	}
+	return nil
+}
+
+// Settings returns every value stored in the settings bucket, by key: the
+// server configuration and those of the drivers.
+func (s *Store) Settings() (map[string]json.RawMessage, error) {
+	settings := make(map[string]json.RawMessage)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			settings[string(k)] = json.RawMessage(bytes.Clone(v))
+			return nil
+		})
+	})
+	return settings, err
 }
 
 // GetConfig retrieves the configuration from the database.
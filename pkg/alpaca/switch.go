@@ -0,0 +1,230 @@
+// Documentation: https://ascom-standards.org/api/#/Switch%20Specific%20Methods
+
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"math"
+	"net/http"
+)
+
+// SwitchChannel describes a switch of a Switch device. A boolean switch has
+// a minimum of 0, a maximum of 1 and a step of 1.
+type SwitchChannel struct {
+	Name        string
+	Description string
+	CanWrite    bool
+	Min         float64
+	Max         float64
+	Step        float64
+}
+
+// Switch is a set of switches, boolean or multi-valued, such as relays or
+// read-only gauges. The switches are numbered from 0 in the order of
+// Switches; the handler checks the numbers and the values against it.
+type Switch interface {
+	Device
+
+	Switches() []SwitchChannel
+	SwitchValue(id int) (float64, error)
+	SetSwitchValue(id int, value float64) error
+	SetSwitchName(id int, name string) error
+}
+
+type SwitchHandler struct {
+	DeviceHandler
+	dev Switch
+}
+
+func NewSwitchHandler(dev Switch, version int) *SwitchHandler {
+	return &SwitchHandler{
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
+		dev:           dev,
+	}
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeSwitch, func(dev Device, version int) DeviceHTTPHandler {
+		if d, ok := dev.(Switch); ok {
+			return NewSwitchHandler(d, version)
+		}
+		return nil
+	})
+}
+
+func (sh *SwitchHandler) RegisterRoutes(mux *http.ServeMux) {
+	sh.DeviceHandler.RegisterRoutes(mux)
+
+	mux.Handle("GET /maxswitch", sh.handleStatic(sh.handleMaxSwitch))
+	mux.Handle("GET /canwrite", handleAPI(sh.handleCanWrite))
+	mux.Handle("GET /getswitch", handleAPI(sh.handleGetSwitch))
+	mux.Handle("GET /getswitchdescription", handleAPI(sh.handleGetSwitchDescription))
+	mux.Handle("GET /getswitchname", handleAPI(sh.handleGetSwitchName))
+	mux.Handle("GET /getswitchvalue", handleAPI(sh.handleGetSwitchValue))
+	mux.Handle("GET /minswitchvalue", handleAPI(sh.handleMinSwitchValue))
+	mux.Handle("GET /maxswitchvalue", handleAPI(sh.handleMaxSwitchValue))
+	mux.Handle("GET /switchstep", handleAPI(sh.handleSwitchStep))
+	mux.Handle("PUT /setswitch", handleAPI(sh.handleSetSwitch))
+	mux.Handle("PUT /setswitchname", handleAPI(sh.handleSetSwitchName))
+	mux.Handle("PUT /setswitchvalue", handleAPI(sh.handleSetSwitchValue))
+}
+
+// channel returns the number and the description of the switch named by the
+// Id parameter, matched in any case for the GET requests.
+func (sh *SwitchHandler) channel(r *http.Request) (int, SwitchChannel, error) {
+	id, err := getUintParam(r, "Id", r.Method == http.MethodGet)
+	if err != nil {
+		return 0, SwitchChannel{}, errBadRequest
+	}
+	channels := sh.dev.Switches()
+	if int(id) >= len(channels) {
+		return 0, SwitchChannel{}, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "switch %d out of range 0 to %d", id, len(channels)-1)
+	}
+	return int(id), channels[id], nil
+}
+
+func (sh *SwitchHandler) handleMaxSwitch(r *http.Request) (any, error) {
+	return len(sh.dev.Switches()), nil
+}
+
+func (sh *SwitchHandler) handleCanWrite(r *http.Request) (any, error) {
+	_, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	return ch.CanWrite, nil
+}
+
+// handleGetSwitch returns false at the minimum value of the switch, and true
+// otherwise.
+func (sh *SwitchHandler) handleGetSwitch(r *http.Request) (any, error) {
+	id, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	if !sh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	value, err := sh.dev.SwitchValue(id)
+	if err != nil {
+		return nil, err
+	}
+	return value != ch.Min, nil
+}
+
+func (sh *SwitchHandler) handleGetSwitchDescription(r *http.Request) (any, error) {
+	_, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	return ch.Description, nil
+}
+
+func (sh *SwitchHandler) handleGetSwitchName(r *http.Request) (any, error) {
+	_, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	return ch.Name, nil
+}
+
+func (sh *SwitchHandler) handleGetSwitchValue(r *http.Request) (any, error) {
+	id, _, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	if !sh.dev.Connected() {
+		return nil, alpacaerrors.ErrNotConnected
+	}
+	return sh.dev.SwitchValue(id)
+}
+
+func (sh *SwitchHandler) handleMinSwitchValue(r *http.Request) (any, error) {
+	_, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	return ch.Min, nil
+}
+
+func (sh *SwitchHandler) handleMaxSwitchValue(r *http.Request) (any, error) {
+	_, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	return ch.Max, nil
+}
+
+func (sh *SwitchHandler) handleSwitchStep(r *http.Request) (any, error) {
+	_, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	return ch.Step, nil
+}
+
+// handleSetSwitch sets the switch to its maximum value for true and to its
+// minimum value for false.
+func (sh *SwitchHandler) handleSetSwitch(r *http.Request) (any, error) {
+	state, err := getBoolParam(r, "State")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	id, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	value := ch.Min
+	if state {
+		value = ch.Max
+	}
+	return nil, sh.setValue(id, ch, value)
+}
+
+func (sh *SwitchHandler) handleSetSwitchName(r *http.Request) (any, error) {
+	name, err := getParam(r, "Name", false)
+	if err != nil {
+		return nil, errBadRequest
+	}
+	id, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	if !ch.CanWrite {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrNotImplemented, "switch %d is read-only", id)
+	}
+	return nil, sh.dev.SetSwitchName(id, name)
+}
+
+func (sh *SwitchHandler) handleSetSwitchValue(r *http.Request) (any, error) {
+	value, err := getFloatParam(r, "Value")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	id, ch, err := sh.channel(r)
+	if err != nil {
+		return nil, err
+	}
+	if value < ch.Min || value > ch.Max || (ch.Step > 0 && !onStep(value-ch.Min, ch.Step)) {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "value %g of switch %d out of range %g to %g by %g", value, id, ch.Min, ch.Max, ch.Step)
+	}
+	return nil, sh.setValue(id, ch, value)
+}
+
+// setValue sets a writable switch of a connected device.
+func (sh *SwitchHandler) setValue(id int, ch SwitchChannel, value float64) error {
+	if !ch.CanWrite {
+		return alpacaerrors.Errorf(alpacaerrors.ErrNotImplemented, "switch %d is read-only", id)
+	}
+	if !sh.dev.Connected() {
+		return alpacaerrors.ErrNotConnected
+	}
+	return sh.dev.SetSwitchValue(id, value)
+}
+
+// onStep reports whether an offset is a whole number of steps, within the
+// rounding of the clients.
+func onStep(offset, step float64) bool {
+	n := offset / step
+	return math.Abs(n-math.Round(n)) < 1e-6
+}
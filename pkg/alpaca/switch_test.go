@@ -0,0 +1,80 @@
+package alpaca
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSwitch has a read-only gauge and a writable dimmer.
+type fakeSwitch struct {
+	fakeConditions
+	values [2]float64
+	name   string
+}
+
+func (d *fakeSwitch) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Switch", Type: DeviceTypeSwitch, Number: 0, UniqueID: "fake-switch"}
+}
+
+func (d *fakeSwitch) Switches() []SwitchChannel {
+	return []SwitchChannel{
+		{Name: "Voltage", Min: 0, Max: 20, Step: 0.1},
+		{Name: d.name, CanWrite: true, Min: 0, Max: 10, Step: 2.5},
+	}
+}
+func (d *fakeSwitch) SwitchValue(id int) (float64, error)        { return d.values[id], nil }
+func (d *fakeSwitch) SetSwitchValue(id int, value float64) error { d.values[id] = value; return nil }
+func (d *fakeSwitch) SetSwitchName(id int, name string) error    { d.name = name; return nil }
+
+func TestSwitchHandler(t *testing.T) {
+	dev := &fakeSwitch{values: [2]float64{12.6, 0}, name: "Dimmer"}
+	dev.connected = true
+	ts := newTestServer(dev)
+	defer ts.Close()
+	api := ts.URL + "/api/v1/switch/0/"
+
+	assert.Equal(t, 2.0, getJSON(t, api+"maxswitch?ClientTransactionID=1").Value)
+	assert.Equal(t, false, getJSON(t, api+"canwrite?Id=0&ClientTransactionID=1").Value)
+	assert.Equal(t, "Dimmer", getJSON(t, api+"getswitchname?id=1&ClientTransactionID=1").Value, "GET parameters in any case")
+	assert.Equal(t, 12.6, getJSON(t, api+"getswitchvalue?Id=0&ClientTransactionID=1").Value)
+	assert.Equal(t, false, getJSON(t, api+"getswitch?Id=1&ClientTransactionID=1").Value)
+	assert.Equal(t, 2.5, getJSON(t, api+"switchstep?Id=1&ClientTransactionID=1").Value)
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, getJSON(t, api+"getswitchname?Id=2&ClientTransactionID=1").ErrorNumber)
+
+	resp := putForm(t, api+"setswitch", url.Values{"Id": {"1"}, "State": {"true"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 10.0, dev.values[1], "true is the maximum")
+	assert.Equal(t, true, getJSON(t, api+"getswitch?Id=1&ClientTransactionID=1").Value)
+
+	resp = putForm(t, api+"setswitchvalue", url.Values{"Id": {"1"}, "Value": {"7.5"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 7.5, dev.values[1])
+	resp = putForm(t, api+"setswitchvalue", url.Values{"Id": {"1"}, "Value": {"3"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber, "not on a step")
+	resp = putForm(t, api+"setswitchvalue", url.Values{"Id": {"1"}, "Value": {"12.5"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, resp.ErrorNumber, "out of range")
+
+	resp = putForm(t, api+"setswitch", url.Values{"Id": {"0"}, "State": {"false"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, resp.ErrorNumber, "read-only")
+	assert.Equal(t, 12.6, dev.values[0])
+
+	resp = putForm(t, api+"setswitchname", url.Values{"Id": {"1"}, "Name": {"Flat panel"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, "Flat panel", getJSON(t, api+"getswitchname?Id=1&ClientTransactionID=1").Value)
+
+	dev.connected = false
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, getJSON(t, api+"getswitchvalue?Id=0&ClientTransactionID=1").ErrorNumber)
+	resp = putForm(t, api+"setswitch", url.Values{"Id": {"1"}, "State": {"false"}, "ClientTransactionID": {"1"}})
+	assert.Equal(t, alpacaerrors.ErrNotConnected.Number, resp.ErrorNumber)
+
+	r, err := http.Get(api + "setswitch")
+	require.NoError(t, err)
+	r.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, r.StatusCode)
+}
@@ -0,0 +1,62 @@
+package alpaca
+
+import (
+	"alpaca/pkg/notify"
+	"alpaca/pkg/syslog"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SyslogPriority returns the priority of an event in the syslog or the
+// journal: the aborted motions are critical, the failures errors, the other
+// notifications warnings, the connections notices and the state changes
+// informational.
+func SyslogPriority(e Event) syslog.Priority {
+	switch e.Notification {
+	case notify.EventRunawaySlew, notify.EventShutterOvercurrent:
+		return syslog.Crit
+	case notify.EventShutterError:
+		return syslog.Err
+	case "":
+	default:
+		return syslog.Warning
+	}
+
+	switch e.Type {
+	case EventError:
+		return syslog.Err
+	case EventConnected, EventDisconnected:
+		return syslog.Notice
+	default:
+		return syslog.Info
+	}
+}
+
+// SyslogHandler returns the event bus handler writing the events to w, with
+// the device, the event type and the notification as structured fields.
+// The failed writes are logged, the first one and then every hundredth.
+func SyslogHandler(w syslog.Writer, logger log.FieldLogger) func(Event) {
+	var failed atomic.Int64
+	return func(e Event) {
+		fields := map[string]string{
+			"ALPACA_DEVICE": e.Device,
+			"ALPACA_EVENT":  string(e.Type),
+		}
+		if e.Notification != "" {
+			fields["ALPACA_NOTIFICATION"] = string(e.Notification)
+		}
+
+		err := w.Write(syslog.Record{
+			Time:     e.Time,
+			Priority: SyslogPriority(e),
+			Message:  e.Message,
+			Fields:   fields,
+		})
+		if err != nil {
+			if n := failed.Add(1); n == 1 || n%100 == 0 {
+				logger.Warnf("Failed to write event to syslog, %d failures: %v", n, err)
+			}
+		}
+	}
+}
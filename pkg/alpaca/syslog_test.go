@@ -0,0 +1,45 @@
+package alpaca
+
+import (
+	"alpaca/pkg/notify"
+	"alpaca/pkg/syslog"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordWriter struct {
+	records []syslog.Record
+}
+
+func (w *recordWriter) Write(r syslog.Record) error { w.records = append(w.records, r); return nil }
+func (w *recordWriter) Close() error                { return nil }
+
+func TestSyslogPriority(t *testing.T) {
+	assert.Equal(t, syslog.Crit, SyslogPriority(Event{Type: EventError, Notification: notify.EventRunawaySlew}))
+	assert.Equal(t, syslog.Err, SyslogPriority(Event{Type: EventError}))
+	assert.Equal(t, syslog.Warning, SyslogPriority(Event{Type: EventDisconnected, Notification: notify.EventConnectionLost}))
+	assert.Equal(t, syslog.Notice, SyslogPriority(Event{Type: EventDisconnected}))
+	assert.Equal(t, syslog.Info, SyslogPriority(Event{Type: EventStateChanged}))
+}
+
+func TestSyslogHandler(t *testing.T) {
+	var w recordWriter
+	SyslogHandler(&w, log.New())(Event{
+		Type:         EventDisconnected,
+		Device:       "ZRO Dome",
+		Message:      "Lost connection",
+		Notification: notify.EventConnectionLost,
+	})
+
+	require.Len(t, w.records, 1)
+	assert.Equal(t, syslog.Warning, w.records[0].Priority)
+	assert.Equal(t, "Lost connection", w.records[0].Message)
+	assert.Equal(t, map[string]string{
+		"ALPACA_DEVICE":       "ZRO Dome",
+		"ALPACA_EVENT":        "disconnected",
+		"ALPACA_NOTIFICATION": "connection_lost",
+	}, w.records[0].Fields)
+}
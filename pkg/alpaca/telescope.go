@@ -0,0 +1,862 @@
+// Documentation: https://ascom-standards.org/api/#/Telescope%20Specific%20Methods
+
+package alpaca
+
+import (
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+type AlignmentMode int
+
+const (
+	AlignmentAltAz AlignmentMode = iota
+	AlignmentPolar
+	AlignmentGermanPolar
+)
+
+type EquatorialSystem int
+
+const (
+	EquatorialOther EquatorialSystem = iota
+	EquatorialTopocentric
+	EquatorialJ2000
+	EquatorialJ2050
+	EquatorialB1950
+)
+
+type PierSide int
+
+const (
+	PierUnknown PierSide = -1
+	PierEast    PierSide = 0
+	PierWest    PierSide = 1
+)
+
+func (p PierSide) String() string {
+	switch p {
+	case PierEast:
+		return "East"
+	case PierWest:
+		return "West"
+	default:
+		return "Unknown"
+	}
+}
+
+type DriveRate int
+
+const (
+	DriveSidereal DriveRate = iota
+	DriveLunar
+	DriveSolar
+	DriveKing
+)
+
+type TelescopeAxis int
+
+const (
+	AxisPrimary TelescopeAxis = iota
+	AxisSecondary
+	AxisTertiary
+)
+
+type GuideDirection int
+
+const (
+	GuideNorth GuideDirection = iota
+	GuideSouth
+	GuideEast
+	GuideWest
+)
+
+// AxisRate is a range of MoveAxis rates, in degrees per second.
+type AxisRate struct {
+	Minimum float64 `json:"Minimum"`
+	Maximum float64 `json:"Maximum"`
+}
+
+type TelescopeCapabilities struct {
+	AlignmentMode    AlignmentMode
+	EquatorialSystem EquatorialSystem
+	ApertureArea     float64 // Square meters
+	ApertureDiameter float64 // Meters
+	FocalLength      float64 // Meters
+
+	CanFindHome              bool
+	CanPark                  bool
+	CanPulseGuide            bool
+	CanSetDeclinationRate    bool
+	CanSetGuideRates         bool
+	CanSetPark               bool
+	CanSetPierSide           bool
+	CanSetRightAscensionRate bool
+	CanSetTracking           bool
+	CanSlew                  bool
+	CanSlewAltAz             bool
+	CanSlewAltAzAsync        bool
+	CanSlewAsync             bool
+	CanSync                  bool
+	CanSyncAltAz             bool
+	CanUnpark                bool
+
+	// AxisRates are the MoveAxis rates of the primary, secondary and
+	// tertiary axes, none for an axis that cannot be moved.
+	AxisRates     [3][]AxisRate
+	TrackingRates []DriveRate
+}
+
+// CanMoveAxis reports whether an axis can be moved with MoveAxis.
+func (c TelescopeCapabilities) CanMoveAxis(axis TelescopeAxis) bool {
+	return axis >= AxisPrimary && axis <= AxisTertiary && len(c.AxisRates[axis]) > 0
+}
+
+type TelescopeStatus struct {
+	Altitude       float64 // Degrees
+	Azimuth        float64 // Degrees
+	RightAscension float64 // Hours, in the EquatorialSystem
+	Declination    float64 // Degrees, in the EquatorialSystem
+	SiderealTime   float64 // Local apparent sidereal time, in hours
+	AtHome         bool
+	AtPark         bool
+	Slewing        bool
+	Tracking       bool
+	IsPulseGuiding bool
+	SideOfPier     PierSide
+	TrackingRate   DriveRate
+	UTCDate        time.Time
+}
+
+func (ts TelescopeStatus) ToProperties() []StateProperty {
+	return []StateProperty{
+		{"Altitude", ts.Altitude},
+		{"AtHome", ts.AtHome},
+		{"AtPark", ts.AtPark},
+		{"Azimuth", ts.Azimuth},
+		{"Declination", ts.Declination},
+		{"IsPulseGuiding", ts.IsPulseGuiding},
+		{"RightAscension", ts.RightAscension},
+		{"SideOfPier", ts.SideOfPier},
+		{"SiderealTime", ts.SiderealTime},
+		{"Slewing", ts.Slewing},
+		{"Tracking", ts.Tracking},
+		{"UTCDate", ts.UTCDate.UTC().Format(time.RFC3339Nano)},
+	}
+}
+
+// TelescopeSettings are the read-write properties of a telescope that are
+// set as a whole by the handler.
+type TelescopeSettings struct {
+	SiteElevation           float64 // Meters
+	SiteLatitude            float64 // Degrees, positive north
+	SiteLongitude           float64 // Degrees, positive east
+	DoesRefraction          bool
+	SlewSettleTime          int     // Seconds added to the end of the slews
+	GuideRateRightAscension float64 // Degrees per second
+	GuideRateDeclination    float64 // Degrees per second
+	RightAscensionRate      float64 // Offset from the tracking rate, in seconds of right ascension per sidereal second
+	DeclinationRate         float64 // Arc seconds per second
+}
+
+// TelescopeTarget is the target of SlewToTarget and SyncToTarget, with nil
+// coordinates until they are set.
+type TelescopeTarget struct {
+	RightAscension *float64 // Hours
+	Declination    *float64 // Degrees
+}
+
+// Telescope is a mount. The handler checks the capabilities and the ranges
+// of the parameters; SlewToCoordinates and SlewToAltAz return once the slew
+// started, and the handler waits for the end of the synchronous slews.
+type Telescope interface {
+	Device
+
+	Capabilities() TelescopeCapabilities
+	Status() TelescopeStatus
+	Settings() TelescopeSettings
+	SetSettings(TelescopeSettings) error
+	Target() TelescopeTarget
+	SetTarget(TelescopeTarget) error
+
+	SetTracking(bool) error
+	SetTrackingRate(DriveRate) error
+	SetSideOfPier(PierSide) error
+	SetUTCDate(time.Time) error
+	DestinationSideOfPier(ra, dec float64) (PierSide, error)
+
+	SlewToCoordinates(ra, dec float64) error
+	SlewToAltAz(azimuth, altitude float64) error
+	SyncToCoordinates(ra, dec float64) error
+	SyncToAltAz(azimuth, altitude float64) error
+	MoveAxis(axis TelescopeAxis, rate float64) error
+	PulseGuide(direction GuideDirection, duration time.Duration) error
+	AbortSlew() error
+
+	FindHome() error
+	Park() error
+	Unpark() error
+	SetPark() error
+}
+
+// slewPollInterval is the period of the status checks while a synchronous
+// slew runs.
+const slewPollInterval = 100 * time.Millisecond
+
+type TelescopeHandler struct {
+	DeviceHandler
+	dev Telescope
+}
+
+func NewTelescopeHandler(dev Telescope, version int) *TelescopeHandler {
+	return &TelescopeHandler{
+		DeviceHandler: DeviceHandler{dev: dev, version: version},
+		dev:           dev,
+	}
+}
+
+func init() {
+	RegisterDeviceHandler(DeviceTypeTelescope, func(dev Device, version int) DeviceHTTPHandler {
+		if t, ok := dev.(Telescope); ok {
+			return NewTelescopeHandler(t, version)
+		}
+		return nil
+	})
+}
+
+// telescopeRate is a read-write rate of TelescopeSettings, with the
+// capability needed to set it.
+type telescopeRate struct {
+	param string
+	field func(*TelescopeSettings) *float64
+	can   func(TelescopeCapabilities) bool
+}
+
+var telescopeRates = []telescopeRate{
+	{"DeclinationRate", func(s *TelescopeSettings) *float64 { return &s.DeclinationRate },
+		func(c TelescopeCapabilities) bool { return c.CanSetDeclinationRate }},
+	{"RightAscensionRate", func(s *TelescopeSettings) *float64 { return &s.RightAscensionRate },
+		func(c TelescopeCapabilities) bool { return c.CanSetRightAscensionRate }},
+	{"GuideRateDeclination", func(s *TelescopeSettings) *float64 { return &s.GuideRateDeclination },
+		func(c TelescopeCapabilities) bool { return c.CanSetGuideRates }},
+	{"GuideRateRightAscension", func(s *TelescopeSettings) *float64 { return &s.GuideRateRightAscension },
+		func(c TelescopeCapabilities) bool { return c.CanSetGuideRates }},
+}
+
+func (th *TelescopeHandler) RegisterRoutes(mux *http.ServeMux) {
+	th.DeviceHandler.RegisterRoutes(mux)
+
+	for _, property := range []string{
+		"alignmentmode", "aperturearea", "aperturediameter", "equatorialsystem", "focallength",
+		"canfindhome", "canpark", "canpulseguide", "cansetdeclinationrate", "cansetguiderates",
+		"cansetpark", "cansetpierside", "cansetrightascensionrate", "cansettracking", "canslew",
+		"canslewaltaz", "canslewaltazasync", "canslewasync", "cansync", "cansyncaltaz", "canunpark",
+		"trackingrates",
+	} {
+		mux.Handle("GET /"+property, th.handleStatic(th.handleCapabilities))
+	}
+	for _, property := range []string{
+		"altitude", "azimuth", "rightascension", "declination", "siderealtime", "athome", "atpark",
+		"slewing", "tracking", "ispulseguiding", "sideofpier", "trackingrate", "utcdate",
+	} {
+		mux.Handle("GET /"+property, handleAPI(th.handleStatus))
+	}
+
+	for _, rate := range telescopeRates {
+		path := "/" + strings.ToLower(rate.param)
+		mux.Handle("GET "+path, handleAPI(func(r *http.Request) (any, error) {
+			s := th.dev.Settings()
+			return *rate.field(&s), nil
+		}))
+		mux.Handle("PUT "+path, handleAPI(th.putRate(rate)))
+	}
+	mux.Handle("GET /doesrefraction", handleAPI(func(r *http.Request) (any, error) {
+		return th.dev.Settings().DoesRefraction, nil
+	}))
+	mux.Handle("PUT /doesrefraction", handleAPI(th.handleDoesRefraction))
+	mux.Handle("GET /siteelevation", handleAPI(func(r *http.Request) (any, error) {
+		return th.dev.Settings().SiteElevation, nil
+	}))
+	mux.Handle("PUT /siteelevation", handleAPI(th.handleSite))
+	mux.Handle("GET /sitelatitude", handleAPI(func(r *http.Request) (any, error) {
+		return th.dev.Settings().SiteLatitude, nil
+	}))
+	mux.Handle("PUT /sitelatitude", handleAPI(th.handleSite))
+	mux.Handle("GET /sitelongitude", handleAPI(func(r *http.Request) (any, error) {
+		return th.dev.Settings().SiteLongitude, nil
+	}))
+	mux.Handle("PUT /sitelongitude", handleAPI(th.handleSite))
+	mux.Handle("GET /slewsettletime", handleAPI(func(r *http.Request) (any, error) {
+		return th.dev.Settings().SlewSettleTime, nil
+	}))
+	mux.Handle("PUT /slewsettletime", handleAPI(th.handleSlewSettleTime))
+
+	mux.Handle("GET /targetrightascension", handleAPI(th.handleTarget))
+	mux.Handle("GET /targetdeclination", handleAPI(th.handleTarget))
+	mux.Handle("PUT /targetrightascension", handleAPI(th.handleSetTarget))
+	mux.Handle("PUT /targetdeclination", handleAPI(th.handleSetTarget))
+
+	mux.Handle("PUT /tracking", handleAPI(th.handleTracking))
+	mux.Handle("PUT /trackingrate", handleAPI(th.handleTrackingRate))
+	mux.Handle("PUT /sideofpier", handleAPI(th.handleSideOfPier))
+	mux.Handle("PUT /utcdate", handleAPI(th.handleUTCDate))
+
+	mux.Handle("GET /axisrates", handleAPI(th.handleAxisRates))
+	mux.Handle("GET /canmoveaxis", handleAPI(th.handleCanMoveAxis))
+	mux.Handle("GET /destinationsideofpier", handleAPI(th.handleDestinationSideOfPier))
+
+	mux.Handle("PUT /slewtocoordinates", handleAPI(th.handleSlewToCoordinates))
+	mux.Handle("PUT /slewtocoordinatesasync", handleAPI(th.handleSlewToCoordinates))
+	mux.Handle("PUT /slewtotarget", handleAPI(th.handleSlewToTarget))
+	mux.Handle("PUT /slewtotargetasync", handleAPI(th.handleSlewToTarget))
+	mux.Handle("PUT /slewtoaltaz", handleAPI(th.handleSlewToAltAz))
+	mux.Handle("PUT /slewtoaltazasync", handleAPI(th.handleSlewToAltAz))
+	mux.Handle("PUT /synctocoordinates", handleAPI(th.handleSyncToCoordinates))
+	mux.Handle("PUT /synctotarget", handleAPI(th.handleSyncToTarget))
+	mux.Handle("PUT /synctoaltaz", handleAPI(th.handleSyncToAltAz))
+	mux.Handle("PUT /moveaxis", handleAPI(th.handleMoveAxis))
+	mux.Handle("PUT /pulseguide", handleAPI(th.handlePulseGuide))
+	mux.Handle("PUT /abortslew", handleAPI(th.handleAbortSlew))
+	mux.Handle("PUT /findhome", handleAPI(th.handleFindHome))
+	mux.Handle("PUT /park", handleAPI(th.handlePark))
+	mux.Handle("PUT /unpark", handleAPI(th.handleUnpark))
+	mux.Handle("PUT /setpark", handleAPI(th.handleSetPark))
+}
+
+func (th *TelescopeHandler) handleCapabilities(r *http.Request) (any, error) {
+	cap := th.dev.Capabilities()
+
+	property := r.URL.Path[1:]
+	switch property {
+	case "alignmentmode":
+		return cap.AlignmentMode, nil
+	case "aperturearea":
+		return cap.ApertureArea, nil
+	case "aperturediameter":
+		return cap.ApertureDiameter, nil
+	case "equatorialsystem":
+		return cap.EquatorialSystem, nil
+	case "focallength":
+		return cap.FocalLength, nil
+	case "canfindhome":
+		return cap.CanFindHome, nil
+	case "canpark":
+		return cap.CanPark, nil
+	case "canpulseguide":
+		return cap.CanPulseGuide, nil
+	case "cansetdeclinationrate":
+		return cap.CanSetDeclinationRate, nil
+	case "cansetguiderates":
+		return cap.CanSetGuideRates, nil
+	case "cansetpark":
+		return cap.CanSetPark, nil
+	case "cansetpierside":
+		return cap.CanSetPierSide, nil
+	case "cansetrightascensionrate":
+		return cap.CanSetRightAscensionRate, nil
+	case "cansettracking":
+		return cap.CanSetTracking, nil
+	case "canslew":
+		return cap.CanSlew, nil
+	case "canslewaltaz":
+		return cap.CanSlewAltAz, nil
+	case "canslewaltazasync":
+		return cap.CanSlewAltAzAsync, nil
+	case "canslewasync":
+		return cap.CanSlewAsync, nil
+	case "cansync":
+		return cap.CanSync, nil
+	case "cansyncaltaz":
+		return cap.CanSyncAltAz, nil
+	case "canunpark":
+		return cap.CanUnpark, nil
+	case "trackingrates":
+		return cap.TrackingRates, nil
+	default:
+		return nil, errBadRequest
+	}
+}
+
+func (th *TelescopeHandler) handleStatus(r *http.Request) (any, error) {
+	status := th.dev.Status()
+
+	property := r.URL.Path[1:]
+	switch property {
+	case "altitude":
+		return status.Altitude, nil
+	case "azimuth":
+		return status.Azimuth, nil
+	case "rightascension":
+		return status.RightAscension, nil
+	case "declination":
+		return status.Declination, nil
+	case "siderealtime":
+		return status.SiderealTime, nil
+	case "athome":
+		return status.AtHome, nil
+	case "atpark":
+		return status.AtPark, nil
+	case "slewing":
+		return status.Slewing, nil
+	case "tracking":
+		return status.Tracking, nil
+	case "ispulseguiding":
+		return status.IsPulseGuiding, nil
+	case "sideofpier":
+		return status.SideOfPier, nil
+	case "trackingrate":
+		return status.TrackingRate, nil
+	case "utcdate":
+		return status.UTCDate.UTC().Format(time.RFC3339Nano), nil
+	default:
+		return nil, errBadRequest
+	}
+}
+
+// updateSettings changes the settings of the telescope.
+func (th *TelescopeHandler) updateSettings(update func(*TelescopeSettings)) error {
+	s := th.dev.Settings()
+	update(&s)
+	return th.dev.SetSettings(s)
+}
+
+func (th *TelescopeHandler) putRate(rate telescopeRate) func(r *http.Request) (any, error) {
+	return func(r *http.Request) (any, error) {
+		value, err := getFloatParam(r, rate.param)
+		if err != nil {
+			return nil, errBadRequest
+		}
+		if !rate.can(th.dev.Capabilities()) {
+			return nil, alpacaerrors.ErrNotImplemented
+		}
+		if strings.HasPrefix(rate.param, "GuideRate") && value < 0 {
+			return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "invalid guide rate %g", value)
+		}
+		return nil, th.updateSettings(func(s *TelescopeSettings) { *rate.field(s) = value })
+	}
+}
+
+func (th *TelescopeHandler) handleDoesRefraction(r *http.Request) (any, error) {
+	refraction, err := getBoolParam(r, "DoesRefraction")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	return nil, th.updateSettings(func(s *TelescopeSettings) { s.DoesRefraction = refraction })
+}
+
+// handleSite sets the elevation, the latitude or the longitude of the site.
+func (th *TelescopeHandler) handleSite(r *http.Request) (any, error) {
+	var param string
+	var min, max float64
+	var field func(*TelescopeSettings) *float64
+
+	switch r.URL.Path[1:] {
+	case "siteelevation":
+		param, min, max = "SiteElevation", -300, 10000
+		field = func(s *TelescopeSettings) *float64 { return &s.SiteElevation }
+	case "sitelatitude":
+		param, min, max = "SiteLatitude", -90, 90
+		field = func(s *TelescopeSettings) *float64 { return &s.SiteLatitude }
+	case "sitelongitude":
+		param, min, max = "SiteLongitude", -180, 180
+		field = func(s *TelescopeSettings) *float64 { return &s.SiteLongitude }
+	default:
+		return nil, errBadRequest
+	}
+
+	value, err := getFloatParam(r, param)
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if value < min || value > max {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "%s %g out of range %g to %g", param, value, min, max)
+	}
+	return nil, th.updateSettings(func(s *TelescopeSettings) { *field(s) = value })
+}
+
+func (th *TelescopeHandler) handleSlewSettleTime(r *http.Request) (any, error) {
+	seconds, err := getIntParam(r, "SlewSettleTime")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if seconds < 0 {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "invalid slew settle time %d", seconds)
+	}
+	return nil, th.updateSettings(func(s *TelescopeSettings) { s.SlewSettleTime = seconds })
+}
+
+func (th *TelescopeHandler) handleTarget(r *http.Request) (any, error) {
+	target := th.dev.Target()
+
+	value := target.Declination
+	if r.URL.Path == "/targetrightascension" {
+		value = target.RightAscension
+	}
+	if value == nil {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrValueNotSet, "the target has not been set")
+	}
+	return *value, nil
+}
+
+func (th *TelescopeHandler) handleSetTarget(r *http.Request) (any, error) {
+	target := th.dev.Target()
+
+	if r.URL.Path == "/targetrightascension" {
+		ra, err := getFloatParam(r, "TargetRightAscension")
+		if err != nil {
+			return nil, errBadRequest
+		}
+		if err := checkCoordinates(ra, 0); err != nil {
+			return nil, err
+		}
+		target.RightAscension = &ra
+	} else {
+		dec, err := getFloatParam(r, "TargetDeclination")
+		if err != nil {
+			return nil, errBadRequest
+		}
+		if err := checkCoordinates(0, dec); err != nil {
+			return nil, err
+		}
+		target.Declination = &dec
+	}
+	return nil, th.dev.SetTarget(target)
+}
+
+func (th *TelescopeHandler) handleTracking(r *http.Request) (any, error) {
+	tracking, err := getBoolParam(r, "Tracking")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if !th.dev.Capabilities().CanSetTracking {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	return nil, th.dev.SetTracking(tracking)
+}
+
+func (th *TelescopeHandler) handleTrackingRate(r *http.Request) (any, error) {
+	rate, err := getIntParam(r, "TrackingRate")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if !slices.Contains(th.dev.Capabilities().TrackingRates, DriveRate(rate)) {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "unsupported tracking rate %d", rate)
+	}
+	return nil, th.dev.SetTrackingRate(DriveRate(rate))
+}
+
+func (th *TelescopeHandler) handleSideOfPier(r *http.Request) (any, error) {
+	side, err := getIntParam(r, "SideOfPier")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if !th.dev.Capabilities().CanSetPierSide {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if PierSide(side) != PierEast && PierSide(side) != PierWest {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "invalid side of pier %d", side)
+	}
+	return nil, th.dev.SetSideOfPier(PierSide(side))
+}
+
+func (th *TelescopeHandler) handleUTCDate(r *http.Request) (any, error) {
+	value, err := getParam(r, "UTCDate", false)
+	if err != nil {
+		return nil, errBadRequest
+	}
+	date, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "invalid UTC date %q", value)
+	}
+	return nil, th.dev.SetUTCDate(date)
+}
+
+// axis reads the Axis parameter, matched in any case for the GET requests.
+func axis(r *http.Request) (TelescopeAxis, error) {
+	value, err := getUintParam(r, "Axis", r.Method == http.MethodGet)
+	if err != nil {
+		return 0, errBadRequest
+	}
+	if TelescopeAxis(value) > AxisTertiary {
+		return 0, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "axis %d out of range 0 to 2", value)
+	}
+	return TelescopeAxis(value), nil
+}
+
+func (th *TelescopeHandler) handleAxisRates(r *http.Request) (any, error) {
+	axis, err := axis(r)
+	if err != nil {
+		return nil, err
+	}
+	rates := th.dev.Capabilities().AxisRates[axis]
+	if rates == nil {
+		rates = []AxisRate{}
+	}
+	return rates, nil
+}
+
+func (th *TelescopeHandler) handleCanMoveAxis(r *http.Request) (any, error) {
+	axis, err := axis(r)
+	if err != nil {
+		return nil, err
+	}
+	return th.dev.Capabilities().CanMoveAxis(axis), nil
+}
+
+// checkCoordinates checks a right ascension, in hours, and a declination, in
+// degrees.
+func checkCoordinates(ra, dec float64) error {
+	if ra < 0 || ra >= 24 {
+		return alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "right ascension %g out of range 0 to 24", ra)
+	}
+	if dec < -90 || dec > 90 {
+		return alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "declination %g out of range -90 to 90", dec)
+	}
+	return nil
+}
+
+// coordinates reads and checks the RightAscension and Declination parameters,
+// matched in any case for the GET requests.
+func coordinates(r *http.Request) (float64, float64, error) {
+	anyCase := r.Method == http.MethodGet
+	ra, err := getFloatParamCase(r, "RightAscension", anyCase)
+	if err != nil {
+		return 0, 0, errBadRequest
+	}
+	dec, err := getFloatParamCase(r, "Declination", anyCase)
+	if err != nil {
+		return 0, 0, errBadRequest
+	}
+	return ra, dec, checkCoordinates(ra, dec)
+}
+
+// altAz reads and checks the Azimuth and Altitude parameters.
+func altAz(r *http.Request) (float64, float64, error) {
+	az, err := getFloatParam(r, "Azimuth")
+	if err != nil {
+		return 0, 0, errBadRequest
+	}
+	alt, err := getFloatParam(r, "Altitude")
+	if err != nil {
+		return 0, 0, errBadRequest
+	}
+	if az < 0 || az >= 360 {
+		return 0, 0, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "azimuth %g out of range 0 to 360", az)
+	}
+	if alt < -90 || alt > 90 {
+		return 0, 0, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "altitude %g out of range -90 to 90", alt)
+	}
+	return az, alt, nil
+}
+
+func (th *TelescopeHandler) handleDestinationSideOfPier(r *http.Request) (any, error) {
+	ra, dec, err := coordinates(r)
+	if err != nil {
+		return nil, err
+	}
+	return th.dev.DestinationSideOfPier(ra, dec)
+}
+
+// async reports whether the request is for the asynchronous variant of a
+// method.
+func async(r *http.Request) bool {
+	return strings.HasSuffix(r.URL.Path, "async")
+}
+
+// slew starts a slew to coordinates, and waits for its end unless async.
+func (th *TelescopeHandler) slew(r *http.Request, ra, dec float64) error {
+	cap := th.dev.Capabilities()
+	if (async(r) && !cap.CanSlewAsync) || (!async(r) && !cap.CanSlew) {
+		return alpacaerrors.ErrNotImplemented
+	}
+	if err := th.dev.SlewToCoordinates(ra, dec); err != nil {
+		return err
+	}
+	if async(r) {
+		return nil
+	}
+	return th.waitSlew(r)
+}
+
+// waitSlew waits for the end of a synchronous slew, or for the client to go
+// away.
+func (th *TelescopeHandler) waitSlew(r *http.Request) error {
+	ticker := time.NewTicker(slewPollInterval)
+	defer ticker.Stop()
+
+	for th.dev.Status().Slewing {
+		select {
+		case <-r.Context().Done():
+			return fmt.Errorf("slew still running: %w", r.Context().Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// handleSlewToCoordinates sets the target to the coordinates, then slews
+// to it.
+func (th *TelescopeHandler) handleSlewToCoordinates(r *http.Request) (any, error) {
+	ra, dec, err := coordinates(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := th.dev.SetTarget(TelescopeTarget{RightAscension: &ra, Declination: &dec}); err != nil {
+		return nil, err
+	}
+	return nil, th.slew(r, ra, dec)
+}
+
+func (th *TelescopeHandler) handleSlewToTarget(r *http.Request) (any, error) {
+	target := th.dev.Target()
+	if target.RightAscension == nil || target.Declination == nil {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrValueNotSet, "the target has not been set")
+	}
+	return nil, th.slew(r, *target.RightAscension, *target.Declination)
+}
+
+// handleSlewToAltAz slews to horizontal coordinates, which is only valid
+// while not tracking.
+func (th *TelescopeHandler) handleSlewToAltAz(r *http.Request) (any, error) {
+	az, alt, err := altAz(r)
+	if err != nil {
+		return nil, err
+	}
+	cap := th.dev.Capabilities()
+	if (async(r) && !cap.CanSlewAltAzAsync) || (!async(r) && !cap.CanSlewAltAz) {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if th.dev.Status().Tracking {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidOperation, "cannot slew to alt/az coordinates while tracking")
+	}
+	if err := th.dev.SlewToAltAz(az, alt); err != nil {
+		return nil, err
+	}
+	if async(r) {
+		return nil, nil
+	}
+	return nil, th.waitSlew(r)
+}
+
+// handleSyncToCoordinates sets the target to the coordinates, then syncs
+// to it.
+func (th *TelescopeHandler) handleSyncToCoordinates(r *http.Request) (any, error) {
+	ra, dec, err := coordinates(r)
+	if err != nil {
+		return nil, err
+	}
+	if !th.dev.Capabilities().CanSync {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if err := th.dev.SetTarget(TelescopeTarget{RightAscension: &ra, Declination: &dec}); err != nil {
+		return nil, err
+	}
+	return nil, th.dev.SyncToCoordinates(ra, dec)
+}
+
+func (th *TelescopeHandler) handleSyncToTarget(r *http.Request) (any, error) {
+	if !th.dev.Capabilities().CanSync {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	target := th.dev.Target()
+	if target.RightAscension == nil || target.Declination == nil {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrValueNotSet, "the target has not been set")
+	}
+	return nil, th.dev.SyncToCoordinates(*target.RightAscension, *target.Declination)
+}
+
+func (th *TelescopeHandler) handleSyncToAltAz(r *http.Request) (any, error) {
+	az, alt, err := altAz(r)
+	if err != nil {
+		return nil, err
+	}
+	if !th.dev.Capabilities().CanSyncAltAz {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if th.dev.Status().Tracking {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidOperation, "cannot sync to alt/az coordinates while tracking")
+	}
+	return nil, th.dev.SyncToAltAz(az, alt)
+}
+
+// handleMoveAxis moves an axis at a rate, in degrees per second, within the
+// AxisRates of the axis; a zero rate stops it.
+func (th *TelescopeHandler) handleMoveAxis(r *http.Request) (any, error) {
+	rate, err := getFloatParam(r, "Rate")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	axis, err := axis(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := th.dev.Capabilities().AxisRates[axis]
+	if len(rates) == 0 {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrNotImplemented, "axis %d cannot be moved", axis)
+	}
+	if rate != 0 && !slices.ContainsFunc(rates, func(ar AxisRate) bool {
+		return math.Abs(rate) >= ar.Minimum && math.Abs(rate) <= ar.Maximum
+	}) {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "rate %g out of the rates of axis %d", rate, axis)
+	}
+	return nil, th.dev.MoveAxis(axis, rate)
+}
+
+// handlePulseGuide guides in a direction for a duration in milliseconds.
+func (th *TelescopeHandler) handlePulseGuide(r *http.Request) (any, error) {
+	direction, err := getIntParam(r, "Direction")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	duration, err := getIntParam(r, "Duration")
+	if err != nil {
+		return nil, errBadRequest
+	}
+	if !th.dev.Capabilities().CanPulseGuide {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	if GuideDirection(direction) < GuideNorth || GuideDirection(direction) > GuideWest {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "invalid guide direction %d", direction)
+	}
+	if duration < 0 {
+		return nil, alpacaerrors.Errorf(alpacaerrors.ErrInvalidValue, "invalid guide duration %d", duration)
+	}
+	return nil, th.dev.PulseGuide(GuideDirection(direction), time.Duration(duration)*time.Millisecond)
+}
+
+func (th *TelescopeHandler) handleAbortSlew(r *http.Request) (any, error) {
+	return nil, th.dev.AbortSlew()
+}
+
+func (th *TelescopeHandler) handleFindHome(r *http.Request) (any, error) {
+	if !th.dev.Capabilities().CanFindHome {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	return nil, th.dev.FindHome()
+}
+
+func (th *TelescopeHandler) handlePark(r *http.Request) (any, error) {
+	if !th.dev.Capabilities().CanPark {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	return nil, th.dev.Park()
+}
+
+func (th *TelescopeHandler) handleUnpark(r *http.Request) (any, error) {
+	if !th.dev.Capabilities().CanUnpark {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	return nil, th.dev.Unpark()
+}
+
+func (th *TelescopeHandler) handleSetPark(r *http.Request) (any, error) {
+	if !th.dev.Capabilities().CanSetPark {
+		return nil, alpacaerrors.ErrNotImplemented
+	}
+	return nil, th.dev.SetPark()
+}
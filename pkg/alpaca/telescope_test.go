@@ -0,0 +1,162 @@
+package alpaca
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	alpacaerrors "alpaca/pkg/alpaca/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTelescope is a mount that slews at once, with a slewing state set by
+// the test.
+type fakeTelescope struct {
+	fakeConditions
+	status   TelescopeStatus
+	settings TelescopeSettings
+	target   TelescopeTarget
+	moved    [3]float64
+}
+
+func (d *fakeTelescope) DeviceInfo() DeviceInfo {
+	return DeviceInfo{Name: "Fake Telescope", Type: DeviceTypeTelescope, Number: 0, UniqueID: "fake-telescope"}
+}
+
+func (d *fakeTelescope) Capabilities() TelescopeCapabilities {
+	return TelescopeCapabilities{
+		AlignmentMode: AlignmentGermanPolar,
+		CanSlew:       true,
+		CanSlewAsync:  true,
+		CanSlewAltAz:  true,
+		CanSync:       true,
+		CanPark:       true,
+		AxisRates:     [3][]AxisRate{{{Minimum: 0.5, Maximum: 4}}},
+		TrackingRates: []DriveRate{DriveSidereal},
+	}
+}
+func (d *fakeTelescope) Status() TelescopeStatus               { return d.status }
+func (d *fakeTelescope) Settings() TelescopeSettings           { return d.settings }
+func (d *fakeTelescope) SetSettings(s TelescopeSettings) error { d.settings = s; return nil }
+func (d *fakeTelescope) Target() TelescopeTarget               { return d.target }
+func (d *fakeTelescope) SetTarget(t TelescopeTarget) error     { d.target = t; return nil }
+func (d *fakeTelescope) SetTracking(on bool) error             { d.status.Tracking = on; return nil }
+func (d *fakeTelescope) SetTrackingRate(r DriveRate) error     { d.status.TrackingRate = r; return nil }
+func (d *fakeTelescope) SetSideOfPier(PierSide) error          { return nil }
+func (d *fakeTelescope) SetUTCDate(date time.Time) error       { d.status.UTCDate = date; return nil }
+func (d *fakeTelescope) DestinationSideOfPier(ra, dec float64) (PierSide, error) {
+	return PierWest, nil
+}
+func (d *fakeTelescope) SlewToCoordinates(ra, dec float64) error {
+	d.status.RightAscension, d.status.Declination = ra, dec
+	return nil
+}
+func (d *fakeTelescope) SlewToAltAz(az, alt float64) error {
+	d.status.Azimuth, d.status.Altitude = az, alt
+	return nil
+}
+func (d *fakeTelescope) SyncToCoordinates(ra, dec float64) error { return d.SlewToCoordinates(ra, dec) }
+func (d *fakeTelescope) SyncToAltAz(az, alt float64) error       { return d.SlewToAltAz(az, alt) }
+func (d *fakeTelescope) MoveAxis(axis TelescopeAxis, rate float64) error {
+	d.moved[axis] = rate
+	return nil
+}
+func (d *fakeTelescope) PulseGuide(GuideDirection, time.Duration) error { return nil }
+func (d *fakeTelescope) AbortSlew() error                               { return nil }
+func (d *fakeTelescope) FindHome() error                                { return nil }
+func (d *fakeTelescope) Park() error                                    { d.status.AtPark = true; return nil }
+func (d *fakeTelescope) Unpark() error                                  { return nil }
+func (d *fakeTelescope) SetPark() error                                 { return nil }
+
+func TestTelescopeHandler(t *testing.T) {
+	dev := &fakeTelescope{}
+	dev.connected = true
+	ts := newTestServer(dev)
+	defer ts.Close()
+	api := ts.URL + "/api/v1/telescope/0/"
+	put := func(method string, values url.Values) baseResponse {
+		values.Set("ClientTransactionID", "1")
+		return putForm(t, api+method, values)
+	}
+
+	assert.Equal(t, float64(AlignmentGermanPolar), getJSON(t, api+"alignmentmode?ClientTransactionID=1").Value)
+	assert.Equal(t, true, getJSON(t, api+"canslew?ClientTransactionID=1").Value)
+	assert.Equal(t, false, getJSON(t, api+"canfindhome?ClientTransactionID=1").Value)
+	assert.Equal(t, true, getJSON(t, api+"canmoveaxis?axis=0&ClientTransactionID=1").Value)
+	assert.Equal(t, false, getJSON(t, api+"canmoveaxis?Axis=2&ClientTransactionID=1").Value)
+	assert.Equal(t, []any{}, getJSON(t, api+"axisrates?Axis=1&ClientTransactionID=1").Value)
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, getJSON(t, api+"axisrates?Axis=3&ClientTransactionID=1").ErrorNumber)
+	assert.Equal(t, float64(PierWest), getJSON(t, api+"destinationsideofpier?rightascension=5&declination=20&ClientTransactionID=1").Value)
+
+	// The target is not set until given or slewed to.
+	assert.Equal(t, alpacaerrors.ErrValueNotSet.Number, getJSON(t, api+"targetrightascension?ClientTransactionID=1").ErrorNumber)
+	assert.Equal(t, alpacaerrors.ErrValueNotSet.Number, put("slewtotarget", url.Values{}).ErrorNumber)
+
+	resp := put("slewtocoordinatesasync", url.Values{"RightAscension": {"5.5"}, "Declination": {"-20"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 5.5, dev.status.RightAscension)
+	assert.Equal(t, 5.5, getJSON(t, api+"targetrightascension?ClientTransactionID=1").Value)
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, put("slewtocoordinates", url.Values{"RightAscension": {"24"}, "Declination": {"0"}}).ErrorNumber)
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, put("targetdeclination", url.Values{"TargetDeclination": {"91"}}).ErrorNumber)
+
+	resp = put("targetdeclination", url.Values{"TargetDeclination": {"45"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	resp = put("slewtotarget", url.Values{})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, 45.0, dev.status.Declination)
+
+	// Alt/az slews are only valid while not tracking.
+	dev.status.Tracking = true
+	assert.Equal(t, alpacaerrors.ErrInvalidOperation.Number, put("slewtoaltaz", url.Values{"Azimuth": {"90"}, "Altitude": {"30"}}).ErrorNumber)
+	dev.status.Tracking = false
+	require.Zero(t, put("slewtoaltaz", url.Values{"Azimuth": {"90"}, "Altitude": {"30"}}).ErrorNumber)
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, put("slewtoaltazasync", url.Values{"Azimuth": {"90"}, "Altitude": {"30"}}).ErrorNumber)
+
+	// The capabilities and the ranges are checked by the handler.
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, put("tracking", url.Values{"Tracking": {"true"}}).ErrorNumber)
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, put("findhome", url.Values{}).ErrorNumber)
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, put("declinationrate", url.Values{"DeclinationRate": {"1"}}).ErrorNumber)
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, put("trackingrate", url.Values{"TrackingRate": {"2"}}).ErrorNumber)
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, put("moveaxis", url.Values{"Axis": {"0"}, "Rate": {"5"}}).ErrorNumber)
+	assert.Equal(t, alpacaerrors.ErrNotImplemented.Number, put("moveaxis", url.Values{"Axis": {"1"}, "Rate": {"1"}}).ErrorNumber)
+	require.Zero(t, put("moveaxis", url.Values{"Axis": {"0"}, "Rate": {"-2"}}).ErrorNumber)
+	assert.Equal(t, -2.0, dev.moved[AxisPrimary])
+	require.Zero(t, put("moveaxis", url.Values{"Axis": {"0"}, "Rate": {"0"}}).ErrorNumber, "a zero rate stops the axis")
+
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, put("sitelatitude", url.Values{"SiteLatitude": {"-91"}}).ErrorNumber)
+	require.Zero(t, put("sitelatitude", url.Values{"SiteLatitude": {"40.5"}}).ErrorNumber)
+	require.Zero(t, put("doesrefraction", url.Values{"DoesRefraction": {"true"}}).ErrorNumber)
+	assert.Equal(t, 40.5, getJSON(t, api+"sitelatitude?ClientTransactionID=1").Value)
+	assert.Equal(t, true, getJSON(t, api+"doesrefraction?ClientTransactionID=1").Value)
+
+	resp = put("utcdate", url.Values{"UTCDate": {"2026-03-01T22:00:00.5Z"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.Equal(t, "2026-03-01T22:00:00.5Z", getJSON(t, api+"utcdate?ClientTransactionID=1").Value)
+	assert.Equal(t, alpacaerrors.ErrInvalidValue.Number, put("utcdate", url.Values{"UTCDate": {"yesterday"}}).ErrorNumber)
+}
+
+// slowTelescope slews until a time.
+type slowTelescope struct {
+	fakeTelescope
+	until time.Time
+}
+
+func (d *slowTelescope) Status() TelescopeStatus {
+	return TelescopeStatus{Slewing: time.Now().Before(d.until)}
+}
+
+func TestSynchronousSlewWaits(t *testing.T) {
+	dev := &slowTelescope{until: time.Now().Add(3 * slewPollInterval)}
+	dev.connected = true
+	ts := newTestServer(dev)
+	defer ts.Close()
+
+	resp := putForm(t, ts.URL+"/api/v1/telescope/0/slewtocoordinates", url.Values{"RightAscension": {"1"}, "Declination": {"2"}, "ClientTransactionID": {"1"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+	assert.False(t, time.Now().Before(dev.until), "answered once the slew ended")
+
+	resp = putForm(t, ts.URL+"/api/v1/telescope/0/slewtocoordinatesasync", url.Values{"RightAscension": {"1"}, "Declination": {"2"}, "ClientTransactionID": {"2"}})
+	require.Zero(t, resp.ErrorNumber, resp.ErrorMessage)
+}
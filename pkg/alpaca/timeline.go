@@ -0,0 +1,107 @@
+package alpaca
+
+import (
+	"alpaca/pkg/timeline"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Paging limits of the timeline endpoint.
+const (
+	defaultTimelineLimit = 100
+	maxTimelineLimit     = 1000
+)
+
+// timelinePage is the response of the timeline endpoint.
+type timelinePage struct {
+	Entries []timeline.Entry `json:"Entries"`
+	// Next is the cursor of the following page, to be sent as the before
+	// parameter, or 0 if there are no more entries.
+	Next int64 `json:"Next"`
+}
+
+// handleTimeline returns the commands, events and telemetry, newest first.
+// The page size is set with the limit parameter and the page with before, the
+// Next cursor of the previous page. This is an extension to the Alpaca
+// management API.
+func (s *Server) handleTimeline(r *http.Request) (any, error) {
+	query := r.URL.Query()
+
+	limit := defaultTimelineLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid limit %q", v)
+		}
+		limit = min(n, maxTimelineLimit)
+	}
+
+	var before int64
+	if v := query.Get("before"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid before %q", v)
+		}
+		before = n
+	}
+
+	page := timelinePage{Entries: timeline.Default().Page(before, limit)}
+	if len(page.Entries) == limit {
+		page.Next = page.Entries[len(page.Entries)-1].ID
+	}
+	return page, nil
+}
+
+// recordCommand adds a PUT request and its outcome to the timeline.
+func recordCommand(r *http.Request, response baseResponse) {
+	if r.Method != http.MethodPut {
+		return
+	}
+
+	path := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		path = u.Path
+	}
+
+	params, _ := r.Context().Value(paramsKey).(url.Values)
+	var args []string
+	for name, values := range params {
+		if strings.EqualFold(name, "ClientID") || strings.EqualFold(name, "ClientTransactionID") {
+			continue
+		}
+		args = append(args, name+"="+strings.Join(values, ","))
+	}
+	slices.Sort(args)
+
+	summary := strings.TrimSpace(fmt.Sprintf("%s %s", path[strings.LastIndex(path, "/")+1:], strings.Join(args, " ")))
+	if response.ErrorNumber != 0 {
+		summary += ": " + response.ErrorMessage
+	}
+
+	timeline.Record(timeline.Entry{
+		Kind:    timeline.KindCommand,
+		Device:  devicePath(path),
+		Summary: summary,
+		Data: map[string]any{
+			"Remote":              r.RemoteAddr,
+			"ClientID":            ClientID(r.Context()),
+			"ClientTransactionID": response.ClientTransactionID,
+			"ServerTransactionID": response.ServerTransactionID,
+			"ErrorNumber":         response.ErrorNumber,
+		},
+	})
+}
+
+// devicePath returns the "<type>/<number>" part of a device API path such as
+// /api/v1/dome/1/park, or an empty string for other paths.
+func devicePath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[0] != "api" {
+		return ""
+	}
+	return parts[2] + "/" + parts[3]
+}
@@ -0,0 +1,96 @@
+package alpaca
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// certReloader serves a certificate and its key from PEM files, reloaded
+// when the certificate file changes, so a renewed certificate, such as one
+// from Let's Encrypt, is served without a restart.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time // Modification time of the loaded certificate file
+}
+
+// reload loads the certificate again if its file changed since it was
+// loaded.
+func (c *certReloader) reload() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		if c.cert != nil {
+			return c.cert, nil // Keep serving the loaded one, e.g. while it is replaced
+		}
+		return nil, err
+	}
+	if c.cert != nil && info.ModTime().Equal(c.modified) {
+		return c.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			return c.cert, nil
+		}
+		return nil, fmt.Errorf("failed to load the TLS certificate: %v", err)
+	}
+	c.cert, c.modified = &cert, info.ModTime()
+	return c.cert, nil
+}
+
+// NewTLSConfig returns the TLS configuration of a server serving the
+// certificate and key of PEM files. The files are loaded at once, to report
+// a mistake on startup, and again whenever the certificate changes.
+func NewTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("both a TLS certificate and a key are needed")
+	}
+
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return reloader.reload()
+		},
+	}, nil
+}
+
+// RedirectHTTPS redirects the plain HTTP GET and HEAD requests to the same
+// URL over HTTPS on port. The other methods are refused rather than
+// redirected: their command and its parameters already crossed the network
+// in the clear, and the client must send them over HTTPS itself.
+func RedirectHTTPS(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := strings.Trim(r.Host, "[]")
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]" // IPv6 address
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, fmt.Sprintf("%s over plain HTTP is refused, use %s", r.Method, target), http.StatusForbidden)
+			return
+		}
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,139 @@
+package alpaca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate for a common name and its key
+// in a directory.
+func writeCert(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "dome-1")
+
+	cfg, err := NewTLSConfig(certFile, keyFile)
+	require.NoError(t, err)
+	commonName := func() string {
+		cert, err := cfg.GetCertificate(nil)
+		require.NoError(t, err)
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return parsed.Subject.CommonName
+	}
+	assert.Equal(t, "dome-1", commonName())
+
+	// A renewed certificate is served without a restart.
+	writeCert(t, dir, "dome-2")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	assert.Equal(t, "dome-2", commonName())
+
+	// A broken certificate keeps the loaded one.
+	require.NoError(t, os.WriteFile(certFile, []byte("garbage"), 0o600))
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	assert.Equal(t, "dome-2", commonName())
+
+	_, err = NewTLSConfig(certFile, "")
+	assert.Error(t, err, "no key")
+	_, err = NewTLSConfig(filepath.Join(dir, "missing.pem"), keyFile)
+	assert.Error(t, err, "no certificate")
+}
+
+func TestRedirectHTTPS(t *testing.T) {
+	tests := []struct {
+		port     int
+		host     string
+		expected string
+	}{
+		{8443, "dome.local:8080", "https://dome.local:8443/api/v1/dome/0/park?x=1"},
+		{443, "dome.local", "https://dome.local/api/v1/dome/0/park?x=1"},
+		{8443, "[fe80::1]:8080", "https://[fe80::1]:8443/api/v1/dome/0/park?x=1"},
+		{443, "[fe80::1]", "https://[fe80::1]/api/v1/dome/0/park?x=1"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/dome/0/park?x=1", nil)
+		r.Host = test.host
+		w := httptest.NewRecorder()
+		RedirectHTTPS(test.port).ServeHTTP(w, r)
+		assert.Equal(t, http.StatusPermanentRedirect, w.Code, test.host)
+		assert.Equal(t, test.expected, w.Header().Get("Location"), test.host)
+	}
+
+	r := httptest.NewRequest(http.MethodHead, "/setup", nil)
+	w := httptest.NewRecorder()
+	RedirectHTTPS(8443).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+
+	// The commands are not redirected.
+	for _, method := range []string{http.MethodPut, http.MethodPost, http.MethodDelete} {
+		r := httptest.NewRequest(method, "/api/v1/dome/0/park", strings.NewReader("ClientID=1"))
+		r.Host = "dome.local:8080"
+		w := httptest.NewRecorder()
+		RedirectHTTPS(8443).ServeHTTP(w, r)
+		assert.Equal(t, http.StatusForbidden, w.Code, method)
+		assert.Empty(t, w.Header().Get("Location"), method)
+		assert.Contains(t, w.Body.String(), "https://dome.local:8443/api/v1/dome/0/park", method)
+	}
+}
+
+func TestDiscoveryResponseScheme(t *testing.T) {
+	assert.JSONEq(t, `{"AlpacaPort": 8090}`, discoveryResponse(8090, false))
+	assert.JSONEq(t, `{"AlpacaPort": 8443, "AlpacaScheme": "https"}`, discoveryResponse(8443, true))
+}
+
+func TestSetupFormTLS(t *testing.T) {
+	parse := func(form url.Values) (Config, error) {
+		r := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return parseSetupForm(r)
+	}
+
+	cfg, err := parse(url.Values{"tls-cert": {"/etc/alpaca/cert.pem"}, "tls-key": {"/etc/alpaca/key.pem"}, "http-redirect-port": {"8080"}})
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/alpaca/cert.pem", cfg.TLSCert)
+	assert.Equal(t, "/etc/alpaca/key.pem", cfg.TLSKey)
+	assert.Equal(t, 8080, cfg.HTTPRedirectPort)
+
+	_, err = parse(url.Values{"tls-cert": {"/etc/alpaca/cert.pem"}})
+	assert.Error(t, err, "no key")
+	_, err = parse(url.Values{"http-redirect-port": {"70000"}})
+	assert.Error(t, err)
+}
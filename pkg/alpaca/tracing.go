@@ -0,0 +1,103 @@
+package alpaca
+
+import (
+	"alpaca/pkg/version"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces the Alpaca requests, from their reception to their response.
+// The commands the devices send while serving a request are children of its
+// span, see ContextBinder.
+var tracer = otel.Tracer("alpaca/pkg/alpaca")
+
+// StartTracing exports the spans of the requests to an OpenTelemetry
+// collector, at the OTLP/HTTP endpoint of its URL, e.g.
+// http://localhost:4318. The traces of the clients sending a W3C traceparent
+// header, such as NINA behind an instrumented proxy, are continued. The
+// returned function flushes the spans left and stops the export.
+func StartTracing(ctx context.Context, endpoint, service string) (shutdown func(context.Context) error, err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q", endpoint)
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = "/v1/traces"
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP exporter: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", service),
+			attribute.String("service.version", version.Get().String()),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return provider.Shutdown, nil
+}
+
+// requestTarget returns the device of a request, "management" for the
+// management API, and its method.
+func requestTarget(r *http.Request) (device, method string) {
+	device, ok := r.Context().Value(devicePathKey).(string)
+	if !ok {
+		device = "management"
+	}
+	return device, strings.ToLower(path.Base(r.URL.Path))
+}
+
+// startSpan starts the span of an Alpaca request, continuing the trace of
+// the client if it sent one, and returns the request carrying it.
+func startSpan(r *http.Request) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	device, method := requestTarget(r)
+	ctx, span := tracer.Start(ctx, r.Method+" "+device+"/"+method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.String("client.address", r.RemoteAddr),
+			attribute.String("alpaca.device", device),
+			attribute.String("alpaca.method", method),
+		))
+	return r.WithContext(ctx), span
+}
+
+// endSpan records the response of an Alpaca request in its span, an Alpaca
+// error as an error status, and ends the span.
+func endSpan(span trace.Span, r *http.Request, response baseResponse) {
+	span.SetAttributes(
+		attribute.Int64("alpaca.client_id", int64(ClientID(r.Context()))),
+		attribute.Int("alpaca.client_transaction_id", response.ClientTransactionID),
+		attribute.Int("alpaca.server_transaction_id", response.ServerTransactionID),
+		attribute.Int("alpaca.error_number", response.ErrorNumber),
+	)
+	if response.ErrorNumber != 0 {
+		span.SetStatus(codes.Error, response.ErrorMessage)
+	}
+	span.End()
+}
+
+// failSpan ends the span of a request rejected before it reached its
+// handler.
+func failSpan(span trace.Span, err error) {
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+}
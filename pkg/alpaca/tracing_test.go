@@ -0,0 +1,84 @@
+package alpaca
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedDome is a fakeDome recording the span of the request it parks for.
+type tracedDome struct {
+	fakeDome
+	ctx    context.Context
+	parked *trace.SpanContext
+}
+
+func (d *tracedDome) WithContext(ctx context.Context) Device {
+	return &tracedDome{fakeDome: d.fakeDome, ctx: ctx, parked: d.parked}
+}
+
+func (d *tracedDome) Park() error {
+	*d.parked = trace.SpanContextFromContext(d.ctx)
+	return nil
+}
+
+func TestRequestSpans(t *testing.T) {
+	// The tracer of the package delegates to the first provider set, so the
+	// spans of the package are only recorded in this test.
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var parked trace.SpanContext
+	ts := newTestServer(&tracedDome{fakeDome: fakeDome{connected: true}, parked: &parked})
+	defer ts.Close()
+
+	put := func(method string, form url.Values) {
+		req, err := http.NewRequest(http.MethodPut, ts.URL+"/api/v1/dome/0/"+method, strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	put("park", url.Values{"ClientID": {"7"}, "ClientTransactionID": {"1"}})
+	put("slewtoaltitude", url.Values{"Altitude": {"10"}, "ClientTransactionID": {"2"}})
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	// The request continues the trace of the client, and the device serves
+	// it in the context of its span.
+	span := spans[0]
+	assert.Equal(t, "PUT dome/0/park", span.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, span.SpanContext(), parked)
+	assert.Contains(t, span.Attributes(), attribute.Int64("alpaca.client_id", 7))
+	assert.Contains(t, span.Attributes(), attribute.Int("alpaca.client_transaction_id", 1))
+	assert.Equal(t, codes.Unset, span.Status().Code)
+
+	span = spans[1]
+	assert.Equal(t, "PUT dome/0/slewtoaltitude", span.Name())
+	assert.Equal(t, codes.Error, span.Status().Code, "Alpaca error")
+}
+
+func TestStartTracingEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"localhost:4318", "grpc://collector:4317", "http://", "http://[::1"} {
+		_, err := StartTracing(context.Background(), endpoint, "test")
+		assert.Error(t, err, endpoint)
+	}
+}
@@ -0,0 +1,105 @@
+package alpaca
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// validator is the ETag of the last value of a static property, and when
+// that value was first returned.
+type validator struct {
+	etag     string
+	modified time.Time
+}
+
+// validatorCache keeps the validators of the static properties of a device,
+// by path.
+type validatorCache struct {
+	mu         sync.Mutex
+	validators map[string]validator
+}
+
+// get returns the validator of a property answering a value with an ETag,
+// resetting its modification time when the ETag changed.
+func (c *validatorCache) get(path, etag string, now time.Time) validator {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.validators == nil {
+		c.validators = make(map[string]validator)
+	}
+	v, ok := c.validators[path]
+	if !ok || v.etag != etag {
+		// Last-Modified has a resolution of a second.
+		v = validator{etag: etag, modified: now.UTC().Truncate(time.Second)}
+		c.validators[path] = v
+	}
+	return v
+}
+
+// valueETag returns a strong ETag of the value of a response. The
+// transaction IDs differ on every response and are left out.
+func valueETag(value any) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// notModified reports whether the conditional headers of a request match a
+// validator, as in RFC 9110: If-None-Match takes precedence over
+// If-Modified-Since.
+func notModified(r *http.Request, v validator) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == v.etag {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !v.modified.After(since)
+}
+
+// handleStatic returns the handler of a property that does not change while
+// the device is connected, such as a capability. Its successful responses
+// carry an ETag and a Last-Modified date, so a polling client can send
+// conditional requests and get a bodiless 304 Not Modified while the value
+// is unchanged. The errors, such as not connected, are never cached.
+func (h *DeviceHandler) handleStatic(handler func(r *http.Request) (any, error)) http.Handler {
+	return serveAPI(handler, h.writeStatic)
+}
+
+func (h *DeviceHandler) writeStatic(w http.ResponseWriter, r *http.Request, response baseResponse) {
+	if response.ErrorNumber != 0 {
+		writeJSON(w, r, response)
+		return
+	}
+	etag, err := valueETag(response.Value)
+	if err != nil {
+		writeJSON(w, r, response)
+		return
+	}
+
+	v := h.validators.get(r.URL.Path, etag, time.Now())
+	w.Header().Set("ETag", v.etag)
+	w.Header().Set("Last-Modified", v.modified.Format(http.TimeFormat))
+	// The clients must revalidate, since the value changes once the device
+	// reconnects or is set up again.
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if notModified(r, v) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, r, response)
+}
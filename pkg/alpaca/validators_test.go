@@ -0,0 +1,112 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parkingDome is a dome whose CanPark capability can be changed.
+type parkingDome struct {
+	fakeDome
+	canPark bool
+}
+
+func (d *parkingDome) Capabilities() DomeCapabilities {
+	return DomeCapabilities{CanPark: d.canPark}
+}
+
+func conditionalGet(t *testing.T, url string, header http.Header) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header = header
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func TestValidatorCache(t *testing.T) {
+	var c validatorCache
+	now := time.Date(2026, 1, 2, 3, 4, 5, 600, time.UTC)
+
+	v := c.get("/canpark", `"a"`, now)
+	assert.Equal(t, now.Truncate(time.Second), v.modified)
+	assert.Equal(t, v, c.get("/canpark", `"a"`, now.Add(time.Hour)), "unchanged value")
+	assert.Equal(t, now.Add(time.Hour).Truncate(time.Second), c.get("/canpark", `"b"`, now.Add(time.Hour)).modified)
+}
+
+func TestNotModified(t *testing.T) {
+	v := validator{etag: `"a"`, modified: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	request := func(name, value string) *http.Request {
+		r, _ := http.NewRequest(http.MethodGet, "/canpark", nil)
+		r.Header.Set(name, value)
+		return r
+	}
+
+	assert.True(t, notModified(request("If-None-Match", `"b", "a"`), v))
+	assert.True(t, notModified(request("If-None-Match", `W/"a"`), v))
+	assert.True(t, notModified(request("If-None-Match", "*"), v))
+	assert.False(t, notModified(request("If-None-Match", `"b"`), v))
+	assert.True(t, notModified(request("If-Modified-Since", v.modified.Format(http.TimeFormat)), v))
+	assert.False(t, notModified(request("If-Modified-Since", v.modified.Add(-time.Second).Format(http.TimeFormat)), v))
+	assert.False(t, notModified(request("If-Modified-Since", "yesterday"), v))
+
+	r := request("If-None-Match", `"b"`)
+	r.Header.Set("If-Modified-Since", v.modified.Format(http.TimeFormat))
+	assert.False(t, notModified(r, v), "If-None-Match takes precedence")
+}
+
+func TestStaticPropertyValidators(t *testing.T) {
+	dome := &parkingDome{canPark: true}
+	ts := newTestServer(dome)
+	defer ts.Close()
+	url := ts.URL + "/api/v1/dome/0/canpark?ClientTransactionID=1"
+
+	resp := conditionalGet(t, url, http.Header{})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.NotEmpty(t, resp.Header.Get("Last-Modified"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	resp = conditionalGet(t, ts.URL+"/api/v1/dome/0/canpark?ClientTransactionID=2", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode, "the transaction IDs are not part of the ETag")
+
+	resp = conditionalGet(t, url, http.Header{"If-Modified-Since": {resp.Header.Get("Last-Modified")}})
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	dome.canPark = false
+	resp = conditionalGet(t, url, http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "the value changed")
+	assert.NotEqual(t, etag, resp.Header.Get("ETag"))
+
+	// The status properties have no validators.
+	resp = conditionalGet(t, ts.URL+"/api/v1/dome/0/azimuth", http.Header{})
+	assert.Empty(t, resp.Header.Get("ETag"))
+}
+
+func TestStaticPropertyErrorsNotValidated(t *testing.T) {
+	rotator := &fakeRotator{}
+	ts := newTestServer(rotator)
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/rotator/0/stepsize", nil)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", "*")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("ETag"))
+	var body baseResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.NotZero(t, body.ErrorNumber)
+}
@@ -0,0 +1,153 @@
+package alpaca
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WidgetPrefix is the path of the mobile widget API. The version is part of
+// the path so a widget keeps working when the response changes.
+const WidgetPrefix = "/widget/v1"
+
+// BatteryReporter is implemented by devices that report the voltage of a
+// battery, such as a dome with a battery powered shutter.
+type BatteryReporter interface {
+	// BatteryVoltage returns the battery voltage, false if it is unknown.
+	BatteryVoltage() (float64, bool)
+}
+
+// widgetStatus is the response of the widget status endpoint. It only holds
+// what a home screen widget shows, in a few hundred bytes.
+type widgetStatus struct {
+	Devices []widgetDevice `json:"Devices"`
+}
+
+type widgetDevice struct {
+	Name      string       `json:"Name"`
+	Connected bool         `json:"Connected"`
+	Azimuth   *float64     `json:"Azimuth,omitempty"`   // Degrees, rounded to 0.1
+	Shutter   string       `json:"Shutter,omitempty"`   // Shutter status of a dome
+	Battery   *float64     `json:"Battery,omitempty"`   // Volts, rounded to 0.1
+	LastEvent *widgetEvent `json:"LastEvent,omitempty"` // Last connection, error or notification
+}
+
+type widgetEvent struct {
+	Time    int64  `json:"Time"` // Unix time in seconds
+	Type    string `json:"Type"`
+	Message string `json:"Message,omitempty"`
+}
+
+// lastEvents keeps the last event of each device for the widgets. The state
+// changes are left out, they are reported by the status itself.
+type lastEvents struct {
+	mu     sync.Mutex
+	events map[string]widgetEvent // By device name
+}
+
+func (l *lastEvents) handleEvent(e Event) {
+	if e.Type == EventStateChanged {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	typ := string(e.Type)
+	if e.Notification != "" {
+		typ = string(e.Notification)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.events == nil {
+		l.events = make(map[string]widgetEvent)
+	}
+	l.events[e.Device] = widgetEvent{Time: e.Time.Unix(), Type: typ, Message: e.Message}
+}
+
+func (l *lastEvents) get(device string) *widgetEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.events[device]; ok {
+		return &e
+	}
+	return nil
+}
+
+// roundTenth rounds a value to 0.1, so the noise of the telemetry does not
+// change the ETag of the widget status.
+func roundTenth(v float64) *float64 {
+	v = math.Round(v*10) / 10
+	return &v
+}
+
+// handleWidgetStatus serves the compact status of the enabled devices for
+// mobile widgets. The response carries an ETag: a widget polling with
+// If-None-Match gets an empty 304 response until something changes, to save
+// data over cellular links.
+func (s *Server) handleWidgetStatus(w http.ResponseWriter, r *http.Request) {
+	status := widgetStatus{Devices: []widgetDevice{}}
+	for _, dev := range s.devices {
+		if !deviceEnabled(dev) {
+			continue
+		}
+
+		name := dev.DeviceInfo().Name
+		wd := widgetDevice{
+			Name:      name,
+			Connected: dev.Connected(),
+			LastEvent: s.lastEvents.get(name),
+		}
+		if d, ok := dev.(Dome); ok && wd.Connected {
+			ds := d.Status()
+			wd.Azimuth = roundTenth(ds.Azimuth)
+			if d.Capabilities().CanSetShutter {
+				wd.Shutter = ds.Shutter.String()
+			}
+		}
+		if b, ok := dev.(BatteryReporter); ok && wd.Connected {
+			if volts, ok := b.BatteryVoltage(); ok {
+				wd.Battery = roundTenth(volts)
+			}
+		}
+		status.Devices = append(status.Devices, wd)
+	}
+
+	body, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, sha1.Sum(body))
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Debugf("Failed to write the widget status: %v", err)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header matches the ETag. Weak
+// validators match as well, as required for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -8,19 +8,37 @@
 package dome
 
 import (
+	"alpaca/pkg/metrics"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var ErrNotConnected = fmt.Errorf("driver is not connected")
 
+// ErrCommandRejected is returned when the controller answers a command with
+// a NACK.
+var ErrCommandRejected = errors.New("command rejected by the controller")
+
+// ErrRawCommandNotAllowed is returned by SendRaw for the commands that must
+// not bypass the driver.
+var ErrRawCommandNotAllowed = errors.New("command not allowed as a raw command")
+
 type Direction int
 
 const (
@@ -28,6 +46,17 @@ const (
 	DirCCW
 )
 
+func (d Direction) String() string {
+	switch d {
+	case DirCW:
+		return "CW"
+	case DirCCW:
+		return "CCW"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(d))
+	}
+}
+
 type ShutterCommand int
 
 const (
@@ -46,9 +75,30 @@ const (
 	ShutterStatusError
 )
 
+func (s ShutterStatus) String() string {
+	switch s {
+	case ShutterStatusClosed:
+		return "Closed"
+	case ShutterStatusOpening:
+		return "Opening"
+	case ShutterStatusOpen:
+		return "Open"
+	case ShutterStatusClosing:
+		return "Closing"
+	case ShutterStatusAborted:
+		return "Aborted"
+	case ShutterStatusError:
+		return "Error"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
 type cmdCode uint8
 
-// Dome commands
+// Dome commands. Each one is sent framed as "_<code>;", or "_<code>=<value>;"
+// with a value. The U command relays the command code given as its value to
+// the shutter controller: "_U=A;" aborts the shutter motor.
 const (
 	// Configuration commands
 	cmdLoad    cmdCode = 'L' // Load dome configuration parameters
@@ -60,7 +110,7 @@ const (
 	cmdDisconnectShutter cmdCode = 'Z' // Disconnect from the shutter
 	cmdOpenShutter       cmdCode = 'O' // Open shutter
 	cmdCloseShutter      cmdCode = 'C' // Close shutter
-	cmdShutter           cmdCode = 'U' // Send a command to the shutter
+	cmdShutter           cmdCode = 'U' // Relay a command to the shutter, as U=<code>
 
 	// Dome movement commands
 	cmdAbort cmdCode = 'A' // Abort azimuth movement
@@ -82,30 +132,47 @@ const (
 
 type MQTTConfig struct {
 	Host      string
+	Failover  []string // Brokers tried in order when Host cannot be reached
 	Username  string
 	Password  string
 	TopicRoot string // Root topic for the ZRO dome controller
 }
 
+// Brokers returns the broker URLs in the order they are tried, starting
+// with Host.
+func (c MQTTConfig) Brokers() []string {
+	brokers := make([]string, 0, 1+len(c.Failover))
+	for _, b := range append([]string{c.Host}, c.Failover...) {
+		if b = strings.TrimSpace(b); b != "" && !slices.Contains(brokers, b) {
+			brokers = append(brokers, b)
+		}
+	}
+	return brokers
+}
+
 type Config struct {
 	MQTTConfig
 
-	TicksPerTurn   int     // Encoder ticks per dome revolution
-	Tolerance      int     // Tolerance in encoder ticks
-	HomePosition   float64 // Home position in degrees
-	ParkPosition   float64 // Park position in degrees
-	AzimuthTimeout int     // Azimuth timeout in seconds
-	MaxSpeed       int     // Maximum speed in encoder ticks per second
-	MinSpeed       int     // Minimum speed in encoder ticks per second
-	BrakeSpeed     int     // Brake speed in encoder ticks per second
-	EncoderDiv     int     // Encoder divisor (for high-resolution encoders)
-	VelTimeout     int     // Velocity timeout in seconds
-	ShortDistance  int     // Short distance in encoder ticks
-	ParkOnShutter  bool    // True if the dome should park on shutter
-	ShutterTimeout int     // Shutter timeout in seconds
-	UseShutter     bool    // True if the shutter is used
+	TicksPerTurn   int           // Encoder ticks per dome revolution
+	Tolerance      int           // Tolerance in encoder ticks
+	HomePosition   float64       // Home position in degrees
+	ParkPosition   float64       // Park position in degrees
+	AzimuthTimeout time.Duration // Azimuth timeout, sent to the firmware in milliseconds
+	MaxSpeed       int           // Maximum speed in encoder ticks per second
+	MinSpeed       int           // Minimum speed in encoder ticks per second
+	BrakeSpeed     int           // Brake speed in encoder ticks per second
+	EncoderDiv     int           // Divisor of the azimuth encoder count, for high-resolution encoders
+	VelTimeout     time.Duration // Velocity timeout, sent to the firmware in seconds
+	ShortDistance  int           // Short distance in encoder ticks
+	ParkOnShutter  bool          // True if the dome rotates to the park position before the shutter closes
+	ShutterTimeout time.Duration // Shutter timeout
+	UseShutter     bool          // True if the shutter is used
 }
 
+// maxTimeout is the longest timeout accepted in the configuration. It catches
+// values entered in the wrong unit, such as milliseconds taken as seconds.
+const maxTimeout = 10 * time.Minute
+
 func DefaultConfig() Config {
 	return Config{
 		MQTTConfig: MQTTConfig{
@@ -118,11 +185,11 @@ func DefaultConfig() Config {
 		Tolerance:      4,
 		HomePosition:   0,
 		ParkPosition:   0,
-		AzimuthTimeout: 20000,
+		AzimuthTimeout: 20 * time.Second,
 		MaxSpeed:       200,
 		MinSpeed:       30,
 		BrakeSpeed:     80,
-		VelTimeout:     10,
+		VelTimeout:     10 * time.Second,
 		ShortDistance:  100,
 		ParkOnShutter:  false,
 		ShutterTimeout: 0,
@@ -131,27 +198,16 @@ func DefaultConfig() Config {
 	}
 }
 
+// Validate checks the firmware parameters against the bounds of the Params
+// schema, and the settings of the driver itself.
 func (c *Config) Validate() error {
-	if c.TicksPerTurn <= 0 {
-		return fmt.Errorf("ticks per turn must be greater than 0")
-	}
-	if c.Tolerance < 0 {
-		return fmt.Errorf("tolerance must be non-negative")
-	}
-	if c.AzimuthTimeout <= 0 {
-		return fmt.Errorf("azimuth timeout must be greater than 0")
-	}
-	if c.MaxSpeed <= 0 {
-		return fmt.Errorf("maximum speed must be greater than 0")
-	}
-	if c.MinSpeed <= 0 {
-		return fmt.Errorf("minimum speed must be greater than 0")
-	}
-	if c.BrakeSpeed <= 0 {
-		return fmt.Errorf("brake speed must be greater than 0")
+	for _, p := range Params {
+		if err := p.check(*c); err != nil {
+			return err
+		}
 	}
-	if c.EncoderDiv <= 0 {
-		return fmt.Errorf("encoder divisor must be greater than 0")
+	if c.ShutterTimeout < 0 || c.ShutterTimeout > maxTimeout {
+		return fmt.Errorf("shutter timeout must be non-negative and at most %v", maxTimeout)
 	}
 	return nil
 }
@@ -164,8 +220,9 @@ type Status struct {
 	Dir      Direction // Direction of movement (CW or CCW)
 	Target   int       // Target position in encoder ticks
 
-	Temperature float32
-	Humidity    float32
+	Temperature     float32   // Degrees Celsius
+	Humidity        float32   // Percent
+	EnvironmentTime time.Time // When the temperature or the humidity was last received, zero if never
 
 	BatteryVoltage float32
 	BatteryCurrent float32
@@ -174,27 +231,8 @@ type Status struct {
 
 	Shutter          ShutterStatus // Shutter status
 	ShutterConnected bool          // True if shutter is connected
-}
-
-// telemetryMsg represents the telemetry message received periodically from the
-// ZRO dome controller under the "telemetry" topic.
-type telemetryMsg struct {
-	AzState     int           `json:"az_state"` // State of the azimuth state machine
-	ShState     ShutterStatus `json:"sh_state"`
-	Position    int           `json:"pos"`
-	Home        int           `json:"home"`
-	Dir         int           `json:"dir"`
-	Target      int           `json:"target"`
-	Link        int           `json:"link"`
-	Temperature float32       `json:"temp"`
-	Humidity    float32       `json:"hum"`
-}
 
-// batteryMsg represents the battery message received periodically from the
-// ZRO dome controller under the "battery" topic.“
-type batteryMsg struct {
-	Voltage float32 `json:"batt_voltage"`
-	Current float32 `json:"batt_current"`
+	TelemetryTime time.Time // When the last telemetry was received, zero if none yet
 }
 
 type Response struct {
@@ -218,18 +256,52 @@ func normalizeAngle(angle float64) float64 {
 	return math.Mod(angle+360, 360)
 }
 
+// normalizeTicks brings an encoder count to the range [0, ticksPerTurn).
+// The firmware counter keeps counting after a full turn, above ticksPerTurn
+// when turning clockwise and below zero when turning counterclockwise past
+// home, so both directions are folded back onto a single turn.
+func normalizeTicks(ticks, ticksPerTurn int) int {
+	return ((ticks % ticksPerTurn) + ticksPerTurn) % ticksPerTurn
+}
+
+// responseQueueSize is the number of controller responses queued for the
+// command waiting for them.
+const responseQueueSize = 16
+
+// echoWindow is how long a published command is remembered to recognize its
+// echo.
+const echoWindow = 10 * time.Second
+
 // Dome represents the ZRO dome controller.
 // It is controlled via MQTT messages.
 type Dome struct {
+	*controller
+	ctx context.Context // Context of the commands, whose span they follow, if set
+}
+
+// controller is the state of a dome controller, shared by the Dome values
+// bound to the contexts of the requests.
+type controller struct {
 	client mqtt.Client // MQTT client
 
+	mu     sync.RWMutex // Protects status, updated from the MQTT callbacks
 	status Status
 	config Config // Configuration parameters
 
-	responseChan chan Response // Channel for responses from the ZRO dome controller
+	cmdMu        sync.Mutex    // Serializes the commands, so each one gets its own response
+	responseChan chan Response // Bounded queue of the responses from the ZRO dome controller
 	logger       log.FieldLogger
+	panics       atomic.Int64 // Panics recovered in the MQTT message handlers
 
-	// shutterLink bool   // True if the shutter is linked to the dome
+	droppedResponses     atomic.Int64 // Responses dropped because the queue was full
+	unsolicitedResponses atomic.Int64 // Responses that matched no pending command
+
+	sentMu sync.Mutex
+	sent   map[string]time.Time // Recently published commands, to recognize their echoes
+	echoes atomic.Int64         // Echoes of our own commands ignored
+
+	histogram *AzimuthHistogram // Time spent slewing through each azimuth sector, if set
+	slewLog   *SlewLog          // Commanded and achieved azimuths of the slews, if set
 }
 
 func NewDome(client mqtt.Client, config Config, logger log.FieldLogger) (*Dome, error) {
@@ -237,12 +309,13 @@ func NewDome(client mqtt.Client, config Config, logger log.FieldLogger) (*Dome,
 		return nil, fmt.Errorf("invalid configuration: %v", err)
 	}
 
-	dome := &Dome{
+	dome := &Dome{controller: &controller{
 		client:       client,
 		config:       config,
-		responseChan: make(chan Response, 1),
+		responseChan: make(chan Response, responseQueueSize),
+		sent:         make(map[string]time.Time),
 		logger:       logger,
-	}
+	}}
 
 	// Initialize shutter status as unknown/closed
 	dome.status.Shutter = ShutterStatusClosed
@@ -251,50 +324,81 @@ func NewDome(client mqtt.Client, config Config, logger log.FieldLogger) (*Dome,
 	return dome, nil
 }
 
+// WithContext returns the dome sending its commands in a context, such as
+// the one of the Alpaca request asking for them, so that their spans are
+// children of the span of the request. The returned dome shares the state of
+// d.
+func (d *Dome) WithContext(ctx context.Context) *Dome {
+	return &Dome{controller: d.controller, ctx: ctx}
+}
+
+// Config returns the configuration of the dome.
+func (d *Dome) Config() Config {
+	return d.config
+}
+
 func (d *Dome) DegreesToTicks(degrees float64) int {
-	return int(normalizeAngle(degrees-d.config.HomePosition) * float64(d.config.TicksPerTurn) / 360.0)
+	return degreesToTicks(degrees, d.config)
+}
+
+// degreesToTicks converts an azimuth to encoder ticks from the home position.
+func degreesToTicks(degrees float64, config Config) int {
+	return int(normalizeAngle(degrees-config.HomePosition) * float64(config.TicksPerTurn) / 360.0)
 }
 
 func (d *Dome) TicksToDegrees(ticks int) float64 {
-	return float64(ticks)*360.0/float64(d.config.TicksPerTurn) + d.config.HomePosition
+	ticks = normalizeTicks(ticks, d.config.TicksPerTurn)
+	return normalizeAngle(float64(ticks)*360.0/float64(d.config.TicksPerTurn) + d.config.HomePosition)
 }
 
-// Run connects to the ZRO dome controller and subscribes to the necessary topics.
-// When the context is cancelled, it unsubscribes from the topics and disconnects.
+// Run starts the dome, then reads the info periodically until the context
+// is cancelled.
 func (d *Dome) Run(ctx context.Context) error {
+	if err := d.Start(); err != nil {
+		return err
+	}
+	d.Serve(ctx)
+	return nil
+}
+
+// Start subscribes to the topics of the controller, links the shutter and
+// sends the configuration. It returns once the dome accepts commands, which
+// may take several seconds while the shutter link is retried. On failure, it
+// undoes what it did.
+func (d *Dome) Start() (err error) {
 	if !d.client.IsConnected() {
 		return fmt.Errorf("MQTT client is not connected")
 	}
+	linked := false
+	defer func() {
+		if err != nil {
+			d.stop(linked)
+		}
+	}()
 
 	root := d.config.MQTTConfig.TopicRoot
 
 	// Subscribe to telemetry topic
-	telemetryTopic := root + "/telemetry"
-	if token := d.client.Subscribe(telemetryTopic, 0, d.telemetryHandler); token.Wait() && token.Error() != nil {
+	if token := d.client.Subscribe(root+"/telemetry", 0, d.recoverHandler(d.telemetryHandler)); token.Wait() && token.Error() != nil {
 		return fmt.Errorf("failed to subscribe to telemetry topic: %v", token.Error())
 	}
-	defer d.client.Unsubscribe(telemetryTopic)
 
 	// Subscribe to battery topic
-	batteryTopic := root + "/battery"
-	if token := d.client.Subscribe(batteryTopic, 0, d.batteryHandler); token.Wait() && token.Error() != nil {
+	if token := d.client.Subscribe(root+"/battery", 0, d.recoverHandler(d.batteryHandler)); token.Wait() && token.Error() != nil {
 		return fmt.Errorf("failed to subscribe to battery topic: %v", token.Error())
 	}
-	defer d.client.Unsubscribe(batteryTopic)
 
 	// Subscribe to responses topic
-	responseTopic := root + "/responses"
-	if token := d.client.Subscribe(responseTopic, 0, d.responseHandler); token.Wait() && token.Error() != nil {
+	if token := d.client.Subscribe(root+"/responses", 0, d.recoverHandler(d.responseHandler)); token.Wait() && token.Error() != nil {
 		return fmt.Errorf("failed to subscribe to responses topic: %v", token.Error())
 	}
-	defer d.client.Unsubscribe(responseTopic)
 
 	// Connect to the shutter
 	if d.config.UseShutter {
 		if err := d.connectShutter(); err != nil {
 			return fmt.Errorf("failed to connect to shutter: %v", err)
 		}
-		defer d.disconnectShutter()
+		linked = true
 	}
 
 	// Read status, firmware version and battery status
@@ -312,44 +416,125 @@ func (d *Dome) Run(ctx context.Context) error {
 	if err := d.setConfig(d.config); err != nil {
 		return fmt.Errorf("failed to set configuration: %v", err)
 	}
+	return nil
+}
 
+// Serve waits until the context is cancelled, then unlinks the shutter and
+// unsubscribes from the controller topics. The dome must have been started.
+func (d *Dome) Serve(ctx context.Context) {
 	<-ctx.Done()
 	d.logger.Info("Stopping ZRO dome controller")
-	return nil
+	d.stop(d.config.UseShutter)
+}
+
+// stop undoes Start, unlinking the shutter if it was linked.
+func (d *Dome) stop(unlink bool) {
+	if unlink {
+		d.disconnectShutter()
+	}
+
+	root := d.config.MQTTConfig.TopicRoot
+	d.client.Unsubscribe(root+"/telemetry", root+"/battery", root+"/responses")
 }
 
 // sendCommandWithTimeout sends a command and waits for response with custom timeout
 func (d *Dome) sendCommandWithTimeout(cmd string, timeout time.Duration) error {
+	_, err := d.request(cmd, timeout)
+	return err
+}
+
+// commandRoundTrips are the times between the publication of the commands
+// and their responses, by topic root and command code, to graph the latency
+// of the broker and of the controller link.
+var commandRoundTrips = metrics.NewHistogramVec("zro_mqtt_command_seconds",
+	"Round trip time of the MQTT commands to the dome controllers, by topic root and command code.",
+	[]float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}, "topic", "command")
+
+// tracer traces the commands, from their publication to the response of the
+// controller.
+var tracer = otel.Tracer("alpaca/pkg/dome")
+
+// request sends a command and returns the response of the controller.
+func (d *Dome) request(cmd string, timeout time.Duration) (_ Response, err error) {
+	ctx := d.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	topic := d.config.TopicRoot + "/commands"
+	_, span := tracer.Start(ctx, "zro command "+string(cmd[0]), trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "mqtt"),
+			attribute.String("messaging.destination.name", topic),
+			attribute.String("zro.command", cmd),
+		))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	if !d.client.IsConnected() {
-		return ErrNotConnected
+		return Response{}, ErrNotConnected
 	}
 
+	d.cmdMu.Lock()
+	defer d.cmdMu.Unlock()
+	span.AddEvent("command lock acquired")
+
+	// Responses left in the queue answer commands that gave up waiting.
+	d.discardResponses()
+
 	// Create the message string
 	msg := "_" + cmd + ";"
 	d.logger.Debugf("Sending command: %s", msg)
 
 	// Publish the command to the ZRO dome controller
-	topic := d.config.TopicRoot + "/commands"
+	sent := time.Now()
+	d.rememberSent(msg, sent)
 	if token := d.client.Publish(topic, 0, false, msg); token.Wait() && token.Error() != nil {
-		return fmt.Errorf("failed to publish command: %v", token.Error())
+		return Response{}, fmt.Errorf("failed to publish command: %v", token.Error())
 	}
+	span.AddEvent("published")
+
+	// Wait for the response with custom timeout, skipping the responses to
+	// other commands.
+	deadline := time.After(timeout)
+	for {
+		select {
+		case resp := <-d.responseChan:
+			if resp.Code != cmdCode(cmd[0]) {
+				d.unsolicitedResponses.Add(1)
+				d.logger.Warnf("Ignoring unsolicited response %c while waiting for %c", resp.Code, cmd[0])
+				continue
+			}
+			commandRoundTrips.Observe(time.Since(sent).Seconds(), d.config.TopicRoot, string(resp.Code))
+			span.AddEvent("response", trace.WithAttributes(attribute.Bool("zro.rejected", resp.Error)))
 
-	// Wait for the response with custom timeout
-	select {
-	case resp := <-d.responseChan:
-		if resp.Error {
-			return fmt.Errorf("command failed: %c", resp.Code)
-		}
+			if resp.Error {
+				return resp, fmt.Errorf("%w: %c", ErrCommandRejected, resp.Code)
+			}
 
-		if resp.Code != cmdCode(cmd[0]) {
-			return fmt.Errorf("unexpected response command: %c", resp.Code)
-		}
+			d.logger.Debugf("Response: %+v", resp)
+			return resp, nil
 
-		d.logger.Debugf("Response: %+v", resp)
-		return nil
+		case <-deadline:
+			return Response{}, fmt.Errorf("timeout waiting for response")
+		}
+	}
+}
 
-	case <-time.After(timeout):
-		return fmt.Errorf("timeout waiting for response")
+// discardResponses empties the response queue.
+func (d *Dome) discardResponses() {
+	for {
+		select {
+		case resp := <-d.responseChan:
+			d.unsolicitedResponses.Add(1)
+			d.logger.Warnf("Discarding unsolicited response %c", resp.Code)
+		default:
+			return
+		}
 	}
 }
 
@@ -357,73 +542,291 @@ func (d *Dome) sendCommand(cmd string) error {
 	return d.sendCommandWithTimeout(cmd, 5*time.Second)
 }
 
-// setConfig sends the configuration to the ZRO dome controller.
-// Each parameter is sent as a command with the format "_L<param>=<value>;"
-// All values are integers. Example: "_LTICK=1000;"
+// rawAllowed are the commands SendRaw sends: the read-only queries of the
+// controller. Everything else, the motions, the parameters and the shutter
+// link included, goes through the driver, so the arbitration, the interlocks,
+// the watchdog and the slaving see it.
+var rawAllowed = []cmdCode{cmdStatus, cmdVersion, cmdBattery, cmdTemperature, cmdHumidity, cmdHelp}
+
+// relayForbidden are the commands of the controller that a relay command
+// must not use, since the driver sends them itself.
+var relayForbidden = []cmdCode{
+	cmdLoad, cmdSetPark, cmdTicks,
+	cmdConnectShutter, cmdDisconnectShutter, cmdOpenShutter, cmdCloseShutter, cmdShutter,
+	cmdAbort, cmdMove, cmdHome, cmdGoto, cmdPark,
+}
+
+// unframe strips the framing of a raw command, so "_V;" becomes "V".
+func unframe(cmd string) (string, error) {
+	cmd = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(cmd), "_"), ";")
+	if cmd == "" {
+		return "", fmt.Errorf("empty command")
+	}
+	return cmd, nil
+}
+
+// SendRaw sends a raw command, such as "V" or "_V;", to the controller for
+// diagnostics, and returns the value of its response, empty if the response
+// carries none. Only the read-only queries are sent, the other commands are
+// refused with ErrRawCommandNotAllowed.
+func (d *Dome) SendRaw(cmd string) (string, error) {
+	cmd, err := unframe(cmd)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(rawAllowed, cmdCode(cmd[0])) {
+		return "", fmt.Errorf("%w: %q is not a read-only query, use the driver methods", ErrRawCommandNotAllowed, cmd)
+	}
+	return d.sendRaw(cmd)
+}
+
+// CheckRelayCommand checks that a relay command, such as "_Y1=1;", is not one
+// of the commands the driver sends itself.
+func CheckRelayCommand(cmd string) error {
+	cmd, err := unframe(cmd)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(relayForbidden, cmdCode(cmd[0])) {
+		return fmt.Errorf("%w: %q is a command of the driver, not a relay command", ErrRawCommandNotAllowed, cmd)
+	}
+	return nil
+}
+
+// SendRelay sends the command of a relay, as set in the configuration, and
+// returns the value of its response. The command is checked with
+// CheckRelayCommand.
+func (d *Dome) SendRelay(cmd string) (string, error) {
+	if err := CheckRelayCommand(cmd); err != nil {
+		return "", err
+	}
+	cmd, _ = unframe(cmd)
+	return d.sendRaw(cmd)
+}
+
+func (d *Dome) sendRaw(cmd string) (string, error) {
+	resp, err := d.request(cmd, 5*time.Second)
+	if err != nil {
+		return "", err
+	}
+	value, _ := resp.Value.(string)
+	return value, nil
+}
+
+// RequestBattery asks the shutter for a new reading of its battery, which
+// the controller publishes on the battery topic.
+func (d *Dome) RequestBattery() error {
+	if !d.config.UseShutter {
+		return fmt.Errorf("shutter not supported")
+	}
+	return d.sendCommand(string(cmdBattery))
+}
+
+// ReadEnvironment reads the temperature and the humidity at once, with the
+// "t" and "u" commands, instead of waiting for the next telemetry.
+func (d *Dome) ReadEnvironment() error {
+	var values [2]float32
+	for i, cmd := range []cmdCode{cmdTemperature, cmdHumidity} {
+		resp, err := d.request(string(cmd), 5*time.Second)
+		if err != nil {
+			return err
+		}
+		value, _ := resp.Value.(string)
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 32)
+		if err != nil {
+			return fmt.Errorf("invalid %c response %q", cmd, value)
+		}
+		values[i] = float32(v)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status.Temperature, d.status.Humidity = values[0], values[1]
+	d.status.EnvironmentTime = time.Now()
+	return nil
+}
+
+// setConfig sends the configuration to the ZRO dome controller, following
+// the Params schema. Each parameter is sent as a command with the format
+// "_L<param>=<value>;", for example "_LTICK=1000;". Parameters that the
+// firmware version does not support are skipped.
 func (d *Dome) setConfig(config Config) error {
 	if !d.client.IsConnected() {
 		return ErrNotConnected
 	}
 
-	cfgMap := map[string]int{
-		"TICK": config.TicksPerTurn,
-		"TOLE": config.Tolerance,
-		"PKPO": d.DegreesToTicks(config.ParkPosition),
-		"AZTO": config.AzimuthTimeout,
-		"MXSP": config.MaxSpeed,
-		"MNSP": config.MinSpeed,
-		"BKSP": config.BrakeSpeed,
-		"VLTO": config.VelTimeout,
-		"SHDS": config.ShortDistance,
-		"POSH": boolToInt(config.ParkOnShutter),
-		"ENDV": config.EncoderDiv, // Encoder divisor for the shutter
-	}
-
-	for param, value := range cfgMap {
-		if err := d.sendCommand(fmt.Sprintf("%c%s=%d", cmdLoad, param, value)); err != nil {
-			return fmt.Errorf("failed to send config parameter %s: %v", param, err)
+	version := d.GetStatus().Version
+	for _, p := range Params {
+		if !p.Supported(version) {
+			d.logger.Infof("Skipping config parameter %s, not supported by firmware %s", p.Code, version)
+			continue
+		}
+		if err := d.sendCommand(fmt.Sprintf("%c%s=%d", cmdLoad, p.Code, p.FirmwareValue(config))); err != nil {
+			return fmt.Errorf("failed to send config parameter %s: %v", p.Code, err)
 		}
 	}
 	return nil
 }
 
+// recoverHandler wraps an MQTT message handler so that a panic caused by a
+// malformed payload is logged instead of crashing the process.
+func (d *Dome) recoverHandler(handler mqtt.MessageHandler) mqtt.MessageHandler {
+	return func(client mqtt.Client, msg mqtt.Message) {
+		defer func() {
+			if p := recover(); p != nil {
+				d.panics.Add(1)
+				d.logger.Errorf("Recovered from panic handling message on %s: %v (payload %q)\n%s",
+					msg.Topic(), p, msg.Payload(), debug.Stack())
+			}
+		}()
+
+		handler(client, msg)
+	}
+}
+
+// Panics returns the number of panics recovered in the MQTT message handlers.
+func (d *Dome) Panics() int64 {
+	return d.panics.Load()
+}
+
+// DroppedResponses returns the number of responses dropped because the
+// response queue was full.
+func (d *Dome) DroppedResponses() int64 {
+	return d.droppedResponses.Load()
+}
+
+// Echoes returns the number of echoes of our own commands that were ignored.
+func (d *Dome) Echoes() int64 {
+	return d.echoes.Load()
+}
+
+// UnsolicitedResponses returns the number of responses that did not answer
+// the pending command.
+func (d *Dome) UnsolicitedResponses() int64 {
+	return d.unsolicitedResponses.Load()
+}
+
 // telemetryHandler processes the telemetry messages.
 func (d *Dome) telemetryHandler(client mqtt.Client, msg mqtt.Message) {
-	var telemetry telemetryMsg
-	if err := json.Unmarshal(msg.Payload(), &telemetry); err != nil {
+	telemetry, err := parseTelemetry(msg.Payload())
+	if err != nil {
 		d.logger.Errorf("Failed to unmarshal telemetry message: %v", err)
 		return
 	}
 
-	d.logger.Debugf("Telemetry: %+v", telemetry)
+	d.logger.Debugf("Telemetry: %s", msg.Payload())
 
-	d.status.Position = telemetry.Position
-	d.status.Dir = Direction(telemetry.Dir)
-	d.status.Target = telemetry.Target
-	d.status.AtHome = telemetry.Home == 1
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.status.TelemetryTime = time.Now()
+
+	// The fields missing from the message keep their last value, so a
+	// firmware that stops sending one does not move the dome to north.
+	if telemetry.Dir != nil {
+		d.status.Dir = Direction(*telemetry.Dir)
+	}
+	if telemetry.Position != nil {
+		d.status.Position = normalizeTicks(*telemetry.Position, d.config.TicksPerTurn)
+		if d.status.Position != *telemetry.Position {
+			d.logger.Debugf("Encoder count %d wrapped to %d turning %s", *telemetry.Position, d.status.Position, d.status.Dir)
+		}
+	}
+	if telemetry.Target != nil {
+		d.status.Target = normalizeTicks(*telemetry.Target, d.config.TicksPerTurn)
+	}
+	if telemetry.Home != nil {
+		d.status.AtHome = *telemetry.Home == 1
+	}
 
 	// Determine if the dome is slewing
-	d.status.Slewing = telemetry.AzState > 0 && telemetry.AzState < 5
+	if telemetry.AzState != nil {
+		d.status.Slewing = *telemetry.AzState > 0 && *telemetry.AzState < 5
+	}
+
+	if telemetry.ShState != nil {
+		d.status.Shutter = ShutterStatus(*telemetry.ShState)
+	}
+
+	// The link of the shutter controller is reported with the telemetry, so
+	// a link lost after the connection is noticed.
+	if telemetry.Link != nil {
+		linked := *telemetry.Link == 1
+		if linked != d.status.ShutterConnected {
+			if linked {
+				d.logger.Info("Shutter link restored")
+			} else {
+				d.logger.Warn("Shutter link lost")
+			}
+		}
+		d.status.ShutterConnected = linked
+	}
+
+	if telemetry.Temperature != nil {
+		d.status.Temperature = *telemetry.Temperature
+		d.status.EnvironmentTime = d.status.TelemetryTime
+	}
+	if telemetry.Humidity != nil {
+		d.status.Humidity = *telemetry.Humidity
+		d.status.EnvironmentTime = d.status.TelemetryTime
+	}
 
-	d.status.Temperature = telemetry.Temperature
-	d.status.Humidity = telemetry.Humidity
+	if d.histogram != nil {
+		d.histogram.Record(d.TicksToDegrees(d.status.Position), d.status.Slewing, time.Now())
+	}
+	if d.slewLog != nil {
+		if r, done := d.slewLog.Record(d.TicksToDegrees(d.status.Position), d.status.Slewing, time.Now()); done {
+			d.logger.Infof("Slew to %.2f ended at %.2f degrees (%+.2f) after %v", r.Target, r.Achieved, r.Offset(), r.Duration.Round(time.Second))
+		}
+	}
+}
+
+// SetAzimuthHistogram sets the histogram fed with the telemetry. The caller
+// keeps it, so the statistics survive a new controller after a reconnection.
+func (d *Dome) SetAzimuthHistogram(h *AzimuthHistogram) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.histogram = h
+}
+
+// SetSlewLog sets the log of the slews fed with the telemetry. The caller
+// keeps it, so the records survive a new controller after a reconnection.
+func (d *Dome) SetSlewLog(l *SlewLog) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.slewLog = l
 }
 
 // batteryHandler processes the battery messages.
 func (d *Dome) batteryHandler(client mqtt.Client, msg mqtt.Message) {
-	var battery batteryMsg
-	if err := json.Unmarshal(msg.Payload(), &battery); err != nil {
+	battery, err := parseBattery(msg.Payload())
+	if err != nil {
 		d.logger.Errorf("Failed to unmarshal battery message: %v", err)
 		return
 	}
 
-	d.logger.Debugf("Battery: %+v", battery)
+	d.logger.Debugf("Battery: %s", msg.Payload())
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-	d.status.BatteryVoltage = battery.Voltage
-	d.status.BatteryCurrent = battery.Current
+	if battery.Voltage != nil {
+		d.status.BatteryVoltage = *battery.Voltage
+	}
+	if battery.Current != nil {
+		d.status.BatteryCurrent = *battery.Current
+	}
 }
 
 func (d *Dome) responseHandler(client mqtt.Client, msg mqtt.Message) {
+	if d.isEcho(string(msg.Payload()), time.Now()) {
+		d.echoes.Add(1)
+		d.logger.Debugf("Ignoring echo of our command %s", msg.Payload())
+		return
+	}
+
 	resp, err := parseResponse(string(msg.Payload()))
 	if err != nil {
 		d.logger.Errorf("Failed to parse response: %v", err)
@@ -431,12 +834,67 @@ func (d *Dome) responseHandler(client mqtt.Client, msg mqtt.Message) {
 	}
 	d.logger.Debugf("Response received: %+v", resp)
 
+	d.updateStatus(resp)
+	d.queueResponse(resp)
+}
+
+// rememberSent records a published command so that its echo is recognized.
+func (d *Dome) rememberSent(msg string, now time.Time) {
+	d.sentMu.Lock()
+	defer d.sentMu.Unlock()
+
+	d.sent[msg] = now
+}
+
+// isEcho reports whether a message received on the responses topic is one of
+// our own commands, looped back by a bridged broker. The firmware protocol
+// has no room for a client ID, but commands never look like responses, so a
+// payload matching a command we recently published can only be its echo.
+func (d *Dome) isEcho(payload string, now time.Time) bool {
+	d.sentMu.Lock()
+	defer d.sentMu.Unlock()
+
+	for msg, at := range d.sent {
+		if now.Sub(at) > echoWindow {
+			delete(d.sent, msg)
+		}
+	}
+	_, ok := d.sent[payload]
+	return ok
+}
+
+// queueResponse queues a response without ever blocking the MQTT callback.
+// When the queue is full the oldest response is dropped, since the command
+// it answered has most likely given up waiting for it.
+func (d *Dome) queueResponse(resp Response) {
+	for {
+		select {
+		case d.responseChan <- resp:
+			return
+		default:
+		}
+
+		select {
+		case old := <-d.responseChan:
+			d.droppedResponses.Add(1)
+			d.logger.Warnf("Response queue full, dropping response %c", old.Code)
+		default:
+		}
+	}
+}
+
+// updateStatus updates the status with the information carried by a response.
+func (d *Dome) updateStatus(resp Response) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	// Handle the response based on the command
 	switch resp.Code {
 	case cmdStatus:
 	case cmdBattery:
 	case cmdVersion:
-		d.status.Version = strings.Trim(resp.Value.(string), "()")
+		version, _ := resp.Value.(string)
+		d.status.Version = strings.Trim(version, "()")
 		d.logger.Infof("Dome controller firmware version: %s", d.status.Version)
 	case cmdConnectShutter:
 		if !resp.Error {
@@ -450,14 +908,6 @@ func (d *Dome) responseHandler(client mqtt.Client, msg mqtt.Message) {
 		d.status.ShutterConnected = false
 		d.logger.Info("Shutter disconnected")
 	}
-
-	// Attempt to send the response to the channel with a timeout
-	select {
-	case d.responseChan <- resp:
-		// Successfully sent the response
-	case <-time.After(1 * time.Second):
-		d.logger.Warn("Timeout while sending response to the channel")
-	}
 }
 
 // Responses have the format:
@@ -498,18 +948,44 @@ func parseResponse(msg string) (Response, error) {
 }
 
 func (d *Dome) GetStatus() Status {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	return d.status
 }
 
 func (d *Dome) SlewToAzimuth(az float64) error {
 	ticks := d.DegreesToTicks(az)
-	return d.sendCommand(fmt.Sprintf("%c=%d", cmdGoto, ticks))
+	if err := d.sendCommand(fmt.Sprintf("%c=%d", cmdGoto, ticks)); err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.slewLog != nil {
+		d.slewLog.Start(d.TicksToDegrees(d.status.Position), az, time.Now())
+	}
+	return nil
 }
 
 func (d *Dome) AbortSlew() error {
+	d.mu.RLock()
+	if d.slewLog != nil {
+		d.slewLog.Abort()
+	}
+	d.mu.RUnlock()
 	return d.sendCommand(string(cmdAbort))
 }
 
+// AbortShutter stops the shutter motor. The controller relays the abort
+// command to the shutter.
+func (d *Dome) AbortShutter() error {
+	if !d.config.UseShutter {
+		return fmt.Errorf("shutter not supported")
+	}
+	return d.sendCommand(fmt.Sprintf("%c=%c", cmdShutter, cmdAbort))
+}
+
 func (d *Dome) FindHome() error {
 	return d.sendCommand(string(cmdHome))
 }
@@ -520,7 +996,7 @@ func (d *Dome) Park() error {
 
 func (d *Dome) SetPark() error {
 	// Get current position as the new park position
-	currentTicks := d.status.Position
+	currentTicks := d.GetStatus().Position
 
 	// Send the park position using the load command
 	return d.sendCommand(fmt.Sprintf("%c%s=%d", cmdLoad, "PKPO", currentTicks))
@@ -532,17 +1008,22 @@ func (d *Dome) SetShutter(command ShutterCommand) error {
 	}
 
 	var cmd cmdCode
+	var status ShutterStatus
 	switch command {
 	case ShutterOpen:
 		cmd = cmdOpenShutter
-		d.status.Shutter = ShutterStatusOpening
+		status = ShutterStatusOpening
 	case ShutterClose:
 		cmd = cmdCloseShutter
-		d.status.Shutter = ShutterStatusClosing
+		status = ShutterStatusClosing
 	default:
 		return fmt.Errorf("invalid shutter command: %d", command)
 	}
 
+	d.mu.Lock()
+	d.status.Shutter = status
+	d.mu.Unlock()
+
 	return d.sendCommand(string(cmd))
 }
 
@@ -590,7 +1071,9 @@ func (d *Dome) disconnectShutter() error {
 	}
 
 	// Update status regardless of command success
+	d.mu.Lock()
 	d.status.ShutterConnected = false
+	d.mu.Unlock()
 	d.logger.Info("Shutter disconnected")
 
 	return nil
@@ -1,9 +1,21 @@
 package dome
 
 import (
+	"context"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestParseResponse(t *testing.T) {
@@ -88,6 +100,14 @@ func TestParseResponse(t *testing.T) {
 	}
 }
 
+func TestBrokers(t *testing.T) {
+	cfg := MQTTConfig{Host: "tcp://site:1883"}
+	assert.Equal(t, []string{"tcp://site:1883"}, cfg.Brokers())
+
+	cfg.Failover = []string{" tcp://cloud:1883", "", "tcp://site:1883"}
+	assert.Equal(t, []string{"tcp://site:1883", "tcp://cloud:1883"}, cfg.Brokers(), "blanks and duplicates are dropped")
+}
+
 func TestNormalizeAngle(t *testing.T) {
 	assert.Equal(t, 0.0, normalizeAngle(0.0))
 	assert.Equal(t, 45.0, normalizeAngle(45.0))
@@ -99,3 +119,316 @@ func TestNormalizeAngle(t *testing.T) {
 	assert.Equal(t, 85.0, normalizeAngle(3685.0))
 	assert.Equal(t, 30.0, normalizeAngle(-3570.0))
 }
+
+func TestNormalizeTicks(t *testing.T) {
+	const turn = 10476
+
+	assert.Equal(t, 0, normalizeTicks(0, turn))
+	assert.Equal(t, 100, normalizeTicks(100, turn))
+	assert.Equal(t, 0, normalizeTicks(turn, turn))
+	assert.Equal(t, 100, normalizeTicks(3*turn+100, turn), "clockwise past several turns")
+	assert.Equal(t, turn-100, normalizeTicks(-100, turn), "counterclockwise past home")
+	assert.Equal(t, turn-100, normalizeTicks(-3*turn-100, turn), "counterclockwise past several turns")
+}
+
+func TestTicksToDegreesAfterTurns(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TicksPerTurn = 3600
+	cfg.HomePosition = 350
+	d, err := NewDome(nil, cfg, log.New())
+	require.NoError(t, err)
+
+	assert.InDelta(t, 350.0, d.TicksToDegrees(0), 1e-9)
+	assert.InDelta(t, 0.0, d.TicksToDegrees(100), 1e-9, "wraps past 360 with the home offset")
+	assert.InDelta(t, 10.0, d.TicksToDegrees(5*3600+200), 1e-9)
+	assert.InDelta(t, 340.0, d.TicksToDegrees(-100), 1e-9)
+
+	d.telemetryHandler(nil, &fakeMessage{payload: []byte(`{"pos":-100,"target":7300,"dir":1}`)})
+	st := d.GetStatus()
+	assert.Equal(t, 3500, st.Position)
+	assert.Equal(t, 100, st.Target)
+	assert.Equal(t, DirCCW, st.Dir)
+}
+
+// fakeMessage implements mqtt.Message for feeding payloads to the handlers.
+type fakeMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *fakeMessage) Duplicate() bool   { return false }
+func (m *fakeMessage) Qos() byte         { return 0 }
+func (m *fakeMessage) Retained() bool    { return false }
+func (m *fakeMessage) Topic() string     { return m.topic }
+func (m *fakeMessage) MessageID() uint16 { return 0 }
+func (m *fakeMessage) Payload() []byte   { return m.payload }
+func (m *fakeMessage) Ack()              {}
+
+func TestRecoverHandler(t *testing.T) {
+	d, err := NewDome(nil, DefaultConfig(), log.New())
+	assert.NoError(t, err)
+
+	handler := d.recoverHandler(func(mqtt.Client, mqtt.Message) {
+		var values []int
+		_ = values[1]
+	})
+	assert.NotPanics(t, func() {
+		handler(nil, &fakeMessage{topic: "/ZRO/telemetry", payload: []byte("{}")})
+	})
+	assert.Equal(t, int64(1), d.Panics())
+}
+
+// TestStatusConcurrentAccess is meant to be run with -race.
+func TestStatusConcurrentAccess(t *testing.T) {
+	d, err := NewDome(nil, DefaultConfig(), log.New())
+	assert.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			d.telemetryHandler(nil, &fakeMessage{payload: []byte(`{"pos":100,"az_state":1}`)})
+			d.batteryHandler(nil, &fakeMessage{payload: []byte(`{"batt_voltage":12.5}`)})
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		d.GetStatus()
+	}
+	<-done
+
+	assert.Equal(t, 100, d.GetStatus().Position)
+	assert.True(t, d.GetStatus().Slewing)
+}
+
+// doneToken is an mqtt.Token that is already complete.
+type doneToken struct{}
+
+func (doneToken) Wait() bool                     { return true }
+func (doneToken) WaitTimeout(time.Duration) bool { return true }
+func (doneToken) Done() <-chan struct{}          { ch := make(chan struct{}); close(ch); return ch }
+func (doneToken) Error() error                   { return nil }
+
+// replyClient is an MQTT client that answers each published command with the
+// response returned by reply, as the controller would.
+type replyClient struct {
+	mqtt.Client
+	dome  *Dome
+	reply func(cmd string) string
+	echo  bool // Loop the commands back to the responses, as a bridged broker may
+
+	mu        sync.Mutex
+	published []string
+}
+
+func (c *replyClient) IsConnected() bool { return true }
+
+func (c *replyClient) Publish(topic string, qos byte, retained bool, payload any) mqtt.Token {
+	cmd := payload.(string)
+
+	c.mu.Lock()
+	c.published = append(c.published, cmd)
+	c.mu.Unlock()
+
+	go func() {
+		if c.echo {
+			c.dome.responseHandler(c, &fakeMessage{topic: "/ZRO/responses", payload: []byte(cmd)})
+		}
+		c.dome.responseHandler(c, &fakeMessage{topic: "/ZRO/responses", payload: []byte(c.reply(cmd))})
+	}()
+	return doneToken{}
+}
+
+func (c *replyClient) commands() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.published...)
+}
+
+// newReplyDome returns a dome whose client answers with reply.
+func newReplyDome(t *testing.T, reply func(cmd string) string) (*Dome, *replyClient) {
+	t.Helper()
+
+	client := &replyClient{reply: reply}
+	d, err := NewDome(client, DefaultConfig(), log.StandardLogger())
+	require.NoError(t, err)
+	client.dome = d
+	return d, client
+}
+
+// ack acknowledges every command.
+func ack(cmd string) string {
+	return "_ACK_" + strings.TrimSuffix(strings.TrimPrefix(cmd, "_"), ";") + ";"
+}
+
+func TestResponseQueueOverflow(t *testing.T) {
+	d, err := NewDome(nil, DefaultConfig(), log.New())
+	require.NoError(t, err)
+
+	// Nobody waits for these responses, yet the handler must not block.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < responseQueueSize+5; i++ {
+			d.responseHandler(nil, &fakeMessage{payload: []byte("_ACK_S;")})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("response handler blocked on a full queue")
+	}
+	assert.Equal(t, int64(5), d.DroppedResponses())
+	assert.Len(t, d.responseChan, responseQueueSize)
+}
+
+func TestUnsolicitedResponses(t *testing.T) {
+	// The controller sends a stray battery response before each answer.
+	d, client := newReplyDome(t, ack)
+	client.reply = func(cmd string) string {
+		d.responseHandler(client, &fakeMessage{payload: []byte("_ACK_B=12.5;")})
+		return ack(cmd)
+	}
+
+	require.NoError(t, d.sendCommand("V"))
+	assert.Equal(t, int64(1), d.UnsolicitedResponses())
+
+	// A response left over from a timed out command is discarded.
+	d.queueResponse(Response{Code: cmdStatus})
+	require.NoError(t, d.sendCommand("V"))
+	assert.Equal(t, int64(3), d.UnsolicitedResponses())
+}
+
+func TestIgnoreEchoes(t *testing.T) {
+	d, client := newReplyDome(t, ack)
+	client.echo = true
+
+	require.NoError(t, d.sendCommand("V"))
+	require.NoError(t, d.sendCommand("S"))
+	assert.Equal(t, int64(2), d.Echoes())
+	assert.Zero(t, d.UnsolicitedResponses())
+
+	// Commands are forgotten once no echo can be expected.
+	now := time.Now()
+	assert.True(t, d.isEcho("_V;", now))
+	assert.False(t, d.isEcho("_V;", now.Add(echoWindow+time.Second)))
+	assert.False(t, d.isEcho("_ACK_V;", now))
+}
+
+func TestSendRaw(t *testing.T) {
+	d, client := newReplyDome(t, func(cmd string) string {
+		if cmd == "_V;" {
+			return "_ACK_V=(2.3);"
+		}
+		return "_NACK_" + strings.Trim(cmd, "_;") + ";"
+	})
+
+	value, err := d.SendRaw("_V;")
+	require.NoError(t, err)
+	assert.Equal(t, "(2.3)", value)
+
+	_, err = d.SendRaw("h")
+	assert.ErrorIs(t, err, ErrCommandRejected)
+	_, err = d.SendRaw(" ")
+	assert.Error(t, err)
+	for _, cmd := range []string{"_R;", "F", "Q", "G=180", "_P;", "H", "K", "O", "C", "U=A", "_LTICK=100;", "T=3600", "X", "_Z;"} {
+		_, err = d.SendRaw(cmd)
+		assert.ErrorIs(t, err, ErrRawCommandNotAllowed, cmd)
+	}
+	assert.Equal(t, []string{"_V;", "_h;"}, client.commands(), "the refused commands are not sent")
+}
+
+func TestAbortShutter(t *testing.T) {
+	d, client := newReplyDome(t, ack)
+
+	require.NoError(t, d.AbortShutter())
+	assert.Equal(t, []string{"_U=A;"}, client.commands())
+
+	d.config.UseShutter = false
+	assert.Error(t, d.AbortShutter())
+	assert.Len(t, client.commands(), 1, "not sent without a shutter")
+}
+
+func TestSendRelay(t *testing.T) {
+	d, client := newReplyDome(t, ack)
+
+	_, err := d.SendRelay("_Y1=1;")
+	require.NoError(t, err)
+	for _, cmd := range []string{"_LTICK=100;", "T=3600", "X", "G=180", "_U=A;"} {
+		_, err = d.SendRelay(cmd)
+		assert.ErrorIs(t, err, ErrRawCommandNotAllowed, cmd)
+	}
+	_, err = d.SendRelay(" ")
+	assert.Error(t, err)
+	assert.Equal(t, []string{"_Y1=1;"}, client.commands(), "the refused commands are not sent")
+}
+
+func TestCommandSpans(t *testing.T) {
+	// The tracer of the package delegates to the first provider set, so the
+	// spans of the package are only recorded in this test.
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+
+	d, _ := newReplyDome(t, func(cmd string) string {
+		if cmd == "_V;" {
+			return "_ACK_V=(2.3);"
+		}
+		return "_NACK_" + strings.Trim(cmd, "_;") + ";"
+	})
+	ctx, request := provider.Tracer("test").Start(context.Background(), "PUT dome/0/commandstring")
+	_, err := d.WithContext(ctx).SendRaw("V")
+	require.NoError(t, err)
+	_, err = d.SendRaw("h")
+	require.ErrorIs(t, err, ErrCommandRejected)
+	request.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	// The command of a request is a child of its span.
+	span := spans[0]
+	assert.Equal(t, "zro command V", span.Name())
+	assert.Equal(t, request.SpanContext().SpanID(), span.Parent().SpanID())
+	assert.Contains(t, span.Attributes(), attribute.String("messaging.destination.name", "/ZRO/commands"))
+	var events []string
+	for _, e := range span.Events() {
+		events = append(events, e.Name)
+	}
+	assert.Equal(t, []string{"command lock acquired", "published", "response"}, events)
+	assert.Equal(t, codes.Unset, span.Status().Code)
+
+	// The commands sent outside of a request start their own trace.
+	span = spans[1]
+	assert.Equal(t, "zro command h", span.Name())
+	assert.False(t, span.Parent().IsValid())
+	assert.Equal(t, codes.Error, span.Status().Code)
+}
+
+func TestSetConfig(t *testing.T) {
+	d, client := newReplyDome(t, ack)
+
+	cfg := DefaultConfig()
+	cfg.TicksPerTurn = 3600
+	cfg.HomePosition = 10
+	cfg.ParkPosition = 100
+	cfg.ParkOnShutter = true
+	cfg.EncoderDiv = 4
+	require.NoError(t, d.setConfig(cfg))
+
+	assert.Equal(t, []string{
+		"_LTICK=3600;",
+		"_LENDV=4;",
+		"_LTOLE=4;",
+		"_LPKPO=900;", // 90 degrees from home, with the ticks per turn being sent
+		"_LAZTO=20000;",
+		"_LMXSP=200;",
+		"_LMNSP=30;",
+		"_LBKSP=80;",
+		"_LVLTO=10;",
+		"_LSHDS=100;",
+		"_LPOSH=1;",
+	}, client.commands())
+}
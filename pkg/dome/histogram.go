@@ -0,0 +1,115 @@
+package dome
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// histogramBinWidth is the azimuth width of a histogram bin, in degrees.
+	histogramBinWidth = 10.0
+
+	// histogramMaxGap is the longest interval between two telemetry samples
+	// that is accounted, so a missed sample does not credit a bin with the
+	// time of a whole slew.
+	histogramMaxGap = 5 * time.Second
+
+	// slowBinMinPasses is the number of passes through a bin needed before it
+	// can be flagged as slow.
+	slowBinMinPasses = 3
+
+	// slowBinFactor is how much longer than the median a pass through a bin
+	// takes to flag it as slow.
+	slowBinFactor = 2.0
+)
+
+// AzimuthBin is the time spent slewing through an azimuth sector.
+type AzimuthBin struct {
+	From   float64       // Start of the sector, in degrees
+	To     float64       // End of the sector, in degrees
+	Time   time.Duration // Total time spent slewing through the sector
+	Passes int           // Number of times the dome slewed into the sector
+	Slow   bool          // True if a pass takes much longer than through the other sectors
+}
+
+// MeanPass returns the mean time of a pass through the sector.
+func (b AzimuthBin) MeanPass() time.Duration {
+	if b.Passes == 0 {
+		return 0
+	}
+	return b.Time / time.Duration(b.Passes)
+}
+
+// AzimuthHistogram aggregates the time the dome spends slewing through each
+// azimuth sector. Sectors where a pass takes much longer than elsewhere point
+// to a mechanical issue, such as a sticking wheel or an unbalanced dome.
+type AzimuthHistogram struct {
+	mu   sync.Mutex
+	bins []AzimuthBin
+
+	slewing bool      // True if the dome was slewing at the last sample
+	bin     int       // Bin of the last sample
+	at      time.Time // Time of the last sample
+}
+
+func NewAzimuthHistogram() *AzimuthHistogram {
+	n := int(360 / histogramBinWidth)
+	h := &AzimuthHistogram{bins: make([]AzimuthBin, n)}
+	for i := range h.bins {
+		h.bins[i].From = float64(i) * histogramBinWidth
+		h.bins[i].To = float64(i+1) * histogramBinWidth
+	}
+	return h
+}
+
+// Record adds a telemetry sample. The time since the previous sample is
+// credited to the bin of the previous sample if the dome was slewing then.
+func (h *AzimuthHistogram) Record(azimuth float64, slewing bool, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bin := int(normalizeAngle(azimuth)/histogramBinWidth) % len(h.bins)
+
+	if h.slewing {
+		if dt := now.Sub(h.at); dt > 0 && dt <= histogramMaxGap {
+			h.bins[h.bin].Time += dt
+		}
+	}
+	if slewing && (!h.slewing || bin != h.bin) {
+		h.bins[bin].Passes++
+	}
+
+	h.slewing = slewing
+	h.bin = bin
+	h.at = now
+}
+
+// Bins returns the bins, starting at azimuth 0, with the slow ones flagged.
+func (h *AzimuthHistogram) Bins() []AzimuthBin {
+	h.mu.Lock()
+	bins := slices.Clone(h.bins)
+	h.mu.Unlock()
+
+	var means []float64
+	for _, b := range bins {
+		if b.Passes >= slowBinMinPasses {
+			means = append(means, float64(b.MeanPass()))
+		}
+	}
+	if len(means) < 3 {
+		return bins
+	}
+
+	slices.Sort(means)
+	median := means[len(means)/2]
+	if len(means)%2 == 0 {
+		median = (means[len(means)/2-1] + median) / 2
+	}
+
+	for i, b := range bins {
+		mean := float64(b.MeanPass())
+		bins[i].Slow = b.Passes >= slowBinMinPasses && median > 0 && mean > slowBinFactor*median
+	}
+	return bins
+}
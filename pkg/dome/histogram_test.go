@@ -0,0 +1,67 @@
+package dome
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slew records a slew from one azimuth to another at the given speed, with a
+// telemetry sample every second.
+func slew(h *AzimuthHistogram, from, to, speed float64, now time.Time) time.Time {
+	for az := from; az < to; az += speed {
+		h.Record(az, true, now)
+		now = now.Add(time.Second)
+	}
+	h.Record(to, false, now)
+	return now.Add(time.Minute)
+}
+
+func TestAzimuthHistogram(t *testing.T) {
+	h := NewAzimuthHistogram()
+	now := time.Now()
+
+	for range 3 {
+		now = slew(h, 0, 45, 5, now)
+	}
+
+	bins := h.Bins()
+	require.Len(t, bins, 36)
+	assert.Equal(t, AzimuthBin{From: 10, To: 20, Time: 6 * time.Second, Passes: 3}, bins[1])
+	assert.Equal(t, 2*time.Second, bins[1].MeanPass())
+	assert.Zero(t, bins[5].Passes, "sectors never reached")
+
+	// The idle time between the slews is not accounted.
+	assert.Equal(t, 3*time.Second, bins[4].Time, "the time until the slew stops is accounted")
+}
+
+func TestAzimuthHistogramSlowSector(t *testing.T) {
+	h := NewAzimuthHistogram()
+	now := time.Now()
+
+	for range 3 {
+		now = slew(h, 0, 30, 5, now)
+		now = slew(h, 30, 40, 1, now) // The dome slows down between 30 and 40 degrees
+		now = slew(h, 40, 70, 5, now)
+	}
+
+	bins := h.Bins()
+	assert.True(t, bins[3].Slow)
+	for i, b := range bins {
+		if i != 3 {
+			assert.False(t, b.Slow, "sector %v", b.From)
+		}
+	}
+}
+
+func TestAzimuthHistogramGap(t *testing.T) {
+	h := NewAzimuthHistogram()
+	now := time.Now()
+
+	h.Record(5, true, now)
+	h.Record(6, true, now.Add(time.Minute))
+	assert.Zero(t, h.Bins()[0].Time, "a missed telemetry does not credit the sector")
+	assert.Equal(t, 1, h.Bins()[0].Passes)
+}
@@ -0,0 +1,262 @@
+package dome
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:generate go test -run TestParamsDoc -update
+
+// ParamKind is the kind of value of a firmware parameter.
+type ParamKind int
+
+const (
+	ParamInt      ParamKind = iota // Integer, sent as is
+	ParamAzimuth                   // Azimuth in degrees, sent in encoder ticks from the home position
+	ParamDuration                  // Duration set in seconds, sent in the firmware unit
+	ParamBool                      // Flag, sent as 0 or 1
+)
+
+// Param describes a configuration parameter of the ZRO dome controller,
+// sent with the command "_L<code>=<value>;". The schema is used to push the
+// configuration, to validate it, to generate the setup form and the
+// documentation.
+type Param struct {
+	Code        string        // Firmware code of the parameter
+	Field       string        // Name of the setup form field
+	Label       string        // Label of the setup form field
+	Group       string        // Section of the setup form: "geometry" or "motion"
+	Help        string        // Description shown on the setup form and in the documentation
+	Kind        ParamKind     // Kind of value
+	Unit        string        // Unit of the value as set, such as "ticks/s"
+	Min, Max    float64       // Bounds of the value as set, in Unit; no upper bound if Max is 0
+	Step        float64       // Step of the setup form input, 1 if 0
+	Firmware    time.Duration // Unit of the durations sent to the firmware
+	MinFirmware string        // Oldest firmware version supporting the parameter, empty if all do
+
+	field func(c *Config) any // Returns a pointer to the Config field
+}
+
+// Params is the schema of the firmware parameters, in the order they are
+// sent. The ticks per turn come first, since the firmware checks the park
+// position against them.
+var Params = []Param{
+	{
+		Code: "TICK", Field: "ticks-per-turn", Label: "Encoder ticks per revolution", Group: "geometry",
+		Help: "Encoder count of a full dome revolution.",
+		Kind: ParamInt, Unit: "ticks", Min: 1,
+		field: func(c *Config) any { return &c.TicksPerTurn },
+	},
+	{
+		Code: "ENDV", Field: "encoder-div", Label: "Encoder divisor", Group: "geometry",
+		Help: "Divisor of the azimuth encoder count, for high-resolution encoders.",
+		Kind: ParamInt, Min: 1,
+		field: func(c *Config) any { return &c.EncoderDiv },
+	},
+	{
+		Code: "TOLE", Field: "tolerance", Label: "Tolerance", Group: "geometry",
+		Help: "Distance to the target within which the dome is on target.",
+		Kind: ParamInt, Unit: "ticks", Min: 0,
+		field: func(c *Config) any { return &c.Tolerance },
+	},
+	{
+		Code: "PKPO", Field: "park-position", Label: "Park position", Group: "geometry",
+		Help: "Azimuth of the park position.",
+		Kind: ParamAzimuth, Unit: "degrees", Min: 0, Max: 360, Step: 0.1,
+		field: func(c *Config) any { return &c.ParkPosition },
+	},
+	{
+		Code: "AZTO", Field: "azimuth-timeout", Label: "Azimuth timeout", Group: "motion",
+		Help: "Timeout of the azimuth movements.",
+		Kind: ParamDuration, Unit: "seconds", Min: 0.1, Max: maxTimeout.Seconds(), Step: 0.1, Firmware: time.Millisecond,
+		field: func(c *Config) any { return &c.AzimuthTimeout },
+	},
+	{
+		Code: "MXSP", Field: "max-speed", Label: "Maximum speed", Group: "motion",
+		Help: "Highest motor speed.",
+		Kind: ParamInt, Unit: "ticks/s", Min: 1,
+		field: func(c *Config) any { return &c.MaxSpeed },
+	},
+	{
+		Code: "MNSP", Field: "min-speed", Label: "Minimum speed", Group: "motion",
+		Help: "Lowest motor speed.",
+		Kind: ParamInt, Unit: "ticks/s", Min: 1,
+		field: func(c *Config) any { return &c.MinSpeed },
+	},
+	{
+		Code: "BKSP", Field: "brake-speed", Label: "Brake speed", Group: "motion",
+		Help: "Motor speed while braking.",
+		Kind: ParamInt, Unit: "ticks/s", Min: 1,
+		field: func(c *Config) any { return &c.BrakeSpeed },
+	},
+	{
+		Code: "VLTO", Field: "vel-timeout", Label: "Velocity timeout", Group: "motion",
+		Help: "Timeout of the motor speed control.",
+		Kind: ParamDuration, Unit: "seconds", Min: 0, Max: maxTimeout.Seconds(), Firmware: time.Second,
+		field: func(c *Config) any { return &c.VelTimeout },
+	},
+	{
+		Code: "SHDS", Field: "short-distance", Label: "Short distance", Group: "motion",
+		Help: "Distance below which a slew is handled as a short move.",
+		Kind: ParamInt, Unit: "ticks", Min: 0,
+		field: func(c *Config) any { return &c.ShortDistance },
+	},
+	{
+		Code: "POSH", Field: "park-on-shutter", Label: "Park on shutter", Group: "motion",
+		Help:  "Rotate the dome to the park position before closing the shutter, for shutters powered or latched at the park position.",
+		Kind:  ParamBool,
+		field: func(c *Config) any { return &c.ParkOnShutter },
+	},
+}
+
+// value returns the value of the parameter as set, in Unit.
+func (p Param) value(c Config) float64 {
+	switch v := p.field(&c).(type) {
+	case *int:
+		return float64(*v)
+	case *float64:
+		return *v
+	case *time.Duration:
+		return v.Seconds()
+	case *bool:
+		return float64(boolToInt(*v))
+	default:
+		panic(fmt.Sprintf("parameter %s: unsupported field type %T", p.Code, v))
+	}
+}
+
+// FirmwareValue returns the value sent to the firmware.
+func (p Param) FirmwareValue(c Config) int {
+	switch v := p.field(&c).(type) {
+	case *float64:
+		return degreesToTicks(*v, c)
+	case *time.Duration:
+		return int(*v / p.Firmware)
+	default:
+		return int(p.value(c))
+	}
+}
+
+// FormValue returns the value of the setup form field.
+func (p Param) FormValue(c Config) string {
+	switch v := p.field(&c).(type) {
+	case *bool:
+		return strconv.FormatBool(*v)
+	case *int:
+		return strconv.Itoa(*v)
+	default:
+		return strconv.FormatFloat(p.value(c), 'f', -1, 64)
+	}
+}
+
+// Parse sets the parameter from the value of the setup form field. An
+// unchecked checkbox sends no value.
+func (p Param) Parse(c *Config, value string) error {
+	value = strings.TrimSpace(value)
+	var err error
+	switch v := p.field(c).(type) {
+	case *bool:
+		*v = value == "true"
+	case *int:
+		*v, err = strconv.Atoi(value)
+	case *float64:
+		*v, err = strconv.ParseFloat(value, 64)
+	case *time.Duration:
+		var seconds float64
+		seconds, err = strconv.ParseFloat(value, 64)
+		*v = time.Duration(seconds * float64(time.Second))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %q", strings.ToLower(p.Label), value)
+	}
+	return nil
+}
+
+// check returns an error if the value is out of bounds.
+func (p Param) check(c Config) error {
+	if p.Kind == ParamBool {
+		return nil
+	}
+
+	v := p.value(c)
+	switch {
+	case p.Max != 0 && (v < p.Min || v > p.Max):
+		return fmt.Errorf("%s must be between %s", strings.ToLower(p.Label), strings.TrimSpace(fmt.Sprintf("%v and %v %s", p.Min, p.Max, p.Unit)))
+	case v < p.Min:
+		return fmt.Errorf("%s must be at least %s", strings.ToLower(p.Label), strings.TrimSpace(fmt.Sprintf("%v %s", p.Min, p.Unit)))
+	}
+	return nil
+}
+
+// Supported reports whether a firmware version accepts the parameter. An
+// unknown version is assumed to accept all of them.
+func (p Param) Supported(version string) bool {
+	return p.MinFirmware == "" || version == "" || compareVersions(version, p.MinFirmware) >= 0
+}
+
+// compareVersions compares two dotted versions, such as 1.2.3, numerically.
+// Missing or non-numeric components count as 0.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// WriteParamsDoc writes the documentation of the firmware parameters as a
+// Markdown table.
+func WriteParamsDoc(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("| Code | Setting | Unit | Range | Sent as | Firmware | Description |\n")
+	b.WriteString("|------|---------|------|-------|---------|----------|-------------|\n")
+	for _, p := range Params {
+		fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s | %s | %s |\n",
+			p.Code, p.Label, p.Unit, p.rangeDoc(), p.sentAs(), p.firmwareDoc(), p.Help)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (p Param) rangeDoc() string {
+	switch {
+	case p.Kind == ParamBool:
+		return "on/off"
+	case p.Max != 0:
+		return fmt.Sprintf("%v to %v", p.Min, p.Max)
+	default:
+		return fmt.Sprintf("≥ %v", p.Min)
+	}
+}
+
+func (p Param) sentAs() string {
+	switch p.Kind {
+	case ParamAzimuth:
+		return "ticks from home"
+	case ParamDuration:
+		return map[time.Duration]string{time.Millisecond: "milliseconds", time.Second: "seconds"}[p.Firmware]
+	case ParamBool:
+		return "0 or 1"
+	default:
+		return "as set"
+	}
+}
+
+func (p Param) firmwareDoc() string {
+	if p.MinFirmware == "" {
+		return "all"
+	}
+	return p.MinFirmware + " and later"
+}
@@ -0,0 +1,117 @@
+package dome
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the generated documentation")
+
+// param returns the parameter of the schema with the firmware code.
+func param(t *testing.T, code string) Param {
+	t.Helper()
+	for _, p := range Params {
+		if p.Code == code {
+			return p
+		}
+	}
+	t.Fatalf("parameter %s not found", code)
+	return Param{}
+}
+
+func TestParamsFirmwareValue(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TicksPerTurn = 3600
+	cfg.HomePosition = 10
+	cfg.ParkPosition = 100
+	cfg.AzimuthTimeout = 1500 * time.Millisecond
+	cfg.VelTimeout = 12 * time.Second
+
+	assert.Equal(t, 900, param(t, "PKPO").FirmwareValue(cfg), "ticks from home")
+	assert.Equal(t, 1500, param(t, "AZTO").FirmwareValue(cfg), "milliseconds")
+	assert.Equal(t, 12, param(t, "VLTO").FirmwareValue(cfg), "seconds")
+	assert.Equal(t, 0, param(t, "POSH").FirmwareValue(cfg))
+	cfg.ParkOnShutter = true
+	assert.Equal(t, 1, param(t, "POSH").FirmwareValue(cfg))
+}
+
+func TestParamsForm(t *testing.T) {
+	cfg := DefaultConfig()
+	for _, p := range Params {
+		parsed := DefaultConfig()
+		require.NoError(t, p.Parse(&parsed, p.FormValue(cfg)), p.Code)
+		assert.Equal(t, p.FormValue(cfg), p.FormValue(parsed), "%s round trip", p.Code)
+	}
+
+	p := param(t, "AZTO")
+	require.NoError(t, p.Parse(&cfg, "2.5"))
+	assert.Equal(t, 2500*time.Millisecond, cfg.AzimuthTimeout)
+	assert.EqualError(t, param(t, "MXSP").Parse(&cfg, "fast"), `invalid maximum speed: "fast"`)
+
+	require.NoError(t, param(t, "POSH").Parse(&cfg, ""), "unchecked")
+	assert.False(t, cfg.ParkOnShutter)
+}
+
+func TestValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.Validate())
+
+	cfg.MaxSpeed = 0
+	assert.EqualError(t, cfg.Validate(), "maximum speed must be at least 1 ticks/s")
+
+	cfg = DefaultConfig()
+	cfg.EncoderDiv = 0
+	assert.EqualError(t, cfg.Validate(), "encoder divisor must be at least 1")
+
+	cfg = DefaultConfig()
+	cfg.AzimuthTimeout = time.Hour
+	assert.EqualError(t, cfg.Validate(), "azimuth timeout must be between 0.1 and 600 seconds")
+}
+
+func TestParamsFirmwareGate(t *testing.T) {
+	p := Param{MinFirmware: "1.10"}
+	assert.True(t, p.Supported(""), "unknown version")
+	assert.True(t, p.Supported("1.10.0"))
+	assert.True(t, p.Supported("2.0"))
+	assert.False(t, p.Supported("1.9.5"), "compared numerically")
+	assert.True(t, Param{}.Supported("0.1"))
+}
+
+func TestSetConfigSkipsUnsupported(t *testing.T) {
+	saved := Params
+	t.Cleanup(func() { Params = saved })
+	Params = []Param{param(t, "TICK"), param(t, "ENDV")}
+	Params[1].MinFirmware = "2.0"
+
+	d, client := newReplyDome(t, ack)
+	d.status.Version = "1.2.3"
+	require.NoError(t, d.setConfig(DefaultConfig()))
+	assert.Equal(t, []string{"_LTICK=10476;"}, client.commands())
+}
+
+// TestParamsDoc checks the generated documentation of the firmware
+// parameters. Run go generate to update it.
+func TestParamsDoc(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteParamsDoc(&buf))
+
+	const path = "../../docs/firmware-parameters.md"
+	header := "# ZRO Firmware Parameters\n\n" +
+		"<!-- Code generated by go generate ./pkg/dome; DO NOT EDIT. -->\n\n" +
+		"The driver sends these parameters to the controller when it connects, in this order, with the command `_L<code>=<value>;`. " +
+		"They are set on the dome setup page.\n\n"
+	want := header + buf.String()
+
+	if *update {
+		require.NoError(t, os.WriteFile(path, []byte(want), 0o644))
+	}
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(got), "run go generate ./pkg/dome")
+}
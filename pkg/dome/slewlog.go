@@ -0,0 +1,110 @@
+package dome
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// slewLogSize is the number of slews kept, the oldest are dropped.
+	slewLogSize = 500
+
+	// slewLogSettle is how long a commanded slew may go without the telemetry
+	// reporting it, after which the dome is taken as already on target.
+	slewLogSettle = 5 * time.Second
+)
+
+// SlewRecord is a slew with the azimuth commanded and the one reached.
+type SlewRecord struct {
+	Time     time.Time     // When the slew was commanded
+	From     float64       // Azimuth at the start, in degrees
+	Target   float64       // Commanded azimuth, in degrees
+	Achieved float64       // Azimuth where the dome stopped, in degrees
+	Duration time.Duration // Time until the dome stopped
+}
+
+// Offset returns the signed difference between the achieved and the
+// commanded azimuths, in degrees, positive clockwise of the target.
+func (r SlewRecord) Offset() float64 {
+	return math.Mod(r.Achieved-r.Target+540, 360) - 180
+}
+
+// SlewLog records the commanded and achieved azimuths of the slews, to
+// measure the accuracy and the repeatability of the positioning.
+type SlewLog struct {
+	mu      sync.Mutex
+	records []SlewRecord // Oldest first
+	pending *SlewRecord  // Slew in progress, if any
+	started bool         // True once the telemetry reported the pending slew
+}
+
+func NewSlewLog() *SlewLog {
+	return &SlewLog{}
+}
+
+// Start records a commanded slew, replacing one still in progress.
+func (l *SlewLog) Start(from, target float64, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pending = &SlewRecord{Time: now, From: normalizeAngle(from), Target: normalizeAngle(target)}
+	l.started = false
+}
+
+// Abort forgets the slew in progress, which tells nothing of the accuracy.
+func (l *SlewLog) Abort() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.pending = nil
+}
+
+// Record adds a telemetry sample, and returns the slew in progress once the
+// dome stopped.
+func (l *SlewLog) Record(azimuth float64, slewing bool, now time.Time) (SlewRecord, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending == nil {
+		return SlewRecord{}, false
+	}
+	if slewing {
+		l.started = true
+		return SlewRecord{}, false
+	}
+	if !l.started && now.Sub(l.pending.Time) < slewLogSettle {
+		return SlewRecord{}, false
+	}
+
+	r := *l.pending
+	r.Achieved = normalizeAngle(azimuth)
+	r.Duration = now.Sub(r.Time)
+	l.pending = nil
+
+	if len(l.records) == slewLogSize {
+		l.records = slices.Delete(l.records, 0, 1)
+	}
+	l.records = append(l.records, r)
+	return r, true
+}
+
+// Pending returns the slew in progress, if any.
+func (l *SlewLog) Pending() (SlewRecord, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending == nil {
+		return SlewRecord{}, false
+	}
+	return *l.pending, true
+}
+
+// Records returns the slews, oldest first.
+func (l *SlewLog) Records() []SlewRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return slices.Clone(l.records)
+}
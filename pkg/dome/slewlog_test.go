@@ -0,0 +1,48 @@
+package dome
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlewLog(t *testing.T) {
+	l := NewSlewLog()
+	now := time.Now()
+
+	_, done := l.Record(10, false, now)
+	assert.False(t, done, "no slew commanded")
+
+	l.Start(350, 10, now)
+	pending, ok := l.Pending()
+	require.True(t, ok)
+	assert.Equal(t, 10.0, pending.Target)
+	_, done = l.Record(350, false, now.Add(time.Second))
+	assert.False(t, done, "not reported by the telemetry yet")
+	_, done = l.Record(0, true, now.Add(2*time.Second))
+	assert.False(t, done)
+	r, done := l.Record(9.5, false, now.Add(4*time.Second))
+	require.True(t, done)
+	assert.Equal(t, SlewRecord{Time: now, From: 350, Target: 10, Achieved: 9.5, Duration: 4 * time.Second}, r)
+	assert.InDelta(t, -0.5, r.Offset(), 1e-9)
+
+	// A slew to the azimuth the dome is at is never reported.
+	l.Start(9.5, 10, now)
+	_, done = l.Record(9.5, false, now.Add(time.Second))
+	assert.False(t, done)
+	r, done = l.Record(9.5, false, now.Add(slewLogSettle))
+	require.True(t, done)
+	assert.Equal(t, 9.5, r.Achieved)
+
+	// An aborted slew is not recorded.
+	l.Start(0, 180, now)
+	l.Record(90, true, now.Add(time.Second))
+	l.Abort()
+	_, done = l.Record(90, false, now.Add(2*time.Second))
+	assert.False(t, done)
+
+	assert.Len(t, l.Records(), 2)
+	assert.InDelta(t, 1.0, SlewRecord{Target: 359.5, Achieved: 0.5}.Offset(), 1e-9, "across north")
+}
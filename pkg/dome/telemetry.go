@@ -0,0 +1,144 @@
+package dome
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// telemetryMsg represents the telemetry message received periodically from the
+// ZRO dome controller under the "telemetry" topic. A nil field was missing
+// from the message, or could not be read, and leaves the status unchanged.
+type telemetryMsg struct {
+	AzState     *int // State of the azimuth state machine
+	ShState     *int
+	Position    *int
+	Home        *int
+	Dir         *int
+	Target      *int
+	Link        *int
+	Temperature *float32
+	Humidity    *float32
+}
+
+// batteryMsg represents the battery message received periodically from the
+// ZRO dome controller under the "battery" topic.
+type batteryMsg struct {
+	Voltage *float32
+	Current *float32
+}
+
+// The names of the telemetry and battery fields, the current one first,
+// followed by the names used by other firmware versions. The names are
+// matched in any case.
+var (
+	azStateNames     = []string{"az_state", "azstate", "az_st"}
+	shStateNames     = []string{"sh_state", "shstate", "shutter"}
+	positionNames    = []string{"pos", "position", "az_pos"}
+	homeNames        = []string{"home", "at_home"}
+	dirNames         = []string{"dir", "direction"}
+	targetNames      = []string{"target", "tgt", "az_target"}
+	linkNames        = []string{"link", "sh_link"}
+	temperatureNames = []string{"temp", "temperature"}
+	humidityNames    = []string{"hum", "humidity"}
+	voltageNames     = []string{"batt_voltage", "voltage", "vbat"}
+	currentNames     = []string{"batt_current", "current", "ibat"}
+)
+
+// telemetryFields holds the fields of a message, by lowercase name. Unknown
+// fields are ignored.
+type telemetryFields map[string]json.RawMessage
+
+func decodeFields(payload []byte) (telemetryFields, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+	fields := make(telemetryFields, len(raw))
+	for name, value := range raw {
+		fields[strings.ToLower(name)] = value
+	}
+	return fields, nil
+}
+
+// number returns the value of the first of the names found in the message.
+// Besides JSON numbers, it accepts numbers sent as strings and booleans, as
+// some firmware versions send them. It returns false if none of the names is
+// found or the value is not a number.
+func (f telemetryFields) number(names []string) (float64, bool) {
+	for _, name := range names {
+		raw, ok := f[name]
+		if !ok {
+			continue
+		}
+
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return 0, false
+		}
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return n, err == nil
+		case bool:
+			if v {
+				return 1, true
+			}
+			return 0, true
+		default:
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+func (f telemetryFields) int(names []string) *int {
+	v, ok := f.number(names)
+	if !ok {
+		return nil
+	}
+	n := int(v)
+	return &n
+}
+
+func (f telemetryFields) float(names []string) *float32 {
+	v, ok := f.number(names)
+	if !ok {
+		return nil
+	}
+	n := float32(v)
+	return &n
+}
+
+// parseTelemetry decodes a telemetry message.
+func parseTelemetry(payload []byte) (telemetryMsg, error) {
+	f, err := decodeFields(payload)
+	if err != nil {
+		return telemetryMsg{}, err
+	}
+	return telemetryMsg{
+		AzState:     f.int(azStateNames),
+		ShState:     f.int(shStateNames),
+		Position:    f.int(positionNames),
+		Home:        f.int(homeNames),
+		Dir:         f.int(dirNames),
+		Target:      f.int(targetNames),
+		Link:        f.int(linkNames),
+		Temperature: f.float(temperatureNames),
+		Humidity:    f.float(humidityNames),
+	}, nil
+}
+
+// parseBattery decodes a battery message.
+func parseBattery(payload []byte) (batteryMsg, error) {
+	f, err := decodeFields(payload)
+	if err != nil {
+		return batteryMsg{}, err
+	}
+	return batteryMsg{
+		Voltage: f.float(voltageNames),
+		Current: f.float(currentNames),
+	}, nil
+}
@@ -0,0 +1,125 @@
+package dome
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetryHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		want    Status
+	}{
+		{
+			name:    "Current firmware",
+			payload: `{"az_state":1,"sh_state":2,"pos":1000,"home":0,"dir":1,"target":2000,"link":1,"temp":12.5,"hum":80}`,
+			want:    Status{Position: 1000, Target: 2000, Dir: 1, Slewing: true, Shutter: ShutterStatusOpen, ShutterConnected: true, Temperature: 12.5, Humidity: 80},
+		},
+		{
+			name:    "Unknown fields",
+			payload: `{"pos":1000,"rssi":-67,"uptime":3600,"fw":{"version":"2.3"}}`,
+			want:    Status{Position: 1000},
+		},
+		{
+			name:    "Alternate names",
+			payload: `{"azState":2,"Position":1500,"at_home":true,"Temperature":-3}`,
+			want:    Status{Position: 1500, Slewing: true, AtHome: true, Temperature: -3},
+		},
+		{
+			name:    "Numbers as strings",
+			payload: `{"pos":"750","az_state":"0","temp":"8.5"}`,
+			want:    Status{Position: 750, Temperature: 8.5},
+		},
+		{
+			name:    "Floating point encoder count",
+			payload: `{"pos":1200.0}`,
+			want:    Status{Position: 1200},
+		},
+		{
+			name:    "Unreadable value",
+			payload: `{"pos":"north","hum":55}`,
+			want:    Status{Humidity: 55},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewDome(nil, DefaultConfig(), log.New())
+			require.NoError(t, err)
+
+			before := time.Now()
+			d.telemetryHandler(nil, &fakeMessage{payload: []byte(tt.payload)})
+			status := d.GetStatus()
+			assert.False(t, status.TelemetryTime.Before(before), "the reception time is recorded")
+			if tt.want.Temperature != 0 || tt.want.Humidity != 0 {
+				assert.Equal(t, status.TelemetryTime, status.EnvironmentTime, "the environment is stamped with the telemetry")
+			}
+			status.TelemetryTime, status.EnvironmentTime = time.Time{}, time.Time{}
+			assert.Equal(t, tt.want, status)
+		})
+	}
+}
+
+func TestTelemetryMissingFieldsKept(t *testing.T) {
+	d, err := NewDome(nil, DefaultConfig(), log.New())
+	require.NoError(t, err)
+
+	d.telemetryHandler(nil, &fakeMessage{payload: []byte(`{"pos":1000,"az_state":1,"sh_state":0}`)})
+	d.telemetryHandler(nil, &fakeMessage{payload: []byte(`{"az_state":0}`)})
+
+	st := d.GetStatus()
+	assert.Equal(t, 1000, st.Position, "a missing position does not move the dome")
+	assert.False(t, st.Slewing)
+
+	d.telemetryHandler(nil, &fakeMessage{payload: []byte(`not json`)})
+	assert.Equal(t, st, d.GetStatus())
+}
+
+func TestTelemetryShutterLink(t *testing.T) {
+	d, err := NewDome(nil, DefaultConfig(), log.New())
+	require.NoError(t, err)
+
+	d.telemetryHandler(nil, &fakeMessage{payload: []byte(`{"link":1}`)})
+	assert.True(t, d.GetStatus().ShutterConnected)
+
+	d.telemetryHandler(nil, &fakeMessage{payload: []byte(`{"pos":10}`)})
+	assert.True(t, d.GetStatus().ShutterConnected, "kept when missing")
+
+	d.telemetryHandler(nil, &fakeMessage{payload: []byte(`{"link":0}`)})
+	assert.False(t, d.GetStatus().ShutterConnected, "the link was lost")
+}
+
+func TestBatteryHandler(t *testing.T) {
+	d, err := NewDome(nil, DefaultConfig(), log.New())
+	require.NoError(t, err)
+
+	d.batteryHandler(nil, &fakeMessage{payload: []byte(`{"batt_voltage":12.5,"batt_current":0.25}`)})
+	assert.Equal(t, float32(12.5), d.GetStatus().BatteryVoltage)
+	assert.Equal(t, float32(0.25), d.GetStatus().BatteryCurrent)
+
+	d.batteryHandler(nil, &fakeMessage{payload: []byte(`{"vbat":"12.1","cell":3}`)})
+	assert.Equal(t, float32(12.1), d.GetStatus().BatteryVoltage)
+	assert.Equal(t, float32(0.25), d.GetStatus().BatteryCurrent, "kept when missing")
+}
+
+func TestReadEnvironment(t *testing.T) {
+	d, client := newReplyDome(t, func(cmd string) string {
+		if cmd == "_t;" {
+			return "_ACK_t=8.5;"
+		}
+		return "_ACK_u=72;"
+	})
+
+	before := time.Now()
+	require.NoError(t, d.ReadEnvironment())
+	st := d.GetStatus()
+	assert.Equal(t, float32(8.5), st.Temperature)
+	assert.Equal(t, float32(72), st.Humidity)
+	assert.False(t, st.EnvironmentTime.Before(before))
+	assert.Equal(t, []string{"_t;", "_u;"}, client.commands())
+}
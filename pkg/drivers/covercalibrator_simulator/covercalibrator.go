@@ -0,0 +1,400 @@
+// Package covercalibrator_simulator simulates a flip-flat, a telescope cover
+// with a flat panel, so the CoverCalibrator API can be exercised without a
+// real one.
+package covercalibrator_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	coverCalibratorUID = "2d8e4b71-6c3a-4f09-a5d2-e91b7f4c0a36"
+	deviceName         = "Flat Panel Simulator"
+	deviceType         = "CoverCalibrator"
+	driverName         = "ZRO Flat Panel Simulator"
+	driverVersion      = "1.0"
+)
+
+// move is a move of the cover in progress, interpolated from its start to
+// its target.
+type move struct {
+	from, to float64 // Opening, from 0 when closed to 1 when open
+	start    time.Time
+	duration time.Duration
+}
+
+// CoverCalibratorSimulator implements the alpaca.CoverCalibrator interface.
+type CoverCalibratorSimulator struct {
+	logger log.FieldLogger
+	tmpl   *template.Template
+	store  *store
+
+	info   alpaca.DeviceInfo
+	driver alpaca.DriverInfo
+
+	connected atomic.Bool
+
+	mu         sync.Mutex
+	config     Config
+	opening    float64   // Opening of the cover while no move is in progress
+	move       *move     // Move of the cover in progress, if any
+	halted     bool      // Cover halted half way, in an unknown state
+	brightness int       // Brightness of the panel, 0 when off
+	readyAt    time.Time // End of the warm-up of the panel
+}
+
+// New creates a cover calibrator simulator for a device instance, with the
+// settings saved under its key, covercalibrator_config when it has none. The
+// cover starts closed and the panel off.
+func New(dev alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*CoverCalibratorSimulator, error) {
+	key := dev.Key
+	if key == "" {
+		key = coverCalibratorConfigKey
+	}
+
+	store, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %v", err)
+	}
+
+	config, err := store.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cover calibrator config: %v", err)
+	}
+
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(coverCalibratorUID, dev.Key)
+	}
+
+	return &CoverCalibratorSimulator{
+		logger: logger,
+		tmpl:   tmpl,
+		store:  store,
+		config: config,
+
+		info: alpaca.DeviceInfo{
+			Name:     deviceName,
+			Type:     deviceType,
+			Number:   dev.Number,
+			UniqueID: uid,
+		},
+		driver: alpaca.DriverInfo{
+			Name:             driverName,
+			Version:          driverVersion,
+			InterfaceVersion: 2,
+		},
+	}, nil
+}
+
+// Shutdown only logs: the cover and the panel warm-up are timed from their
+// start when read, so there is nothing to stop.
+func (c *CoverCalibratorSimulator) Shutdown(ctx context.Context) error {
+	c.logger.Info("Shutting down cover calibrator simulator")
+	return nil
+}
+
+func (c *CoverCalibratorSimulator) DeviceInfo() alpaca.DeviceInfo {
+	info := c.info
+
+	format := c.getConfig().Description
+	if format == "" {
+		format = defaultDescription
+	}
+	info.Description = alpaca.ExpandDescription(format, map[string]string{
+		"driver": driverVersion,
+	})
+	return info
+}
+
+func (c *CoverCalibratorSimulator) DriverInfo() alpaca.DriverInfo {
+	return c.driver
+}
+
+// Disabled reports whether the flat panel is not served, as set on its setup
+// page.
+func (c *CoverCalibratorSimulator) Disabled() bool {
+	return c.getConfig().Disabled
+}
+
+func (c *CoverCalibratorSimulator) GetState() []alpaca.StateProperty {
+	props := []alpaca.StateProperty{
+		alpaca.StateTimeStamp(time.Now()),
+	}
+
+	if c.connected.Load() {
+		props = append(props, c.Status().ToProperties()...)
+	}
+
+	return props
+}
+
+func (c *CoverCalibratorSimulator) Connect() error {
+	if !c.connected.Swap(true) {
+		c.logger.Infof("%s connected", c.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventConnected,
+			Device:  c.info.Name,
+			Message: "Simulator connected",
+		})
+	}
+	return nil
+}
+
+func (c *CoverCalibratorSimulator) Disconnect() error {
+	if c.connected.Swap(false) {
+		c.logger.Infof("%s disconnected", c.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventDisconnected,
+			Device:  c.info.Name,
+			Message: "Simulator disconnected",
+		})
+	}
+	return nil
+}
+
+func (c *CoverCalibratorSimulator) Connected() bool {
+	return c.connected.Load()
+}
+
+func (c *CoverCalibratorSimulator) Connecting() bool {
+	return false
+}
+
+func (c *CoverCalibratorSimulator) getConfig() Config {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.config
+}
+
+func (c *CoverCalibratorSimulator) Capabilities() alpaca.CoverCalibratorCapabilities {
+	return alpaca.CoverCalibratorCapabilities{
+		Cover:         true,
+		CanHaltCover:  true,
+		MaxBrightness: c.getConfig().MaxBrightness,
+	}
+}
+
+// current returns the opening of the cover at a time.
+func (c *CoverCalibratorSimulator) current(now time.Time) float64 {
+	m := c.move
+	if m == nil {
+		return c.opening
+	}
+	frac := 1.0
+	if m.duration > 0 {
+		frac = math.Min(1, now.Sub(m.start).Seconds()/m.duration.Seconds())
+	}
+	return m.from + frac*(m.to-m.from)
+}
+
+// settle ends a finished move of the cover.
+func (c *CoverCalibratorSimulator) settle(now time.Time) {
+	if m := c.move; m != nil && !now.Before(m.start.Add(m.duration)) {
+		c.opening, c.move = m.to, nil
+	}
+}
+
+func (c *CoverCalibratorSimulator) Status() alpaca.CoverCalibratorStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.settle(now)
+
+	status := alpaca.CoverCalibratorStatus{Brightness: c.brightness}
+	switch {
+	case c.move != nil:
+		status.Cover = alpaca.CoverMoving
+	case c.halted:
+		status.Cover = alpaca.CoverUnknown
+	case c.opening == 0:
+		status.Cover = alpaca.CoverClosed
+	default:
+		status.Cover = alpaca.CoverOpen
+	}
+	switch {
+	case now.Before(c.readyAt):
+		status.Calibrator = alpaca.CalibratorNotReady
+	case c.brightness == 0:
+		status.Calibrator = alpaca.CalibratorOff
+	default:
+		status.Calibrator = alpaca.CalibratorReady
+	}
+	return status
+}
+
+func (c *CoverCalibratorSimulator) OpenCover() error {
+	return c.moveCover(1)
+}
+
+func (c *CoverCalibratorSimulator) CloseCover() error {
+	return c.moveCover(0)
+}
+
+// moveCover starts a move of the cover, taking the cover time for a full
+// stroke.
+func (c *CoverCalibratorSimulator) moveCover(opening float64) error {
+	if !c.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.settle(now)
+	from := c.current(now)
+	c.move = &move{
+		from:     from,
+		to:       opening,
+		start:    now,
+		duration: time.Duration(math.Abs(opening-from) * c.config.CoverTime * float64(time.Second)),
+	}
+	c.halted = false
+	if opening == 1 {
+		c.logger.Info("Opening cover")
+	} else {
+		c.logger.Info("Closing cover")
+	}
+	return nil
+}
+
+// HaltCover stops the cover where it is, leaving it in an unknown state.
+func (c *CoverCalibratorSimulator) HaltCover() error {
+	if !c.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.settle(now)
+	if c.move != nil {
+		c.opening, c.move, c.halted = c.current(now), nil, true
+		c.logger.Infof("Cover halted at %.0f%%", c.opening*100)
+	}
+	return nil
+}
+
+// CalibratorOn turns the panel on, ready after the warm-up time.
+func (c *CoverCalibratorSimulator) CalibratorOn(brightness int) error {
+	if !c.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if brightness < 0 || brightness > c.config.MaxBrightness {
+		return errors.Errorf(errors.ErrInvalidValue, "brightness %d out of range 0 to %d", brightness, c.config.MaxBrightness)
+	}
+	c.setBrightness(brightness)
+	return nil
+}
+
+// CalibratorOff turns the panel off, which also takes the warm-up time.
+func (c *CoverCalibratorSimulator) CalibratorOff() error {
+	if !c.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.setBrightness(0)
+	return nil
+}
+
+func (c *CoverCalibratorSimulator) setBrightness(brightness int) {
+	if brightness != c.brightness {
+		c.readyAt = time.Now().Add(time.Duration(c.config.WarmUpTime * float64(time.Second)))
+	}
+	c.brightness = brightness
+	c.logger.Infof("Panel brightness %d", brightness)
+}
+
+func (c *CoverCalibratorSimulator) HandleSetup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := c.store.GetConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		c.renderSetupForm(w, cfg, false, "")
+
+	case http.MethodPost:
+		cfg, err := parseCoverCalibratorSetupForm(r)
+		if err != nil {
+			c.renderSetupForm(w, cfg, false, err.Error())
+			return
+		}
+
+		c.logger.Infof("Setting cover calibrator config: %+v", alpaca.Redact(cfg))
+		c.mu.Lock()
+		c.config = cfg
+		c.brightness = min(c.brightness, cfg.MaxBrightness)
+		c.mu.Unlock()
+		if err := c.store.SetConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		c.renderSetupForm(w, cfg, true, "")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (c *CoverCalibratorSimulator) renderSetupForm(w http.ResponseWriter, cfg Config, success bool, err string) {
+	data := struct {
+		Config
+		Status    alpaca.CoverCalibratorStatus
+		Connected bool
+		Success   bool
+		Error     string
+	}{cfg, c.Status(), c.connected.Load(), success, err}
+
+	if err := c.tmpl.ExecuteTemplate(w, "covercalibrator_simulator_setup.html", data); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		c.logger.Errorf("Error rendering template: %v", err)
+	}
+}
+
+func parseCoverCalibratorSetupForm(r *http.Request) (Config, error) {
+	var cfg Config
+	if err := r.ParseForm(); err != nil {
+		return cfg, fmt.Errorf("error parsing form: %v", err)
+	}
+
+	var err error
+	if cfg.CoverTime, err = strconv.ParseFloat(r.FormValue("cover-time"), 64); err != nil || cfg.CoverTime < 0 {
+		return cfg, fmt.Errorf("invalid cover time: must be a number of seconds")
+	}
+	if cfg.WarmUpTime, err = strconv.ParseFloat(r.FormValue("warm-up-time"), 64); err != nil || cfg.WarmUpTime < 0 {
+		return cfg, fmt.Errorf("invalid warm-up time: must be a number of seconds")
+	}
+	if cfg.MaxBrightness, err = strconv.Atoi(r.FormValue("max-brightness")); err != nil || cfg.MaxBrightness < 1 {
+		return cfg, fmt.Errorf("invalid maximum brightness: must be a positive number")
+	}
+	cfg.Description = strings.TrimSpace(r.FormValue("description"))
+	cfg.Disabled = r.FormValue("enabled") != "true"
+	return cfg, nil
+}
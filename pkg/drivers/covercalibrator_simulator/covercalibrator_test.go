@@ -0,0 +1,85 @@
+package covercalibrator_simulator
+
+import (
+	"alpaca/internal/simtest"
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCover(t *testing.T) {
+	c := simtest.Connected(t, New)
+	assert.Equal(t, alpaca.CoverClosed, c.Status().Cover)
+
+	require.NoError(t, c.OpenCover())
+	assert.Equal(t, alpaca.CoverMoving, c.Status().Cover)
+
+	// Half way through the 5 seconds of the stroke.
+	c.mu.Lock()
+	c.move.start = c.move.start.Add(-2500 * time.Millisecond)
+	c.mu.Unlock()
+	require.NoError(t, c.HaltCover())
+	assert.Equal(t, alpaca.CoverUnknown, c.Status().Cover)
+
+	require.NoError(t, c.OpenCover())
+	c.mu.Lock()
+	assert.InDelta(t, 2500*time.Millisecond, c.move.duration, float64(10*time.Millisecond), "only the rest of the stroke")
+	c.move.start = c.move.start.Add(-time.Hour)
+	c.mu.Unlock()
+	assert.Equal(t, alpaca.CoverOpen, c.Status().Cover)
+
+	require.NoError(t, c.Disconnect())
+	assert.ErrorIs(t, c.CloseCover(), errors.ErrNotConnected)
+}
+
+func TestCalibrator(t *testing.T) {
+	c := simtest.Connected(t, New)
+	assert.Equal(t, alpaca.CalibratorOff, c.Status().Calibrator)
+
+	require.NoError(t, c.CalibratorOn(100))
+	st := c.Status()
+	assert.Equal(t, alpaca.CalibratorNotReady, st.Calibrator)
+	assert.Equal(t, 100, st.Brightness)
+
+	c.mu.Lock()
+	c.readyAt = time.Now().Add(-time.Second)
+	c.mu.Unlock()
+	assert.Equal(t, alpaca.CalibratorReady, c.Status().Calibrator)
+
+	require.NoError(t, c.CalibratorOff())
+	assert.Equal(t, alpaca.CalibratorNotReady, c.Status().Calibrator)
+	c.mu.Lock()
+	c.readyAt = time.Now().Add(-time.Second)
+	c.mu.Unlock()
+	assert.Equal(t, alpaca.CalibratorOff, c.Status().Calibrator)
+
+	assert.ErrorIs(t, c.CalibratorOn(256), errors.ErrInvalidValue)
+}
+
+func TestParseCoverCalibratorSetupForm(t *testing.T) {
+	form := url.Values{
+		"cover-time":     {"8"},
+		"warm-up-time":   {"0.5"},
+		"max-brightness": {"1023"},
+		"enabled":        {"true"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	cfg, err := parseCoverCalibratorSetupForm(req)
+	require.NoError(t, err)
+	assert.Equal(t, Config{CoverTime: 8, WarmUpTime: 0.5, MaxBrightness: 1023}, cfg)
+
+	form.Set("max-brightness", "0")
+	req = httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = parseCoverCalibratorSetupForm(req)
+	assert.Error(t, err)
+}
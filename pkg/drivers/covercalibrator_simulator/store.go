@@ -0,0 +1,56 @@
+package covercalibrator_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultDescription = "Flat panel simulator {driver} @ {host}"
+
+	coverCalibratorConfigKey = "covercalibrator_config"
+)
+
+// Config holds the timings and the brightness range of the simulated
+// flip-flat.
+type Config struct {
+	CoverTime     float64 `json:"cover_time"`     // seconds to open or close the cover
+	WarmUpTime    float64 `json:"warm_up_time"`   // seconds for the panel to settle after a change of brightness
+	MaxBrightness int     `json:"max_brightness"` // brightness of the panel at full power
+
+	Description string `json:"description"` // device description, with {driver} and {host} placeholders
+
+	Disabled bool `json:"disabled"` // hidden from the configured devices, e.g. in production
+}
+
+type store = alpaca.DriverStore[Config]
+
+// NewStoreWithKey creates a store for the configuration saved under key,
+// which defaults to a disabled flip-flat whose cover takes 5 seconds to move,
+// with an 8-bit panel settling in 2 seconds.
+func NewStoreWithKey(db *bolt.DB, key string) (*store, error) {
+	return alpaca.NewDriverStore(db, key, Config{
+		CoverTime:     5,
+		WarmUpTime:    2,
+		MaxBrightness: 255,
+		Description:   defaultDescription,
+		Disabled:      true,
+	})
+}
+
+// ApplySettings merges the settings of a device file into the cover calibrator simulator
+// configuration saved under key, the default key if empty, and saves it if
+// they changed it.
+func ApplySettings(db *bolt.DB, key string, settings json.RawMessage) error {
+	if key == "" {
+		key = coverCalibratorConfigKey
+	}
+
+	st, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return err
+	}
+	return st.ApplySettings(settings)
+}
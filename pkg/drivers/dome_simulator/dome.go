@@ -2,10 +2,15 @@ package dome_simulator
 
 import (
 	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"context"
 	"fmt"
 	"html/template"
+	"math/rand/v2"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -32,12 +37,30 @@ type DomeSimulator struct {
 	capabilities alpaca.DomeCapabilities
 	status       alpaca.DomeStatus
 
-	connected  bool
-	connecting bool
+	// Connect may complete in the background, so the connection state is
+	// read and written atomically.
+	connected  atomic.Bool
+	connecting atomic.Bool
+
+	// fault is the fault injected from the control panel, if any. While set,
+	// every motion and shutter command fails.
+	fault string
 }
 
 func NewDomeSimulator(number int, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*DomeSimulator, error) {
-	store, err := NewStore(db)
+	return New(alpaca.DeviceConfig{Number: number}, db, tmpl, logger)
+}
+
+// New creates the simulator of a configured device instance. Each instance
+// keeps its settings under its own key; the default key is used when none is
+// set.
+func New(dev alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*DomeSimulator, error) {
+	key := dev.Key
+	if key == "" {
+		key = domeConfigKey
+	}
+
+	store, err := NewStoreWithKey(db, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create store: %v", err)
 	}
@@ -47,6 +70,11 @@ func NewDomeSimulator(number int, db *bolt.DB, tmpl *template.Template, logger l
 		return nil, fmt.Errorf("failed to get dome config: %v", err)
 	}
 
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(domeUID, dev.Key)
+	}
+
 	dome := DomeSimulator{
 		logger: logger,
 		tmpl:   tmpl,
@@ -56,8 +84,8 @@ func NewDomeSimulator(number int, db *bolt.DB, tmpl *template.Template, logger l
 		info: alpaca.DeviceInfo{
 			Name:     deviceName,
 			Type:     deviceType,
-			Number:   number,
-			UniqueID: domeUID,
+			Number:   dev.Number,
+			UniqueID: uid,
 		},
 		driver: alpaca.DriverInfo{
 			Name:             driverName,
@@ -88,30 +116,43 @@ func NewDomeSimulator(number int, db *bolt.DB, tmpl *template.Template, logger l
 	return &dome, nil
 }
 
-func (d *DomeSimulator) Close() error {
-	d.logger.Info("Closing dome simulator")
+// Shutdown stops the simulator. It holds no resources, so it only logs.
+func (d *DomeSimulator) Shutdown(ctx context.Context) error {
+	d.logger.Info("Shutting down dome simulator")
 	return nil
 }
 
 func (d *DomeSimulator) DeviceInfo() alpaca.DeviceInfo {
-	return d.info
+	info := d.info
+
+	format := d.config.Description
+	if format == "" {
+		format = defaultDescription
+	}
+	info.Description = alpaca.ExpandDescription(format, map[string]string{
+		"driver": driverVersion,
+	})
+	return info
 }
 
 func (d *DomeSimulator) DriverInfo() alpaca.DriverInfo {
 	return d.driver
 }
 
+// Disabled reports whether the simulator is disabled in its setup page.
+func (d *DomeSimulator) Disabled() bool {
+	return d.config.Disabled
+}
+
 func (d *DomeSimulator) GetState() []alpaca.StateProperty {
 	props := []alpaca.StateProperty{
-		{
-			Name:  "TimeStamp",
-			Value: time.Now().Format(time.RFC3339),
-		},
+		alpaca.StateTimeStamp(time.Now()),
 	}
 
-	if d.connected {
+	if d.connected.Load() {
 		// If connected, add status properties
 		props = append(props, d.status.ToProperties()...)
+		props = append(props, alpaca.StateProperty{Name: "SlewTimeRemaining", Value: alpaca.SlewSeconds(d.SlewTimeRemaining())})
 	}
 
 	return props
@@ -125,61 +166,114 @@ func (d *DomeSimulator) Status() alpaca.DomeStatus {
 	return d.status
 }
 
+// Connect connects the simulator. With a connect delay configured, the
+// connection completes in the background while Connecting is true, and
+// fails with the configured probability, leaving Connected false, so that
+// the client handling of the asynchronous connection can be validated.
 func (d *DomeSimulator) Connect() error {
-	if d.connected {
+	if d.connected.Load() {
 		return nil
 	}
 
-	// d.connecting = true
-	// d.logger.Infof("%s connecting...", d.info.Name)
+	delay := time.Duration(d.config.ConnectDelay * float64(time.Second))
+	fail := rand.Float64() < d.config.ConnectFailure
 
-	// go func() {
-	// 	d.logger.Infof("%s connection in progress...", d.info.Name)
-	// 	// Simulate connection delay
-	// 	time.Sleep(2 * time.Second)
-	// 	d.connecting = false
-	// 	d.connected = true
-	// 	d.logger.Infof("%s connected", d.info.Name)
-	// }()
+	if delay <= 0 {
+		if fail {
+			d.connectFailed()
+			return errors.Errorf(errors.ErrUnspecified, "simulated connection failure")
+		}
+		d.connectDone()
+		return nil
+	}
 
-	d.connected = true
-	d.logger.Infof("%s connected", d.info.Name)
+	if !d.connecting.CompareAndSwap(false, true) {
+		return nil
+	}
+	d.logger.Infof("%s connecting in %v...", d.info.Name, delay)
+
+	go func() {
+		time.Sleep(delay)
+		if fail {
+			d.connectFailed()
+		} else {
+			d.connectDone()
+		}
+		d.connecting.Store(false)
+	}()
 
 	return nil
 }
 
 func (d *DomeSimulator) Disconnect() error {
-	if !d.connected {
+	if !d.connected.Swap(false) {
 		return nil
 	}
-	d.connected = false
 	d.logger.Infof("%s disconnected", d.info.Name)
+	alpaca.Publish(alpaca.Event{
+		Type:    alpaca.EventDisconnected,
+		Device:  d.info.Name,
+		Message: "Simulator disconnected",
+	})
 	return nil
 }
 
+// connectDone marks the simulator connected.
+func (d *DomeSimulator) connectDone() {
+	d.connected.Store(true)
+	d.logger.Infof("%s connected", d.info.Name)
+	alpaca.Publish(alpaca.Event{
+		Type:    alpaca.EventConnected,
+		Device:  d.info.Name,
+		Message: "Simulator connected",
+	})
+}
+
+// connectFailed reports a simulated connection failure.
+func (d *DomeSimulator) connectFailed() {
+	d.logger.Warnf("%s simulated connection failure", d.info.Name)
+	alpaca.Publish(alpaca.Event{
+		Type:    alpaca.EventError,
+		Device:  d.info.Name,
+		Message: "Simulated connection failure",
+	})
+}
+
 func (d *DomeSimulator) Connected() bool {
-	return d.connected
+	return d.connected.Load()
 }
 
 func (d *DomeSimulator) Connecting() bool {
-	return d.connecting
+	return d.connecting.Load()
+}
+
+// checkReady returns an error if the simulator cannot accept commands.
+func (d *DomeSimulator) checkReady() error {
+	if !d.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	if d.fault != "" {
+		return errors.Errorf(errors.ErrInvalidOperation, "simulated fault: %s", d.fault)
+	}
+	return nil
 }
 
 func (d *DomeSimulator) SetSlaved(slaved bool) error {
-	if !d.connected {
-		return alpaca.ErrNotConnected
+	if !d.connected.Load() {
+		return errors.ErrNotConnected
 	}
 	d.logger.Infof("Dome slaved: %v", slaved)
+	d.status.Slaved = slaved
 	return nil
 }
 
 func (d *DomeSimulator) SlewToAltitude(altitude float64) error {
-	return alpaca.ErrPropertyNotImplemented
+	return errors.ErrNotImplemented
 }
 
 func (d *DomeSimulator) SlewToAzimuth(azimuth float64) error {
-	if !d.connected {
-		return alpaca.ErrNotConnected
+	if err := d.checkReady(); err != nil {
+		return err
 	}
 	d.logger.Infof("Slewing to azimuth: %f", azimuth)
 	d.status.Azimuth = azimuth
@@ -193,9 +287,21 @@ func (d *DomeSimulator) SlewToAzimuth(azimuth float64) error {
 	return nil
 }
 
+// EstimateSlew returns the duration of a slew, none since the simulator
+// slews at once.
+func (d *DomeSimulator) EstimateSlew(azimuth float64) time.Duration {
+	return 0
+}
+
+// SlewTimeRemaining returns the time left in the slew in progress, none
+// since the simulator slews at once.
+func (d *DomeSimulator) SlewTimeRemaining() time.Duration {
+	return 0
+}
+
 func (d *DomeSimulator) SyncToAzimuth(azimuth float64) error {
-	if !d.connected {
-		return alpaca.ErrNotConnected
+	if err := d.checkReady(); err != nil {
+		return err
 	}
 	d.logger.Infof("Syncing to azimuth: %f", azimuth)
 	d.status.Azimuth = azimuth
@@ -203,8 +309,8 @@ func (d *DomeSimulator) SyncToAzimuth(azimuth float64) error {
 }
 
 func (d *DomeSimulator) AbortSlew() error {
-	if !d.connected {
-		return alpaca.ErrNotConnected
+	if err := d.checkReady(); err != nil {
+		return err
 	}
 	d.logger.Info("Aborting slew")
 	d.status.Slewing = false
@@ -212,8 +318,8 @@ func (d *DomeSimulator) AbortSlew() error {
 }
 
 func (d *DomeSimulator) FindHome() error {
-	if !d.connected {
-		return alpaca.ErrNotConnected
+	if err := d.checkReady(); err != nil {
+		return err
 	}
 	d.logger.Info("Finding home")
 	d.status.AtHome = true
@@ -228,8 +334,8 @@ func (d *DomeSimulator) Park() error {
 }
 
 func (d *DomeSimulator) SetPark() error {
-	if !d.connected {
-		return alpaca.ErrNotConnected
+	if err := d.checkReady(); err != nil {
+		return err
 	}
 	d.logger.Info("Setting park position")
 	d.config.ParkPosition = uint(d.status.Azimuth)
@@ -239,8 +345,8 @@ func (d *DomeSimulator) SetPark() error {
 }
 
 func (d *DomeSimulator) SetShutter(cmd alpaca.ShutterCommand) error {
-	if !d.connected {
-		return alpaca.ErrNotConnected
+	if err := d.checkReady(); err != nil {
+		return err
 	}
 	d.logger.Infof("Setting shutter: %v", cmd)
 	switch cmd {
@@ -263,13 +369,23 @@ func (d *DomeSimulator) HandleSetup(w http.ResponseWriter, r *http.Request) {
 		d.renderSetupForm(w, cfg, false, "")
 
 	case http.MethodPost:
+		if r.FormValue("control") != "" {
+			err := d.handleControl(r)
+			if err != nil {
+				d.renderSetupForm(w, d.config, false, err.Error())
+				return
+			}
+			d.renderSetupForm(w, d.config, true, "")
+			return
+		}
+
 		cfg, err := parseDomeSetupForm(r)
 		if err != nil {
 			d.renderSetupForm(w, cfg, false, err.Error())
 			return
 		}
 
-		d.logger.Infof("Setting dome config: %+v", cfg)
+		d.logger.Infof("Setting dome config: %+v", alpaca.Redact(cfg))
 		d.config = cfg
 		if err := d.store.SetConfig(cfg); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -283,12 +399,64 @@ func (d *DomeSimulator) HandleSetup(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleControl applies a control panel action to the simulator state.
+// Control actions bypass the Alpaca API so demos can put the dome in any state,
+// including states a client could never reach, such as a shutter fault.
+func (d *DomeSimulator) handleControl(r *http.Request) error {
+	switch action := r.FormValue("control"); action {
+	case "azimuth":
+		azimuth, err := strconv.ParseFloat(r.FormValue("azimuth"), 64)
+		if err != nil || azimuth < 0 || azimuth >= 360 {
+			return fmt.Errorf("invalid azimuth: %q", r.FormValue("azimuth"))
+		}
+		d.logger.Infof("Control: azimuth set to %.1f", azimuth)
+		d.status.Azimuth = azimuth
+		d.status.Slewing = false
+		d.status.AtHome = azimuth == float64(d.config.HomePosition)
+		d.status.AtPark = azimuth == float64(d.config.ParkPosition)
+
+	case "shutter":
+		shutter, err := strconv.Atoi(r.FormValue("shutter"))
+		if err != nil || shutter < int(alpaca.ShutterOpen) || shutter > int(alpaca.ShutterError) {
+			return fmt.Errorf("invalid shutter status: %q", r.FormValue("shutter"))
+		}
+		d.logger.Infof("Control: shutter status set to %d", shutter)
+		d.status.Shutter = alpaca.ShutterStatus(shutter)
+
+	case "slewing":
+		d.status.Slewing = r.FormValue("slewing") == "true"
+		d.logger.Infof("Control: slewing set to %v", d.status.Slewing)
+
+	case "fault":
+		fault := r.FormValue("fault")
+		if fault == "" {
+			fault = "injected from control panel"
+		}
+		d.logger.Warnf("Control: fault triggered: %s", fault)
+		d.fault = fault
+		d.status.Slewing = false
+		d.status.Shutter = alpaca.ShutterError
+
+	case "clear-fault":
+		d.logger.Info("Control: fault cleared")
+		d.fault = ""
+
+	default:
+		return fmt.Errorf("unknown control action: %q", action)
+	}
+
+	return nil
+}
+
 func (d *DomeSimulator) renderSetupForm(w http.ResponseWriter, cfg Config, success bool, err string) {
 	data := struct {
 		Config
-		Success bool
-		Error   string
-	}{cfg, success, err}
+		Status    alpaca.DomeStatus
+		Connected bool
+		Fault     string
+		Success   bool
+		Error     string
+	}{cfg, d.status, d.connected.Load(), d.fault, success, err}
 
 	if err := d.tmpl.ExecuteTemplate(w, "dome_simulator_setup.html", data); err != nil {
 		http.Error(w, "Error rendering template", http.StatusInternalServerError)
@@ -317,12 +485,24 @@ func parseDomeSetupForm(r *http.Request) (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	connectDelay, err := strconv.ParseFloat(r.FormValue("connect-delay"), 64)
+	if err != nil || connectDelay < 0 || connectDelay > maxConnectDelay {
+		return Config{}, fmt.Errorf("invalid connect-delay: must be between 0 and %d seconds", maxConnectDelay)
+	}
+	connectFailure, err := strconv.ParseFloat(r.FormValue("connect-failure"), 64)
+	if err != nil || connectFailure < 0 || connectFailure > 1 {
+		return Config{}, fmt.Errorf("invalid connect-failure: must be a probability between 0 and 1")
+	}
 
 	return Config{
 		HomePosition:   homePosition,
 		ParkPosition:   parkPosition,
 		ShutterTimeout: shutterTimeout,
 		TicksPerRev:    ticksPerRevolution,
+		Description:    strings.TrimSpace(r.FormValue("description")),
+		ConnectDelay:   connectDelay,
+		ConnectFailure: connectFailure,
+		Disabled:       r.FormValue("enabled") != "true",
 	}, nil
 }
 
@@ -0,0 +1,51 @@
+package dome_simulator
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func newTestSimulator(t *testing.T) *DomeSimulator {
+	t.Helper()
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "alpaca.db"), 0600, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	d, err := NewDomeSimulator(0, db, nil, log.New())
+	require.NoError(t, err)
+	return d
+}
+
+func TestAsyncConnect(t *testing.T) {
+	d := newTestSimulator(t)
+	d.config.ConnectDelay = 0.05
+
+	require.NoError(t, d.Connect())
+	assert.True(t, d.Connecting())
+	assert.False(t, d.Connected())
+
+	// Connected is final once Connecting drops.
+	assert.Eventually(t, func() bool { return !d.Connecting() }, time.Second, 10*time.Millisecond)
+	assert.True(t, d.Connected())
+}
+
+func TestConnectFailure(t *testing.T) {
+	d := newTestSimulator(t)
+	d.config.ConnectFailure = 1
+
+	assert.Error(t, d.Connect())
+	assert.False(t, d.Connected())
+
+	d.config.ConnectDelay = 0.05
+	require.NoError(t, d.Connect())
+	assert.True(t, d.Connecting())
+	assert.Eventually(t, func() bool { return !d.Connecting() }, time.Second, 10*time.Millisecond)
+	assert.False(t, d.Connected())
+}
@@ -1,6 +1,7 @@
 package dome_simulator
 
 import (
+	"alpaca/pkg/alpaca"
 	"encoding/json"
 	"fmt"
 
@@ -14,6 +15,8 @@ const (
 	defaultParkPosition   = 90
 	defaultShutterTimeout = 60
 	defaultTicksPerRev    = 1470
+	defaultDescription    = "Dome simulator {driver} @ {host}"
+	maxConnectDelay       = 60
 
 	domeConfigKey = "dome_config"
 )
@@ -23,14 +26,28 @@ type Config struct {
 	ParkPosition   uint `json:"park_position"`   // degrees
 	ShutterTimeout uint `json:"shutter_timeout"` // seconds
 	TicksPerRev    uint `json:"ticks_per_rev"`   // encoder ticks per revolution
+
+	Description string `json:"description"` // device description, with {driver} and {host} placeholders
+
+	ConnectDelay   float64 `json:"connect_delay"`   // seconds before an asynchronous connection completes, 0 to connect at once
+	ConnectFailure float64 `json:"connect_failure"` // probability, from 0 to 1, that a connection attempt fails
+
+	Disabled bool `json:"disabled"` // hidden from the configured devices, e.g. in production
 }
 
 type store struct {
-	db *bolt.DB
+	db  *bolt.DB
+	key string // database key of the configuration
 }
 
 func NewStore(db *bolt.DB) (*store, error) {
-	st := store{db: db}
+	return NewStoreWithKey(db, domeConfigKey)
+}
+
+// NewStoreWithKey creates a store for the configuration saved under key, for
+// additional simulator instances.
+func NewStoreWithKey(db *bolt.DB, key string) (*store, error) {
+	st := store{db: db, key: key}
 
 	if err := st.setDefaults(); err != nil {
 		return nil, err
@@ -46,6 +63,8 @@ func (s *store) setDefaults() error {
 			ParkPosition:   defaultParkPosition,
 			ShutterTimeout: defaultShutterTimeout,
 			TicksPerRev:    defaultTicksPerRev,
+			Description:    defaultDescription,
+			Disabled:       true,
 		})
 	}
 
@@ -54,15 +73,23 @@ func (s *store) setDefaults() error {
 
 // SetConfig saves the dome configuration as a json string in the database.
 func (s *store) SetConfig(cfg Config) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
 		if err != nil {
 			return err
 		}
 
 		value, _ := json.Marshal(cfg)
-		return b.Put([]byte(domeConfigKey), value)
+		return b.Put([]byte(s.key), value)
 	})
+	if err != nil {
+		return err
+	}
+
+	if err := alpaca.BackupDB(s.db); err != nil {
+		log.Warnf("Failed to back up database: %v", err)
+	}
+	return nil
 }
 
 // GetConfig retrieves the dome configuration from the database.
@@ -75,7 +102,7 @@ func (s *store) GetConfig() (Config, error) {
 			return fmt.Errorf("bucket %s not found", bucket)
 		}
 
-		value := b.Get([]byte(domeConfigKey))
+		value := b.Get([]byte(s.key))
 		if value == nil {
 			return fmt.Errorf("key config not found")
 		}
@@ -85,3 +112,27 @@ func (s *store) GetConfig() (Config, error) {
 
 	return cfg, err
 }
+
+// ApplySettings merges the settings of a device file into the dome simulator
+// configuration saved under key, the default key if empty, and saves it if
+// they changed it.
+func ApplySettings(db *bolt.DB, key string, settings json.RawMessage) error {
+	if key == "" {
+		key = domeConfigKey
+	}
+
+	st, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return err
+	}
+	cfg, err := st.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	changed, err := alpaca.MergeSettings(&cfg, settings)
+	if err != nil || !changed {
+		return err
+	}
+	return st.SetConfig(cfg)
+}
@@ -0,0 +1,170 @@
+// Package drivers creates the devices served by the server from the device
+// list of the server configuration.
+package drivers
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/drivers/covercalibrator_simulator"
+	"alpaca/pkg/drivers/dome_simulator"
+	"alpaca/pkg/drivers/filterwheel_simulator"
+	"alpaca/pkg/drivers/focuser_simulator"
+	"alpaca/pkg/drivers/remote"
+	"alpaca/pkg/drivers/rotator_simulator"
+	"alpaca/pkg/drivers/telescope_simulator"
+	"alpaca/pkg/drivers/weather_simulator"
+	"alpaca/pkg/drivers/zro"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Driver names used in the device list.
+const (
+	DriverDomeSimulator            = "dome_simulator"
+	DriverWeatherSimulator         = "weather_simulator"
+	DriverTelescopeSimulator       = "telescope_simulator"
+	DriverFocuserSimulator         = "focuser_simulator"
+	DriverFilterWheelSimulator     = "filterwheel_simulator"
+	DriverRotatorSimulator         = "rotator_simulator"
+	DriverCoverCalibratorSimulator = "covercalibrator_simulator"
+	DriverZRO                      = "zro"
+	DriverZROSafety                = "zro_safety"     // Safety monitor of the ZRO dome whose settings key is the key
+	DriverZROConditions            = "zro_conditions" // Sensors of the ZRO dome whose settings key is the key
+	DriverZROSwitch                = "zro_switch"     // Battery and relays of the ZRO dome whose settings key is the key
+	DriverRemote                   = "remote"         // A device of another Alpaca server, whose URL is the key
+)
+
+// Names returns the names of the available drivers.
+func Names() []string {
+	return []string{DriverDomeSimulator, DriverWeatherSimulator, DriverTelescopeSimulator, DriverFocuserSimulator, DriverFilterWheelSimulator, DriverRotatorSimulator, DriverCoverCalibratorSimulator, DriverZRO, DriverZROSafety, DriverZROConditions, DriverZROSwitch, DriverRemote}
+}
+
+// DefaultDevices returns the devices created when the configuration does not
+// list any: the dome simulator, disabled by default, and the ZRO dome.
+func DefaultDevices() []alpaca.DeviceConfig {
+	return []alpaca.DeviceConfig{
+		{Driver: DriverDomeSimulator, Number: 0},
+		{Driver: DriverZRO, Number: 1},
+	}
+}
+
+// settingsAppliers merge the settings of a device file into the stored
+// configuration of the drivers that have one.
+var settingsAppliers = map[string]func(db *bolt.DB, key string, settings json.RawMessage) error{
+	DriverDomeSimulator:            dome_simulator.ApplySettings,
+	DriverWeatherSimulator:         weather_simulator.ApplySettings,
+	DriverTelescopeSimulator:       telescope_simulator.ApplySettings,
+	DriverFocuserSimulator:         focuser_simulator.ApplySettings,
+	DriverFilterWheelSimulator:     filterwheel_simulator.ApplySettings,
+	DriverRotatorSimulator:         rotator_simulator.ApplySettings,
+	DriverCoverCalibratorSimulator: covercalibrator_simulator.ApplySettings,
+	DriverZRO:                      zro.ApplySettings,
+	DriverRemote:                   remote.ApplySettings,
+}
+
+// applySettings saves the settings of a device before it is created, so the
+// driver starts with them.
+func applySettings(cfg alpaca.DeviceConfig, db *bolt.DB) error {
+	if len(cfg.Settings) == 0 {
+		return nil
+	}
+	apply, ok := settingsAppliers[cfg.Driver]
+	if !ok {
+		return fmt.Errorf("driver %q has no settings", cfg.Driver)
+	}
+	return apply(db, cfg.Key, cfg.Settings)
+}
+
+// New creates the device described by cfg. The devices created before it are
+// given to the drivers built on another device.
+func New(cfg alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger, created []alpaca.Device) (alpaca.Device, error) {
+	switch cfg.Driver {
+	case DriverDomeSimulator:
+		return dome_simulator.New(cfg, db, tmpl, logger)
+	case DriverWeatherSimulator:
+		return weather_simulator.New(cfg, db, tmpl, logger)
+	case DriverTelescopeSimulator:
+		return telescope_simulator.New(cfg, db, tmpl, logger)
+	case DriverFocuserSimulator:
+		return focuser_simulator.New(cfg, db, tmpl, logger)
+	case DriverFilterWheelSimulator:
+		return filterwheel_simulator.New(cfg, db, tmpl, logger)
+	case DriverRotatorSimulator:
+		return rotator_simulator.New(cfg, db, tmpl, logger)
+	case DriverCoverCalibratorSimulator:
+		return covercalibrator_simulator.New(cfg, db, tmpl, logger)
+	case DriverZRO:
+		return zro.New(cfg, db, tmpl, logger)
+	case DriverZROSafety:
+		dome, err := zro.FindDome(created, cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+		return zro.NewSafetyMonitor(cfg, dome, logger), nil
+	case DriverZROConditions:
+		dome, err := zro.FindDome(created, cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+		return zro.NewConditions(cfg, dome, logger), nil
+	case DriverZROSwitch:
+		dome, err := zro.FindDome(created, cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+		return zro.NewSwitch(cfg, dome, logger), nil
+	case DriverRemote:
+		return remote.New(cfg, logger)
+	default:
+		return nil, fmt.Errorf("unknown driver %q", cfg.Driver)
+	}
+}
+
+// NewDevices creates the configured devices, after applying their settings.
+// A device that cannot be created, or whose settings are invalid, is logged
+// and skipped, so a configuration mistake does not keep the server, and its
+// setup page, from starting.
+func NewDevices(configs []alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template) []alpaca.Device {
+	if len(configs) == 0 {
+		configs = DefaultDevices()
+	}
+
+	type slot struct {
+		devType alpaca.DeviceType
+		number  int
+	}
+	used := make(map[slot]bool)
+
+	var devices []alpaca.Device
+	for _, cfg := range configs {
+		logger := log.WithFields(log.Fields{"device": cfg.Driver, "number": cfg.Number})
+
+		if err := applySettings(cfg, db); err != nil {
+			logger.Errorf("Failed to apply the device settings: %v", err)
+			continue
+		}
+
+		dev, err := New(cfg, db, tmpl, logger, devices)
+		if err != nil {
+			logger.Errorf("Failed to create device: %v", err)
+			continue
+		}
+
+		s := slot{dev.DeviceInfo().Type, cfg.Number}
+		if used[s] {
+			logger.Errorf("Skipping device: %s number %d is already used", s.devType, s.number)
+			if d, ok := dev.(alpaca.Shutdowner); ok {
+				d.Shutdown(context.Background())
+			}
+			continue
+		}
+		used[s] = true
+
+		devices = append(devices, dev)
+	}
+	return devices
+}
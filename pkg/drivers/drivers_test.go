@@ -0,0 +1,82 @@
+package drivers
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/drivers/focuser_simulator"
+	"alpaca/pkg/drivers/zro"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestNewDevices(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "alpaca.db"), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	devices := NewDevices(nil, db, nil)
+	require.Len(t, devices, 2, "the default devices")
+
+	devices = NewDevices([]alpaca.DeviceConfig{
+		{Driver: DriverZRO, Number: 1},
+		{Driver: DriverZRO, Number: 2, Key: "zro_config_2"},
+		{Driver: DriverZRO, Number: 2, Key: "zro_config_3"},
+		{Driver: "unknown", Number: 3},
+	}, db, nil)
+
+	require.Len(t, devices, 2, "the duplicate number and the unknown driver are skipped")
+	assert.Equal(t, 2, devices[1].DeviceInfo().Number)
+	assert.NotEqual(t, devices[0].DeviceInfo().UniqueID, devices[1].DeviceInfo().UniqueID)
+}
+
+func TestNewDomeDevices(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "alpaca.db"), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	devices := NewDevices([]alpaca.DeviceConfig{
+		{Driver: DriverZROSafety, Number: 0},
+		{Driver: DriverZRO, Number: 0},
+		{Driver: DriverZROSafety, Number: 0},
+		{Driver: DriverZROConditions, Number: 0},
+		{Driver: DriverZROSwitch, Number: 0},
+	}, db, nil)
+
+	require.Len(t, devices, 4, "the safety monitor listed before its dome is skipped")
+	assert.Equal(t, alpaca.DeviceTypeSafety, devices[1].DeviceInfo().Type)
+	assert.Equal(t, alpaca.DeviceTypeConditions, devices[2].DeviceInfo().Type)
+	assert.Equal(t, alpaca.DeviceTypeSwitch, devices[3].DeviceInfo().Type)
+	assert.Equal(t, devices[0].(*zro.Driver).Disabled(), devices[1].(*zro.SafetyMonitor).Disabled())
+}
+
+func TestNewDevicesSettings(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "alpaca.db"), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	configs := []alpaca.DeviceConfig{
+		{Driver: DriverFocuserSimulator, Number: 0, Settings: json.RawMessage(`{"max_step": 1000, "disabled": false}`)},
+		{Driver: DriverFocuserSimulator, Number: 1, Key: "focuser_2", Settings: json.RawMessage(`{"max_step": 2000}`)},
+		{Driver: DriverFocuserSimulator, Number: 2, Key: "focuser_3", Settings: json.RawMessage(`{"maxstep": 3000}`)},
+		{Driver: DriverZRO, Number: 0, Key: "zro_config_2", Settings: json.RawMessage(`{"Description": "East dome"}`)},
+		{Driver: DriverZROSafety, Number: 0, Key: "zro_config_2", Settings: json.RawMessage(`{}`)},
+	}
+	devices := NewDevices(configs, db, nil)
+
+	require.Len(t, devices, 3, "the misspelled setting and the driver without settings are skipped")
+	assert.Equal(t, 1000, devices[0].(*focuser_simulator.FocuserSimulator).Capabilities().MaxStep)
+	assert.False(t, devices[0].(*focuser_simulator.FocuserSimulator).Disabled())
+	assert.Equal(t, 2000, devices[1].(*focuser_simulator.FocuserSimulator).Capabilities().MaxStep)
+	assert.True(t, devices[1].(*focuser_simulator.FocuserSimulator).Disabled(), "the other settings keep their defaults")
+	assert.Equal(t, "East dome", devices[2].DeviceInfo().Description)
+
+	// The settings are applied again on the next startup, over the changes
+	// made meanwhile.
+	require.NoError(t, focuser_simulator.ApplySettings(db, "", json.RawMessage(`{"max_step": 500}`)))
+	devices = NewDevices(configs[:1], db, nil)
+	assert.Equal(t, 1000, devices[0].(*focuser_simulator.FocuserSimulator).Capabilities().MaxStep)
+}
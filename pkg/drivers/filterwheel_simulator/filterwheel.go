@@ -0,0 +1,331 @@
+// Package filterwheel_simulator simulates a filter wheel, so the FilterWheel
+// API can be exercised without a real wheel.
+package filterwheel_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	filterWheelUID = "e5a1c8d2-3f69-4b07-8d4e-2c9b7a1f6e58"
+	deviceName     = "Filter Wheel Simulator"
+	deviceType     = "FilterWheel"
+	driverName     = "ZRO Filter Wheel Simulator"
+	driverVersion  = "1.0"
+)
+
+// FilterWheelSimulator implements the alpaca.FilterWheel interface.
+type FilterWheelSimulator struct {
+	logger log.FieldLogger
+	tmpl   *template.Template
+	store  *store
+
+	info   alpaca.DeviceInfo
+	driver alpaca.DriverInfo
+
+	connected atomic.Bool
+
+	mu       sync.Mutex
+	config   Config
+	position int       // Filter in place, or being moved to
+	arrival  time.Time // End of the move to the position
+}
+
+// New creates a filter wheel simulator for a device instance, with the
+// settings saved under its key, filterwheel_config when it has none. The
+// wheel starts at the first filter.
+func New(dev alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*FilterWheelSimulator, error) {
+	key := dev.Key
+	if key == "" {
+		key = filterWheelConfigKey
+	}
+
+	store, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %v", err)
+	}
+
+	config, err := store.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get filter wheel config: %v", err)
+	}
+
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(filterWheelUID, dev.Key)
+	}
+
+	return &FilterWheelSimulator{
+		logger: logger,
+		tmpl:   tmpl,
+		store:  store,
+		config: config,
+
+		info: alpaca.DeviceInfo{
+			Name:     deviceName,
+			Type:     deviceType,
+			Number:   dev.Number,
+			UniqueID: uid,
+		},
+		driver: alpaca.DriverInfo{
+			Name:             driverName,
+			Version:          driverVersion,
+			InterfaceVersion: 3,
+		},
+	}, nil
+}
+
+// Shutdown only logs: the wheel reaches its slot at the arrival time of the
+// move, without a timer to stop.
+func (f *FilterWheelSimulator) Shutdown(ctx context.Context) error {
+	f.logger.Info("Shutting down filter wheel simulator")
+	return nil
+}
+
+func (f *FilterWheelSimulator) DeviceInfo() alpaca.DeviceInfo {
+	info := f.info
+
+	format := f.getConfig().Description
+	if format == "" {
+		format = defaultDescription
+	}
+	info.Description = alpaca.ExpandDescription(format, map[string]string{
+		"driver": driverVersion,
+	})
+	return info
+}
+
+func (f *FilterWheelSimulator) DriverInfo() alpaca.DriverInfo {
+	return f.driver
+}
+
+// Disabled reports whether the wheel is hidden by the enabled switch of its
+// setup page.
+func (f *FilterWheelSimulator) Disabled() bool {
+	return f.getConfig().Disabled
+}
+
+func (f *FilterWheelSimulator) GetState() []alpaca.StateProperty {
+	props := []alpaca.StateProperty{
+		alpaca.StateTimeStamp(time.Now()),
+	}
+
+	if f.connected.Load() {
+		props = append(props, alpaca.StateProperty{Name: "Position", Value: f.Position()})
+	}
+
+	return props
+}
+
+func (f *FilterWheelSimulator) Connect() error {
+	if !f.connected.Swap(true) {
+		f.logger.Infof("%s connected", f.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventConnected,
+			Device:  f.info.Name,
+			Message: "Simulator connected",
+		})
+	}
+	return nil
+}
+
+func (f *FilterWheelSimulator) Disconnect() error {
+	if f.connected.Swap(false) {
+		f.logger.Infof("%s disconnected", f.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventDisconnected,
+			Device:  f.info.Name,
+			Message: "Simulator disconnected",
+		})
+	}
+	return nil
+}
+
+func (f *FilterWheelSimulator) Connected() bool {
+	return f.connected.Load()
+}
+
+func (f *FilterWheelSimulator) Connecting() bool {
+	return false
+}
+
+func (f *FilterWheelSimulator) getConfig() Config {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.config
+}
+
+func (f *FilterWheelSimulator) Names() []string {
+	filters := f.getConfig().Filters
+	names := make([]string, len(filters))
+	for i, filter := range filters {
+		names[i] = filter.Name
+	}
+	return names
+}
+
+func (f *FilterWheelSimulator) FocusOffsets() []int {
+	filters := f.getConfig().Filters
+	offsets := make([]int, len(filters))
+	for i, filter := range filters {
+		offsets[i] = filter.FocusOffset
+	}
+	return offsets
+}
+
+// Position returns the filter in place, or -1 while the wheel turns.
+func (f *FilterWheelSimulator) Position() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Now().Before(f.arrival) {
+		return -1
+	}
+	return f.position
+}
+
+// SetPosition turns the wheel to a filter the shortest way, taking the slot
+// time for each slot passed.
+func (f *FilterWheelSimulator) SetPosition(position int) error {
+	if !f.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := len(f.config.Filters)
+	if position < 0 || position >= n {
+		return errors.Errorf(errors.ErrInvalidValue, "position %d out of range 0 to %d", position, n-1)
+	}
+
+	slots := slotsBetween(f.position, position, n)
+	f.position = position
+	f.arrival = time.Now().Add(time.Duration(float64(slots) * f.config.SlotTime * float64(time.Second)))
+	f.logger.Infof("Moving to filter %d (%s), %d slots", position, f.config.Filters[position].Name, slots)
+	return nil
+}
+
+// slotsBetween returns the number of slots between two positions of a wheel
+// of n filters, turning the shortest way.
+func slotsBetween(from, to, n int) int {
+	d := (to - from + n) % n
+	return min(d, n-d)
+}
+
+func (f *FilterWheelSimulator) HandleSetup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := f.store.GetConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		f.renderSetupForm(w, cfg, false, "")
+
+	case http.MethodPost:
+		cfg, err := parseFilterWheelSetupForm(r)
+		if err != nil {
+			f.renderSetupForm(w, cfg, false, err.Error())
+			return
+		}
+
+		f.logger.Infof("Setting filter wheel config: %+v", alpaca.Redact(cfg))
+		f.mu.Lock()
+		f.config = cfg
+		if f.position >= len(cfg.Filters) {
+			f.position, f.arrival = 0, time.Time{}
+		}
+		f.mu.Unlock()
+		if err := f.store.SetConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		f.renderSetupForm(w, cfg, true, "")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *FilterWheelSimulator) renderSetupForm(w http.ResponseWriter, cfg Config, success bool, err string) {
+	position, filter := f.Position(), ""
+	if names := f.Names(); position >= 0 && position < len(names) {
+		filter = names[position]
+	}
+
+	data := struct {
+		Config
+		Position  int
+		Filter    string // Name of the filter in place
+		Connected bool
+		Success   bool
+		Error     string
+	}{cfg, position, filter, f.connected.Load(), success, err}
+
+	if err := f.tmpl.ExecuteTemplate(w, "filterwheel_simulator_setup.html", data); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		f.logger.Errorf("Error rendering template: %v", err)
+	}
+}
+
+func parseFilterWheelSetupForm(r *http.Request) (Config, error) {
+	var cfg Config
+	if err := r.ParseForm(); err != nil {
+		return cfg, fmt.Errorf("error parsing form: %v", err)
+	}
+
+	filters, err := parseFilters(r.FormValue("filters"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Filters = filters
+	if cfg.SlotTime, err = strconv.ParseFloat(r.FormValue("slot-time"), 64); err != nil || cfg.SlotTime < 0 {
+		return cfg, fmt.Errorf("invalid slot time: must be a number of seconds")
+	}
+	cfg.Description = strings.TrimSpace(r.FormValue("description"))
+	cfg.Disabled = r.FormValue("enabled") != "true"
+	return cfg, nil
+}
+
+// parseFilters reads one filter per line, its name and its focus offset
+// separated by a comma, e.g. "Ha, -30". The offset defaults to 0.
+func parseFilters(s string) ([]Filter, error) {
+	var filters []Filter
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, offset, _ := strings.Cut(line, ",")
+		filter := Filter{Name: strings.TrimSpace(name)}
+		if filter.Name == "" {
+			return nil, fmt.Errorf("invalid filter %q: missing name", line)
+		}
+		if offset = strings.TrimSpace(offset); offset != "" {
+			var err error
+			if filter.FocusOffset, err = strconv.Atoi(offset); err != nil {
+				return nil, fmt.Errorf("invalid focus offset of filter %s: %q", filter.Name, offset)
+			}
+		}
+		filters = append(filters, filter)
+	}
+	if len(filters) == 0 {
+		return nil, fmt.Errorf("the wheel needs at least one filter")
+	}
+	return filters, nil
+}
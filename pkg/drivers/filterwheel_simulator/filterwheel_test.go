@@ -0,0 +1,48 @@
+package filterwheel_simulator
+
+import (
+	"alpaca/internal/simtest"
+	"alpaca/pkg/alpaca/errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlotsBetween(t *testing.T) {
+	assert.Equal(t, 0, slotsBetween(3, 3, 7))
+	assert.Equal(t, 2, slotsBetween(1, 3, 7))
+	assert.Equal(t, 2, slotsBetween(6, 1, 7), "the shortest way wraps around")
+	assert.Equal(t, 3, slotsBetween(0, 4, 7))
+}
+
+func TestSetPosition(t *testing.T) {
+	f := simtest.Connected(t, New)
+	assert.Equal(t, []string{"L", "R", "G", "B", "Ha", "OIII", "SII"}, f.Names())
+	assert.Equal(t, -30, f.FocusOffsets()[4])
+	assert.Equal(t, 0, f.Position())
+
+	require.NoError(t, f.SetPosition(5))
+	assert.Equal(t, -1, f.Position(), "moving")
+
+	f.mu.Lock()
+	f.arrival = f.arrival.Add(-time.Minute)
+	f.mu.Unlock()
+	assert.Equal(t, 5, f.Position())
+
+	assert.ErrorIs(t, f.SetPosition(7), errors.ErrInvalidValue)
+	require.NoError(t, f.Disconnect())
+	assert.ErrorIs(t, f.SetPosition(1), errors.ErrNotConnected)
+}
+
+func TestParseFilters(t *testing.T) {
+	filters, err := parseFilters("L, 0\r\nHa,-30\n\nClear\n")
+	require.NoError(t, err)
+	assert.Equal(t, []Filter{{"L", 0}, {"Ha", -30}, {"Clear", 0}}, filters)
+
+	for _, s := range []string{"", "L, x", ", 5"} {
+		_, err := parseFilters(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,67 @@
+package filterwheel_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultDescription = "Filter wheel simulator {driver} @ {host}"
+
+	filterWheelConfigKey = "filterwheel_config"
+)
+
+// Filter is a filter of the wheel.
+type Filter struct {
+	Name        string `json:"name"`
+	FocusOffset int    `json:"focus_offset"` // focuser steps relative to the reference filter
+}
+
+// Config holds the filters of the simulated wheel and its speed.
+type Config struct {
+	Filters  []Filter `json:"filters"`
+	SlotTime float64  `json:"slot_time"` // seconds to turn by one slot
+
+	Description string `json:"description"` // device description, with {driver} and {host} placeholders
+
+	Disabled bool `json:"disabled"` // hidden from the configured devices, e.g. in production
+}
+
+type store = alpaca.DriverStore[Config]
+
+// NewStoreWithKey creates a store for the configuration saved under key,
+// which defaults to a disabled wheel of LRGB and narrowband filters, turning
+// by a slot per second.
+func NewStoreWithKey(db *bolt.DB, key string) (*store, error) {
+	return alpaca.NewDriverStore(db, key, Config{
+		Filters: []Filter{
+			{Name: "L", FocusOffset: 0},
+			{Name: "R", FocusOffset: 12},
+			{Name: "G", FocusOffset: 15},
+			{Name: "B", FocusOffset: 20},
+			{Name: "Ha", FocusOffset: -30},
+			{Name: "OIII", FocusOffset: -25},
+			{Name: "SII", FocusOffset: -35},
+		},
+		SlotTime:    1,
+		Description: defaultDescription,
+		Disabled:    true,
+	})
+}
+
+// ApplySettings merges the settings of a device file into the filter wheel simulator
+// configuration saved under key, the default key if empty, and saves it if
+// they changed it.
+func ApplySettings(db *bolt.DB, key string, settings json.RawMessage) error {
+	if key == "" {
+		key = filterWheelConfigKey
+	}
+
+	st, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return err
+	}
+	return st.ApplySettings(settings)
+}
@@ -0,0 +1,373 @@
+// Package focuser_simulator simulates an absolute focuser with temperature
+// compensation, so the Focuser API can be exercised without a real focuser.
+package focuser_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	focuserUID    = "4c7e2a91-d3f5-4b86-9e10-8f2b6a5c3d74"
+	deviceName    = "Focuser Simulator"
+	deviceType    = "Focuser"
+	driverName    = "ZRO Focuser Simulator"
+	driverVersion = "1.0"
+)
+
+// move is a move in progress, interpolated from its start to its target.
+type move struct {
+	from, to float64 // Steps
+	start    time.Time
+	duration time.Duration
+}
+
+// FocuserSimulator implements the alpaca.Focuser interface.
+type FocuserSimulator struct {
+	logger log.FieldLogger
+	tmpl   *template.Template
+	store  *store
+	config Config
+
+	info   alpaca.DeviceInfo
+	driver alpaca.DriverInfo
+
+	connected atomic.Bool
+
+	mu       sync.Mutex
+	position float64 // Steps, while no move is in progress
+	move     *move   // Move in progress, if any
+	tempComp bool
+	tempRef  float64 // Temperature of the last compensation, in Celsius
+}
+
+// New creates a focuser simulator for a device instance, with the settings
+// saved under its key, focuser_config when it has none. The focuser starts at
+// mid travel.
+func New(dev alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*FocuserSimulator, error) {
+	key := dev.Key
+	if key == "" {
+		key = focuserConfigKey
+	}
+
+	store, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %v", err)
+	}
+
+	config, err := store.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get focuser config: %v", err)
+	}
+
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(focuserUID, dev.Key)
+	}
+
+	return &FocuserSimulator{
+		logger: logger,
+		tmpl:   tmpl,
+		store:  store,
+		config: config,
+
+		info: alpaca.DeviceInfo{
+			Name:     deviceName,
+			Type:     deviceType,
+			Number:   dev.Number,
+			UniqueID: uid,
+		},
+		driver: alpaca.DriverInfo{
+			Name:             driverName,
+			Version:          driverVersion,
+			InterfaceVersion: 4,
+		},
+		position: float64(config.MaxStep / 2),
+	}, nil
+}
+
+// Shutdown only logs, since a move is interpolated when the position is
+// read and nothing runs in the background.
+func (f *FocuserSimulator) Shutdown(ctx context.Context) error {
+	f.logger.Info("Shutting down focuser simulator")
+	return nil
+}
+
+func (f *FocuserSimulator) DeviceInfo() alpaca.DeviceInfo {
+	info := f.info
+
+	format := f.config.Description
+	if format == "" {
+		format = defaultDescription
+	}
+	info.Description = alpaca.ExpandDescription(format, map[string]string{
+		"driver": driverVersion,
+	})
+	return info
+}
+
+func (f *FocuserSimulator) DriverInfo() alpaca.DriverInfo {
+	return f.driver
+}
+
+// Disabled reports whether the focuser is turned off on its setup page.
+func (f *FocuserSimulator) Disabled() bool {
+	return f.config.Disabled
+}
+
+func (f *FocuserSimulator) GetState() []alpaca.StateProperty {
+	props := []alpaca.StateProperty{
+		alpaca.StateTimeStamp(time.Now()),
+	}
+
+	if f.connected.Load() {
+		props = append(props, f.Status().ToProperties()...)
+	}
+
+	return props
+}
+
+func (f *FocuserSimulator) Connect() error {
+	if !f.connected.Swap(true) {
+		f.logger.Infof("%s connected", f.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventConnected,
+			Device:  f.info.Name,
+			Message: "Simulator connected",
+		})
+	}
+	return nil
+}
+
+func (f *FocuserSimulator) Disconnect() error {
+	if f.connected.Swap(false) {
+		f.logger.Infof("%s disconnected", f.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventDisconnected,
+			Device:  f.info.Name,
+			Message: "Simulator disconnected",
+		})
+	}
+	return nil
+}
+
+func (f *FocuserSimulator) Connected() bool {
+	return f.connected.Load()
+}
+
+func (f *FocuserSimulator) Connecting() bool {
+	return false
+}
+
+func (f *FocuserSimulator) Capabilities() alpaca.FocuserCapabilities {
+	return alpaca.FocuserCapabilities{
+		Absolute:          true,
+		MaxIncrement:      f.config.MaxStep,
+		MaxStep:           f.config.MaxStep,
+		StepSize:          f.config.StepSize,
+		TempCompAvailable: true,
+	}
+}
+
+// ambient returns the simulated temperature at a time: 10 °C on average,
+// warmest at 15:00 UTC and coldest at 03:00 UTC.
+func ambient(now time.Time) float64 {
+	h := now.UTC()
+	hours := float64(h.Hour()) + float64(h.Minute())/60 + float64(h.Second())/3600
+	return 10 + 5*math.Cos(2*math.Pi*(hours-15)/24)
+}
+
+// current returns the position at a time, in steps.
+func (f *FocuserSimulator) current(now time.Time) float64 {
+	m := f.move
+	if m == nil {
+		return f.position
+	}
+	frac := 1.0
+	if m.duration > 0 {
+		frac = math.Min(1, now.Sub(m.start).Seconds()/m.duration.Seconds())
+	}
+	return m.from + frac*(m.to-m.from)
+}
+
+// settle ends a finished move, then applies the temperature compensation
+// for the change of temperature since the last one. The compensation waits
+// for the end of the moves.
+func (f *FocuserSimulator) settle(now time.Time) {
+	if m := f.move; m != nil {
+		if now.Before(m.start.Add(m.duration)) {
+			return
+		}
+		f.position, f.move = m.to, nil
+	}
+	if f.tempComp {
+		t := ambient(now)
+		f.position = math.Max(0, math.Min(float64(f.config.MaxStep), f.position+f.config.TempCoefficient*(t-f.tempRef)))
+		f.tempRef = t
+	}
+}
+
+func (f *FocuserSimulator) Status() alpaca.FocuserStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	f.settle(now)
+	temperature := ambient(now)
+
+	return alpaca.FocuserStatus{
+		Position:    int(math.Round(f.current(now))),
+		IsMoving:    f.move != nil,
+		TempComp:    f.tempComp,
+		Temperature: &temperature,
+	}
+}
+
+func (f *FocuserSimulator) SetTempComp(on bool) error {
+	if !f.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	f.settle(now)
+	if on && !f.tempComp {
+		f.tempRef = ambient(now)
+	}
+	f.tempComp = on
+	return nil
+}
+
+// Move starts a move to a position at the configured speed.
+func (f *FocuserSimulator) Move(position int) error {
+	if !f.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	if position < 0 || position > f.config.MaxStep {
+		return errors.Errorf(errors.ErrInvalidValue, "position %d out of range 0 to %d", position, f.config.MaxStep)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	f.settle(now)
+	from := f.current(now)
+	f.move = &move{
+		from:     from,
+		to:       float64(position),
+		start:    now,
+		duration: time.Duration(math.Abs(float64(position)-from) / float64(f.config.Speed) * float64(time.Second)),
+	}
+	f.logger.Infof("Moving from %.0f to %d", from, position)
+	return nil
+}
+
+// Halt stops a move where it is.
+func (f *FocuserSimulator) Halt() error {
+	if !f.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	f.settle(now)
+	if f.move != nil {
+		f.position, f.move = f.current(now), nil
+		f.logger.Infof("Halted at %.0f", f.position)
+	}
+	return nil
+}
+
+func (f *FocuserSimulator) HandleSetup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := f.store.GetConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		f.renderSetupForm(w, cfg, false, "")
+
+	case http.MethodPost:
+		cfg, err := parseFocuserSetupForm(r)
+		if err != nil {
+			f.renderSetupForm(w, cfg, false, err.Error())
+			return
+		}
+
+		f.logger.Infof("Setting focuser config: %+v", alpaca.Redact(cfg))
+		f.mu.Lock()
+		f.settle(time.Now())
+		if f.move == nil {
+			f.position = math.Min(f.position, float64(cfg.MaxStep))
+		}
+		f.config = cfg
+		f.mu.Unlock()
+		if err := f.store.SetConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		f.renderSetupForm(w, cfg, true, "")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *FocuserSimulator) renderSetupForm(w http.ResponseWriter, cfg Config, success bool, err string) {
+	data := struct {
+		Config
+		Status    alpaca.FocuserStatus
+		Connected bool
+		Success   bool
+		Error     string
+	}{cfg, f.Status(), f.connected.Load(), success, err}
+
+	if err := f.tmpl.ExecuteTemplate(w, "focuser_simulator_setup.html", data); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		f.logger.Errorf("Error rendering template: %v", err)
+	}
+}
+
+func parseFocuserSetupForm(r *http.Request) (Config, error) {
+	var cfg Config
+	if err := r.ParseForm(); err != nil {
+		return cfg, fmt.Errorf("error parsing form: %v", err)
+	}
+
+	var err error
+	if cfg.MaxStep, err = strconv.Atoi(r.FormValue("max-step")); err != nil || cfg.MaxStep < 1 {
+		return cfg, fmt.Errorf("invalid maximum step: must be a positive number of steps")
+	}
+	if cfg.Speed, err = strconv.Atoi(r.FormValue("speed")); err != nil || cfg.Speed < 1 {
+		return cfg, fmt.Errorf("invalid speed: must be a positive number of steps per second")
+	}
+	if cfg.StepSize, err = strconv.ParseFloat(r.FormValue("step-size"), 64); err != nil || cfg.StepSize < 0 {
+		return cfg, fmt.Errorf("invalid step size: must be a number of microns, 0 if unknown")
+	}
+	if cfg.TempCoefficient, err = strconv.ParseFloat(r.FormValue("temp-coefficient"), 64); err != nil {
+		return cfg, fmt.Errorf("invalid temperature coefficient: %v", err)
+	}
+	cfg.Description = strings.TrimSpace(r.FormValue("description"))
+	cfg.Disabled = r.FormValue("enabled") != "true"
+	return cfg, nil
+}
@@ -0,0 +1,93 @@
+package focuser_simulator
+
+import (
+	"alpaca/internal/simtest"
+	"alpaca/pkg/alpaca/errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmbient(t *testing.T) {
+	day := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	assert.InDelta(t, 15, ambient(day.Add(15*time.Hour)), 1e-9)
+	assert.InDelta(t, 5, ambient(day.Add(3*time.Hour)), 1e-9)
+}
+
+func TestMove(t *testing.T) {
+	f := simtest.Connected(t, New)
+
+	st := f.Status()
+	assert.Equal(t, 25000, st.Position, "starts at mid travel")
+	assert.False(t, st.IsMoving)
+	require.NotNil(t, st.Temperature)
+
+	require.NoError(t, f.Move(30000))
+	assert.True(t, f.Status().IsMoving)
+
+	// Half way through the 5 seconds of the move.
+	f.mu.Lock()
+	f.move.start = f.move.start.Add(-2500 * time.Millisecond)
+	f.mu.Unlock()
+	require.NoError(t, f.Halt())
+	st = f.Status()
+	assert.False(t, st.IsMoving)
+	assert.InDelta(t, 27500, st.Position, 10)
+
+	require.NoError(t, f.Move(20000))
+	f.mu.Lock()
+	f.move.start = f.move.start.Add(-time.Hour)
+	f.mu.Unlock()
+	st = f.Status()
+	assert.False(t, st.IsMoving)
+	assert.Equal(t, 20000, st.Position)
+
+	assert.ErrorIs(t, f.Move(50001), errors.ErrInvalidValue)
+	require.NoError(t, f.Disconnect())
+	assert.ErrorIs(t, f.Move(100), errors.ErrNotConnected)
+}
+
+func TestTempComp(t *testing.T) {
+	f := simtest.Connected(t, New)
+	require.NoError(t, f.SetTempComp(true))
+	assert.True(t, f.Status().TempComp)
+
+	// Two degrees warmer since the compensation was turned on.
+	f.mu.Lock()
+	f.tempRef -= 2
+	f.mu.Unlock()
+	assert.InDelta(t, 25000-2*20, f.Status().Position, 1)
+
+	require.NoError(t, f.SetTempComp(false))
+	f.mu.Lock()
+	f.tempRef -= 2
+	f.mu.Unlock()
+	assert.InDelta(t, 25000-2*20, f.Status().Position, 1, "no compensation while off")
+}
+
+func TestParseFocuserSetupForm(t *testing.T) {
+	form := url.Values{
+		"max-step":         {"10000"},
+		"step-size":        {"3.5"},
+		"speed":            {"500"},
+		"temp-coefficient": {"-12"},
+		"enabled":          {"true"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	cfg, err := parseFocuserSetupForm(req)
+	require.NoError(t, err)
+	assert.Equal(t, Config{MaxStep: 10000, StepSize: 3.5, Speed: 500, TempCoefficient: -12}, cfg)
+
+	form.Set("speed", "0")
+	req = httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = parseFocuserSetupForm(req)
+	assert.Error(t, err)
+}
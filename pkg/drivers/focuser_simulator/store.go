@@ -0,0 +1,57 @@
+package focuser_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultDescription = "Focuser simulator {driver} @ {host}"
+
+	focuserConfigKey = "focuser_config"
+)
+
+// Config holds the travel and the speed of the simulated focuser.
+type Config struct {
+	MaxStep         int     `json:"max_step"`         // travel in steps
+	StepSize        float64 `json:"step_size"`        // microns per step
+	Speed           int     `json:"speed"`            // steps per second
+	TempCoefficient float64 `json:"temp_coefficient"` // steps per degree Celsius, applied by the temperature compensation
+
+	Description string `json:"description"` // device description, with {driver} and {host} placeholders
+
+	Disabled bool `json:"disabled"` // hidden from the configured devices, e.g. in production
+}
+
+type store = alpaca.DriverStore[Config]
+
+// NewStoreWithKey creates a store for the configuration saved under key,
+// which defaults to a disabled focuser with a travel of 50000 steps of 5
+// microns, moving outwards as it gets colder.
+func NewStoreWithKey(db *bolt.DB, key string) (*store, error) {
+	return alpaca.NewDriverStore(db, key, Config{
+		MaxStep:         50000,
+		StepSize:        5,
+		Speed:           1000,
+		TempCoefficient: -20,
+		Description:     defaultDescription,
+		Disabled:        true,
+	})
+}
+
+// ApplySettings merges the settings of a device file into the focuser simulator
+// configuration saved under key, the default key if empty, and saves it if
+// they changed it.
+func ApplySettings(db *bolt.DB, key string, settings json.RawMessage) error {
+	if key == "" {
+		key = focuserConfigKey
+	}
+
+	st, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return err
+	}
+	return st.ApplySettings(settings)
+}
@@ -0,0 +1,289 @@
+// Package remote re-exposes a device of another Alpaca server under a device
+// number of this server, so client software limited to one server address
+// can use the devices of several servers.
+package remote
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/version"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	remoteUID  = "3f1c7d52-8a4e-4b0f-9d61-2c5e8b7a9f10"
+	driverName = "Alpaca Proxy"
+
+	// clientID is the ClientID of the requests the proxy sends on its own,
+	// such as reading the device name. Forwarded requests keep the ClientID
+	// of the client. Any fixed value works; 3 keeps the requests easy to
+	// tell apart in the logs of the other server.
+	clientID = 3
+
+	// timeout bounds the requests the proxy sends on its own.
+	timeout = 2 * time.Second
+
+	// nameRetry is how long to wait before reading the name of the device
+	// again after a failure.
+	nameRetry = time.Minute
+)
+
+// clientHeaders are the credentials of the clients of this server, which
+// are not forwarded to the other server.
+var clientHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// Settings are the settings of a remote device, given in a device file.
+type Settings struct {
+	APIKey string `json:"api_key"` // Key of the other server, sent as a bearer token
+}
+
+// parseSettings reads the settings of a device file, none if empty.
+func parseSettings(settings json.RawMessage) (Settings, error) {
+	var s Settings
+	if len(settings) == 0 {
+		return s, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(settings))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return s, fmt.Errorf("invalid remote settings: %v", err)
+	}
+	return s, nil
+}
+
+// ApplySettings checks the settings of a device file. A remote device stores
+// nothing: its settings are read again from the device file at startup.
+func ApplySettings(_ *bolt.DB, _ string, settings json.RawMessage) error {
+	_, err := parseSettings(settings)
+	return err
+}
+
+// devicePath matches the path of a device URL, e.g. /api/v1/telescope/0.
+var devicePath = regexp.MustCompile(`^/api/v(\d+)/([A-Za-z]+)/(\d+)/?$`)
+
+// deviceTypes are the Alpaca device types, to restore the case of the type
+// in a device URL.
+var deviceTypes = []alpaca.DeviceType{
+	alpaca.DeviceTypeCamera,
+	alpaca.DeviceTypeCover,
+	alpaca.DeviceTypeDome,
+	alpaca.DeviceTypeFilterWheel,
+	alpaca.DeviceTypeFocuser,
+	alpaca.DeviceTypeConditions,
+	alpaca.DeviceTypeRotator,
+	alpaca.DeviceTypeSafety,
+	alpaca.DeviceTypeSwitch,
+	alpaca.DeviceTypeTelescope,
+}
+
+// Device is a device of another Alpaca server. Its API and setup requests
+// are forwarded to that server; the server only calls the Device methods
+// for the management API and the status pages.
+type Device struct {
+	logger log.FieldLogger
+	client *alpaca.Client
+	setup  *url.URL // Setup page of the device on the other server
+
+	api, setupProxy *httputil.ReverseProxy
+
+	mu        sync.RWMutex
+	info      alpaca.DeviceInfo
+	named     bool      // The name was read from the other server
+	nameTried time.Time // Last attempt to read the name
+	naming    atomic.Bool
+}
+
+// New creates the proxy of the device at the URL in the Key of the device
+// configuration, e.g. http://192.168.1.20:11111/api/v1/telescope/0. The
+// device is served under the configured number.
+func New(dev alpaca.DeviceConfig, logger log.FieldLogger) (*Device, error) {
+	target, err := url.Parse(dev.Key)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("invalid device URL %q, expected e.g. http://host:11111/api/v1/telescope/0", dev.Key)
+	}
+	m := devicePath.FindStringSubmatch(target.Path)
+	if m == nil {
+		return nil, fmt.Errorf("invalid device URL %q, expected a path like /api/v1/telescope/0", dev.Key)
+	}
+	devType, ok := parseDeviceType(m[2])
+	if !ok {
+		return nil, fmt.Errorf("unknown device type %q", m[2])
+	}
+	number, _ := strconv.Atoi(m[3])
+
+	api, setup := *target, *target
+	api.Path = fmt.Sprintf("/api/v%s/%s/%d", m[1], strings.ToLower(string(devType)), number)
+	setup.Path = fmt.Sprintf("/setup/v%s/%s/%d", m[1], strings.ToLower(string(devType)), number)
+
+	settings, err := parseSettings(dev.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(remoteUID, api.String())
+	}
+
+	d := &Device{
+		logger: logger,
+		client: alpaca.NewClient(api.String(), clientID, timeout),
+		setup:  &setup,
+		info: alpaca.DeviceInfo{
+			Name:     fmt.Sprintf("%s at %s", devType, target.Host),
+			Type:     devType,
+			Number:   dev.Number,
+			UniqueID: uid,
+		},
+	}
+	d.client.SetAPIKey(settings.APIKey)
+	d.api = d.reverseProxy(&api, settings.APIKey)
+	d.setupProxy = d.reverseProxy(&setup, settings.APIKey)
+	return d, nil
+}
+
+// parseDeviceType returns the device type named in a device URL, in any
+// case.
+func parseDeviceType(name string) (alpaca.DeviceType, bool) {
+	for _, t := range deviceTypes {
+		if strings.EqualFold(name, string(t)) {
+			return t, true
+		}
+	}
+	return "", false
+}
+
+// reverseProxy forwards the requests, whose path is relative to the device,
+// to the target. The credentials of the client are replaced with the key of
+// the other server, if any.
+func (d *Device) reverseProxy(target *url.URL, apiKey string) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			for _, h := range clientHeaders {
+				r.Out.Header.Del(h)
+			}
+			if apiKey != "" {
+				r.Out.Header.Set("Authorization", "Bearer "+apiKey)
+			}
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			d.logger.Warnf("Failed to forward %s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, fmt.Sprintf("%s is unreachable: %v", d.DeviceInfo().Name, err), http.StatusBadGateway)
+		},
+	}
+}
+
+// ProxyAPI forwards the device API requests to the other server.
+func (d *Device) ProxyAPI() http.Handler {
+	return d.api
+}
+
+// ProxySetup forwards the setup page requests to the other server.
+func (d *Device) ProxySetup() http.Handler {
+	return d.setupProxy
+}
+
+// DeviceInfo returns the device information. The name is read from the
+// other server in the background, and is made of the device type and host
+// until then.
+func (d *Device) DeviceInfo() alpaca.DeviceInfo {
+	d.mu.RLock()
+	info, stale := d.info, !d.named && time.Since(d.nameTried) > nameRetry
+	d.mu.RUnlock()
+
+	if stale && d.naming.CompareAndSwap(false, true) {
+		go d.readName()
+	}
+	return info
+}
+
+func (d *Device) readName() {
+	defer d.naming.Store(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var name string
+	err := d.client.Get(ctx, "name", &name)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nameTried = time.Now()
+	if err != nil {
+		d.logger.Warnf("Failed to read the device name: %v", err)
+		return
+	}
+	d.info.Name, d.named = name, true
+}
+
+func (d *Device) DriverInfo() alpaca.DriverInfo {
+	return alpaca.DriverInfo{
+		Name:             driverName,
+		Version:          version.Version,
+		InterfaceVersion: 1,
+	}
+}
+
+// GetState is not used: the devicestate requests are forwarded.
+func (d *Device) GetState() []alpaca.StateProperty {
+	return nil
+}
+
+// Connected reports whether the device is connected, false if the other
+// server does not answer.
+func (d *Device) Connected() bool {
+	return d.getBool("connected")
+}
+
+func (d *Device) Connecting() bool {
+	return d.getBool("connecting")
+}
+
+func (d *Device) getBool(property string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var value bool
+	if err := d.client.Get(ctx, property, &value); err != nil {
+		d.logger.Debugf("Failed to read %s: %v", property, err)
+		return false
+	}
+	return value
+}
+
+func (d *Device) Connect() error {
+	return d.setConnected(true)
+}
+
+func (d *Device) Disconnect() error {
+	return d.setConnected(false)
+}
+
+// setConnected sets the Connected property, supported by all interface
+// versions, unlike the Connect and Disconnect methods.
+func (d *Device) setConnected(connected bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.client.Put(ctx, "connected", url.Values{"Connected": {strconv.FormatBool(connected)}})
+}
+
+// HandleSetup redirects to the setup page on the other server.
+func (d *Device) HandleSetup(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, d.setup.String()+"/setup", http.StatusFound)
+}
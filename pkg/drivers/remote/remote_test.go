@@ -0,0 +1,138 @@
+package remote
+
+import (
+	"alpaca/pkg/alpaca"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// otherServer is a minimal Alpaca server with a telescope 0, recording the
+// paths it is asked for.
+func otherServer(t *testing.T, paths chan<- string) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.Method + " " + r.URL.Path
+		values := map[string]any{
+			"/api/v1/telescope/0/name":           "Mount",
+			"/api/v1/telescope/0/connected":      true,
+			"/api/v1/telescope/0/rightascension": 12.5,
+		}
+		value, ok := values[r.URL.Path]
+		if r.URL.Path == "/setup/v1/telescope/0/setup" {
+			io.WriteString(w, "mount setup")
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"Value": value})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestNewInvalidURL(t *testing.T) {
+	for _, u := range []string{"", "192.168.1.20:11111/api/v1/telescope/0", "http://host/api/v1/telescope", "http://host/api/v1/toaster/0"} {
+		_, err := New(alpaca.DeviceConfig{Driver: "remote", Number: 1, Key: u}, log.StandardLogger())
+		assert.Error(t, err, u)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	paths := make(chan string, 16)
+	other := otherServer(t, paths)
+
+	dev, err := New(alpaca.DeviceConfig{Driver: "remote", Number: 5, Key: other.URL + "/api/v1/Telescope/0/"}, log.StandardLogger())
+	require.NoError(t, err)
+	info := dev.DeviceInfo()
+	assert.Equal(t, alpaca.DeviceTypeTelescope, info.Type)
+	assert.Equal(t, 5, info.Number)
+	assert.NotEmpty(t, info.UniqueID)
+	assert.Eventually(t, func() bool { return dev.DeviceInfo().Name == "Mount" }, time.Second, 10*time.Millisecond)
+	<-paths
+
+	server := alpaca.NewServer(alpaca.ServerDescription{Name: "Facade"}, []alpaca.Device{dev}, nil, nil)
+	ts := httptest.NewServer(server.AddRoutes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/v1/telescope/5/rightascension?ClientID=1&ClientTransactionID=2")
+	require.NoError(t, err)
+	var body struct{ Value float64 }
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	resp.Body.Close()
+	assert.Equal(t, 12.5, body.Value)
+	assert.Equal(t, "GET /api/v1/telescope/0/rightascension", <-paths, "remapped to the number on the other server")
+
+	resp, err = http.Get(ts.URL + "/setup/v1/telescope/5/setup")
+	require.NoError(t, err)
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "mount setup", string(page))
+	<-paths
+
+	assert.True(t, dev.Connected())
+	assert.Equal(t, "GET /api/v1/telescope/0/connected", <-paths)
+}
+
+func TestProxyUnreachable(t *testing.T) {
+	dev, err := New(alpaca.DeviceConfig{Driver: "remote", Number: 5, Key: "http://127.0.0.1:1/api/v1/telescope/0"}, log.StandardLogger())
+	require.NoError(t, err)
+
+	server := alpaca.NewServer(alpaca.ServerDescription{Name: "Facade"}, []alpaca.Device{dev}, nil, nil)
+	ts := httptest.NewServer(server.AddRoutes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/v1/telescope/5/rightascension")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.False(t, dev.Connected())
+}
+
+func TestProxyCredentials(t *testing.T) {
+	headers := make(chan http.Header, 16)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		json.NewEncoder(w).Encode(map[string]any{"Value": "Mount"})
+	}))
+	defer other.Close()
+
+	settings := json.RawMessage(`{"api_key": "remote-key"}`)
+	dev, err := New(alpaca.DeviceConfig{Driver: "remote", Number: 5, Key: other.URL + "/api/v1/telescope/0", Settings: settings}, log.StandardLogger())
+	require.NoError(t, err)
+	dev.DeviceInfo()
+	assert.Equal(t, "Bearer remote-key", (<-headers).Get("Authorization"), "the name is read with the key of the other server")
+
+	server := alpaca.NewServer(alpaca.ServerDescription{Name: "Facade"}, []alpaca.Device{dev}, nil, nil)
+	ts := httptest.NewServer(server.AddRoutes())
+	defer ts.Close()
+
+	for _, path := range []string{"/api/v1/telescope/5/name", "/setup/v1/telescope/5/setup"} {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("", "local-key")
+		req.Header.Set("Proxy-Authorization", "Basic cHJveHk6c2VjcmV0")
+		req.Header.Set("Cookie", "session=local")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		h := <-headers
+		assert.Equal(t, "Bearer remote-key", h.Get("Authorization"), path)
+		assert.Empty(t, h.Get("Proxy-Authorization"), path)
+		assert.Empty(t, h.Get("Cookie"), path)
+	}
+
+	_, err = New(alpaca.DeviceConfig{Driver: "remote", Key: other.URL + "/api/v1/telescope/0", Settings: json.RawMessage(`{"password": "x"}`)}, log.StandardLogger())
+	assert.Error(t, err, "unknown setting")
+}
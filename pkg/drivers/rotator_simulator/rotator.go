@@ -0,0 +1,447 @@
+// Package rotator_simulator simulates a camera rotator, so the Rotator API
+// can be exercised without a real rotator.
+package rotator_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	rotatorUID    = "9b3f6d20-7a4e-4c15-b8e2-51d0c7f9a36e"
+	deviceName    = "Rotator Simulator"
+	deviceType    = "Rotator"
+	driverName    = "ZRO Rotator Simulator"
+	driverVersion = "1.0"
+)
+
+// move is a move in progress, interpolated from its start to its target.
+// The rotator does not turn past 0 degrees, to spare the cables, so the
+// moves never wrap around.
+type move struct {
+	from, to float64 // Mechanical degrees
+	start    time.Time
+	duration time.Duration
+}
+
+// RotatorSimulator implements the alpaca.Rotator interface. The sky position
+// is the mechanical position turned by the sync offset, in the opposite
+// direction while reversed.
+type RotatorSimulator struct {
+	logger log.FieldLogger
+	tmpl   *template.Template
+	store  *store
+
+	info   alpaca.DeviceInfo
+	driver alpaca.DriverInfo
+
+	connected atomic.Bool
+
+	mu         sync.Mutex
+	config     Config
+	mechanical float64 // Degrees, while no move is in progress
+	move       *move   // Move in progress, if any
+	offset     float64 // Sky position at the mechanical 0, in degrees
+	reverse    bool
+	target     float64 // Sky position of the last move, in degrees
+}
+
+// New creates a rotator simulator for a device instance, with the settings
+// saved under its key, rotator_config when it has none. The rotator starts at
+// 0 degrees, not synced.
+func New(dev alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*RotatorSimulator, error) {
+	key := dev.Key
+	if key == "" {
+		key = rotatorConfigKey
+	}
+
+	store, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %v", err)
+	}
+
+	config, err := store.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rotator config: %v", err)
+	}
+
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(rotatorUID, dev.Key)
+	}
+
+	return &RotatorSimulator{
+		logger: logger,
+		tmpl:   tmpl,
+		store:  store,
+		config: config,
+
+		info: alpaca.DeviceInfo{
+			Name:     deviceName,
+			Type:     deviceType,
+			Number:   dev.Number,
+			UniqueID: uid,
+		},
+		driver: alpaca.DriverInfo{
+			Name:             driverName,
+			Version:          driverVersion,
+			InterfaceVersion: 4,
+		},
+	}, nil
+}
+
+// Shutdown only logs, as the angle of a move is computed from its start time
+// when it is read.
+func (rs *RotatorSimulator) Shutdown(ctx context.Context) error {
+	rs.logger.Info("Shutting down rotator simulator")
+	return nil
+}
+
+func (rs *RotatorSimulator) DeviceInfo() alpaca.DeviceInfo {
+	info := rs.info
+
+	format := rs.getConfig().Description
+	if format == "" {
+		format = defaultDescription
+	}
+	info.Description = alpaca.ExpandDescription(format, map[string]string{
+		"driver": driverVersion,
+	})
+	return info
+}
+
+func (rs *RotatorSimulator) DriverInfo() alpaca.DriverInfo {
+	return rs.driver
+}
+
+// Disabled reports whether the rotator is switched off in its settings.
+func (rs *RotatorSimulator) Disabled() bool {
+	return rs.getConfig().Disabled
+}
+
+func (rs *RotatorSimulator) GetState() []alpaca.StateProperty {
+	props := []alpaca.StateProperty{
+		alpaca.StateTimeStamp(time.Now()),
+	}
+
+	if rs.connected.Load() {
+		props = append(props, rs.Status().ToProperties()...)
+	}
+
+	return props
+}
+
+func (rs *RotatorSimulator) Connect() error {
+	if !rs.connected.Swap(true) {
+		rs.logger.Infof("%s connected", rs.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventConnected,
+			Device:  rs.info.Name,
+			Message: "Simulator connected",
+		})
+	}
+	return nil
+}
+
+func (rs *RotatorSimulator) Disconnect() error {
+	if rs.connected.Swap(false) {
+		rs.logger.Infof("%s disconnected", rs.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventDisconnected,
+			Device:  rs.info.Name,
+			Message: "Simulator disconnected",
+		})
+	}
+	return nil
+}
+
+func (rs *RotatorSimulator) Connected() bool {
+	return rs.connected.Load()
+}
+
+func (rs *RotatorSimulator) Connecting() bool {
+	return false
+}
+
+func (rs *RotatorSimulator) getConfig() Config {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.config
+}
+
+func (rs *RotatorSimulator) Capabilities() alpaca.RotatorCapabilities {
+	return alpaca.RotatorCapabilities{
+		CanReverse: true,
+		StepSize:   rs.getConfig().StepSize,
+	}
+}
+
+// normalize returns an angle from 0 to 360 degrees.
+func normalize(degrees float64) float64 {
+	degrees = math.Mod(degrees, 360)
+	if degrees < 0 {
+		degrees += 360
+	}
+	return degrees
+}
+
+// current returns the mechanical position at a time, in degrees.
+func (rs *RotatorSimulator) current(now time.Time) float64 {
+	m := rs.move
+	if m == nil {
+		return rs.mechanical
+	}
+	frac := 1.0
+	if m.duration > 0 {
+		frac = math.Min(1, now.Sub(m.start).Seconds()/m.duration.Seconds())
+	}
+	return m.from + frac*(m.to-m.from)
+}
+
+// settle ends a finished move.
+func (rs *RotatorSimulator) settle(now time.Time) {
+	if m := rs.move; m != nil && !now.Before(m.start.Add(m.duration)) {
+		rs.mechanical, rs.move = m.to, nil
+	}
+}
+
+// sky returns the sky position of a mechanical position.
+func (rs *RotatorSimulator) sky(mechanical float64) float64 {
+	if rs.reverse {
+		return normalize(rs.offset - mechanical)
+	}
+	return normalize(rs.offset + mechanical)
+}
+
+// mechanicalOf returns the mechanical position of a sky position.
+func (rs *RotatorSimulator) mechanicalOf(sky float64) float64 {
+	if rs.reverse {
+		return normalize(rs.offset - sky)
+	}
+	return normalize(sky - rs.offset)
+}
+
+func (rs *RotatorSimulator) Status() alpaca.RotatorStatus {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	rs.settle(now)
+	mechanical := rs.current(now)
+
+	return alpaca.RotatorStatus{
+		Position:           rs.sky(mechanical),
+		MechanicalPosition: mechanical,
+		TargetPosition:     rs.target,
+		IsMoving:           rs.move != nil,
+		Reverse:            rs.reverse,
+	}
+}
+
+// SetReverse changes the direction of the sky positions, keeping the current
+// one where it is.
+func (rs *RotatorSimulator) SetReverse(on bool) error {
+	if !rs.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	rs.settle(now)
+	mechanical := rs.current(now)
+	position := rs.sky(mechanical)
+	rs.reverse = on
+	if on {
+		rs.offset = normalize(position + mechanical)
+	} else {
+		rs.offset = normalize(position - mechanical)
+	}
+	if rs.move != nil {
+		rs.target = rs.sky(rs.move.to)
+	}
+	return nil
+}
+
+// Move turns by an offset from the current sky position.
+func (rs *RotatorSimulator) Move(offset float64) error {
+	if !rs.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	rs.settle(now)
+	return rs.moveSky(rs.sky(rs.current(now)) + offset)
+}
+
+func (rs *RotatorSimulator) MoveAbsolute(position float64) error {
+	if !rs.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return rs.moveSky(position)
+}
+
+// moveSky starts a move to a sky position.
+func (rs *RotatorSimulator) moveSky(position float64) error {
+	position = normalize(position)
+	rs.moveTo(rs.mechanicalOf(position))
+	rs.target = position
+	return nil
+}
+
+func (rs *RotatorSimulator) MoveMechanical(position float64) error {
+	if !rs.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	if position < 0 || position >= 360 {
+		return errors.Errorf(errors.ErrInvalidValue, "position %g out of range 0 to 360 degrees", position)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	rs.moveTo(position)
+	rs.target = rs.sky(position)
+	return nil
+}
+
+// moveTo starts a move to a mechanical position at the configured speed.
+func (rs *RotatorSimulator) moveTo(position float64) {
+	now := time.Now()
+	rs.settle(now)
+	from := rs.current(now)
+	rs.move = &move{
+		from:     from,
+		to:       position,
+		start:    now,
+		duration: time.Duration(math.Abs(position-from) / rs.config.Speed * float64(time.Second)),
+	}
+	rs.logger.Infof("Moving from %.2f to %.2f mechanical degrees", from, position)
+}
+
+// Sync makes the current position the given sky position.
+func (rs *RotatorSimulator) Sync(position float64) error {
+	if !rs.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	if position < 0 || position >= 360 {
+		return errors.Errorf(errors.ErrInvalidValue, "position %g out of range 0 to 360 degrees", position)
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	rs.settle(now)
+	shift := position - rs.sky(rs.current(now))
+	rs.offset = normalize(rs.offset + shift)
+	rs.target = normalize(rs.target + shift)
+	rs.logger.Infof("Synced to %.2f degrees", position)
+	return nil
+}
+
+// Halt stops a move where it is.
+func (rs *RotatorSimulator) Halt() error {
+	if !rs.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	rs.settle(now)
+	if rs.move != nil {
+		rs.mechanical, rs.move = rs.current(now), nil
+		rs.target = rs.sky(rs.mechanical)
+		rs.logger.Infof("Halted at %.2f mechanical degrees", rs.mechanical)
+	}
+	return nil
+}
+
+func (rs *RotatorSimulator) HandleSetup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := rs.store.GetConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rs.renderSetupForm(w, cfg, false, "")
+
+	case http.MethodPost:
+		cfg, err := parseRotatorSetupForm(r)
+		if err != nil {
+			rs.renderSetupForm(w, cfg, false, err.Error())
+			return
+		}
+
+		rs.logger.Infof("Setting rotator config: %+v", alpaca.Redact(cfg))
+		rs.mu.Lock()
+		rs.config = cfg
+		rs.mu.Unlock()
+		if err := rs.store.SetConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rs.renderSetupForm(w, cfg, true, "")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (rs *RotatorSimulator) renderSetupForm(w http.ResponseWriter, cfg Config, success bool, err string) {
+	data := struct {
+		Config
+		Status    alpaca.RotatorStatus
+		Connected bool
+		Success   bool
+		Error     string
+	}{cfg, rs.Status(), rs.connected.Load(), success, err}
+
+	if err := rs.tmpl.ExecuteTemplate(w, "rotator_simulator_setup.html", data); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		rs.logger.Errorf("Error rendering template: %v", err)
+	}
+}
+
+func parseRotatorSetupForm(r *http.Request) (Config, error) {
+	var cfg Config
+	if err := r.ParseForm(); err != nil {
+		return cfg, fmt.Errorf("error parsing form: %v", err)
+	}
+
+	var err error
+	if cfg.Speed, err = strconv.ParseFloat(r.FormValue("speed"), 64); err != nil || cfg.Speed <= 0 {
+		return cfg, fmt.Errorf("invalid speed: must be a positive number of degrees per second")
+	}
+	if cfg.StepSize, err = strconv.ParseFloat(r.FormValue("step-size"), 64); err != nil || cfg.StepSize < 0 {
+		return cfg, fmt.Errorf("invalid step size: must be a number of degrees, 0 if unknown")
+	}
+	cfg.Description = strings.TrimSpace(r.FormValue("description"))
+	cfg.Disabled = r.FormValue("enabled") != "true"
+	return cfg, nil
+}
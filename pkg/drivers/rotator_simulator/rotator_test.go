@@ -0,0 +1,103 @@
+package rotator_simulator
+
+import (
+	"alpaca/internal/simtest"
+	"alpaca/pkg/alpaca/errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// finish ends the move in progress.
+func finish(rs *RotatorSimulator) {
+	rs.mu.Lock()
+	if rs.move != nil {
+		rs.move.start = rs.move.start.Add(-time.Hour)
+	}
+	rs.mu.Unlock()
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, 10.0, normalize(370))
+	assert.Equal(t, 350.0, normalize(-10))
+	assert.Equal(t, 0.0, normalize(360))
+}
+
+func TestMove(t *testing.T) {
+	rs := simtest.Connected(t, New)
+
+	require.NoError(t, rs.MoveAbsolute(90))
+	st := rs.Status()
+	assert.True(t, st.IsMoving)
+	assert.Equal(t, 90.0, st.TargetPosition)
+
+	// Half way through the 18 seconds of the move.
+	rs.mu.Lock()
+	rs.move.start = rs.move.start.Add(-9 * time.Second)
+	rs.mu.Unlock()
+	require.NoError(t, rs.Halt())
+	st = rs.Status()
+	assert.False(t, st.IsMoving)
+	assert.InDelta(t, 45, st.Position, 0.5)
+	assert.Equal(t, st.Position, st.TargetPosition, "the target of a halted move is where it stopped")
+
+	require.NoError(t, rs.Move(-60))
+	finish(rs)
+	st = rs.Status()
+	assert.InDelta(t, 345, st.Position, 0.5, "offsets wrap around")
+	assert.InDelta(t, 345, st.MechanicalPosition, 0.5, "the rotator turns the long way rather than past 0")
+
+	require.NoError(t, rs.Disconnect())
+	assert.ErrorIs(t, rs.Move(10), errors.ErrNotConnected)
+}
+
+func TestSyncAndReverse(t *testing.T) {
+	rs := simtest.Connected(t, New)
+
+	require.NoError(t, rs.MoveMechanical(100))
+	finish(rs)
+	require.NoError(t, rs.Sync(30))
+	st := rs.Status()
+	assert.Equal(t, 30.0, st.Position)
+	assert.Equal(t, 100.0, st.MechanicalPosition)
+
+	require.NoError(t, rs.MoveAbsolute(50))
+	finish(rs)
+	assert.Equal(t, 120.0, rs.Status().MechanicalPosition)
+
+	require.NoError(t, rs.SetReverse(true))
+	st = rs.Status()
+	assert.Equal(t, 50.0, st.Position, "reversing keeps the position")
+	assert.True(t, st.Reverse)
+
+	require.NoError(t, rs.MoveAbsolute(60))
+	finish(rs)
+	assert.Equal(t, 110.0, rs.Status().MechanicalPosition, "reversed, a larger angle turns backwards")
+
+	assert.ErrorIs(t, rs.MoveMechanical(360), errors.ErrInvalidValue)
+}
+
+func TestParseRotatorSetupForm(t *testing.T) {
+	form := url.Values{
+		"speed":     {"2.5"},
+		"step-size": {"0.1"},
+		"enabled":   {"true"},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	cfg, err := parseRotatorSetupForm(req)
+	require.NoError(t, err)
+	assert.Equal(t, Config{Speed: 2.5, StepSize: 0.1}, cfg)
+
+	form.Set("speed", "0")
+	req = httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	_, err = parseRotatorSetupForm(req)
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package rotator_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultDescription = "Rotator simulator {driver} @ {host}"
+
+	rotatorConfigKey = "rotator_config"
+)
+
+// Config holds the speed and the resolution of the simulated rotator.
+type Config struct {
+	Speed    float64 `json:"speed"`     // degrees per second
+	StepSize float64 `json:"step_size"` // degrees per step, 0 if unknown
+
+	Description string `json:"description"` // device description, with {driver} and {host} placeholders
+
+	Disabled bool `json:"disabled"` // hidden from the configured devices, e.g. in production
+}
+
+type store = alpaca.DriverStore[Config]
+
+// NewStoreWithKey creates a store for the configuration saved under key,
+// which defaults to a disabled rotator turning at 5 degrees per second, by
+// steps of a hundredth of a degree.
+func NewStoreWithKey(db *bolt.DB, key string) (*store, error) {
+	return alpaca.NewDriverStore(db, key, Config{
+		Speed:       5,
+		StepSize:    0.01,
+		Description: defaultDescription,
+		Disabled:    true,
+	})
+}
+
+// ApplySettings merges the settings of a device file into the rotator simulator
+// configuration saved under key, the default key if empty, and saves it if
+// they changed it.
+func ApplySettings(db *bolt.DB, key string, settings json.RawMessage) error {
+	if key == "" {
+		key = rotatorConfigKey
+	}
+
+	st, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return err
+	}
+	return st.ApplySettings(settings)
+}
@@ -0,0 +1,69 @@
+package telescope_simulator
+
+import (
+	"math"
+	"time"
+)
+
+// siderealRate is the number of sidereal seconds in a second.
+const siderealRate = 1.00273790935
+
+// siderealTime returns the local mean sidereal time, in hours, at a
+// longitude in degrees east.
+func siderealTime(t time.Time, longitude float64) float64 {
+	days := float64(t.UnixNano())/float64(24*time.Hour) - 10957.5 // Since J2000.0
+	return normalizeHours(18.697374558 + 24.06570982441908*days + longitude/15)
+}
+
+// horizontal converts equatorial coordinates, in hours and degrees, to an
+// azimuth and an altitude in degrees, at a local sidereal time in hours and
+// a latitude in degrees.
+func horizontal(ra, dec, lst, latitude float64) (float64, float64) {
+	h := rad((lst - ra) * 15)
+	d, lat := rad(dec), rad(latitude)
+
+	alt := math.Asin(math.Sin(d)*math.Sin(lat) + math.Cos(d)*math.Cos(lat)*math.Cos(h))
+	az := math.Atan2(-math.Sin(h)*math.Cos(d), math.Sin(d)*math.Cos(lat)-math.Cos(d)*math.Sin(lat)*math.Cos(h))
+	return normalizeDegrees(deg(az)), deg(alt)
+}
+
+// equatorial converts an azimuth and an altitude, in degrees, to a right
+// ascension in hours and a declination in degrees.
+func equatorial(az, alt, lst, latitude float64) (float64, float64) {
+	a, e, lat := rad(az), rad(alt), rad(latitude)
+
+	dec := math.Asin(math.Sin(e)*math.Sin(lat) + math.Cos(e)*math.Cos(lat)*math.Cos(a))
+	h := math.Atan2(-math.Sin(a)*math.Cos(e), math.Sin(e)*math.Cos(lat)-math.Cos(e)*math.Sin(lat)*math.Cos(a))
+	return normalizeHours(lst - deg(h)/15), deg(dec)
+}
+
+// hourAngle returns the hour angle of a right ascension, from -12 to 12
+// hours, positive west of the meridian.
+func hourAngle(ra, lst float64) float64 {
+	return normalizeHours(lst-ra+12) - 12
+}
+
+// hoursBetween returns the shortest difference from one right ascension to
+// another, from -12 to 12 hours.
+func hoursBetween(from, to float64) float64 {
+	return normalizeHours(to-from+12) - 12
+}
+
+func normalizeHours(h float64) float64 {
+	h = math.Mod(h, 24)
+	if h < 0 {
+		h += 24
+	}
+	return h
+}
+
+func normalizeDegrees(d float64) float64 {
+	d = math.Mod(d, 360)
+	if d < 0 {
+		d += 360
+	}
+	return d
+}
+
+func rad(d float64) float64 { return d * math.Pi / 180 }
+func deg(r float64) float64 { return r * 180 / math.Pi }
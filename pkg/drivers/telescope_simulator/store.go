@@ -0,0 +1,63 @@
+package telescope_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultSlewRate    = 4 // degrees per second
+	defaultDescription = "Telescope simulator {driver} @ {host}"
+
+	telescopeConfigKey = "telescope_config"
+)
+
+// Config holds the site of the simulated mount and its park position.
+type Config struct {
+	Latitude  float64 `json:"latitude"`  // degrees, positive north
+	Longitude float64 `json:"longitude"` // degrees, positive east
+	Elevation float64 `json:"elevation"` // meters
+	SlewRate  float64 `json:"slew_rate"` // degrees per second on each axis
+
+	ParkAzimuth  float64 `json:"park_azimuth"`  // degrees
+	ParkAltitude float64 `json:"park_altitude"` // degrees
+
+	Description string `json:"description"` // device description, with {driver} and {host} placeholders
+
+	Disabled bool `json:"disabled"` // hidden from the configured devices, e.g. in production
+}
+
+type store = alpaca.DriverStore[Config]
+
+// NewStoreWithKey creates a store for the configuration saved under key,
+// which defaults to a disabled mount at 40 degrees north, parked pointing at
+// the pole.
+func NewStoreWithKey(db *bolt.DB, key string) (*store, error) {
+	return alpaca.NewDriverStore(db, key, Config{
+		Latitude:     40,
+		Longitude:    0,
+		Elevation:    0,
+		SlewRate:     defaultSlewRate,
+		ParkAzimuth:  0,
+		ParkAltitude: 40,
+		Description:  defaultDescription,
+		Disabled:     true,
+	})
+}
+
+// ApplySettings merges the settings of a device file into the telescope simulator
+// configuration saved under key, the default key if empty, and saves it if
+// they changed it.
+func ApplySettings(db *bolt.DB, key string, settings json.RawMessage) error {
+	if key == "" {
+		key = telescopeConfigKey
+	}
+
+	st, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return err
+	}
+	return st.ApplySettings(settings)
+}
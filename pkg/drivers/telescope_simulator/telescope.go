@@ -0,0 +1,732 @@
+// Package telescope_simulator simulates a German equatorial mount, so the
+// Telescope API can be exercised and a dome can be slaved to a telescope
+// without a real mount.
+package telescope_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	telescopeUID  = "9d3b6f2e-71a4-4c58-b0e9-5a8c2d4f6e13"
+	deviceName    = "Telescope Simulator"
+	deviceType    = "Telescope"
+	driverName    = "ZRO Telescope Simulator"
+	driverVersion = "1.0"
+)
+
+// slew is a slew in progress, interpolated from its start to its target.
+type slew struct {
+	fromRA, fromDec float64 // Hours and degrees
+	toRA, toDec     float64
+	start           time.Time
+	duration        time.Duration // Of the motion
+	settle          time.Duration // Added to the motion while slewing
+}
+
+// TelescopeSimulator implements the alpaca.Telescope interface.
+type TelescopeSimulator struct {
+	logger log.FieldLogger
+	tmpl   *template.Template
+	store  *store
+	config Config
+
+	info   alpaca.DeviceInfo
+	driver alpaca.DriverInfo
+
+	connected atomic.Bool
+
+	mu           sync.Mutex
+	ra, dec      float64   // Position at the time at, in hours and degrees
+	at           time.Time // Time of the position
+	slew         *slew     // Slew in progress, if any
+	axisRates    [2]float64
+	tracking     bool
+	trackingRate alpaca.DriveRate
+	parked       bool
+	home         bool          // True after a home search, until the next motion
+	guideUntil   time.Time     // End of the pulse guide in progress
+	clockOffset  time.Duration // Offset of the simulated clock set with UTCDate
+	settings     alpaca.TelescopeSettings
+	target       alpaca.TelescopeTarget
+}
+
+// New creates a telescope simulator for a device instance, with the settings
+// saved under its key, telescope_config when it has none. The mount starts
+// parked.
+func New(dev alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*TelescopeSimulator, error) {
+	key := dev.Key
+	if key == "" {
+		key = telescopeConfigKey
+	}
+
+	store, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %v", err)
+	}
+
+	config, err := store.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get telescope config: %v", err)
+	}
+
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(telescopeUID, dev.Key)
+	}
+
+	t := &TelescopeSimulator{
+		logger: logger,
+		tmpl:   tmpl,
+		store:  store,
+		config: config,
+
+		info: alpaca.DeviceInfo{
+			Name:     deviceName,
+			Type:     deviceType,
+			Number:   dev.Number,
+			UniqueID: uid,
+		},
+		driver: alpaca.DriverInfo{
+			Name:             driverName,
+			Version:          driverVersion,
+			InterfaceVersion: 4,
+		},
+		parked: true,
+		settings: alpaca.TelescopeSettings{
+			SiteElevation:           config.Elevation,
+			SiteLatitude:            config.Latitude,
+			SiteLongitude:           config.Longitude,
+			GuideRateRightAscension: 0.5 * 15 / 3600,
+			GuideRateDeclination:    0.5 * 15 / 3600,
+		},
+	}
+
+	now := time.Now()
+	t.ra, t.dec = equatorial(config.ParkAzimuth, config.ParkAltitude, siderealTime(now, config.Longitude), config.Latitude)
+	t.at = now
+	return t, nil
+}
+
+// Shutdown only logs: the simulated mount tracks the sky from the clock and
+// runs nothing in the background.
+func (t *TelescopeSimulator) Shutdown(ctx context.Context) error {
+	t.logger.Info("Shutting down telescope simulator")
+	return nil
+}
+
+func (t *TelescopeSimulator) DeviceInfo() alpaca.DeviceInfo {
+	info := t.info
+
+	format := t.config.Description
+	if format == "" {
+		format = defaultDescription
+	}
+	info.Description = alpaca.ExpandDescription(format, map[string]string{
+		"driver": driverVersion,
+	})
+	return info
+}
+
+func (t *TelescopeSimulator) DriverInfo() alpaca.DriverInfo {
+	return t.driver
+}
+
+// Disabled reports whether the mount is left out of the served devices.
+func (t *TelescopeSimulator) Disabled() bool {
+	return t.config.Disabled
+}
+
+func (t *TelescopeSimulator) GetState() []alpaca.StateProperty {
+	props := []alpaca.StateProperty{
+		alpaca.StateTimeStamp(time.Now()),
+	}
+
+	if t.connected.Load() {
+		props = append(props, t.Status().ToProperties()...)
+	}
+
+	return props
+}
+
+func (t *TelescopeSimulator) Connect() error {
+	if !t.connected.Swap(true) {
+		t.logger.Infof("%s connected", t.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventConnected,
+			Device:  t.info.Name,
+			Message: "Simulator connected",
+		})
+	}
+	return nil
+}
+
+func (t *TelescopeSimulator) Disconnect() error {
+	if t.connected.Swap(false) {
+		t.logger.Infof("%s disconnected", t.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventDisconnected,
+			Device:  t.info.Name,
+			Message: "Simulator disconnected",
+		})
+	}
+	return nil
+}
+
+func (t *TelescopeSimulator) Connected() bool {
+	return t.connected.Load()
+}
+
+func (t *TelescopeSimulator) Connecting() bool {
+	return false
+}
+
+func (t *TelescopeSimulator) Capabilities() alpaca.TelescopeCapabilities {
+	rates := []alpaca.AxisRate{{Minimum: 0, Maximum: t.config.SlewRate}}
+	return alpaca.TelescopeCapabilities{
+		AlignmentMode:    alpaca.AlignmentGermanPolar,
+		EquatorialSystem: alpaca.EquatorialTopocentric,
+		ApertureArea:     math.Pi * 0.1 * 0.1,
+		ApertureDiameter: 0.2,
+		FocalLength:      1,
+
+		CanFindHome:              true,
+		CanPark:                  true,
+		CanPulseGuide:            true,
+		CanSetDeclinationRate:    true,
+		CanSetGuideRates:         true,
+		CanSetPark:               true,
+		CanSetRightAscensionRate: true,
+		CanSetTracking:           true,
+		CanSlew:                  true,
+		CanSlewAltAz:             true,
+		CanSlewAltAzAsync:        true,
+		CanSlewAsync:             true,
+		CanSync:                  true,
+		CanSyncAltAz:             true,
+		CanUnpark:                true,
+
+		AxisRates:     [3][]alpaca.AxisRate{rates, rates, nil},
+		TrackingRates: []alpaca.DriveRate{alpaca.DriveSidereal, alpaca.DriveLunar, alpaca.DriveSolar, alpaca.DriveKing},
+	}
+}
+
+// now returns the time of the simulated clock.
+func (t *TelescopeSimulator) now() time.Time {
+	return time.Now().Add(t.clockOffset)
+}
+
+// lst returns the local sidereal time at a time of the simulated clock.
+func (t *TelescopeSimulator) lst(now time.Time) float64 {
+	return siderealTime(now, t.settings.SiteLongitude)
+}
+
+// position returns the right ascension and the declination at a time. A
+// mount not tracking keeps its hour angle, so its right ascension follows
+// the sidereal time; the tracking rates other than sidereal are simulated as
+// sidereal.
+func (t *TelescopeSimulator) position(now time.Time) (float64, float64) {
+	if s := t.slew; s != nil {
+		f := 1.0
+		if s.duration > 0 {
+			f = math.Min(1, now.Sub(s.start).Seconds()/s.duration.Seconds())
+		}
+		return normalizeHours(s.fromRA + f*hoursBetween(s.fromRA, s.toRA)), s.fromDec + f*(s.toDec-s.fromDec)
+	}
+
+	dt := now.Sub(t.at).Seconds()
+	ra, dec := t.ra, t.dec
+	if t.tracking {
+		ra += t.settings.RightAscensionRate * dt * siderealRate / 3600
+		dec += t.settings.DeclinationRate * dt / 3600
+	} else {
+		ra += dt * siderealRate / 3600
+	}
+	ra -= t.axisRates[alpaca.AxisPrimary] * dt / 15
+	dec += t.axisRates[alpaca.AxisSecondary] * dt
+	return normalizeHours(ra), math.Max(-90, math.Min(90, dec))
+}
+
+// settle moves the position to a time, ending the slew once over.
+func (t *TelescopeSimulator) settle(now time.Time) {
+	if s := t.slew; s != nil {
+		end := s.start.Add(s.duration)
+		if now.Before(end) {
+			return
+		}
+		t.ra, t.dec, t.at = s.toRA, s.toDec, end
+		if now.Before(end.Add(s.settle)) {
+			return
+		}
+		t.slew = nil
+	}
+	t.ra, t.dec = t.position(now)
+	t.at = now
+}
+
+// slewing reports whether a slew or a moving axis is in progress.
+func (t *TelescopeSimulator) slewing(now time.Time) bool {
+	if s := t.slew; s != nil && now.Before(s.start.Add(s.duration+s.settle)) {
+		return true
+	}
+	return t.axisRates != [2]float64{}
+}
+
+// sideOfPier returns the pointing state of a German mount at an hour angle:
+// east of the pier, looking west, past the meridian.
+func sideOfPier(ha float64) alpaca.PierSide {
+	if ha >= 0 {
+		return alpaca.PierEast
+	}
+	return alpaca.PierWest
+}
+
+func (t *TelescopeSimulator) Status() alpaca.TelescopeStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.settle(now)
+	ra, dec := t.position(now)
+	lst := t.lst(now)
+	az, alt := horizontal(ra, dec, lst, t.settings.SiteLatitude)
+	slewing := t.slewing(now)
+
+	return alpaca.TelescopeStatus{
+		Altitude:       alt,
+		Azimuth:        az,
+		RightAscension: ra,
+		Declination:    dec,
+		SiderealTime:   lst,
+		AtHome:         t.home && !slewing,
+		AtPark:         t.parked && !slewing,
+		Slewing:        slewing,
+		Tracking:       t.tracking,
+		IsPulseGuiding: now.Before(t.guideUntil),
+		SideOfPier:     sideOfPier(hourAngle(ra, lst)),
+		TrackingRate:   t.trackingRate,
+		UTCDate:        now,
+	}
+}
+
+func (t *TelescopeSimulator) Settings() alpaca.TelescopeSettings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.settings
+}
+
+// SetSettings applies the settings; the site is saved with the configuration.
+func (t *TelescopeSimulator) SetSettings(s alpaca.TelescopeSettings) error {
+	if !t.connected.Load() {
+		return errors.ErrNotConnected
+	}
+
+	t.mu.Lock()
+	t.settle(t.now())
+	t.settings = s
+	t.mu.Unlock()
+
+	if s.SiteLatitude == t.config.Latitude && s.SiteLongitude == t.config.Longitude && s.SiteElevation == t.config.Elevation {
+		return nil
+	}
+	t.logger.Infof("Site set to %.4f, %.4f, %.0f m", s.SiteLatitude, s.SiteLongitude, s.SiteElevation)
+	t.config.Latitude, t.config.Longitude, t.config.Elevation = s.SiteLatitude, s.SiteLongitude, s.SiteElevation
+	return t.store.SetConfig(t.config)
+}
+
+func (t *TelescopeSimulator) Target() alpaca.TelescopeTarget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.target
+}
+
+func (t *TelescopeSimulator) SetTarget(target alpaca.TelescopeTarget) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.target = target
+	return nil
+}
+
+// checkReady returns an error while disconnected or parked.
+func (t *TelescopeSimulator) checkReady() error {
+	if !t.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	if t.parked {
+		return errors.ErrParked
+	}
+	return nil
+}
+
+func (t *TelescopeSimulator) SetTracking(tracking bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	if tracking && t.parked {
+		return errors.ErrParked
+	}
+	t.logger.Infof("Tracking: %v", tracking)
+	t.settle(t.now())
+	t.tracking = tracking
+	return nil
+}
+
+func (t *TelescopeSimulator) SetTrackingRate(rate alpaca.DriveRate) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	t.trackingRate = rate
+	return nil
+}
+
+// SetSideOfPier is not implemented: the simulated mount flips by itself.
+func (t *TelescopeSimulator) SetSideOfPier(side alpaca.PierSide) error {
+	return errors.ErrNotImplemented
+}
+
+// SetUTCDate sets the simulated clock.
+func (t *TelescopeSimulator) SetUTCDate(date time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	t.settle(t.now())
+	t.clockOffset = time.Until(date)
+	t.at = t.now()
+	return nil
+}
+
+func (t *TelescopeSimulator) DestinationSideOfPier(ra, dec float64) (alpaca.PierSide, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.connected.Load() {
+		return alpaca.PierUnknown, errors.ErrNotConnected
+	}
+	return sideOfPier(hourAngle(ra, t.lst(t.now()))), nil
+}
+
+// startSlew starts a slew from the current position, at the slew rate on
+// both axes.
+func (t *TelescopeSimulator) startSlew(ra, dec float64) {
+	now := t.now()
+	t.settle(now)
+	t.slew = nil
+	fromRA, fromDec := t.position(now)
+
+	distance := math.Max(math.Abs(hoursBetween(fromRA, ra))*15, math.Abs(dec-fromDec))
+	duration := time.Duration(distance / t.config.SlewRate * float64(time.Second))
+	t.slew = &slew{
+		fromRA: fromRA, fromDec: fromDec,
+		toRA: ra, toDec: dec,
+		start:    now,
+		duration: duration,
+		settle:   time.Duration(t.settings.SlewSettleTime) * time.Second,
+	}
+	t.ra, t.dec, t.at = fromRA, fromDec, now
+	t.axisRates = [2]float64{}
+	t.home = false
+}
+
+func (t *TelescopeSimulator) SlewToCoordinates(ra, dec float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkReady(); err != nil {
+		return err
+	}
+	t.logger.Infof("Slewing to RA %.4f h, Dec %.4f", ra, dec)
+	t.startSlew(ra, dec)
+	return nil
+}
+
+func (t *TelescopeSimulator) SlewToAltAz(azimuth, altitude float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkReady(); err != nil {
+		return err
+	}
+	t.logger.Infof("Slewing to azimuth %.2f, altitude %.2f", azimuth, altitude)
+	t.startSlew(equatorial(azimuth, altitude, t.lst(t.now()), t.settings.SiteLatitude))
+	return nil
+}
+
+func (t *TelescopeSimulator) SyncToCoordinates(ra, dec float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkReady(); err != nil {
+		return err
+	}
+	if t.slewing(t.now()) {
+		return errors.Errorf(errors.ErrInvalidOperation, "cannot sync while slewing")
+	}
+	t.logger.Infof("Syncing to RA %.4f h, Dec %.4f", ra, dec)
+	t.ra, t.dec, t.at = ra, dec, t.now()
+	t.slew = nil
+	return nil
+}
+
+func (t *TelescopeSimulator) SyncToAltAz(azimuth, altitude float64) error {
+	t.mu.Lock()
+	ra, dec := equatorial(azimuth, altitude, t.lst(t.now()), t.settings.SiteLatitude)
+	t.mu.Unlock()
+
+	return t.SyncToCoordinates(ra, dec)
+}
+
+// MoveAxis moves the right ascension or the declination axis, in degrees
+// per second; a zero rate stops it.
+func (t *TelescopeSimulator) MoveAxis(axis alpaca.TelescopeAxis, rate float64) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkReady(); err != nil {
+		return err
+	}
+	if axis > alpaca.AxisSecondary {
+		return errors.ErrNotImplemented
+	}
+	now := t.now()
+	t.settle(now)
+	t.slew = nil
+	t.axisRates[axis] = rate
+	t.home = false
+	return nil
+}
+
+// PulseGuide moves the mount at the guide rate for the duration. The
+// correction is applied at once, and IsPulseGuiding stays true until the end
+// of the pulse.
+func (t *TelescopeSimulator) PulseGuide(direction alpaca.GuideDirection, duration time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkReady(); err != nil {
+		return err
+	}
+	if t.slewing(t.now()) {
+		return errors.Errorf(errors.ErrInvalidOperation, "cannot guide while slewing")
+	}
+
+	now := t.now()
+	t.settle(now)
+	seconds := duration.Seconds()
+	switch direction {
+	case alpaca.GuideNorth:
+		t.dec = math.Min(90, t.dec+t.settings.GuideRateDeclination*seconds)
+	case alpaca.GuideSouth:
+		t.dec = math.Max(-90, t.dec-t.settings.GuideRateDeclination*seconds)
+	case alpaca.GuideEast:
+		t.ra = normalizeHours(t.ra + t.settings.GuideRateRightAscension*seconds/15)
+	case alpaca.GuideWest:
+		t.ra = normalizeHours(t.ra - t.settings.GuideRateRightAscension*seconds/15)
+	}
+	t.guideUntil = now.Add(duration)
+	return nil
+}
+
+func (t *TelescopeSimulator) AbortSlew() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkReady(); err != nil {
+		return err
+	}
+	t.logger.Info("Aborting slew")
+	now := t.now()
+	t.settle(now)
+	if t.slew != nil {
+		t.ra, t.dec = t.position(now)
+		t.at = now
+		t.slew = nil
+	}
+	t.axisRates = [2]float64{}
+	return nil
+}
+
+// FindHome slews to the home position, pointing at the pole with the
+// counterweight down.
+func (t *TelescopeSimulator) FindHome() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.checkReady(); err != nil {
+		return err
+	}
+	t.logger.Info("Finding home")
+	t.tracking = false
+	t.startSlew(t.homePosition())
+	t.home = true
+	return nil
+}
+
+// homePosition returns the right ascension and the declination of the pole.
+func (t *TelescopeSimulator) homePosition() (float64, float64) {
+	lst := t.lst(t.now())
+	if t.settings.SiteLatitude < 0 {
+		return lst, -90
+	}
+	return lst, 90
+}
+
+// Park slews to the park position and stops tracking.
+func (t *TelescopeSimulator) Park() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	if t.parked {
+		return nil
+	}
+	t.logger.Info("Parking")
+	t.tracking = false
+	t.startSlew(equatorial(t.config.ParkAzimuth, t.config.ParkAltitude, t.lst(t.now()), t.settings.SiteLatitude))
+	t.parked = true
+	return nil
+}
+
+func (t *TelescopeSimulator) Unpark() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.connected.Load() {
+		return errors.ErrNotConnected
+	}
+	if t.parked {
+		t.logger.Info("Unparking")
+	}
+	t.parked = false
+	return nil
+}
+
+// SetPark saves the current position as the park position.
+func (t *TelescopeSimulator) SetPark() error {
+	t.mu.Lock()
+	if !t.connected.Load() {
+		t.mu.Unlock()
+		return errors.ErrNotConnected
+	}
+	now := t.now()
+	t.settle(now)
+	ra, dec := t.position(now)
+	az, alt := horizontal(ra, dec, t.lst(now), t.settings.SiteLatitude)
+	t.mu.Unlock()
+
+	t.logger.Infof("Setting park position to azimuth %.2f, altitude %.2f", az, alt)
+	t.config.ParkAzimuth, t.config.ParkAltitude = az, alt
+	return t.store.SetConfig(t.config)
+}
+
+func (t *TelescopeSimulator) HandleSetup(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := t.store.GetConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		t.renderSetupForm(w, cfg, false, "")
+
+	case http.MethodPost:
+		cfg, err := parseTelescopeSetupForm(r, t.config)
+		if err != nil {
+			t.renderSetupForm(w, cfg, false, err.Error())
+			return
+		}
+
+		t.logger.Infof("Setting telescope config: %+v", alpaca.Redact(cfg))
+		t.mu.Lock()
+		t.settle(t.now())
+		t.settings.SiteLatitude, t.settings.SiteLongitude, t.settings.SiteElevation = cfg.Latitude, cfg.Longitude, cfg.Elevation
+		t.mu.Unlock()
+		t.config = cfg
+		if err := t.store.SetConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		t.renderSetupForm(w, cfg, true, "")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (t *TelescopeSimulator) renderSetupForm(w http.ResponseWriter, cfg Config, success bool, err string) {
+	data := struct {
+		Config
+		Status    alpaca.TelescopeStatus
+		Connected bool
+		Success   bool
+		Error     string
+	}{cfg, t.Status(), t.connected.Load(), success, err}
+
+	if err := t.tmpl.ExecuteTemplate(w, "telescope_simulator_setup.html", data); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		t.logger.Errorf("Error rendering template: %v", err)
+	}
+}
+
+// parseTelescopeSetupForm reads the settings of the setup page; the park
+// position is kept from the current configuration, it is set with SetPark.
+func parseTelescopeSetupForm(r *http.Request, current Config) (Config, error) {
+	if err := r.ParseForm(); err != nil {
+		return current, fmt.Errorf("error parsing form: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, key := range []string{"latitude", "longitude", "elevation", "slew-rate"} {
+		value, err := strconv.ParseFloat(r.FormValue(key), 64)
+		if err != nil {
+			return current, fmt.Errorf("invalid %s: %v", key, err)
+		}
+		values[key] = value
+	}
+	if lat := values["latitude"]; lat < -90 || lat > 90 {
+		return current, fmt.Errorf("invalid latitude: must be between -90 and 90 degrees")
+	}
+	if lon := values["longitude"]; lon < -180 || lon > 180 {
+		return current, fmt.Errorf("invalid longitude: must be between -180 and 180 degrees")
+	}
+	if rate := values["slew-rate"]; rate <= 0 || rate > 20 {
+		return current, fmt.Errorf("invalid slew rate: must be between 0 and 20 degrees per second")
+	}
+
+	cfg := current
+	cfg.Latitude = values["latitude"]
+	cfg.Longitude = values["longitude"]
+	cfg.Elevation = values["elevation"]
+	cfg.SlewRate = values["slew-rate"]
+	cfg.Description = strings.TrimSpace(r.FormValue("description"))
+	cfg.Disabled = r.FormValue("enabled") != "true"
+	return cfg, nil
+}
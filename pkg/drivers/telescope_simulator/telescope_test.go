@@ -0,0 +1,117 @@
+package telescope_simulator
+
+import (
+	"alpaca/internal/simtest"
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiderealTime(t *testing.T) {
+	j2000 := time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+	assert.InDelta(t, 18.697374558, siderealTime(j2000, 0), 1e-6)
+	assert.InDelta(t, 18.697374558-1, siderealTime(j2000, -15), 1e-6, "an hour per 15 degrees west")
+	assert.InDelta(t, siderealTime(j2000, 0), siderealTime(j2000.Add(23*time.Hour+56*time.Minute+4091*time.Millisecond), 0), 1e-4, "a sidereal day later")
+}
+
+func TestHorizontal(t *testing.T) {
+	// On the meridian, the altitude is the colatitude plus the declination.
+	az, alt := horizontal(6, 20, 6, 40)
+	assert.InDelta(t, 180, az, 1e-9)
+	assert.InDelta(t, 70, alt, 1e-9)
+
+	// Below the pole, due north.
+	az, alt = horizontal(23, 60, 11, 40)
+	assert.InDelta(t, 10, alt, 1e-9)
+	assert.InDelta(t, 0, math.Mod(az+180, 360)-180, 1e-6)
+
+	for _, c := range [][2]float64{{1, 10}, {13, -30}, {22.5, 75}} {
+		az, alt := horizontal(c[0], c[1], 7.25, 40)
+		ra, dec := equatorial(az, alt, 7.25, 40)
+		assert.InDelta(t, c[0], ra, 1e-9)
+		assert.InDelta(t, c[1], dec, 1e-9)
+	}
+}
+
+func TestSideOfPier(t *testing.T) {
+	assert.Equal(t, alpaca.PierEast, sideOfPier(hourAngle(4, 6)), "past the meridian, looking west")
+	assert.Equal(t, alpaca.PierWest, sideOfPier(hourAngle(8, 6)))
+	assert.Equal(t, alpaca.PierWest, sideOfPier(hourAngle(1, 23)), "wraps around 0 hours")
+}
+
+func TestSlew(t *testing.T) {
+	s := simtest.Connected(t, New)
+
+	st := s.Status()
+	assert.True(t, st.AtPark, "starts parked")
+	assert.InDelta(t, s.config.ParkAltitude, st.Altitude, 1e-6)
+	assert.ErrorIs(t, s.SlewToCoordinates(1, 2), errors.ErrParked)
+
+	require.NoError(t, s.Unpark())
+	require.NoError(t, s.SetTracking(true))
+	lst := s.lst(s.now())
+	ra, dec := normalizeHours(lst-1), 30.0
+	require.NoError(t, s.SlewToCoordinates(ra, dec))
+	st = s.Status()
+	assert.True(t, st.Slewing)
+	assert.False(t, st.AtPark)
+	assert.Equal(t, alpaca.PierEast, st.SideOfPier)
+
+	// End the slew, then the mount tracks the target.
+	s.mu.Lock()
+	s.slew.start = s.slew.start.Add(-time.Hour)
+	s.mu.Unlock()
+	st = s.Status()
+	assert.False(t, st.Slewing)
+	assert.InDelta(t, ra, st.RightAscension, 1e-9)
+	assert.InDelta(t, dec, st.Declination, 1e-9)
+
+	// Without tracking, the mount keeps its hour angle.
+	require.NoError(t, s.SetTracking(false))
+	s.mu.Lock()
+	s.at = s.at.Add(-time.Hour)
+	s.mu.Unlock()
+	st = s.Status()
+	assert.InDelta(t, normalizeHours(ra+siderealRate), st.RightAscension, 1e-6)
+
+	require.NoError(t, s.MoveAxis(alpaca.AxisSecondary, 2))
+	assert.True(t, s.Status().Slewing)
+	require.NoError(t, s.AbortSlew())
+	assert.False(t, s.Status().Slewing)
+	assert.ErrorIs(t, s.MoveAxis(alpaca.AxisTertiary, 1), errors.ErrNotImplemented)
+
+	require.NoError(t, s.SyncToCoordinates(10, 10))
+	st = s.Status()
+	assert.InDelta(t, 10, st.RightAscension, 1e-6)
+
+	require.NoError(t, s.Park())
+	assert.True(t, s.Status().Slewing)
+	assert.False(t, s.Status().Tracking)
+	assert.ErrorIs(t, s.SetTracking(true), errors.ErrParked)
+
+	require.NoError(t, s.Disconnect())
+	assert.ErrorIs(t, s.Unpark(), errors.ErrNotConnected)
+}
+
+func TestSettings(t *testing.T) {
+	s := simtest.Connected(t, New)
+
+	settings := s.Settings()
+	settings.SiteLatitude = -33.5
+	settings.SlewSettleTime = 2
+	require.NoError(t, s.SetSettings(settings))
+
+	cfg, err := s.store.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, -33.5, cfg.Latitude, "the site is saved")
+	assert.Equal(t, 2, s.Settings().SlewSettleTime)
+
+	date := time.Date(2026, 3, 1, 22, 0, 0, 0, time.UTC)
+	require.NoError(t, s.SetUTCDate(date))
+	assert.WithinDuration(t, date, s.Status().UTCDate, time.Second)
+}
@@ -0,0 +1,113 @@
+package weather_simulator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scriptStep is a step of a weather script: after the delay from the
+// previous step, it sets the clouds and the rain, when given.
+type scriptStep struct {
+	delay  time.Duration
+	clouds *float64
+	rain   *bool
+}
+
+// parseScript parses a weather script, steps separated by semicolons or new
+// lines, such as "clouds=80 rain=off; +30s rain=on; +2m clouds=0 rain=off".
+// A step starts with its delay from the previous step, if any, followed by
+// the clouds, in percent, and the rain, on or off.
+func parseScript(script string) ([]scriptStep, error) {
+	var steps []scriptStep
+	for _, text := range strings.FieldsFunc(script, func(r rune) bool { return r == ';' || r == '\n' }) {
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		var step scriptStep
+		if strings.HasPrefix(fields[0], "+") {
+			delay, err := time.ParseDuration(fields[0][1:])
+			if err != nil || delay < 0 {
+				return nil, fmt.Errorf("invalid delay %q", fields[0])
+			}
+			step.delay = delay
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("step %q sets nothing", strings.TrimSpace(text))
+		}
+
+		for _, field := range fields {
+			name, value, _ := strings.Cut(field, "=")
+			switch strings.ToLower(name) {
+			case "clouds":
+				clouds, err := parseClouds(value)
+				if err != nil {
+					return nil, err
+				}
+				step.clouds = &clouds
+			case "rain":
+				rain, err := parseRain(value)
+				if err != nil {
+					return nil, err
+				}
+				step.rain = &rain
+			default:
+				return nil, fmt.Errorf("unknown setting %q", field)
+			}
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("empty script")
+	}
+	return steps, nil
+}
+
+func parseClouds(value string) (float64, error) {
+	clouds, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || clouds < 0 || clouds > 100 {
+		return 0, fmt.Errorf("invalid clouds %q: must be a percentage", value)
+	}
+	return clouds, nil
+}
+
+func parseRain(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "on", "true", "1":
+		return true, nil
+	case "off", "false", "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid rain %q: must be on or off", value)
+	}
+}
+
+// runScript applies the steps in turn until the end of the script or the
+// cancellation of the context.
+func (w *WeatherSimulator) runScript(ctx context.Context, steps []scriptStep) {
+	for i, step := range steps {
+		if step.delay > 0 {
+			timer := time.NewTimer(step.delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+
+		w.logger.Infof("Weather script step %d of %d", i+1, len(steps))
+		if step.clouds != nil {
+			w.setClouds(*step.clouds)
+		}
+		if step.rain != nil {
+			w.setRain(*step.rain)
+		}
+	}
+	w.logger.Info("Weather script completed")
+}
@@ -0,0 +1,64 @@
+package weather_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"encoding/json"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultTemperature = 12
+	defaultHumidity    = 60
+	defaultPressure    = 1013
+	defaultWindSpeed   = 3
+	defaultRainRate    = 2
+	defaultDescription = "Weather simulator {driver} @ {host}"
+
+	weatherConfigKey = "weather_config"
+)
+
+// Config holds the clear sky weather. The clouds and the rain, set from the
+// actions or the setup page, are applied over it.
+type Config struct {
+	Temperature float64 `json:"temperature"` // degrees Celsius
+	Humidity    float64 `json:"humidity"`    // percent, with a clear sky
+	Pressure    float64 `json:"pressure"`    // hectopascals
+	WindSpeed   float64 `json:"wind_speed"`  // meters per second
+	RainRate    float64 `json:"rain_rate"`   // millimeters per hour while raining
+
+	Description string `json:"description"` // device description, with {driver} and {host} placeholders
+
+	Disabled bool `json:"disabled"` // hidden from the configured devices, e.g. in production
+}
+
+type store = alpaca.DriverStore[Config]
+
+// NewStoreWithKey creates a store for the configuration saved under key,
+// which defaults to a disabled simulator of a mild night with a light breeze.
+func NewStoreWithKey(db *bolt.DB, key string) (*store, error) {
+	return alpaca.NewDriverStore(db, key, Config{
+		Temperature: defaultTemperature,
+		Humidity:    defaultHumidity,
+		Pressure:    defaultPressure,
+		WindSpeed:   defaultWindSpeed,
+		RainRate:    defaultRainRate,
+		Description: defaultDescription,
+		Disabled:    true,
+	})
+}
+
+// ApplySettings merges the settings of a device file into the weather simulator
+// configuration saved under key, the default key if empty, and saves it if
+// they changed it.
+func ApplySettings(db *bolt.DB, key string, settings json.RawMessage) error {
+	if key == "" {
+		key = weatherConfigKey
+	}
+
+	st, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return err
+	}
+	return st.ApplySettings(settings)
+}
@@ -0,0 +1,511 @@
+// Package weather_simulator simulates a weather station, with the clouds and
+// the rain set from actions, a script or the setup page, so the reaction of
+// the safety chain to the weather can be tested without a real sky.
+package weather_simulator
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"context"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	weatherUID    = "2fc81f57-82e4-4aca-a585-18b52eca5439"
+	deviceName    = "Weather Simulator"
+	deviceType    = "ObservingConditions"
+	driverName    = "ZRO Weather Simulator"
+	driverVersion = "1.0"
+
+	// clearSkyDepression is how much colder than the air the clear sky is,
+	// in degrees Celsius. Clouds bring the sky temperature up to the air
+	// temperature.
+	clearSkyDepression = 25
+
+	// rainHumidity is the humidity while raining, in percent.
+	rainHumidity = 98
+)
+
+// Action names.
+const (
+	actionSetClouds = "SetClouds" // Set the cloud cover, in percent
+	actionSetRain   = "SetRain"   // Start or stop the rain, on or off
+	actionScript    = "Script"    // Run a weather script, or stop the running one without parameters
+)
+
+// sensorDescriptions describes the simulated sensors. The other sensors are
+// not implemented.
+var sensorDescriptions = map[string]string{
+	alpaca.SensorCloudCover:     "Simulated cloud cover",
+	alpaca.SensorDewPoint:       "Dew point derived from the simulated temperature and humidity",
+	alpaca.SensorHumidity:       "Simulated humidity, rising with the clouds and the rain",
+	alpaca.SensorPressure:       "Simulated pressure",
+	alpaca.SensorRainRate:       "Simulated rain rate",
+	alpaca.SensorSkyTemperature: "Simulated sky temperature, rising with the clouds",
+	alpaca.SensorTemperature:    "Simulated temperature",
+	alpaca.SensorWindDirection:  "Simulated wind direction",
+	alpaca.SensorWindGust:       "Simulated wind gust",
+	alpaca.SensorWindSpeed:      "Simulated wind speed",
+}
+
+// WeatherSimulator implements the alpaca.ObservingConditions interface.
+type WeatherSimulator struct {
+	logger log.FieldLogger
+	tmpl   *template.Template
+	store  *store
+	config Config
+
+	info   alpaca.DeviceInfo
+	driver alpaca.DriverInfo
+
+	connected atomic.Bool
+
+	mu      sync.Mutex
+	clouds  float64   // percent
+	rain    bool      // raining
+	updated time.Time // time of the last change of the weather
+
+	// cancelScript stops the running script, if any.
+	cancelScript context.CancelFunc
+}
+
+// New creates the simulator of a configured device instance. Each instance
+// keeps its settings under its own key; the default key is used when none is
+// set.
+func New(dev alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*WeatherSimulator, error) {
+	key := dev.Key
+	if key == "" {
+		key = weatherConfigKey
+	}
+
+	store, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store: %v", err)
+	}
+
+	config, err := store.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get weather config: %v", err)
+	}
+
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(weatherUID, dev.Key)
+	}
+
+	return &WeatherSimulator{
+		logger: logger,
+		tmpl:   tmpl,
+		store:  store,
+		config: config,
+
+		info: alpaca.DeviceInfo{
+			Name:     deviceName,
+			Type:     deviceType,
+			Number:   dev.Number,
+			UniqueID: uid,
+		},
+		driver: alpaca.DriverInfo{
+			Name:             driverName,
+			Version:          driverVersion,
+			InterfaceVersion: 1,
+		},
+		updated: time.Now(),
+	}, nil
+}
+
+// Shutdown stops the running script, if any.
+func (w *WeatherSimulator) Shutdown(ctx context.Context) error {
+	w.logger.Info("Shutting down weather simulator")
+	w.stopScript()
+	return nil
+}
+
+func (w *WeatherSimulator) DeviceInfo() alpaca.DeviceInfo {
+	info := w.info
+
+	format := w.config.Description
+	if format == "" {
+		format = defaultDescription
+	}
+	info.Description = alpaca.ExpandDescription(format, map[string]string{
+		"driver": driverVersion,
+	})
+	return info
+}
+
+func (w *WeatherSimulator) DriverInfo() alpaca.DriverInfo {
+	return w.driver
+}
+
+// Disabled reports whether the simulator is disabled in its setup page.
+func (w *WeatherSimulator) Disabled() bool {
+	return w.config.Disabled
+}
+
+func (w *WeatherSimulator) GetState() []alpaca.StateProperty {
+	props := []alpaca.StateProperty{
+		alpaca.StateTimeStamp(time.Now()),
+	}
+
+	if w.connected.Load() {
+		for _, name := range alpaca.ObservingSensors {
+			if value, err := w.Sensor(name); err == nil {
+				props = append(props, alpaca.StateProperty{Name: name, Value: value})
+			}
+		}
+	}
+
+	return props
+}
+
+func (w *WeatherSimulator) Connect() error {
+	if !w.connected.Swap(true) {
+		w.logger.Infof("%s connected", w.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventConnected,
+			Device:  w.info.Name,
+			Message: "Simulator connected",
+		})
+	}
+	return nil
+}
+
+func (w *WeatherSimulator) Disconnect() error {
+	if w.connected.Swap(false) {
+		w.logger.Infof("%s disconnected", w.info.Name)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventDisconnected,
+			Device:  w.info.Name,
+			Message: "Simulator disconnected",
+		})
+	}
+	return nil
+}
+
+func (w *WeatherSimulator) Connected() bool {
+	return w.connected.Load()
+}
+
+func (w *WeatherSimulator) Connecting() bool {
+	return false
+}
+
+// Sensor returns the simulated value of a sensor. The humidity and the sky
+// temperature follow the clouds and the rain, as a cloud or rain sensor
+// would see them.
+func (w *WeatherSimulator) Sensor(name string) (float64, error) {
+	w.mu.Lock()
+	clouds, rain := w.clouds, w.rain
+	w.mu.Unlock()
+
+	cfg := w.config
+	humidity := cfg.Humidity + (100-cfg.Humidity)*clouds/200
+	if rain {
+		humidity = math.Max(humidity, rainHumidity)
+	}
+
+	switch name {
+	case alpaca.SensorCloudCover:
+		return clouds, nil
+	case alpaca.SensorDewPoint:
+		return alpaca.DewPoint(cfg.Temperature, humidity), nil
+	case alpaca.SensorHumidity:
+		return humidity, nil
+	case alpaca.SensorPressure:
+		return cfg.Pressure, nil
+	case alpaca.SensorRainRate:
+		if rain {
+			return cfg.RainRate, nil
+		}
+		return 0, nil
+	case alpaca.SensorSkyTemperature:
+		return cfg.Temperature - clearSkyDepression*(1-clouds/100), nil
+	case alpaca.SensorTemperature:
+		return cfg.Temperature, nil
+	case alpaca.SensorWindDirection:
+		if cfg.WindSpeed == 0 {
+			return 0, nil
+		}
+		return 270, nil
+	case alpaca.SensorWindGust:
+		return cfg.WindSpeed * 1.5, nil
+	case alpaca.SensorWindSpeed:
+		return cfg.WindSpeed, nil
+	default:
+		return 0, errors.ErrNotImplemented
+	}
+}
+
+func (w *WeatherSimulator) SensorDescription(name string) (string, error) {
+	description, ok := sensorDescriptions[name]
+	if !ok {
+		return "", errors.ErrNotImplemented
+	}
+	return description, nil
+}
+
+// TimeSinceLastUpdate returns the time since the last change of the weather,
+// the same for all the sensors.
+func (w *WeatherSimulator) TimeSinceLastUpdate(name string) (time.Duration, error) {
+	if _, ok := sensorDescriptions[name]; name != "" && !ok {
+		return 0, errors.ErrNotImplemented
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return time.Since(w.updated), nil
+}
+
+// AveragePeriod is always 0: the simulator does not average its values.
+func (w *WeatherSimulator) AveragePeriod() float64 {
+	return 0
+}
+
+// SetAveragePeriod only accepts 0, as a device that does not average.
+func (w *WeatherSimulator) SetAveragePeriod(hours float64) error {
+	if hours != 0 {
+		return errors.Errorf(errors.ErrInvalidValue, "the simulator does not average its values")
+	}
+	return nil
+}
+
+// Refresh does nothing: the simulated values are always current.
+func (w *WeatherSimulator) Refresh() error {
+	return nil
+}
+
+func (w *WeatherSimulator) SupportedActions() []string {
+	return []string{actionSetClouds, actionSetRain, actionScript}
+}
+
+func (w *WeatherSimulator) Action(name, parameters string) (string, error) {
+	if !w.connected.Load() {
+		return "", errors.ErrNotConnected
+	}
+
+	switch name {
+	case actionSetClouds:
+		clouds, err := parseClouds(parameters)
+		if err != nil {
+			return "", errors.Wrap(errors.ErrInvalidValue, err)
+		}
+		w.setClouds(clouds)
+		return "", nil
+	case actionSetRain:
+		rain, err := parseRain(parameters)
+		if err != nil {
+			return "", errors.Wrap(errors.ErrInvalidValue, err)
+		}
+		w.setRain(rain)
+		return "", nil
+	case actionScript:
+		if strings.TrimSpace(parameters) == "" {
+			w.stopScript()
+			return "script stopped", nil
+		}
+		if err := w.startScript(parameters); err != nil {
+			return "", errors.Wrap(errors.ErrInvalidValue, err)
+		}
+		return "script started", nil
+	default:
+		return "", errors.ErrActionNotImplemented
+	}
+}
+
+func (w *WeatherSimulator) setClouds(clouds float64) {
+	w.mu.Lock()
+	w.clouds = clouds
+	w.updated = time.Now()
+	w.mu.Unlock()
+
+	w.logger.Infof("Clouds set to %.0f%%", clouds)
+	w.publishChange(fmt.Sprintf("Clouds %.0f%%", clouds))
+}
+
+func (w *WeatherSimulator) setRain(rain bool) {
+	w.mu.Lock()
+	w.rain = rain
+	w.updated = time.Now()
+	w.mu.Unlock()
+
+	w.logger.Infof("Rain set to %v", rain)
+	if rain {
+		w.publishChange("Rain started")
+	} else {
+		w.publishChange("Rain stopped")
+	}
+}
+
+func (w *WeatherSimulator) publishChange(message string) {
+	alpaca.Publish(alpaca.Event{
+		Type:    alpaca.EventStateChanged,
+		Device:  w.info.Name,
+		Message: message,
+	})
+}
+
+// startScript runs a script in the background, stopping the running one.
+func (w *WeatherSimulator) startScript(script string) error {
+	steps, err := parseScript(script)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.mu.Lock()
+	if w.cancelScript != nil {
+		w.cancelScript()
+	}
+	w.cancelScript = cancel
+	w.mu.Unlock()
+
+	w.logger.Infof("Starting weather script of %d steps", len(steps))
+	go w.runScript(ctx, steps)
+	return nil
+}
+
+func (w *WeatherSimulator) stopScript() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cancelScript != nil {
+		w.cancelScript()
+		w.cancelScript = nil
+	}
+}
+
+func (w *WeatherSimulator) HandleSetup(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg, err := w.store.GetConfig()
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.renderSetupForm(rw, cfg, false, "")
+
+	case http.MethodPost:
+		if r.FormValue("control") != "" {
+			if err := w.handleControl(r); err != nil {
+				w.renderSetupForm(rw, w.config, false, err.Error())
+				return
+			}
+			w.renderSetupForm(rw, w.config, true, "")
+			return
+		}
+
+		cfg, err := parseWeatherSetupForm(r)
+		if err != nil {
+			w.renderSetupForm(rw, cfg, false, err.Error())
+			return
+		}
+
+		w.logger.Infof("Setting weather config: %+v", alpaca.Redact(cfg))
+		w.config = cfg
+		if err := w.store.SetConfig(cfg); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.renderSetupForm(rw, cfg, true, "")
+
+	default:
+		http.Error(rw, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleControl applies a control panel action to the simulated weather.
+func (w *WeatherSimulator) handleControl(r *http.Request) error {
+	switch action := r.FormValue("control"); action {
+	case "clouds":
+		clouds, err := parseClouds(r.FormValue("clouds"))
+		if err != nil {
+			return err
+		}
+		w.setClouds(clouds)
+
+	case "rain":
+		w.setRain(r.FormValue("rain") == "true")
+
+	case "script":
+		return w.startScript(r.FormValue("script"))
+
+	case "stop-script":
+		w.logger.Info("Control: weather script stopped")
+		w.stopScript()
+
+	default:
+		return fmt.Errorf("unknown control action: %q", action)
+	}
+
+	return nil
+}
+
+func (w *WeatherSimulator) renderSetupForm(rw http.ResponseWriter, cfg Config, success bool, err string) {
+	sensors := make(map[string]float64)
+	for name := range sensorDescriptions {
+		sensors[name], _ = w.Sensor(name)
+	}
+
+	w.mu.Lock()
+	rain, scriptRunning := w.rain, w.cancelScript != nil
+	w.mu.Unlock()
+
+	data := struct {
+		Config
+		Sensors       map[string]float64
+		Rain          bool
+		ScriptRunning bool
+		Connected     bool
+		Success       bool
+		Error         string
+	}{cfg, sensors, rain, scriptRunning, w.connected.Load(), success, err}
+
+	if err := w.tmpl.ExecuteTemplate(rw, "weather_simulator_setup.html", data); err != nil {
+		http.Error(rw, "Error rendering template", http.StatusInternalServerError)
+		w.logger.Errorf("Error rendering template: %v", err)
+	}
+}
+
+func parseWeatherSetupForm(r *http.Request) (Config, error) {
+	if err := r.ParseForm(); err != nil {
+		return Config{}, fmt.Errorf("error parsing form: %v", err)
+	}
+
+	values := make(map[string]float64)
+	for _, key := range []string{"temperature", "humidity", "pressure", "wind-speed", "rain-rate"} {
+		value, err := strconv.ParseFloat(r.FormValue(key), 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s: %v", key, err)
+		}
+		values[key] = value
+	}
+	if h := values["humidity"]; h < 0 || h > 100 {
+		return Config{}, fmt.Errorf("invalid humidity: must be a percentage")
+	}
+	if values["pressure"] <= 0 || values["wind-speed"] < 0 || values["rain-rate"] < 0 {
+		return Config{}, fmt.Errorf("invalid pressure, wind speed or rain rate: must not be negative")
+	}
+
+	return Config{
+		Temperature: values["temperature"],
+		Humidity:    values["humidity"],
+		Pressure:    values["pressure"],
+		WindSpeed:   values["wind-speed"],
+		RainRate:    values["rain-rate"],
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Disabled:    r.FormValue("enabled") != "true",
+	}, nil
+}
@@ -0,0 +1,91 @@
+package weather_simulator
+
+import (
+	"alpaca/internal/simtest"
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSimulator(t *testing.T) *WeatherSimulator {
+	t.Helper()
+
+	w, err := New(alpaca.DeviceConfig{}, simtest.OpenDB(t), nil, log.New())
+	require.NoError(t, err)
+	t.Cleanup(w.stopScript)
+	return w
+}
+
+func TestParseScript(t *testing.T) {
+	steps, err := parseScript("clouds=80 rain=off; +30s rain=on\n+2m clouds=0")
+	require.NoError(t, err)
+	require.Len(t, steps, 3)
+
+	assert.Zero(t, steps[0].delay)
+	assert.Equal(t, 80.0, *steps[0].clouds)
+	assert.False(t, *steps[0].rain)
+	assert.Equal(t, 30*time.Second, steps[1].delay)
+	assert.Nil(t, steps[1].clouds)
+	assert.True(t, *steps[1].rain)
+	assert.Equal(t, 2*time.Minute, steps[2].delay)
+
+	for _, script := range []string{"", "+30s", "clouds=120", "rain=maybe", "wind=10", "+soon clouds=0"} {
+		_, err := parseScript(script)
+		assert.Error(t, err, script)
+	}
+}
+
+func TestWeather(t *testing.T) {
+	w := newTestSimulator(t)
+	require.NoError(t, w.Connect())
+
+	clear, err := w.Sensor(alpaca.SensorSkyTemperature)
+	require.NoError(t, err)
+	rate, _ := w.Sensor(alpaca.SensorRainRate)
+	assert.Zero(t, rate)
+
+	_, err = w.Action(actionSetClouds, "100")
+	require.NoError(t, err)
+	_, err = w.Action(actionSetRain, "on")
+	require.NoError(t, err)
+
+	cloudy, _ := w.Sensor(alpaca.SensorSkyTemperature)
+	assert.Greater(t, cloudy, clear, "clouds warm the sky")
+	rate, _ = w.Sensor(alpaca.SensorRainRate)
+	assert.Equal(t, float64(defaultRainRate), rate)
+	humidity, _ := w.Sensor(alpaca.SensorHumidity)
+	assert.GreaterOrEqual(t, humidity, float64(rainHumidity))
+
+	_, err = w.Sensor(alpaca.SensorStarFWHM)
+	assert.ErrorIs(t, err, errors.ErrNotImplemented)
+	_, err = w.Action(actionSetClouds, "lots")
+	assert.ErrorIs(t, err, errors.ErrInvalidValue)
+}
+
+func TestScriptAction(t *testing.T) {
+	w := newTestSimulator(t)
+	require.NoError(t, w.Connect())
+
+	_, err := w.Action(actionScript, "clouds=90; +50ms rain=on")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		rate, _ := w.Sensor(alpaca.SensorRainRate)
+		return rate > 0
+	}, time.Second, 10*time.Millisecond)
+	clouds, _ := w.Sensor(alpaca.SensorCloudCover)
+	assert.Equal(t, 90.0, clouds)
+
+	// A new script cancels the running one.
+	_, err = w.Action(actionScript, "rain=off; +50ms clouds=10 rain=on")
+	require.NoError(t, err)
+	_, err = w.Action(actionScript, "clouds=0")
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	rate, _ := w.Sensor(alpaca.SensorRainRate)
+	assert.Zero(t, rate, "the cancelled script did not start the rain")
+}
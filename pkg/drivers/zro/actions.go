@@ -0,0 +1,151 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"alpaca/pkg/notify"
+	"encoding/json"
+	"strings"
+)
+
+// rawCommand sends the command in parameters, such as "V" or "_V;", to the
+// controller and returns the value of its response.
+func (d *Driver) rawCommand(parameters string) (string, error) {
+	ctrl, err := d.controller()
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(parameters) == "" {
+		return "", errors.Errorf(errors.ErrInvalidValue, "missing command, e.g. V")
+	}
+
+	d.logger.Infof("Raw command: %s", parameters)
+	value, err := ctrl.SendRaw(parameters)
+	return value, deviceError(err)
+}
+
+// Command passes a command through to the controller for diagnostics, for
+// the CommandBlind, CommandBool and CommandString methods. Without raw, the
+// command is given without its framing, such as "V"; with raw, it is given
+// as sent to the controller, such as "_V;".
+func (d *Driver) Command(command string, raw bool) (string, error) {
+	framed := strings.HasPrefix(command, "_") && strings.HasSuffix(command, ";")
+	if raw != framed {
+		if raw {
+			return "", errors.Errorf(errors.ErrInvalidValue, "raw command %q must be framed as _X;", command)
+		}
+		return "", errors.Errorf(errors.ErrInvalidValue, "command %q must not be framed unless raw", command)
+	}
+	return d.rawCommand(command)
+}
+
+// batteryReading is the result of the ReadBattery action.
+type batteryReading struct {
+	Voltage float32 `json:"Voltage"` // Volts, 0 until the battery is read
+	Current float32 `json:"Current"` // Amperes
+}
+
+// readBattery returns the last reading of the shutter battery, as JSON, and
+// asks the shutter for a new one, which the next call returns.
+func (d *Driver) readBattery() (string, error) {
+	ctrl, err := d.controller()
+	if err != nil {
+		return "", err
+	}
+	if !ctrl.Config().UseShutter {
+		return "", errors.Errorf(errors.ErrInvalidOperation, "the shutter is not used")
+	}
+
+	if err := ctrl.RequestBattery(); err != nil {
+		d.logger.Warnf("Failed to request a battery reading: %v", err)
+	}
+
+	st := ctrl.GetStatus()
+	b, err := json.Marshal(batteryReading{Voltage: st.BatteryVoltage, Current: st.BatteryCurrent})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// emergencyStop stops the dome and the shutter at once. The slaving ends, the
+// held motions and a pending park on shutter are cancelled, so nothing starts
+// the dome again until a client asks for it.
+func (d *Driver) emergencyStop() (string, error) {
+	ctrl, err := d.controller()
+	if err != nil {
+		return "", err
+	}
+
+	d.mu.Lock()
+	d.slaved = false
+	d.mu.Unlock()
+	d.parkingToClose.Store(false)
+	d.interlock.take()
+	d.arbiter.release()
+
+	// The shutter is stopped even if the dome could not be.
+	err = ctrl.AbortSlew()
+	if ctrl.Config().UseShutter {
+		if serr := ctrl.AbortShutter(); serr != nil && err == nil {
+			err = serr
+		}
+	}
+
+	d.logger.Warn("Emergency stop")
+	alpaca.Publish(alpaca.Event{
+		Type:    alpaca.EventError,
+		Device:  d.name,
+		Message: "Emergency stop requested, the slaving is off",
+	})
+	if err != nil {
+		return "", deviceError(err)
+	}
+	return "stopped", nil
+}
+
+// shutdownSequence secures the dome at the end of the night: the slaving
+// ends, the shutter closes and the dome parks. The park is held until the
+// shutter stops, and with park on shutter closing the shutter parks the dome
+// first. Both are safety motions, allowed while the dome is slaved.
+func (d *Driver) shutdownSequence() (string, error) {
+	ctrl, err := d.controller()
+	if err != nil {
+		return "", err
+	}
+
+	d.mu.Lock()
+	d.slaved = false
+	d.mu.Unlock()
+	d.logger.Info("Shutdown sequence: slaving off, closing the shutter and parking")
+
+	cfg := ctrl.Config()
+	if cfg.UseShutter {
+		if err := d.closeShutter(ctrl); err != nil {
+			return "", deviceError(err)
+		}
+		alpaca.Publish(alpaca.Event{
+			Type:         alpaca.EventStateChanged,
+			Device:       d.name,
+			Message:      "Shutdown sequence: closing the shutter for safety",
+			Notification: notify.EventSafetyClose,
+		})
+		if cfg.ParkOnShutter {
+			return "closing the shutter after parking", nil
+		}
+	}
+
+	st := ctrl.GetStatus()
+	ticks := azimuthTicks(cfg, ctrl.TicksToDegrees(st.Position), cfg.ParkPosition)
+	if err := d.startMotion(ctrl, motionSafety, "shutdown park", ticks); err != nil {
+		return "", err
+	}
+	if err := d.move(ctrl, queuedMotion{
+		what:     "shutdown park",
+		expected: slewDuration(cfg, ticks),
+		run:      ctrl.Park,
+	}); err != nil {
+		return "", err
+	}
+	return "closing the shutter and parking", nil
+}
@@ -0,0 +1,119 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca/errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// motionSource identifies who commanded the current dome motion.
+type motionSource int
+
+const (
+	motionIdle    motionSource = iota // No motion is owned
+	motionManual                      // Slew or park requested by a client
+	motionHoming                      // Home search requested by a client
+	motionSlaving                     // Correction of the slaving engine
+	motionSafety                      // Safety action, such as a shutdown sequence
+)
+
+func (s motionSource) String() string {
+	switch s {
+	case motionIdle:
+		return "idle"
+	case motionManual:
+		return "manual"
+	case motionHoming:
+		return "homing"
+	case motionSlaving:
+		return "slaving"
+	case motionSafety:
+		return "safety"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(s))
+	}
+}
+
+// motionSettle is how long a motion stays owned before an idle status
+// releases it, since the controller only reports the slew with its next
+// telemetry.
+const motionSettle = 3 * time.Second
+
+// arbiter decides which source may move the dome. Safety actions come first,
+// then the slaving, then the manual client slews:
+//
+//   - a safety action preempts any motion in progress;
+//   - while the dome is slaved, and the slaving is not paused, manual slews
+//     are rejected by the driver;
+//   - any other motion waits until the one in progress ends. In particular
+//     the slaving does not take over a manual recovery started while it was
+//     paused.
+//
+// A motion is owned from the command until the dome is seen idle.
+type arbiter struct {
+	logger log.FieldLogger
+
+	mu    sync.Mutex
+	owner motionSource // Source of the motion in progress
+	since time.Time    // Time the motion was started
+}
+
+func newArbiter(logger log.FieldLogger) *arbiter {
+	return &arbiter{logger: logger}
+}
+
+// acquire gives the ownership of the dome motion to the source, or returns
+// an error naming the source that owns the motion in progress.
+func (a *arbiter) acquire(source motionSource, what string, now time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	switch {
+	case a.owner == motionIdle, a.owner == source:
+	case source == motionSafety:
+		a.logger.Warnf("Safety action preempts the %s motion", a.owner)
+	default:
+		return errors.Errorf(errors.ErrInvalidOperation, "the dome is moving for %s", a.owner)
+	}
+
+	if a.owner != source {
+		a.logger.Infof("Motion owned by %s: %s", source, what)
+	}
+	a.owner = source
+	a.since = now
+	return nil
+}
+
+// settle releases the motion once the dome is no longer slewing.
+func (a *arbiter) settle(slewing bool, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.owner == motionIdle || slewing || now.Sub(a.since) < motionSettle {
+		return
+	}
+	a.logger.Infof("Motion by %s finished", a.owner)
+	a.owner = motionIdle
+}
+
+// release drops the ownership of the motion, after an abort.
+func (a *arbiter) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.owner != motionIdle {
+		a.logger.Infof("Motion by %s aborted", a.owner)
+	}
+	a.owner = motionIdle
+}
+
+// current returns the source that owns the motion in progress.
+func (a *arbiter) current() motionSource {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.owner
+}
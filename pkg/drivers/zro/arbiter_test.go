@@ -0,0 +1,46 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca/errors"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArbiter(t *testing.T) {
+	a := newArbiter(log.StandardLogger())
+	now := time.Now()
+
+	require.NoError(t, a.acquire(motionManual, "slew", now))
+	assert.Equal(t, motionManual, a.current())
+	require.NoError(t, a.acquire(motionManual, "slew again", now), "a source may retarget its own motion")
+
+	// The slaving waits for the manual recovery to end.
+	err := a.acquire(motionSlaving, "follow", now)
+	var alpacaErr errors.Error
+	require.ErrorAs(t, err, &alpacaErr)
+	assert.Equal(t, errors.ErrInvalidOperation.Number, alpacaErr.Number)
+	assert.Equal(t, motionManual, a.current())
+
+	// The motion is kept until the dome has had time to report the slew.
+	a.settle(false, now.Add(time.Second))
+	assert.Equal(t, motionManual, a.current())
+	a.settle(true, now.Add(time.Minute))
+	assert.Equal(t, motionManual, a.current())
+	a.settle(false, now.Add(time.Minute))
+	assert.Equal(t, motionIdle, a.current())
+
+	require.NoError(t, a.acquire(motionSlaving, "follow", now))
+	assert.Error(t, a.acquire(motionHoming, "find home", now))
+
+	// Safety preempts any motion, and nothing else preempts it.
+	require.NoError(t, a.acquire(motionSafety, "close", now))
+	assert.Equal(t, motionSafety, a.current())
+	assert.Error(t, a.acquire(motionSlaving, "follow", now))
+
+	a.release()
+	assert.Equal(t, motionIdle, a.current())
+}
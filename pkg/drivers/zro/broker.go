@@ -0,0 +1,109 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/notify"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// brokerStatus follows the MQTT broker the driver is connected to. With
+// several brokers configured the client tries them in order, on connect and
+// on every reconnection, so it fails over when the preferred one is down and
+// returns to it once it is back.
+type brokerStatus struct {
+	device string // Device of the events
+
+	mu        sync.Mutex
+	attempt   string    // Broker of the last connection attempt
+	current   string    // Connected broker, empty while disconnected
+	previous  string    // Last connected broker, kept across connections
+	since     time.Time // Time of the connection to the current broker
+	failovers int       // Connections to a broker other than the previous one
+}
+
+// brokerInfo is the broker status shown on the setup page.
+type brokerInfo struct {
+	Current   string
+	Since     time.Time
+	Failovers int
+}
+
+// attempting records a connection attempt to broker.
+func (b *brokerStatus) attempting(broker string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.attempt = broker
+}
+
+// connected records the connection to the broker of the last attempt at now.
+// It returns the broker and whether it differs from the previous one.
+func (b *brokerStatus) connected(now time.Time) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failover := b.previous != "" && b.previous != b.attempt
+	if failover {
+		b.failovers++
+	}
+	b.current, b.previous, b.since = b.attempt, b.attempt, now
+	return b.current, failover
+}
+
+// lost records the loss of the connection and returns the broker it was to.
+func (b *brokerStatus) lost() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	broker := b.current
+	b.current = ""
+	return broker
+}
+
+// info returns the connected broker, or nil while disconnected.
+func (b *brokerStatus) info() *brokerInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.current == "" {
+		return nil
+	}
+	return &brokerInfo{Current: b.current, Since: b.since, Failovers: b.failovers}
+}
+
+// onConnect is called by the MQTT client after each successful connection.
+// A connection to another broker than the last one is a failover, which is
+// published as a notification.
+func (b *brokerStatus) onConnect() {
+	broker, failover := b.connected(time.Now())
+	if !failover {
+		return
+	}
+	alpaca.Publish(alpaca.Event{
+		Type:         alpaca.EventConnected,
+		Device:       b.device,
+		Message:      fmt.Sprintf("Failed over to MQTT broker %s", broker),
+		Notification: notify.EventBrokerFailover,
+	})
+}
+
+// onConnectionLost is called by the MQTT client when the connection drops.
+func (b *brokerStatus) onConnectionLost(err error) {
+	broker := b.lost()
+	alpaca.Publish(alpaca.Event{
+		Type:         alpaca.EventDisconnected,
+		Device:       b.device,
+		Message:      fmt.Sprintf("Lost connection to MQTT broker %s: %v", broker, err),
+		Notification: notify.EventConnectionLost,
+	})
+}
+
+// parseBrokers splits a list of broker URLs separated by commas, spaces or
+// new lines.
+func parseBrokers(s string) []string {
+	return strings.FieldsFunc(s, func(c rune) bool {
+		return c == ',' || c == '\n' || c == '\r' || c == ' '
+	})
+}
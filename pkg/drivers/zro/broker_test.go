@@ -0,0 +1,51 @@
+package zro
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrokerStatus(t *testing.T) {
+	var b brokerStatus
+	assert.Nil(t, b.info(), "not connected yet")
+
+	now := time.Now()
+	b.attempting("tcp://site:1883")
+	broker, failover := b.connected(now)
+	assert.Equal(t, "tcp://site:1883", broker)
+	assert.False(t, failover, "the first connection is not a failover")
+
+	info := b.info()
+	require.NotNil(t, info)
+	assert.Equal(t, brokerInfo{Current: "tcp://site:1883", Since: now}, *info)
+
+	// The site broker goes down and the client reconnects to the cloud one.
+	assert.Equal(t, "tcp://site:1883", b.lost())
+	assert.Nil(t, b.info())
+	b.attempting("tcp://site:1883")
+	b.attempting("tcp://cloud:1883")
+	_, failover = b.connected(now.Add(time.Minute))
+	assert.True(t, failover)
+	assert.Equal(t, "tcp://cloud:1883", b.info().Current)
+	assert.Equal(t, 1, b.info().Failovers)
+
+	// Back to the site broker on the next reconnection.
+	b.lost()
+	b.attempting("tcp://site:1883")
+	_, failover = b.connected(now.Add(time.Hour))
+	assert.True(t, failover)
+	assert.Equal(t, 2, b.info().Failovers)
+
+	// Reconnecting to the same broker is not a failover.
+	b.lost()
+	_, failover = b.connected(now.Add(2 * time.Hour))
+	assert.False(t, failover)
+}
+
+func TestParseBrokers(t *testing.T) {
+	assert.Empty(t, parseBrokers(""))
+	assert.Equal(t, []string{"tcp://a:1883", "ssl://b:8883"}, parseBrokers("tcp://a:1883,\r\n ssl://b:8883\n"))
+}
@@ -0,0 +1,233 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"alpaca/pkg/dome"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const conditionsUID = "3c9b2e6d-8f41-4a7c-b5d0-2e7f9a1c4b68"
+
+// conditionsDescriptions describes the sensors of the controller. The other
+// sensors are not implemented.
+var conditionsDescriptions = map[string]string{
+	alpaca.SensorTemperature: "Temperature at the dome controller",
+	alpaca.SensorHumidity:    "Humidity at the dome controller",
+	alpaca.SensorDewPoint:    "Dew point derived from the controller temperature and humidity",
+}
+
+// Conditions serves the temperature and humidity sensors of the ZRO dome
+// controller as an ObservingConditions device, so the imaging software can log
+// them from the same server. The values come with the controller telemetry.
+type Conditions struct {
+	dome   *Driver
+	number int
+	uid    string
+	logger log.FieldLogger
+
+	connected atomic.Bool
+}
+
+// NewConditions creates the ObservingConditions device of a ZRO dome.
+func NewConditions(dev alpaca.DeviceConfig, dome *Driver, logger log.FieldLogger) *Conditions {
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(conditionsUID, dev.Key)
+	}
+	return &Conditions{dome: dome, number: dev.Number, uid: uid, logger: logger}
+}
+
+func (c *Conditions) DeviceInfo() alpaca.DeviceInfo {
+	dome := c.dome.DeviceInfo()
+	return alpaca.DeviceInfo{
+		Name:        dome.Name + " Sensors",
+		Description: "Temperature and humidity at the controller of " + dome.Name,
+		Type:        alpaca.DeviceTypeConditions,
+		Number:      c.number,
+		UniqueID:    c.uid,
+	}
+}
+
+func (c *Conditions) DriverInfo() alpaca.DriverInfo {
+	info := c.dome.DriverInfo()
+	info.InterfaceVersion = 2
+	return info
+}
+
+// Disabled reports whether the dome it reads is disabled.
+func (c *Conditions) Disabled() bool {
+	return c.dome.Disabled()
+}
+
+// GetState returns the sensor values, time stamped with the last
+// environment telemetry received from the controller, like the dome state.
+func (c *Conditions) GetState() []alpaca.StateProperty {
+	ctrl, err := c.dome.controller()
+	if err != nil || !c.connected.Load() {
+		return []alpaca.StateProperty{alpaca.StateTimeStamp(time.Now())}
+	}
+
+	return sensorState(ctrl.GetStatus())
+}
+
+// sensorState returns the sensor values of the controller in status st.
+func sensorState(st dome.Status) []alpaca.StateProperty {
+	received := st.EnvironmentTime
+	if received.IsZero() {
+		received = time.Now()
+	}
+	props := []alpaca.StateProperty{alpaca.StateTimeStamp(received)}
+	for _, name := range alpaca.ObservingSensors {
+		if value, err := sensorValue(st, name); err == nil {
+			props = append(props, alpaca.StateProperty{Name: name, Value: value})
+		}
+	}
+	return props
+}
+
+// Connect connects the device only: the dome keeps its own connection, and
+// the sensors are not connected while it is not.
+func (c *Conditions) Connect() error {
+	if !c.connected.Swap(true) {
+		c.logger.Info("Dome sensors connected")
+	}
+	return nil
+}
+
+func (c *Conditions) Disconnect() error {
+	if c.connected.Swap(false) {
+		c.logger.Info("Dome sensors disconnected")
+	}
+	return nil
+}
+
+func (c *Conditions) Connected() bool {
+	return c.connected.Load()
+}
+
+func (c *Conditions) Connecting() bool {
+	return false
+}
+
+// Sensor returns the last value of a sensor received from the controller.
+func (c *Conditions) Sensor(name string) (float64, error) {
+	if _, ok := conditionsDescriptions[name]; !ok {
+		return 0, errors.ErrNotImplemented
+	}
+	ctrl, err := c.dome.controller()
+	if err != nil {
+		return 0, err
+	}
+	return sensorValue(ctrl.GetStatus(), name)
+}
+
+// sensorValue returns the value of a sensor of the controller in status st.
+func sensorValue(st dome.Status, name string) (float64, error) {
+	if st.EnvironmentTime.IsZero() {
+		return 0, errors.Errorf(errors.ErrValueNotSet, "no %s received from the controller yet", name)
+	}
+
+	temperature, humidity := float64(st.Temperature), float64(st.Humidity)
+	switch name {
+	case alpaca.SensorTemperature:
+		return temperature, nil
+	case alpaca.SensorHumidity:
+		return humidity, nil
+	case alpaca.SensorDewPoint:
+		return alpaca.DewPoint(temperature, humidity), nil
+	default:
+		return 0, errors.ErrNotImplemented
+	}
+}
+
+func (c *Conditions) SensorDescription(name string) (string, error) {
+	description, ok := conditionsDescriptions[name]
+	if !ok {
+		return "", errors.ErrNotImplemented
+	}
+	return description, nil
+}
+
+// TimeSinceLastUpdate returns the time since the controller last reported
+// its temperature or humidity, the same for all the sensors.
+func (c *Conditions) TimeSinceLastUpdate(name string) (time.Duration, error) {
+	if _, ok := conditionsDescriptions[name]; name != "" && !ok {
+		return 0, errors.ErrNotImplemented
+	}
+	ctrl, err := c.dome.controller()
+	if err != nil {
+		return 0, err
+	}
+	updated := ctrl.GetStatus().EnvironmentTime
+	if updated.IsZero() {
+		return 0, errors.Errorf(errors.ErrValueNotSet, "no sensor value received from the controller yet")
+	}
+	return time.Since(updated), nil
+}
+
+// AveragePeriod is always 0: the controller reports instantaneous values.
+func (c *Conditions) AveragePeriod() float64 {
+	return 0
+}
+
+// SetAveragePeriod only accepts 0, as a device that does not average.
+func (c *Conditions) SetAveragePeriod(hours float64) error {
+	if hours != 0 {
+		return errors.Errorf(errors.ErrInvalidValue, "the controller does not average its values")
+	}
+	return nil
+}
+
+// Refresh reads the temperature and the humidity from the controller at
+// once, instead of waiting for the next telemetry.
+func (c *Conditions) Refresh() error {
+	ctrl, err := c.dome.controller()
+	if err != nil {
+		return err
+	}
+	return deviceError(ctrl.ReadEnvironment())
+}
+
+// HandleSetup shows the sensor values; the dome is set up on its own page.
+func (c *Conditions) HandleSetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type sensor struct {
+		Name  string
+		Value string
+	}
+	var sensors []sensor
+	for _, name := range []string{alpaca.SensorTemperature, alpaca.SensorHumidity, alpaca.SensorDewPoint} {
+		value := "not available"
+		if v, err := c.Sensor(name); err == nil {
+			value = fmt.Sprintf("%.1f", v)
+		}
+		sensors = append(sensors, sensor{name, value})
+	}
+	age := "never"
+	if d, err := c.TimeSinceLastUpdate(""); err == nil {
+		age = d.Round(time.Second).String() + " ago"
+	}
+
+	data := struct {
+		Name      string
+		Connected bool
+		Sensors   []sensor
+		Updated   string
+		DomeSetup string
+	}{c.DeviceInfo().Name, c.connected.Load(), sensors, age, fmt.Sprintf("/setup/v1/dome/%d/setup", c.dome.number)}
+
+	if err := c.dome.tmpl.ExecuteTemplate(w, "zro_conditions_setup.html", data); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		c.logger.Errorf("Error rendering template: %v", err)
+	}
+}
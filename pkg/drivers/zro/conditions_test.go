@@ -0,0 +1,60 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"alpaca/pkg/dome"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSensorValue(t *testing.T) {
+	_, err := sensorValue(dome.Status{}, alpaca.SensorTemperature)
+	assert.ErrorIs(t, err, errors.ErrValueNotSet, "nothing received yet")
+
+	st := dome.Status{Temperature: 10, Humidity: 80, EnvironmentTime: time.Now()}
+	value, err := sensorValue(st, alpaca.SensorTemperature)
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, value)
+	value, err = sensorValue(st, alpaca.SensorHumidity)
+	require.NoError(t, err)
+	assert.Equal(t, 80.0, value)
+	value, err = sensorValue(st, alpaca.SensorDewPoint)
+	require.NoError(t, err)
+	assert.InDelta(t, 6.7, value, 0.1)
+
+	_, err = sensorValue(st, alpaca.SensorPressure)
+	assert.ErrorIs(t, err, errors.ErrNotImplemented)
+}
+
+func TestSensorState(t *testing.T) {
+	received := time.Date(2024, 3, 1, 22, 15, 0, 0, time.UTC)
+	props := sensorState(dome.Status{Temperature: 10, Humidity: 80, EnvironmentTime: received})
+	require.NotEmpty(t, props)
+	assert.Equal(t, alpaca.StateTimeStamp(received), props[0], "stamped with the telemetry reception")
+	assert.Contains(t, props, alpaca.StateProperty{Name: alpaca.SensorTemperature, Value: 10.0})
+
+	props = sensorState(dome.Status{})
+	assert.Len(t, props, 1, "only the time stamp before the first telemetry")
+}
+
+func TestConditions(t *testing.T) {
+	d := newConnectedDriver(t)
+	c := NewConditions(alpaca.DeviceConfig{Number: 0}, d, d.logger)
+	assert.Equal(t, alpaca.DeviceTypeConditions, c.DeviceInfo().Type)
+
+	_, err := c.Sensor(alpaca.SensorWindSpeed)
+	assert.ErrorIs(t, err, errors.ErrNotImplemented)
+	_, err = c.TimeSinceLastUpdate(alpaca.SensorTemperature)
+	assert.ErrorIs(t, err, errors.ErrValueNotSet)
+	assert.Error(t, c.SetAveragePeriod(1))
+	assert.NoError(t, c.SetAveragePeriod(0))
+
+	require.NoError(t, d.Disconnect())
+	_, err = c.Sensor(alpaca.SensorTemperature)
+	assert.ErrorIs(t, err, errors.ErrNotConnected)
+	assert.ErrorIs(t, c.Refresh(), errors.ErrNotConnected)
+}
@@ -0,0 +1,151 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/dome"
+	"alpaca/pkg/notify"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultOvercurrentFactor is the overcurrent factor of configurations
+	// stored before it was added.
+	defaultOvercurrentFactor = 1.5
+
+	// baselineMinOperations is the number of shutter operations learned before
+	// the current is checked against the baseline.
+	baselineMinOperations = 3
+
+	// baselineWeight is the weight of the last operation in the baseline, an
+	// exponential moving average of the peak current of each operation, so it
+	// follows the seasons and the battery ageing.
+	baselineWeight = 0.2
+)
+
+// overcurrentFactor returns the configured factor, or the default one for
+// configurations stored before it was added.
+func (c Config) overcurrentFactor() float64 {
+	if c.ShutterOvercurrentFactor <= 1 {
+		return defaultOvercurrentFactor
+	}
+	return c.ShutterOvercurrentFactor
+}
+
+// Shutter operations with a learned baseline.
+const (
+	shutterOpening = iota
+	shutterClosing
+	shutterOperations
+)
+
+// shutterOperation returns the operation of a shutter status, or -1 if the
+// shutter is not moving.
+func shutterOperation(status dome.ShutterStatus) int {
+	switch status {
+	case dome.ShutterStatusOpening:
+		return shutterOpening
+	case dome.ShutterStatusClosing:
+		return shutterClosing
+	default:
+		return -1
+	}
+}
+
+// currentBaseline learns the peak battery current drawn by the shutter motor
+// while opening and closing, and detects an operation drawing much more, such
+// as when the shutter is obstructed or frozen.
+type currentBaseline struct {
+	mu       sync.Mutex
+	baseline [shutterOperations]float64 // Learned peak current of each operation, in A
+	learned  [shutterOperations]int     // Number of operations learned
+
+	operation int     // Operation in progress, -1 if none
+	peak      float64 // Peak current of the operation in progress
+	tripped   bool    // True if the operation in progress was aborted
+}
+
+func newCurrentBaseline() *currentBaseline {
+	return &currentBaseline{operation: -1}
+}
+
+// check follows the telemetry and reports, once per operation, a current
+// above factor times the baseline, with the limit. The peak current of each
+// operation that ends normally is learned into the baseline.
+func (b *currentBaseline) check(st dome.Status, factor float64) (float64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	op := shutterOperation(st.Shutter)
+	if op != b.operation {
+		b.learn()
+		b.operation = op
+	}
+	if op < 0 {
+		return 0, false
+	}
+
+	current := float64(st.BatteryCurrent)
+	b.peak = max(b.peak, current)
+	if b.tripped || b.learned[op] < baselineMinOperations {
+		return 0, false
+	}
+
+	limit := factor * b.baseline[op]
+	if current <= limit {
+		return 0, false
+	}
+	b.tripped = true
+	return limit, true
+}
+
+// learn adds the operation that ended to the baseline, unless it was aborted
+// or no current was read.
+func (b *currentBaseline) learn() {
+	op := b.operation
+	if op >= 0 && !b.tripped && b.peak > 0 {
+		if b.learned[op] == 0 {
+			b.baseline[op] = b.peak
+		} else {
+			b.baseline[op] += baselineWeight * (b.peak - b.baseline[op])
+		}
+		b.learned[op]++
+	}
+
+	b.peak = 0
+	b.tripped = false
+}
+
+// checkShutterCurrent aborts the shutter operation in progress if it draws
+// much more current than the learned baseline.
+func (d *Driver) checkShutterCurrent(ctrl *dome.Dome, st dome.Status, cfg Config) {
+	if !cfg.UseShutter || cfg.ShutterCurrentDisabled {
+		return
+	}
+
+	limit, overcurrent := d.current.check(st, cfg.overcurrentFactor())
+	if !overcurrent {
+		return
+	}
+
+	operation := strings.ToLower(st.Shutter.String())
+	d.logger.Errorf("Shutter overcurrent: %.2f A while %s, above %.2f A, aborting", st.BatteryCurrent, operation, limit)
+	if err := ctrl.AbortShutter(); err != nil {
+		d.logger.Errorf("Failed to abort the shutter: %v", err)
+		alpaca.Publish(alpaca.Event{
+			Type:         alpaca.EventError,
+			Device:       d.name,
+			Message:      fmt.Sprintf("Shutter still %s after failing to abort it: drawing %.2f A, above %.2f A, stop it by hand (%v)", operation, st.BatteryCurrent, limit, err),
+			Notification: notify.EventShutterOvercurrent,
+		})
+		return
+	}
+
+	alpaca.Publish(alpaca.Event{
+		Type:         alpaca.EventError,
+		Device:       d.name,
+		Message:      fmt.Sprintf("Shutter aborted while %s: drawing %.2f A, above %.2f A, check for an obstruction or ice", operation, st.BatteryCurrent, limit),
+		Notification: notify.EventShutterOvercurrent,
+	})
+}
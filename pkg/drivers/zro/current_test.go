@@ -0,0 +1,50 @@
+package zro
+
+import (
+	"alpaca/pkg/dome"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCurrentBaseline(t *testing.T) {
+	b := newCurrentBaseline()
+	check := func(shutter dome.ShutterStatus, current float32) bool {
+		_, overcurrent := b.check(dome.Status{Shutter: shutter, BatteryCurrent: current}, 1.5)
+		return overcurrent
+	}
+
+	// Nothing is checked until a few openings were learned.
+	for range baselineMinOperations {
+		assert.False(t, check(dome.ShutterStatusOpening, 1))
+		assert.False(t, check(dome.ShutterStatusOpening, 2))
+		assert.False(t, check(dome.ShutterStatusOpen, 0))
+	}
+	assert.InDelta(t, 2, b.baseline[shutterOpening], 1e-9)
+	assert.Zero(t, b.learned[shutterClosing])
+	assert.False(t, check(dome.ShutterStatusClosing, 10), "closing has no baseline yet")
+	assert.False(t, check(dome.ShutterStatusClosed, 0))
+
+	assert.False(t, check(dome.ShutterStatusOpening, 3))
+	limit, overcurrent := b.check(dome.Status{Shutter: dome.ShutterStatusOpening, BatteryCurrent: 3.5}, 1.5)
+	assert.True(t, overcurrent)
+	assert.InDelta(t, 3, limit, 1e-9)
+	assert.False(t, check(dome.ShutterStatusOpening, 4), "reported once per operation")
+
+	// The aborted opening is not learned.
+	assert.False(t, check(dome.ShutterStatusAborted, 0))
+	assert.InDelta(t, 2, b.baseline[shutterOpening], 1e-9)
+	assert.Equal(t, baselineMinOperations, b.learned[shutterOpening])
+
+	// The baseline follows the normal operations.
+	assert.False(t, check(dome.ShutterStatusOpening, 2.5))
+	assert.False(t, check(dome.ShutterStatusOpen, 0))
+	assert.InDelta(t, 2.1, b.baseline[shutterOpening], 1e-9)
+}
+
+func TestOvercurrentFactor(t *testing.T) {
+	var cfg Config
+	assert.Equal(t, defaultOvercurrentFactor, cfg.overcurrentFactor(), "stored before it was added")
+	cfg.ShutterOvercurrentFactor = 2
+	assert.Equal(t, 2.0, cfg.overcurrentFactor())
+}
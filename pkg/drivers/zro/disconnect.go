@@ -0,0 +1,143 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca/errors"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Causes of an unexpected disconnection.
+const (
+	causeBroker  = "broker"  // The broker closed the connection, e.g. while restarting
+	causeNetwork = "network" // The broker stopped answering, e.g. after a Wi-Fi loss
+	causeCrash   = "crash"   // The server stopped without disconnecting the dome
+)
+
+// disconnection is the last unexpected disconnection of the dome. It is
+// saved, so the operators can tell after a restart whether the broker, the
+// network or the server failed.
+type disconnection struct {
+	Time    time.Time
+	Cause   string
+	Message string
+}
+
+// disconnectCause classifies the error of a lost MQTT connection. The broker
+// closing the connection is told apart from a broker that no longer answers,
+// which is the network between them.
+func disconnectCause(err error) string {
+	switch {
+	case err == nil:
+		return causeBroker
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		return causeNetwork
+	case strings.Contains(err.Error(), "pingresp not received"), strings.Contains(err.Error(), "i/o timeout"):
+		return causeNetwork
+	default:
+		return causeBroker
+	}
+}
+
+// lastDisconnectKey and connectedKey are the database keys of the last
+// unexpected disconnection and of the time the dome was connected, set while
+// it is.
+func (s *store) lastDisconnectKey() []byte { return []byte(s.key + "_last_disconnect") }
+func (s *store) connectedKey() []byte      { return []byte(s.key + "_connected") }
+
+// LastDisconnect returns the last unexpected disconnection, nil if none was
+// recorded.
+func (s *store) LastDisconnect() (*disconnection, error) {
+	var last *disconnection
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		value := b.Get(s.lastDisconnectKey())
+		if value == nil {
+			return nil
+		}
+		last = &disconnection{}
+		return json.Unmarshal(value, last)
+	})
+	return last, err
+}
+
+// SetLastDisconnect saves the last unexpected disconnection. It is not a
+// configuration change, so the database is not backed up.
+func (s *store) SetLastDisconnect(last disconnection) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		value, _ := json.Marshal(last)
+		return b.Put(s.lastDisconnectKey(), value)
+	})
+}
+
+// setConnected records that the dome is connected since a time, or is
+// disconnected for a zero time.
+func (s *store) setConnected(since time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		if since.IsZero() {
+			return b.Delete(s.connectedKey())
+		}
+		value, _ := json.Marshal(since)
+		return b.Put(s.connectedKey(), value)
+	})
+}
+
+// checkCrash records a crash when the previous run of the server stopped
+// while the dome was connected, without disconnecting it, and clears the
+// record of that connection.
+func (s *store) checkCrash(now time.Time) (*disconnection, error) {
+	var since *time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		if value := b.Get(s.connectedKey()); value != nil {
+			since = &time.Time{}
+			return json.Unmarshal(value, since)
+		}
+		return nil
+	})
+	if err != nil || since == nil {
+		return nil, err
+	}
+
+	last := disconnection{
+		Time:    now,
+		Cause:   causeCrash,
+		Message: fmt.Sprintf("Server stopped while the dome was connected, since %s", since.Format(time.RFC3339)),
+	}
+	if err := s.SetLastDisconnect(last); err != nil {
+		return nil, err
+	}
+	return &last, s.setConnected(time.Time{})
+}
+
+// recordLost saves the loss of the MQTT connection as the last unexpected
+// disconnection. The client reconnects by itself meanwhile.
+func (d *Driver) recordLost(err error) {
+	last := disconnection{
+		Time:    time.Now(),
+		Cause:   disconnectCause(err),
+		Message: fmt.Sprintf("Lost connection to MQTT broker: %v", err),
+	}
+	if err := d.store.SetLastDisconnect(last); err != nil {
+		d.logger.Warnf("Failed to save the last disconnection: %v", err)
+	}
+}
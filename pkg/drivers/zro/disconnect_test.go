@@ -0,0 +1,73 @@
+package zro
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisconnectCause(t *testing.T) {
+	assert.Equal(t, causeNetwork, disconnectCause(fmt.Errorf("pingresp not received, disconnecting")))
+	assert.Equal(t, causeNetwork, disconnectCause(fmt.Errorf("read tcp: %w", os.ErrDeadlineExceeded)))
+	assert.Equal(t, causeNetwork, disconnectCause(fmt.Errorf("dial tcp: %w", syscall.EHOSTUNREACH)))
+	assert.Equal(t, causeBroker, disconnectCause(fmt.Errorf("EOF")))
+	assert.Equal(t, causeBroker, disconnectCause(fmt.Errorf("read tcp: %w", syscall.ECONNRESET)))
+}
+
+func TestLastDisconnectAfterCrash(t *testing.T) {
+	db := openTestDB(t)
+
+	d, err := NewDriver(1, db, nil, log.New())
+	require.NoError(t, err)
+	last, err := d.store.LastDisconnect()
+	require.NoError(t, err)
+	assert.Nil(t, last)
+
+	// The server stops without disconnecting the dome.
+	require.NoError(t, d.store.setConnected(time.Now()))
+
+	d, err = NewDriver(1, db, nil, log.New())
+	require.NoError(t, err)
+	last, err = d.store.LastDisconnect()
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	assert.Equal(t, causeCrash, last.Cause)
+
+	// Once recorded, the crash is not recorded again on the next start.
+	require.NoError(t, d.store.SetLastDisconnect(disconnection{Time: time.Now(), Cause: causeBroker}))
+	d, err = NewDriver(1, db, nil, log.New())
+	require.NoError(t, err)
+	last, err = d.store.LastDisconnect()
+	require.NoError(t, err)
+	assert.Equal(t, causeBroker, last.Cause)
+}
+
+func TestLastDisconnectState(t *testing.T) {
+	d := newConnectedDriver(t)
+	require.NoError(t, d.store.setConnected(time.Now()))
+
+	state := func() map[string]any {
+		props := make(map[string]any)
+		for _, p := range d.GetState() {
+			props[p.Name] = p.Value
+		}
+		return props
+	}
+	assert.NotContains(t, state(), "LastDisconnectCause")
+
+	d.recordLost(fmt.Errorf("pingresp not received, disconnecting"))
+	assert.Equal(t, causeNetwork, state()["LastDisconnectCause"])
+	assert.NotEmpty(t, state()["LastDisconnectTime"])
+
+	// A disconnection asked by a client is not a crash on the next start.
+	require.NoError(t, d.Disconnect())
+	crash, err := d.store.checkCrash(time.Now())
+	require.NoError(t, err)
+	assert.Nil(t, crash)
+}
@@ -2,13 +2,21 @@ package zro
 
 import (
 	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
 	"alpaca/pkg/dome"
+	"alpaca/pkg/metrics"
+	"cmp"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"html/template"
 	"math"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -17,7 +25,6 @@ import (
 )
 
 const (
-	// TODO: domeUID should be unique for each device.
 	domeUID       = "0a0af300-b0fc-4178-b758-caa109fc836f"
 	deviceName    = "ZRO Dome"
 	deviceType    = "Dome"
@@ -25,6 +32,23 @@ const (
 	driverVersion = "1.0"
 )
 
+// Custom actions supported by the driver.
+const (
+	actionRawCommand       = "RawCommand"       // Send a raw command to the controller
+	actionShutdownSequence = "ShutdownSequence" // Close the shutter and park the dome
+	actionEmergencyStop    = "EmergencyStop"    // Stop the dome and the shutter, and end the slaving
+	actionReadBattery      = "ReadBattery"      // Read the shutter battery voltage and current
+	actionPauseSlaving     = "PauseSlaving"     // Pause the slaving, optionally for a duration
+	actionResumeSlaving    = "ResumeSlaving"    // Resume a paused slaving
+
+	actionAcknowledgeRunaway = "AcknowledgeRunaway" // Allow new slews after a runaway slew was aborted
+
+	actionListPresets  = "ListPresets"  // List the azimuth presets
+	actionSlewToPreset = "SlewToPreset" // Slew to an azimuth preset, by name
+	actionSetPreset    = "SetPreset"    // Save an azimuth preset, as name=azimuth or name for the current azimuth
+	actionDeletePreset = "DeletePreset" // Delete an azimuth preset, by name
+)
+
 type connState int
 
 const (
@@ -34,14 +58,26 @@ const (
 )
 
 // createMQTTClient initializes and returns a new MQTT client using the configuration
-// retrieved from the provided alpaca.Store. It allows overriding the MQTT broker,
-// username, and password via CLI context flags.
-func createMQTTClient(cfg dome.MQTTConfig) (mqtt.Client, error) {
+// retrieved from the provided alpaca.Store. The brokers are tried in order, on
+// connect and on every reconnection, and status follows the one in use. The
+// lost function is called when the connection drops.
+func createMQTTClient(cfg dome.MQTTConfig, clientID string, status *brokerStatus, lost func(error)) (mqtt.Client, error) {
 	opts := mqtt.NewClientOptions()
-	opts.SetClientID("zro-alpaca")
-	opts.AddBroker(cfg.Host)
+	opts.SetClientID(clientID)
+	for _, broker := range cfg.Brokers() {
+		opts.AddBroker(broker)
+	}
 	opts.SetUsername(cfg.Username)
 	opts.SetPassword(cfg.Password)
+	opts.SetConnectionAttemptHandler(func(broker *url.URL, tlsCfg *tls.Config) *tls.Config {
+		status.attempting(broker.String())
+		return tlsCfg
+	})
+	opts.SetOnConnectHandler(func(mqtt.Client) { status.onConnect() })
+	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
+		status.onConnectionLost(err)
+		lost(err)
+	})
 
 	mqttClient := mqtt.NewClient(opts)
 	if token := mqttClient.Connect(); token.Wait() && token.Error() != nil {
@@ -51,93 +87,253 @@ func createMQTTClient(cfg dome.MQTTConfig) (mqtt.Client, error) {
 }
 
 // Driver represents the ZRO dome Alpaca driver.
+// Its methods are called concurrently from the HTTP handlers, so the mutable
+// fields below mu must only be accessed while holding it.
 type Driver struct {
+	*driverState
+	ctx context.Context // Context of the Alpaca request served, whose span the commands follow, if set
+}
+
+// driverState is the state of a dome instance, shared by the drivers bound to
+// the contexts of the requests.
+type driverState struct {
 	number int                // Driver number
+	name   string             // Device name, also the device of the published events
+	uid    string             // Unique ID of the device
 	store  *store             // Configuration store
 	tmpl   *template.Template // HTML template for rendering the setup form
-	state  connState          // Connection state
-	slaved bool               // Slaved state
 	logger log.FieldLogger
 
+	mu     sync.RWMutex
+	state  connState // Connection state
+	slaved bool      // Slaved state
+
 	// The MQTT client and the controller are created when the driver is connected
 	client mqtt.Client        // MQTT client
 	dome   *dome.Dome         // ZRO dome controller
 	cancel context.CancelFunc // Context cancel function
+	pause  *slavingPause      // Slaving pause requested with an action
+
+	arbiter   *arbiter               // Owner of the dome motion
+	histogram *dome.AzimuthHistogram // Time spent slewing through each azimuth sector, kept across connections
+	slewLog   *dome.SlewLog          // Commanded and achieved azimuths of the slews, kept across connections
+	watchdog  *watchdog              // Runaway slew detection
+	current   *currentBaseline       // Shutter motor current, kept across connections
+	interlock *interlock             // Azimuth motion held while the shutter moves
+	drift     *drift                 // Motion since the last home search
+	broker    *brokerStatus          // MQTT broker in use, kept across connections
+
+	parkingToClose atomic.Bool // True while the dome parks before closing the shutter
 }
 
 func NewDriver(number int, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*Driver, error) {
-	store, err := NewStore(db)
+	return New(alpaca.DeviceConfig{Number: number}, db, tmpl, logger)
+}
+
+// New creates the driver of a configured dome instance. Each instance keeps
+// its settings under its own key; the default key is used when none is set.
+func New(dev alpaca.DeviceConfig, db *bolt.DB, tmpl *template.Template, logger log.FieldLogger) (*Driver, error) {
+	key := dev.Key
+	if key == "" {
+		key = configKey
+	}
+
+	store, err := NewStoreWithKey(db, key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create store: %v", err)
 	}
 
-	driver := Driver{
-		number: number,
-		tmpl:   tmpl,
-		store:  store,
-		state:  connStateDisconnected,
-		logger: logger,
+	if last, err := store.checkCrash(time.Now()); err != nil {
+		logger.Warnf("Failed to check the last disconnection: %v", err)
+	} else if last != nil {
+		logger.Warn(last.Message)
 	}
 
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(domeUID, dev.Key)
+	}
+
+	name := instanceName(dev.Number)
+	driver := Driver{driverState: &driverState{
+		number:    dev.Number,
+		name:      name,
+		uid:       uid,
+		tmpl:      tmpl,
+		store:     store,
+		state:     connStateDisconnected,
+		logger:    logger,
+		arbiter:   newArbiter(logger),
+		histogram: dome.NewAzimuthHistogram(),
+		slewLog:   dome.NewSlewLog(),
+		watchdog:  &watchdog{},
+		current:   newCurrentBaseline(),
+		interlock: &interlock{},
+		drift:     &drift{},
+		broker:    &brokerStatus{device: name},
+	}}
+
 	return &driver, nil
 }
 
-func (d *Driver) Close() {
-	d.logger.Info("Closing ZRO driver")
+// instanceName returns the name of the dome with the given number. The first
+// dome keeps the plain name; the others carry their number, so the events,
+// notifications and connection history of several domes are kept apart.
+func instanceName(number int) string {
+	if number == 0 {
+		return deviceName
+	}
+	return fmt.Sprintf("%s %d", deviceName, number)
+}
+
+// Shutdown disconnects the driver from the broker.
+func (d *Driver) Shutdown(ctx context.Context) error {
+	d.logger.Info("Shutting down ZRO driver")
 
-	if d.state == connStateDisconnected {
-		if d.cancel != nil {
-			d.cancel()
-			d.cancel = nil
+	done := make(chan error, 1)
+	go func() {
+		done <- d.Disconnect()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && !errors.Is(err, errors.ErrNotConnected) {
+			return fmt.Errorf("failed to disconnect: %v", err)
 		}
-		return
-	}
-	if err := d.Disconnect(); err != nil {
-		d.logger.Errorf("failed to disconnect: %v", err)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to disconnect: %v", ctx.Err())
 	}
 }
 
+// Connect starts connecting to the broker and the controller in the
+// background, and returns at once. Connecting is true until the MQTT session
+// is up and the shutter linked; a failure leaves Connected false and is
+// logged and published as an error event.
 func (d *Driver) Connect() error {
 	config, err := d.store.GetConfig()
 	if err != nil {
 		return fmt.Errorf("failed to get dome config: %v", err)
 	}
 
-	if d.state != connStateDisconnected {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch d.state {
+	case connStateConnecting:
+		return nil
+	case connStateConnected:
 		return fmt.Errorf("driver is already connected")
 	}
 
+	// Disconnect cancels the attempt.
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
 	d.state = connStateConnecting
+	d.logger.Infof("Connecting to MQTT broker %s", strings.Join(config.Brokers(), ", "))
 
-	client, err := createMQTTClient(config.MQTTConfig)
-	if err != nil {
-		return fmt.Errorf("failed to create MQTT client: %v", err)
+	go d.connect(ctx, config)
+	return nil
+}
+
+// connect establishes the connection started by Connect. The lock is not held
+// meanwhile so that the status polls are not blocked.
+func (d *Driver) connect(ctx context.Context, config Config) {
+	client, ctrl, err := d.dial(config)
+
+	d.mu.Lock()
+	if ctx.Err() != nil {
+		// Disconnected, or connected again, meanwhile: the cancelled context
+		// makes Serve stop the controller at once.
+		d.mu.Unlock()
+		if err == nil {
+			ctrl.Serve(ctx)
+			client.Disconnect(100)
+		}
+		return
 	}
+	defer d.mu.Unlock()
 
-	d.client = client
-	d.dome, err = dome.NewDome(client, config, d.logger)
 	if err != nil {
-		d.client.Disconnect(100)
+		d.cancel()
+		d.cancel = nil
 		d.state = connStateDisconnected
-		return fmt.Errorf("failed to create ZRO dome controller: %v", err)
+		d.logger.Errorf("Failed to connect: %v", err)
+		alpaca.Publish(alpaca.Event{
+			Type:    alpaca.EventError,
+			Device:  d.name,
+			Message: fmt.Sprintf("Failed to connect: %v", err),
+		})
+		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	d.cancel = cancel
-	go func() {
-		d.dome.Run(ctx)
-	}()
+	go ctrl.Serve(ctx)
+	go d.monitor(ctx, ctrl)
+	go d.slave(ctx, ctrl)
 
+	d.client = client
+	d.dome = ctrl
 	d.state = connStateConnected
+	if err := d.store.setConnected(time.Now()); err != nil {
+		d.logger.Warnf("Failed to save the connection: %v", err)
+	}
+
+	broker := config.Host
+	if info := d.broker.info(); info != nil {
+		broker = info.Current
+	}
+	d.logger.Infof("Connected to MQTT broker %s", broker)
+	alpaca.Publish(alpaca.Event{
+		Type:    alpaca.EventConnected,
+		Device:  d.name,
+		Message: fmt.Sprintf("Connected to MQTT broker %s", broker),
+	})
+}
 
-	d.logger.Info("Connected to MQTT broker")
+// dial connects to the broker and starts the dome controller.
+func (d *Driver) dial(config Config) (mqtt.Client, *dome.Dome, error) {
+	client, err := createMQTTClient(config.MQTTConfig, d.mqttClientID(), d.broker, d.recordLost)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create MQTT client: %v", err)
+	}
 
-	return nil
+	ctrl, err := dome.NewDome(client, config.Config, d.logger)
+	if err != nil {
+		client.Disconnect(100)
+		return nil, nil, fmt.Errorf("failed to create ZRO dome controller: %v", err)
+	}
+	ctrl.SetAzimuthHistogram(d.histogram)
+	ctrl.SetSlewLog(d.slewLog)
+
+	if err := ctrl.Start(); err != nil {
+		client.Disconnect(100)
+		return nil, nil, fmt.Errorf("failed to start ZRO dome controller: %v", err)
+	}
+	return client, ctrl, nil
+}
+
+// mqttClientID returns the MQTT client ID of the instance. Additional
+// instances need their own, or the broker would disconnect one of them.
+func (d *Driver) mqttClientID() string {
+	if d.store.key == configKey {
+		return "zro-alpaca"
+	}
+	return "zro-alpaca-" + d.store.key
 }
 
 func (d *Driver) Disconnect() error {
-	if d.state != connStateConnected {
-		return dome.ErrNotConnected
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch d.state {
+	case connStateDisconnected:
+		return errors.ErrNotConnected
+	case connStateConnecting:
+		d.cancel()
+		d.cancel = nil
+		d.state = connStateDisconnected
+		d.logger.Info("Connection attempt cancelled")
+		return nil
 	}
 
 	if d.cancel != nil {
@@ -145,55 +341,274 @@ func (d *Driver) Disconnect() error {
 		d.cancel = nil
 	}
 	d.client.Disconnect(100)
+	d.client = nil
+	d.dome = nil
+	d.broker.lost()
 	d.state = connStateDisconnected
+	if err := d.store.setConnected(time.Time{}); err != nil {
+		d.logger.Warnf("Failed to save the disconnection: %v", err)
+	}
 	d.logger.Info("Disconnected from MQTT broker")
+	alpaca.Publish(alpaca.Event{
+		Type:    alpaca.EventDisconnected,
+		Device:  d.name,
+		Message: "Disconnected from MQTT broker",
+	})
 	return nil
 }
 
+// Restart tears the driver down, closing its MQTT client and stopping the
+// controller, then initializes it again with the stored settings, such as
+// after a change of the broker settings, without restarting the server. The
+// held motions and the motion in progress are forgotten; a runaway slew
+// still has to be acknowledged. A driver that was connected, or connecting,
+// connects again in the background.
+func (d *Driver) Restart() error {
+	d.mu.RLock()
+	reconnect := d.state != connStateDisconnected
+	d.mu.RUnlock()
+
+	if reconnect {
+		if err := d.Disconnect(); err != nil && !errors.Is(err, errors.ErrNotConnected) {
+			return fmt.Errorf("failed to disconnect: %v", err)
+		}
+	}
+	d.parkingToClose.Store(false)
+	if m := d.interlock.take(); m != nil {
+		d.logger.Infof("Shutter interlock: held %s cancelled", m.what)
+	}
+	d.arbiter.release()
+
+	d.logger.Info("Driver restarted")
+	if !reconnect {
+		return nil
+	}
+	return d.Connect()
+}
+
+// controller returns the dome controller if the driver is connected.
+// The controller is safe for concurrent use, so it can be used after the lock
+// is released.
+func (d *Driver) controller() (*dome.Dome, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.state != connStateConnected {
+		return nil, errors.ErrNotConnected
+	}
+	if d.ctx != nil {
+		return d.dome.WithContext(d.ctx), nil
+	}
+	return d.dome, nil
+}
+
+// WithContext returns the driver serving the Alpaca request of ctx: the
+// commands it sends to the controller are traced under the span of the
+// request.
+func (d *Driver) WithContext(ctx context.Context) alpaca.Device {
+	return &Driver{driverState: d.driverState, ctx: ctx}
+}
+
+// deviceError gives the errors of the dome controller the number of their
+// Alpaca error, so the clients can tell a rejected command from a failure.
+func deviceError(err error) error {
+	switch {
+	case errors.Is(err, dome.ErrNotConnected):
+		return errors.Wrap(errors.ErrNotConnected, err)
+	case errors.Is(err, dome.ErrCommandRejected):
+		return errors.Wrap(errors.ErrInvalidOperation, err)
+	case errors.Is(err, dome.ErrRawCommandNotAllowed):
+		return errors.Wrap(errors.ErrInvalidValue, err)
+	}
+	return err
+}
+
 func (d *Driver) Connecting() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	return d.state == connStateConnecting
 }
 
 func (d *Driver) Connected() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
 	return d.state == connStateConnected
 }
 
+// Disabled reports whether the dome is disabled in its setup page.
+func (d *Driver) Disabled() bool {
+	cfg, err := d.store.GetConfig()
+	return err == nil && cfg.Disabled
+}
+
+// ConnectOnStartup reports whether the dome is connected when the server
+// starts, so it is back after a power cut without a client.
+func (d *Driver) ConnectOnStartup() bool {
+	cfg, err := d.store.GetConfig()
+	return err == nil && cfg.ConnectOnStartup
+}
+
+// GetState returns the device state, time stamped with the last telemetry
+// received from the controller, so a stalled controller shows as stale data.
 func (d *Driver) GetState() []alpaca.StateProperty {
-	props := []alpaca.StateProperty{
-		{
-			Name:  "TimeStamp",
-			Value: time.Now().Format(time.RFC3339),
-		},
+	ctrl, err := d.controller()
+	if err != nil {
+		return []alpaca.StateProperty{alpaca.StateTimeStamp(time.Now())}
+	}
+
+	received := ctrl.GetStatus().TelemetryTime
+	if received.IsZero() {
+		received = time.Now()
+	}
+	props := []alpaca.StateProperty{alpaca.StateTimeStamp(received)}
+
+	props = append(props, d.Status().ToProperties()...)
+
+	// The Alpaca shutter status cannot tell a user abort from a fault,
+	// so the controller state is reported as well.
+	props = append(props, alpaca.StateProperty{
+		Name:  "ZROShutterState",
+		Value: ctrl.GetStatus().Shutter.String(),
+	})
+
+	// Whether the shutter controller is linked, when the dome has one.
+	if ctrl.Config().UseShutter {
+		props = append(props, alpaca.StateProperty{
+			Name:  "ShutterLink",
+			Value: ctrl.GetStatus().ShutterConnected,
+		})
+	}
+
+	// Why the dome is moving: idle, manual, homing, slaving or safety.
+	props = append(props, alpaca.StateProperty{
+		Name:  "MotionSource",
+		Value: d.arbiter.current().String(),
+	})
+
+	// True after a runaway slew was aborted, until it is acknowledged.
+	props = append(props, alpaca.StateProperty{
+		Name:  "RunawaySlew",
+		Value: d.watchdog.tripped(),
+	})
+
+	// The time left in the commanded slew, for countdowns.
+	props = append(props, alpaca.StateProperty{
+		Name:  "SlewTimeRemaining",
+		Value: alpaca.SlewSeconds(d.SlewTimeRemaining()),
+	})
+
+	// The last unexpected disconnection, kept across restarts.
+	if last, err := d.store.LastDisconnect(); err == nil && last != nil {
+		props = append(props,
+			alpaca.StateProperty{Name: "LastDisconnectTime", Value: last.Time.UTC().Format(time.RFC3339)},
+			alpaca.StateProperty{Name: "LastDisconnectCause", Value: last.Cause},
+		)
 	}
 
-	if d.state == connStateConnected {
-		props = append(props, d.Status().ToProperties()...)
+	// The motion since the last home search, to follow the drift.
+	slews, rotation := d.drift.counts()
+	props = append(props,
+		alpaca.StateProperty{Name: "SlewsSinceHome", Value: slews},
+		alpaca.StateProperty{Name: "RotationSinceHome", Value: math.Round(rotation)},
+	)
+
+	// The MQTT broker in use, and how often the client failed over.
+	if info := d.broker.info(); info != nil {
+		props = append(props,
+			alpaca.StateProperty{Name: "MQTTBroker", Value: info.Current},
+			alpaca.StateProperty{Name: "BrokerFailovers", Value: info.Failovers},
+		)
 	}
 
 	return props
 }
 
+// Metrics returns the gauges of the controller for Prometheus: the age of
+// its telemetry, the controller shutter state, which tells an aborted
+// shutter apart, and the shutter battery voltage.
+func (d *Driver) Metrics() []metrics.Gauge {
+	ctrl, err := d.controller()
+	if err != nil {
+		return nil
+	}
+	status := ctrl.GetStatus()
+	device := []metrics.Label{{Name: "device", Value: fmt.Sprintf("%s/%d", strings.ToLower(deviceType), d.number)}}
+
+	gauges := []metrics.Gauge{{
+		Name:   "zro_shutter_state",
+		Help:   "Shutter state of the ZRO controller: 0 closed, 1 opening, 2 open, 3 closing, 4 aborted, 5 error.",
+		Labels: device,
+		Value:  float64(status.Shutter),
+	}}
+	if !status.TelemetryTime.IsZero() {
+		gauges = append(gauges, metrics.Gauge{
+			Name:   "zro_telemetry_age_seconds",
+			Help:   "Seconds since the last telemetry of the ZRO controller.",
+			Labels: device,
+			Value:  time.Since(status.TelemetryTime).Seconds(),
+		})
+	}
+	if ctrl.Config().UseShutter && status.BatteryVoltage != 0 {
+		gauges = append(gauges, metrics.Gauge{
+			Name:   "zro_shutter_battery_volts",
+			Help:   "Last shutter battery voltage read from the ZRO controller.",
+			Labels: device,
+			Value:  float64(status.BatteryVoltage),
+		})
+	}
+	return gauges
+}
+
 func (d *Driver) Status() alpaca.DomeStatus {
-	if d.state != connStateConnected {
+	d.mu.RLock()
+	ctrl, slaved := d.dome, d.slaved
+	connected := d.state == connStateConnected
+	d.mu.RUnlock()
+
+	if !connected {
 		return alpaca.DomeStatus{}
 	}
 
-	st := d.dome.GetStatus()
-
+	st := ctrl.GetStatus()
+
+	// A motion held by the shutter interlock is reported as started, and the
+	// shutter as closing while the dome parks before closing it. Otherwise,
+	// without a link to the shutter controller, the last shutter state is
+	// stale and reported as an error.
+	shutter := d.convertShutterStatus(st.Shutter, d.abortedMapping())
+	switch {
+	case d.parkingToClose.Load():
+		shutter = alpaca.ShutterClosing
+	case ctrl.Config().UseShutter && !st.ShutterConnected:
+		shutter = alpaca.ShutterError
+	}
 	status := alpaca.DomeStatus{
-		Azimuth:  d.dome.TicksToDegrees(st.Position),
+		Azimuth:  ctrl.TicksToDegrees(st.Position),
 		AtHome:   st.AtHome,
 		AtPark:   st.AtHome, // TODO: Implement park status
-		Slewing:  st.Slewing,
-		Slaved:   d.slaved,
+		Slewing:  st.Slewing || d.interlock.pending(),
+		Slaved:   slaved,
 		Altitude: 0.0,
-		Shutter:  d.convertShutterStatus(st.Shutter),
+		Shutter:  shutter,
 	}
 	return status
 }
 
-// convertShutterStatus converts ZRO ShutterStatus to Alpaca ShutterStatus
-func (d *Driver) convertShutterStatus(zroStatus dome.ShutterStatus) alpaca.ShutterStatus {
+// abortedMapping returns the configured mapping of an aborted shutter.
+func (d *Driver) abortedMapping() string {
+	if cfg, err := d.store.GetConfig(); err == nil && cfg.AbortedShutter != "" {
+		return cfg.AbortedShutter
+	}
+	return abortedAsError
+}
+
+// convertShutterStatus converts ZRO ShutterStatus to Alpaca ShutterStatus.
+// Alpaca has no aborted state, so an aborted shutter is reported according to
+// the aborted mapping: as an error by default, or as open since it has
+// stopped partially open.
+func (d *Driver) convertShutterStatus(zroStatus dome.ShutterStatus, aborted string) alpaca.ShutterStatus {
 	switch zroStatus {
 	case dome.ShutterStatusClosed:
 		return alpaca.ShutterClosed
@@ -204,6 +619,9 @@ func (d *Driver) convertShutterStatus(zroStatus dome.ShutterStatus) alpaca.Shutt
 	case dome.ShutterStatusClosing:
 		return alpaca.ShutterClosing
 	case dome.ShutterStatusAborted:
+		if aborted == abortedAsOpen {
+			return alpaca.ShutterOpen
+		}
 		return alpaca.ShutterError
 	case dome.ShutterStatusError:
 		return alpaca.ShutterError
@@ -214,10 +632,11 @@ func (d *Driver) convertShutterStatus(zroStatus dome.ShutterStatus) alpaca.Shutt
 }
 
 func (d *Driver) Capabilities() alpaca.DomeCapabilities {
-	// Get the configuration to check if shutter is enabled
-	canSetShutter := false
+	// Get the configuration to check if shutter and slaving are enabled
+	canSetShutter, canSlave := false, false
 	if cfg, err := d.store.GetConfig(); err == nil {
 		canSetShutter = cfg.UseShutter
+		canSlave = cfg.Slaving && cfg.TelescopeURL != ""
 	}
 
 	return alpaca.DomeCapabilities{
@@ -227,20 +646,40 @@ func (d *Driver) Capabilities() alpaca.DomeCapabilities {
 		CanSetAzimuth:  true,
 		CanSetPark:     true,
 		CanSetShutter:  canSetShutter,
-		CanSlave:       true,
+		CanSlave:       canSlave,
 		CanSyncAzimuth: false,
 	}
 }
 
 func (d *Driver) DeviceInfo() alpaca.DeviceInfo {
 	return alpaca.DeviceInfo{
-		Name:     deviceName,
-		Type:     deviceType,
-		Number:   d.number,
-		UniqueID: domeUID,
+		Name:        d.name,
+		Description: d.description(),
+		Type:        deviceType,
+		Number:      d.number,
+		UniqueID:    d.uid,
 	}
 }
 
+// description returns the configured description with the firmware version
+// of the connected controller, or "unknown" before it has been read.
+func (d *Driver) description() string {
+	format := defaultDescription
+	if cfg, err := d.store.GetConfig(); err == nil && cfg.Description != "" {
+		format = cfg.Description
+	}
+
+	firmware := "unknown"
+	if ctrl, err := d.controller(); err == nil && ctrl.GetStatus().Version != "" {
+		firmware = ctrl.GetStatus().Version
+	}
+
+	return alpaca.ExpandDescription(format, map[string]string{
+		"firmware": firmware,
+		"driver":   driverVersion,
+	})
+}
+
 func (d *Driver) DriverInfo() alpaca.DriverInfo {
 	return alpaca.DriverInfo{
 		Name:             driverName,
@@ -249,62 +688,181 @@ func (d *Driver) DriverInfo() alpaca.DriverInfo {
 	}
 }
 
+func (d *Driver) SupportedActions() []string {
+	return []string{
+		actionRawCommand,
+		actionShutdownSequence,
+		actionEmergencyStop,
+		actionReadBattery,
+		actionPauseSlaving,
+		actionResumeSlaving,
+		actionAcknowledgeRunaway,
+		actionListPresets,
+		actionSlewToPreset,
+		actionSetPreset,
+		actionDeletePreset,
+	}
+}
+
+func (d *Driver) Action(name, parameters string) (string, error) {
+	switch name {
+	case actionRawCommand:
+		return d.rawCommand(parameters)
+	case actionShutdownSequence:
+		return d.shutdownSequence()
+	case actionEmergencyStop:
+		return d.emergencyStop()
+	case actionReadBattery:
+		return d.readBattery()
+	case actionPauseSlaving:
+		return d.pauseSlaving(parameters)
+	case actionResumeSlaving:
+		return d.resumeSlaving()
+	case actionAcknowledgeRunaway:
+		return d.acknowledgeRunaway()
+	case actionListPresets:
+		return d.listPresets()
+	case actionSlewToPreset:
+		return d.slewToPreset(parameters)
+	case actionSetPreset:
+		return d.savePreset(parameters)
+	case actionDeletePreset:
+		return d.removePreset(parameters)
+	default:
+		return "", errors.ErrActionNotImplemented
+	}
+}
+
 func (d *Driver) SlewToAzimuth(az float64) error {
-	if d.state != connStateConnected {
-		return dome.ErrNotConnected
+	ctrl, err := d.controller()
+	if err != nil {
+		return err
 	}
 
-	return d.dome.SlewToAzimuth(az)
+	from := ctrl.TicksToDegrees(ctrl.GetStatus().Position)
+	ticks := azimuthTicks(ctrl.Config(), from, az)
+	what := fmt.Sprintf("slew to %.1f degrees", az)
+	if err := d.startManualMotion(ctrl, motionManual, what, ticks); err != nil {
+		return err
+	}
+
+	return d.move(ctrl, queuedMotion{
+		what:     what,
+		expected: slewDuration(ctrl.Config(), ticks),
+		run:      func() error { return ctrl.SlewToAzimuth(az) },
+	})
 }
 
 func (d *Driver) SyncToAzimuth(azimuth float64) error {
-	if d.state != connStateConnected {
-		return alpaca.ErrNotConnected
+	if !d.Connected() {
+		return errors.ErrNotConnected
 	}
 	d.logger.Warn("SyncToAzimuth not implemented")
 	return nil
 }
 
 func (d *Driver) SlewToAltitude(altitude float64) error {
-	return alpaca.ErrPropertyNotImplemented
+	return errors.ErrNotImplemented
 }
 
 func (d *Driver) SyncToAltitude(altitude float64) error {
-	return alpaca.ErrPropertyNotImplemented
+	return errors.ErrNotImplemented
 }
 
 func (d *Driver) AbortSlew() error {
-	if d.state != connStateConnected {
-		return dome.ErrNotConnected
+	ctrl, err := d.controller()
+	if err != nil {
+		return err
 	}
 
-	return d.dome.AbortSlew()
+	d.parkingToClose.Store(false)
+	if m := d.interlock.take(); m != nil {
+		d.logger.Infof("Shutter interlock: held %s cancelled", m.what)
+	}
+	d.arbiter.release()
+	return deviceError(ctrl.AbortSlew())
 }
 
 func (d *Driver) FindHome() error {
-	if d.state != connStateConnected {
-		return dome.ErrNotConnected
+	ctrl, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	// The home search may take a whole turn.
+	ticks := ctrl.Config().TicksPerTurn
+	if err := d.startManualMotion(ctrl, motionHoming, "find home", ticks); err != nil {
+		return err
 	}
 
-	return d.dome.FindHome()
+	return d.move(ctrl, queuedMotion{
+		what:     "home search",
+		expected: slewDuration(ctrl.Config(), ticks),
+		run:      ctrl.FindHome,
+	})
 }
 
 func (d *Driver) Park() error {
-	if d.state != connStateConnected {
-		return dome.ErrNotConnected
+	ctrl, err := d.controller()
+	if err != nil {
+		return err
+	}
+
+	ticks := ctrl.Config().TicksPerTurn / 2
+	if err := d.startManualMotion(ctrl, motionManual, "park", ticks); err != nil {
+		return err
 	}
 
-	return d.dome.Park()
+	return d.move(ctrl, queuedMotion{
+		what:     "park",
+		expected: slewDuration(ctrl.Config(), ticks),
+		run:      ctrl.Park,
+	})
+}
+
+// startManualMotion checks that a client may move the dome and gives it the
+// ownership of the motion, expected to cover the given encoder ticks. Manual
+// motions are rejected while the dome is slaved, unless the slaving is paused
+// for a manual recovery, and after a runaway slew until it is acknowledged.
+func (d *Driver) startManualMotion(ctrl *dome.Dome, source motionSource, what string, ticks int) error {
+	d.mu.RLock()
+	slaved := d.slaved
+	d.mu.RUnlock()
+
+	if slaved {
+		cfg, _ := d.store.GetConfig()
+		if d.slavingPausedBy(cfg, time.Now()) == "" {
+			return errors.ErrSlaved
+		}
+	}
+	return d.startMotion(ctrl, source, what, ticks)
+}
+
+// startMotion gives the ownership of the motion to the source, unless a
+// runaway slew waits for an acknowledgment, and arms the runaway watchdog.
+func (d *Driver) startMotion(ctrl *dome.Dome, source motionSource, what string, ticks int) error {
+	if err := d.watchdog.allow(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	d.arbiter.settle(ctrl.GetStatus().Slewing, now)
+	if err := d.arbiter.acquire(source, what, now); err != nil {
+		return err
+	}
+	d.watchdog.expect(slewDuration(ctrl.Config(), ticks), now)
+	return nil
 }
 
 func (d *Driver) SetPark() error {
-	if d.state != connStateConnected {
-		return dome.ErrNotConnected
+	ctrl, err := d.controller()
+	if err != nil {
+		return err
 	}
 
 	// Get current dome position
-	status := d.dome.GetStatus()
-	currentAzimuth := math.Round(d.dome.TicksToDegrees(status.Position))
+	status := ctrl.GetStatus()
+	currentAzimuth := math.Round(ctrl.TicksToDegrees(status.Position))
 
 	// Get current config and update park position
 	cfg, err := d.store.GetConfig()
@@ -320,30 +878,39 @@ func (d *Driver) SetPark() error {
 	}
 
 	d.logger.Infof("Park position set to %.2f degrees", currentAzimuth)
-	return d.dome.SetPark()
+	return deviceError(ctrl.SetPark())
 }
 
 func (d *Driver) SetSlaved(slaved bool) error {
+	if slaved && !d.Capabilities().CanSlave {
+		return errors.ErrNotImplemented
+	}
 	d.logger.Infof("Dome slaved: %v", slaved)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
 	d.slaved = slaved
 	return nil
 }
 
 func (d *Driver) SetShutter(command alpaca.ShutterCommand) error {
-	if d.state != connStateConnected {
-		return dome.ErrNotConnected
+	ctrl, err := d.controller()
+	if err != nil {
+		return err
 	}
 
-	var cmd dome.ShutterCommand
 	switch command {
 	case alpaca.ShutterCommandOpen:
-		cmd = dome.ShutterOpen
+		if d.parkingToClose.Swap(false) {
+			d.logger.Info("Park on shutter: closing cancelled by an open command")
+		}
+		return deviceError(ctrl.SetShutter(dome.ShutterOpen))
 	case alpaca.ShutterCommandClose:
-		cmd = dome.ShutterClose
+		return deviceError(d.closeShutter(ctrl))
 	default:
-		return fmt.Errorf("invalid shutter command: %v", command)
+		return errors.Errorf(errors.ErrInvalidValue, "invalid shutter command: %v", command)
 	}
-	return d.dome.SetShutter(cmd)
 }
 
 func (d *Driver) HandleSetup(w http.ResponseWriter, r *http.Request) {
@@ -357,13 +924,24 @@ func (d *Driver) HandleSetup(w http.ResponseWriter, r *http.Request) {
 		d.renderSetupForm(w, cfg, false, "")
 
 	case http.MethodPost:
+		current, err := d.store.GetConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.FormValue("preset-action") != "" {
+			d.handlePresetForm(w, r, current)
+			return
+		}
+
 		cfg, err := parseDomeSetupForm(r)
+		cfg.Presets = current.Presets
 		if err != nil {
 			d.renderSetupForm(w, cfg, false, err.Error())
 			return
 		}
 
-		d.logger.Infof("Setting dome config: %+v", cfg)
+		d.logger.Infof("Setting dome config: %+v", alpaca.Redact(cfg))
 		if err := d.store.SetConfig(cfg); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -376,12 +954,143 @@ func (d *Driver) HandleSetup(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (d *Driver) renderSetupForm(w http.ResponseWriter, cfg dome.Config, success bool, err string) {
+// AzimuthHistogram returns the time spent slewing through each azimuth
+// sector since the driver started.
+func (d *Driver) AzimuthHistogram() []alpaca.AzimuthBin {
+	var bins []alpaca.AzimuthBin
+	for _, b := range d.histogram.Bins() {
+		bins = append(bins, alpaca.AzimuthBin{
+			From:     b.From,
+			To:       b.To,
+			Seconds:  b.Time.Seconds(),
+			Passes:   b.Passes,
+			MeanPass: b.MeanPass().Seconds(),
+			Slow:     b.Slow,
+		})
+	}
+	return bins
+}
+
+// SlewHistory returns the slews since the driver started, with the azimuth
+// commanded and the one reached, oldest first.
+func (d *Driver) SlewHistory() []alpaca.SlewEntry {
+	var entries []alpaca.SlewEntry
+	for _, r := range d.slewLog.Records() {
+		entries = append(entries, alpaca.SlewEntry{
+			Time:     r.Time,
+			From:     r.From,
+			Target:   r.Target,
+			Achieved: r.Achieved,
+			Offset:   r.Offset(),
+			Duration: r.Duration.Seconds(),
+		})
+	}
+	return entries
+}
+
+// EstimateSlew returns the expected duration of a slew from the current
+// azimuth, at the mean speed of the recorded slews.
+func (d *Driver) EstimateSlew(azimuth float64) time.Duration {
+	ctrl, err := d.controller()
+	if err != nil {
+		return 0
+	}
+	from := ctrl.TicksToDegrees(ctrl.GetStatus().Position)
+	return slewEstimate(ctrl.Config(), alpaca.SlewAccuracy(d.SlewHistory()).MeanSpeed, from, azimuth)
+}
+
+// SlewTimeRemaining returns the expected time until the slew in progress
+// reaches its target, zero if none.
+func (d *Driver) SlewTimeRemaining() time.Duration {
+	slew, ok := d.slewLog.Pending()
+	if !ok {
+		return 0
+	}
+	return d.EstimateSlew(slew.Target)
+}
+
+// BatteryVoltage returns the voltage of the shutter battery, once read by
+// the controller.
+func (d *Driver) BatteryVoltage() (float64, bool) {
+	ctrl, err := d.controller()
+	if err != nil {
+		return 0, false
+	}
+	if cfg, err := d.store.GetConfig(); err != nil || !cfg.UseShutter {
+		return 0, false
+	}
+
+	// A zero voltage means the battery has not been read yet.
+	voltage := float64(ctrl.GetStatus().BatteryVoltage)
+	return voltage, voltage > 0
+}
+
+// histogramBar is a bar of the azimuth histogram chart of the setup page.
+type histogramBar struct {
+	alpaca.AzimuthBin
+	Height int // Height of the bar, in percent of the tallest one
+}
+
+// histogramChart returns the bars of the mean pass time of each sector, or
+// nil if the dome has not slewed yet.
+func (d *Driver) histogramChart() []histogramBar {
+	bins := d.AzimuthHistogram()
+
+	var highest float64
+	for _, b := range bins {
+		highest = max(highest, b.MeanPass)
+	}
+	if highest == 0 {
+		return nil
+	}
+
+	bars := make([]histogramBar, len(bins))
+	for i, b := range bins {
+		bars[i] = histogramBar{AzimuthBin: b, Height: int(math.Round(100 * b.MeanPass / highest))}
+	}
+	return bars
+}
+
+// formParam is a firmware parameter input of the setup page, generated from
+// the dome.Params schema.
+type formParam struct {
+	dome.Param
+	Value     string  // Value of the input
+	Checkbox  bool    // True for a flag
+	Azimuth   bool    // True for an azimuth, with a button to use the current one
+	InputStep float64 // Step of the input
+}
+
+// formParams returns the firmware parameter inputs of a configuration.
+func formParams(cfg Config) []formParam {
+	params := make([]formParam, len(dome.Params))
+	for i, p := range dome.Params {
+		params[i] = formParam{
+			Param:     p,
+			Value:     p.FormValue(cfg.Config),
+			Checkbox:  p.Kind == dome.ParamBool,
+			Azimuth:   p.Kind == dome.ParamAzimuth,
+			InputStep: cmp.Or(p.Step, 1),
+		}
+	}
+	return params
+}
+
+func (d *Driver) renderSetupForm(w http.ResponseWriter, cfg Config, success bool, err string) {
+	lastDisconnect, lastErr := d.store.LastDisconnect()
+	if lastErr != nil {
+		d.logger.Warnf("Failed to read the last disconnection: %v", lastErr)
+	}
+
 	data := struct {
-		dome.Config
-		Success bool
-		Error   string
-	}{cfg, success, err}
+		Config
+		Success        bool
+		Error          string
+		Histogram      []histogramBar
+		Params         []formParam
+		Broker         *brokerInfo
+		LastDisconnect *disconnection
+	}{cfg, success, err, d.histogramChart(), formParams(cfg), d.broker.info(), lastDisconnect}
 
 	if err := d.tmpl.ExecuteTemplate(w, "dome_zro_setup.html", data); err != nil {
 		http.Error(w, "Error rendering template", http.StatusInternalServerError)
@@ -389,31 +1098,116 @@ func (d *Driver) renderSetupForm(w http.ResponseWriter, cfg dome.Config, success
 	}
 }
 
-func parseDomeSetupForm(r *http.Request) (dome.Config, error) {
+func parseDomeSetupForm(r *http.Request) (Config, error) {
 	if err := r.ParseForm(); err != nil {
-		return dome.Config{}, fmt.Errorf("error parsing form: %v", err)
+		return Config{}, fmt.Errorf("error parsing form: %v", err)
 	}
 
-	cfg := dome.DefaultConfig()
-	cfg.Host = r.FormValue("mqtt-host")
+	cfg := DefaultConfig()
+	cfg.Host = strings.TrimSpace(r.FormValue("mqtt-host"))
+	cfg.Failover = parseBrokers(r.FormValue("mqtt-failover"))
 	cfg.Username = r.FormValue("mqtt-username")
 	cfg.Password = r.FormValue("mqtt-password")
 	cfg.TopicRoot = r.FormValue("mqtt-topic-root")
+	cfg.Description = strings.TrimSpace(r.FormValue("description"))
+	cfg.Disabled = r.FormValue("enabled") != "true"
+	cfg.ConnectOnStartup = r.FormValue("connect-on-startup") == "true"
 
-	cfg.TicksPerTurn, _ = strconv.Atoi(r.FormValue("ticks-per-turn"))
-	cfg.Tolerance, _ = strconv.Atoi(r.FormValue("tolerance"))
+	for _, p := range dome.Params {
+		if err := p.Parse(&cfg.Config, r.FormValue(p.Field)); err != nil {
+			return cfg, err
+		}
+	}
 	cfg.HomePosition, _ = strconv.ParseFloat(r.FormValue("home-position"), 64)
-	cfg.ParkPosition, _ = strconv.ParseFloat(r.FormValue("park-position"), 64)
-	cfg.AzimuthTimeout, _ = strconv.Atoi(r.FormValue("azimuth-timeout"))
-	cfg.MaxSpeed, _ = strconv.Atoi(r.FormValue("max-speed"))
-	cfg.MinSpeed, _ = strconv.Atoi(r.FormValue("min-speed"))
-	cfg.BrakeSpeed, _ = strconv.Atoi(r.FormValue("brake-speed"))
-	cfg.VelTimeout, _ = strconv.Atoi(r.FormValue("vel-timeout"))
-	cfg.ShortDistance, _ = strconv.Atoi(r.FormValue("short-distance"))
-	cfg.ShutterTimeout, _ = strconv.Atoi(r.FormValue("shutter-timeout"))
-
-	cfg.ParkOnShutter = r.FormValue("park-on-shutter") == "true"
+	cfg.ShutterTimeout = parseSeconds(r.FormValue("shutter-timeout"))
+	cfg.LowBatteryVoltage, _ = strconv.ParseFloat(r.FormValue("low-battery-voltage"), 64)
+	cfg.SafetyMaxHumidity, _ = strconv.ParseFloat(r.FormValue("safety-max-humidity"), 64)
+	cfg.SafetyTelemetryTimeout = parseSeconds(r.FormValue("safety-telemetry-timeout"))
+	cfg.ShutterCurrentDisabled = r.FormValue("shutter-current") != "true"
+	cfg.ShutterOvercurrentFactor, _ = strconv.ParseFloat(r.FormValue("shutter-overcurrent-factor"), 64)
+	cfg.RunawayWatchdogDisabled = r.FormValue("runaway-watchdog") != "true"
+	cfg.RunawayMargin = parseSeconds(r.FormValue("runaway-margin"))
+	cfg.RehomeAfterSlews, _ = strconv.Atoi(r.FormValue("rehome-after-slews"))
+	cfg.RehomeAfterRotation, _ = strconv.ParseFloat(r.FormValue("rehome-after-rotation"), 64)
+
 	cfg.UseShutter = r.FormValue("use-shutter") == "true"
+	cfg.ShutterInterlock = r.FormValue("shutter-interlock") == "true"
+	cfg.Slaving = r.FormValue("slaving") == "true"
+	cfg.TelescopeURL = strings.TrimSpace(r.FormValue("telescope-url"))
+	cfg.TelescopeSource = r.FormValue("telescope-source")
+	cfg.SlavingDeadband, _ = strconv.ParseFloat(r.FormValue("slaving-deadband"), 64)
+	cfg.SlavingMinInterval = parseSeconds(r.FormValue("slaving-min-interval"))
+	relays, err := parseRelays(r.FormValue("relays"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Relays = relays
+	cfg.SlavingPauseWindows = strings.FieldsFunc(r.FormValue("slaving-pause-windows"), func(c rune) bool {
+		return c == ',' || c == '\n' || c == '\r' || c == ' '
+	})
+
+	switch cfg.TelescopeSource {
+	case sourceAlpaca, sourceStellarium:
+	default:
+		return cfg, fmt.Errorf("invalid telescope source: %q", cfg.TelescopeSource)
+	}
+
+	switch cfg.AbortedShutter = r.FormValue("aborted-shutter"); cfg.AbortedShutter {
+	case abortedAsError, abortedAsOpen:
+	default:
+		return cfg, fmt.Errorf("invalid aborted shutter mapping: %q", cfg.AbortedShutter)
+	}
+
+	if cfg.Slaving && cfg.TelescopeURL == "" {
+		return cfg, fmt.Errorf("slaving needs the URL of the telescope")
+	}
+	if cfg.TelescopeURL != "" {
+		if u, err := url.Parse(cfg.TelescopeURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return cfg, fmt.Errorf("invalid telescope URL %q", cfg.TelescopeURL)
+		}
+	}
+	for _, window := range cfg.SlavingPauseWindows {
+		if _, err := parsePauseWindow(window); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.SlavingDeadband <= 0 || cfg.SlavingDeadband > 90 {
+		return cfg, fmt.Errorf("the slaving deadband must be greater than 0 and at most 90 degrees")
+	}
+	if cfg.SlavingMinInterval < 0 || cfg.SlavingMinInterval > time.Hour {
+		return cfg, fmt.Errorf("the minimum slaving interval must be between 0 and 1 hour")
+	}
+	if cfg.ShutterOvercurrentFactor <= 1 || cfg.ShutterOvercurrentFactor > 10 {
+		return cfg, fmt.Errorf("the shutter overcurrent factor must be greater than 1 and at most 10")
+	}
+	if cfg.SafetyMaxHumidity < 0 || cfg.SafetyMaxHumidity > 100 {
+		return cfg, fmt.Errorf("the safety humidity threshold must be between 0 and 100%%")
+	}
+	if cfg.SafetyTelemetryTimeout < 0 || cfg.SafetyTelemetryTimeout > time.Hour {
+		return cfg, fmt.Errorf("the safety telemetry timeout must be between 0 and 1 hour")
+	}
+	if cfg.RehomeAfterSlews < 0 || cfg.RehomeAfterSlews > 10000 {
+		return cfg, fmt.Errorf("the slews before an automatic home search must be between 0 and 10000")
+	}
+	if cfg.RehomeAfterRotation < 0 || cfg.RehomeAfterRotation > 360000 {
+		return cfg, fmt.Errorf("the rotation before an automatic home search must be between 0 and 360000 degrees")
+	}
+	if cfg.RunawayMargin < time.Second || cfg.RunawayMargin > time.Hour {
+		return cfg, fmt.Errorf("the runaway slew margin must be between 1 second and 1 hour")
+	}
 
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
 	return cfg, nil
 }
+
+// parseSeconds parses a form value in seconds, with decimals, as a duration.
+// Invalid values are returned as 0 and rejected by the validation.
+func parseSeconds(value string) time.Duration {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,376 @@
+package zro
+
+import (
+	"alpaca/internal/mocks"
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"alpaca/pkg/dome"
+	"alpaca/templates"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newConnectedDriver returns a driver in the connected state, backed by a
+// controller that never talks to a broker.
+func newConnectedDriver(t *testing.T) *Driver {
+	t.Helper()
+
+	d, err := NewDriver(1, openTestDB(t), nil, log.New())
+	require.NoError(t, err)
+
+	ctrl, err := dome.NewDome(&mocks.MQTTClient{}, dome.DefaultConfig(), d.logger)
+	require.NoError(t, err)
+
+	d.client = &mocks.MQTTClient{}
+	d.dome = ctrl
+	d.state = connStateConnected
+	return d
+}
+
+// TestDriverConcurrentAccess is meant to be run with -race.
+func TestDriverConcurrentAccess(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				d.SetSlaved(j%2 == 0)
+				d.Status()
+				d.GetState()
+				d.Connected()
+				d.Connecting()
+			}
+			if i == 0 {
+				d.Disconnect()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.False(t, d.Connected())
+	assert.ErrorIs(t, d.AbortSlew(), errors.ErrNotConnected)
+}
+
+func TestConvertShutterStatus(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	tests := []struct {
+		status  dome.ShutterStatus
+		aborted string
+		want    alpaca.ShutterStatus
+	}{
+		{dome.ShutterStatusOpen, abortedAsError, alpaca.ShutterOpen},
+		{dome.ShutterStatusClosed, abortedAsError, alpaca.ShutterClosed},
+		{dome.ShutterStatusOpening, abortedAsError, alpaca.ShutterOpening},
+		{dome.ShutterStatusClosing, abortedAsError, alpaca.ShutterClosing},
+		{dome.ShutterStatusError, abortedAsError, alpaca.ShutterError},
+		{dome.ShutterStatusError, abortedAsOpen, alpaca.ShutterError},
+		{dome.ShutterStatusAborted, abortedAsError, alpaca.ShutterError},
+		{dome.ShutterStatusAborted, abortedAsOpen, alpaca.ShutterOpen},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.status.String()+"/"+tc.aborted, func(t *testing.T) {
+			assert.Equal(t, tc.want, d.convertShutterStatus(tc.status, tc.aborted))
+		})
+	}
+}
+
+func TestDeviceError(t *testing.T) {
+	err := deviceError(fmt.Errorf("%w: N", dome.ErrCommandRejected))
+	assert.Equal(t, errors.ErrInvalidOperation.Number, errors.Number(err))
+	assert.ErrorIs(t, err, dome.ErrCommandRejected)
+
+	assert.Equal(t, errors.ErrNotConnected.Number, errors.Number(deviceError(dome.ErrNotConnected)))
+	assert.Nil(t, deviceError(nil))
+}
+
+func TestSupportedActionsImplemented(t *testing.T) {
+	d, err := NewDriver(0, openTestDB(t), nil, log.New())
+	require.NoError(t, err)
+
+	// Every advertised action is handled, even if it fails while the dome is
+	// disconnected.
+	for _, name := range d.SupportedActions() {
+		_, err := d.Action(name, "")
+		assert.NotErrorIs(t, err, errors.ErrActionNotImplemented, name)
+	}
+	_, err = d.Action("Calibrate", "")
+	assert.ErrorIs(t, err, errors.ErrActionNotImplemented)
+}
+
+func TestEmergencyStopAction(t *testing.T) {
+	d := newConnectedDriver(t)
+	d.slaved = true
+	d.parkingToClose.Store(true)
+	d.interlock.queue(queuedMotion{what: "park"})
+
+	// The test controller has no broker, so the abort itself fails, but the
+	// driver state is cleared anyway.
+	_, err := d.Action(actionEmergencyStop, "")
+	assert.Equal(t, errors.ErrNotConnected.Number, errors.Number(err))
+	assert.False(t, d.Status().Slaved)
+	assert.False(t, d.parkingToClose.Load())
+	assert.Nil(t, d.interlock.take())
+}
+
+func TestRawCommandAction(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	_, err := d.Action(actionRawCommand, " ")
+	assert.ErrorIs(t, err, errors.ErrInvalidValue)
+
+	_, err = d.Action(actionRawCommand, "V")
+	assert.ErrorIs(t, err, errors.ErrNotConnected)
+
+	// Only the read-only queries reach the controller.
+	for _, cmd := range []string{"R", "F", "G=100", "P", "H", "O", "LTICK=100", "T", "X", "Y1=1"} {
+		_, err = d.Action(actionRawCommand, cmd)
+		assert.ErrorIs(t, err, errors.ErrInvalidValue, cmd)
+	}
+	_, err = d.Command("_R;", true)
+	assert.ErrorIs(t, err, errors.ErrInvalidValue)
+}
+
+func TestCommand(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	_, err := d.Command("V", true)
+	assert.ErrorIs(t, err, errors.ErrInvalidValue)
+	_, err = d.Command("_V;", false)
+	assert.ErrorIs(t, err, errors.ErrInvalidValue)
+
+	_, err = d.Command("_V;", true)
+	assert.ErrorIs(t, err, errors.ErrNotConnected, "sent to the controller")
+}
+
+func TestManualMotionWhileSlaved(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	cfg := DefaultConfig()
+	cfg.Slaving = true
+	cfg.TelescopeURL = "http://localhost:11111/api/v1/telescope/0"
+	require.NoError(t, d.store.SetConfig(cfg))
+	require.NoError(t, d.SetSlaved(true))
+
+	assert.ErrorIs(t, d.SlewToAzimuth(90), errors.ErrSlaved)
+	assert.ErrorIs(t, d.FindHome(), errors.ErrSlaved)
+	assert.ErrorIs(t, d.Park(), errors.ErrSlaved)
+	assert.Equal(t, motionIdle, d.arbiter.current())
+
+	// A paused slaving lets the operator recover the dome manually.
+	_, err := d.Action(actionPauseSlaving, "")
+	require.NoError(t, err)
+	require.NoError(t, d.startManualMotion(d.dome, motionManual, "recovery", 0))
+	assert.Equal(t, motionManual, d.arbiter.current())
+}
+
+func TestMotionSourceState(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	motionSource := func() any {
+		for _, p := range d.GetState() {
+			if p.Name == "MotionSource" {
+				return p.Value
+			}
+		}
+		return nil
+	}
+
+	assert.Equal(t, "idle", motionSource())
+	require.NoError(t, d.arbiter.acquire(motionHoming, "find home", time.Now()))
+	assert.Equal(t, "homing", motionSource())
+}
+
+func TestDescription(t *testing.T) {
+	d := newConnectedDriver(t)
+	assert.Equal(t, "ZRO Dome fw unknown", d.DeviceInfo().Description)
+
+	cfg := DefaultConfig()
+	cfg.Description = "ZRO Dome fw {firmware}, driver {driver} @ observatory-east"
+	require.NoError(t, d.store.SetConfig(cfg))
+	assert.Equal(t, "ZRO Dome fw unknown, driver "+driverVersion+" @ observatory-east", d.DeviceInfo().Description)
+}
+
+func TestInstanceName(t *testing.T) {
+	db := openTestDB(t)
+	first, err := New(alpaca.DeviceConfig{Number: 0}, db, nil, log.New())
+	require.NoError(t, err)
+	second, err := New(alpaca.DeviceConfig{Number: 1, Key: "zro_config_1"}, db, nil, log.New())
+	require.NoError(t, err)
+
+	assert.Equal(t, "ZRO Dome", first.DeviceInfo().Name)
+	assert.Equal(t, "ZRO Dome 1", second.DeviceInfo().Name)
+
+	m := monitorState{device: second.name}
+	events := m.check(dome.Status{Shutter: dome.ShutterStatusError}, DefaultConfig())
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "ZRO Dome 1", events[0].Device)
+	}
+}
+
+func TestAzimuthHistogramChart(t *testing.T) {
+	tmpl, err := templates.LoadTemplates()
+	require.NoError(t, err)
+	d, err := NewDriver(1, openTestDB(t), tmpl, log.New())
+	require.NoError(t, err)
+
+	assert.Nil(t, d.histogramChart(), "no chart before the first slew")
+
+	now := time.Now()
+	for _, az := range []float64{0, 5, 10, 12, 14, 16} {
+		d.histogram.Record(az, true, now)
+		now = now.Add(time.Second)
+	}
+	d.histogram.Record(20, false, now)
+
+	bins := d.AzimuthHistogram()
+	require.Len(t, bins, 36)
+	assert.Equal(t, alpaca.AzimuthBin{From: 10, To: 20, Seconds: 4, Passes: 1, MeanPass: 4}, bins[1])
+
+	chart := d.histogramChart()
+	assert.Equal(t, 50, chart[0].Height)
+	assert.Equal(t, 100, chart[1].Height)
+
+	rec := httptest.NewRecorder()
+	d.HandleSetup(rec, httptest.NewRequest(http.MethodGet, "/setup", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "10&deg;-20&deg;: 4.0 s per pass, 1 passes")
+}
+
+func TestSetupFormParams(t *testing.T) {
+	tmpl, err := templates.LoadTemplates()
+	require.NoError(t, err)
+	d, err := NewDriver(1, openTestDB(t), tmpl, log.New())
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	d.HandleSetup(rec, httptest.NewRequest(http.MethodGet, "/setup", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	for _, p := range dome.Params {
+		assert.Contains(t, rec.Body.String(), `name="`+p.Field+`"`, "input of %s", p.Code)
+	}
+
+	// The values rendered by the form are parsed back to the same settings.
+	cfg := DefaultConfig()
+	cfg.TicksPerTurn = 3600
+	cfg.AzimuthTimeout = 2500 * time.Millisecond
+	cfg.ParkOnShutter = true
+	form := url.Values{
+		"enabled":                    {"true"},
+		"aborted-shutter":            {abortedAsError},
+		"home-position":              {"0"},
+		"shutter-timeout":            {"0"},
+		"slaving-deadband":           {"2"},
+		"runaway-watchdog":           {"true"},
+		"runaway-margin":             {"30"},
+		"shutter-overcurrent-factor": {"1.5"},
+		"mqtt-failover":              {"tcp://site:1883\r\ntcp://cloud:1883"},
+	}
+	for _, p := range formParams(cfg) {
+		form.Set(p.Field, p.Value)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	parsed, err := parseDomeSetupForm(req)
+	require.NoError(t, err)
+	assert.Equal(t, cfg.TicksPerTurn, parsed.TicksPerTurn)
+	assert.Equal(t, cfg.AzimuthTimeout, parsed.AzimuthTimeout)
+	assert.True(t, parsed.ParkOnShutter)
+	assert.Equal(t, []string{"tcp://site:1883", "tcp://cloud:1883"}, parsed.Failover)
+}
+
+func TestShutterWithoutLink(t *testing.T) {
+	d := newConnectedDriver(t)
+	assert.Equal(t, alpaca.ShutterError, d.Status().Shutter, "the shutter state is stale without a link")
+
+	cfg := dome.DefaultConfig()
+	cfg.UseShutter = false
+	ctrl, err := dome.NewDome(&mocks.MQTTClient{}, cfg, d.logger)
+	require.NoError(t, err)
+	d.dome = ctrl
+	assert.Equal(t, alpaca.ShutterClosed, d.Status().Shutter, "a dome without shutter has no link")
+}
+
+func TestConnectInBackground(t *testing.T) {
+	d, err := NewDriver(1, openTestDB(t), nil, log.New())
+	require.NoError(t, err)
+
+	// Nothing listens on the port, so the connection fails after Connect
+	// returned.
+	cfg, err := d.store.GetConfig()
+	require.NoError(t, err)
+	cfg.Host = "tcp://127.0.0.1:1"
+	require.NoError(t, d.store.SetConfig(cfg))
+
+	require.NoError(t, d.Connect())
+	assert.True(t, d.Connecting())
+	assert.NoError(t, d.Connect(), "a connection in progress is not an error")
+
+	assert.Eventually(t, func() bool { return !d.Connecting() }, 5*time.Second, 10*time.Millisecond)
+	assert.False(t, d.Connected(), "the failure surfaces once Connecting drops")
+	assert.ErrorIs(t, d.Disconnect(), errors.ErrNotConnected)
+
+	// A connection in progress can be cancelled.
+	require.NoError(t, d.Connect())
+	require.NoError(t, d.Disconnect())
+	assert.False(t, d.Connecting())
+	assert.False(t, d.Connected())
+}
+
+func TestConnectOnStartup(t *testing.T) {
+	d, err := NewDriver(1, openTestDB(t), nil, log.New())
+	require.NoError(t, err)
+	assert.False(t, d.ConnectOnStartup(), "off by default")
+
+	cfg := DefaultConfig()
+	cfg.ConnectOnStartup = true
+	require.NoError(t, d.store.SetConfig(cfg))
+	assert.True(t, d.ConnectOnStartup())
+	assert.Implements(t, (*alpaca.StartupConnector)(nil), d)
+}
+
+func TestRestart(t *testing.T) {
+	d := newConnectedDriver(t)
+	require.NoError(t, d.arbiter.acquire(motionManual, "slew", time.Now()))
+	d.parkingToClose.Store(true)
+
+	require.NoError(t, d.Restart())
+	assert.Equal(t, motionIdle, d.arbiter.current())
+	assert.False(t, d.parkingToClose.Load())
+	assert.True(t, d.Connecting(), "a connected driver connects again")
+	require.NoError(t, d.Disconnect())
+
+	// A disconnected driver stays disconnected.
+	require.NoError(t, d.Restart())
+	assert.False(t, d.Connected())
+	assert.False(t, d.Connecting())
+}
+
+func TestMetrics(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	gauges := d.Metrics()
+	require.NotEmpty(t, gauges)
+	assert.Equal(t, "zro_shutter_state", gauges[0].Name)
+	assert.Equal(t, "dome/1", gauges[0].Labels[0].Value)
+
+	d.mu.Lock()
+	d.state = connStateDisconnected
+	d.mu.Unlock()
+	assert.Empty(t, d.Metrics())
+}
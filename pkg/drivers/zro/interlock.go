@@ -0,0 +1,89 @@
+package zro
+
+import (
+	"alpaca/pkg/dome"
+	"sync"
+	"time"
+)
+
+// queuedMotion is an azimuth motion held by the shutter interlock.
+type queuedMotion struct {
+	what     string        // Description of the motion, for the log
+	expected time.Duration // Expected duration of the motion, for the runaway watchdog
+	run      func() error  // Sends the motion command
+}
+
+// interlock holds the azimuth motions requested while the shutter moves, for
+// domes that share the power of both motors or whose shutter must not turn
+// while it opens or closes. Only the last motion is kept.
+type interlock struct {
+	mu     sync.Mutex
+	queued *queuedMotion
+}
+
+// queue holds a motion until the shutter stops, replacing any held motion.
+func (i *interlock) queue(m queuedMotion) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.queued = &m
+}
+
+// take returns the held motion, if any, and forgets it.
+func (i *interlock) take() *queuedMotion {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	m := i.queued
+	i.queued = nil
+	return m
+}
+
+// pending reports whether a motion is held.
+func (i *interlock) pending() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.queued != nil
+}
+
+// shutterMoving reports whether the shutter is opening or closing.
+func shutterMoving(st dome.Status) bool {
+	return st.Shutter == dome.ShutterStatusOpening || st.Shutter == dome.ShutterStatusClosing
+}
+
+// interlocked reports whether azimuth motions must wait for the shutter.
+func (d *Driver) interlocked(ctrl *dome.Dome) bool {
+	cfg, err := d.store.GetConfig()
+	return err == nil && cfg.UseShutter && cfg.ShutterInterlock && shutterMoving(ctrl.GetStatus())
+}
+
+// move sends an azimuth motion owned with startManualMotion, or holds it
+// while the shutter interlock is engaged.
+func (d *Driver) move(ctrl *dome.Dome, m queuedMotion) error {
+	if d.interlocked(ctrl) {
+		d.logger.Infof("Shutter interlock: %s held until the shutter stops", m.what)
+		d.interlock.queue(m)
+		return nil
+	}
+
+	return deviceError(m.run())
+}
+
+// releaseInterlock sends the held motion once the shutter has stopped.
+func (d *Driver) releaseInterlock(ctrl *dome.Dome, st dome.Status) {
+	if shutterMoving(st) {
+		return
+	}
+	m := d.interlock.take()
+	if m == nil {
+		return
+	}
+
+	d.logger.Infof("Shutter interlock: the shutter stopped, starting the %s", m.what)
+	d.watchdog.expect(m.expected, time.Now())
+	if err := m.run(); err != nil {
+		d.logger.Errorf("Shutter interlock: failed to start the %s: %v", m.what, err)
+		d.arbiter.release()
+	}
+}
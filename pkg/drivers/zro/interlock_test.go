@@ -0,0 +1,41 @@
+package zro
+
+import (
+	"alpaca/pkg/dome"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterlock(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	var started []string
+	motion := func(what string) queuedMotion {
+		return queuedMotion{what: what, run: func() error {
+			started = append(started, what)
+			return nil
+		}}
+	}
+
+	d.interlock.queue(motion("slew to 90.0 degrees"))
+	d.interlock.queue(motion("slew to 180.0 degrees"))
+	assert.True(t, d.Status().Slewing, "a held motion is reported as started")
+
+	d.releaseInterlock(d.dome, dome.Status{Shutter: dome.ShutterStatusOpening})
+	assert.Empty(t, started, "held while the shutter moves")
+
+	d.releaseInterlock(d.dome, dome.Status{Shutter: dome.ShutterStatusOpen})
+	assert.Equal(t, []string{"slew to 180.0 degrees"}, started, "only the last motion is kept")
+	assert.False(t, d.interlock.pending())
+
+	d.releaseInterlock(d.dome, dome.Status{Shutter: dome.ShutterStatusOpen})
+	assert.Len(t, started, 1)
+}
+
+func TestShutterMoving(t *testing.T) {
+	assert.True(t, shutterMoving(dome.Status{Shutter: dome.ShutterStatusOpening}))
+	assert.True(t, shutterMoving(dome.Status{Shutter: dome.ShutterStatusClosing}))
+	assert.False(t, shutterMoving(dome.Status{Shutter: dome.ShutterStatusOpen}))
+	assert.False(t, shutterMoving(dome.Status{Shutter: dome.ShutterStatusClosed, Slewing: true}))
+}
@@ -0,0 +1,169 @@
+package zro
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Keys used by the legacy alpaca/ package (cmd/main.go binary).
+const (
+	legacyMQTTKey = "mqtt_config"
+	legacyDomeKey = "dome_config"
+)
+
+// legacyMQTTConfig is the MQTT configuration stored by the legacy binary.
+// Field names are matched in any case by encoding/json.
+type legacyMQTTConfig struct {
+	Broker    string `json:"broker"`
+	Host      string `json:"host"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	TopicRoot string `json:"topic_root"`
+}
+
+// legacyDomeConfig is the dome configuration stored by the legacy binary. The
+// dome simulator still uses the same layout under the same key.
+type legacyDomeConfig struct {
+	HomePosition   *uint `json:"home_position"`
+	ParkPosition   *uint `json:"park_position"`
+	ShutterTimeout *uint `json:"shutter_timeout"`
+	TicksPerRev    *uint `json:"ticks_per_rev"`
+}
+
+// migrateLegacyConfig converts the configuration written by the legacy binary
+// into the ZRO driver layout. It only runs when there is no ZRO configuration
+// yet and a legacy MQTT configuration is present. The legacy MQTT entry is
+// removed once migrated; the dome entry is kept for the simulator.
+func migrateLegacyConfig(db *bolt.DB) (bool, error) {
+	migrated := false
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil || b.Get([]byte(configKey)) != nil {
+			return nil
+		}
+
+		mqttValue := b.Get([]byte(legacyMQTTKey))
+		if mqttValue == nil {
+			return nil
+		}
+
+		cfg, err := convertLegacyConfig(mqttValue, b.Get([]byte(legacyDomeKey)))
+		if err != nil {
+			return err
+		}
+
+		value, _ := json.Marshal(cfg)
+		if err := b.Put([]byte(configKey), value); err != nil {
+			return err
+		}
+
+		migrated = true
+		return b.Delete([]byte(legacyMQTTKey))
+	})
+
+	return migrated, err
+}
+
+// convertLegacyConfig builds a ZRO configuration from the legacy entries.
+// Values missing from the legacy layout keep their defaults.
+func convertLegacyConfig(mqttValue, domeValue []byte) (Config, error) {
+	cfg := DefaultConfig()
+
+	var legacyMQTT legacyMQTTConfig
+	if err := json.Unmarshal(mqttValue, &legacyMQTT); err != nil {
+		return cfg, fmt.Errorf("invalid legacy %s: %v", legacyMQTTKey, err)
+	}
+
+	switch {
+	case legacyMQTT.Host != "":
+		cfg.Host = legacyMQTT.Host
+	case legacyMQTT.Broker != "":
+		cfg.Host = legacyMQTT.Broker
+	}
+	cfg.Username = legacyMQTT.Username
+	cfg.Password = legacyMQTT.Password
+	if legacyMQTT.TopicRoot != "" {
+		cfg.TopicRoot = legacyMQTT.TopicRoot
+	}
+
+	if domeValue == nil {
+		return cfg, nil
+	}
+
+	var legacyDome legacyDomeConfig
+	if err := json.Unmarshal(domeValue, &legacyDome); err != nil {
+		log.Warnf("Ignoring invalid legacy %s: %v", legacyDomeKey, err)
+		return cfg, nil
+	}
+
+	if legacyDome.HomePosition != nil {
+		cfg.HomePosition = float64(*legacyDome.HomePosition)
+	}
+	if legacyDome.ParkPosition != nil {
+		cfg.ParkPosition = float64(*legacyDome.ParkPosition)
+	}
+	if legacyDome.ShutterTimeout != nil {
+		cfg.ShutterTimeout = time.Duration(*legacyDome.ShutterTimeout) * time.Second
+	}
+	if legacyDome.TicksPerRev != nil && *legacyDome.TicksPerRev > 0 {
+		cfg.TicksPerTurn = int(*legacyDome.TicksPerRev)
+	}
+
+	return cfg, nil
+}
+
+// legacyTimeouts are the timeouts stored before configVersion 1, as integers
+// in the units of the firmware.
+type legacyTimeouts struct {
+	Version        int
+	AzimuthTimeout int // Milliseconds
+	VelTimeout     int // Seconds
+	ShutterTimeout int // Seconds
+}
+
+// migrateTimeoutUnits converts the timeouts of a configuration stored before
+// configVersion 1 to durations. Without it, an azimuth timeout of 20000 ms
+// would be read as 20000 ns.
+func migrateTimeoutUnits(db *bolt.DB) (bool, error) {
+	converted := false
+
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		value := b.Get([]byte(configKey))
+		if value == nil {
+			return nil
+		}
+
+		var legacy legacyTimeouts
+		if err := json.Unmarshal(value, &legacy); err != nil {
+			return fmt.Errorf("invalid %s: %v", configKey, err)
+		}
+		if legacy.Version >= configVersion {
+			return nil
+		}
+
+		var cfg Config
+		if err := json.Unmarshal(value, &cfg); err != nil {
+			return fmt.Errorf("invalid %s: %v", configKey, err)
+		}
+		cfg.Version = configVersion
+		cfg.AzimuthTimeout = time.Duration(legacy.AzimuthTimeout) * time.Millisecond
+		cfg.VelTimeout = time.Duration(legacy.VelTimeout) * time.Second
+		cfg.ShutterTimeout = time.Duration(legacy.ShutterTimeout) * time.Second
+
+		value, _ = json.Marshal(cfg)
+		converted = true
+		return b.Put([]byte(configKey), value)
+	})
+
+	return converted, err
+}
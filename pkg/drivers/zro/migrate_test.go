@@ -0,0 +1,102 @@
+package zro
+
+import (
+	"alpaca/pkg/dome"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openTestDB(t *testing.T) *bolt.DB {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "alpaca.db"), 0600, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func putLegacy(t *testing.T, db *bolt.DB, entries map[string]string) {
+	t.Helper()
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		for k, v := range entries {
+			if err := b.Put([]byte(k), []byte(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+}
+
+func TestMigrateLegacyConfig(t *testing.T) {
+	db := openTestDB(t)
+	putLegacy(t, db, map[string]string{
+		legacyMQTTKey: `{"Broker":"tcp://broker:1883","Username":"zro","Password":"secret"}`,
+		legacyDomeKey: `{"home_position":10,"park_position":90,"shutter_timeout":60,"ticks_per_rev":1470}`,
+	})
+
+	st, err := NewStore(db)
+	require.NoError(t, err)
+
+	cfg, err := st.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "tcp://broker:1883", cfg.Host)
+	assert.Equal(t, "zro", cfg.Username)
+	assert.Equal(t, "secret", cfg.Password)
+	assert.Equal(t, "/ZRO", cfg.TopicRoot)
+	assert.Equal(t, 10.0, cfg.HomePosition)
+	assert.Equal(t, 90.0, cfg.ParkPosition)
+	assert.Equal(t, 60*time.Second, cfg.ShutterTimeout)
+	assert.Equal(t, 1470, cfg.TicksPerTurn)
+	assert.Equal(t, dome.DefaultConfig().MaxSpeed, cfg.MaxSpeed)
+
+	// The legacy MQTT entry is consumed, the dome entry is kept for the simulator.
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		assert.Nil(t, b.Get([]byte(legacyMQTTKey)))
+		assert.NotNil(t, b.Get([]byte(legacyDomeKey)))
+		return nil
+	}))
+}
+
+func TestMigrateKeepsExistingConfig(t *testing.T) {
+	db := openTestDB(t)
+	putLegacy(t, db, map[string]string{
+		configKey:     `{"Host":"tcp://current:1883","TicksPerTurn":1000}`,
+		legacyMQTTKey: `{"host":"tcp://legacy:1883"}`,
+	})
+
+	migrated, err := migrateLegacyConfig(db)
+	require.NoError(t, err)
+	assert.False(t, migrated)
+}
+
+func TestMigrateTimeoutUnits(t *testing.T) {
+	db := openTestDB(t)
+	putLegacy(t, db, map[string]string{
+		configKey: `{"Host":"tcp://current:1883","TicksPerTurn":1000,"AzimuthTimeout":20000,"VelTimeout":10,"ShutterTimeout":90}`,
+	})
+
+	st, err := NewStore(db)
+	require.NoError(t, err)
+
+	cfg, err := st.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, configVersion, cfg.Version)
+	assert.Equal(t, 20*time.Second, cfg.AzimuthTimeout)
+	assert.Equal(t, 10*time.Second, cfg.VelTimeout)
+	assert.Equal(t, 90*time.Second, cfg.ShutterTimeout)
+	assert.Equal(t, 1000, cfg.TicksPerTurn)
+
+	// A converted configuration is left alone.
+	converted, err := migrateTimeoutUnits(db)
+	require.NoError(t, err)
+	assert.False(t, converted)
+}
@@ -0,0 +1,136 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/dome"
+	"alpaca/pkg/notify"
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// monitorInterval is the period of the status checks that raise notifications.
+const monitorInterval = 5 * time.Second
+
+// batteryHysteresis is how far above the low battery threshold the voltage
+// must recover before another low battery event is sent.
+const batteryHysteresis = 0.3
+
+// telemetryAzimuthStep is the azimuth change that is published as a state
+// change, in degrees.
+const telemetryAzimuthStep = 1.0
+
+// monitorState keeps what the monitor has already notified, so each
+// condition is only reported once, and the last status published as a state
+// change.
+type monitorState struct {
+	device     string // Device of the events
+	lowBattery bool
+	shutter    dome.ShutterStatus
+
+	recorded *alpaca.DomeStatus
+}
+
+// monitor checks the controller status periodically and publishes the alarms
+// and state changes on the event bus until the context is cancelled.
+func (d *Driver) monitor(ctx context.Context, ctrl *dome.Dome) {
+	ticker := time.NewTicker(monitorInterval)
+	defer ticker.Stop()
+
+	state := monitorState{device: d.name, shutter: ctrl.GetStatus().Shutter}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg, err := d.store.GetConfig()
+			if err != nil {
+				continue
+			}
+			d.mu.RLock()
+			slaved := d.slaved
+			d.mu.RUnlock()
+
+			st := ctrl.GetStatus()
+			d.releaseInterlock(ctrl, st)
+			d.arbiter.settle(st.Slewing || d.interlock.pending(), time.Now())
+			d.checkRunaway(ctrl, st, cfg, time.Now())
+			d.checkShutterCurrent(ctrl, st, cfg)
+			d.drift.update(st, ctrl.TicksToDegrees(st.Position), d.arbiter.current() == motionHoming, time.Now())
+			d.checkRehome(cfg, slaved, time.Now())
+
+			for _, event := range state.check(st, cfg) {
+				alpaca.Publish(alpaca.Event{
+					Type:         alpaca.EventError,
+					Time:         event.Time,
+					Device:       event.Device,
+					Message:      event.Message,
+					Notification: event.Type,
+				})
+			}
+			if st := d.Status(); state.telemetryChanged(st) {
+				alpaca.Publish(alpaca.Event{
+					Type:    alpaca.EventStateChanged,
+					Device:  d.name,
+					Message: fmt.Sprintf("Azimuth %.1f, shutter %s", st.Azimuth, st.Shutter),
+					State:   st,
+				})
+			}
+		}
+	}
+}
+
+// moving reports whether the dome or the shutter is moving.
+func moving(st dome.Status) bool {
+	return st.Slewing || shutterMoving(st)
+}
+
+// check compares the status with the previous one and returns the events to
+// send.
+func (m *monitorState) check(st dome.Status, cfg Config) []notify.Event {
+	var events []notify.Event
+
+	// A zero voltage means the battery has not been read yet.
+	voltage := float64(st.BatteryVoltage)
+	if cfg.UseShutter && cfg.LowBatteryVoltage > 0 && voltage > 0 {
+		switch {
+		case !m.lowBattery && voltage < cfg.LowBatteryVoltage:
+			m.lowBattery = true
+			events = append(events, notify.Event{
+				Type:    notify.EventLowBattery,
+				Device:  m.device,
+				Message: fmt.Sprintf("Shutter battery at %.2f V, below %.2f V", voltage, cfg.LowBatteryVoltage),
+			})
+		case m.lowBattery && voltage >= cfg.LowBatteryVoltage+batteryHysteresis:
+			m.lowBattery = false
+		}
+	}
+
+	if st.Shutter == dome.ShutterStatusError && m.shutter != dome.ShutterStatusError {
+		events = append(events, notify.Event{
+			Type:    notify.EventShutterError,
+			Device:  m.device,
+			Message: fmt.Sprintf("Shutter reported an error after %s", m.shutter),
+		})
+	}
+	m.shutter = st.Shutter
+
+	return events
+}
+
+// telemetryChanged reports whether the status differs enough from the last
+// recorded one to be published, and remembers it if so.
+func (m *monitorState) telemetryChanged(st alpaca.DomeStatus) bool {
+	if m.recorded != nil {
+		last := *m.recorded
+		azimuthMoved := math.Abs(st.Azimuth-last.Azimuth) >= telemetryAzimuthStep
+		last.Azimuth = st.Azimuth
+		if !azimuthMoved && last == st {
+			return false
+		}
+	}
+
+	m.recorded = &st
+	return true
+}
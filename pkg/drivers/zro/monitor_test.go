@@ -0,0 +1,63 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/dome"
+	"alpaca/pkg/notify"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorLowBattery(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.UseShutter = true
+	cfg.LowBatteryVoltage = 12
+
+	var m monitorState
+	check := func(voltage float32) []notify.Event {
+		return m.check(dome.Status{BatteryVoltage: voltage}, cfg)
+	}
+
+	assert.Empty(t, check(0), "battery not read yet")
+	assert.Empty(t, check(12.5))
+
+	events := check(11.9)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, notify.EventLowBattery, events[0].Type)
+	}
+	assert.Empty(t, check(11.5), "already notified")
+	assert.Empty(t, check(12.1), "within the hysteresis")
+	assert.Empty(t, check(11.9), "not recovered yet")
+	assert.Empty(t, check(12.4))
+	assert.Len(t, check(11.9), 1, "notified again after recovering")
+}
+
+func TestMonitorShutterError(t *testing.T) {
+	cfg := DefaultConfig()
+
+	var m monitorState
+	assert.Empty(t, m.check(dome.Status{Shutter: dome.ShutterStatusOpening}, cfg))
+
+	events := m.check(dome.Status{Shutter: dome.ShutterStatusError}, cfg)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, notify.EventShutterError, events[0].Type)
+	}
+	assert.Empty(t, m.check(dome.Status{Shutter: dome.ShutterStatusError}, cfg))
+}
+
+func TestMonitorTelemetryChanged(t *testing.T) {
+	var m monitorState
+
+	st := alpaca.DomeStatus{Azimuth: 10, Shutter: alpaca.ShutterClosed}
+	assert.True(t, m.telemetryChanged(st), "first status")
+	assert.False(t, m.telemetryChanged(st))
+
+	st.Azimuth = 10.5
+	assert.False(t, m.telemetryChanged(st), "below the azimuth step")
+	st.Azimuth = 11
+	assert.True(t, m.telemetryChanged(st))
+
+	st.Shutter = alpaca.ShutterOpening
+	assert.True(t, m.telemetryChanged(st))
+}
@@ -0,0 +1,98 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/dome"
+	"alpaca/pkg/notify"
+	"fmt"
+	"time"
+)
+
+// parkPollInterval is the period of the status checks while the dome parks
+// before closing the shutter.
+const parkPollInterval = 500 * time.Millisecond
+
+// atPark reports whether the dome is within the tolerance of the park
+// position.
+func atPark(cfg dome.Config, st dome.Status, azimuth float64) bool {
+	return !st.Slewing && azimuthTicks(cfg, azimuth, cfg.ParkPosition) <= cfg.Tolerance
+}
+
+// closeShutter closes the shutter. With ParkOnShutter, the dome first
+// rotates to the park position, where the shutter is powered or latched, and
+// the shutter is closed once it gets there. The shutter is reported as
+// closing meanwhile. Closing the shutter protects the dome, so the park is a
+// safety motion that is not rejected while the dome is slaved.
+func (d *Driver) closeShutter(ctrl *dome.Dome) error {
+	cfg := ctrl.Config()
+	st := ctrl.GetStatus()
+	if !cfg.ParkOnShutter || atPark(cfg, st, ctrl.TicksToDegrees(st.Position)) {
+		return ctrl.SetShutter(dome.ShutterClose)
+	}
+
+	ticks := azimuthTicks(cfg, ctrl.TicksToDegrees(st.Position), cfg.ParkPosition)
+	if err := d.startMotion(ctrl, motionSafety, "park before closing the shutter", ticks); err != nil {
+		return err
+	}
+
+	d.parkingToClose.Store(true)
+	if err := ctrl.Park(); err != nil {
+		d.parkingToClose.Store(false)
+		d.arbiter.release()
+		return err
+	}
+
+	deadline := time.Now().Add(runawayFactor*slewDuration(cfg, cfg.TicksPerTurn) + defaultRunawayMargin)
+	go d.closeWhenParked(ctrl, deadline)
+	return nil
+}
+
+// closeWhenParked closes the shutter once the dome reaches the park position.
+// It gives up if the park is aborted, the dome stops elsewhere or it takes
+// longer than the deadline.
+func (d *Driver) closeWhenParked(ctrl *dome.Dome, deadline time.Time) {
+	defer d.parkingToClose.Store(false)
+
+	cfg := ctrl.Config()
+	started := time.Now()
+	ticker := time.NewTicker(parkPollInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if !d.parkingToClose.Load() {
+			d.logger.Info("Park on shutter: park aborted, the shutter is left open")
+			return
+		}
+		if current, err := d.controller(); err != nil || current != ctrl {
+			return
+		}
+
+		st := ctrl.GetStatus()
+		switch {
+		case atPark(cfg, st, ctrl.TicksToDegrees(st.Position)):
+			d.logger.Info("Park on shutter: dome parked, closing the shutter")
+			if err := ctrl.SetShutter(dome.ShutterClose); err != nil {
+				d.logger.Errorf("Park on shutter: failed to close the shutter: %v", err)
+			}
+			return
+		case now.After(deadline):
+			d.leftOpen("the dome did not park in time")
+			return
+		case !st.Slewing && now.Sub(started) > motionSettle:
+			d.leftOpen(fmt.Sprintf("the dome stopped at %.1f degrees instead of parking", ctrl.TicksToDegrees(st.Position)))
+			return
+		}
+	}
+}
+
+// leftOpen reports a park before closing the shutter that failed, leaving
+// the shutter open.
+func (d *Driver) leftOpen(reason string) {
+	d.logger.Errorf("Park on shutter: %s, the shutter is left open", reason)
+	alpaca.Publish(alpaca.Event{
+		Type:         alpaca.EventError,
+		Device:       d.name,
+		Message:      fmt.Sprintf("Shutter left open: %s before closing it", reason),
+		Notification: notify.EventShutterError,
+	})
+}
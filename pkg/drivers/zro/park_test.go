@@ -0,0 +1,62 @@
+package zro
+
+import (
+	"alpaca/internal/mocks"
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/dome"
+	"alpaca/pkg/notify"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtPark(t *testing.T) {
+	cfg := dome.DefaultConfig()
+	cfg.TicksPerTurn = 3600
+	cfg.Tolerance = 5
+	cfg.ParkPosition = 0
+
+	assert.True(t, atPark(cfg, dome.Status{}, 0))
+	assert.True(t, atPark(cfg, dome.Status{}, 359.7), "within the tolerance across north")
+	assert.False(t, atPark(cfg, dome.Status{}, 1))
+	assert.False(t, atPark(cfg, dome.Status{Slewing: true}, 0), "passing through the park position")
+}
+
+func TestCloseShutterReportedWhileParking(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	d.parkingToClose.Store(true)
+	assert.Equal(t, alpaca.ShutterClosing, d.Status().Shutter)
+}
+
+func TestCloseWhenParkedTimeout(t *testing.T) {
+	d := newConnectedDriver(t)
+	cfg := dome.DefaultConfig()
+	cfg.ParkOnShutter = true
+	cfg.ParkPosition = 180
+	ctrl, err := dome.NewDome(&mocks.MQTTClient{}, cfg, d.logger)
+	require.NoError(t, err)
+	d.dome = ctrl
+
+	events := make(chan alpaca.Event, 10)
+	unsubscribe := alpaca.Events().Subscribe("test", func(e alpaca.Event) {
+		if e.Notification == notify.EventShutterError {
+			events <- e
+		}
+	})
+	defer unsubscribe()
+
+	d.parkingToClose.Store(true)
+	d.closeWhenParked(ctrl, time.Now())
+	assert.False(t, d.parkingToClose.Load())
+
+	select {
+	case e := <-events:
+		assert.Equal(t, d.name, e.Device)
+		assert.Contains(t, e.Message, "did not park in time")
+	case <-time.After(time.Second):
+		t.Fatal("no event for the park that timed out")
+	}
+}
@@ -0,0 +1,191 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca/errors"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxPresets bounds the number of azimuth presets of a dome.
+const maxPresets = 32
+
+// Preset is a named azimuth the dome can be slewed to in one call, such as
+// the flat panel or the service hatch.
+type Preset struct {
+	Name    string  `json:"Name"`
+	Azimuth float64 `json:"Azimuth"` // Degrees, from 0 to 360
+}
+
+// findPreset returns the index of the preset called name, ignoring the case,
+// or -1 if there is none.
+func findPreset(presets []Preset, name string) int {
+	return slices.IndexFunc(presets, func(p Preset) bool { return strings.EqualFold(p.Name, name) })
+}
+
+// validatePresets checks the names are set and unique and the azimuths
+// within range.
+func validatePresets(presets []Preset) error {
+	if len(presets) > maxPresets {
+		return fmt.Errorf("at most %d azimuth presets are allowed", maxPresets)
+	}
+	for i, p := range presets {
+		if p.Name == "" || strings.ContainsAny(p.Name, "=\n") {
+			return fmt.Errorf("invalid preset name %q", p.Name)
+		}
+		if findPreset(presets[:i], p.Name) >= 0 {
+			return fmt.Errorf("duplicate preset name %q", p.Name)
+		}
+		if p.Azimuth < 0 || p.Azimuth >= 360 || math.IsNaN(p.Azimuth) {
+			return fmt.Errorf("the azimuth of preset %q must be between 0 and 360 degrees", p.Name)
+		}
+	}
+	return nil
+}
+
+// setPreset adds the preset, or moves the one of the same name.
+func setPreset(presets []Preset, preset Preset) ([]Preset, error) {
+	preset.Name = strings.TrimSpace(preset.Name)
+	presets = slices.Clone(presets)
+	if i := findPreset(presets, preset.Name); i >= 0 {
+		presets[i] = preset
+	} else {
+		presets = append(presets, preset)
+	}
+	return presets, validatePresets(presets)
+}
+
+// deletePreset removes the preset called name.
+func deletePreset(presets []Preset, name string) ([]Preset, error) {
+	i := findPreset(presets, strings.TrimSpace(name))
+	if i < 0 {
+		return presets, fmt.Errorf("no azimuth preset %q", name)
+	}
+	return slices.Delete(slices.Clone(presets), i, i+1), nil
+}
+
+// listPresets returns the azimuth presets, as JSON, for the ListPresets
+// action.
+func (d *Driver) listPresets() (string, error) {
+	cfg, err := d.store.GetConfig()
+	if err != nil {
+		return "", err
+	}
+	presets := cfg.Presets
+	if presets == nil {
+		presets = []Preset{} // Listed as [] rather than null
+	}
+	b, err := json.Marshal(presets)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// slewToPreset slews to the preset named in parameters, as SlewToAzimuth
+// would.
+func (d *Driver) slewToPreset(parameters string) (string, error) {
+	cfg, err := d.store.GetConfig()
+	if err != nil {
+		return "", err
+	}
+	i := findPreset(cfg.Presets, strings.TrimSpace(parameters))
+	if i < 0 {
+		return "", errors.Errorf(errors.ErrInvalidValue, "no azimuth preset %q", parameters)
+	}
+
+	preset := cfg.Presets[i]
+	d.logger.Infof("Slewing to preset %s at %.1f degrees", preset.Name, preset.Azimuth)
+	if err := d.SlewToAzimuth(preset.Azimuth); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("slewing to %s at %.1f degrees", preset.Name, preset.Azimuth), nil
+}
+
+// savePreset saves the preset given in parameters as "name=azimuth", or as
+// "name" for the current azimuth of the dome, for the SetPreset action.
+func (d *Driver) savePreset(parameters string) (string, error) {
+	name, value, found := strings.Cut(parameters, "=")
+	preset := Preset{Name: strings.TrimSpace(name)}
+	if found {
+		azimuth, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", errors.Errorf(errors.ErrInvalidValue, "invalid preset azimuth %q", value)
+		}
+		preset.Azimuth = azimuth
+	} else {
+		ctrl, err := d.controller()
+		if err != nil {
+			return "", err
+		}
+		preset.Azimuth = math.Mod(math.Round(10*ctrl.TicksToDegrees(ctrl.GetStatus().Position))/10, 360)
+	}
+
+	err := d.store.UpdateConfig(func(cfg *Config) error {
+		presets, err := setPreset(cfg.Presets, preset)
+		if err != nil {
+			return errors.Errorf(errors.ErrInvalidValue, "%v", err)
+		}
+		cfg.Presets = presets
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	d.logger.Infof("Azimuth preset %s set to %.1f degrees", preset.Name, preset.Azimuth)
+	return fmt.Sprintf("%s set to %.1f degrees", preset.Name, preset.Azimuth), nil
+}
+
+// removePreset deletes the preset named in parameters, for the DeletePreset
+// action.
+func (d *Driver) removePreset(parameters string) (string, error) {
+	err := d.store.UpdateConfig(func(cfg *Config) error {
+		presets, err := deletePreset(cfg.Presets, parameters)
+		if err != nil {
+			return errors.Errorf(errors.ErrInvalidValue, "%v", err)
+		}
+		cfg.Presets = presets
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	d.logger.Infof("Azimuth preset %s deleted", strings.TrimSpace(parameters))
+	return "deleted", nil
+}
+
+// handlePresetForm adds or deletes an azimuth preset from the setup page.
+func (d *Driver) handlePresetForm(w http.ResponseWriter, r *http.Request, cfg Config) {
+	var presets []Preset
+	var err error
+
+	switch r.FormValue("preset-action") {
+	case "create":
+		var azimuth float64
+		if azimuth, err = strconv.ParseFloat(r.FormValue("preset-azimuth"), 64); err != nil {
+			d.renderSetupForm(w, cfg, false, fmt.Sprintf("invalid preset azimuth %q", r.FormValue("preset-azimuth")))
+			return
+		}
+		presets, err = setPreset(cfg.Presets, Preset{Name: r.FormValue("preset-name"), Azimuth: azimuth})
+	case "delete":
+		presets, err = deletePreset(cfg.Presets, r.FormValue("preset-name"))
+	default:
+		err = fmt.Errorf("unknown preset action %q", r.FormValue("preset-action"))
+	}
+	if err != nil {
+		d.renderSetupForm(w, cfg, false, err.Error())
+		return
+	}
+
+	cfg.Presets = presets
+	d.logger.Infof("Setting azimuth presets: %v", presets)
+	if err := d.store.SetConfig(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	d.renderSetupForm(w, cfg, true, "")
+}
@@ -0,0 +1,107 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca/errors"
+	"alpaca/templates"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPreset(t *testing.T) {
+	presets, err := setPreset(nil, Preset{Name: " Flat panel ", Azimuth: 120})
+	require.NoError(t, err)
+	assert.Equal(t, []Preset{{Name: "Flat panel", Azimuth: 120}}, presets)
+
+	moved, err := setPreset(presets, Preset{Name: "flat panel", Azimuth: 125})
+	require.NoError(t, err)
+	assert.Equal(t, []Preset{{Name: "flat panel", Azimuth: 125}}, moved, "the name is matched ignoring the case")
+	assert.Equal(t, 120.0, presets[0].Azimuth, "the previous list is unchanged")
+
+	for _, p := range []Preset{{Name: "", Azimuth: 10}, {Name: "a=b", Azimuth: 10}, {Name: "Hatch", Azimuth: 360}, {Name: "Hatch", Azimuth: -1}} {
+		_, err := setPreset(presets, p)
+		assert.Error(t, err, p)
+	}
+
+	presets, err = deletePreset(moved, "FLAT PANEL")
+	require.NoError(t, err)
+	assert.Empty(t, presets)
+	_, err = deletePreset(presets, "Hatch")
+	assert.Error(t, err)
+}
+
+func TestPresetActions(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	list, err := d.Action(actionListPresets, "")
+	require.NoError(t, err)
+	assert.Equal(t, "[]", list)
+
+	_, err = d.Action(actionSetPreset, "Service hatch=270.5")
+	require.NoError(t, err)
+	_, err = d.Action(actionSetPreset, "Flat panel")
+	require.NoError(t, err, "the current azimuth")
+	_, err = d.Action(actionSetPreset, "Hatch=north")
+	assert.ErrorIs(t, err, errors.ErrInvalidValue)
+
+	list, err = d.Action(actionListPresets, "")
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"Name":"Service hatch","Azimuth":270.5},{"Name":"Flat panel","Azimuth":0}]`, list)
+
+	_, err = d.Action(actionSlewToPreset, "Telescope")
+	assert.ErrorIs(t, err, errors.ErrInvalidValue)
+
+	_, err = d.Action(actionDeletePreset, "service hatch")
+	require.NoError(t, err)
+	_, err = d.Action(actionDeletePreset, "service hatch")
+	assert.ErrorIs(t, err, errors.ErrInvalidValue)
+
+	require.NoError(t, d.Disconnect())
+	_, err = d.Action(actionSlewToPreset, "Flat panel")
+	assert.ErrorIs(t, err, errors.ErrNotConnected)
+}
+
+func TestPresetSetupForm(t *testing.T) {
+	tmpl, err := templates.LoadTemplates()
+	require.NoError(t, err)
+	d, err := NewDriver(1, openTestDB(t), tmpl, log.New())
+	require.NoError(t, err)
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/setup", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		d.HandleSetup(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+
+	rec := post(url.Values{"preset-action": {"create"}, "preset-name": {"Flat panel"}, "preset-azimuth": {"120"}})
+	assert.Contains(t, rec.Body.String(), `data-preset="Flat panel"`)
+
+	// Saving the settings keeps the presets.
+	form := url.Values{
+		"aborted-shutter":            {abortedAsError},
+		"slaving-deadband":           {"2"},
+		"runaway-margin":             {"30"},
+		"shutter-overcurrent-factor": {"1.5"},
+	}
+	for _, p := range formParams(DefaultConfig()) {
+		form.Set(p.Field, p.Value)
+	}
+	assert.Contains(t, post(form).Body.String(), "Settings saved successfully")
+	cfg, err := d.store.GetConfig()
+	require.NoError(t, err)
+	assert.Equal(t, []Preset{{Name: "Flat panel", Azimuth: 120}}, cfg.Presets)
+
+	post(url.Values{"preset-action": {"delete"}, "preset-name": {"Flat panel"}})
+	cfg, err = d.store.GetConfig()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Presets)
+}
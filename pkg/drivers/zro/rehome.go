@@ -0,0 +1,97 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/dome"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rehomeIdle is how long the dome must stay still before an automatic home
+// search, so it does not start between the slews of a sequence.
+const rehomeIdle = time.Minute
+
+// drift counts the slews and the rotation since the last home search, after
+// which the encoder may have drifted from the true azimuth.
+type drift struct {
+	mu         sync.Mutex
+	slews      int       // Slews started since the last home search
+	rotation   float64   // Degrees turned since the last home search
+	azimuth    float64   // Azimuth at the last update
+	known      bool      // True once the azimuth was read
+	slewing    bool      // True if the dome was slewing at the last update
+	lastMotion time.Time // Last update that saw the dome or the shutter moving
+}
+
+// update records the status of the controller, read at now, with the dome at
+// the given azimuth. The counts restart while the dome searches its home.
+func (r *drift) update(st dome.Status, azimuth float64, homing bool, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch {
+	case homing:
+		r.slews, r.rotation = 0, 0
+	case st.Slewing && !r.slewing:
+		r.slews++
+	}
+	if r.known && !homing {
+		r.rotation += azimuthDistance(azimuth, r.azimuth)
+	}
+	r.azimuth, r.known, r.slewing = azimuth, true, st.Slewing
+	if moving(st) {
+		r.lastMotion = now
+	}
+}
+
+// counts returns the slews and the degrees turned since the last home search.
+func (r *drift) counts() (int, float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.slews, r.rotation
+}
+
+// due returns why a home search is due at now, or an empty string if it is
+// not due or the dome has not been still for long enough.
+func (r *drift) due(cfg Config, now time.Time) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.lastMotion) < rehomeIdle {
+		return ""
+	}
+	var reasons []string
+	if cfg.RehomeAfterSlews > 0 && r.slews >= cfg.RehomeAfterSlews {
+		reasons = append(reasons, fmt.Sprintf("%d slews", r.slews))
+	}
+	if cfg.RehomeAfterRotation > 0 && r.rotation >= cfg.RehomeAfterRotation {
+		reasons = append(reasons, fmt.Sprintf("%.0f degrees of rotation", r.rotation))
+	}
+	return strings.Join(reasons, " and ")
+}
+
+// checkRehome starts an automatic home search once it is due, while the dome
+// is idle and not following the telescope: a slaved dome is only homed while
+// its slaving is paused.
+func (d *Driver) checkRehome(cfg Config, slaved bool, now time.Time) {
+	reason := d.drift.due(cfg, now)
+	if reason == "" || d.arbiter.current() != motionIdle || d.interlock.pending() || d.watchdog.tripped() {
+		return
+	}
+	if slaved && d.slavingPausedBy(cfg, now) == "" {
+		return
+	}
+
+	d.logger.Infof("Automatic home search after %s", reason)
+	if err := d.FindHome(); err != nil {
+		d.logger.Warnf("Automatic home search failed: %v", err)
+		return
+	}
+	alpaca.Publish(alpaca.Event{
+		Type:    alpaca.EventStateChanged,
+		Device:  d.name,
+		Message: "Automatic home search after " + reason,
+	})
+}
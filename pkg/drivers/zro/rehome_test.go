@@ -0,0 +1,59 @@
+package zro
+
+import (
+	"alpaca/pkg/dome"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrift(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RehomeAfterSlews = 2
+	cfg.RehomeAfterRotation = 300
+
+	var r drift
+	now := time.Now()
+	r.update(dome.Status{}, 10, false, now)
+	r.update(dome.Status{Slewing: true}, 100, false, now)
+	r.update(dome.Status{Slewing: true}, 190, false, now)
+	r.update(dome.Status{}, 200, false, now)
+	slews, rotation := r.counts()
+	assert.Equal(t, 1, slews)
+	assert.InDelta(t, 190, rotation, 1e-9)
+	assert.Empty(t, r.due(cfg, now.Add(time.Hour)))
+
+	r.update(dome.Status{Slewing: true}, 350, false, now)
+	r.update(dome.Status{}, 20, false, now)
+	slews, rotation = r.counts()
+	assert.Equal(t, 2, slews)
+	assert.InDelta(t, 370, rotation, 1e-9, "across north")
+	assert.Empty(t, r.due(cfg, now.Add(30*time.Second)), "not still for long enough")
+	assert.Equal(t, "2 slews and 370 degrees of rotation", r.due(cfg, now.Add(time.Minute)))
+
+	cfg.RehomeAfterSlews, cfg.RehomeAfterRotation = 0, 0
+	assert.Empty(t, r.due(cfg, now.Add(time.Hour)), "disabled")
+
+	r.update(dome.Status{Slewing: true}, 100, true, now)
+	slews, rotation = r.counts()
+	assert.Zero(t, slews, "the home search restarts the counts")
+	assert.Zero(t, rotation)
+}
+
+func TestRehomeWaitsForSlaving(t *testing.T) {
+	d := newConnectedDriver(t)
+	cfg := DefaultConfig()
+	cfg.RehomeAfterSlews = 1
+
+	now := time.Now()
+	d.drift.update(dome.Status{Slewing: true}, 0, false, now.Add(-time.Hour))
+	d.drift.update(dome.Status{}, 90, false, now.Add(-time.Hour))
+	assert.NotEmpty(t, d.drift.due(cfg, now))
+
+	// Slaved without a pause, the dome keeps following the telescope.
+	d.checkRehome(cfg, true, now)
+	assert.Equal(t, motionIdle, d.arbiter.current())
+	slews, _ := d.drift.counts()
+	assert.Equal(t, 1, slews)
+}
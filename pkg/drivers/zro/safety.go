@@ -0,0 +1,198 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/dome"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	safetyUID = "7a4f7c1e-3b5d-4f0e-9c2a-6d8e1f0b5a93"
+
+	defaultSafetyMaxHumidity      = 90
+	defaultSafetyTelemetryTimeout = time.Minute
+)
+
+// SafetyMonitor reports whether the ZRO dome is safe to observe under, from
+// the controller telemetry, so the clients pause imaging when the dome loses
+// contact with the driver or its shutter. The thresholds are set on the setup
+// page of the dome.
+type SafetyMonitor struct {
+	dome   *Driver
+	number int
+	uid    string
+	logger log.FieldLogger
+
+	connected atomic.Bool
+
+	mu      sync.Mutex
+	reasons []string // Reasons of the last unsafe state, nil while safe
+}
+
+// NewSafetyMonitor creates the safety monitor of a ZRO dome.
+func NewSafetyMonitor(dev alpaca.DeviceConfig, dome *Driver, logger log.FieldLogger) *SafetyMonitor {
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(safetyUID, dev.Key)
+	}
+	return &SafetyMonitor{dome: dome, number: dev.Number, uid: uid, logger: logger}
+}
+
+// FindDome returns the ZRO dome whose settings are saved under key, the
+// default dome if key is empty, among the devices.
+func FindDome(devices []alpaca.Device, key string) (*Driver, error) {
+	if key == "" {
+		key = configKey
+	}
+	for _, dev := range devices {
+		if d, ok := dev.(*Driver); ok && d.store.key == key {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("no ZRO dome with the settings key %q listed before the device", key)
+}
+
+func (s *SafetyMonitor) DeviceInfo() alpaca.DeviceInfo {
+	dome := s.dome.DeviceInfo()
+	return alpaca.DeviceInfo{
+		Name:        dome.Name + " Safety",
+		Description: "Safety of " + dome.Name + ", from the controller telemetry",
+		Type:        alpaca.DeviceTypeSafety,
+		Number:      s.number,
+		UniqueID:    s.uid,
+	}
+}
+
+func (s *SafetyMonitor) DriverInfo() alpaca.DriverInfo {
+	info := s.dome.DriverInfo()
+	info.InterfaceVersion = 3
+	return info
+}
+
+// Disabled reports whether the dome it watches is disabled.
+func (s *SafetyMonitor) Disabled() bool {
+	return s.dome.Disabled()
+}
+
+func (s *SafetyMonitor) GetState() []alpaca.StateProperty {
+	props := []alpaca.StateProperty{
+		alpaca.StateTimeStamp(time.Now()),
+	}
+	if s.connected.Load() {
+		reasons := s.unsafeReasons(time.Now())
+		props = append(props,
+			alpaca.StateProperty{Name: "IsSafe", Value: len(reasons) == 0},
+			alpaca.StateProperty{Name: "UnsafeReason", Value: strings.Join(reasons, ", ")},
+		)
+	}
+	return props
+}
+
+// Connect connects the monitor only: the dome keeps its own connection, and
+// is unsafe while it is not connected.
+func (s *SafetyMonitor) Connect() error {
+	if !s.connected.Swap(true) {
+		s.logger.Info("Safety monitor connected")
+	}
+	return nil
+}
+
+func (s *SafetyMonitor) Disconnect() error {
+	if s.connected.Swap(false) {
+		s.logger.Info("Safety monitor disconnected")
+	}
+	return nil
+}
+
+func (s *SafetyMonitor) Connected() bool {
+	return s.connected.Load()
+}
+
+func (s *SafetyMonitor) Connecting() bool {
+	return false
+}
+
+// IsSafe reports whether the dome is connected, its telemetry fresh, its
+// shutter linked and its battery and humidity within the thresholds. Each
+// change is logged with its reasons.
+func (s *SafetyMonitor) IsSafe() bool {
+	reasons := s.unsafeReasons(time.Now())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case len(reasons) > 0 && strings.Join(reasons, ", ") != strings.Join(s.reasons, ", "):
+		s.logger.Warnf("Unsafe: %s", strings.Join(reasons, ", "))
+	case len(reasons) == 0 && s.reasons != nil:
+		s.logger.Info("Safe again")
+	}
+	s.reasons = reasons
+	return len(reasons) == 0
+}
+
+// unsafeReasons returns why the dome is unsafe at now, nil if it is safe.
+func (s *SafetyMonitor) unsafeReasons(now time.Time) []string {
+	ctrl, err := s.dome.controller()
+	if err != nil {
+		return []string{"dome not connected"}
+	}
+	cfg, err := s.dome.store.GetConfig()
+	if err != nil {
+		return []string{"dome settings unreadable"}
+	}
+	return checkSafety(ctrl.GetStatus(), cfg, now)
+}
+
+// checkSafety returns why the dome in status st is unsafe at now under the
+// thresholds of cfg, nil if it is safe.
+func checkSafety(st dome.Status, cfg Config, now time.Time) []string {
+	var reasons []string
+	if cfg.SafetyTelemetryTimeout > 0 {
+		if st.TelemetryTime.IsZero() {
+			reasons = append(reasons, "no telemetry received")
+		} else if age := now.Sub(st.TelemetryTime); age > cfg.SafetyTelemetryTimeout {
+			reasons = append(reasons, fmt.Sprintf("no telemetry for %s", age.Round(time.Second)))
+		}
+	}
+	if cfg.UseShutter && !st.ShutterConnected {
+		reasons = append(reasons, "shutter link lost")
+	}
+	if voltage := float64(st.BatteryVoltage); cfg.UseShutter && cfg.LowBatteryVoltage > 0 && voltage > 0 && voltage < cfg.LowBatteryVoltage {
+		reasons = append(reasons, fmt.Sprintf("shutter battery at %.2f V", voltage))
+	}
+	if humidity := float64(st.Humidity); cfg.SafetyMaxHumidity > 0 && humidity > cfg.SafetyMaxHumidity {
+		reasons = append(reasons, fmt.Sprintf("humidity at %.0f%%", humidity))
+	}
+	return reasons
+}
+
+// HandleSetup shows the safety state; the thresholds are set on the setup
+// page of the dome.
+func (s *SafetyMonitor) HandleSetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	reasons := s.unsafeReasons(time.Now())
+	data := struct {
+		Name      string
+		Connected bool
+		Safe      bool
+		Reasons   []string
+		DomeSetup string
+	}{s.DeviceInfo().Name, s.connected.Load(), len(reasons) == 0, reasons, fmt.Sprintf("/setup/v1/dome/%d/setup", s.dome.number)}
+
+	if err := s.dome.tmpl.ExecuteTemplate(w, "zro_safety_setup.html", data); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		s.logger.Errorf("Error rendering template: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/dome"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSafety(t *testing.T) {
+	now := time.Now()
+	cfg := DefaultConfig()
+	cfg.UseShutter = true
+	cfg.LowBatteryVoltage = 12
+	safe := dome.Status{TelemetryTime: now, ShutterConnected: true, BatteryVoltage: 12.6, Humidity: 60}
+
+	assert.Empty(t, checkSafety(safe, cfg, now))
+
+	st := safe
+	st.TelemetryTime = time.Time{}
+	assert.Equal(t, []string{"no telemetry received"}, checkSafety(st, cfg, now))
+	st.TelemetryTime = now.Add(-2 * time.Minute)
+	assert.Equal(t, []string{"no telemetry for 2m0s"}, checkSafety(st, cfg, now))
+
+	st = safe
+	st.ShutterConnected = false
+	st.BatteryVoltage = 11.5
+	st.Humidity = 95
+	assert.Equal(t, []string{"shutter link lost", "shutter battery at 11.50 V", "humidity at 95%"}, checkSafety(st, cfg, now))
+
+	st.BatteryVoltage = 0
+	assert.NotContains(t, checkSafety(st, cfg, now), "shutter battery at 0.00 V", "battery not read yet")
+
+	cfg.UseShutter = false
+	cfg.SafetyMaxHumidity = 0
+	cfg.SafetyTelemetryTimeout = 0
+	assert.Empty(t, checkSafety(dome.Status{Humidity: 100}, cfg, now), "all checks disabled")
+}
+
+func TestSafetyMonitor(t *testing.T) {
+	d := newConnectedDriver(t)
+	cfg, err := d.store.GetConfig()
+	require.NoError(t, err)
+	cfg.SafetyTelemetryTimeout = 0
+	cfg.UseShutter = false
+	require.NoError(t, d.store.SetConfig(cfg))
+
+	s := NewSafetyMonitor(alpaca.DeviceConfig{Number: 1}, d, d.logger)
+	assert.Equal(t, alpaca.DeviceTypeSafety, s.DeviceInfo().Type)
+	assert.True(t, s.IsSafe())
+
+	require.NoError(t, d.Disconnect())
+	assert.False(t, s.IsSafe())
+	assert.Equal(t, []string{"dome not connected"}, s.reasons)
+}
+
+func TestFindDome(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	found, err := FindDome([]alpaca.Device{d}, "")
+	require.NoError(t, err)
+	assert.Same(t, d, found)
+
+	_, err = FindDome([]alpaca.Device{d}, "zro_config_2")
+	assert.Error(t, err)
+}
@@ -0,0 +1,256 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca/errors"
+	"alpaca/pkg/dome"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// slavingInterval is the period of the telescope position checks.
+	slavingInterval = 2 * time.Second
+
+	// defaultSlavingDeadband is the deadband used when none is configured,
+	// in degrees.
+	defaultSlavingDeadband = 2.0
+
+	// telescopeTimeout is the timeout of the requests to the telescope.
+	telescopeTimeout = 5 * time.Second
+
+	// telescopeClientID identifies the driver to the telescope server. Any
+	// fixed value works; 2 keeps the slaving requests easy to tell apart in
+	// the logs of the telescope server.
+	telescopeClientID = 2
+)
+
+// slavingPause is a pause of the slaving requested with the PauseSlaving
+// action. A zero until pauses the slaving until ResumeSlaving is called.
+type slavingPause struct {
+	until time.Time
+}
+
+// slave keeps the dome aligned with the telescope while the driver is slaved,
+// until the context is cancelled.
+func (d *Driver) slave(ctx context.Context, ctrl *dome.Dome) {
+	ticker := time.NewTicker(slavingInterval)
+	defer ticker.Stop()
+
+	var telescope telescope
+	var telescopeURL, telescopeSource string
+	var pausedBy string
+	var lastCorrection time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg, err := d.store.GetConfig()
+		if err != nil || !cfg.Slaving || cfg.TelescopeURL == "" {
+			continue
+		}
+
+		d.mu.RLock()
+		slaved := d.slaved
+		d.mu.RUnlock()
+		if !slaved {
+			continue
+		}
+
+		// Log the pauses when they start and end, not on every check.
+		reason := d.slavingPausedBy(cfg, time.Now())
+		if reason != pausedBy {
+			if reason != "" {
+				d.logger.Infof("Slaving paused: %s", reason)
+			} else {
+				d.logger.Info("Slaving resumed")
+			}
+			pausedBy = reason
+		}
+		if reason != "" {
+			continue
+		}
+
+		if telescope == nil || telescopeURL != cfg.TelescopeURL || telescopeSource != cfg.TelescopeSource {
+			telescope = newTelescope(cfg)
+			telescopeURL, telescopeSource = cfg.TelescopeURL, cfg.TelescopeSource
+		}
+
+		if time.Since(lastCorrection) < cfg.SlavingMinInterval {
+			continue
+		}
+
+		corrected, err := d.followTelescope(ctx, ctrl, telescope, cfg.slavingDeadband())
+		if err != nil {
+			d.logger.Warnf("Slaving: %v", err)
+		}
+		if corrected {
+			lastCorrection = time.Now()
+		}
+	}
+}
+
+// followTelescope slews the dome to the telescope azimuth if they are
+// further apart than the deadband, and reports whether it did. Corrections
+// are skipped while the telescope or the dome slews, so a meridian flip is
+// followed once the mount settles.
+func (d *Driver) followTelescope(ctx context.Context, ctrl *dome.Dome, telescope telescope, deadband float64) (bool, error) {
+	azimuth, slewing, err := telescope.position(ctx)
+	if err != nil || slewing {
+		return false, err
+	}
+
+	st := ctrl.GetStatus()
+	if st.Slewing || d.interlocked(ctrl) {
+		return false, nil
+	}
+
+	current := ctrl.TicksToDegrees(st.Position)
+	if !needsCorrection(current, azimuth, deadband) {
+		return false, nil
+	}
+
+	if err := d.watchdog.allow(); err != nil {
+		d.logger.Debugf("Slaving: correction blocked, %v", err)
+		return false, nil
+	}
+
+	// A manual or safety motion in progress keeps the dome until it ends.
+	now := time.Now()
+	d.arbiter.settle(st.Slewing, now)
+	if err := d.arbiter.acquire(motionSlaving, fmt.Sprintf("follow the telescope to %.1f degrees", azimuth), now); err != nil {
+		d.logger.Debugf("Slaving: correction deferred, %v", err)
+		return false, nil
+	}
+
+	d.logger.Infof("Slaving: moving the dome from %.1f to %.1f degrees", current, azimuth)
+	d.watchdog.expect(slewDuration(ctrl.Config(), azimuthTicks(ctrl.Config(), current, azimuth)), now)
+	return true, ctrl.SlewToAzimuth(azimuth)
+}
+
+// needsCorrection reports whether the dome is outside the deadband around
+// the telescope azimuth.
+func needsCorrection(dome, telescope, deadband float64) bool {
+	return azimuthDistance(dome, telescope) > deadband
+}
+
+// azimuthDistance returns the shortest angle between two azimuths.
+func azimuthDistance(a, b float64) float64 {
+	diff := math.Mod(math.Abs(a-b), 360)
+	return math.Min(diff, 360-diff)
+}
+
+// slavingPausedBy returns why the slaving is paused at the given time, or an
+// empty string if it is not. An expired action pause is cleared.
+func (d *Driver) slavingPausedBy(cfg Config, now time.Time) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pause != nil {
+		switch {
+		case d.pause.until.IsZero():
+			return "by action until resumed"
+		case now.Before(d.pause.until):
+			return fmt.Sprintf("by action until %s", d.pause.until.Format(time.TimeOnly))
+		default:
+			d.pause = nil
+		}
+	}
+
+	for _, window := range cfg.SlavingPauseWindows {
+		if w, err := parsePauseWindow(window); err == nil && w.contains(now) {
+			return fmt.Sprintf("in window %s", window)
+		}
+	}
+	return ""
+}
+
+// pauseSlaving pauses the slaving for the duration given in the parameters,
+// as a Go duration ("15m") or in seconds. Without a duration the slaving is
+// paused until resumed.
+func (d *Driver) pauseSlaving(parameters string) (string, error) {
+	pause := &slavingPause{}
+
+	if parameters = strings.TrimSpace(parameters); parameters != "" {
+		duration, err := time.ParseDuration(parameters)
+		if err != nil {
+			seconds, serr := strconv.ParseFloat(parameters, 64)
+			if serr != nil {
+				return "", errors.Errorf(errors.ErrInvalidValue, "invalid pause duration %q", parameters)
+			}
+			duration = time.Duration(seconds * float64(time.Second))
+		}
+		if duration <= 0 {
+			return "", errors.Errorf(errors.ErrInvalidValue, "invalid pause duration %q", parameters)
+		}
+		pause.until = time.Now().Add(duration)
+	}
+
+	d.mu.Lock()
+	d.pause = pause
+	d.mu.Unlock()
+
+	if pause.until.IsZero() {
+		d.logger.Info("Slaving paused until resumed")
+		return "paused until resumed", nil
+	}
+	d.logger.Infof("Slaving paused until %s", pause.until.Format(time.TimeOnly))
+	return "paused until " + pause.until.Format(time.RFC3339), nil
+}
+
+// resumeSlaving cancels a pause requested with the PauseSlaving action.
+func (d *Driver) resumeSlaving() (string, error) {
+	d.mu.Lock()
+	d.pause = nil
+	d.mu.Unlock()
+
+	d.logger.Info("Slaving pause cancelled")
+	return "resumed", nil
+}
+
+// pauseWindow is a daily time window, in minutes since local midnight. A
+// window whose end is before its start spans midnight.
+type pauseWindow struct {
+	start, end int
+}
+
+// parsePauseWindow parses a window in the "HH:MM-HH:MM" format.
+func parsePauseWindow(s string) (pauseWindow, error) {
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return pauseWindow{}, fmt.Errorf("invalid pause window %q, expected HH:MM-HH:MM", s)
+	}
+
+	parse := func(v string) (int, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("invalid pause window %q, expected HH:MM-HH:MM", s)
+		}
+		return t.Hour()*60 + t.Minute(), nil
+	}
+
+	start, err := parse(startStr)
+	if err != nil {
+		return pauseWindow{}, err
+	}
+	end, err := parse(endStr)
+	if err != nil {
+		return pauseWindow{}, err
+	}
+	return pauseWindow{start: start, end: end}, nil
+}
+
+func (w pauseWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
@@ -0,0 +1,75 @@
+package zro
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 5, 1, hour, minute, 0, 0, time.Local)
+	}
+
+	w, err := parsePauseWindow("19:30-20:00")
+	require.NoError(t, err)
+	assert.False(t, w.contains(at(19, 29)))
+	assert.True(t, w.contains(at(19, 30)))
+	assert.False(t, w.contains(at(20, 0)))
+
+	// A window spanning midnight
+	w, err = parsePauseWindow("23:00 - 01:00")
+	require.NoError(t, err)
+	assert.True(t, w.contains(at(23, 30)))
+	assert.True(t, w.contains(at(0, 30)))
+	assert.False(t, w.contains(at(1, 0)))
+
+	_, err = parsePauseWindow("19:30")
+	assert.Error(t, err)
+	_, err = parsePauseWindow("25:00-26:00")
+	assert.Error(t, err)
+}
+
+func TestPauseSlavingAction(t *testing.T) {
+	d := &Driver{driverState: &driverState{logger: log.StandardLogger()}}
+	cfg := DefaultConfig()
+	now := time.Now()
+
+	assert.Empty(t, d.slavingPausedBy(cfg, now))
+
+	_, err := d.Action(actionPauseSlaving, "10m")
+	require.NoError(t, err)
+	assert.NotEmpty(t, d.slavingPausedBy(cfg, now))
+	assert.Empty(t, d.slavingPausedBy(cfg, now.Add(11*time.Minute)), "resumed automatically")
+	assert.Nil(t, d.pause)
+
+	_, err = d.Action(actionPauseSlaving, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, d.slavingPausedBy(cfg, now.Add(24*time.Hour)))
+
+	_, err = d.Action(actionResumeSlaving, "")
+	require.NoError(t, err)
+	assert.Empty(t, d.slavingPausedBy(cfg, now))
+
+	_, err = d.Action(actionPauseSlaving, "soon")
+	assert.Error(t, err)
+}
+
+func TestAzimuthDistance(t *testing.T) {
+	assert.InDelta(t, 10.0, azimuthDistance(355, 5), 1e-9)
+	assert.InDelta(t, 180.0, azimuthDistance(0, 180), 1e-9)
+	assert.InDelta(t, 30.0, azimuthDistance(100, 70), 1e-9)
+}
+
+func TestNeedsCorrection(t *testing.T) {
+	assert.False(t, needsCorrection(100, 101.5, 2))
+	assert.True(t, needsCorrection(100, 102.5, 2))
+	assert.False(t, needsCorrection(359, 1, 2.5), "across north")
+	assert.True(t, needsCorrection(359, 1, 1))
+
+	assert.Equal(t, defaultSlavingDeadband, Config{}.slavingDeadband())
+	assert.Equal(t, 5.0, Config{SlavingDeadband: 5}.slavingDeadband())
+}
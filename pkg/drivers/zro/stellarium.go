@@ -0,0 +1,115 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// Values of Config.TelescopeSource.
+const (
+	sourceAlpaca     = ""           // An Alpaca telescope
+	sourceStellarium = "stellarium" // The view of Stellarium, through its Remote Control plugin
+)
+
+// stellariumSettle is the motion of the Stellarium view between two
+// readings, in degrees, above which the view is still moving, as a telescope
+// slewing.
+const stellariumSettle = 0.5
+
+// telescope is the source of the azimuth followed by the dome while slaved.
+type telescope interface {
+	// position returns the telescope azimuth in degrees, and whether the
+	// telescope slews, in which case the azimuth may be unknown.
+	position(ctx context.Context) (azimuth float64, slewing bool, err error)
+}
+
+// newTelescope returns the telescope followed with a configuration.
+func newTelescope(cfg Config) telescope {
+	if cfg.TelescopeSource == sourceStellarium {
+		return &stellarium{baseURL: strings.TrimSuffix(cfg.TelescopeURL, "/"), client: &http.Client{Timeout: telescopeTimeout}}
+	}
+	return alpacaTelescope{alpaca.NewClient(cfg.TelescopeURL, telescopeClientID, telescopeTimeout)}
+}
+
+// alpacaTelescope is an Alpaca telescope.
+type alpacaTelescope struct {
+	client *alpaca.Client
+}
+
+func (t alpacaTelescope) position(ctx context.Context) (float64, bool, error) {
+	var slewing bool
+	if err := t.client.Get(ctx, "slewing", &slewing); err != nil {
+		return 0, false, fmt.Errorf("failed to read the telescope slewing state: %v", err)
+	}
+	if slewing {
+		return 0, true, nil
+	}
+
+	var azimuth float64
+	if err := t.client.Get(ctx, "azimuth", &azimuth); err != nil {
+		return 0, false, fmt.Errorf("failed to read the telescope azimuth: %v", err)
+	}
+	return azimuth, false, nil
+}
+
+// stellarium follows the view of Stellarium as a telescope, through the
+// Remote Control plugin, to rehearse an observing session with the dome
+// during development.
+type stellarium struct {
+	baseURL string // Remote Control URL, e.g. http://localhost:8093
+	client  *http.Client
+
+	last    float64 // Azimuth of the previous reading
+	hasLast bool
+}
+
+func (s *stellarium) position(ctx context.Context) (float64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/api/main/view", nil)
+	if err != nil {
+		return 0, false, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read the Stellarium view: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, false, fmt.Errorf("failed to read the Stellarium view: %s", resp.Status)
+	}
+
+	var view struct {
+		AltAz string `json:"altAz"` // Direction of the view, as a "[x, y, z]" vector
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&view); err != nil {
+		return 0, false, fmt.Errorf("invalid Stellarium view: %v", err)
+	}
+	azimuth, err := stellariumAzimuth(view.AltAz)
+	if err != nil {
+		return 0, false, err
+	}
+
+	// A view still moving is followed once it stops, as a slewing telescope.
+	moving := s.hasLast && azimuthDistance(azimuth, s.last) > stellariumSettle
+	s.last, s.hasLast = azimuth, true
+	return azimuth, moving, nil
+}
+
+// stellariumAzimuth converts the alt-az direction vector of Stellarium,
+// whose x axis points south and y axis east, to an azimuth from the north
+// through the east.
+func stellariumAzimuth(vector string) (float64, error) {
+	var v []float64
+	if err := json.Unmarshal([]byte(vector), &v); err != nil || len(v) != 3 {
+		return 0, fmt.Errorf("invalid Stellarium direction %q", vector)
+	}
+	if v[0] == 0 && v[1] == 0 {
+		return 0, fmt.Errorf("the Stellarium view points to the zenith")
+	}
+	azimuth := 180 - math.Atan2(v[1], v[0])*180/math.Pi
+	return math.Mod(azimuth+360, 360), nil
+}
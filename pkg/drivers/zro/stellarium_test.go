@@ -0,0 +1,64 @@
+package zro
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStellariumAzimuth(t *testing.T) {
+	tests := []struct {
+		vector  string
+		azimuth float64
+	}{
+		{"[-1, 0, 0]", 0},
+		{"[0, 1, 0]", 90},
+		{"[1, 0, 0.5]", 180},
+		{"[0, -1, 0]", 270},
+		{"[-0.5, 0.5, 0.7]", 45},
+	}
+	for _, tt := range tests {
+		azimuth, err := stellariumAzimuth(tt.vector)
+		require.NoError(t, err, tt.vector)
+		assert.InDelta(t, tt.azimuth, azimuth, 1e-9, tt.vector)
+	}
+
+	_, err := stellariumAzimuth("[0, 0, 1]")
+	assert.Error(t, err, "no azimuth at the zenith")
+	_, err = stellariumAzimuth("[1, 0]")
+	assert.Error(t, err)
+}
+
+func TestStellariumPosition(t *testing.T) {
+	vector := "[0, 1, 0]"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/main/view", r.URL.Path)
+		fmt.Fprintf(w, `{"altAz": %q, "j2000": "[1, 0, 0]", "jNow": "[1, 0, 0]"}`, vector)
+	}))
+	defer ts.Close()
+
+	telescope := newTelescope(Config{TelescopeURL: ts.URL + "/", TelescopeSource: sourceStellarium})
+	azimuth, slewing, err := telescope.position(context.Background())
+	require.NoError(t, err)
+	assert.InDelta(t, 90, azimuth, 1e-9)
+	assert.False(t, slewing)
+
+	// The view is moving between the readings, then stops.
+	vector = "[-1, 0, 0]"
+	_, slewing, err = telescope.position(context.Background())
+	require.NoError(t, err)
+	assert.True(t, slewing)
+	azimuth, slewing, err = telescope.position(context.Background())
+	require.NoError(t, err)
+	assert.InDelta(t, 0, azimuth, 1e-9)
+	assert.False(t, slewing)
+
+	ts.Close()
+	_, _, err = telescope.position(context.Background())
+	assert.Error(t, err)
+}
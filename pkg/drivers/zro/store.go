@@ -1,9 +1,11 @@
 package zro
 
 import (
+	"alpaca/pkg/alpaca"
 	"alpaca/pkg/dome"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	bolt "go.etcd.io/bbolt"
@@ -14,13 +16,130 @@ const (
 	configKey = "zro_config"
 )
 
+// configVersion is the version of the stored configuration layout.
+// Version 1 stores the timeouts as time.Duration instead of integers in
+// firmware units.
+const configVersion = 1
+
+// defaultDescription is the device description of new configurations.
+const defaultDescription = "ZRO Dome fw {firmware}"
+
+// Values of Config.AbortedShutter.
+const (
+	abortedAsError = "error" // Report an aborted shutter as ShutterError
+	abortedAsOpen  = "open"  // Report an aborted shutter as ShutterOpen, since it is partially open
+)
+
+// Config is the ZRO driver configuration: the dome controller settings plus
+// the options of the Alpaca driver itself. The controller settings are
+// embedded so the stored JSON layout is unchanged.
+type Config struct {
+	dome.Config
+
+	Version          int  // Layout version of the stored configuration
+	Disabled         bool // True if the dome is hidden from the configured devices
+	ConnectOnStartup bool // True to connect when the server starts, without waiting for a client
+
+	Description    string // Device description, with {firmware}, {driver} and {host} placeholders
+	AbortedShutter string // Alpaca shutter status reported for an aborted shutter
+	Slaving        bool   // True if the dome can be slaved to a telescope
+
+	ShutterInterlock bool // True to hold azimuth motions while the shutter opens or closes
+
+	TelescopeURL        string        // Alpaca URL of the telescope followed when slaved, e.g. http://host:11111/api/v1/telescope/0
+	TelescopeSource     string        // Kind of telescope at TelescopeURL: empty for Alpaca, stellarium for the Stellarium Remote Control plugin
+	SlavingPauseWindows []string      // Daily local time windows, as HH:MM-HH:MM, where the slaving is paused
+	SlavingDeadband     float64       // Dome to telescope azimuth difference tolerated before a correction, in degrees
+	SlavingMinInterval  time.Duration // Minimum time between two slaving corrections
+
+	LowBatteryVoltage float64 // Shutter battery voltage that raises a low battery notification, 0 to disable
+
+	Presets []Preset // Named azimuths, slewed to with the SlewToPreset action
+	Relays  []Relay  // Controller relays served by the zro_switch device
+
+	SafetyMaxHumidity      float64       // Controller humidity above which the safety monitor reports unsafe, 0 to ignore
+	SafetyTelemetryTimeout time.Duration // Time without telemetry after which the safety monitor reports unsafe, 0 to ignore
+
+	ShutterCurrentDisabled   bool    // True to never abort the shutter for drawing too much current
+	ShutterOvercurrentFactor float64 // Multiple of the learned peak current of the shutter motor that aborts an operation
+
+	RunawayWatchdogDisabled bool          // True to never abort slews lasting longer than expected
+	RunawayMargin           time.Duration // Time allowed beyond twice the expected slew duration before a slew is aborted
+
+	RehomeAfterSlews    int     // Slews after which the dome searches its home once idle, 0 to disable
+	RehomeAfterRotation float64 // Degrees turned after which the dome searches its home once idle, 0 to disable
+}
+
+// DefaultConfig returns the default ZRO driver configuration.
+func DefaultConfig() Config {
+	return Config{
+		Config:                   dome.DefaultConfig(),
+		Version:                  configVersion,
+		Description:              defaultDescription,
+		AbortedShutter:           abortedAsError,
+		LowBatteryVoltage:        11.8,
+		SafetyMaxHumidity:        defaultSafetyMaxHumidity,
+		SafetyTelemetryTimeout:   defaultSafetyTelemetryTimeout,
+		ShutterOvercurrentFactor: defaultOvercurrentFactor,
+		SlavingDeadband:          defaultSlavingDeadband,
+		SlavingMinInterval:       10 * time.Second,
+		RunawayMargin:            defaultRunawayMargin,
+	}
+}
+
+// slavingDeadband returns the configured deadband, or the default one for
+// configurations stored before it was added.
+func (c Config) slavingDeadband() float64 {
+	if c.SlavingDeadband <= 0 {
+		return defaultSlavingDeadband
+	}
+	return c.SlavingDeadband
+}
+
 type store struct {
-	db *bolt.DB
+	db  *bolt.DB
+	key string // Database key of the configuration
 }
 
-// NewStore creates a new store instance and sets default values if they are not already set.
+// NewStore creates a new store instance, migrates the configuration written by
+// the legacy binary and sets default values if they are not already set.
 func NewStore(db *bolt.DB) (*store, error) {
-	st := store{db: db}
+	return NewStoreWithKey(db, configKey)
+}
+
+// NewStoreWithKey creates a store for the configuration saved under key, for
+// additional dome instances. The migrations only apply to the default key,
+// since the other instances were created with the current layout.
+func NewStoreWithKey(db *bolt.DB, key string) (*store, error) {
+	st := store{db: db, key: key}
+	if key != configKey {
+		if err := st.setDefaults(); err != nil {
+			return nil, err
+		}
+		return &st, nil
+	}
+
+	migrated, err := migrateLegacyConfig(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate legacy config: %v", err)
+	}
+	if migrated {
+		log.Info("Migrated legacy MQTT and dome config")
+	}
+
+	converted, err := migrateTimeoutUnits(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate timeout units: %v", err)
+	}
+	if converted {
+		log.Info("Converted the stored timeouts to durations")
+	}
+
+	if migrated || converted {
+		if err := alpaca.BackupDB(db); err != nil {
+			log.Warnf("Failed to back up database: %v", err)
+		}
+	}
 
 	if err := st.setDefaults(); err != nil {
 		return nil, err
@@ -32,28 +151,36 @@ func NewStore(db *bolt.DB) (*store, error) {
 func (s *store) setDefaults() error {
 	if _, err := s.GetConfig(); err != nil {
 		log.Infof("Setting default MQTT config")
-		s.SetConfig(dome.DefaultConfig())
+		s.SetConfig(DefaultConfig())
 	}
 
 	return nil
 }
 
 // SetConfig saves the dome configuration as a json string in the database.
-func (s *store) SetConfig(cfg dome.Config) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
+func (s *store) SetConfig(cfg Config) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
 		if err != nil {
 			return err
 		}
 
 		value, _ := json.Marshal(cfg)
-		return b.Put([]byte(configKey), value)
+		return b.Put([]byte(s.key), value)
 	})
+	if err != nil {
+		return err
+	}
+
+	if err := alpaca.BackupDB(s.db); err != nil {
+		log.Warnf("Failed to back up database: %v", err)
+	}
+	return nil
 }
 
 // GetConfig retrieves the dome configuration from the database.
-func (s *store) GetConfig() (dome.Config, error) {
-	var cfg dome.Config
+func (s *store) GetConfig() (Config, error) {
+	var cfg Config
 
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucket))
@@ -61,7 +188,7 @@ func (s *store) GetConfig() (dome.Config, error) {
 			return fmt.Errorf("bucket %s not found", bucket)
 		}
 
-		value := b.Get([]byte(configKey))
+		value := b.Get([]byte(s.key))
 		if value == nil {
 			return fmt.Errorf("key config not found")
 		}
@@ -71,3 +198,58 @@ func (s *store) GetConfig() (dome.Config, error) {
 
 	return cfg, err
 }
+
+// UpdateConfig applies update to the stored configuration in a single
+// transaction, so concurrent updates are not lost. Nothing is saved if update
+// returns an error.
+func (s *store) UpdateConfig(update func(*Config) error) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return fmt.Errorf("bucket %s not found", bucket)
+		}
+
+		var cfg Config
+		if err := json.Unmarshal(b.Get([]byte(s.key)), &cfg); err != nil {
+			return err
+		}
+		if err := update(&cfg); err != nil {
+			return err
+		}
+
+		value, _ := json.Marshal(cfg)
+		return b.Put([]byte(s.key), value)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := alpaca.BackupDB(s.db); err != nil {
+		log.Warnf("Failed to back up database: %v", err)
+	}
+	return nil
+}
+
+// ApplySettings merges the settings of a device file into the ZRO dome
+// configuration saved under key, the default key if empty, and saves it if
+// they changed it.
+func ApplySettings(db *bolt.DB, key string, settings json.RawMessage) error {
+	if key == "" {
+		key = configKey
+	}
+
+	st, err := NewStoreWithKey(db, key)
+	if err != nil {
+		return err
+	}
+	cfg, err := st.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	changed, err := alpaca.MergeSettings(&cfg, settings)
+	if err != nil || !changed {
+		return err
+	}
+	return st.SetConfig(cfg)
+}
@@ -0,0 +1,283 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"alpaca/pkg/dome"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const switchUID = "b8e1d4a7-5c2f-4e93-8a6b-0f3d7c9e2a51"
+
+// maxRelays bounds the number of relays of a dome.
+const maxRelays = 8
+
+// Switches of the battery, before the relays.
+const (
+	switchBatteryVoltage = iota
+	switchBatteryCurrent
+	batterySwitches
+)
+
+// Relay is an output of the controller, switched with a raw command for each
+// state, since the relays depend on how the controller is wired.
+type Relay struct {
+	Name string
+	On   string // Command that closes the relay, such as _Y1=1;
+	Off  string // Command that opens the relay
+}
+
+// parseRelays parses the relays of the setup page, one per line as
+// "name, on command, off command".
+func parseRelays(text string) ([]Relay, error) {
+	var relays []Relay
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid relay %q, expected name, on command, off command", line)
+		}
+		relay := Relay{Name: strings.TrimSpace(fields[0]), On: strings.TrimSpace(fields[1]), Off: strings.TrimSpace(fields[2])}
+		if relay.Name == "" || relay.On == "" || relay.Off == "" {
+			return nil, fmt.Errorf("invalid relay %q, expected name, on command, off command", line)
+		}
+		for _, cmd := range []string{relay.On, relay.Off} {
+			if err := dome.CheckRelayCommand(cmd); err != nil {
+				return nil, fmt.Errorf("invalid relay %q: %v", relay.Name, err)
+			}
+		}
+		relays = append(relays, relay)
+	}
+	if len(relays) > maxRelays {
+		return nil, fmt.Errorf("at most %d relays are allowed", maxRelays)
+	}
+	return relays, nil
+}
+
+// Switch serves the shutter battery readings as read-only switches and the
+// relays of the ZRO controller as boolean switches.
+type Switch struct {
+	dome   *Driver
+	number int
+	uid    string
+	logger log.FieldLogger
+
+	connected atomic.Bool
+
+	mu     sync.Mutex
+	states map[int]bool // Last state set of each relay, by switch number
+}
+
+// NewSwitch creates the Switch device of a ZRO dome.
+func NewSwitch(dev alpaca.DeviceConfig, dome *Driver, logger log.FieldLogger) *Switch {
+	uid := dev.UniqueID
+	if uid == "" {
+		uid = alpaca.InstanceUID(switchUID, dev.Key)
+	}
+	return &Switch{dome: dome, number: dev.Number, uid: uid, logger: logger, states: make(map[int]bool)}
+}
+
+func (s *Switch) DeviceInfo() alpaca.DeviceInfo {
+	dome := s.dome.DeviceInfo()
+	return alpaca.DeviceInfo{
+		Name:        dome.Name + " Power",
+		Description: "Shutter battery and relays of " + dome.Name,
+		Type:        alpaca.DeviceTypeSwitch,
+		Number:      s.number,
+		UniqueID:    s.uid,
+	}
+}
+
+func (s *Switch) DriverInfo() alpaca.DriverInfo {
+	info := s.dome.DriverInfo()
+	info.InterfaceVersion = 2
+	return info
+}
+
+// Disabled reports whether the dome it belongs to is disabled.
+func (s *Switch) Disabled() bool {
+	return s.dome.Disabled()
+}
+
+func (s *Switch) GetState() []alpaca.StateProperty {
+	props := []alpaca.StateProperty{
+		alpaca.StateTimeStamp(time.Now()),
+	}
+	if s.connected.Load() {
+		for id, ch := range s.Switches() {
+			if value, err := s.SwitchValue(id); err == nil {
+				props = append(props, alpaca.StateProperty{Name: ch.Name, Value: value})
+			}
+		}
+	}
+	return props
+}
+
+// Connect connects the device only: the dome keeps its own connection, and
+// the switches cannot be read or set while it is not connected.
+func (s *Switch) Connect() error {
+	if !s.connected.Swap(true) {
+		s.logger.Info("Dome switches connected")
+	}
+	return nil
+}
+
+func (s *Switch) Disconnect() error {
+	if s.connected.Swap(false) {
+		s.logger.Info("Dome switches disconnected")
+	}
+	return nil
+}
+
+func (s *Switch) Connected() bool {
+	return s.connected.Load()
+}
+
+func (s *Switch) Connecting() bool {
+	return false
+}
+
+// relays returns the relays configured for the dome.
+func (s *Switch) relays() []Relay {
+	cfg, err := s.dome.store.GetConfig()
+	if err != nil {
+		return nil
+	}
+	return cfg.Relays
+}
+
+// Switches lists the battery voltage and current, then the relays.
+func (s *Switch) Switches() []alpaca.SwitchChannel {
+	channels := []alpaca.SwitchChannel{
+		{Name: "Shutter battery voltage", Description: "Voltage of the shutter battery, in volts", Min: 0, Max: 30, Step: 0.01},
+		{Name: "Shutter battery current", Description: "Current drawn from the shutter battery, in amperes", Min: -20, Max: 20, Step: 0.01},
+	}
+	for _, r := range s.relays() {
+		channels = append(channels, alpaca.SwitchChannel{
+			Name:        r.Name,
+			Description: "Controller relay, switched with " + r.On + " and " + r.Off,
+			CanWrite:    true,
+			Min:         0,
+			Max:         1,
+			Step:        1,
+		})
+	}
+	return channels
+}
+
+// SwitchValue returns the last battery reading, or the last state set of a
+// relay, since the controller does not report them.
+func (s *Switch) SwitchValue(id int) (float64, error) {
+	ctrl, err := s.dome.controller()
+	if err != nil {
+		return 0, err
+	}
+
+	if id < batterySwitches {
+		st := ctrl.GetStatus()
+		// A zero voltage means the battery has not been read yet.
+		if !ctrl.Config().UseShutter || st.BatteryVoltage == 0 {
+			return 0, errors.Errorf(errors.ErrValueNotSet, "the shutter battery has not been read")
+		}
+		if id == switchBatteryVoltage {
+			return float64(st.BatteryVoltage), nil
+		}
+		return float64(st.BatteryCurrent), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	on, ok := s.states[id]
+	if !ok {
+		return 0, errors.Errorf(errors.ErrValueNotSet, "relay %d has not been set since the driver started", id)
+	}
+	if on {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// SetSwitchValue sends the command of a relay for the state.
+func (s *Switch) SetSwitchValue(id int, value float64) error {
+	relays := s.relays()
+	if id < batterySwitches || id-batterySwitches >= len(relays) {
+		return errors.Errorf(errors.ErrNotImplemented, "switch %d is read-only", id)
+	}
+	ctrl, err := s.dome.controller()
+	if err != nil {
+		return err
+	}
+
+	relay, on := relays[id-batterySwitches], value != 0
+	state, cmd := "off", relay.Off
+	if on {
+		state, cmd = "on", relay.On
+	}
+	s.logger.Infof("Relay %s %s: %s", relay.Name, state, cmd)
+	if _, err := ctrl.SendRelay(cmd); err != nil {
+		return deviceError(err)
+	}
+
+	s.mu.Lock()
+	s.states[id] = on
+	s.mu.Unlock()
+	return nil
+}
+
+// SetSwitchName renames a relay in the dome settings.
+func (s *Switch) SetSwitchName(id int, name string) error {
+	if name = strings.TrimSpace(name); name == "" || strings.Contains(name, ",") {
+		return errors.Errorf(errors.ErrInvalidValue, "invalid relay name %q", name)
+	}
+	return s.dome.store.UpdateConfig(func(cfg *Config) error {
+		i := id - batterySwitches
+		if i < 0 || i >= len(cfg.Relays) {
+			return errors.Errorf(errors.ErrNotImplemented, "switch %d is read-only", id)
+		}
+		cfg.Relays[i].Name = name
+		return nil
+	})
+}
+
+// HandleSetup shows the switches; the relays are set up on the setup page
+// of the dome.
+func (s *Switch) HandleSetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type row struct {
+		Name  string
+		Value string
+	}
+	var rows []row
+	for id, ch := range s.Switches() {
+		value := "not available"
+		if v, err := s.SwitchValue(id); err == nil {
+			value = fmt.Sprintf("%g", v)
+		}
+		rows = append(rows, row{ch.Name, value})
+	}
+
+	data := struct {
+		Name      string
+		Connected bool
+		Switches  []row
+		DomeSetup string
+	}{s.DeviceInfo().Name, s.connected.Load(), rows, fmt.Sprintf("/setup/v1/dome/%d/setup", s.dome.number)}
+
+	if err := s.dome.tmpl.ExecuteTemplate(w, "zro_switch_setup.html", data); err != nil {
+		http.Error(w, "Error rendering template", http.StatusInternalServerError)
+		s.logger.Errorf("Error rendering template: %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRelays(t *testing.T) {
+	relays, err := parseRelays("Flat panel, _Y1=1;, _Y1=0;\n\n  Dew heater ,_Y2=1;,_Y2=0;  \n")
+	require.NoError(t, err)
+	assert.Equal(t, []Relay{
+		{Name: "Flat panel", On: "_Y1=1;", Off: "_Y1=0;"},
+		{Name: "Dew heater", On: "_Y2=1;", Off: "_Y2=0;"},
+	}, relays)
+
+	relays, err = parseRelays("")
+	require.NoError(t, err)
+	assert.Empty(t, relays)
+
+	for _, text := range []string{"Flat panel, _Y1=1;", ", _Y1=1;, _Y1=0;", "Flat panel, , _Y1=0;", "Flat panel, _O;, _C;", "Flat panel, _Y1=1;, _LTICK=0;"} {
+		_, err := parseRelays(text)
+		assert.Error(t, err, text)
+	}
+
+	var many string
+	for i := 0; i <= maxRelays; i++ {
+		many += "r, on, off\n"
+	}
+	_, err = parseRelays(many)
+	assert.Error(t, err)
+}
+
+func TestSwitch(t *testing.T) {
+	d := newConnectedDriver(t)
+	require.NoError(t, d.store.UpdateConfig(func(cfg *Config) error {
+		cfg.Relays = []Relay{{Name: "Flat panel", On: "_Y1=1;", Off: "_Y1=0;"}}
+		return nil
+	}))
+	s := NewSwitch(alpaca.DeviceConfig{Number: 0}, d, d.logger)
+	assert.Equal(t, alpaca.DeviceTypeSwitch, s.DeviceInfo().Type)
+
+	channels := s.Switches()
+	require.Len(t, channels, 3)
+	assert.False(t, channels[switchBatteryVoltage].CanWrite)
+	assert.True(t, channels[2].CanWrite)
+	assert.Equal(t, "Flat panel", channels[2].Name)
+
+	_, err := s.SwitchValue(switchBatteryVoltage)
+	assert.ErrorIs(t, err, errors.ErrValueNotSet, "the battery has not been read")
+	_, err = s.SwitchValue(2)
+	assert.ErrorIs(t, err, errors.ErrValueNotSet, "the relay has not been set")
+	assert.ErrorIs(t, s.SetSwitchValue(switchBatteryCurrent, 1), errors.ErrNotImplemented)
+
+	require.NoError(t, s.SetSwitchName(2, "Panel"))
+	assert.Equal(t, "Panel", s.Switches()[2].Name)
+	assert.ErrorIs(t, s.SetSwitchName(2, "a, b"), errors.ErrInvalidValue)
+	assert.ErrorIs(t, s.SetSwitchName(switchBatteryVoltage, "Volts"), errors.ErrNotImplemented)
+
+	require.NoError(t, d.Disconnect())
+	_, err = s.SwitchValue(2)
+	assert.ErrorIs(t, err, errors.ErrNotConnected)
+}
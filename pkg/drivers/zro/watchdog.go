@@ -0,0 +1,172 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/alpaca/errors"
+	"alpaca/pkg/dome"
+	"alpaca/pkg/notify"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// runawayFactor is how many times its expected duration a slew may last,
+	// plus the configured margin, before it is aborted as a runaway.
+	runawayFactor = 2
+
+	// defaultRunawayMargin is the margin of configurations stored before it
+	// was added.
+	defaultRunawayMargin = 30 * time.Second
+)
+
+// runawayMargin returns the configured margin, or the default one for
+// configurations stored before it was added.
+func (c Config) runawayMargin() time.Duration {
+	if c.RunawayMargin <= 0 {
+		return defaultRunawayMargin
+	}
+	return c.RunawayMargin
+}
+
+// slewDuration returns the expected duration of a slew over a number of
+// encoder ticks at the maximum speed.
+func slewDuration(cfg dome.Config, ticks int) time.Duration {
+	return time.Duration(float64(ticks) / float64(cfg.MaxSpeed) * float64(time.Second))
+}
+
+// slewEstimate returns the expected duration of a slew between two azimuths
+// at a speed in degrees per second, measured over the past slews so that it
+// includes the acceleration and the braking, or at the maximum speed while
+// none is known.
+func slewEstimate(cfg dome.Config, speed, from, to float64) time.Duration {
+	if speed <= 0 {
+		return slewDuration(cfg, azimuthTicks(cfg, from, to))
+	}
+	diff := math.Abs(math.Mod(to-from+540, 360) - 180)
+	return time.Duration(diff / speed * float64(time.Second))
+}
+
+// azimuthTicks returns the encoder ticks of the shortest slew between two
+// azimuths.
+func azimuthTicks(cfg dome.Config, from, to float64) int {
+	diff := math.Abs(math.Mod(to-from+540, 360) - 180)
+	return int(math.Ceil(diff / 360 * float64(cfg.TicksPerTurn)))
+}
+
+// watchdog detects runaway slews: the dome still moving long after the
+// commanded slew should have ended, such as with a slipping encoder wheel or
+// a lost home sensor. Once a runaway slew is aborted, new motions are
+// rejected until an operator acknowledges it.
+type watchdog struct {
+	mu        sync.Mutex
+	started   time.Time     // Start of the tracked slew, zero if none
+	expected  time.Duration // Expected duration of the tracked slew
+	trippedAt time.Time     // Time of the last runaway abort, zero once acknowledged
+}
+
+// expect tracks a commanded slew with its expected duration.
+func (w *watchdog) expect(expected time.Duration, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.started = now
+	w.expected = expected
+}
+
+// check follows the telemetry and reports, once, a slew that lasts longer
+// than the limit, with its duration. Slews not commanded by the driver, such
+// as from the controller buttons, are expected to take up to fullTurn.
+func (w *watchdog) check(slewing bool, fullTurn, margin time.Duration, now time.Time) (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !slewing {
+		// The controller reports a commanded slew with its next telemetry.
+		if !w.started.IsZero() && now.Sub(w.started) > motionSettle {
+			w.started = time.Time{}
+		}
+		return 0, false
+	}
+
+	if w.started.IsZero() {
+		w.started = now
+		w.expected = fullTurn
+	}
+
+	elapsed := now.Sub(w.started)
+	if elapsed <= runawayFactor*w.expected+margin {
+		return 0, false
+	}
+
+	w.started = time.Time{}
+	w.trippedAt = now
+	return elapsed, true
+}
+
+// allow returns an error if a runaway slew has not been acknowledged.
+func (w *watchdog) allow() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.trippedAt.IsZero() {
+		return nil
+	}
+	return errors.Errorf(errors.ErrInvalidOperation, "a runaway slew was aborted at %s, acknowledge it with the %s action before moving the dome",
+		w.trippedAt.Format(time.TimeOnly), actionAcknowledgeRunaway)
+}
+
+// tripped reports whether a runaway slew waits for an acknowledgment.
+func (w *watchdog) tripped() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return !w.trippedAt.IsZero()
+}
+
+// acknowledge clears a runaway slew and reports whether there was one.
+func (w *watchdog) acknowledge() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	tripped := !w.trippedAt.IsZero()
+	w.trippedAt = time.Time{}
+	return tripped
+}
+
+// checkRunaway aborts the slew in progress if the watchdog reports it as a
+// runaway.
+func (d *Driver) checkRunaway(ctrl *dome.Dome, st dome.Status, cfg Config, now time.Time) {
+	if cfg.RunawayWatchdogDisabled {
+		return
+	}
+
+	fullTurn := slewDuration(cfg.Config, cfg.TicksPerTurn)
+	elapsed, runaway := d.watchdog.check(st.Slewing, fullTurn, cfg.runawayMargin(), now)
+	if !runaway {
+		return
+	}
+
+	d.logger.Errorf("Runaway slew: the dome is still moving after %s, aborting", elapsed.Round(time.Second))
+	if err := ctrl.AbortSlew(); err != nil {
+		d.logger.Errorf("Failed to abort the runaway slew: %v", err)
+	}
+	d.arbiter.release()
+
+	alpaca.Publish(alpaca.Event{
+		Type:         alpaca.EventError,
+		Device:       d.name,
+		Message:      fmt.Sprintf("Runaway slew aborted after %s, new slews are blocked until acknowledged", elapsed.Round(time.Second)),
+		Notification: notify.EventRunawaySlew,
+	})
+}
+
+// acknowledgeRunaway allows new motions after a runaway slew.
+func (d *Driver) acknowledgeRunaway() (string, error) {
+	if !d.watchdog.acknowledge() {
+		return "no runaway slew to acknowledge", nil
+	}
+	d.logger.Warn("Runaway slew acknowledged")
+	return "runaway slew acknowledged", nil
+}
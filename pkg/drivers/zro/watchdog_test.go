@@ -0,0 +1,93 @@
+package zro
+
+import (
+	"alpaca/pkg/alpaca/errors"
+	"alpaca/pkg/dome"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlewDuration(t *testing.T) {
+	cfg := dome.DefaultConfig()
+	cfg.TicksPerTurn = 1000
+	cfg.MaxSpeed = 100
+
+	assert.Equal(t, 10*time.Second, slewDuration(cfg, cfg.TicksPerTurn))
+	assert.Equal(t, 250, azimuthTicks(cfg, 10, 100))
+	assert.Equal(t, 50, azimuthTicks(cfg, 350, 8), "the shortest way wraps around north")
+}
+
+func TestSlewEstimate(t *testing.T) {
+	cfg := dome.DefaultConfig()
+	cfg.TicksPerTurn = 1000
+	cfg.MaxSpeed = 100
+
+	assert.Equal(t, 2500*time.Millisecond, slewEstimate(cfg, 0, 10, 100), "at the maximum speed without recorded slews")
+	assert.Equal(t, 9*time.Second, slewEstimate(cfg, 10, 10, 100))
+	assert.Equal(t, 2*time.Second, slewEstimate(cfg, 10, 350, 10), "the shortest way wraps around north")
+}
+
+func TestWatchdog(t *testing.T) {
+	var w watchdog
+	now := time.Now()
+	margin := 30 * time.Second
+
+	// A commanded slew of 10s may last up to 50s.
+	w.expect(10*time.Second, now)
+	_, runaway := w.check(true, time.Minute, margin, now.Add(50*time.Second))
+	assert.False(t, runaway)
+	elapsed, runaway := w.check(true, time.Minute, margin, now.Add(51*time.Second))
+	assert.True(t, runaway)
+	assert.Equal(t, 51*time.Second, elapsed)
+	assert.True(t, w.tripped())
+
+	var alpacaErr errors.Error
+	require.ErrorAs(t, w.allow(), &alpacaErr)
+	assert.Equal(t, errors.ErrInvalidOperation.Number, alpacaErr.Number)
+
+	assert.True(t, w.acknowledge())
+	assert.NoError(t, w.allow())
+	assert.False(t, w.acknowledge(), "already acknowledged")
+
+	// A slew that ends in time is forgotten once the controller reported it.
+	now = now.Add(time.Hour)
+	w.expect(10*time.Second, now)
+	_, runaway = w.check(false, time.Minute, margin, now.Add(motionSettle/2))
+	assert.False(t, runaway)
+	_, runaway = w.check(false, time.Minute, margin, now.Add(2*motionSettle))
+	assert.False(t, runaway)
+	assert.True(t, w.started.IsZero())
+
+	// An uncommanded slew may take a full turn.
+	now = now.Add(time.Hour)
+	_, runaway = w.check(true, time.Minute, margin, now)
+	assert.False(t, runaway)
+	_, runaway = w.check(true, time.Minute, margin, now.Add(2*time.Minute+margin))
+	assert.False(t, runaway)
+	_, runaway = w.check(true, time.Minute, margin, now.Add(3*time.Minute))
+	assert.True(t, runaway)
+}
+
+func TestRunawayBlocksMotion(t *testing.T) {
+	d := newConnectedDriver(t)
+
+	now := time.Now()
+	d.watchdog.expect(time.Second, now)
+	_, runaway := d.watchdog.check(true, time.Minute, time.Second, now.Add(time.Minute))
+	require.True(t, runaway)
+
+	var alpacaErr errors.Error
+	require.ErrorAs(t, d.SlewToAzimuth(90), &alpacaErr)
+	assert.Equal(t, errors.ErrInvalidOperation.Number, alpacaErr.Number)
+	assert.Error(t, d.FindHome())
+	assert.Error(t, d.Park())
+	assert.Equal(t, motionIdle, d.arbiter.current())
+
+	msg, err := d.Action(actionAcknowledgeRunaway, "")
+	require.NoError(t, err)
+	assert.Equal(t, "runaway slew acknowledged", msg)
+	assert.False(t, d.watchdog.tripped())
+}
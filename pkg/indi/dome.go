@@ -0,0 +1,261 @@
+package indi
+
+import (
+	"alpaca/pkg/alpaca"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// domeInterface is the DRIVER_INTERFACE of the domes, DOME_INTERFACE in the
+// INDI library.
+const domeInterface = 1 << 5
+
+// Groups of the properties, the tabs of the control panel of KStars.
+const (
+	groupMain = "Main Control"
+	groupInfo = "General Info"
+)
+
+// slewTimeout is the time a slew, a home search or a shutter motion may take,
+// in seconds.
+const slewTimeout = 120
+
+// device is a dome served as an INDI device.
+type device struct {
+	name string
+	dome alpaca.Dome
+
+	mu   sync.Mutex
+	last []vector // Properties last sent to the clients, nil before the first client
+}
+
+// enabled reports whether the dome is served: a dome disabled on its setup
+// page is hidden from the INDI clients, as from the Alpaca ones.
+func (d *device) enabled() bool {
+	dis, ok := d.dome.(alpaca.Disabler)
+	return !ok || !dis.Disabled()
+}
+
+// snapshot returns the properties last sent to the clients, taken now if
+// none were.
+func (d *device) snapshot() []vector {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.last == nil {
+		d.last = d.properties()
+	}
+	return d.last
+}
+
+// properties returns the properties of the dome: its connection and driver
+// until it connects, then the standard dome properties its capabilities
+// allow.
+func (d *device) properties() []vector {
+	driver := d.dome.DriverInfo()
+	connected := d.dome.Connected()
+
+	connection := vector{
+		kind: "Switch", name: "CONNECTION", label: "Connection", group: groupMain, rule: "OneOfMany", timeout: 60,
+		state: stateOk,
+		elements: []element{
+			{name: "CONNECT", label: "Connect", value: switchValue(connected)},
+			{name: "DISCONNECT", label: "Disconnect", value: switchValue(!connected)},
+		},
+	}
+	if d.dome.Connecting() {
+		connection.state = stateBusy
+	}
+	properties := []vector{connection, {
+		kind: "Text", name: "DRIVER_INFO", label: "Driver Info", group: groupInfo, perm: "ro", state: stateIdle,
+		elements: []element{
+			{name: "DRIVER_NAME", label: "Name", value: driver.Name},
+			{name: "DRIVER_EXEC", label: "Exec", value: "zro-alpaca"},
+			{name: "DRIVER_VERSION", label: "Version", value: driver.Version},
+			{name: "DRIVER_INTERFACE", label: "Interface", value: strconv.Itoa(domeInterface)},
+		},
+	}}
+	if !connected {
+		return properties
+	}
+
+	caps := d.dome.Capabilities()
+	status := d.dome.Status()
+
+	position := vector{
+		kind: "Number", name: "ABS_DOME_POSITION", label: "Absolute position", group: groupMain, perm: "ro", timeout: slewTimeout,
+		state: stateOk,
+		elements: []element{
+			{name: "DOME_ABSOLUTE_POSITION", label: "Degrees", value: formatNumber(status.Azimuth), format: "%6.2f", max: 360, step: 1},
+		},
+	}
+	if caps.CanSetAzimuth {
+		position.perm = "rw"
+	}
+	if status.Slewing {
+		position.state = stateBusy
+	}
+	properties = append(properties, position, vector{
+		kind: "Switch", name: "DOME_ABORT_MOTION", label: "Abort Motion", group: groupMain, rule: "AtMostOne", state: stateIdle,
+		elements: []element{{name: "ABORT", label: "Abort", value: off}},
+	})
+
+	if caps.CanPark {
+		properties = append(properties, vector{
+			kind: "Switch", name: "DOME_PARK", label: "Parking", group: groupMain, rule: "OneOfMany", timeout: slewTimeout,
+			state: stateOk,
+			elements: []element{
+				{name: "PARK", label: "Park(ed)", value: switchValue(status.AtPark)},
+				{name: "UNPARK", label: "UnPark(ed)", value: switchValue(!status.AtPark)},
+			},
+		})
+	}
+	if caps.CanFindHome || caps.CanPark {
+		var elements []element
+		if caps.CanFindHome {
+			elements = append(elements, element{name: "DOME_HOME", label: "Home", value: off})
+		}
+		if caps.CanPark {
+			elements = append(elements, element{name: "DOME_PARK", label: "Park", value: off})
+		}
+		properties = append(properties, vector{
+			kind: "Switch", name: "DOME_GOTO", label: "Goto", group: groupMain, rule: "AtMostOne", timeout: slewTimeout,
+			state: stateIdle, elements: elements,
+		})
+	}
+
+	if caps.CanSetShutter {
+		shutter := vector{
+			kind: "Switch", name: "DOME_SHUTTER", label: "Shutter", group: groupMain, rule: "OneOfMany", timeout: slewTimeout,
+			state: stateOk,
+			elements: []element{
+				{name: "SHUTTER_OPEN", label: "Open", value: switchValue(status.Shutter == alpaca.ShutterOpen || status.Shutter == alpaca.ShutterOpening)},
+				{name: "SHUTTER_CLOSE", label: "Close", value: switchValue(status.Shutter == alpaca.ShutterClosed || status.Shutter == alpaca.ShutterClosing)},
+			},
+		}
+		switch status.Shutter {
+		case alpaca.ShutterOpening, alpaca.ShutterClosing:
+			shutter.state = stateBusy
+		case alpaca.ShutterError:
+			shutter.state = stateAlert
+		}
+		properties = append(properties, shutter)
+	}
+	return properties
+}
+
+func switchValue(b bool) string {
+	if b {
+		return on
+	}
+	return off
+}
+
+// action returns the call of the dome setting a property to the values sent
+// by a client.
+func (d *device) action(name string, values map[string]string) (func() error, error) {
+	switch name {
+	case "CONNECTION":
+		switch {
+		case values["CONNECT"] == on:
+			return d.dome.Connect, nil
+		case values["DISCONNECT"] == on:
+			return d.dome.Disconnect, nil
+		}
+	case "ABS_DOME_POSITION":
+		azimuth, err := strconv.ParseFloat(strings.TrimSpace(values["DOME_ABSOLUTE_POSITION"]), 64)
+		if err != nil || azimuth < 0 || azimuth > 360 {
+			return nil, fmt.Errorf("invalid azimuth %q", values["DOME_ABSOLUTE_POSITION"])
+		}
+		return func() error { return d.dome.SlewToAzimuth(azimuth) }, nil
+	case "DOME_ABORT_MOTION":
+		if values["ABORT"] == on {
+			return d.dome.AbortSlew, nil
+		}
+	case "DOME_PARK":
+		switch {
+		case values["PARK"] == on:
+			return d.dome.Park, nil
+		case values["UNPARK"] == on:
+			// An Alpaca dome leaves its park position with the next slew.
+			return func() error { return nil }, nil
+		}
+	case "DOME_GOTO":
+		switch {
+		case values["DOME_HOME"] == on:
+			return d.dome.FindHome, nil
+		case values["DOME_PARK"] == on:
+			return d.dome.Park, nil
+		}
+	case "DOME_SHUTTER":
+		switch {
+		case values["SHUTTER_OPEN"] == on:
+			return func() error { return d.dome.SetShutter(alpaca.ShutterCommandOpen) }, nil
+		case values["SHUTTER_CLOSE"] == on:
+			return func() error { return d.dome.SetShutter(alpaca.ShutterCommandClose) }, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown property %s", name)
+	}
+	return nil, fmt.Errorf("no switch of %s is on", name)
+}
+
+// command sets a property of a device to the values sent by a client. The
+// property turns Busy while the dome runs the command, then gets the state
+// of the dome, or Alert with the error.
+func (s *Server) command(c *client, name, property string, values map[string]string, logger log.FieldLogger) {
+	d := s.device(name)
+	if d == nil {
+		return
+	}
+	if !d.enabled() {
+		logger.Warnf("INDI client set the property %s of the disabled %s", property, name)
+		return
+	}
+	current, ok := find(d.properties(), property)
+	if !ok {
+		logger.Warnf("INDI client set the unknown property %s of %s", property, name)
+		return
+	}
+
+	run, err := d.action(property, values)
+	if err == nil && current.perm == "ro" {
+		err = fmt.Errorf("%s is read only", property)
+	}
+	if err != nil {
+		current.state = stateAlert
+		c.send(current.set(d.name, err.Error(), time.Now()))
+		return
+	}
+
+	logger.Infof("INDI command %s of %s", property, name)
+	busy := current
+	busy.state = stateBusy
+	busy.elements = make([]element, len(current.elements))
+	for i, e := range current.elements {
+		if v, ok := values[e.name]; ok {
+			e.value = v
+		} else if current.kind == "Switch" {
+			e.value = off
+		}
+		busy.elements[i] = e
+	}
+	s.broadcast(d.name, busy.set(d.name, "", time.Now()))
+
+	go func() {
+		if err := run(); err != nil {
+			logger.Warnf("INDI command %s of %s failed: %v", property, name, err)
+			if v, ok := find(d.properties(), property); ok {
+				current = v
+			}
+			current.state = stateAlert
+			s.broadcast(d.name, current.set(d.name, err.Error(), time.Now()))
+			return
+		}
+		s.update(d, property)
+	}()
+}
@@ -0,0 +1,498 @@
+// Package indi serves the domes to INDI clients, such as KStars and Ekos,
+// next to the Alpaca API, for the Linux imaging stacks that do not speak
+// Alpaca. It implements the part of the INDI protocol a dome needs: the
+// clients ask for the properties of the devices, then get their updates and
+// set the numbers and switches of the dome standard properties.
+//
+// Reference: https://www.indilib.org/develop/developer-manual/104-scripting.html
+// and the INDI white paper, http://www.clearskyinstitute.com/INDI/INDI.pdf
+package indi
+
+import (
+	"alpaca/pkg/alpaca"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultPort is the port of the INDI servers.
+	DefaultPort = 7624
+
+	// protocolVersion is the version of the INDI protocol served.
+	protocolVersion = "1.7"
+
+	// pollInterval is the period of the updates of the properties.
+	pollInterval = time.Second
+
+	// writeTimeout bounds the writes to a client, so a stalled client does
+	// not hold the updates of the others.
+	writeTimeout = 5 * time.Second
+
+	// timestampFormat is the format of the INDI timestamps, in UTC.
+	timestampFormat = "2006-01-02T15:04:05"
+)
+
+// Property states.
+const (
+	stateIdle  = "Idle"
+	stateOk    = "Ok"
+	stateBusy  = "Busy"
+	stateAlert = "Alert"
+)
+
+// Switch values.
+const (
+	on  = "On"
+	off = "Off"
+)
+
+// vector is a property of a device: a vector of switches, numbers or texts.
+type vector struct {
+	kind     string // "Switch", "Number" or "Text"
+	name     string
+	label    string
+	group    string
+	perm     string // "ro", "wo" or "rw"
+	rule     string // Of the switches: "OneOfMany", "AtMostOne" or "AnyOfMany"
+	timeout  int    // Seconds the changes may take
+	state    string
+	elements []element
+}
+
+// element is a switch, number or text of a vector.
+type element struct {
+	name  string
+	label string
+	value string // "On" or "Off", a number or a text
+
+	format         string // Of the numbers, a printf format
+	min, max, step float64
+}
+
+// equal reports whether two snapshots of a vector tell the same to the
+// clients.
+func (v vector) equal(o vector) bool {
+	if v.state != o.state || len(v.elements) != len(o.elements) {
+		return false
+	}
+	for i := range v.elements {
+		if v.elements[i].name != o.elements[i].name || v.elements[i].value != o.elements[i].value {
+			return false
+		}
+	}
+	return true
+}
+
+// xmlVector is a def or set vector message.
+type xmlVector struct {
+	XMLName   xml.Name
+	Device    string       `xml:"device,attr"`
+	Name      string       `xml:"name,attr"`
+	Label     string       `xml:"label,attr,omitempty"`
+	Group     string       `xml:"group,attr,omitempty"`
+	State     string       `xml:"state,attr"`
+	Perm      string       `xml:"perm,attr,omitempty"`
+	Rule      string       `xml:"rule,attr,omitempty"`
+	Timeout   string       `xml:"timeout,attr,omitempty"`
+	Timestamp string       `xml:"timestamp,attr"`
+	Message   string       `xml:"message,attr,omitempty"`
+	Elements  []xmlElement `xml:",any"`
+}
+
+type xmlElement struct {
+	XMLName xml.Name
+	Name    string `xml:"name,attr"`
+	Label   string `xml:"label,attr,omitempty"`
+	Format  string `xml:"format,attr,omitempty"`
+	Min     string `xml:"min,attr,omitempty"`
+	Max     string `xml:"max,attr,omitempty"`
+	Step    string `xml:"step,attr,omitempty"`
+	Value   string `xml:",chardata"`
+}
+
+// def returns the message defining a vector of a device.
+func (v vector) def(device string, now time.Time) xmlVector {
+	m := xmlVector{
+		XMLName:   xml.Name{Local: "def" + v.kind + "Vector"},
+		Device:    device,
+		Name:      v.name,
+		Label:     v.label,
+		Group:     v.group,
+		State:     v.state,
+		Perm:      v.perm,
+		Rule:      v.rule,
+		Timestamp: now.UTC().Format(timestampFormat),
+	}
+	if v.kind == "Switch" && v.perm == "" {
+		m.Perm = "rw"
+	}
+	if v.timeout > 0 {
+		m.Timeout = strconv.Itoa(v.timeout)
+	}
+	for _, e := range v.elements {
+		x := xmlElement{XMLName: xml.Name{Local: "def" + v.kind}, Name: e.name, Label: e.label, Value: e.value}
+		if v.kind == "Number" {
+			x.Format = e.format
+			x.Min, x.Max, x.Step = formatNumber(e.min), formatNumber(e.max), formatNumber(e.step)
+		}
+		m.Elements = append(m.Elements, x)
+	}
+	return m
+}
+
+// set returns the message updating a vector of a device, with an optional
+// message for the user.
+func (v vector) set(device string, message string, now time.Time) xmlVector {
+	m := xmlVector{
+		XMLName:   xml.Name{Local: "set" + v.kind + "Vector"},
+		Device:    device,
+		Name:      v.name,
+		State:     v.state,
+		Timestamp: now.UTC().Format(timestampFormat),
+		Message:   message,
+	}
+	for _, e := range v.elements {
+		m.Elements = append(m.Elements, xmlElement{XMLName: xml.Name{Local: "one" + v.kind}, Name: e.name, Value: e.value})
+	}
+	return m
+}
+
+// xmlDelete deletes a property of a device, or the device without a name.
+type xmlDelete struct {
+	XMLName   xml.Name `xml:"delProperty"`
+	Device    string   `xml:"device,attr"`
+	Name      string   `xml:"name,attr,omitempty"`
+	Timestamp string   `xml:"timestamp,attr"`
+}
+
+// xmlRequest is a message of a client: getProperties, or a new vector
+// setting the values of a property.
+type xmlRequest struct {
+	XMLName  xml.Name
+	Version  string `xml:"version,attr"`
+	Device   string `xml:"device,attr"`
+	Name     string `xml:"name,attr"`
+	Elements []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// client is a connection of an INDI client.
+type client struct {
+	conn net.Conn
+
+	mu      sync.Mutex // Serializes the messages
+	all     bool       // True if the client asked for the properties of every device
+	devices map[string]bool
+}
+
+// send writes messages to the client.
+func (c *client) send(messages ...any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	enc := xml.NewEncoder(c.conn)
+	for _, m := range messages {
+		if err := enc.Encode(m); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(c.conn, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watch subscribes the client to the updates of a device, of every device
+// without a name.
+func (c *client) watch(device string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if device == "" {
+		c.all = true
+	} else {
+		c.devices[device] = true
+	}
+}
+
+func (c *client) watches(device string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.all || c.devices[device]
+}
+
+// Server serves domes to INDI clients.
+type Server struct {
+	devices []*device
+	logger  log.FieldLogger
+
+	mu      sync.Mutex // Protects clients
+	clients map[*client]struct{}
+}
+
+// NewServer returns a server of domes. Each dome is an INDI device named
+// after the Alpaca device, with its number if several domes share a name.
+// The disabled domes are not served; the check is done on each message since
+// devices are disabled from their setup page.
+func NewServer(domes []alpaca.Dome, logger log.FieldLogger) *Server {
+	s := &Server{logger: logger, clients: make(map[*client]struct{})}
+
+	names := make(map[string]int)
+	for _, dome := range domes {
+		names[dome.DeviceInfo().Name]++
+	}
+	for _, dome := range domes {
+		info := dome.DeviceInfo()
+		name := info.Name
+		if names[name] > 1 {
+			name = fmt.Sprintf("%s %d", name, info.Number)
+		}
+		s.devices = append(s.devices, &device{name: name, dome: dome})
+	}
+	return s
+}
+
+// ListenAndServe serves the INDI clients on a TCP address until the context
+// is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve serves the INDI clients of a listener until the context is
+// cancelled, then closes the listener and the connections.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	s.logger.Infof("INDI server listening on %s", ln.Addr())
+
+	// The server stops with the context, or when the listener fails.
+	serveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.poll(serveCtx)
+	}()
+	go func() {
+		<-serveCtx.Done()
+		ln.Close()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for c := range s.clients {
+			c.conn.Close()
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			cancel()
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		c := &client{conn: conn, devices: make(map[string]bool)}
+		s.mu.Lock()
+		s.clients[c] = struct{}{}
+		s.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveClient(c)
+		}()
+	}
+}
+
+// serveClient reads the messages of a client until it disconnects.
+func (s *Server) serveClient(c *client) {
+	logger := s.logger.WithField("remote", c.conn.RemoteAddr().String())
+	logger.Info("INDI client connected")
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		s.mu.Unlock()
+		c.conn.Close()
+		logger.Info("INDI client disconnected")
+	}()
+
+	dec := xml.NewDecoder(c.conn)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				logger.Debugf("INDI client stream ended: %v", err)
+			}
+			return
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var req xmlRequest
+		if err := dec.DecodeElement(&req, &start); err != nil {
+			logger.Warnf("Invalid INDI message: %v", err)
+			return
+		}
+		if err := s.handle(c, req, logger); err != nil {
+			logger.Debugf("Failed to answer the INDI client: %v", err)
+			return
+		}
+	}
+}
+
+// handle answers a message of a client.
+func (s *Server) handle(c *client, req xmlRequest, logger log.FieldLogger) error {
+	switch req.XMLName.Local {
+	case "getProperties":
+		if req.Version != "" && req.Version != protocolVersion {
+			logger.Debugf("INDI client of protocol version %s", req.Version)
+		}
+		c.watch(req.Device)
+		return s.define(c, req.Device, req.Name)
+	case "newSwitchVector", "newNumberVector", "newTextVector":
+		values := make(map[string]string, len(req.Elements))
+		for _, e := range req.Elements {
+			values[e.Name] = e.Value
+		}
+		s.command(c, req.Device, req.Name, values, logger)
+		return nil
+	default:
+		// The BLOBs and the snooping of the other devices are not served.
+		return nil
+	}
+}
+
+// define sends the properties of a device, of every device without a name,
+// restricted to one property with a name.
+func (s *Server) define(c *client, device, name string) error {
+	now := time.Now()
+	var messages []any
+	for _, d := range s.devices {
+		if (device != "" && d.name != device) || !d.enabled() {
+			continue
+		}
+		for _, v := range d.snapshot() {
+			if name == "" || v.name == name {
+				messages = append(messages, v.def(d.name, now))
+			}
+		}
+	}
+	return c.send(messages...)
+}
+
+// device returns the device of a name, nil if there is none.
+func (s *Server) device(name string) *device {
+	for _, d := range s.devices {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// broadcast sends messages about a device to the clients watching it.
+func (s *Server) broadcast(device string, messages ...any) {
+	if len(messages) == 0 {
+		return
+	}
+	s.mu.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	for _, c := range clients {
+		if !c.watches(device) {
+			continue
+		}
+		if err := c.send(messages...); err != nil {
+			s.logger.Debugf("Failed to update the INDI client %s: %v", c.conn.RemoteAddr(), err)
+			c.conn.Close()
+		}
+	}
+}
+
+// poll sends the changes of the properties until the context is cancelled.
+func (s *Server) poll(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, d := range s.devices {
+				if d.enabled() {
+					s.update(d, "")
+				}
+			}
+		}
+	}
+}
+
+// update sends the properties of a device defined, deleted or changed since
+// the last update, and the property forced even if it did not change, to
+// close the Busy state of a command.
+func (s *Server) update(d *device, forced string) {
+	now := time.Now()
+	properties := d.properties()
+
+	d.mu.Lock()
+	var messages []any
+	// Without a snapshot, no client was sent the properties yet.
+	if d.last != nil {
+		for _, v := range properties {
+			last, ok := find(d.last, v.name)
+			switch {
+			case !ok:
+				messages = append(messages, v.def(d.name, now))
+			case !v.equal(last) || v.name == forced:
+				messages = append(messages, v.set(d.name, "", now))
+			}
+		}
+		for _, v := range d.last {
+			if _, ok := find(properties, v.name); !ok {
+				messages = append(messages, xmlDelete{Device: d.name, Name: v.name, Timestamp: now.UTC().Format(timestampFormat)})
+			}
+		}
+	}
+	d.last = properties
+	d.mu.Unlock()
+
+	s.broadcast(d.name, messages...)
+}
+
+// find returns the vector of a name.
+func find(vectors []vector, name string) (vector, bool) {
+	for _, v := range vectors {
+		if v.name == name {
+			return v, true
+		}
+	}
+	return vector{}, false
+}
@@ -0,0 +1,262 @@
+package indi
+
+import (
+	"alpaca/internal/mocks"
+	"alpaca/pkg/alpaca"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient is an INDI client reading the messages of a server.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	dec  *xml.Decoder
+}
+
+func (c *testClient) write(message string) {
+	c.t.Helper()
+	_, err := fmt.Fprintln(c.conn, message)
+	require.NoError(c.t, err)
+}
+
+// readUntil reads the messages until one of a kind and property, which it
+// returns.
+func (c *testClient) readUntil(kind, name string) xmlVector {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		tok, err := c.dec.Token()
+		require.NoError(c.t, err, "waiting for %s %s", kind, name)
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		var m xmlVector
+		require.NoError(c.t, c.dec.DecodeElement(&m, &start))
+		if m.XMLName.Local == kind && m.Name == name {
+			return m
+		}
+	}
+}
+
+func values(m xmlVector) map[string]string {
+	v := make(map[string]string, len(m.Elements))
+	for _, e := range m.Elements {
+		v[e.Name] = e.Value
+	}
+	return v
+}
+
+func TestServer(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		connected bool
+		azimuth   = 90.0
+	)
+	slewed := make(chan float64, 1)
+	dome := &mocks.Dome{
+		DeviceInfoFunc: func() alpaca.DeviceInfo { return alpaca.DeviceInfo{Name: "ZRO Dome", Type: alpaca.DeviceTypeDome} },
+		DriverInfoFunc: func() alpaca.DriverInfo { return alpaca.DriverInfo{Name: "ZRO", Version: "1.2.3"} },
+		ConnectedFunc: func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			return connected
+		},
+		ConnectFunc: func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			connected = true
+			return nil
+		},
+		CapabilitiesFunc: func() alpaca.DomeCapabilities {
+			return alpaca.DomeCapabilities{CanSetAzimuth: true, CanPark: true, CanFindHome: true, CanSetShutter: true}
+		},
+		StatusFunc: func() alpaca.DomeStatus {
+			mu.Lock()
+			defer mu.Unlock()
+			return alpaca.DomeStatus{Azimuth: azimuth, Shutter: alpaca.ShutterClosed}
+		},
+		SlewToAzimuthFunc: func(az float64) error {
+			mu.Lock()
+			azimuth = az
+			mu.Unlock()
+			slewed <- az
+			return nil
+		},
+		SetShutterFunc: func(alpaca.ShutterCommand) error { return errors.New("shutter battery low") },
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- NewServer([]alpaca.Dome{dome}, log.StandardLogger()).Serve(ctx, ln) }()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-done)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	c := &testClient{t: t, conn: conn, dec: xml.NewDecoder(conn)}
+
+	// A disconnected dome only has its connection and driver.
+	c.write(`<getProperties version="1.7"/>`)
+	m := c.readUntil("defSwitchVector", "CONNECTION")
+	assert.Equal(t, "ZRO Dome", m.Device)
+	assert.Equal(t, map[string]string{"CONNECT": "Off", "DISCONNECT": "On"}, values(m))
+	m = c.readUntil("defTextVector", "DRIVER_INFO")
+	assert.Equal(t, "1.2.3", values(m)["DRIVER_VERSION"])
+	assert.Equal(t, "32", values(m)["DRIVER_INTERFACE"])
+
+	// Once connected, the dome properties are defined.
+	c.write(`<newSwitchVector device="ZRO Dome" name="CONNECTION"><oneSwitch name="CONNECT">On</oneSwitch></newSwitchVector>`)
+	m = c.readUntil("setSwitchVector", "CONNECTION")
+	assert.Equal(t, stateBusy, m.State)
+	assert.Equal(t, map[string]string{"CONNECT": "On", "DISCONNECT": "Off"}, values(m))
+	m = c.readUntil("defNumberVector", "ABS_DOME_POSITION")
+	assert.Equal(t, "90", values(m)["DOME_ABSOLUTE_POSITION"])
+	assert.Equal(t, "rw", m.Perm)
+
+	c.write(`<newNumberVector device="ZRO Dome" name="ABS_DOME_POSITION"><oneNumber name="DOME_ABSOLUTE_POSITION">123.5</oneNumber></newNumberVector>`)
+	select {
+	case az := <-slewed:
+		assert.Equal(t, 123.5, az)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no slew")
+	}
+	m = c.readUntil("setNumberVector", "ABS_DOME_POSITION")
+	assert.Equal(t, stateBusy, m.State)
+	m = c.readUntil("setNumberVector", "ABS_DOME_POSITION")
+	assert.Equal(t, stateOk, m.State)
+	assert.Equal(t, "123.5", values(m)["DOME_ABSOLUTE_POSITION"])
+
+	// Invalid values and the errors of the dome turn the property to Alert.
+	c.write(`<newNumberVector device="ZRO Dome" name="ABS_DOME_POSITION"><oneNumber name="DOME_ABSOLUTE_POSITION">400</oneNumber></newNumberVector>`)
+	m = c.readUntil("setNumberVector", "ABS_DOME_POSITION")
+	assert.Equal(t, stateAlert, m.State)
+	assert.Contains(t, m.Message, "invalid azimuth")
+
+	c.write(`<newSwitchVector device="ZRO Dome" name="DOME_SHUTTER"><oneSwitch name="SHUTTER_OPEN">On</oneSwitch><oneSwitch name="SHUTTER_CLOSE">Off</oneSwitch></newSwitchVector>`)
+	m = c.readUntil("setSwitchVector", "DOME_SHUTTER")
+	assert.Equal(t, stateBusy, m.State)
+	m = c.readUntil("setSwitchVector", "DOME_SHUTTER")
+	assert.Equal(t, stateAlert, m.State)
+	assert.Equal(t, "shutter battery low", m.Message)
+	assert.Equal(t, map[string]string{"SHUTTER_OPEN": "Off", "SHUTTER_CLOSE": "On"}, values(m), "the state of the dome")
+}
+
+func TestDeviceNames(t *testing.T) {
+	dome := func(name string, number int) alpaca.Dome {
+		return &mocks.Dome{DeviceInfoFunc: func() alpaca.DeviceInfo { return alpaca.DeviceInfo{Name: name, Number: number} }}
+	}
+	s := NewServer([]alpaca.Dome{dome("ZRO Dome", 0), dome("ZRO Dome", 1), dome("Roll-off Roof", 2)}, log.StandardLogger())
+
+	var names []string
+	for _, d := range s.devices {
+		names = append(names, d.name)
+	}
+	assert.Equal(t, []string{"ZRO Dome 0", "ZRO Dome 1", "Roll-off Roof"}, names)
+}
+
+// failingListener fails to accept connections.
+type failingListener struct{ net.Listener }
+
+func (l failingListener) Accept() (net.Conn, error) { return nil, errors.New("too many open files") }
+
+func TestServeListenerError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() { done <- NewServer(nil, log.StandardLogger()).Serve(context.Background(), failingListener{ln}) }()
+	select {
+	case err := <-done:
+		assert.EqualError(t, err, "too many open files")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return the error of the listener")
+	}
+}
+
+// disablerDome is a dome disabled from its setup page.
+type disablerDome struct {
+	*mocks.Dome
+	mu       sync.Mutex
+	disabled bool
+}
+
+func (d *disablerDome) Disabled() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.disabled
+}
+
+func TestServerDisabledDome(t *testing.T) {
+	connects := make(chan struct{}, 1)
+	dome := &disablerDome{Dome: &mocks.Dome{
+		DeviceInfoFunc: func() alpaca.DeviceInfo { return alpaca.DeviceInfo{Name: "ZRO Dome", Type: alpaca.DeviceTypeDome} },
+		DriverInfoFunc: func() alpaca.DriverInfo { return alpaca.DriverInfo{Name: "ZRO"} },
+		ConnectedFunc:  func() bool { return false },
+		ConnectFunc: func() error {
+			connects <- struct{}{}
+			return nil
+		},
+	}}
+	s := NewServer([]alpaca.Dome{dome}, log.StandardLogger())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, ln) }()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-done)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	c := &testClient{t: t, conn: conn, dec: xml.NewDecoder(conn)}
+
+	c.write(`<getProperties version="1.7"/>`)
+	c.readUntil("defTextVector", "DRIVER_INFO")
+
+	// Once disabled on its setup page, the dome ignores the commands.
+	dome.mu.Lock()
+	dome.disabled = true
+	dome.mu.Unlock()
+	c.write(`<newSwitchVector device="ZRO Dome" name="CONNECTION"><oneSwitch name="CONNECT">On</oneSwitch></newSwitchVector>`)
+	select {
+	case <-connects:
+		t.Fatal("a disabled dome was connected")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// Nor is it defined to the clients.
+	c.write(`<getProperties version="1.7"/>`)
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	for {
+		tok, err := c.dec.Token()
+		if err != nil {
+			var netErr net.Error
+			assert.True(t, errors.As(err, &netErr) && netErr.Timeout(), err)
+			break
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			t.Fatalf("a disabled dome is not defined, got %s", start.Name.Local)
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Package metrics exports counters, histograms and gauges in the Prometheus
+// text format, for Grafana dashboards of the domes. It only implements what
+// the server needs, without the dependencies of the Prometheus client.
+package metrics
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Label is a label of a metric sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Gauge is a value sampled when the metrics are scraped.
+type Gauge struct {
+	Name   string
+	Help   string
+	Labels []Label
+	Value  float64
+}
+
+// metric is a family of samples sharing a name.
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds the metrics exported by an endpoint.
+type Registry struct {
+	mu         sync.Mutex
+	metrics    []metric
+	collectors map[int]func() []Gauge
+	nextID     int
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[int]func() []Gauge)}
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the registry of the server.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Collect registers a function sampling gauges at every scrape. It returns
+// a function unregistering it.
+func (r *Registry) Collect(fn func() []Gauge) (unregister func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID
+	r.nextID++
+	r.collectors[id] = fn
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.collectors, id)
+	}
+}
+
+// Write writes every metric in the Prometheus text format, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := slices.Clone(r.metrics)
+	collectors := make([]func() []Gauge, 0, len(r.collectors))
+	for _, collect := range r.collectors {
+		collectors = append(collectors, collect)
+	}
+	r.mu.Unlock()
+
+	var gauges []Gauge
+	for _, collect := range collectors {
+		gauges = append(gauges, collect()...)
+	}
+	byName := make(map[string][]Gauge)
+	for _, g := range gauges {
+		byName[g.Name] = append(byName[g.Name], g)
+	}
+	for name, samples := range byName {
+		metrics = append(metrics, gaugeFamily{family: name, samples: samples})
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	bw := bufio.NewWriter(w)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the metrics of the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// CounterVec is a counter per set of label values.
+type CounterVec struct {
+	family string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// NewCounterVec registers a counter with labels in the default registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family: name, help: help, labels: labels, values: make(map[string]float64)}
+	defaultRegistry.register(c)
+	return c
+}
+
+// Inc adds one to the counter of the label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	key := joinLabels(c.labels, labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key]++
+}
+
+func (c *CounterVec) name() string { return c.family }
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeHeader(w, c.family, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.family, braces(key), formatValue(c.values[key]))
+	}
+}
+
+// HistogramVec is a histogram per set of label values.
+type HistogramVec struct {
+	family  string
+	help    string
+	labels  []string
+	buckets []float64 // Upper bounds, increasing
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // Observations per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram with labels in the default
+// registry. The buckets are the increasing upper bounds of the observations;
+// the +Inf bucket is added.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{family: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histogram)}
+	defaultRegistry.register(h)
+	return h
+}
+
+// Observe adds a value to the histogram of the label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := joinLabels(h.labels, labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *HistogramVec) name() string { return h.family }
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeHeader(w, h.family, h.help, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.family, braces(appendLabel(key, "le", formatValue(le))), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.family, braces(appendLabel(key, "le", "+Inf")), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.family, braces(key), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.family, braces(key), s.count)
+	}
+}
+
+// gaugeFamily is the gauges of a name sampled by the collectors.
+type gaugeFamily struct {
+	family  string
+	samples []Gauge
+}
+
+func (g gaugeFamily) name() string { return g.family }
+
+func (g gaugeFamily) write(w io.Writer) {
+	var help string
+	lines := make([]string, 0, len(g.samples))
+	for _, s := range g.samples {
+		help = cmp.Or(help, s.Help)
+		var key string
+		for _, l := range s.Labels {
+			key = appendLabel(key, l.Name, l.Value)
+		}
+		lines = append(lines, fmt.Sprintf("%s%s %s\n", g.family, braces(key), formatValue(s.Value)))
+	}
+	slices.Sort(lines)
+	writeHeader(w, g.family, help, "gauge")
+	for _, line := range lines {
+		io.WriteString(w, line)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	if help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// joinLabels formats the labels of a sample, the key of its series.
+func joinLabels(names, values []string) string {
+	var key string
+	for i, name := range names {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		key = appendLabel(key, name, value)
+	}
+	return key
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func appendLabel(key, name, value string) string {
+	label := name + `="` + labelEscaper.Replace(value) + `"`
+	if key == "" {
+		return label
+	}
+	return key + "," + label
+}
+
+func braces(key string) string {
+	if key == "" {
+		return ""
+	}
+	return "{" + key + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
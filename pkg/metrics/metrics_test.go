@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	requests := &CounterVec{family: "test_requests_total", help: "Requests.", labels: []string{"method"}, values: make(map[string]float64)}
+	durations := &HistogramVec{family: "test_duration_seconds", labels: []string{"method"}, buckets: []float64{0.1, 1}, series: make(map[string]*histogram)}
+	r.register(requests)
+	r.register(durations)
+	unregister := r.Collect(func() []Gauge {
+		return []Gauge{
+			{Name: "test_voltage", Labels: []Label{{Name: "device", Value: `dome/"1"`}}, Value: 12.5},
+			{Name: "test_voltage", Help: "Voltage.", Labels: []Label{{Name: "device", Value: "dome/0"}}, Value: 11},
+		}
+	})
+
+	requests.Inc("azimuth")
+	requests.Inc("azimuth")
+	requests.Inc("park")
+	durations.Observe(0.05, "park")
+	durations.Observe(0.5, "park")
+	durations.Observe(3, "park")
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Write(&buf))
+	assert.Equal(t, `# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{method="park",le="0.1"} 1
+test_duration_seconds_bucket{method="park",le="1"} 2
+test_duration_seconds_bucket{method="park",le="+Inf"} 3
+test_duration_seconds_sum{method="park"} 3.55
+test_duration_seconds_count{method="park"} 3
+# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{method="azimuth"} 2
+test_requests_total{method="park"} 1
+# HELP test_voltage Voltage.
+# TYPE test_voltage gauge
+test_voltage{device="dome/0"} 11
+test_voltage{device="dome/\"1\""} 12.5
+`, buf.String())
+
+	unregister()
+	buf.Reset()
+	require.NoError(t, r.Write(&buf))
+	assert.NotContains(t, buf.String(), "test_voltage")
+}
@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+)
+
+// Config is the configuration of the built-in sinks and of the routes.
+// A sink is enabled when its settings are filled in.
+type Config struct {
+	WebhookURL   string `json:"webhook_url"`   // URL receiving the events as a JSON POST
+	MQTTBroker   string `json:"mqtt_broker"`   // Broker of the MQTT sink, e.g. tcp://localhost:1883
+	MQTTUsername string `json:"mqtt_username"` // Username for the MQTT broker
+	MQTTPassword string `json:"mqtt_password"` // Password for the MQTT broker
+	MQTTTopic    string `json:"mqtt_topic"`    // Topic the events are published to
+
+	SMTPHost     string   `json:"smtp_host"`     // Mail server of the email sink
+	SMTPPort     int      `json:"smtp_port"`     // Mail server port
+	SMTPSecurity string   `json:"smtp_security"` // Connection security: starttls, tls or none
+	SMTPUsername string   `json:"smtp_username"` // Username for the mail server, empty to skip authentication
+	SMTPPassword string   `json:"smtp_password"` // Password for the mail server
+	SMTPFrom     string   `json:"smtp_from"`     // Sender address
+	SMTPTo       []string `json:"smtp_to"`       // Recipient addresses
+
+	TelegramToken   string  `json:"telegram_token"`    // Telegram bot token, empty to disable the bot
+	TelegramChatIDs []int64 `json:"telegram_chat_ids"` // Chats receiving the events and allowed to send commands
+
+	Routes map[EventType][]string `json:"routes"` // Sinks receiving each event type
+}
+
+// DefaultConfig routes every event to the log.
+func DefaultConfig() Config {
+	routes := make(map[EventType][]string, len(EventTypes))
+	for _, event := range EventTypes {
+		routes[event] = []string{SinkLog}
+	}
+	return Config{
+		SMTPPort:     587,
+		SMTPSecurity: SMTPStartTLS,
+		Routes:       routes,
+	}
+}
+
+// SinkNames returns the names of the built-in sinks, in the order shown in
+// the setup page.
+func SinkNames() []string {
+	return []string{SinkLog, SinkWebhook, SinkMQTT, SinkEmail, SinkTelegram}
+}
+
+// Routed reports whether the event type is routed to the sink.
+func (c Config) Routed(event EventType, sink string) bool {
+	return slices.Contains(c.Routes[event], sink)
+}
+
+func (c *Config) Validate() error {
+	if c.WebhookURL != "" {
+		u, err := url.Parse(c.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q", c.WebhookURL)
+		}
+	}
+	if (c.MQTTBroker == "") != (c.MQTTTopic == "") {
+		return fmt.Errorf("the MQTT sink needs both a broker and a topic")
+	}
+	if c.SMTPHost != "" {
+		if err := c.validateSMTP(); err != nil {
+			return err
+		}
+	}
+	if c.TelegramToken != "" && len(c.TelegramChatIDs) == 0 {
+		return fmt.Errorf("the Telegram bot needs at least one allowed chat")
+	}
+	for event, sinks := range c.Routes {
+		if !slices.Contains(EventTypes, event) {
+			return fmt.Errorf("unknown event type %q", event)
+		}
+		for _, sink := range sinks {
+			if !slices.Contains(SinkNames(), sink) {
+				return fmt.Errorf("unknown sink %q", sink)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateSMTP() error {
+	switch c.SMTPSecurity {
+	case SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		return fmt.Errorf("invalid SMTP security %q", c.SMTPSecurity)
+	}
+	if c.SMTPPort <= 0 || c.SMTPPort > 65535 {
+		return fmt.Errorf("invalid SMTP port %d", c.SMTPPort)
+	}
+	if _, err := mail.ParseAddress(c.SMTPFrom); err != nil {
+		return fmt.Errorf("invalid sender address %q", c.SMTPFrom)
+	}
+	if len(c.SMTPTo) == 0 {
+		return fmt.Errorf("the email sink needs at least one recipient")
+	}
+	for _, to := range c.SMTPTo {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient address %q", to)
+		}
+	}
+	return nil
+}
+
+// Configure replaces the built-in sinks and the routes of the notifier.
+// The Telegram sink is registered by the bot itself, since it needs the dome.
+func (n *Notifier) Configure(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	n.AddSink(NewLogSink(n.logger))
+
+	if cfg.WebhookURL != "" {
+		n.AddSink(NewWebhookSink(cfg.WebhookURL))
+	} else {
+		n.RemoveSink(SinkWebhook)
+	}
+
+	if cfg.MQTTBroker != "" {
+		n.AddSink(NewMQTTSink(cfg.MQTTBroker, cfg.MQTTUsername, cfg.MQTTPassword, cfg.MQTTTopic))
+	} else {
+		n.RemoveSink(SinkMQTT)
+	}
+
+	if cfg.SMTPHost != "" {
+		n.AddSink(NewSMTPSink(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPSecurity, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.SMTPTo))
+	} else {
+		n.RemoveSink(SinkEmail)
+	}
+
+	n.SetRoutes(cfg.Routes)
+	return nil
+}
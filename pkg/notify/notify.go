@@ -0,0 +1,197 @@
+// Package notify delivers events, such as a lost connection or a low
+// battery, to notification sinks like webhooks or MQTT topics.
+//
+// Event producers only call Notify. Which sinks receive each event type is
+// decided by the routes of the Notifier, so new channels can be added
+// without touching the producers.
+package notify
+
+import (
+	"alpaca/pkg/timeline"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EventType identifies the kind of an event. Routes are configured per type.
+type EventType string
+
+const (
+	EventConnected      EventType = "connected"       // A device connected to its controller
+	EventConnectionLost EventType = "connection_lost" // A device lost the connection to its controller
+	EventLowBattery     EventType = "low_battery"     // The shutter battery is running low
+	EventSafetyClose    EventType = "safety_close"    // The shutter was closed for safety
+	EventShutterError   EventType = "shutter_error"   // The shutter reported an error
+	EventRunawaySlew    EventType = "runaway_slew"    // A slew lasting far longer than expected was aborted
+
+	EventShutterOvercurrent EventType = "shutter_overcurrent" // The shutter was aborted for drawing too much current
+	EventBrokerFailover     EventType = "broker_failover"     // A device connected to another MQTT broker than the last one
+)
+
+// EventTypes lists all the event types, in the order shown in the setup page.
+var EventTypes = []EventType{
+	EventConnected,
+	EventConnectionLost,
+	EventLowBattery,
+	EventSafetyClose,
+	EventShutterError,
+	EventRunawaySlew,
+	EventShutterOvercurrent,
+	EventBrokerFailover,
+}
+
+// Event is a notification produced by a device or the server.
+type Event struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Device  string    `json:"device,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Sink delivers events to a notification channel.
+// Send is called from its own goroutine and must honor the context deadline.
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, event Event) error
+}
+
+// closer is implemented by sinks holding resources, such as a connection,
+// that must be released when they are replaced.
+type closer interface {
+	Close()
+}
+
+// sendTimeout is the time given to a sink to deliver an event.
+const sendTimeout = 10 * time.Second
+
+// Notifier fans out events to the sinks routed for their type.
+type Notifier struct {
+	mu     sync.RWMutex
+	sinks  map[string]Sink
+	routes map[EventType][]string
+	logger log.FieldLogger
+	wg     sync.WaitGroup
+}
+
+func NewNotifier(logger log.FieldLogger) *Notifier {
+	return &Notifier{
+		sinks:  make(map[string]Sink),
+		routes: make(map[EventType][]string),
+		logger: logger,
+	}
+}
+
+// AddSink registers a sink, replacing any sink with the same name.
+func (n *Notifier) AddSink(sink Sink) {
+	n.mu.Lock()
+	old := n.sinks[sink.Name()]
+	n.sinks[sink.Name()] = sink
+	n.mu.Unlock()
+
+	if c, ok := old.(closer); ok {
+		c.Close()
+	}
+}
+
+// RemoveSink unregisters the sink with the given name.
+func (n *Notifier) RemoveSink(name string) {
+	n.mu.Lock()
+	old := n.sinks[name]
+	delete(n.sinks, name)
+	n.mu.Unlock()
+
+	if c, ok := old.(closer); ok {
+		c.Close()
+	}
+}
+
+// Sinks returns the names of the registered sinks.
+func (n *Notifier) Sinks() []string {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	names := make([]string, 0, len(n.sinks))
+	for name := range n.sinks {
+		names = append(names, name)
+	}
+	return names
+}
+
+// SetRoutes sets the sinks that receive each event type. Event types without
+// a route are dropped.
+func (n *Notifier) SetRoutes(routes map[EventType][]string) {
+	copied := make(map[EventType][]string, len(routes))
+	for event, sinks := range routes {
+		copied[event] = append([]string(nil), sinks...)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.routes = copied
+}
+
+// Notify sends the event to the sinks routed for its type. Delivery is
+// asynchronous, so a slow sink never blocks the producer; failures are
+// logged.
+func (n *Notifier) Notify(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	n.mu.RLock()
+	var sinks []Sink
+	for _, name := range n.routes[event.Type] {
+		if sink, ok := n.sinks[name]; ok {
+			sinks = append(sinks, sink)
+		}
+	}
+	n.mu.RUnlock()
+
+	for _, sink := range sinks {
+		n.wg.Add(1)
+		go func(sink Sink) {
+			defer n.wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+
+			if err := sink.Send(ctx, event); err != nil {
+				n.logger.Errorf("Failed to send %s event to %s: %v", event.Type, sink.Name(), err)
+			}
+		}(sink)
+	}
+}
+
+// Wait blocks until the events sent so far have been delivered.
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// defaultNotifier is the notifier used by the package-level functions.
+var defaultNotifier = NewNotifier(log.WithField("component", "notify"))
+
+// Default returns the notifier used by the package-level functions.
+func Default() *Notifier {
+	return defaultNotifier
+}
+
+// Notify sends an event through the default notifier and records it in the
+// timeline.
+func Notify(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	timeline.Record(timeline.Entry{
+		Time:    event.Time,
+		Kind:    timeline.KindEvent,
+		Device:  event.Device,
+		Summary: fmt.Sprintf("%s: %s", event.Type, event.Message),
+		Data:    event,
+	})
+	defaultNotifier.Notify(event)
+}
@@ -0,0 +1,178 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordSink keeps the events it receives.
+type recordSink struct {
+	name string
+
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *recordSink) Name() string { return s.name }
+
+func (s *recordSink) Send(ctx context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordSink) received() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Event(nil), s.events...)
+}
+
+func TestNotifierRoutes(t *testing.T) {
+	n := NewNotifier(log.StandardLogger())
+	a := &recordSink{name: "a"}
+	b := &recordSink{name: "b"}
+	n.AddSink(a)
+	n.AddSink(b)
+	n.SetRoutes(map[EventType][]string{
+		EventLowBattery:     {"a", "b"},
+		EventConnectionLost: {"b", "missing"},
+	})
+
+	n.Notify(Event{Type: EventLowBattery, Message: "battery"})
+	n.Notify(Event{Type: EventConnectionLost, Message: "lost"})
+	n.Notify(Event{Type: EventConnected, Message: "not routed"})
+	n.Wait()
+
+	require.Len(t, a.received(), 1)
+	assert.Equal(t, EventLowBattery, a.received()[0].Type)
+	assert.False(t, a.received()[0].Time.IsZero())
+	assert.Len(t, b.received(), 2)
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan Event, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		received <- event
+	}))
+	defer ts.Close()
+
+	sink := NewWebhookSink(ts.URL)
+	require.NoError(t, sink.Send(context.Background(), Event{Type: EventSafetyClose, Device: "dome", Message: "closing"}))
+
+	event := <-received
+	assert.Equal(t, EventSafetyClose, event.Type)
+	assert.Equal(t, "dome", event.Device)
+
+	ts.Close()
+	err := NewWebhookSink(ts.URL+"/hooks/webhook-secret").Send(context.Background(), Event{Type: EventShutterError})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "webhook-secret", "the URL is not quoted")
+}
+
+func TestConfigValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.NoError(t, cfg.Validate())
+	assert.True(t, cfg.Routed(EventLowBattery, SinkLog))
+	assert.False(t, cfg.Routed(EventLowBattery, SinkWebhook))
+
+	cfg.WebhookURL = "ftp://example.com"
+	assert.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig()
+	cfg.MQTTBroker = "tcp://localhost:1883"
+	assert.Error(t, cfg.Validate(), "broker without topic")
+
+	cfg = DefaultConfig()
+	cfg.Routes[EventLowBattery] = []string{"pager"}
+	assert.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig()
+	cfg.SMTPHost = "smtp.example.com"
+	cfg.SMTPFrom = "dome@example.com"
+	assert.Error(t, cfg.Validate(), "no recipients")
+	cfg.SMTPTo = []string{"operator@example.com"}
+	assert.NoError(t, cfg.Validate())
+	cfg.SMTPSecurity = "ssl"
+	assert.Error(t, cfg.Validate())
+}
+
+// fakeSMTPServer accepts a single SMTP session and returns the message data.
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	data := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 Go ahead")
+				lines, _ := tp.ReadDotLines()
+				data <- strings.Join(lines, "\n")
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				return
+			default:
+				tp.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	return ln.Addr().String(), data
+}
+
+func TestSMTPSink(t *testing.T) {
+	addr, data := fakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(addr)
+	portNum, _ := strconv.Atoi(port)
+
+	sink := NewSMTPSink(host, portNum, SMTPNone, "", "", "Dome <dome@example.com>", []string{"operator@example.com"})
+	err := sink.Send(context.Background(), Event{
+		Type:    EventLowBattery,
+		Time:    time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC),
+		Device:  "ZRO Dome",
+		Message: "Shutter battery at 11.50 V",
+	})
+	require.NoError(t, err)
+
+	message := <-data
+	assert.Contains(t, message, "Subject: [ZRO Alpaca] low_battery - ZRO Dome")
+	assert.Contains(t, message, "To: operator@example.com")
+	assert.Contains(t, message, "Shutter battery at 11.50 V")
+}
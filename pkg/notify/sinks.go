@@ -0,0 +1,153 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	log "github.com/sirupsen/logrus"
+)
+
+// Names of the built-in sinks.
+const (
+	SinkLog      = "log"
+	SinkWebhook  = "webhook"
+	SinkMQTT     = "mqtt"
+	SinkEmail    = "email"
+	SinkTelegram = "telegram" // Registered by the Telegram bot when it is enabled
+)
+
+// LogSink writes events to the log.
+type LogSink struct {
+	logger log.FieldLogger
+}
+
+func NewLogSink(logger log.FieldLogger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+func (s *LogSink) Name() string { return SinkLog }
+
+func (s *LogSink) Send(ctx context.Context, event Event) error {
+	s.logger.WithFields(log.Fields{
+		"event":  event.Type,
+		"device": event.Device,
+	}).Warn(event.Message)
+	return nil
+}
+
+// WebhookSink posts events as JSON to a URL.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{}}
+}
+
+func (s *WebhookSink) Name() string { return SinkWebhook }
+
+func (s *WebhookSink) Send(ctx context.Context, event Event) error {
+	body, _ := json.Marshal(event)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The error of the client quotes the URL, which holds the secret of
+		// the webhook.
+		if urlErr, ok := err.(*url.Error); ok {
+			return fmt.Errorf("webhook request failed: %w", urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// MQTTSink publishes events as JSON to a topic. The connection to the broker
+// is opened on the first event, so an unreachable broker does not delay the
+// server start.
+type MQTTSink struct {
+	opts  *mqtt.ClientOptions
+	topic string
+
+	mu     sync.Mutex
+	client mqtt.Client
+}
+
+func NewMQTTSink(broker, username, password, topic string) *MQTTSink {
+	opts := mqtt.NewClientOptions()
+	opts.SetClientID("zro-alpaca-notify")
+	opts.AddBroker(broker)
+	opts.SetUsername(username)
+	opts.SetPassword(password)
+
+	return &MQTTSink{opts: opts, topic: topic}
+}
+
+func (s *MQTTSink) Name() string { return SinkMQTT }
+
+func (s *MQTTSink) Send(ctx context.Context, event Event) error {
+	client, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload, _ := json.Marshal(event)
+	token := client.Publish(s.topic, 1, false, payload)
+
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *MQTTSink) connect(ctx context.Context) (mqtt.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil && s.client.IsConnectionOpen() {
+		return s.client, nil
+	}
+
+	client := mqtt.NewClient(s.opts)
+	token := client.Connect()
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return nil, fmt.Errorf("failed to connect to MQTT broker: %v", err)
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.client = client
+	return client, nil
+}
+
+func (s *MQTTSink) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		s.client.Disconnect(100)
+		s.client = nil
+	}
+}
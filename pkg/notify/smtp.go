@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Security modes of the SMTP connection.
+const (
+	SMTPStartTLS = "starttls" // Plain connection upgraded with STARTTLS, usually on port 587
+	SMTPTLS      = "tls"      // Implicit TLS, usually on port 465
+	SMTPNone     = "none"     // No encryption, only for local relays
+)
+
+// SMTPSink emails events to the observatory operators.
+type SMTPSink struct {
+	host     string
+	port     int
+	security string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func NewSMTPSink(host string, port int, security, username, password, from string, to []string) *SMTPSink {
+	return &SMTPSink{
+		host:     host,
+		port:     port,
+		security: security,
+		username: username,
+		password: password,
+		from:     from,
+		to:       to,
+	}
+}
+
+func (s *SMTPSink) Name() string { return SinkEmail }
+
+func (s *SMTPSink) Send(ctx context.Context, event Event) error {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", s.host, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.security == SMTPStartTLS {
+		if err := c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	}
+
+	// PlainAuth refuses to send the password over an unencrypted
+	// connection, except to localhost.
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, s.host)); err != nil {
+			return fmt.Errorf("authentication failed: %v", err)
+		}
+	}
+
+	if err := c.Mail(envelopeAddress(s.from)); err != nil {
+		return err
+	}
+	for _, to := range s.to {
+		if err := c.Rcpt(envelopeAddress(to)); err != nil {
+			return fmt.Errorf("recipient %s rejected: %v", to, err)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(formatEmail(s.from, s.to, event)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s *SMTPSink) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+
+	if s.security == SMTPTLS {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: s.host}}
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+// envelopeAddress returns the bare address of "Name <address>", as required
+// by the MAIL and RCPT commands.
+func envelopeAddress(addr string) string {
+	if a, err := mail.ParseAddress(addr); err == nil {
+		return a.Address
+	}
+	return addr
+}
+
+// formatEmail builds a plain text email for an event.
+func formatEmail(from string, to []string, event Event) []byte {
+	subject := fmt.Sprintf("[ZRO Alpaca] %s", event.Type)
+	if event.Device != "" {
+		subject += " - " + event.Device
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", event.Time.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\n", event.Message)
+	fmt.Fprintf(&b, "Event:  %s\r\n", event.Type)
+	if event.Device != "" {
+		fmt.Fprintf(&b, "Device: %s\r\n", event.Device)
+	}
+	fmt.Fprintf(&b, "Time:   %s\r\n", event.Time.Format(time.RFC3339))
+	return b.Bytes()
+}
@@ -0,0 +1,234 @@
+// Package syslog sends structured records to a syslog server or to the
+// systemd journal, for the observatory hosts that collect their logs
+// centrally. It does not depend on the Alpaca code.
+package syslog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority is the severity of a record, as defined by RFC 5424.
+type Priority int
+
+const (
+	Emerg Priority = iota
+	Alert
+	Crit
+	Err
+	Warning
+	Notice
+	Info
+	Debug
+)
+
+// facilityDaemon is the facility of the records, that of system daemons.
+const facilityDaemon = 3
+
+// journalSocket is the socket of the native protocol of systemd-journald.
+const journalSocket = "/run/systemd/journal/socket"
+
+// TargetJournal is the target of the local systemd journal.
+const TargetJournal = "journald"
+
+// Record is a message with its priority and its structured fields. The
+// field names are made of upper case letters, digits and underscores, as
+// the journal requires.
+type Record struct {
+	Time     time.Time
+	Priority Priority
+	Message  string
+	Fields   map[string]string
+}
+
+// Writer sends records to a log collector.
+type Writer interface {
+	Write(Record) error
+	Close() error
+}
+
+// Dial opens a target: "journald" for the local systemd journal, or the URL
+// of a syslog server receiving RFC 5424 messages, such as udp://host:514,
+// tcp://host:514 or unix:///dev/log. The records are tagged with the
+// application name.
+func Dial(target, tag string) (Writer, error) {
+	if target == TargetJournal {
+		conn, err := net.Dial("unixgram", journalSocket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the journal: %v", err)
+		}
+		return &journalWriter{conn: conn, tag: tag}, nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog target %q: %v", target, err)
+	}
+	var network, address string
+	switch u.Scheme {
+	case "udp", "tcp":
+		network, address = u.Scheme, u.Host
+	case "unix":
+		network, address = "unixgram", u.Path
+	default:
+		return nil, fmt.Errorf("invalid syslog target %q: expected journald, udp://, tcp:// or unix://", target)
+	}
+	if address == "" {
+		return nil, fmt.Errorf("invalid syslog target %q: missing address", target)
+	}
+
+	hostname, _ := os.Hostname()
+	w := &syslogWriter{network: network, address: address, tag: tag, hostname: hostname}
+	if err := w.dial(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// syslogWriter sends RFC 5424 messages to a syslog server. Over TCP, the
+// messages are framed by their length, as in RFC 6587, and the connection is
+// opened again once after a failed write.
+type syslogWriter struct {
+	network  string
+	address  string
+	tag      string
+	hostname string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (w *syslogWriter) dial() error {
+	conn, err := net.DialTimeout(w.network, w.address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog server %s: %v", w.address, err)
+	}
+	w.conn = conn
+	return nil
+}
+
+func (w *syslogWriter) Write(r Record) error {
+	msg := formatRFC5424(r, w.hostname, w.tag, os.Getpid())
+	if w.network == "tcp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn != nil {
+		if _, err := w.conn.Write([]byte(msg)); err == nil || w.network != "tcp" {
+			return err
+		}
+		w.conn.Close()
+	}
+	if err := w.dial(); err != nil {
+		w.conn = nil
+		return err
+	}
+	_, err := w.conn.Write([]byte(msg))
+	return err
+}
+
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// formatRFC5424 formats a record as an RFC 5424 message, with the fields as
+// the parameters of a structured data element named after the documentation
+// enterprise number.
+func formatRFC5424(r Record, hostname, tag string, pid int) string {
+	if hostname == "" {
+		hostname = "-"
+	}
+	if tag == "" {
+		tag = "-"
+	}
+
+	sd := "-"
+	if len(r.Fields) > 0 {
+		var b strings.Builder
+		b.WriteString("[alpaca@32473")
+		for _, name := range sortedNames(r.Fields) {
+			fmt.Fprintf(&b, ` %s="%s"`, strings.ToLower(name), escapeParam(r.Fields[name]))
+		}
+		b.WriteString("]")
+		sd = b.String()
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d - %s %s",
+		facilityDaemon*8+int(r.Priority), r.Time.UTC().Format(time.RFC3339Nano), hostname, tag, pid, sd, r.Message)
+}
+
+// escapeParam escapes the characters of a structured data parameter value.
+func escapeParam(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// journalWriter sends records to the local journal with its native protocol,
+// which keeps the fields searchable with journalctl.
+type journalWriter struct {
+	conn net.Conn
+	tag  string
+}
+
+func (w *journalWriter) Write(r Record) error {
+	_, err := w.conn.Write(encodeJournal(r, w.tag))
+	return err
+}
+
+func (w *journalWriter) Close() error {
+	return w.conn.Close()
+}
+
+// encodeJournal encodes a record in the native journal protocol: one
+// NAME=value line per field, or the name, the length and the value for the
+// values spanning several lines.
+func encodeJournal(r Record, tag string) []byte {
+	var b bytes.Buffer
+	field := func(name, value string) {
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&b, "%s=%s\n", name, value)
+			return
+		}
+		b.WriteString(name + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(value)))
+		b.WriteString(value + "\n")
+	}
+
+	field("MESSAGE", r.Message)
+	field("PRIORITY", fmt.Sprint(int(r.Priority)))
+	field("SYSLOG_FACILITY", fmt.Sprint(facilityDaemon))
+	if tag != "" {
+		field("SYSLOG_IDENTIFIER", tag)
+	}
+	for _, name := range sortedNames(r.Fields) {
+		field(name, r.Fields[name])
+	}
+	return b.Bytes()
+}
+
+func sortedNames(fields map[string]string) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,63 @@
+package syslog
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRecord = Record{
+	Time:     time.Date(2026, 3, 1, 22, 15, 30, 0, time.UTC),
+	Priority: Warning,
+	Message:  "Lost connection to MQTT broker",
+	Fields:   map[string]string{"ALPACA_EVENT": "disconnected", "ALPACA_DEVICE": `ZRO "Dome"`},
+}
+
+func TestFormatRFC5424(t *testing.T) {
+	assert.Equal(t,
+		`<28>1 2026-03-01T22:15:30Z obs1 zro-alpaca 42 - [alpaca@32473 alpaca_device="ZRO \"Dome\"" alpaca_event="disconnected"] Lost connection to MQTT broker`,
+		formatRFC5424(testRecord, "obs1", "zro-alpaca", 42))
+
+	assert.Equal(t, "<30>1 2026-03-01T22:15:30Z - - 42 - - Connected",
+		formatRFC5424(Record{Time: testRecord.Time, Priority: Info, Message: "Connected"}, "", "", 42))
+}
+
+func TestEncodeJournal(t *testing.T) {
+	msg := string(encodeJournal(testRecord, "zro-alpaca"))
+	assert.Equal(t, "MESSAGE=Lost connection to MQTT broker\nPRIORITY=4\nSYSLOG_FACILITY=3\nSYSLOG_IDENTIFIER=zro-alpaca\n"+
+		"ALPACA_DEVICE=ZRO \"Dome\"\nALPACA_EVENT=disconnected\n", msg)
+
+	msg = string(encodeJournal(Record{Message: "two\nlines"}, ""))
+	assert.True(t, strings.HasPrefix(msg, "MESSAGE\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\n"), "%q", msg)
+}
+
+func TestDial(t *testing.T) {
+	for _, target := range []string{"", "http://host:514", "udp://", "tcp"} {
+		_, err := Dial(target, "test")
+		assert.Error(t, err, target)
+	}
+}
+
+func TestWriteTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	w, err := Dial("tcp://"+ln.Addr().String(), "zro-alpaca")
+	require.NoError(t, err)
+	defer w.Close()
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, w.Write(testRecord))
+	frame, err := bufio.NewReader(conn).ReadString(']')
+	require.NoError(t, err)
+	assert.Regexp(t, `^\d+ <28>1 2026-03-01T22:15:30Z `, frame, "framed by the length")
+}
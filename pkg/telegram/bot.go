@@ -0,0 +1,230 @@
+// Package telegram implements a Telegram bot that reports notification events
+// and accepts a few dome commands from whitelisted chats.
+//
+// Reference: https://core.telegram.org/bots/api
+package telegram
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/notify"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	apiURL = "https://api.telegram.org"
+
+	// pollTimeout is the long polling timeout of getUpdates.
+	pollTimeout = 30 * time.Second
+
+	// retryDelay is the wait after a failed poll.
+	retryDelay = 10 * time.Second
+)
+
+// Dome is the part of alpaca.Dome controlled by the bot.
+type Dome interface {
+	Connected() bool
+	Status() alpaca.DomeStatus
+	SetShutter(alpaca.ShutterCommand) error
+	Park() error
+}
+
+// Bot is a Telegram bot bound to a dome. It is also a notification sink that
+// sends every event to the whitelisted chats.
+type Bot struct {
+	token   string
+	chatIDs []int64 // Chats allowed to send commands and receiving events
+	dome    Dome
+	apiURL  string
+	client  *http.Client
+	logger  log.FieldLogger
+}
+
+func NewBot(token string, chatIDs []int64, dome Dome, logger log.FieldLogger) *Bot {
+	return &Bot{
+		token:   token,
+		chatIDs: chatIDs,
+		dome:    dome,
+		apiURL:  apiURL,
+		client:  &http.Client{Timeout: pollTimeout + 10*time.Second},
+		logger:  logger,
+	}
+}
+
+func (b *Bot) Name() string { return notify.SinkTelegram }
+
+// Send sends the event to every whitelisted chat.
+func (b *Bot) Send(ctx context.Context, event notify.Event) error {
+	text := fmt.Sprintf("%s: %s", event.Type, event.Message)
+	if event.Device != "" {
+		text = fmt.Sprintf("[%s] %s", event.Device, text)
+	}
+
+	var errs []string
+	for _, chatID := range b.chatIDs {
+		if err := b.sendMessage(ctx, chatID, text); err != nil {
+			errs = append(errs, fmt.Sprintf("chat %d: %v", chatID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Run polls the bot updates and answers the commands until the context is
+// cancelled.
+func (b *Bot) Run(ctx context.Context) error {
+	b.logger.Info("Telegram bot started")
+
+	offset := 0
+	for {
+		updates, err := b.getUpdates(ctx, offset)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			b.logger.Warnf("Failed to get Telegram updates: %v", err)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(retryDelay):
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message == nil || u.Message.Text == "" {
+				continue
+			}
+
+			reply := b.handleMessage(u.Message.Chat.ID, u.Message.Text)
+			if reply == "" {
+				continue
+			}
+			if err := b.sendMessage(ctx, u.Message.Chat.ID, reply); err != nil {
+				b.logger.Warnf("Failed to reply to chat %d: %v", u.Message.Chat.ID, err)
+			}
+		}
+	}
+}
+
+// handleMessage runs a command received from a chat and returns the reply,
+// empty for the chats outside the whitelist, which get no answer.
+func (b *Bot) handleMessage(chatID int64, text string) string {
+	if !slices.Contains(b.chatIDs, chatID) {
+		b.logger.Warnf("Ignoring command %q from chat %d, not in the whitelist", text, chatID)
+		return ""
+	}
+
+	// Commands may be addressed to the bot as /command@botname.
+	var command string
+	if fields := strings.Fields(text); len(fields) > 0 {
+		command, _, _ = strings.Cut(fields[0], "@")
+	}
+
+	b.logger.Infof("Telegram command %s from chat %d", command, chatID)
+
+	switch command {
+	case "/status":
+		return b.statusText()
+	case "/close":
+		if err := b.dome.SetShutter(alpaca.ShutterCommandClose); err != nil {
+			return fmt.Sprintf("Failed to close the shutter: %v", err)
+		}
+		return "Closing the shutter."
+	case "/park":
+		if err := b.dome.Park(); err != nil {
+			return fmt.Sprintf("Failed to park: %v", err)
+		}
+		return "Parking the dome."
+	default:
+		return "Commands:\n/status - dome status\n/close - close the shutter\n/park - park the dome"
+	}
+}
+
+func (b *Bot) statusText() string {
+	if !b.dome.Connected() {
+		return "Dome not connected."
+	}
+
+	st := b.dome.Status()
+	return fmt.Sprintf("Azimuth: %.1f°\nShutter: %s\nSlewing: %t\nAt park: %t\nAt home: %t",
+		st.Azimuth, st.Shutter, st.Slewing, st.AtPark, st.AtHome)
+}
+
+// update is the subset of the Telegram Update object used by the bot.
+type update struct {
+	UpdateID int `json:"update_id"`
+	Message  *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+func (b *Bot) getUpdates(ctx context.Context, offset int) ([]update, error) {
+	params := url.Values{
+		"offset":  {fmt.Sprint(offset)},
+		"timeout": {fmt.Sprint(int(pollTimeout.Seconds()))},
+	}
+
+	var updates []update
+	err := b.call(ctx, http.MethodGet, "getUpdates?"+params.Encode(), nil, &updates)
+	return updates, err
+}
+
+func (b *Bot) sendMessage(ctx context.Context, chatID int64, text string) error {
+	body, _ := json.Marshal(map[string]any{
+		"chat_id": chatID,
+		"text":    text,
+	})
+	return b.call(ctx, http.MethodPost, "sendMessage", body, nil)
+}
+
+// call calls a Bot API method and decodes its result into result, if not nil.
+func (b *Bot) call(ctx context.Context, httpMethod, method string, body []byte, result any) error {
+	req, err := http.NewRequestWithContext(ctx, httpMethod, fmt.Sprintf("%s/bot%s/%s", b.apiURL, b.token, method), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// The error contains the URL, which contains the token.
+		return fmt.Errorf("%s request failed: %v", method, strings.ReplaceAll(err.Error(), b.token, "<token>"))
+	}
+	defer resp.Body.Close()
+
+	var apiResp apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("invalid %s response: %v", method, err)
+	}
+	if !apiResp.OK {
+		return fmt.Errorf("%s failed: %s", method, apiResp.Description)
+	}
+	if result != nil {
+		return json.Unmarshal(apiResp.Result, result)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package telegram
+
+import (
+	"alpaca/pkg/alpaca"
+	"alpaca/pkg/notify"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDome struct {
+	shutter alpaca.ShutterStatus
+	parked  bool
+}
+
+func (d *fakeDome) Connected() bool { return true }
+
+func (d *fakeDome) Status() alpaca.DomeStatus {
+	return alpaca.DomeStatus{Azimuth: 123.4, Shutter: d.shutter, AtPark: d.parked}
+}
+
+func (d *fakeDome) SetShutter(cmd alpaca.ShutterCommand) error {
+	if cmd == alpaca.ShutterCommandClose {
+		d.shutter = alpaca.ShutterClosing
+	}
+	return nil
+}
+
+func (d *fakeDome) Park() error {
+	d.parked = true
+	return nil
+}
+
+func TestHandleMessage(t *testing.T) {
+	dome := &fakeDome{shutter: alpaca.ShutterOpen}
+	bot := NewBot("token", []int64{42}, dome, log.StandardLogger())
+
+	assert.Empty(t, bot.handleMessage(7, "/close"), "no answer outside the whitelist")
+	assert.Equal(t, alpaca.ShutterOpen, dome.shutter)
+
+	assert.Contains(t, bot.handleMessage(42, "/status"), "Azimuth: 123.4°")
+	assert.Contains(t, bot.handleMessage(42, "/status"), "Shutter: Open")
+
+	bot.handleMessage(42, "/close@zro_bot")
+	assert.Equal(t, alpaca.ShutterClosing, dome.shutter)
+
+	bot.handleMessage(42, "/park")
+	assert.True(t, dome.parked)
+
+	assert.Contains(t, bot.handleMessage(42, "hello"), "/status")
+	assert.Contains(t, bot.handleMessage(42, " \n\t"), "/status")
+}
+
+func TestSendEvent(t *testing.T) {
+	var sent []map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bottoken/sendMessage", r.URL.Path)
+
+		var msg map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		sent = append(sent, msg)
+		w.Write([]byte(`{"ok":true,"result":{}}`))
+	}))
+	defer ts.Close()
+
+	bot := NewBot("token", []int64{1, 2}, &fakeDome{}, log.StandardLogger())
+	bot.apiURL = ts.URL
+
+	err := bot.Send(context.Background(), notify.Event{Type: notify.EventLowBattery, Device: "ZRO Dome", Message: "11.5 V"})
+	require.NoError(t, err)
+	require.Len(t, sent, 2)
+	assert.Equal(t, "[ZRO Dome] low_battery: 11.5 V", sent[0]["text"])
+}
@@ -0,0 +1,103 @@
+// Package timeline keeps a bounded, in-memory history of the commands sent to
+// the devices, the notification events and the telemetry, and returns them as
+// a single time-ordered feed.
+package timeline
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Kind is the source of a timeline entry.
+type Kind string
+
+const (
+	KindCommand   Kind = "command"   // A command sent by a client
+	KindEvent     Kind = "event"     // A notification event
+	KindTelemetry Kind = "telemetry" // A change in a device status
+)
+
+// Entry is an item of the timeline. IDs increase with time, so they can be
+// used as paging cursors.
+type Entry struct {
+	ID      int64     `json:"ID"`
+	Time    time.Time `json:"Time"`
+	Kind    Kind      `json:"Kind"`
+	Device  string    `json:"Device,omitempty"`
+	Summary string    `json:"Summary"`
+	Data    any       `json:"Data,omitempty"`
+}
+
+// DefaultCapacity is the number of entries kept for each kind. Each kind has
+// its own buffer, so frequent telemetry does not push commands out.
+const DefaultCapacity = 1000
+
+// Timeline stores the latest entries of each kind.
+type Timeline struct {
+	mu       sync.Mutex
+	capacity int
+	lastID   int64
+	entries  map[Kind][]Entry
+}
+
+func New(capacity int) *Timeline {
+	return &Timeline{
+		capacity: capacity,
+		entries:  make(map[Kind][]Entry),
+	}
+}
+
+// Record adds an entry, assigning its ID and, if not set, its time.
+func (t *Timeline) Record(e Entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastID++
+	e.ID = t.lastID
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	entries := append(t.entries[e.Kind], e)
+	if len(entries) > t.capacity {
+		entries = slices.Delete(entries, 0, len(entries)-t.capacity)
+	}
+	t.entries[e.Kind] = entries
+}
+
+// Page returns up to limit entries older than the entry with ID before,
+// newest first. A before of 0 starts from the newest entry.
+func (t *Timeline) Page(before int64, limit int) []Entry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var page []Entry
+	for _, entries := range t.entries {
+		for _, e := range entries {
+			if before == 0 || e.ID < before {
+				page = append(page, e)
+			}
+		}
+	}
+
+	slices.SortFunc(page, func(a, b Entry) int {
+		return int(b.ID - a.ID)
+	})
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page
+}
+
+var defaultTimeline = New(DefaultCapacity)
+
+// Default returns the timeline used by the package-level functions.
+func Default() *Timeline {
+	return defaultTimeline
+}
+
+// Record adds an entry to the default timeline.
+func Record(e Entry) {
+	defaultTimeline.Record(e)
+}
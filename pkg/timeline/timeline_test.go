@@ -0,0 +1,32 @@
+package timeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPage(t *testing.T) {
+	tl := New(3)
+	tl.Record(Entry{Kind: KindCommand, Summary: "c1"})
+	for range 5 {
+		tl.Record(Entry{Kind: KindTelemetry, Summary: "t"})
+	}
+	tl.Record(Entry{Kind: KindEvent, Summary: "e1"})
+
+	page := tl.Page(0, 3)
+	if assert.Len(t, page, 3) {
+		assert.Equal(t, "e1", page[0].Summary)
+		assert.Equal(t, KindTelemetry, page[1].Kind)
+		assert.Greater(t, page[0].ID, page[1].ID)
+	}
+
+	// Only the last 3 telemetry entries are kept, but the command is not
+	// pushed out by them.
+	page = tl.Page(page[2].ID, 10)
+	if assert.Len(t, page, 2) {
+		assert.Equal(t, KindTelemetry, page[0].Kind)
+		assert.Equal(t, "c1", page[1].Summary)
+		assert.False(t, page[1].Time.IsZero())
+	}
+}
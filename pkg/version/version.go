@@ -0,0 +1,91 @@
+// Package version holds the build information of the binary. The variables
+// are set at build time with ldflags, for example:
+//
+//	go build -ldflags "-X alpaca/pkg/version.Version=1.2.0 -X alpaca/pkg/version.Commit=abc1234"
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+var (
+	Version = "dev"     // Release version
+	Commit  = "unknown" // Git commit hash
+	Date    = "unknown" // Build date
+)
+
+// Info is the build information reported by the server.
+type Info struct {
+	Version   string `json:"Version"`
+	Commit    string `json:"Commit"`
+	Date      string `json:"BuildDate"`
+	GoVersion string `json:"GoVersion"`
+	Platform  string `json:"Platform"`
+}
+
+// Get returns the build information. When the binary was built without
+// ldflags, the commit and date are taken from the VCS information embedded
+// by the Go toolchain, if any.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		Date:      Date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "unknown":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.Date == "unknown":
+				info.Date = s.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// String returns a one-line description of the build.
+func (i Info) String() string {
+	commit := i.Commit
+	if len(commit) > 12 {
+		commit = commit[:12]
+	}
+	return fmt.Sprintf("%s (commit %s, built %s, %s %s)", i.Version, commit, i.Date, i.GoVersion, i.Platform)
+}
+
+// Compare compares two release versions, such as v1.2.3, numerically. A
+// leading v and any suffix after - or +, as added by git describe, are
+// ignored, and missing or non-numeric components count as 0.
+func Compare(a, b string) int {
+	as, bs := releaseParts(a), releaseParts(b)
+	for i := range max(len(as), len(bs)) {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+func releaseParts(v string) []string {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	return strings.Split(v, ".")
+}
@@ -0,0 +1,33 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.0", "v1.2.0", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.10.0", "1.9.3", 1},
+		{"v1.2.0", "1.3.0", -1},
+		{"1.2.0", "v1.2.0-3-gabc1234-dirty", 0},
+		{"1.2.0", "dev", 1},
+	}
+
+	for _, tc := range tests {
+		got := Compare(tc.a, tc.b)
+		switch {
+		case tc.want > 0:
+			assert.Positive(t, got, "%s vs %s", tc.a, tc.b)
+		case tc.want < 0:
+			assert.Negative(t, got, "%s vs %s", tc.a, tc.b)
+		default:
+			assert.Zero(t, got, "%s vs %s", tc.a, tc.b)
+		}
+	}
+}